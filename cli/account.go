@@ -0,0 +1,80 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/urfave/cli/v2"
+
+	"claude-proxy/modules/auth/application/dto"
+	"claude-proxy/modules/auth/application/services"
+	"claude-proxy/modules/auth/infrastructure/clients"
+	"claude-proxy/modules/auth/infrastructure/repositories"
+)
+
+// AccountImport creates an account from a Claude Code credentials file, directly on the data folder
+// The refresh token is validated (and rotated) with one refresh, so this needs network access
+func AccountImport(c *cli.Context) error {
+	data, err := os.ReadFile(repositories.ExpandPath(c.String("file")))
+	if err != nil {
+		return fmt.Errorf("failed to read credentials file: %w", err)
+	}
+
+	creds, err := dto.ParseClaudeCodeCredentials(data)
+	if err != nil {
+		return err
+	}
+
+	cfg, logger, err := prepareOfflineCommand(c, false)
+	if err != nil {
+		return err
+	}
+
+	persistenceRepo, err := repositories.NewJSONAccountPersistenceRepository(
+		cfg.Storage.DataFolder, cfg.Storage.Fsync, logger,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to open account storage: %w", err)
+	}
+
+	oauthClient := clients.NewOAuthClient(
+		cfg.OAuth.ClientID,
+		cfg.OAuth.AuthorizeURL,
+		cfg.OAuth.TokenURL,
+		cfg.OAuth.RedirectURI,
+		cfg.OAuth.Scope,
+		cfg.OAuth.OrganizationsURL,
+		cfg.Retry.MaxRetries,
+		cfg.Retry.RetryDelay,
+		cfg.Claude.Headers.Map(),
+		logger,
+	)
+	accountSvc := services.NewAccountService(
+		repositories.NewMemoryAccountRepository(0, logger),
+		persistenceRepo,
+		oauthClient,
+		cfg.Accounts.NewAccountCooldown,
+		logger,
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	account, err := accountSvc.ImportAccount(ctx, c.String("name"), creds)
+	if err != nil {
+		return fmt.Errorf("failed to import credentials: %w", err)
+	}
+
+	if err := accountSvc.FinalSync(ctx); err != nil {
+		return err
+	}
+
+	fmt.Printf("Account imported\n")
+	fmt.Printf("  ID:           %s\n", account.ID)
+	fmt.Printf("  Name:         %s\n", account.Name)
+	fmt.Printf("  Organization: %s\n", account.OrganizationUUID)
+	fmt.Println("The refresh token was rotated: the client the file came from may need to log in again.")
+	return nil
+}
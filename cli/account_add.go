@@ -0,0 +1,227 @@
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"claude-proxy/config"
+	"claude-proxy/modules/auth/application/dto"
+	"claude-proxy/modules/auth/domain/entities"
+
+	"github.com/urfave/cli/v2"
+)
+
+// devicePollInterval is how often AccountAdd polls the device authorization
+const devicePollInterval = 3 * time.Second
+
+// deviceStartResponse is the part of the POST /oauth/device/start response AccountAdd uses
+type deviceStartResponse struct {
+	PollCode         string `json:"poll_code"`
+	AuthorizationURL string `json:"authorization_url"`
+	ExpiresAt        string `json:"expires_at"`
+}
+
+// devicePollResponse is the GET /oauth/device/poll/:code response
+type devicePollResponse struct {
+	Status              string                  `json:"status"`
+	DeviceAuthorization *dto.DeviceAuthResponse `json:"device_authorization"`
+	Account             *dto.AccountResponse    `json:"account"`
+}
+
+// deviceCompleteResponse is the part of the POST /oauth/device/complete response AccountAdd uses
+type deviceCompleteResponse struct {
+	RequiresOrgSelection bool                  `json:"requires_org_selection"`
+	SelectionID          string                `json:"selection_id"`
+	Organizations        []dto.OrganizationDTO `json:"organizations"`
+}
+
+// AccountAdd adds an account through the running server with a device authorization, for headless servers:
+// it prints the authorization URL to open in any browser, takes the code#state Claude shows (pasted here, or
+// sent to POST /oauth/device/complete from elsewhere) and polls until the account exists
+func AccountAdd(c *cli.Context) error {
+	cfg, err := config.LoadConfig(c.String("config"))
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	adminKey := os.Getenv(c.String("key-env"))
+	if adminKey == "" {
+		adminKey = cfg.Auth.APIKey
+	}
+	if adminKey == "" {
+		return fmt.Errorf("no admin key: set %s or auth.api_key", c.String("key-env"))
+	}
+
+	client := localClient(cfg, 45*time.Second) // Completing waits for the code exchange (up to 30s)
+	var start deviceStartResponse
+	if err := deviceRequest(client, cfg, http.MethodPost, "/oauth/device/start", adminKey, map[string]any{
+		"name":   c.String("name"),
+		"org_id": c.String("org"),
+	}, &start); err != nil {
+		return fmt.Errorf("failed to start device authorization: %w", err)
+	}
+
+	fmt.Printf("1. Open this URL in any browser and authorize with Claude:\n\n   %s\n\n", start.AuthorizationURL)
+	fmt.Printf("2. Paste the code Claude shows (code#state) here and press Enter,\n")
+	fmt.Printf("   or send it to POST /oauth/device/complete.\n\n")
+	fmt.Printf("Polling code: %s (expires %s)\n", start.PollCode, start.ExpiresAt)
+
+	lines := make(chan string)
+	go func() {
+		scanner := bufio.NewScanner(os.Stdin)
+		for scanner.Scan() {
+			lines <- strings.TrimSpace(scanner.Text())
+		}
+	}()
+
+	ticker := time.NewTicker(devicePollInterval)
+	defer ticker.Stop()
+
+	var organizations []dto.OrganizationDTO
+	var selectionID string
+	for {
+		select {
+		case line := <-lines:
+			if line == "" {
+				continue
+			}
+			if selectionID != "" {
+				if err := selectOrganization(client, cfg, selectionID, organizations, line); err != nil {
+					fmt.Printf("%v\n", err)
+				}
+				continue
+			}
+
+			var completed deviceCompleteResponse
+			err := deviceRequest(client, cfg, http.MethodPost, "/oauth/device/complete", "", map[string]any{
+				"code": line,
+			}, &completed)
+			if err != nil {
+				fmt.Printf("Code not accepted: %v\nAuthorize again and paste the new code.\n", err)
+				continue
+			}
+			if completed.RequiresOrgSelection {
+				selectionID, organizations = completed.SelectionID, completed.Organizations
+				fmt.Println("Your Claude user belongs to several organizations. Enter the number of the one to add:")
+				for i, org := range organizations {
+					fmt.Printf("  %d. %s %s\n", i+1, org.Name, org.UUID)
+				}
+			}
+
+		case <-ticker.C:
+			var poll devicePollResponse
+			path := "/oauth/device/poll/" + start.PollCode
+			if err := deviceRequest(client, cfg, http.MethodGet, path, "", nil, &poll); err != nil {
+				return fmt.Errorf("failed to poll device authorization: %w", err)
+			}
+
+			switch poll.Status {
+			case entities.DeviceAuthStatusCompleted:
+				if poll.Account != nil {
+					fmt.Printf("Account added: %s (%s), status %s\n", poll.Account.Name, poll.Account.ID, poll.Account.Status)
+				} else {
+					fmt.Printf("Account added: %s\n", poll.DeviceAuthorization.AccountID)
+				}
+				return nil
+			case entities.DeviceAuthStatusExpired:
+				return fmt.Errorf("device authorization expired before it was completed")
+			case entities.DeviceAuthStatusSelectingOrg:
+				if selectionID == "" {
+					selectionID = poll.DeviceAuthorization.SelectionID
+					fmt.Printf("Waiting for an organization to be selected (POST /oauth/select-org, selection_id %s)\n",
+						selectionID)
+				}
+			}
+		}
+	}
+}
+
+// selectOrganization finishes a device authorization with the organization picked by number (or UUID)
+func selectOrganization(
+	client *http.Client,
+	cfg *config.Config,
+	selectionID string,
+	organizations []dto.OrganizationDTO,
+	choice string,
+) error {
+	orgUUID := choice
+	if n, err := strconv.Atoi(choice); err == nil {
+		if n < 1 || n > len(organizations) {
+			return fmt.Errorf("enter a number between 1 and %d", len(organizations))
+		}
+		orgUUID = organizations[n-1].UUID
+	}
+
+	err := deviceRequest(client, cfg, http.MethodPost, "/oauth/select-org", "", map[string]any{
+		"selection_id":      selectionID,
+		"organization_uuid": orgUUID,
+	}, nil)
+	if err != nil {
+		return fmt.Errorf("failed to select organization: %w", err)
+	}
+	return nil
+}
+
+// deviceRequest sends a JSON request to the local server and decodes a successful response into out (if set)
+// Errors carry the message of the OAuth endpoints' error body
+func deviceRequest(
+	client *http.Client,
+	cfg *config.Config,
+	method, path, adminKey string,
+	body map[string]any,
+	out any,
+) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, localURL(cfg, path), reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if adminKey != "" {
+		req.Header.Set("X-API-Key", adminKey)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("server at %s not reachable: %w", serverAddress(cfg), err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		var failure struct {
+			Error json.RawMessage `json:"error"`
+		}
+		_ = json.Unmarshal(data, &failure)
+		var detail struct {
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(failure.Error, &detail) == nil && detail.Message != "" {
+			return fmt.Errorf("%s (%d)", detail.Message, resp.StatusCode)
+		}
+		return fmt.Errorf("%s returned %d: %s", path, resp.StatusCode, bytes.TrimSpace(data))
+	}
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(data, out)
+}
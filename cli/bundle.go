@@ -0,0 +1,151 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/urfave/cli/v2"
+
+	"claude-proxy/config"
+	"claude-proxy/modules/auth/application/services"
+	"claude-proxy/modules/auth/domain/entities"
+	"claude-proxy/modules/auth/domain/interfaces"
+	"claude-proxy/modules/auth/infrastructure/repositories"
+	"claude-proxy/pkg/atomicfile"
+
+	sctx "github.com/phathdt/service-context"
+)
+
+// RunExport writes every data file (accounts, tokens, sessions, usage statistics, admin keys) to an
+// encrypted state bundle, directly from the data folder
+func RunExport(c *cli.Context) error {
+	passphrase, err := bundlePassphrase(c)
+	if err != nil {
+		return err
+	}
+
+	// Reading is safe while a server runs, but its unsynced in-memory changes are not included
+	cfg, logger, err := prepareOfflineCommand(c, true)
+	if err != nil {
+		return err
+	}
+
+	sources, err := openSnapshotSources(cfg, logger)
+	if err != nil {
+		return err
+	}
+
+	bundle, manifest, err := services.ExportStateBundle(sources, passphrase, "")
+	if err != nil {
+		return fmt.Errorf("export failed: %w", err)
+	}
+
+	out := repositories.ExpandPath(c.String("out"))
+	if err := atomicfile.Write(out, bundle, 0o600, true); err != nil {
+		return fmt.Errorf("failed to write bundle: %w", err)
+	}
+
+	fmt.Printf("Exported %d files to %s (app version %s):\n", len(manifest.Files), out, manifest.AppVersion)
+	printBundleFiles(manifest.Files)
+	return nil
+}
+
+// RunImport restores an encrypted state bundle into the data folder, upgrading older data file schemas
+func RunImport(c *cli.Context) error {
+	passphrase, err := bundlePassphrase(c)
+	if err != nil {
+		return err
+	}
+
+	cfg, logger, err := prepareOfflineCommand(c, false)
+	if err != nil {
+		return err
+	}
+
+	bundle, err := os.ReadFile(repositories.ExpandPath(c.String("in")))
+	if err != nil {
+		return fmt.Errorf("failed to read bundle: %w", err)
+	}
+
+	sources, err := openSnapshotSources(cfg, logger)
+	if err != nil {
+		return err
+	}
+
+	manifest, imported, err := services.ImportStateBundle(
+		bundle, passphrase, sources, c.Bool("force"), cfg.Storage.Fsync,
+	)
+	if err != nil {
+		return fmt.Errorf("import failed: %w", err)
+	}
+
+	fmt.Printf(
+		"Imported bundle created %s by app version %s into %s:\n",
+		manifest.CreatedAt.Format("2006-01-02 15:04:05 MST"),
+		manifest.AppVersion,
+		repositories.ExpandPath(cfg.Storage.DataFolder),
+	)
+	for _, name := range imported {
+		fmt.Printf("  %s\n", name)
+	}
+	return nil
+}
+
+// bundlePassphrase reads the bundle passphrase from the environment variable named by --passphrase-env
+func bundlePassphrase(c *cli.Context) (string, error) {
+	name := c.String("passphrase-env")
+	passphrase := os.Getenv(name)
+	if passphrase == "" {
+		return "", fmt.Errorf("environment variable %s is not set", name)
+	}
+	return passphrase, nil
+}
+
+// openSnapshotSources opens the file-backed persistence repositories of every data file
+func openSnapshotSources(cfg *config.Config, logger sctx.Logger) ([]interfaces.SnapshotSource, error) {
+	dataFolder, fsync := cfg.Storage.DataFolder, cfg.Storage.Fsync
+
+	accountRepo, err := repositories.NewJSONAccountPersistenceRepository(dataFolder, fsync, logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open account storage: %w", err)
+	}
+	tokenRepo, err := repositories.NewJSONTokenRepository(dataFolder, fsync, logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open token storage: %w", err)
+	}
+	// No retention: bundles carry sessions.json exactly as it is on disk
+	sessionRepo, err := repositories.NewJSONSessionRepository(dataFolder, fsync, 0, false, logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open session storage: %w", err)
+	}
+	statsRepo, err := repositories.NewJSONUsageStatsRepository(dataFolder, fsync)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open usage statistics storage: %w", err)
+	}
+	adminKeyRepo, err := repositories.NewJSONAdminKeyRepository(dataFolder, fsync)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open admin key storage: %w", err)
+	}
+
+	var sources []interfaces.SnapshotSource
+	for _, repo := range []any{accountRepo, tokenRepo, sessionRepo, statsRepo, adminKeyRepo} {
+		if source, ok := repo.(interfaces.SnapshotSource); ok {
+			sources = append(sources, source)
+		}
+	}
+	return sources, nil
+}
+
+// printBundleFiles lists the data files of a bundle manifest
+func printBundleFiles(files map[string]entities.StateBundleFile) {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		fmt.Printf("  %s (%d bytes, schema v%d)\n", name, files[name].Size, files[name].SchemaVersion)
+	}
+}
@@ -7,7 +7,7 @@ import (
 	"claude-proxy/cmd/api"
 )
 
-// RunServer starts the API server (now the only service)
+// RunServer starts the API server (the only service; every command that serves goes through here)
 func RunServer(c *cli.Context) error {
 	configPath := c.String("config")
 	return RunServerWithConfig(configPath)
@@ -24,13 +24,3 @@ func RunServerWithConfig(configPath string) error {
 	app.Run()
 	return nil
 }
-
-// RunAPI starts the API service (same as RunServer now)
-func RunAPI(c *cli.Context) error {
-	return RunServer(c)
-}
-
-// RunAPIWithConfig starts the API service with the specified configuration
-func RunAPIWithConfig(configPath string) error {
-	return RunServerWithConfig(configPath)
-}
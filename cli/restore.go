@@ -0,0 +1,39 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/urfave/cli/v2"
+
+	"claude-proxy/config"
+	"claude-proxy/modules/auth/application/services"
+	"claude-proxy/modules/auth/infrastructure/repositories"
+)
+
+// RunRestore restores a backup archive into the data folder (server must be stopped)
+func RunRestore(c *cli.Context) error {
+	cfg, err := config.LoadConfig(c.String("config"))
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	if isServerRunning(cfg) && !c.Bool("force") {
+		return fmt.Errorf(
+			"a server appears to be running on %s; stop it first (its next sync would overwrite "+
+				"the restored files) or pass --force",
+			serverAddress(cfg),
+		)
+	}
+
+	dataFolder := repositories.ExpandPath(cfg.Storage.DataFolder)
+	restored, err := services.RestoreBackup(repositories.ExpandPath(c.String("backup")), dataFolder)
+	if err != nil {
+		return fmt.Errorf("restore failed: %w", err)
+	}
+
+	fmt.Printf("Restored into %s:\n", dataFolder)
+	for _, name := range restored {
+		fmt.Printf("  %s (previous version kept as %s.pre-restore if it existed)\n", name, name)
+	}
+	return nil
+}
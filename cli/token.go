@@ -0,0 +1,224 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/urfave/cli/v2"
+
+	"claude-proxy/config"
+	"claude-proxy/modules/auth/application/dto"
+	"claude-proxy/modules/auth/application/services"
+	"claude-proxy/modules/auth/domain/entities"
+	"claude-proxy/modules/auth/domain/interfaces"
+	"claude-proxy/modules/auth/infrastructure/repositories"
+
+	sctx "github.com/phathdt/service-context"
+	"github.com/phathdt/service-context/core"
+)
+
+// TokenCreate creates a new token with an auto-generated key and prints the key once
+func TokenCreate(c *cli.Context) error {
+	role := entities.TokenRole(c.String("role"))
+	if role != entities.TokenRoleUser && role != entities.TokenRoleAdmin && role != entities.TokenRoleViewer {
+		return fmt.Errorf("invalid role %q: must be user, admin or viewer", role)
+	}
+
+	return withTokenService(c, func(ctx context.Context, tokenSvc interfaces.TokenService) error {
+		key, err := services.GenerateTokenKey()
+		if err != nil {
+			return err
+		}
+
+		token, err := tokenSvc.CreateToken(ctx, c.String("name"), key, entities.TokenStatusActive, role)
+		if err != nil {
+			return fmt.Errorf("failed to create token: %w", err)
+		}
+
+		fmt.Printf("Token created\n")
+		fmt.Printf("  ID:   %s\n", token.ID)
+		fmt.Printf("  Name: %s\n", token.Name)
+		fmt.Printf("  Role: %s\n", token.Role)
+		fmt.Printf("  Key:  %s\n", token.Key)
+		fmt.Println("Store this key now - it will not be shown again.")
+		return nil
+	})
+}
+
+// TokenList prints all tokens as a table with masked keys
+func TokenList(c *cli.Context) error {
+	return withTokenService(c, func(ctx context.Context, tokenSvc interfaces.TokenService) error {
+		paging := core.Paging{Page: 1, Limit: 1 << 30}
+		tokens, err := tokenSvc.ListTokens(ctx, &dto.TokenQueryParams{}, &paging)
+		if err != nil {
+			return fmt.Errorf("failed to list tokens: %w", err)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tNAME\tKEY\tROLE\tSTATUS\tUSAGE\tLAST USED")
+		for _, token := range dto.ToTokenResponses(tokens) {
+			lastUsed := "-"
+			if token.LastUsedAt != nil {
+				lastUsed = *token.LastUsedAt
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+				token.ID, token.Name, token.Key, token.Role, token.Status, strconv.Itoa(token.UsageCount), lastUsed)
+		}
+		return w.Flush()
+	}, readOnly())
+}
+
+// TokenRevoke permanently revokes a token
+func TokenRevoke(c *cli.Context) error {
+	return withTokenService(c, func(ctx context.Context, tokenSvc interfaces.TokenService) error {
+		token, err := tokenSvc.GetTokenByID(ctx, c.String("id"))
+		if err != nil {
+			return err
+		}
+
+		if _, err := tokenSvc.UpdateToken(
+			ctx, token.ID, token.Name, "", entities.TokenStatusRevoked, token.Role,
+		); err != nil {
+			return fmt.Errorf("failed to revoke token: %w", err)
+		}
+
+		fmt.Printf("Token %s (%s) revoked\n", token.ID, token.Name)
+		return nil
+	})
+}
+
+// TokenRotate replaces a token's key with a newly generated one, keeping all other metadata
+func TokenRotate(c *cli.Context) error {
+	return withTokenService(c, func(ctx context.Context, tokenSvc interfaces.TokenService) error {
+		token, err := tokenSvc.RotateTokenKey(ctx, c.String("id"))
+		if err != nil {
+			return fmt.Errorf("failed to rotate token: %w", err)
+		}
+
+		fmt.Printf("Token %s (%s) rotated\n", token.ID, token.Name)
+		fmt.Printf("  Key:  %s\n", token.Key)
+		fmt.Println("Store this key now - it will not be shown again.")
+		return nil
+	})
+}
+
+// tokenCommandOption customizes withTokenService behaviour
+type tokenCommandOption func(*tokenCommandOptions)
+
+type tokenCommandOptions struct {
+	readOnly bool
+}
+
+// readOnly skips the running-server check and the final persist
+func readOnly() tokenCommandOption {
+	return func(o *tokenCommandOptions) { o.readOnly = true }
+}
+
+// withTokenService builds a TokenService directly on the JSON persistence layer,
+// runs fn, and persists the result; it fails without touching tokens.json when the file can't be loaded
+func withTokenService(
+	c *cli.Context,
+	fn func(ctx context.Context, tokenSvc interfaces.TokenService) error,
+	opts ...tokenCommandOption,
+) error {
+	var options tokenCommandOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	cfg, logger, err := prepareOfflineCommand(c, options.readOnly)
+	if err != nil {
+		return err
+	}
+
+	persistenceRepo, err := repositories.NewJSONTokenRepository(cfg.Storage.DataFolder, cfg.Storage.Fsync, logger)
+	if err != nil {
+		return fmt.Errorf("failed to open token storage: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	// The service only logs a failed load and would start empty, so the final sync would replace tokens.json
+	// with whatever fn added: abort while the file can't be read
+	if _, err := persistenceRepo.LoadAll(ctx); err != nil {
+		return fmt.Errorf("failed to load tokens: %w", err)
+	}
+
+	tokenSvc := services.NewTokenService(repositories.NewMemoryTokenRepository(0, logger), persistenceRepo, logger)
+
+	if err := fn(ctx, tokenSvc); err != nil {
+		return err
+	}
+
+	if options.readOnly {
+		return nil
+	}
+
+	return tokenSvc.FinalSync(ctx)
+}
+
+// prepareOfflineCommand loads the config, refuses to write while a server is running (unless --force),
+// and sets up a quiet logger for commands that operate directly on the data folder
+func prepareOfflineCommand(c *cli.Context, readOnly bool) (*config.Config, sctx.Logger, error) {
+	cfg, err := config.LoadConfig(c.String("config"))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Writes would be overwritten by the server's next sync of its in-memory state
+	if !readOnly && !c.Bool("force") && isServerRunning(cfg) {
+		return nil, nil, fmt.Errorf(
+			"a server appears to be listening on %s; stop it first or pass --force "+
+				"(the running server may overwrite these changes on its next sync)",
+			serverAddress(cfg),
+		)
+	}
+
+	// Keep CLI output clean - only surface errors from services
+	sctx.SetGlobalLogger(sctx.NewAppLogger(&sctx.Config{
+		DefaultLevel: "error",
+		BasePrefix:   "claude-proxy",
+		Format:       cfg.Logger.Format,
+	}))
+	return cfg, sctx.GlobalLogger().GetLogger("cli"), nil
+}
+
+// isServerRunning reports whether something is accepting connections on the configured server address
+func isServerRunning(cfg *config.Config) bool {
+	network, address := dialAddress(cfg)
+	conn, err := net.DialTimeout(network, address, 500*time.Millisecond)
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+// dialAddress returns where a local client reaches the server (wildcard hosts are dialed on loopback)
+func dialAddress(cfg *config.Config) (string, string) {
+	network, address := cfg.Server.ListenAddress()
+	if network != "tcp" {
+		return network, address
+	}
+
+	host := cfg.Server.Host
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = "127.0.0.1"
+	}
+	return network, net.JoinHostPort(host, strconv.Itoa(cfg.Server.Port))
+}
+
+// serverAddress describes the configured server address for messages
+func serverAddress(cfg *config.Config) string {
+	network, address := cfg.Server.ListenAddress()
+	if network == "unix" {
+		return "unix socket " + address
+	}
+	return address
+}
@@ -0,0 +1,98 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+
+	"claude-proxy/config"
+	"claude-proxy/pkg/middleware"
+)
+
+const testReadOnlyKey = "test-readonly-key"
+
+// TestIntegrationAdminRoutesByKey sends requests of every method to each admin route group with the full and
+// the read-only API key: the full key passes authentication everywhere, the read-only key only on the reads
+// it was granted and gets 403 READ_ONLY_API_KEY elsewhere
+func TestIntegrationAdminRoutesByKey(t *testing.T) {
+	stack := newTestStack(t, answerMessage, func(cfg *config.Config) {
+		cfg.Auth.ReadOnlyAPIKey = testReadOnlyKey
+	})
+
+	tests := []struct {
+		method   string
+		path     string
+		readOnly bool // Granted to the read-only key
+	}{
+		// Tokens
+		{http.MethodGet, "/api/tokens", true},
+		{http.MethodGet, "/api/tokens/missing", true},
+		{http.MethodGet, "/api/tokens/missing/stats", true},
+		{http.MethodGet, "/api/tokens/missing/users", true},
+		{http.MethodGet, "/api/tokens/missing/failures", false},
+		{http.MethodPost, "/api/tokens", false},
+		{http.MethodPut, "/api/tokens/missing", false},
+		{http.MethodDelete, "/api/tokens/missing", false},
+		// Accounts
+		{http.MethodGet, "/api/accounts", true},
+		{http.MethodGet, "/api/accounts/missing", true},
+		{http.MethodGet, "/api/accounts/missing/requests", true},
+		{http.MethodGet, "/api/accounts/missing/upstream-usage", true},
+		{http.MethodGet, "/api/accounts/invites", false},
+		{http.MethodPost, "/api/accounts/manual", false},
+		{http.MethodPost, "/api/accounts/invites", false},
+		{http.MethodPut, "/api/accounts/missing", false},
+		{http.MethodPut, "/api/accounts/missing/credentials", false},
+		{http.MethodDelete, "/api/accounts/missing", false},
+		{http.MethodDelete, "/api/accounts/invites/missing", false},
+		// Admin
+		{http.MethodGet, "/api/admin/statistics", true},
+		{http.MethodGet, "/api/admin/stats/tokens", true},
+		{http.MethodGet, "/api/admin/metrics", true},
+		{http.MethodGet, "/api/admin/sessions", true},
+		{http.MethodGet, "/api/admin/backups", false},
+		{http.MethodGet, "/api/admin/keys", false},
+		{http.MethodGet, "/api/admin/config", false},
+		{http.MethodPost, "/api/admin/sessions/revoke", false},
+		{http.MethodPost, "/api/admin/maintenance", false},
+		{http.MethodPut, "/api/admin/log-level", false},
+		{http.MethodDelete, "/api/admin/keys/missing", false},
+		// Sessions
+		{http.MethodDelete, "/api/sessions/missing", false},
+		// Device authorization
+		{http.MethodPost, "/oauth/device/start", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			resp := stack.do(t, tt.method, tt.path, "", http.Header{"X-API-Key": {testAdminKey}})
+			if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+				body, _ := io.ReadAll(resp.Body)
+				t.Errorf("full key: status = %d (%s), want it authorized", resp.StatusCode, body)
+			}
+
+			resp = stack.do(t, tt.method, tt.path, "", http.Header{"X-API-Key": {testReadOnlyKey}})
+			var body struct {
+				Error struct {
+					Type string `json:"type"`
+					Code string `json:"code"`
+				} `json:"error"`
+			}
+			_ = json.NewDecoder(resp.Body).Decode(&body)
+			refused := resp.StatusCode == http.StatusForbidden && body.Error.Code == middleware.ErrCodeReadOnlyKey
+			switch {
+			case tt.readOnly && (resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden):
+				t.Errorf("read-only key: status = %d (%s), want it authorized", resp.StatusCode, body.Error.Code)
+			case !tt.readOnly && (!refused || body.Error.Type != "permission_error"):
+				t.Errorf("read-only key: status = %d, error %+v; want 403 %s", resp.StatusCode, body.Error,
+					middleware.ErrCodeReadOnlyKey)
+			}
+		})
+	}
+
+	// A wrong key is still refused outright
+	resp := stack.do(t, http.MethodGet, "/api/tokens", "", http.Header{"X-API-Key": {testReadOnlyKey + "-wrong"}})
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("wrong key: status = %d, want 401", resp.StatusCode)
+	}
+}
@@ -0,0 +1,111 @@
+package api
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"claude-proxy/config"
+	"claude-proxy/pkg/middleware"
+)
+
+// largeMessageResponse is a JSON message well over the compression threshold
+var largeMessageResponse = `{"id":"msg_1","type":"message","role":"assistant","model":"claude-sonnet-4",` +
+	`"content":[{"type":"text","text":"` + strings.Repeat("compressible text ", 500) + `"}],` +
+	`"usage":{"input_tokens":3,"output_tokens":1}}`
+
+// sentBodies records the exact response bytes a fake upstream sent, by request path
+type sentBodies struct {
+	mu     sync.Mutex
+	bodies map[string][]byte
+}
+
+func (s *sentBodies) get(path string) []byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.bodies[path]
+}
+
+// answerLarge answers every request with largeMessageResponse, gzip-compressed when the path asks for it
+// with ?gzip, recording the bytes sent
+func (s *sentBodies) answerLarge(w http.ResponseWriter, r *http.Request) {
+	body := []byte(largeMessageResponse)
+	if r.URL.Query().Has("gzip") && strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		zw.Write(body)
+		zw.Close()
+		body = buf.Bytes()
+		w.Header().Set("Content-Encoding", "gzip")
+	}
+	s.mu.Lock()
+	s.bodies[r.URL.Path] = body
+	s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Length", fmt.Sprint(len(body)))
+	w.Write(body)
+}
+
+func TestIntegrationProxyResponsesAreNeverReencoded(t *testing.T) {
+	sent := &sentBodies{bodies: map[string][]byte{}}
+	stack := newTestStack(t, sent.answerLarge, func(cfg *config.Config) {
+		cfg.Server.Compression.MinSize = 1
+	})
+	message := `{"model":"claude-sonnet-4","max_tokens":10,"messages":[{"role":"user","content":"hi"}]}`
+
+	// The admin API compresses: the middleware is live in this stack
+	accept := http.Header{"Accept-Encoding": {"zstd, gzip"}}
+	header := accept.Clone()
+	header.Set("Authorization", "Bearer "+testAdminKey)
+	resp := stack.do(t, http.MethodGet, "/api/tokens", "", header)
+	if got := resp.Header.Get("Content-Encoding"); got != middleware.EncodingZstd {
+		t.Fatalf("admin API Content-Encoding = %q, want zstd", got)
+	}
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		body     string
+		key      string
+		wantSent bool // The response must be the upstream's bytes, byte for byte
+	}{
+		{"message", http.MethodPost, "/v1/messages", message, testTokenKey, true},
+		{"gzip message", http.MethodPost, "/v1/messages?gzip", message, testTokenKey, true},
+		{"count_tokens", http.MethodPost, "/v1/messages/count_tokens", message, testTokenKey, true},
+		{"models", http.MethodGet, "/v1/models", "", testTokenKey, false},
+		{"local error", http.MethodPost, "/v1/messages", message, "sk-proxy-unknown", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := accept.Clone()
+			header.Set("Authorization", "Bearer "+tt.key)
+			resp := stack.do(t, tt.method, tt.path, tt.body, header)
+			got, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Upstream only ever sends gzip, so zstd would come from the proxy
+			if encoding := resp.Header.Get("Content-Encoding"); encoding == middleware.EncodingZstd {
+				t.Errorf("/v1 response compressed by the proxy (Content-Encoding %q)", encoding)
+			}
+			if !tt.wantSent {
+				if encoding := resp.Header.Get("Content-Encoding"); encoding != "" {
+					t.Errorf("Content-Encoding = %q, want none", encoding)
+				}
+				return
+			}
+			path, _, _ := strings.Cut(tt.path, "?")
+			if want := sent.get(path); !bytes.Equal(got, want) {
+				t.Errorf("response is %d bytes, not the %d bytes upstream sent", len(got), len(want))
+			}
+		})
+	}
+}
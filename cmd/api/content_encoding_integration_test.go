@@ -0,0 +1,152 @@
+package api
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testStreamTranscript is the fake Claude API's answer to streaming message requests
+const testStreamTranscript = "event: message_start\n" +
+	`data: {"type":"message_start","message":{"id":"msg_1","model":"claude-sonnet-4",` +
+	`"usage":{"input_tokens":5,"output_tokens":1}}}` + "\n\n" +
+	"event: content_block_delta\n" +
+	`data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"hi"}}` + "\n\n" +
+	"event: message_delta\n" +
+	`data: {"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":7}}` + "\n\n" +
+	"event: message_stop\n" +
+	`data: {"type":"message_stop"}` + "\n\n"
+
+// answerGzip answers like the Claude API, gzip-compressed when the request accepts it: an SSE stream (flushed
+// event by event) for streaming requests, else the JSON message
+func answerGzip(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	chunks := []string{testMessageResponse}
+	w.Header().Set("Content-Type", "application/json")
+	if bytes.Contains(body, []byte(`"stream":true`)) {
+		chunks = strings.SplitAfter(strings.TrimSuffix(testStreamTranscript, "\n\n"), "\n\n")
+		chunks[len(chunks)-1] += "\n\n"
+		w.Header().Set("Content-Type", "text/event-stream")
+	}
+
+	if !strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+		for _, chunk := range chunks {
+			_, _ = io.WriteString(w, chunk)
+			w.(http.Flusher).Flush()
+		}
+		return
+	}
+	w.Header().Set("Content-Encoding", "gzip")
+	zw := gzip.NewWriter(w)
+	for _, chunk := range chunks {
+		_, _ = io.WriteString(zw, chunk)
+		_ = zw.Flush()
+		w.(http.Flusher).Flush()
+	}
+	_ = zw.Close()
+}
+
+// waitTokenUsage waits for the test token's statistics to count a request, and returns its token usage
+func waitTokenUsage(t *testing.T, stack *testStack) (input, output int) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		resp := stack.do(t, http.MethodGet, "/api/tokens/"+stack.token.ID+"/stats", "",
+			http.Header{"Authorization": {"Bearer " + testAdminKey}})
+		var stats struct {
+			Totals struct {
+				Requests     int `json:"requests"`
+				InputTokens  int `json:"input_tokens"`
+				OutputTokens int `json:"output_tokens"`
+			} `json:"totals"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&stats); err != nil {
+			t.Fatalf("token stats: %v", err)
+		}
+		if stats.Totals.Requests > 0 {
+			return stats.Totals.InputTokens, stats.Totals.OutputTokens
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the request was never counted")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestIntegrationGzipUpstream(t *testing.T) {
+	const (
+		buffered  = `{"model":"claude-sonnet-4","max_tokens":10,"messages":[{"role":"user","content":"hi"}]}`
+		streaming = `{"model":"claude-sonnet-4","max_tokens":10,"stream":true,` +
+			`"messages":[{"role":"user","content":"hi"}]}`
+	)
+
+	tests := []struct {
+		name           string
+		body           string
+		acceptEncoding string // Sent by the client
+		wantForwarded  string // Accept-Encoding upstream receives
+		wantGzip       bool   // The client receives the compressed bytes
+		wantBody       string // Decoded response body
+		wantInput      int
+		wantOutput     int
+	}{
+		{"buffered, client accepts gzip", buffered, "gzip, br", "gzip", true, testMessageResponse, 3, 1},
+		{"buffered, client accepts no gzip", buffered, "br", "gzip", false, testMessageResponse, 3, 1},
+		{"buffered, no Accept-Encoding", buffered, "", "gzip", false, testMessageResponse, 3, 1},
+		{"streaming, client accepts gzip", streaming, "gzip;q=1, zstd", "gzip;q=1", true, testStreamTranscript, 5, 7},
+		{"streaming, no Accept-Encoding", streaming, "", "gzip", false, testStreamTranscript, 5, 7},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stack := newTestStack(t, answerGzip, nil)
+
+			header := http.Header{}
+			if tt.acceptEncoding != "" {
+				header.Set("Accept-Encoding", tt.acceptEncoding)
+			}
+			resp := stack.do(t, http.MethodPost, "/v1/messages", tt.body, header)
+			raw, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("status = %d, body %s", resp.StatusCode, raw)
+			}
+
+			requests := stack.upstream.Requests()
+			if len(requests) != 1 {
+				t.Fatalf("upstream received %d requests, want 1", len(requests))
+			}
+			if got := requests[0].Header.Get("Accept-Encoding"); got != tt.wantForwarded {
+				t.Errorf("upstream Accept-Encoding = %q, want %q", got, tt.wantForwarded)
+			}
+
+			body := raw
+			if gotGzip := resp.Header.Get("Content-Encoding") == "gzip"; gotGzip != tt.wantGzip {
+				t.Fatalf("Content-Encoding = %q, want gzip %v", resp.Header.Get("Content-Encoding"), tt.wantGzip)
+			}
+			if tt.wantGzip {
+				zr, err := gzip.NewReader(bytes.NewReader(raw))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if body, err = io.ReadAll(zr); err != nil {
+					t.Fatalf("relayed gzip stream is corrupt: %v", err)
+				}
+			}
+			if string(body) != tt.wantBody {
+				t.Errorf("body = %q, want %q", body, tt.wantBody)
+			}
+
+			if input, output := waitTokenUsage(t, stack); input != tt.wantInput || output != tt.wantOutput {
+				t.Errorf("usage = %d in / %d out, want %d / %d", input, output, tt.wantInput, tt.wantOutput)
+			}
+		})
+	}
+}
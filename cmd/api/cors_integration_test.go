@@ -0,0 +1,54 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"claude-proxy/config"
+)
+
+func TestIntegrationCORSPreflightOfMessages(t *testing.T) {
+	stack := newTestStack(t, answerMessage, func(cfg *config.Config) {
+		cfg.Server.CORS.Proxy.AllowedOrigins = []string{"https://app.example.com"}
+		cfg.Server.CORS.Proxy.MaxAge = 10 * time.Minute
+	})
+
+	// Browsers send preflights without credentials
+	req, err := http.NewRequest(http.MethodOptions, stack.server.URL+"/v1/messages", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Origin", "https://app.example.com")
+	req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+	req.Header.Set("Access-Control-Request-Headers",
+		"anthropic-version,anthropic-beta,anthropic-dangerous-direct-browser-access,content-type,x-api-key")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("status = %d, want 204", resp.StatusCode)
+	}
+	if got := resp.Header.Get("Access-Control-Allow-Origin"); got != "https://app.example.com" {
+		t.Errorf("Access-Control-Allow-Origin = %q", got)
+	}
+	allowed := strings.ToLower(resp.Header.Get("Access-Control-Allow-Headers"))
+	for _, name := range []string{"anthropic-version", "anthropic-beta", "anthropic-dangerous-direct-browser-access"} {
+		if !strings.Contains(allowed, name) {
+			t.Errorf("Access-Control-Allow-Headers %q lacks %s", allowed, name)
+		}
+	}
+	if got := resp.Header.Values("Vary"); strings.Join(got, ",") != "Origin,Access-Control-Request-Headers" {
+		t.Errorf("Vary = %q", got)
+	}
+	if got := resp.Header.Get("Access-Control-Max-Age"); got != "600" {
+		t.Errorf("Access-Control-Max-Age = %q, want 600", got)
+	}
+	if got := len(stack.upstream.Requests()); got != 0 {
+		t.Errorf("preflight reached the upstream %d times", got)
+	}
+}
@@ -0,0 +1,182 @@
+package api
+
+import (
+	"bytes"
+	"html"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"claude-proxy/config"
+	"claude-proxy/modules/auth/domain/interfaces"
+	"claude-proxy/pkg/middleware"
+
+	"github.com/gin-gonic/gin"
+	sctx "github.com/phathdt/service-context"
+)
+
+// registerDashboard serves the embedded frontend for paths no route matches
+// index.html is rendered once with the base path, which the frontend reads for its router and API calls
+func registerDashboard(
+	engine *gin.Engine,
+	cfg config.ServerConfig,
+	adminKeyService interfaces.AdminKeyService,
+	logger sctx.Logger,
+) {
+	staticFS, err := fs.Sub(FrontendFS, "frontend/dist")
+	if err != nil {
+		return
+	}
+
+	index, err := fs.ReadFile(staticFS, "index.html")
+	if err == nil {
+		index = injectBasePath(index, cfg.BasePath)
+	}
+
+	// Unknown API paths are not dashboard pages: answer them before asking for dashboard credentials
+	handlers := []gin.HandlerFunc{func(c *gin.Context) {
+		if path := c.Request.URL.Path; strings.HasPrefix(path, "/api/") || strings.HasPrefix(path, "/v1/") {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "page not found"})
+		}
+	}}
+	if cfg.DashboardAuth {
+		handlers = append(handlers, middleware.DashboardAuth(adminKeyService, logger))
+	}
+	assets := newAssetCache(cfg.Compression)
+	handlers = append(handlers, func(c *gin.Context) {
+		path := c.Request.URL.Path
+		if path == "/" {
+			path = "/index.html"
+		}
+
+		// Try to serve the file
+		filePath := path[1:] // Remove leading slash
+		data, err := fs.ReadFile(staticFS, filePath)
+		if err != nil {
+			// Serve index.html for SPA routes only, not for missing assets
+			if !isSPARoute(c.Request) || index == nil {
+				c.JSON(http.StatusNotFound, gin.H{"error": "page not found"})
+				return
+			}
+			assets.serve(c, "index.html", "text/html; charset=utf-8", index)
+			return
+		}
+
+		if filePath == "index.html" && index != nil {
+			assets.serve(c, filePath, "text/html; charset=utf-8", index)
+			return
+		}
+
+		// Detect MIME type from file extension
+		ext := filepath.Ext(filePath)
+		contentType := mime.TypeByExtension(ext)
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+
+		// Serve the file with proper MIME type
+		assets.serve(c, filePath, contentType, data)
+	})
+
+	engine.NoRoute(handlers...)
+}
+
+// assetCache serves dashboard files compressed with the coding the client prefers
+// The embedded files never change, so each one is compressed once per coding, on first request
+type assetCache struct {
+	cfg        config.CompressionConfig
+	mu         sync.RWMutex
+	compressed map[string][]byte // Keyed by coding and file path
+}
+
+func newAssetCache(cfg config.CompressionConfig) *assetCache {
+	return &assetCache{cfg: cfg, compressed: make(map[string][]byte)}
+}
+
+// serve writes a dashboard file, compressed when the client accepts it and the file is worth it
+func (a *assetCache) serve(c *gin.Context, filePath, contentType string, data []byte) {
+	if a.cfg.Disabled || len(data) < a.cfg.MinSize || !middleware.IsCompressible(contentType) {
+		c.Data(http.StatusOK, contentType, data)
+		return
+	}
+
+	c.Header("Vary", "Accept-Encoding")
+	encoding := middleware.NegotiateEncoding(c.GetHeader("Accept-Encoding"))
+	if encoding == "" {
+		c.Data(http.StatusOK, contentType, data)
+		return
+	}
+
+	body, err := a.get(encoding, filePath, data)
+	if err != nil {
+		c.Data(http.StatusOK, contentType, data)
+		return
+	}
+	c.Header("Content-Encoding", encoding)
+	c.Header("Content-Length", strconv.Itoa(len(body)))
+	c.Data(http.StatusOK, contentType, body)
+}
+
+// get returns the compressed copy of a file, compressing it on first use
+func (a *assetCache) get(encoding, filePath string, data []byte) ([]byte, error) {
+	key := encoding + ":" + filePath
+
+	a.mu.RLock()
+	body, ok := a.compressed[key]
+	a.mu.RUnlock()
+	if ok {
+		return body, nil
+	}
+
+	body, err := middleware.CompressBytes(encoding, data)
+	if err != nil {
+		return nil, err
+	}
+	a.mu.Lock()
+	a.compressed[key] = body
+	a.mu.Unlock()
+	return body, nil
+}
+
+// isSPARoute returns true for page navigations the frontend router handles: GET/HEAD requests for
+// extension-less paths
+func isSPARoute(r *http.Request) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	return filepath.Ext(r.URL.Path) == ""
+}
+
+// injectBasePath adds a <base> element (so relative asset URLs resolve under the base path) and a meta
+// tag carrying the base path to index.html
+func injectBasePath(index []byte, basePath string) []byte {
+	escaped := html.EscapeString(basePath)
+	tags := []byte(`<base href="` + escaped + `/"><meta name="claude-proxy-base-path" content="` + escaped + `">`)
+
+	if i := bytes.Index(index, []byte("<head>")); i >= 0 {
+		i += len("<head>")
+		return append(append(append([]byte{}, index[:i]...), tags...), index[i:]...)
+	}
+	return append(tags, index...)
+}
+
+// withBasePath strips the base path from request paths, so the server answers both under the prefix
+// (reverse proxies that forward it) and without it (proxies that strip it)
+func withBasePath(basePath string, next http.Handler) http.Handler {
+	if basePath == "" {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == basePath || strings.HasPrefix(r.URL.Path, basePath+"/") {
+			r2 := r.Clone(r.Context())
+			r2.URL.Path = "/" + strings.TrimLeft(strings.TrimPrefix(r.URL.Path, basePath), "/")
+			r2.URL.RawPath = ""
+			r = r2
+		}
+		next.ServeHTTP(w, r)
+	})
+}
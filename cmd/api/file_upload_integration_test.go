@@ -0,0 +1,132 @@
+package api
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"claude-proxy/config"
+	"claude-proxy/modules/proxy/infrastructure/clients"
+)
+
+// streamingUpload is a fake Claude API Files endpoint reading uploads as they arrive: it signals once the
+// first part of the body is in, before the client has sent the rest
+type streamingUpload struct {
+	firstPart []byte        // Bytes the client sends before waiting
+	arrived   chan struct{} // Closed when firstPart reached the upstream
+	once      sync.Once
+
+	mu            sync.Mutex
+	header        http.Header
+	contentLength int64
+	body          []byte
+}
+
+func (u *streamingUpload) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var body bytes.Buffer
+	buf := make([]byte, 1024)
+	for {
+		n, err := r.Body.Read(buf)
+		body.Write(buf[:n])
+		if bytes.HasPrefix(body.Bytes(), u.firstPart) {
+			u.once.Do(func() { close(u.arrived) })
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	u.mu.Lock()
+	u.header, u.contentLength, u.body = r.Header.Clone(), r.ContentLength, body.Bytes()
+	u.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprintf(w, `{"id":"file_1","type":"file","filename":"notes.txt","mime_type":"text/plain","size_bytes":%d}`,
+		body.Len())
+}
+
+func TestIntegrationFileUploadIsStreamedThrough(t *testing.T) {
+	// A multipart upload whose file part is sent in two halves
+	var form bytes.Buffer
+	writer := multipart.NewWriter(&form)
+	part, _ := writer.CreateFormFile("file", "notes.txt")
+	part.Write(bytes.Repeat([]byte("line of notes\n"), 20000))
+	writer.Close()
+	payload := form.Bytes()
+	half := len(payload) / 2
+
+	upstream := &streamingUpload{firstPart: payload[:half], arrived: make(chan struct{})}
+	server := httptest.NewServer(upstream)
+	t.Cleanup(server.Close)
+	stack := newTestStack(t, answerMessage, func(cfg *config.Config) {
+		cfg.Claude.BaseURL = server.URL
+		cfg.Proxy.AllowedPaths = []string{"/v1/files"}
+	})
+
+	// The second half is only sent once the first one went through the proxy to the upstream
+	bodyReader, bodyWriter := io.Pipe()
+	streamed := make(chan bool, 1)
+	go func() {
+		bodyWriter.Write(payload[:half])
+		select {
+		case <-upstream.arrived:
+			streamed <- true
+		case <-time.After(5 * time.Second):
+			streamed <- false
+		}
+		bodyWriter.Write(payload[half:])
+		bodyWriter.Close()
+	}()
+
+	req, err := http.NewRequest(http.MethodPost, stack.server.URL+"/v1/files", bodyReader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+testTokenKey)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	resp, err := rawClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	answer, _ := io.ReadAll(resp.Body)
+
+	if !<-streamed {
+		t.Fatal("the upstream only received the upload once the client finished sending it")
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, body %s", resp.StatusCode, answer)
+	}
+	if !strings.Contains(string(answer), `"id":"file_1"`) {
+		t.Errorf("response = %s, want the upstream's file object", answer)
+	}
+
+	upstream.mu.Lock()
+	defer upstream.mu.Unlock()
+	if !bytes.Equal(upstream.body, payload) {
+		t.Errorf("upstream received a %d byte body, not the %d bytes the client sent", len(upstream.body), len(payload))
+	}
+	if got := upstream.header.Get("Content-Type"); got != writer.FormDataContentType() {
+		t.Errorf("upstream Content-Type = %q, want %q (boundary included)", got, writer.FormDataContentType())
+	}
+	if upstream.contentLength != -1 {
+		t.Errorf("upstream Content-Length = %d, want the body sent chunked", upstream.contentLength)
+	}
+	if beta := upstream.header.Get("Anthropic-Beta"); !strings.Contains(beta, clients.FilesAPIBeta) {
+		t.Errorf("upstream anthropic-beta = %q, want the Files API flag", beta)
+	}
+	if key := upstream.header.Get("X-Api-Key"); key != stack.account.APIKey {
+		t.Errorf("upstream x-api-key = %q, want the account's", key)
+	}
+}
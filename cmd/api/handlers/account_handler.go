@@ -1,11 +1,15 @@
 package handlers
 
 import (
+	stderrors "errors"
 	"net/http"
+	"time"
 
 	"claude-proxy/modules/auth/application/dto"
 	"claude-proxy/modules/auth/domain/entities"
 	"claude-proxy/modules/auth/domain/interfaces"
+	proxydto "claude-proxy/modules/proxy/application/dto"
+	proxyinterfaces "claude-proxy/modules/proxy/domain/interfaces"
 	"claude-proxy/pkg/errors"
 
 	"github.com/gin-gonic/gin"
@@ -14,27 +18,70 @@ import (
 // AccountHandler handles HTTP requests for account management
 type AccountHandler struct {
 	accountService interfaces.AccountService
+	breaker        proxyinterfaces.CircuitBreaker
+	history        proxyinterfaces.RequestHistory
+	failures       proxyinterfaces.AccountFailureTracker
+	upstreamUsage  proxyinterfaces.UpstreamUsage
+	defaultBaseURL string // claude.base_url, shown for accounts without a base URL override
+	usageDays      int    // Days of an upstream usage report by default (accounts.upstream_usage_days)
 }
 
 // NewAccountHandler creates a new account handler
 func NewAccountHandler(
 	accountService interfaces.AccountService,
+	breaker proxyinterfaces.CircuitBreaker,
+	history proxyinterfaces.RequestHistory,
+	failures proxyinterfaces.AccountFailureTracker,
+	upstreamUsage proxyinterfaces.UpstreamUsage,
+	defaultBaseURL string,
+	usageDays int,
 ) *AccountHandler {
 	return &AccountHandler{
 		accountService: accountService,
+		breaker:        breaker,
+		history:        history,
+		failures:       failures,
+		upstreamUsage:  upstreamUsage,
+		defaultBaseURL: defaultBaseURL,
+		usageDays:      usageDays,
 	}
 }
 
-// ListAccounts handles GET /api/accounts
+// toAccountResponse converts an account to its response DTO, including its effective base URL, its consecutive
+// upstream failures and the circuit breaker state when enabled
+func (h *AccountHandler) toAccountResponse(account *entities.Account) *dto.AccountResponse {
+	resp := dto.ToAccountResponse(account)
+	if resp.BaseURL == "" {
+		resp.BaseURL = h.defaultBaseURL
+	}
+	if h.breaker.Enabled() {
+		resp.BreakerState = string(h.breaker.State(account.ID))
+	}
+	resp.ConsecutiveFails = h.failures.Failures(account.ID)
+	return resp
+}
+
+// ListAccounts handles GET /api/accounts?include_deleted=true
 func (h *AccountHandler) ListAccounts(c *gin.Context) {
-	accounts, err := h.accountService.ListAccounts(c.Request.Context())
+	var query dto.AccountQueryParams
+	if err := c.ShouldBindQuery(&query); err != nil {
+		panic(errors.NewBadRequestError("INVALID_REQUEST", "Invalid query parameters", err.Error()))
+	}
+
+	var accounts []*entities.Account
+	var err error
+	if query.IncludeDeleted {
+		accounts, err = h.accountService.ListAllAccounts(c.Request.Context())
+	} else {
+		accounts, err = h.accountService.ListAccounts(c.Request.Context())
+	}
 	if err != nil {
 		panic(errors.NewInternalError("ACCOUNTS_LIST_FAILED", "Failed to list accounts", err.Error()))
 	}
 
 	accountResponses := make([]*dto.AccountResponse, len(accounts))
 	for i, account := range accounts {
-		accountResponses[i] = dto.ToAccountResponse(account)
+		accountResponses[i] = h.toAccountResponse(account)
 	}
 
 	c.JSON(http.StatusOK, gin.H{
@@ -52,7 +99,61 @@ func (h *AccountHandler) GetAccount(c *gin.Context) {
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"account": dto.ToAccountResponse(account),
+		"account": h.toAccountResponse(account),
+	})
+}
+
+// GetAccountRequests handles GET /api/accounts/:id/requests
+// Returns the account's last proxied requests (newest first) from the in-memory history; bodies are never kept
+func (h *AccountHandler) GetAccountRequests(c *gin.Context) {
+	id := c.Param("id")
+
+	if _, err := h.accountService.GetAccount(c.Request.Context(), id); err != nil {
+		panic(errors.NewNotFoundError("ACCOUNT_NOT_FOUND", "Account not found", id))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"account_id": id,
+		"requests":   proxydto.ToAccountRequestResponses(h.history.Recent(id)),
+	})
+}
+
+// upstreamUsageMaxDays bounds the days of one upstream usage report (the usage is kept 90 days)
+const upstreamUsageMaxDays = 92
+
+// GetUpstreamUsage handles GET /api/accounts/:id/upstream-usage?from=YYYY-MM-DD&to=YYYY-MM-DD
+// Returns the account's daily usage as reported by Claude API (admin-capable accounts only) next to the usage
+// the proxy counted, with the status of the last collection
+func (h *AccountHandler) GetUpstreamUsage(c *gin.Context) {
+	id := c.Param("id")
+
+	var query proxydto.UpstreamUsageQueryParams
+	if err := c.ShouldBindQuery(&query); err != nil {
+		panic(errors.NewBadRequestError("INVALID_REQUEST", "Invalid query parameters", "from and to are YYYY-MM-DD dates"))
+	}
+
+	account, err := h.accountService.GetAccount(c.Request.Context(), id)
+	if err != nil {
+		panic(errors.NewNotFoundError("ACCOUNT_NOT_FOUND", "Account not found", id))
+	}
+
+	to := query.To
+	if to.IsZero() {
+		to = time.Now().UTC()
+	}
+	from := query.From
+	if from.IsZero() {
+		from = to.AddDate(0, 0, -(h.usageDays - 1))
+	}
+	if from.After(to) {
+		panic(errors.NewBadRequestError("INVALID_DATE_RANGE", "Invalid date range", "from is after to"))
+	}
+	if to.Sub(from) >= upstreamUsageMaxDays*24*time.Hour {
+		panic(errors.NewBadRequestError("INVALID_DATE_RANGE", "Invalid date range", "at most 92 days per report"))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"usage": proxydto.ToUpstreamUsageResponse(id, account.AdminCapable, h.upstreamUsage.Get(id), from, to),
 	})
 }
 
@@ -65,6 +166,14 @@ func (h *AccountHandler) UpdateAccount(c *gin.Context) {
 		panic(errors.NewBadRequestError("INVALID_REQUEST", "Invalid request body", err.Error()))
 	}
 
+	existing, err := h.accountService.GetAccount(c.Request.Context(), id)
+	if err != nil {
+		panic(errors.NewNotFoundError("ACCOUNT_NOT_FOUND", "Account not found", id))
+	}
+	if existing.IsDeleted() {
+		panic(errors.NewConflictError("ACCOUNT_DELETED", "Account is deleted", "restore the account before updating it"))
+	}
+
 	// Update using service method
 	var name string
 	if req.Name != nil {
@@ -80,20 +189,400 @@ func (h *AccountHandler) UpdateAccount(c *gin.Context) {
 		panic(errors.NewInternalError("ACCOUNT_UPDATE_FAILED", "Failed to update account", err.Error()))
 	}
 
+	// Update quota budgets if provided (unspecified fields keep their current value)
+	if req.QuotaRequests != nil || req.QuotaTokens != nil {
+		quotaRequests := account.QuotaRequests
+		if req.QuotaRequests != nil {
+			quotaRequests = *req.QuotaRequests
+		}
+		quotaTokens := account.QuotaTokens
+		if req.QuotaTokens != nil {
+			quotaTokens = *req.QuotaTokens
+		}
+
+		account, err = h.accountService.UpdateAccountQuota(c.Request.Context(), id, quotaRequests, quotaTokens)
+		if err != nil {
+			panic(errors.NewInternalError("ACCOUNT_UPDATE_FAILED", "Failed to update account quota", err.Error()))
+		}
+	}
+
+	// Set or clear egress proxy if provided
+	if req.ProxyURL != nil {
+		account, err = h.accountService.UpdateAccountProxy(c.Request.Context(), id, *req.ProxyURL)
+		if err != nil {
+			panic(errors.NewBadRequestError("INVALID_PROXY_URL", "Failed to update account proxy", err.Error()))
+		}
+	}
+
+	// Set or clear the base URL override if provided
+	if req.BaseURL != nil {
+		account, err = h.accountService.UpdateAccountBaseURL(c.Request.Context(), id, *req.BaseURL)
+		if err != nil {
+			panic(errors.NewBadRequestError("INVALID_BASE_URL", "Failed to update account base URL", err.Error()))
+		}
+	}
+
+	// Replace header overrides if provided
+	if req.Headers != nil {
+		account, err = h.accountService.UpdateAccountHeaders(c.Request.Context(), id, *req.Headers)
+		if err != nil {
+			panic(errors.NewBadRequestError("INVALID_HEADERS", "Failed to update account headers", err.Error()))
+		}
+	}
+
+	// Replace supported model patterns if provided
+	if req.SupportedModels != nil {
+		account, err = h.accountService.UpdateAccountSupportedModels(c.Request.Context(), id, *req.SupportedModels)
+		if err != nil {
+			panic(errors.NewBadRequestError("INVALID_SUPPORTED_MODELS", "Failed to update supported models", err.Error()))
+		}
+	}
+
+	// Replace availability windows if provided
+	if req.Availability != nil {
+		windows := dto.FromAvailabilityWindowDTOs(*req.Availability)
+		account, err = h.accountService.UpdateAccountAvailability(c.Request.Context(), id, windows)
+		if err != nil {
+			panic(errors.NewBadRequestError("INVALID_AVAILABILITY", "Failed to update account availability", err.Error()))
+		}
+	}
+
+	// Enable or disable auto-refresh (manual mode) if provided
+	if req.AutoRefresh != nil {
+		account, err = h.accountService.UpdateAccountAutoRefresh(c.Request.Context(), id, *req.AutoRefresh)
+		if stderrors.Is(err, entities.ErrAPIKeyAccount) {
+			panic(errors.NewConflictError("API_KEY_ACCOUNT", "API-key accounts have no tokens to refresh", id))
+		}
+		if err != nil {
+			panic(errors.NewInternalError("ACCOUNT_UPDATE_FAILED", "Failed to update auto-refresh", err.Error()))
+		}
+	}
+
+	// Put the account in or out of shadow mode if provided
+	if req.Shadow != nil {
+		account, err = h.accountService.UpdateAccountShadow(c.Request.Context(), id, *req.Shadow)
+		if err != nil {
+			panic(errors.NewInternalError("ACCOUNT_UPDATE_FAILED", "Failed to update shadow mode", err.Error()))
+		}
+	}
+
+	// Flag the account as able to read its organization's usage reports if provided
+	// Flagging it again retries an account whose reports were refused
+	if req.AdminCapable != nil {
+		account, err = h.accountService.UpdateAccountAdminCapable(c.Request.Context(), id, *req.AdminCapable)
+		if err != nil {
+			panic(errors.NewInternalError("ACCOUNT_UPDATE_FAILED", "Failed to update admin capability", err.Error()))
+		}
+		if *req.AdminCapable {
+			h.upstreamUsage.Reset(id)
+		}
+	}
+
+	// Move the account in or out of the canary set if provided
+	if req.Canary != nil {
+		account, err = h.accountService.UpdateAccountCanary(c.Request.Context(), id, *req.Canary)
+		if err != nil {
+			panic(errors.NewInternalError("ACCOUNT_UPDATE_FAILED", "Failed to update canary flag", err.Error()))
+		}
+	}
+
+	// Move the account to another failover tier if provided
+	if req.Priority != nil {
+		account, err = h.accountService.UpdateAccountPriority(c.Request.Context(), id, *req.Priority)
+		if err != nil {
+			panic(errors.NewInternalError("ACCOUNT_UPDATE_FAILED", "Failed to update priority", err.Error()))
+		}
+	}
+
+	// Switch active organization if provided
+	if req.OrganizationUUID != nil {
+		account, err = h.accountService.UpdateAccountOrganization(c.Request.Context(), id, *req.OrganizationUUID)
+		if err != nil {
+			panic(errors.NewBadRequestError("INVALID_ORGANIZATION", "Failed to update account organization", err.Error()))
+		}
+	}
+
 	c.JSON(http.StatusOK, gin.H{
-		"account": dto.ToAccountResponse(account),
+		"account": h.toAccountResponse(account),
 	})
 }
 
-// DeleteAccount handles DELETE /api/accounts/:id
+// DeleteAccount handles DELETE /api/accounts/:id?permanent=true
+// Without permanent the account is soft-deleted and can be restored
 func (h *AccountHandler) DeleteAccount(c *gin.Context) {
 	id := c.Param("id")
 
-	if err := h.accountService.DeleteAccount(c.Request.Context(), id); err != nil {
+	var params dto.DeleteAccountParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		panic(errors.NewBadRequestError("INVALID_REQUEST", "Invalid query parameters", err.Error()))
+	}
+
+	if err := h.accountService.DeleteAccount(c.Request.Context(), id, params.Permanent); err != nil {
+		panic(errors.NewNotFoundError("ACCOUNT_NOT_FOUND", "Account not found", id))
+	}
+
+	message := "account deleted successfully"
+	if params.Permanent {
+		message = "account permanently deleted"
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": message,
+	})
+}
+
+// RestoreAccount handles POST /api/accounts/:id/restore
+func (h *AccountHandler) RestoreAccount(c *gin.Context) {
+	id := c.Param("id")
+
+	existing, err := h.accountService.GetAccount(c.Request.Context(), id)
+	if err != nil {
+		panic(errors.NewNotFoundError("ACCOUNT_NOT_FOUND", "Account not found", id))
+	}
+	if !existing.IsDeleted() {
+		panic(errors.NewConflictError("ACCOUNT_NOT_DELETED", "Account is not deleted", id))
+	}
+
+	account, err := h.accountService.RestoreAccount(c.Request.Context(), id)
+	if err != nil {
+		panic(errors.NewBadRequestError("ACCOUNT_RESTORE_FAILED", "Failed to restore account", err.Error()))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"account": h.toAccountResponse(account),
+	})
+}
+
+// EnableAccount handles POST /api/accounts/:id/enable
+// Reactivates an account (e.g. one auto-disabled after consecutive upstream failures) and clears its failure count
+func (h *AccountHandler) EnableAccount(c *gin.Context) {
+	id := c.Param("id")
+
+	existing, err := h.accountService.GetAccount(c.Request.Context(), id)
+	if err != nil {
+		panic(errors.NewNotFoundError("ACCOUNT_NOT_FOUND", "Account not found", id))
+	}
+	if existing.IsDeleted() {
+		panic(errors.NewConflictError("ACCOUNT_DELETED", "Account is deleted", "restore the account before enabling it"))
+	}
+
+	account, err := h.accountService.EnableAccount(c.Request.Context(), id)
+	if err != nil {
+		panic(errors.NewInternalError("ACCOUNT_ENABLE_FAILED", "Failed to enable account", err.Error()))
+	}
+	h.failures.Reset(id)
+
+	c.JSON(http.StatusOK, gin.H{
+		"account": h.toAccountResponse(account),
+	})
+}
+
+// ActivateAccount handles POST /api/accounts/:id/activate
+// Ends a new account's cooldown so it enters the rotation without waiting for accounts.new_account_cooldown
+func (h *AccountHandler) ActivateAccount(c *gin.Context) {
+	id := c.Param("id")
+
+	existing, err := h.accountService.GetAccount(c.Request.Context(), id)
+	if err != nil {
+		panic(errors.NewNotFoundError("ACCOUNT_NOT_FOUND", "Account not found", id))
+	}
+	if existing.IsDeleted() {
+		panic(errors.NewConflictError("ACCOUNT_DELETED", "Account is deleted", "restore the account before activating it"))
+	}
+	if !existing.IsCoolingDown() {
+		panic(errors.NewConflictError("ACCOUNT_NOT_PENDING", "Account is not pending", "the account is already in the rotation"))
+	}
+
+	account, err := h.accountService.ActivateAccount(c.Request.Context(), id)
+	if err != nil {
+		panic(errors.NewInternalError("ACCOUNT_ACTIVATE_FAILED", "Failed to activate account", err.Error()))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"account": h.toAccountResponse(account),
+	})
+}
+
+// ImportCredentials handles POST /api/accounts/import-credentials?name=laptop
+// The request body is the content of a Claude Code credentials file (~/.claude/.credentials.json)
+func (h *AccountHandler) ImportCredentials(c *gin.Context) {
+	var params dto.ImportAccountParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		panic(errors.NewBadRequestError("INVALID_REQUEST", "Invalid query parameters", err.Error()))
+	}
+	if params.Name == "" {
+		params.Name = "imported"
+	}
+
+	body, err := c.GetRawData()
+	if err != nil {
+		panic(errors.NewBadRequestError("INVALID_REQUEST", "Invalid request body", err.Error()))
+	}
+
+	creds, err := dto.ParseClaudeCodeCredentials(body)
+	if err != nil {
+		panic(errors.NewBadRequestError("INVALID_CREDENTIALS", "Invalid credentials file", err.Error()))
+	}
+
+	account, err := h.accountService.ImportAccount(c.Request.Context(), params.Name, creds)
+	if err != nil {
+		if stderrors.Is(err, entities.ErrCredentialsAlreadyImported) {
+			panic(errors.NewConflictError("CREDENTIALS_ALREADY_IMPORTED", "Credentials already imported", err.Error()))
+		}
+		panic(errors.NewBadRequestError("ACCOUNT_IMPORT_FAILED", "Failed to import credentials", err.Error()))
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"account": h.toAccountResponse(account),
+	})
+}
+
+// SetCredentials handles PUT /api/accounts/:id/credentials?verify=true
+// Replaces the account's tokens with pasted ones; the response never includes tokens
+func (h *AccountHandler) SetCredentials(c *gin.Context) {
+	id := c.Param("id")
+
+	var params dto.ManualCredentialsParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		panic(errors.NewBadRequestError("INVALID_REQUEST", "Invalid query parameters", err.Error()))
+	}
+	var req dto.SetCredentialsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		panic(errors.NewBadRequestError("INVALID_REQUEST", "Invalid request body", err.Error()))
+	}
+
+	existing, err := h.accountService.GetAccount(c.Request.Context(), id)
+	if err != nil {
+		panic(errors.NewNotFoundError("ACCOUNT_NOT_FOUND", "Account not found", id))
+	}
+	if existing.IsDeleted() {
+		panic(errors.NewConflictError("ACCOUNT_DELETED", "Account is deleted", "restore the account before updating it"))
+	}
+
+	creds := dto.ToManualCredentials(req.AccessToken, req.RefreshToken, req.ExpiresIn)
+	account, err := h.accountService.SetCredentials(c.Request.Context(), id, creds, params.Verify)
+	if err != nil {
+		if stderrors.Is(err, entities.ErrCredentialsAlreadyImported) {
+			panic(errors.NewConflictError("CREDENTIALS_ALREADY_IMPORTED", "Credentials already in use", err.Error()))
+		}
+		if stderrors.Is(err, entities.ErrAutoRefreshDisabled) {
+			panic(errors.NewConflictError("AUTO_REFRESH_DISABLED", "Can't verify credentials in manual mode", err.Error()))
+		}
+		if stderrors.Is(err, entities.ErrAPIKeyAccount) {
+			panic(errors.NewConflictError("API_KEY_ACCOUNT", "API-key accounts have no OAuth credentials", id))
+		}
+		panic(errors.NewBadRequestError("CREDENTIALS_UPDATE_FAILED", "Failed to update credentials", err.Error()))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"account": h.toAccountResponse(account),
+	})
+}
+
+// AdoptAccount handles POST /api/accounts/:id/adopt?verify=true
+// Acknowledges a refresh token conflict (another client rotated the account's token) and takes the account
+// back with pasted credentials; the response never includes tokens
+func (h *AccountHandler) AdoptAccount(c *gin.Context) {
+	id := c.Param("id")
+
+	var params dto.ManualCredentialsParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		panic(errors.NewBadRequestError("INVALID_REQUEST", "Invalid query parameters", err.Error()))
+	}
+	var req dto.SetCredentialsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		panic(errors.NewBadRequestError("INVALID_REQUEST", "Invalid request body", err.Error()))
+	}
+
+	existing, err := h.accountService.GetAccount(c.Request.Context(), id)
+	if err != nil {
 		panic(errors.NewNotFoundError("ACCOUNT_NOT_FOUND", "Account not found", id))
 	}
+	if existing.IsDeleted() {
+		panic(errors.NewConflictError("ACCOUNT_DELETED", "Account is deleted", "restore the account before updating it"))
+	}
+
+	creds := dto.ToManualCredentials(req.AccessToken, req.RefreshToken, req.ExpiresIn)
+	account, err := h.accountService.AdoptConflictedAccount(c.Request.Context(), id, creds, params.Verify)
+	if err != nil {
+		if stderrors.Is(err, entities.ErrAccountNotConflicted) {
+			panic(errors.NewConflictError("ACCOUNT_NOT_CONFLICTED", "Account has no refresh token conflict", id))
+		}
+		if stderrors.Is(err, entities.ErrCredentialsAlreadyImported) {
+			panic(errors.NewConflictError("CREDENTIALS_ALREADY_IMPORTED", "Credentials already in use", err.Error()))
+		}
+		if stderrors.Is(err, entities.ErrAutoRefreshDisabled) {
+			panic(errors.NewConflictError("AUTO_REFRESH_DISABLED", "Can't verify credentials in manual mode", err.Error()))
+		}
+		if stderrors.Is(err, entities.ErrAPIKeyAccount) {
+			panic(errors.NewConflictError("API_KEY_ACCOUNT", "API-key accounts have no OAuth credentials", id))
+		}
+		panic(errors.NewBadRequestError("ACCOUNT_ADOPT_FAILED", "Failed to adopt account", err.Error()))
+	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"message": "account deleted successfully",
+		"account": h.toAccountResponse(account),
+	})
+}
+
+// CreateManualAccount handles POST /api/accounts/manual?verify=true
+// Creates an account from pasted tokens without the OAuth flow, or from an API key with auth_type api_key;
+// the response never includes tokens nor the key
+func (h *AccountHandler) CreateManualAccount(c *gin.Context) {
+	var params dto.ManualCredentialsParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		panic(errors.NewBadRequestError("INVALID_REQUEST", "Invalid query parameters", err.Error()))
+	}
+	var req dto.CreateManualAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		panic(errors.NewBadRequestError("INVALID_REQUEST", "Invalid request body", err.Error()))
+	}
+
+	if req.IsAPIKey() {
+		h.createAPIKeyAccount(c, &req, params.Verify)
+		return
+	}
+
+	creds := dto.ToManualCredentials(req.AccessToken, req.RefreshToken, req.ExpiresIn)
+	account, err := h.accountService.CreateManualAccount(
+		c.Request.Context(),
+		req.Name,
+		req.OrganizationUUID,
+		creds,
+		params.Verify,
+	)
+	if err != nil {
+		if stderrors.Is(err, entities.ErrCredentialsAlreadyImported) {
+			panic(errors.NewConflictError("CREDENTIALS_ALREADY_IMPORTED", "Credentials already in use", err.Error()))
+		}
+		panic(errors.NewBadRequestError("ACCOUNT_CREATE_FAILED", "Failed to create account", err.Error()))
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"account": h.toAccountResponse(account),
+	})
+}
+
+// createAPIKeyAccount creates the api_key account of a manual account request
+func (h *AccountHandler) createAPIKeyAccount(c *gin.Context, req *dto.CreateManualAccountRequest, verify bool) {
+	if req.AccessToken != "" || req.RefreshToken != "" || req.OrganizationUUID != "" {
+		panic(errors.NewBadRequestError(
+			"INVALID_REQUEST", "Invalid request body", "api_key accounts take no OAuth tokens nor organization",
+		))
+	}
+	if verify {
+		panic(errors.NewBadRequestError("INVALID_REQUEST", "Invalid query parameters", "verify is for OAuth tokens"))
+	}
+
+	account, err := h.accountService.CreateAPIKeyAccount(c.Request.Context(), req.Name, req.APIKey)
+	if err != nil {
+		if stderrors.Is(err, entities.ErrAPIKeyAlreadyImported) {
+			panic(errors.NewConflictError("CREDENTIALS_ALREADY_IMPORTED", "API key already in use", err.Error()))
+		}
+		panic(errors.NewBadRequestError("ACCOUNT_CREATE_FAILED", "Failed to create account", err.Error()))
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"account": h.toAccountResponse(account),
 	})
 }
@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"net/http"
+
+	"claude-proxy/modules/auth/application/dto"
+	"claude-proxy/modules/auth/domain/interfaces"
+	"claude-proxy/pkg/errors"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AdminKeyHandler handles HTTP requests for admin API key management
+type AdminKeyHandler struct {
+	adminKeyService interfaces.AdminKeyService
+}
+
+// NewAdminKeyHandler creates a new admin key handler
+func NewAdminKeyHandler(adminKeyService interfaces.AdminKeyService) *AdminKeyHandler {
+	return &AdminKeyHandler{
+		adminKeyService: adminKeyService,
+	}
+}
+
+// ListKeys handles GET /api/admin/keys
+func (h *AdminKeyHandler) ListKeys(c *gin.Context) {
+	keys, err := h.adminKeyService.ListKeys(c.Request.Context())
+	if err != nil {
+		panic(errors.NewInternalError("ADMIN_KEYS_LIST_FAILED", "Failed to list admin keys", err.Error()))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"keys": dto.ToAdminKeyResponses(keys),
+	})
+}
+
+// RotateKey handles POST /api/admin/keys/rotate
+// The new key is only returned in this response; previous keys stay valid for the grace period
+func (h *AdminKeyHandler) RotateKey(c *gin.Context) {
+	key, adminKey, err := h.adminKeyService.RotateKey(c.Request.Context())
+	if err != nil {
+		panic(errors.NewInternalError("ADMIN_KEY_ROTATE_FAILED", "Failed to rotate admin key", err.Error()))
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"key":       key,
+		"admin_key": dto.ToAdminKeyResponse(adminKey),
+		"message":   "store this key now, it will not be shown again",
+	})
+}
+
+// RevokeKey handles DELETE /api/admin/keys/:id
+func (h *AdminKeyHandler) RevokeKey(c *gin.Context) {
+	id := c.Param("id")
+
+	keys, err := h.adminKeyService.ListKeys(c.Request.Context())
+	if err != nil {
+		panic(errors.NewInternalError("ADMIN_KEYS_LIST_FAILED", "Failed to list admin keys", err.Error()))
+	}
+	found := false
+	for _, key := range keys {
+		if key.ID == id {
+			found = true
+			break
+		}
+	}
+	if !found {
+		panic(errors.NewNotFoundError("ADMIN_KEY_NOT_FOUND", "Admin key not found", id))
+	}
+
+	if err := h.adminKeyService.RevokeKey(c.Request.Context(), id); err != nil {
+		panic(errors.NewConflictError("ADMIN_KEY_REVOKE_FAILED", "Failed to revoke admin key", err.Error()))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "admin key revoked successfully",
+	})
+}
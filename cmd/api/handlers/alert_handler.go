@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"net/http"
+
+	"claude-proxy/modules/proxy/application/dto"
+	"claude-proxy/modules/proxy/domain/entities"
+	"claude-proxy/modules/proxy/domain/interfaces"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AlertHandler handles HTTP requests for the built-in alert rules
+type AlertHandler struct {
+	evaluator interfaces.AlertEvaluator
+}
+
+// NewAlertHandler creates a new alert handler
+func NewAlertHandler(evaluator interfaces.AlertEvaluator) *AlertHandler {
+	return &AlertHandler{
+		evaluator: evaluator,
+	}
+}
+
+// ListAlerts handles GET /api/admin/alerts
+// Returns every enabled rule with its state at the last evaluation, and how many are firing
+func (h *AlertHandler) ListAlerts(c *gin.Context) {
+	alerts := h.evaluator.Alerts()
+	resp := make([]dto.AlertResponse, 0, len(alerts))
+	firing := 0
+	for _, alert := range alerts {
+		if alert.State == entities.AlertStateFiring {
+			firing++
+		}
+		resp = append(resp, dto.ToAlertResponse(alert))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"alerts": resp,
+		"firing": firing,
+	})
+}
@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"net/http"
+
+	"claude-proxy/modules/auth/application/dto"
+	"claude-proxy/modules/auth/domain/interfaces"
+	"claude-proxy/pkg/errors"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AuditHandler handles HTTP requests for the audit trail of admin changes
+type AuditHandler struct {
+	auditService interfaces.MutationAuditService
+}
+
+// NewAuditHandler creates a new audit handler
+func NewAuditHandler(auditService interfaces.MutationAuditService) *AuditHandler {
+	return &AuditHandler{
+		auditService: auditService,
+	}
+}
+
+// ListMutations handles GET /api/admin/audit/mutations
+// Returns the recorded creates, updates and deletes of accounts and tokens, newest first, filtered by
+// entity_type, entity_id and the from/to time range (limit: newest entries returned, default 100)
+func (h *AuditHandler) ListMutations(c *gin.Context) {
+	var query dto.MutationAuditQueryParams
+	if err := c.ShouldBindQuery(&query); err != nil {
+		panic(errors.NewBadRequestError("INVALID_REQUEST", "Invalid query parameters", err.Error()))
+	}
+	if !query.From.IsZero() && !query.To.IsZero() && !query.From.Before(query.To) {
+		panic(errors.NewBadRequestError("INVALID_REQUEST", "Invalid query parameters", "from must be before to"))
+	}
+
+	entries, err := h.auditService.ListMutations(c.Request.Context(), query.Filter())
+	if err != nil {
+		panic(errors.NewInternalServerError("failed to list audit entries: " + err.Error()))
+	}
+
+	resp := make([]*dto.MutationAuditEntryDTO, 0, len(entries))
+	for _, entry := range entries {
+		resp = append(resp, dto.ToMutationAuditEntryDTO(entry))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"entries": resp,
+		"count":   len(resp),
+	})
+}
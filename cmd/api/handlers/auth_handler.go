@@ -3,23 +3,23 @@ package handlers
 import (
 	"net/http"
 
-	"claude-proxy/config"
 	"claude-proxy/modules/auth/domain/interfaces"
+	"claude-proxy/pkg/middleware"
 
 	"github.com/gin-gonic/gin"
 )
 
 // AuthHandler handles authentication requests
 type AuthHandler struct {
-	tokenService interfaces.TokenService
-	configAPIKey string
+	tokenService    interfaces.TokenService
+	adminKeyService interfaces.AdminKeyService
 }
 
 // NewAuthHandler creates a new auth handler
-func NewAuthHandler(tokenService interfaces.TokenService, cfg *config.Config) *AuthHandler {
+func NewAuthHandler(tokenService interfaces.TokenService, adminKeyService interfaces.AdminKeyService) *AuthHandler {
 	return &AuthHandler{
-		tokenService: tokenService,
-		configAPIKey: cfg.Auth.APIKey,
+		tokenService:    tokenService,
+		adminKeyService: adminKeyService,
 	}
 }
 
@@ -81,8 +81,8 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
-	// Fall back to config API key (backward compatibility)
-	if req.APIKey == h.configAPIKey {
+	// Fall back to admin API keys (config api_key until the first rotation)
+	if _, ok := h.adminKeyService.Authenticate(c.Request.Context(), req.APIKey); ok {
 		c.JSON(http.StatusOK, LoginResponse{
 			Success: true,
 			Token:   req.APIKey,
@@ -96,7 +96,7 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
-	// Neither admin token nor config key
+	// Neither admin token nor admin key
 	c.JSON(http.StatusUnauthorized, gin.H{
 		"error": "Invalid API key",
 	})
@@ -128,8 +128,8 @@ func (h *AuthHandler) Validate(c *gin.Context) {
 		return
 	}
 
-	// Fall back to config API key (backward compatibility)
-	if req.APIKey == h.configAPIKey {
+	// Fall back to admin API keys (config api_key until the first rotation)
+	if _, ok := h.adminKeyService.Authenticate(c.Request.Context(), req.APIKey); ok {
 		c.JSON(http.StatusOK, ValidateResponse{
 			Valid: true,
 			User: &User{
@@ -142,7 +142,8 @@ func (h *AuthHandler) Validate(c *gin.Context) {
 		return
 	}
 
-	// Neither admin token nor config key
+	// Neither admin token nor admin key: a failed attempt for the login rate limit
+	c.Set(middleware.LoginFailedContextKey, true)
 	c.JSON(http.StatusOK, ValidateResponse{
 		Valid: false,
 	})
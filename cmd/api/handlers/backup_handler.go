@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"claude-proxy/modules/auth/application/dto"
+	"claude-proxy/modules/auth/domain/entities"
+	"claude-proxy/modules/auth/domain/interfaces"
+	"claude-proxy/pkg/errors"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BackupHandler handles data folder backup endpoints
+type BackupHandler struct {
+	backupService interfaces.BackupService
+}
+
+// NewBackupHandler creates a new backup handler
+func NewBackupHandler(backupService interfaces.BackupService) *BackupHandler {
+	return &BackupHandler{
+		backupService: backupService,
+	}
+}
+
+// backupResponse represents a backup in API responses
+type backupResponse struct {
+	Name      string `json:"name"`
+	Size      int64  `json:"size"`       // Bytes
+	CreatedAt string `json:"created_at"` // RFC3339/ISO 8601 datetime
+}
+
+// toBackupResponse converts a backup entity to its response shape (without the server path)
+func toBackupResponse(backup *entities.Backup) backupResponse {
+	return backupResponse{
+		Name:      backup.Name,
+		Size:      backup.Size,
+		CreatedAt: backup.CreatedAt.Format(dto.RFC3339),
+	}
+}
+
+// CreateBackup handles POST /api/admin/backup
+func (h *BackupHandler) CreateBackup(c *gin.Context) {
+	backup, err := h.backupService.CreateBackup(c.Request.Context())
+	if err != nil {
+		panic(errors.NewInternalError("BACKUP_FAILED", "Failed to create backup", err.Error()))
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "backup created successfully",
+		"backup":  toBackupResponse(backup),
+	})
+}
+
+// exportBundleRequest is the body of POST /api/admin/export
+type exportBundleRequest struct {
+	Passphrase string `json:"passphrase" binding:"required,min=8"` // Encrypts the bundle; needed again to import it
+}
+
+// ExportBundle handles POST /api/admin/export
+// Returns an encrypted state bundle for `claude-proxy import` as a file download. The ETag header identifies
+// the exported data files: a request whose If-None-Match holds it gets 304 Not Modified while they are unchanged
+// (how standby instances poll their primary).
+func (h *BackupHandler) ExportBundle(c *gin.Context) {
+	var req exportBundleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		panic(errors.NewBadRequestError("INVALID_REQUEST", "Invalid request body", err.Error()))
+	}
+
+	ifNoneMatch := strings.Trim(strings.TrimPrefix(c.GetHeader("If-None-Match"), "W/"), `"`)
+	bundle, manifest, err := h.backupService.ExportBundle(c.Request.Context(), req.Passphrase, ifNoneMatch)
+	if err != nil {
+		panic(errors.NewInternalError("EXPORT_FAILED", "Failed to export state bundle", err.Error()))
+	}
+
+	c.Header("ETag", strconv.Quote(manifest.ETag()))
+	if bundle == nil {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	name := "claude-proxy-state-" + manifest.CreatedAt.Format("20060102-150405") + ".tar.gz.enc"
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+	c.Data(http.StatusOK, "application/octet-stream", bundle)
+}
+
+// ListBackups handles GET /api/admin/backups
+func (h *BackupHandler) ListBackups(c *gin.Context) {
+	backups, err := h.backupService.ListBackups(c.Request.Context())
+	if err != nil {
+		panic(errors.NewInternalError("BACKUP_LIST_FAILED", "Failed to list backups", err.Error()))
+	}
+
+	responses := make([]backupResponse, len(backups))
+	for i, backup := range backups {
+		responses[i] = toBackupResponse(backup)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"backups": responses,
+		"count":   len(responses),
+	})
+}
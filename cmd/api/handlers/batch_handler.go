@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"net/http"
+
+	authinterfaces "claude-proxy/modules/auth/domain/interfaces"
+	"claude-proxy/modules/proxy/application/dto"
+	"claude-proxy/modules/proxy/domain/interfaces"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BatchHandler handles HTTP requests for the message batch index
+type BatchHandler struct {
+	batchService   interfaces.BatchService
+	accountService authinterfaces.AccountService
+}
+
+// NewBatchHandler creates a new batch handler
+func NewBatchHandler(
+	batchService interfaces.BatchService,
+	accountService authinterfaces.AccountService,
+) *BatchHandler {
+	return &BatchHandler{
+		batchService:   batchService,
+		accountService: accountService,
+	}
+}
+
+// ListBatches handles GET /api/admin/batches
+// Returns the known message batches (most recent first) with the account each one is pinned to
+func (h *BatchHandler) ListBatches(c *gin.Context) {
+	batches := h.batchService.List()
+
+	accountNames := make(map[string]string)
+	if accounts, err := h.accountService.ListAccounts(c.Request.Context()); err == nil {
+		for _, account := range accounts {
+			accountNames[account.ID] = account.Name
+		}
+	}
+
+	responses := make([]*dto.BatchResponse, 0, len(batches))
+	for _, batch := range batches {
+		responses = append(responses, dto.ToBatchResponse(batch, accountNames[batch.AccountID]))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"batches": responses,
+		"count":   len(responses),
+	})
+}
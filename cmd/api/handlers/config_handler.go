@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"net/http"
+	"runtime"
+	"time"
+
+	"claude-proxy/config"
+	"claude-proxy/pkg/version"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ConfigHandler handles the effective configuration endpoint
+type ConfigHandler struct {
+	cfg              *config.Config
+	dataFolder       string // Absolute path
+	frontendEmbedded bool
+	startedAt        time.Time
+}
+
+// NewConfigHandler creates a new config handler; dataFolder is the resolved absolute data folder path
+func NewConfigHandler(cfg *config.Config, dataFolder string, frontendEmbedded bool) *ConfigHandler {
+	return &ConfigHandler{
+		cfg:              cfg,
+		dataFolder:       dataFolder,
+		frontendEmbedded: frontendEmbedded,
+		startedAt:        time.Now(),
+	}
+}
+
+// GetConfig handles GET /api/admin/config
+// Returns the configuration the server runs with (secrets redacted), where each section comes from
+// and build/runtime information
+func (h *ConfigHandler) GetConfig(c *gin.Context) {
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, gin.H{
+		"config":  h.cfg.Effective(),
+		"sources": h.cfg.Sources(),
+		"runtime": gin.H{
+			"version":           version.Version,
+			"commit":            version.Revision(),
+			"go_version":        runtime.Version(),
+			"started_at":        h.startedAt.Format(time.RFC3339),
+			"uptime_seconds":    int64(time.Since(h.startedAt).Seconds()),
+			"data_folder":       h.dataFolder,
+			"frontend_embedded": h.frontendEmbedded,
+		},
+	})
+}
@@ -0,0 +1,188 @@
+package handlers
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"claude-proxy/modules/auth/application/dto"
+	"claude-proxy/modules/auth/domain/entities"
+	"claude-proxy/modules/auth/domain/interfaces"
+	"claude-proxy/pkg/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxDeviceAuthTTL caps how long a device authorization can be completed
+const maxDeviceAuthTTL = 24 * time.Hour
+
+// DeviceAuthHandler handles the OAuth flow of headless servers: an admin starts an authorization, opens its
+// URL in any browser, pastes the resulting code#state back, and a CLI polls until the account exists
+type DeviceAuthHandler struct {
+	deviceSvc  interfaces.DeviceAuthService
+	accountSvc interfaces.AccountService
+	defaultTTL time.Duration
+	basePath   string
+}
+
+// NewDeviceAuthHandler creates a new device authorization handler; defaultTTL applies to authorizations
+// started without expires_in
+func NewDeviceAuthHandler(
+	deviceSvc interfaces.DeviceAuthService,
+	accountSvc interfaces.AccountService,
+	defaultTTL time.Duration,
+	basePath string,
+) *DeviceAuthHandler {
+	return &DeviceAuthHandler{
+		deviceSvc:  deviceSvc,
+		accountSvc: accountSvc,
+		defaultTTL: defaultTTL,
+		basePath:   basePath,
+	}
+}
+
+// StartDeviceAuth handles POST /oauth/device/start (admin)
+// The polling code is only returned in this response
+func (h *DeviceAuthHandler) StartDeviceAuth(c *gin.Context) {
+	var req dto.StartDeviceAuthRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		inviteError(c, http.StatusBadRequest, "invalid_request_error", fmt.Sprintf("Invalid request: %v", err))
+		return
+	}
+
+	ttl := h.defaultTTL
+	if req.ExpiresIn < 0 {
+		inviteError(c, http.StatusBadRequest, "invalid_request_error", "expires_in must not be negative")
+		return
+	}
+	if req.ExpiresIn > 0 {
+		ttl = time.Duration(req.ExpiresIn) * time.Second
+	}
+	if ttl > maxDeviceAuthTTL {
+		inviteError(c, http.StatusBadRequest, "invalid_request_error",
+			fmt.Sprintf("device authorizations can be valid for at most %s", maxDeviceAuthTTL))
+		return
+	}
+
+	createdBy := c.GetString(middleware.AdminIdentityContextKey)
+	pollCode, auth, err := h.deviceSvc.Start(
+		c.Request.Context(), strings.TrimSpace(req.Name), strings.TrimSpace(req.OrgID), createdBy, ttl,
+	)
+	if err != nil {
+		inviteError(c, http.StatusInternalServerError, "oauth_error",
+			fmt.Sprintf("Failed to start device authorization: %v", err))
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"poll_code":            pollCode,
+		"poll_path":            h.basePath + "/oauth/device/poll/" + pollCode,
+		"complete_path":        h.basePath + "/oauth/device/complete",
+		"authorization_url":    auth.AuthorizeURL,
+		"state":                auth.State,
+		"code_verifier":        auth.CodeVerifier, // For POST /oauth/exchange, which also completes the flow
+		"expires_at":           auth.ExpiresAt.Format(dto.RFC3339),
+		"device_authorization": dto.ToDeviceAuthResponse(auth),
+		"message": "open authorization_url in a browser, then paste the code#state Claude shows " +
+			"into POST /oauth/device/complete",
+	})
+}
+
+// CompleteDeviceAuth handles POST /oauth/device/complete
+// The pasted code#state identifies the authorization, so no polling code or admin key is needed
+func (h *DeviceAuthHandler) CompleteDeviceAuth(c *gin.Context) {
+	var req dto.CompleteDeviceAuthRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		inviteError(c, http.StatusBadRequest, "invalid_request_error", fmt.Sprintf("Invalid request: %v", err))
+		return
+	}
+	if !strings.Contains(req.Code, "#") {
+		inviteError(c, http.StatusBadRequest, "invalid_request_error",
+			"code must be the full code#state shown by Claude after authorization")
+		return
+	}
+
+	status, body := completeDeviceAuth(c.Request.Context(), h.deviceSvc, req.Code, "")
+	c.JSON(status, body)
+}
+
+// PollDeviceAuth handles GET /oauth/device/poll/:code
+// Answers with the authorization's status, and the created account once completed
+func (h *DeviceAuthHandler) PollDeviceAuth(c *gin.Context) {
+	auth, err := h.deviceSvc.Poll(c.Request.Context(), c.Param("code"))
+	if err != nil {
+		inviteError(c, deviceAuthStatus(err), "oauth_error", deviceAuthErrorMessage(err))
+		return
+	}
+
+	body := gin.H{
+		"status":               auth.Status(time.Now()),
+		"device_authorization": dto.ToDeviceAuthResponse(auth),
+	}
+	if auth.AccountID != "" {
+		if acc, err := h.accountSvc.GetAccount(c.Request.Context(), auth.AccountID); err == nil {
+			body["account"] = dto.ToAccountResponse(acc)
+		}
+	}
+	c.JSON(http.StatusOK, body)
+}
+
+// completeDeviceAuth exchanges an authorization code for a device authorization and returns the status and
+// JSON body to answer with, in the format of POST /oauth/exchange
+func completeDeviceAuth(ctx context.Context, deviceSvc interfaces.DeviceAuthService, code, state string) (int, gin.H) {
+	auth, pending, err := deviceSvc.Complete(ctx, code, state)
+	if err != nil {
+		return deviceAuthStatus(err), inviteErrorBody("oauth_error", deviceAuthErrorMessage(err))
+	}
+
+	if pending != nil {
+		selection := dto.ToPendingAccountResponse(pending)
+		return http.StatusOK, gin.H{
+			"success":                true,
+			"requires_org_selection": true,
+			"message":                "Multiple organizations found. Select one via POST /oauth/select-org",
+			"selection_id":           selection.SelectionID,
+			"organizations":          selection.Organizations,
+			"expires_at":             selection.ExpiresAt,
+			"device_authorization":   dto.ToDeviceAuthResponse(auth),
+		}
+	}
+	return http.StatusOK, gin.H{
+		"success":              true,
+		"message":              "Account configured successfully",
+		"device_authorization": dto.ToDeviceAuthResponse(auth),
+	}
+}
+
+// deviceAuthStatus maps a device authorization error to its HTTP status
+func deviceAuthStatus(err error) int {
+	switch {
+	case stderrors.Is(err, entities.ErrDeviceAuthNotFound):
+		return http.StatusNotFound
+	case stderrors.Is(err, entities.ErrDeviceAuthCompleted), stderrors.Is(err, entities.ErrDeviceAuthExpired):
+		return http.StatusGone
+	case stderrors.Is(err, entities.ErrDeviceAuthBusy):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// deviceAuthErrorMessage returns the message shown for a device authorization error
+func deviceAuthErrorMessage(err error) string {
+	switch {
+	case stderrors.Is(err, entities.ErrDeviceAuthNotFound):
+		return "Unknown or expired device authorization. Start a new one."
+	case stderrors.Is(err, entities.ErrDeviceAuthCompleted):
+		return "This device authorization has already been completed."
+	case stderrors.Is(err, entities.ErrDeviceAuthExpired):
+		return "This device authorization has expired. Start a new one."
+	case stderrors.Is(err, entities.ErrDeviceAuthBusy):
+		return "This device authorization is being completed right now. Try again in a moment."
+	default:
+		return err.Error()
+	}
+}
@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"net/http"
+
+	authinterfaces "claude-proxy/modules/auth/domain/interfaces"
+	"claude-proxy/modules/proxy/application/dto"
+	"claude-proxy/modules/proxy/domain/interfaces"
+
+	"github.com/gin-gonic/gin"
+)
+
+// FileHandler handles HTTP requests for the uploaded file index
+type FileHandler struct {
+	fileService    interfaces.FileService
+	accountService authinterfaces.AccountService
+}
+
+// NewFileHandler creates a new file handler
+func NewFileHandler(
+	fileService interfaces.FileService,
+	accountService authinterfaces.AccountService,
+) *FileHandler {
+	return &FileHandler{
+		fileService:    fileService,
+		accountService: accountService,
+	}
+}
+
+// ListFiles handles GET /api/admin/files
+// Returns the known uploaded files (most recently uploaded first) with the account each one is pinned to
+func (h *FileHandler) ListFiles(c *gin.Context) {
+	files := h.fileService.List()
+
+	accountNames := make(map[string]string)
+	if accounts, err := h.accountService.ListAccounts(c.Request.Context()); err == nil {
+		for _, account := range accounts {
+			accountNames[account.ID] = account.Name
+		}
+	}
+
+	responses := make([]*dto.FileResponse, 0, len(files))
+	for _, file := range files {
+		responses = append(responses, dto.ToFileResponse(file, accountNames[file.AccountID]))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"files": responses,
+		"count": len(responses),
+	})
+}
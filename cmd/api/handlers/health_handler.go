@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	authinterfaces "claude-proxy/modules/auth/domain/interfaces"
+	proxyinterfaces "claude-proxy/modules/proxy/domain/interfaces"
+	"claude-proxy/pkg/health"
+	"claude-proxy/pkg/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HealthHandler handles the liveness and readiness probes
+type HealthHandler struct {
+	checker *health.Checker
+	clock   proxyinterfaces.ClockMonitor
+	storage *storage.Monitor
+	standby authinterfaces.StandbyService
+}
+
+// NewHealthHandler creates a new health handler
+func NewHealthHandler(
+	checker *health.Checker,
+	clock proxyinterfaces.ClockMonitor,
+	storageMonitor *storage.Monitor,
+	standby authinterfaces.StandbyService,
+) *HealthHandler {
+	return &HealthHandler{
+		checker: checker,
+		clock:   clock,
+		storage: storageMonitor,
+		standby: standby,
+	}
+}
+
+// Live handles GET /health/live (and GET /health): the process is up and serving requests
+// A warm standby also reports its state and last sync with the primary (the standby itself is healthy)
+func (h *HealthHandler) Live(c *gin.Context) {
+	response := gin.H{
+		"status":    "healthy",
+		"timestamp": time.Now().UTC(),
+	}
+	if status := h.standby.Status(); status.Enabled {
+		response["standby"] = standbyStatus(status)
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// Ready handles GET /health/ready: every registered dependency check passed
+// Returns 503 with the failed checks when the instance should not receive traffic
+// The clock skew measured against Claude API and the data folder's writability are reported either way, as is
+// the state of a warm standby (which is not ready until promoted)
+func (h *HealthHandler) Ready(c *gin.Context) {
+	results, ready := h.checker.Run(c.Request.Context())
+	if ready {
+		response := gin.H{
+			"status":  "ready",
+			"checks":  results,
+			"clock":   h.clockStatus(),
+			"storage": storageStatus(h.storage),
+		}
+		if status := h.standby.Status(); status.Enabled {
+			response["standby"] = standbyStatus(status)
+		}
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	failed := make([]health.Result, 0, len(results))
+	for _, result := range results {
+		if result.Status != "ok" {
+			failed = append(failed, result)
+		}
+	}
+	response := gin.H{
+		"status":  "not_ready",
+		"failed":  failed,
+		"checks":  results,
+		"clock":   h.clockStatus(),
+		"storage": storageStatus(h.storage),
+	}
+	if status := h.standby.Status(); status.Enabled {
+		response["standby"] = standbyStatus(status)
+	}
+	c.JSON(http.StatusServiceUnavailable, response)
+}
+
+// clockStatus reports the last clock skew measurement (skew_ms is positive when the local clock runs ahead)
+func (h *HealthHandler) clockStatus() gin.H {
+	status := h.clock.Status()
+	if !status.Measured {
+		return gin.H{"measured": false, "max_skew_ms": status.MaxSkew.Milliseconds()}
+	}
+	return gin.H{
+		"measured":    true,
+		"skew_ms":     status.Skew.Milliseconds(),
+		"max_skew_ms": status.MaxSkew.Milliseconds(),
+		"source":      status.Source,
+		"checked_at":  status.CheckedAt.UTC(),
+	}
+}
+
+// storageStatus reports the last writability probe of the data folder (read_only with allow_readonly means
+// changes are kept in memory only)
+func storageStatus(monitor *storage.Monitor) gin.H {
+	status := monitor.Status()
+	result := gin.H{
+		"read_only":      status.ReadOnly,
+		"allow_readonly": status.AllowReadOnly,
+		"checked_at":     status.CheckedAt.UTC(),
+	}
+	if status.Error != "" {
+		result["error"] = status.Error
+	}
+	return result
+}
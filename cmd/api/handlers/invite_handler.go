@@ -0,0 +1,528 @@
+package handlers
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"claude-proxy/modules/auth/application/dto"
+	"claude-proxy/modules/auth/domain/entities"
+	"claude-proxy/modules/auth/domain/interfaces"
+	"claude-proxy/modules/auth/infrastructure/clients"
+	"claude-proxy/pkg/actor"
+	"claude-proxy/pkg/errors"
+	"claude-proxy/pkg/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// inviteChallengeTTL is how long an invite page's authorization URL can be completed
+const inviteChallengeTTL = 10 * time.Minute
+
+// maxInviteTTL caps how long an invite link stays valid
+const maxInviteTTL = 30 * 24 * time.Hour
+
+// inviteChallenge binds a PKCE challenge to the invite whose page generated it
+type inviteChallenge struct {
+	token     string // Invite token (kept in memory only, to finish OAuth callbacks)
+	challenge *clients.PKCEChallenge
+	expiresAt time.Time
+}
+
+// InviteHandler handles account invites: admin management and the public one-time OAuth flow
+type InviteHandler struct {
+	inviteSvc    interfaces.InviteService
+	accountSvc   interfaces.AccountService
+	deviceSvc    interfaces.DeviceAuthService
+	oauthClient  interfaces.OAuthClient
+	defaultTTL   time.Duration
+	basePath     string
+	challenges   map[string]*inviteChallenge // state -> challenge
+	challengesMu sync.Mutex
+}
+
+// NewInviteHandler creates a new invite handler; defaultTTL applies to invites created without expires_in
+func NewInviteHandler(
+	inviteSvc interfaces.InviteService,
+	accountSvc interfaces.AccountService,
+	deviceSvc interfaces.DeviceAuthService,
+	oauthClient interfaces.OAuthClient,
+	defaultTTL time.Duration,
+	basePath string,
+) *InviteHandler {
+	return &InviteHandler{
+		inviteSvc:   inviteSvc,
+		accountSvc:  accountSvc,
+		deviceSvc:   deviceSvc,
+		oauthClient: oauthClient,
+		defaultTTL:  defaultTTL,
+		basePath:    basePath,
+		challenges:  make(map[string]*inviteChallenge),
+	}
+}
+
+// CreateInvite handles POST /api/accounts/invites
+// The invite link is only returned in this response
+func (h *InviteHandler) CreateInvite(c *gin.Context) {
+	var req dto.CreateInviteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		panic(errors.NewValidationError(err.Error()))
+	}
+
+	ttl := h.defaultTTL
+	if req.ExpiresIn < 0 {
+		panic(errors.NewValidationError("expires_in must not be negative"))
+	}
+	if req.ExpiresIn > 0 {
+		ttl = time.Duration(req.ExpiresIn) * time.Second
+	}
+	if ttl > maxInviteTTL {
+		panic(errors.NewValidationError(fmt.Sprintf("invites can be valid for at most %s", maxInviteTTL)))
+	}
+
+	createdBy := c.GetString(middleware.AdminIdentityContextKey)
+	token, invite, err := h.inviteSvc.CreateInvite(
+		c.Request.Context(), strings.TrimSpace(req.Name), strings.TrimSpace(req.OrgID), createdBy, ttl,
+	)
+	if err != nil {
+		panic(errors.NewInternalError("INVITE_CREATE_FAILED", "Failed to create invite", err.Error()))
+	}
+
+	path := h.invitePath(token)
+	c.JSON(http.StatusCreated, gin.H{
+		"token":       token,
+		"invite_path": path,
+		"invite_url":  requestOrigin(c) + path,
+		"invite":      dto.ToInviteResponse(invite),
+		"message":     "send this link now, it will not be shown again",
+	})
+}
+
+// ListInvites handles GET /api/accounts/invites
+func (h *InviteHandler) ListInvites(c *gin.Context) {
+	invites, err := h.inviteSvc.ListInvites(c.Request.Context())
+	if err != nil {
+		panic(errors.NewInternalError("INVITES_LIST_FAILED", "Failed to list invites", err.Error()))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"invites": dto.ToInviteResponses(invites),
+	})
+}
+
+// RevokeInvite handles DELETE /api/accounts/invites/:id
+func (h *InviteHandler) RevokeInvite(c *gin.Context) {
+	id := c.Param("id")
+
+	if err := h.inviteSvc.RevokeInvite(c.Request.Context(), id); err != nil {
+		if stderrors.Is(err, entities.ErrInviteNotFound) {
+			panic(errors.NewNotFoundError("INVITE_NOT_FOUND", "Invite not found", id))
+		}
+		panic(errors.NewInternalError("INVITE_REVOKE_FAILED", "Failed to revoke invite", err.Error()))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "invite revoked successfully",
+	})
+}
+
+// InvitePage handles GET /oauth/invite/:token
+// Serves the page the invited user completes the OAuth flow on, with an authorization URL bound to the invite
+func (h *InviteHandler) InvitePage(c *gin.Context) {
+	token := c.Param("token")
+
+	invite, err := h.inviteSvc.FindInvite(c.Request.Context(), token)
+	if err == nil {
+		err = invite.Usable(time.Now())
+	}
+	if err != nil {
+		h.renderPage(c, inviteStatus(err), invitePageData{Error: inviteErrorMessage(err)})
+		return
+	}
+
+	challenge, err := h.oauthClient.GeneratePKCEChallenge()
+	if err != nil {
+		h.renderPage(c, http.StatusInternalServerError, invitePageData{Error: "Failed to start the authorization."})
+		return
+	}
+	h.storeChallenge(challenge, token)
+
+	h.renderPage(c, http.StatusOK, invitePageData{
+		AccountName:  invite.AccountName,
+		AuthorizeURL: h.oauthClient.BuildAuthorizationURL(challenge, invite.OrgID),
+		ExchangePath: h.invitePath(token) + "/exchange",
+		SelectPath:   h.invitePath(token) + "/select-org",
+	})
+}
+
+// InviteExchangeRequest represents the authorization code an invited user pastes
+type InviteExchangeRequest struct {
+	Code string `json:"code" binding:"required"` // "code#state", as shown by Claude after authorization
+}
+
+// ExchangeInviteCode handles POST /oauth/invite/:token/exchange
+func (h *InviteHandler) ExchangeInviteCode(c *gin.Context) {
+	var req InviteExchangeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		inviteError(c, http.StatusBadRequest, "invalid_request_error", fmt.Sprintf("Invalid request: %v", err))
+		return
+	}
+
+	code := strings.TrimSpace(req.Code)
+	_, state, _ := strings.Cut(code, "#")
+	status, body := h.redeem(c.Request.Context(), c.Param("token"), code, state)
+	c.JSON(status, body)
+}
+
+// InviteCallback handles GET /oauth/callback?code=...&state=...
+// Completes invites and device authorizations when oauth.redirect_uri points at this server instead of
+// Claude's code page
+func (h *InviteHandler) InviteCallback(c *gin.Context) {
+	code, state := c.Query("code"), c.Query("state")
+	if code == "" || state == "" {
+		h.renderPage(c, http.StatusBadRequest, invitePageData{Error: "The authorization response has no code."})
+		return
+	}
+
+	h.challengesMu.Lock()
+	pending, ok := h.challenges[state]
+	h.challengesMu.Unlock()
+	if !ok {
+		if _, err := h.deviceSvc.FindByState(c.Request.Context(), state); err == nil {
+			status, body := completeDeviceAuth(c.Request.Context(), h.deviceSvc, code, state)
+			h.renderResult(c, status, body, h.basePath+"/oauth/select-org")
+			return
+		}
+		h.renderPage(c, http.StatusBadRequest, invitePageData{
+			Error: "This authorization has expired. Open your invite link again.",
+		})
+		return
+	}
+
+	status, body := h.redeem(c.Request.Context(), pending.token, code+"#"+state, state)
+	h.renderResult(c, status, body, h.invitePath(pending.token)+"/select-org")
+}
+
+// renderResult renders the page answering an OAuth callback from the JSON body of its code exchange
+func (h *InviteHandler) renderResult(c *gin.Context, status int, body gin.H, selectPath string) {
+	data := invitePageData{SelectPath: selectPath}
+	switch {
+	case status != http.StatusOK:
+		data.Error = body["error"].(gin.H)["message"].(string)
+	case body["requires_org_selection"] == true:
+		data.SelectionID = body["selection_id"].(string)
+		data.Organizations = body["organizations"].([]dto.OrganizationDTO)
+	default:
+		data.Message = "Your account was added. You can close this page."
+	}
+	h.renderPage(c, status, data)
+}
+
+// InviteSelectOrgRequest represents the organization an invited user picks
+type InviteSelectOrgRequest struct {
+	SelectionID      string `json:"selection_id"      binding:"required"`
+	OrganizationUUID string `json:"organization_uuid" binding:"required"`
+}
+
+// SelectInviteOrg handles POST /oauth/invite/:token/select-org
+// Finishes an invite whose user belongs to several organizations
+func (h *InviteHandler) SelectInviteOrg(c *gin.Context) {
+	var req InviteSelectOrgRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		inviteError(c, http.StatusBadRequest, "invalid_request_error", fmt.Sprintf("Invalid request: %v", err))
+		return
+	}
+
+	invite, err := h.inviteSvc.FindInvite(c.Request.Context(), c.Param("token"))
+	if err != nil {
+		inviteError(c, http.StatusNotFound, "oauth_error", inviteErrorMessage(err))
+		return
+	}
+	if invite.SelectionID == "" || invite.SelectionID != req.SelectionID {
+		inviteError(c, http.StatusBadRequest, "oauth_error", "No organization selection is pending for this invite")
+		return
+	}
+
+	ctx := actor.NewContext(c.Request.Context(), "invite:"+invite.ID)
+	acc, err := h.accountSvc.SelectOrganization(ctx, req.SelectionID, req.OrganizationUUID)
+	if err != nil {
+		inviteError(c, http.StatusBadRequest, "oauth_error", fmt.Sprintf("Failed to create account: %v", err))
+		return
+	}
+	if err := h.inviteSvc.CompleteSelection(c.Request.Context(), invite.ID, acc.ID); err != nil {
+		inviteError(c, http.StatusInternalServerError, "oauth_error", fmt.Sprintf("Failed to update invite: %v", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Account configured successfully",
+	})
+}
+
+// redeem exchanges an authorization code obtained from an invite page and creates the invite's account
+// Returns the status and JSON body to answer with
+func (h *InviteHandler) redeem(ctx context.Context, token, code, state string) (int, gin.H) {
+	h.challengesMu.Lock()
+	pending, ok := h.challenges[state]
+	if ok && pending.token == token {
+		delete(h.challenges, state) // Codes are single use, and so are their challenges
+	}
+	h.challengesMu.Unlock()
+
+	if !ok || pending.token != token || time.Now().After(pending.expiresAt) {
+		return http.StatusBadRequest, inviteErrorBody(
+			"oauth_error", "Invalid or expired authorization. Reload the invite page and authorize again.",
+		)
+	}
+
+	invite, err := h.inviteSvc.BeginRedeem(ctx, token)
+	if err != nil {
+		return inviteStatus(err), inviteErrorBody("oauth_error", inviteErrorMessage(err))
+	}
+
+	exchangeCtx, cancel := context.WithTimeout(actor.NewContext(ctx, "invite:"+invite.ID), 30*time.Second)
+	defer cancel()
+
+	acc, pendingAccount, err := h.accountSvc.CreateAccount(
+		exchangeCtx, invite.AccountName, code, pending.challenge.CodeVerifier, invite.OrgID,
+	)
+	var accountID, selectionID string
+	switch {
+	case acc != nil:
+		accountID = acc.ID
+	case pendingAccount != nil:
+		selectionID = pendingAccount.ID
+	}
+	if finishErr := h.inviteSvc.FinishRedeem(ctx, invite.ID, accountID, selectionID, err); finishErr != nil && err == nil {
+		err = finishErr
+	}
+	if err != nil {
+		return http.StatusInternalServerError, inviteErrorBody(
+			"oauth_error", fmt.Sprintf("Failed to create account: %v", err),
+		)
+	}
+
+	if pendingAccount != nil {
+		selection := dto.ToPendingAccountResponse(pendingAccount)
+		return http.StatusOK, gin.H{
+			"success":                true,
+			"requires_org_selection": true,
+			"selection_id":           selection.SelectionID,
+			"organizations":          selection.Organizations,
+			"expires_at":             selection.ExpiresAt,
+		}
+	}
+	return http.StatusOK, gin.H{
+		"success": true,
+		"message": "Account configured successfully",
+	}
+}
+
+// storeChallenge remembers an invite page's challenge, dropping expired ones
+func (h *InviteHandler) storeChallenge(challenge *clients.PKCEChallenge, token string) {
+	now := time.Now()
+
+	h.challengesMu.Lock()
+	defer h.challengesMu.Unlock()
+
+	for state, pending := range h.challenges {
+		if now.After(pending.expiresAt) {
+			delete(h.challenges, state)
+		}
+	}
+	h.challenges[challenge.State] = &inviteChallenge{
+		token:     token,
+		challenge: challenge,
+		expiresAt: now.Add(inviteChallengeTTL),
+	}
+}
+
+// invitePath returns the path of an invite page (under server.base_path)
+func (h *InviteHandler) invitePath(token string) string {
+	return h.basePath + "/oauth/invite/" + token
+}
+
+// renderPage writes the invite page
+func (h *InviteHandler) renderPage(c *gin.Context, status int, data invitePageData) {
+	c.Header("Cache-Control", "no-store")
+	c.Header("Referrer-Policy", "no-referrer") // The page URL carries the invite token
+	c.Status(status)
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	_ = invitePageTemplate.Execute(c.Writer, data)
+}
+
+// requestOrigin returns the scheme and host clients reached this server with (honoring a reverse proxy's
+// X-Forwarded-Proto / X-Forwarded-Host)
+func requestOrigin(c *gin.Context) string {
+	scheme := "http"
+	if c.Request.TLS != nil {
+		scheme = "https"
+	}
+	if proto := c.GetHeader("X-Forwarded-Proto"); proto == "http" || proto == "https" {
+		scheme = proto
+	}
+	host := c.Request.Host
+	if forwarded := c.GetHeader("X-Forwarded-Host"); forwarded != "" {
+		host, _, _ = strings.Cut(forwarded, ",")
+		host = strings.TrimSpace(host)
+	}
+	return scheme + "://" + host
+}
+
+// inviteStatus maps an invite error to its HTTP status
+func inviteStatus(err error) int {
+	switch {
+	case stderrors.Is(err, entities.ErrInviteNotFound):
+		return http.StatusNotFound
+	case stderrors.Is(err, entities.ErrInviteUsed), stderrors.Is(err, entities.ErrInviteExpired):
+		return http.StatusGone
+	case stderrors.Is(err, entities.ErrInviteBusy):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// inviteErrorMessage returns the message shown to an invited user for an invite error
+func inviteErrorMessage(err error) string {
+	switch {
+	case stderrors.Is(err, entities.ErrInviteNotFound):
+		return "This invite link is not valid."
+	case stderrors.Is(err, entities.ErrInviteUsed):
+		return "This invite link has already been used."
+	case stderrors.Is(err, entities.ErrInviteExpired):
+		return "This invite link has expired. Ask for a new one."
+	case stderrors.Is(err, entities.ErrInviteBusy):
+		return "This invite is being used right now. Try again in a moment."
+	default:
+		return err.Error()
+	}
+}
+
+// inviteErrorBody returns an error body in the OAuth endpoints' format
+func inviteErrorBody(errType, message string) gin.H {
+	return gin.H{"error": gin.H{"type": errType, "message": message}}
+}
+
+// inviteError answers with an error in the OAuth endpoints' format
+func inviteError(c *gin.Context, status int, errType, message string) {
+	c.JSON(status, inviteErrorBody(errType, message))
+}
+
+// invitePageData is rendered by invitePageTemplate
+type invitePageData struct {
+	AccountName   string
+	AuthorizeURL  string
+	ExchangePath  string
+	SelectPath    string
+	SelectionID   string                // Set when the user must pick an organization
+	Organizations []dto.OrganizationDTO // Organizations to pick from
+	Message       string                // Final success message
+	Error         string                // Final error message
+}
+
+var invitePageTemplate = template.Must(template.New("invite").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>Add your Claude account</title>
+<style>
+body { font-family: system-ui, sans-serif; max-width: 36rem; margin: 3rem auto; padding: 0 1rem; color: #222; }
+input { width: 100%; padding: .5rem; box-sizing: border-box; font-family: monospace; }
+button, .button { display: inline-block; margin: .5rem .5rem 0 0; padding: .5rem 1rem; border: 1px solid #555; border-radius: 4px; background: #f4f4f4; color: #222; text-decoration: none; cursor: pointer; }
+.error { color: #b00020; }
+.success { color: #1b7f3a; }
+</style>
+</head>
+<body>
+<h1>Add your Claude account</h1>
+{{if .Error}}
+<p class="error">{{.Error}}</p>
+{{else if .Message}}
+<p class="success">{{.Message}}</p>
+{{else}}
+<div id="flow">
+{{if .SelectionID}}
+<p>Your Claude user belongs to several organizations. Pick the one to add:</p>
+{{range .Organizations}}<button type="button" data-org="{{.UUID}}">{{.Name}}{{if .PlanType}} ({{.PlanType}}){{end}}</button>{{end}}
+{{else}}
+<p>You were invited to add a Claude account{{if .AccountName}} named <strong>{{.AccountName}}</strong>{{end}}. This link works once.</p>
+<p>1. Sign in to Claude and authorize access:</p>
+<p><a class="button" href="{{.AuthorizeURL}}" target="_blank" rel="noopener noreferrer">Authorize with Claude</a></p>
+<p>2. Paste the code Claude shows you after authorizing:</p>
+<form id="code-form">
+<input id="code" name="code" autocomplete="off" required placeholder="code#state">
+<button type="submit">Add account</button>
+</form>
+{{end}}
+</div>
+<p id="result"></p>
+<script>
+(function () {
+  var result = document.getElementById("result");
+  var flow = document.getElementById("flow");
+  var selectionID = {{.SelectionID}};
+
+  function show(message, ok) {
+    result.textContent = message;
+    result.className = ok ? "success" : "error";
+  }
+
+  function pickOrg(orgID) {
+    post({{.SelectPath}}, {selection_id: selectionID, organization_uuid: orgID});
+  }
+
+  function bindOrgButtons() {
+    flow.querySelectorAll("button[data-org]").forEach(function (button) {
+      button.addEventListener("click", function () { pickOrg(button.getAttribute("data-org")); });
+    });
+  }
+
+  function post(path, body) {
+    show("Working...", true);
+    fetch(path, {method: "POST", headers: {"Content-Type": "application/json"}, body: JSON.stringify(body)})
+      .then(function (resp) { return resp.json(); })
+      .then(function (data) {
+        if (data.error) { show(data.error.message, false); return; }
+        if (data.requires_org_selection) {
+          selectionID = data.selection_id;
+          flow.innerHTML = "<p>Your Claude user belongs to several organizations. Pick the one to add:</p>";
+          data.organizations.forEach(function (org) {
+            var button = document.createElement("button");
+            button.type = "button";
+            button.setAttribute("data-org", org.uuid);
+            button.textContent = org.name + (org.plan_type ? " (" + org.plan_type + ")" : "");
+            flow.appendChild(button);
+          });
+          bindOrgButtons();
+          show("", true);
+          return;
+        }
+        flow.innerHTML = "";
+        show("Your account was added. You can close this page.", true);
+      })
+      .catch(function (err) { show("Request failed: " + err, false); });
+  }
+
+  var form = document.getElementById("code-form");
+  if (form) {
+    form.addEventListener("submit", function (event) {
+      event.preventDefault();
+      post({{.ExchangePath}}, {code: document.getElementById("code").value.trim()});
+    });
+  }
+  bindOrgButtons();
+})();
+</script>
+{{end}}
+</body>
+</html>
+`))
@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"claude-proxy/pkg/errors"
+	"claude-proxy/pkg/logging"
+
+	"github.com/gin-gonic/gin"
+)
+
+// LogLevelHandler handles runtime log level changes
+type LogLevelHandler struct {
+	registry *logging.Registry
+}
+
+// NewLogLevelHandler creates a new log level handler
+func NewLogLevelHandler(registry *logging.Registry) *LogLevelHandler {
+	return &LogLevelHandler{
+		registry: registry,
+	}
+}
+
+// setLogLevelRequest is the body of PUT /api/admin/log-level
+type setLogLevelRequest struct {
+	Component string `json:"component"`                    // Empty or "default" for the default level
+	Level     string `json:"level"     binding:"required"` // trace, debug, info, warn, error or "reset"
+	TTL       string `json:"ttl"`                          // Reverts the override after this duration ("15m")
+}
+
+// GetLogLevels handles GET /api/admin/log-level
+func (h *LogLevelHandler) GetLogLevels(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"levels": h.registry.Levels(),
+	})
+}
+
+// SetLogLevel handles PUT /api/admin/log-level
+func (h *LogLevelHandler) SetLogLevel(c *gin.Context) {
+	var req setLogLevelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		panic(errors.NewBadRequestError("INVALID_REQUEST", "Invalid request body", err.Error()))
+	}
+
+	component := req.Component
+	if component == "" {
+		component = logging.DefaultComponent
+	}
+
+	var ttl time.Duration
+	if req.TTL != "" {
+		parsed, err := time.ParseDuration(req.TTL)
+		if err != nil || parsed <= 0 {
+			panic(errors.NewBadRequestError("INVALID_TTL", "Invalid ttl", "expected a positive duration such as 15m"))
+		}
+		ttl = parsed
+	}
+
+	var err error
+	if req.Level == "reset" {
+		err = h.registry.ResetLevel(component)
+	} else {
+		err = h.registry.SetLevel(component, req.Level, ttl)
+	}
+	if err != nil {
+		panic(errors.NewBadRequestError("INVALID_LOG_LEVEL", "Failed to change log level", err.Error()))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"levels": h.registry.Levels(),
+	})
+}
@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"claude-proxy/modules/proxy/application/dto"
+	"claude-proxy/modules/proxy/domain/interfaces"
+	"claude-proxy/pkg/errors"
+	"claude-proxy/pkg/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MaintenanceHandler handles HTTP requests for maintenance mode
+type MaintenanceHandler struct {
+	maintenanceService interfaces.MaintenanceService
+}
+
+// NewMaintenanceHandler creates a new maintenance handler
+func NewMaintenanceHandler(maintenanceService interfaces.MaintenanceService) *MaintenanceHandler {
+	return &MaintenanceHandler{
+		maintenanceService: maintenanceService,
+	}
+}
+
+// GetMaintenance handles GET /api/admin/maintenance
+func (h *MaintenanceHandler) GetMaintenance(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"maintenance": dto.ToMaintenanceResponse(h.maintenanceService.State()),
+	})
+}
+
+// SetMaintenance handles POST /api/admin/maintenance
+// While enabled, /v1 requests get 503 with Retry-After; admin API, OAuth and the dashboard keep working
+func (h *MaintenanceHandler) SetMaintenance(c *gin.Context) {
+	var req dto.SetMaintenanceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		panic(errors.NewBadRequestError("INVALID_REQUEST", "Invalid maintenance request", err.Error()))
+	}
+
+	state, err := h.maintenanceService.SetMaintenance(
+		c.Request.Context(),
+		*req.Enabled,
+		req.Message,
+		time.Duration(req.RetryAfterSeconds)*time.Second,
+		c.GetString(middleware.AdminIdentityContextKey),
+	)
+	if err != nil {
+		panic(errors.NewInternalError("MAINTENANCE_UPDATE_FAILED", "Failed to update maintenance mode", err.Error()))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"maintenance": dto.ToMaintenanceResponse(state),
+	})
+}
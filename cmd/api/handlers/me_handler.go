@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"claude-proxy/config"
+	"claude-proxy/modules/auth/application/dto"
+	"claude-proxy/modules/auth/domain/entities"
+	"claude-proxy/modules/auth/domain/interfaces"
+	"claude-proxy/pkg/actor"
+	"claude-proxy/pkg/errors"
+
+	"github.com/gin-gonic/gin"
+	sctx "github.com/phathdt/service-context"
+	"github.com/phathdt/service-context/core"
+)
+
+// mePath is the self-service path prefix, served under the /v1 proxy group
+const mePath = "/v1/me"
+
+// MeHandler serves the self-service endpoints a token holder calls with their own token
+// Every endpoint only ever reads or changes the authenticated token and its sessions
+type MeHandler struct {
+	tokenService   interfaces.TokenService
+	sessionService interfaces.SessionService
+	session        config.SessionConfig
+	logger         sctx.Logger
+}
+
+// NewMeHandler creates a new self-service handler
+func NewMeHandler(
+	tokenService interfaces.TokenService,
+	sessionService interfaces.SessionService,
+	session config.SessionConfig,
+	appLogger sctx.Logger,
+) *MeHandler {
+	return &MeHandler{
+		tokenService:   tokenService,
+		sessionService: sessionService,
+		session:        session,
+		logger:         appLogger.Withs(sctx.Fields{"component": "me-handler"}),
+	}
+}
+
+// Routes returns middleware answering /v1/me requests itself, placed after BearerTokenAuth
+// The /v1 group forwards every other path through a catch-all route, which rules out registering these
+// as regular routes
+func (h *MeHandler) Routes() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		path := strings.TrimSuffix(c.Request.URL.Path, "/")
+		if path != mePath && !strings.HasPrefix(path, mePath+"/") {
+			c.Next()
+			return
+		}
+
+		rest := strings.TrimPrefix(path, mePath)
+		method := c.Request.Method
+		switch {
+		case rest == "" && method == http.MethodGet:
+			h.GetMe(c)
+		case rest == "/rotate" && method == http.MethodPost:
+			h.RotateKey(c)
+		case rest == "/sessions" && method == http.MethodGet:
+			h.ListSessions(c)
+		case strings.HasPrefix(rest, "/sessions/") && !strings.Contains(rest[len("/sessions/"):], "/") &&
+			method == http.MethodDelete:
+			h.RevokeSession(c, rest[len("/sessions/"):])
+		case rest == "" || rest == "/rotate" || rest == "/sessions" || strings.HasPrefix(rest, "/sessions/"):
+			panic(errors.NewProxyError(
+				http.StatusMethodNotAllowed, "METHOD_NOT_ALLOWED", "Method not allowed",
+				method+" is not supported on "+c.Request.URL.Path,
+			))
+		default:
+			panic(errors.NewNotFoundError("NOT_FOUND", "Not found", c.Request.URL.Path+" does not exist"))
+		}
+		c.Abort()
+	}
+}
+
+// GetMe describes the authenticated token: usage, active sessions and limits
+// GET /v1/me
+func (h *MeHandler) GetMe(c *gin.Context) {
+	token := currentToken(c)
+
+	activeSessions := 0
+	if h.session.Enabled {
+		paging := core.Paging{Page: 1, Limit: 1}
+		query := &dto.SessionQueryParams{TokenID: token.ID, Active: true}
+		if _, _, err := h.sessionService.ListSessions(c.Request.Context(), query, &paging); err != nil {
+			panic(errors.NewInternalServerError("failed to count sessions: " + err.Error()))
+		}
+		activeSessions = int(paging.Total)
+	}
+
+	c.JSON(http.StatusOK, dto.ToMeResponse(token, activeSessions, h.limits(token)))
+}
+
+// limits returns the session limits applied to token under the configured scope
+func (h *MeHandler) limits(token *entities.Token) dto.MeLimitsResponse {
+	if !h.session.Enabled {
+		return dto.MeLimitsResponse{}
+	}
+
+	limits := dto.MeLimitsResponse{SessionsEnabled: true, SessionScope: h.session.Scope}
+	if h.session.Scope != config.SessionScopeToken {
+		limits.MaxConcurrent = h.session.MaxConcurrent
+	}
+	if h.session.Scope != config.SessionScopeGlobal {
+		limits.MaxSessions = token.SessionLimit(h.session.MaxPerToken)
+	}
+	return limits
+}
+
+// RotateKey replaces the authenticated token's key; the new key is returned once and the old one stops working
+// POST /v1/me/rotate
+func (h *MeHandler) RotateKey(c *gin.Context) {
+	// The holder is the actor of the change in the audit trail
+	id := currentToken(c).ID
+	token, err := h.tokenService.RotateTokenKey(actor.NewContext(c.Request.Context(), "token:"+id), id)
+	if err != nil {
+		panic(errors.NewInternalServerError("failed to rotate key: " + err.Error()))
+	}
+
+	h.logger.Withs(sctx.Fields{"token_id": token.ID}).Info("Token key rotated by its holder")
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Key rotated - store it now, it will not be shown again",
+		"token":   dto.ToTokenResponseWithFullKey(token),
+	})
+}
+
+// ListSessions lists the authenticated token's sessions (?active=true, ?expired=true, sorting and pagination)
+// GET /v1/me/sessions
+func (h *MeHandler) ListSessions(c *gin.Context) {
+	var query dto.SessionQueryParams
+	if err := c.ShouldBindQuery(&query); err != nil {
+		panic(errors.NewBadRequestError("INVALID_REQUEST", "Invalid query parameters", err.Error()))
+	}
+	// Always scoped to the caller, whatever token_id the query names
+	query.TokenID = currentToken(c).ID
+
+	var paging core.Paging
+	if err := c.ShouldBindQuery(&paging); err != nil {
+		panic(errors.NewBadRequestError("INVALID_REQUEST", "Invalid pagination parameters", err.Error()))
+	}
+	paging.Process()
+
+	sessions, _, err := h.sessionService.ListSessions(c.Request.Context(), &query, &paging)
+	if err != nil {
+		panic(errors.NewInternalServerError("failed to list sessions: " + err.Error()))
+	}
+
+	responses := make([]*dto.SessionResponse, len(sessions))
+	for i, session := range sessions {
+		responses[i] = dto.ToSessionResponse(session)
+	}
+
+	// Totals across all tokens are left out: they are not the caller's to see
+	c.JSON(http.StatusOK, gin.H{
+		"sessions": responses,
+		"total":    int(paging.Total),
+		"paging":   paging,
+	})
+}
+
+// RevokeSession revokes one of the authenticated token's sessions; other tokens' sessions are not found
+// DELETE /v1/me/sessions/:id
+func (h *MeHandler) RevokeSession(c *gin.Context, sessionID string) {
+	token := currentToken(c)
+
+	if err := h.sessionService.RevokeTokenSession(c.Request.Context(), token.ID, sessionID); err != nil {
+		h.logger.Withs(sctx.Fields{"token_id": token.ID, "session_id": sessionID, "error": err}).Debug(
+			"Self-service session revocation refused",
+		)
+		panic(errors.NewNotFoundError("SESSION_NOT_FOUND", "Session not found", sessionID))
+	}
+
+	h.logger.Withs(sctx.Fields{
+		"token_id":   token.ID,
+		"session_id": sessionID,
+	}).Info("Session revoked by its token holder")
+
+	c.JSON(http.StatusOK, dto.RevokeSessionResponse{
+		Success: true,
+		Message: "Session revoked successfully",
+	})
+}
+
+// currentToken returns the token authenticated by BearerTokenAuth
+func currentToken(c *gin.Context) *entities.Token {
+	validatedToken, exists := c.Get("validated_token")
+	if !exists {
+		panic(errors.NewUnauthorizedError("token not found in context"))
+	}
+	return validatedToken.(*entities.Token)
+}
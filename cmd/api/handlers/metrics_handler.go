@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"claude-proxy/modules/auth/domain/interfaces"
+
+	"github.com/gin-gonic/gin"
+)
+
+// prometheusContentType is the Prometheus text exposition format served by GET /api/admin/metrics
+const prometheusContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// MetricsHandler serves gauges in the Prometheus text format
+type MetricsHandler struct {
+	caches interfaces.CacheMonitor
+}
+
+// NewMetricsHandler creates a new metrics handler
+func NewMetricsHandler(caches interfaces.CacheMonitor) *MetricsHandler {
+	return &MetricsHandler{
+		caches: caches,
+	}
+}
+
+// GetMetrics handles GET /api/admin/metrics
+func (h *MetricsHandler) GetMetrics(c *gin.Context) {
+	stats := h.caches.Stats()
+	names := make([]string, 0, len(stats))
+	for name := range stats {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	var b strings.Builder
+	gauge := func(metric, help string, value func(name string) int64) {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s gauge\n", metric, help, metric)
+		for _, name := range names {
+			fmt.Fprintf(&b, "%s{cache=%q} %d\n", metric, name, value(name))
+		}
+	}
+	gauge("claude_proxy_cache_entries", "Entries held by an in-memory cache.", func(name string) int64 {
+		return int64(stats[name].Entries)
+	})
+	gauge("claude_proxy_cache_approx_bytes", "Estimated memory held by an in-memory cache.", func(name string) int64 {
+		return stats[name].ApproxBytes
+	})
+	gauge("claude_proxy_cache_soft_limit", "Entries above which an in-memory cache logs a warning (0 = none).",
+		func(name string) int64 {
+			return int64(stats[name].SoftLimit)
+		},
+	)
+
+	c.Data(http.StatusOK, prometheusContentType, []byte(b.String()))
+}
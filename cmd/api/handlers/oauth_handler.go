@@ -12,12 +12,14 @@ import (
 	"claude-proxy/modules/auth/application/dto"
 	"claude-proxy/modules/auth/domain/interfaces"
 	"claude-proxy/modules/auth/infrastructure/clients"
+	"claude-proxy/pkg/actor"
 )
 
 // OAuthHandler handles OAuth-related endpoints
 type OAuthHandler struct {
 	oauthClient   interfaces.OAuthClient
 	accountSvc    interfaces.AccountService
+	deviceSvc     interfaces.DeviceAuthService
 	claudeBaseURL string
 	challenges    map[string]*clients.PKCEChallenge // state -> challenge
 	challengesMu  sync.Mutex
@@ -27,11 +29,13 @@ type OAuthHandler struct {
 func NewOAuthHandler(
 	oauthClient interfaces.OAuthClient,
 	accountSvc interfaces.AccountService,
+	deviceSvc interfaces.DeviceAuthService,
 	claudeBaseURL string,
 ) *OAuthHandler {
 	return &OAuthHandler{
 		oauthClient:   oauthClient,
 		accountSvc:    accountSvc,
+		deviceSvc:     deviceSvc,
 		claudeBaseURL: claudeBaseURL,
 		challenges:    make(map[string]*clients.PKCEChallenge),
 	}
@@ -88,6 +92,8 @@ type ExchangeCodeRequest struct {
 }
 
 // ExchangeCode exchanges authorization code for tokens (manual flow)
+// Also completes device authorizations (POST /oauth/device/start), whose state and code_verifier are
+// accepted here; the account then gets the name the authorization was started with
 // POST /oauth/exchange
 func (h *OAuthHandler) ExchangeCode(c *gin.Context) {
 	var req ExchangeCodeRequest
@@ -110,6 +116,13 @@ func (h *OAuthHandler) ExchangeCode(c *gin.Context) {
 	h.challengesMu.Unlock()
 
 	if !exists {
+		auth, err := h.deviceSvc.FindByState(c.Request.Context(), req.State)
+		if err == nil && auth.CodeVerifier == req.CodeVerifier {
+			status, body := completeDeviceAuth(c.Request.Context(), h.deviceSvc, req.Code, req.State)
+			c.JSON(status, body)
+			return
+		}
+
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error": gin.H{
 				"type":    "oauth_error",
@@ -130,11 +143,11 @@ func (h *OAuthHandler) ExchangeCode(c *gin.Context) {
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := context.WithTimeout(actor.NewContext(context.Background(), actor.OAuth), 30*time.Second)
 	defer cancel()
 
 	// Use AccountService to create account (handles OAuth exchange)
-	acc, err := h.accountSvc.CreateAccount(ctx, req.Name, req.Code, req.CodeVerifier, req.OrgID)
+	acc, pending, err := h.accountSvc.CreateAccount(ctx, req.Name, req.Code, req.CodeVerifier, req.OrgID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": gin.H{
@@ -145,6 +158,20 @@ func (h *OAuthHandler) ExchangeCode(c *gin.Context) {
 		return
 	}
 
+	// User belongs to several organizations: caller must pick one via /oauth/select-org
+	if pending != nil {
+		selection := dto.ToPendingAccountResponse(pending)
+		c.JSON(http.StatusOK, gin.H{
+			"success":                true,
+			"requires_org_selection": true,
+			"message":                "Multiple organizations found. Select one via POST /oauth/select-org",
+			"selection_id":           selection.SelectionID,
+			"organizations":          selection.Organizations,
+			"expires_at":             selection.ExpiresAt,
+		})
+		return
+	}
+
 	// Convert to response DTO
 	accountResponse := dto.ToAccountResponse(acc)
 
@@ -154,3 +181,52 @@ func (h *OAuthHandler) ExchangeCode(c *gin.Context) {
 		"account": accountResponse,
 	})
 }
+
+// SelectOrgRequest represents the request body for finalizing a multi-organization account
+type SelectOrgRequest struct {
+	SelectionID      string `json:"selection_id"      binding:"required"`
+	OrganizationUUID string `json:"organization_uuid" binding:"required"`
+}
+
+// SelectOrg finalizes account creation with the chosen organization
+// POST /oauth/select-org
+func (h *OAuthHandler) SelectOrg(c *gin.Context) {
+	var req SelectOrgRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": gin.H{
+				"type":    "invalid_request_error",
+				"message": fmt.Sprintf("Invalid request: %v", err),
+			},
+		})
+		return
+	}
+
+	ctx := actor.NewContext(c.Request.Context(), actor.OAuth)
+	acc, err := h.accountSvc.SelectOrganization(ctx, req.SelectionID, req.OrganizationUUID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": gin.H{
+				"type":    "oauth_error",
+				"message": fmt.Sprintf("Failed to create account: %v", err),
+			},
+		})
+		return
+	}
+	// The selection may finish a device authorization, which its poller is waiting for
+	if err := h.deviceSvc.CompleteSelection(c.Request.Context(), req.SelectionID, acc.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": gin.H{
+				"type":    "oauth_error",
+				"message": fmt.Sprintf("Failed to update device authorization: %v", err),
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Account configured successfully",
+		"account": dto.ToAccountResponse(acc),
+	})
+}
@@ -3,10 +3,14 @@ package handlers
 import (
 	"context"
 	"io"
+	"net/http"
+	"strconv"
+	"strings"
 
 	"claude-proxy/modules/auth/domain/entities"
 	"claude-proxy/modules/proxy/domain/interfaces"
 	"claude-proxy/pkg/errors"
+	"claude-proxy/pkg/httpproxy"
 
 	"github.com/gin-gonic/gin"
 )
@@ -42,26 +46,56 @@ func (h *ProxyHandler) ProxyRequest(c *gin.Context) {
 			c.AbortWithStatus(499) // 499 Client Closed Request (nginx convention)
 			return
 		}
+		// Errors that already carry an HTTP status and code (session limit, classified upstream
+		// failures such as UPSTREAM_TIMEOUT or NO_AVAILABLE_ACCOUNT) are passed through as-is
+		if appErr, ok := err.(errors.AppError); ok {
+			panic(appErr)
+		}
 		if err == context.DeadlineExceeded || ctxErr == context.DeadlineExceeded {
 			// Request timed out
-			panic(errors.NewRequestTimeoutError("request timed out"))
+			panic(errors.NewGatewayTimeoutError("request timed out"))
 		}
 		panic(errors.NewServiceUnavailableError(err.Error()))
 	}
 	defer resp.Body.Close()
 
-	// Copy response headers first (before streaming or buffering)
+	// A body decoded by the HTTP client no longer matches the upstream encoding and length headers
+	if resp.Uncompressed {
+		resp.Header.Del("Content-Encoding")
+		resp.Header.Del("Content-Length")
+	}
+
+	// Connection-level headers describe the upstream connection, not the one to the client
+	httpproxy.RemoveHopByHop(resp.Header)
+	if resp.ProtoMajor > 0 { // Responses the proxy built itself (cached models) were not received over HTTP
+		httpproxy.AppendVia(resp.Header, resp.ProtoMajor, resp.ProtoMinor)
+	}
+
+	// Copy response headers first (before streaming or buffering): upstream values replace any set by
+	// middleware, and every value of a repeated header (Via, Set-Cookie) is kept
 	for key, values := range resp.Header {
+		c.Writer.Header().Del(key)
 		for _, value := range values {
-			c.Header(key, value)
+			c.Writer.Header().Add(key, value)
+		}
+	}
+
+	// HEAD, 204 and 304 responses have no body: their headers (Content-Length included) describe
+	// the resource and are relayed as they are
+	if !httpproxy.BodyAllowed(c.Request.Method, resp.StatusCode) {
+		if resp.StatusCode == http.StatusNoContent {
+			c.Writer.Header().Del("Content-Length")
 		}
+		c.Status(resp.StatusCode)
+		c.Writer.WriteHeaderNow()
+		return
 	}
 
 	// Check if response is SSE (Server-Sent Events) stream
 	contentType := resp.Header.Get("Content-Type")
-	if contentType == "text/event-stream" {
+	if strings.HasPrefix(contentType, "text/event-stream") {
 		// Stream SSE response directly to client
-		h.streamSSEResponse(c, &resp.Body)
+		h.streamSSEResponse(c, resp.Body)
 		return
 	}
 
@@ -76,32 +110,33 @@ func (h *ProxyHandler) ProxyRequest(c *gin.Context) {
 		panic(errors.NewInternalServerError("failed to read response body"))
 	}
 
-	// Return buffered response
+	// Return buffered response, with the length of the body actually sent (the proxy may have rewritten it)
+	c.Header("Content-Length", strconv.Itoa(len(respBody)))
 	c.Data(resp.StatusCode, contentType, respBody)
 }
 
 // streamSSEResponse streams Server-Sent Events from Claude API to the client using Gin's Stream
-func (h *ProxyHandler) streamSSEResponse(c *gin.Context, resp *io.ReadCloser) {
+// The upstream request shares the client's context, so a disconnect aborts a pending read;
+// the upstream body is closed as soon as streaming stops rather than when the handler unwinds
+func (h *ProxyHandler) streamSSEResponse(c *gin.Context, body io.ReadCloser) {
 	// Set SSE headers
 	c.Header("Content-Type", "text/event-stream")
 	c.Header("Cache-Control", "no-cache")
-	c.Header("Connection", "keep-alive")
+	c.Header("Content-Length", "")      // Streams are delimited by the connection, never by a length
 	c.Header("X-Accel-Buffering", "no") // Disable nginx buffering
 
+	buf := make([]byte, 4096) // 4KB buffer for streaming
+
 	// Use Gin's Stream method for efficient streaming
 	c.Stream(func(w io.Writer) bool {
 		// Check if context was canceled
-		select {
-		case <-c.Request.Context().Done():
+		if c.Request.Context().Err() != nil {
 			// Client disconnected or timeout - stop streaming
 			return false
-		default:
-			// Continue streaming
 		}
 
 		// Read and write chunks from Claude API to client
-		buf := make([]byte, 4096) // 4KB buffer for streaming
-		n, err := (*resp).Read(buf)
+		n, err := body.Read(buf)
 
 		if n > 0 {
 			// Write chunk to client
@@ -111,19 +146,12 @@ func (h *ProxyHandler) streamSSEResponse(c *gin.Context, resp *io.ReadCloser) {
 			}
 		}
 
-		// Check for errors
-		if err == io.EOF {
-			// End of stream - stop streaming
-			return false
-		}
-		if err != nil {
-			// Stream error - stop streaming
-			return false
-		}
-
-		// Continue streaming (return true to keep stream open)
-		return true
+		// Stop on end of stream or stream error, continue otherwise
+		return err == nil
 	})
+
+	// Release the upstream connection right away (Close is idempotent, the deferred close is a no-op)
+	body.Close()
 }
 
 // GetModels handles GET /v1/models
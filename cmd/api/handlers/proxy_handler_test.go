@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"claude-proxy/modules/auth/domain/entities"
+	"claude-proxy/modules/proxy/domain/interfaces"
+
+	"github.com/gin-gonic/gin"
+)
+
+// forwardingProxyService forwards every request to upstream with the caller's context, as the proxy does
+type forwardingProxyService struct {
+	interfaces.ProxyService
+	upstream string
+}
+
+func (s *forwardingProxyService) ProxyRequest(
+	ctx context.Context,
+	_ *entities.Token,
+	req *http.Request,
+) (*http.Response, error) {
+	upstreamReq, err := http.NewRequestWithContext(ctx, req.Method, s.upstream+req.URL.Path, req.Body)
+	if err != nil {
+		return nil, err
+	}
+	return http.DefaultClient.Do(upstreamReq)
+}
+
+// newTestProxyServer serves handler's ProxyRequest behind a stand-in for BearerTokenAuth
+func newTestProxyServer(t *testing.T, handler *ProxyHandler) *httptest.Server {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	engine := gin.New()
+	engine.Use(func(c *gin.Context) {
+		c.Set("validated_token", &entities.Token{ID: "tok_test"})
+	})
+	engine.Any("/v1/*path", handler.ProxyRequest)
+
+	server := httptest.NewServer(engine)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestStreamSSEResponseCancelsUpstreamOnClientDisconnect(t *testing.T) {
+	upstreamDone := make(chan time.Time, 1)
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		flusher := w.(http.Flusher)
+		ticker := time.NewTicker(20 * time.Millisecond)
+		defer ticker.Stop()
+
+		for i := 0; ; i++ {
+			select {
+			case <-r.Context().Done():
+				upstreamDone <- time.Now()
+				return
+			case <-ticker.C:
+				fmt.Fprintf(w, "event: ping\ndata: {\"n\":%d}\n\n", i)
+				flusher.Flush()
+			}
+		}
+	}))
+	defer upstream.Close()
+
+	proxy := newTestProxyServer(t, NewProxyHandler(&forwardingProxyService{upstream: upstream.URL}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodPost, proxy.URL+"/v1/messages", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request through the proxy failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	// Wait for the stream to flow, then disconnect
+	reader := bufio.NewReader(resp.Body)
+	if _, err := reader.ReadString('\n'); err != nil {
+		t.Fatalf("no event reached the client: %v", err)
+	}
+	disconnectedAt := time.Now()
+	cancel()
+
+	select {
+	case canceledAt := <-upstreamDone:
+		if elapsed := canceledAt.Sub(disconnectedAt); elapsed > time.Second {
+			t.Errorf("upstream saw the cancellation %s after the disconnect, want under 1s", elapsed)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("upstream did not see the cancellation within 1s of the client disconnect")
+	}
+}
@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"net/http"
+
+	authinterfaces "claude-proxy/modules/auth/domain/interfaces"
+	"claude-proxy/modules/proxy/application/dto"
+	"claude-proxy/modules/proxy/domain/interfaces"
+	"claude-proxy/pkg/errors"
+	"claude-proxy/pkg/middleware"
+
+	"github.com/gin-gonic/gin"
+	sctx "github.com/phathdt/service-context"
+)
+
+// ReplayHandler handles captured failed requests and their replay through a chosen account
+type ReplayHandler struct {
+	replayService interfaces.ReplayService
+	captures      interfaces.FailureCapture
+	tokenService  authinterfaces.TokenService
+	logger        sctx.Logger
+}
+
+// NewReplayHandler creates a new replay handler
+func NewReplayHandler(
+	replayService interfaces.ReplayService,
+	captures interfaces.FailureCapture,
+	tokenService authinterfaces.TokenService,
+	logger sctx.Logger,
+) *ReplayHandler {
+	return &ReplayHandler{
+		replayService: replayService,
+		captures:      captures,
+		tokenService:  tokenService,
+		logger:        logger,
+	}
+}
+
+// GetTokenFailures handles GET /api/tokens/:id/failures
+// Returns the token's last captured failed requests (newest first); empty unless the token has capture_failures
+func (h *ReplayHandler) GetTokenFailures(c *gin.Context) {
+	id := c.Param("id")
+
+	token, err := h.tokenService.GetTokenByID(c.Request.Context(), id)
+	if err != nil {
+		panic(errors.NewNotFoundError("TOKEN_NOT_FOUND", "Token not found", id))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"token_id":         token.ID,
+		"capture_failures": token.CaptureFailures,
+		"failures":         dto.ToCapturedRequestResponses(h.captures.Recent(token.ID)),
+	})
+}
+
+// Replay handles POST /api/admin/replay
+// Sends a captured request envelope to Claude API through the given account and returns the response
+func (h *ReplayHandler) Replay(c *gin.Context) {
+	var req dto.ReplayRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		panic(errors.NewBadRequestError("INVALID_REQUEST", "Invalid request body", err.Error()))
+	}
+	if req.BodyTruncated {
+		panic(errors.NewBadRequestError(
+			"BODY_TRUNCATED", "Captured body was truncated", "only requests captured whole can be replayed",
+		))
+	}
+
+	h.logger.Withs(sctx.Fields{
+		"admin":      c.GetString(middleware.AdminIdentityContextKey),
+		"account_id": req.AccountID,
+		"method":     req.Method,
+		"path":       req.Path,
+	}).Info("Replay requested")
+
+	result, err := h.replayService.Replay(c.Request.Context(), req.AccountID, req.ToCapturedRequest())
+	if err != nil {
+		panic(err)
+	}
+
+	c.JSON(http.StatusOK, dto.ToReplayResponse(req.AccountID, result))
+}
@@ -1,67 +1,177 @@
 package handlers
 
 import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
 	"net/http"
+	"time"
 
 	"claude-proxy/modules/auth/application/dto"
+	"claude-proxy/modules/auth/domain/entities"
 	"claude-proxy/modules/auth/domain/interfaces"
 	"claude-proxy/pkg/errors"
+	"claude-proxy/pkg/middleware"
 
 	"github.com/gin-gonic/gin"
 	sctx "github.com/phathdt/service-context"
+	"github.com/phathdt/service-context/core"
 )
 
 // SessionHandler handles session-related HTTP requests
 type SessionHandler struct {
 	sessionService interfaces.SessionService
+	history        interfaces.SessionHistoryService
 	logger         sctx.Logger
 }
 
 // NewSessionHandler creates a new session handler
 func NewSessionHandler(
 	sessionService interfaces.SessionService,
+	history interfaces.SessionHistoryService,
 	appLogger sctx.Logger,
 ) *SessionHandler {
 	logger := appLogger.Withs(sctx.Fields{"component": "session-handler"})
 	return &SessionHandler{
 		sessionService: sessionService,
+		history:        history,
 		logger:         logger,
 	}
 }
 
-// ListAllSessions lists all active sessions (admin)
-// GET /api/admin/sessions
+// ListAllSessions lists sessions with filters, sorting and pagination (admin)
+// GET /api/admin/sessions?token_id=&ip=&active=true&expired=true&created_after=&sort=&order=&page=&limit=
 func (h *SessionHandler) ListAllSessions(c *gin.Context) {
 	ctx := c.Request.Context()
 
-	sessions, err := h.sessionService.GetAllSessions(ctx)
+	var query dto.SessionQueryParams
+	if err := c.ShouldBindQuery(&query); err != nil {
+		panic(errors.NewBadRequestError("INVALID_REQUEST", "Invalid query parameters", err.Error()))
+	}
+	if query.Active && query.Expired {
+		panic(errors.NewBadRequestError(
+			"INVALID_REQUEST", "Invalid query parameters", "active and expired exclude each other",
+		))
+	}
+
+	var paging core.Paging
+	if err := c.ShouldBindQuery(&paging); err != nil {
+		panic(errors.NewBadRequestError("INVALID_REQUEST", "Invalid pagination parameters", err.Error()))
+	}
+	paging.Process()
+
+	sessions, counts, err := h.sessionService.ListSessions(ctx, &query, &paging)
 	if err != nil {
 		h.logger.Withs(sctx.Fields{"error": err}).Error("Failed to list sessions")
 		panic(errors.NewInternalServerError("failed to list sessions: " + err.Error()))
 	}
 
-	// Convert to DTOs
 	sessionResponses := make([]*dto.SessionResponse, len(sessions))
 	for i, session := range sessions {
-		sessionResponses[i] = &dto.SessionResponse{
-			ID:          session.ID,
-			TokenID:     session.TokenID,
-			UserAgent:   session.UserAgent,
-			IPAddress:   session.IPAddress,
-			CreatedAt:   session.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),  // RFC3339
-			LastSeenAt:  session.LastSeenAt.Format("2006-01-02T15:04:05Z07:00"), // RFC3339
-			ExpiresAt:   session.ExpiresAt.Format("2006-01-02T15:04:05Z07:00"),  // RFC3339
-			IsActive:    session.IsActive,
-			RequestPath: session.RequestPath,
-		}
+		sessionResponses[i] = dto.ToSessionResponse(session)
 	}
 
 	c.JSON(http.StatusOK, dto.ListSessionsResponse{
 		Sessions: sessionResponses,
-		Total:    len(sessionResponses),
+		Total:    int(paging.Total),
+		Paging:   paging,
+		Totals: dto.SessionTotalsResponse{
+			All:     counts.Total,
+			Active:  counts.Active,
+			Expired: counts.Expired,
+		},
+	})
+}
+
+// ListSessionHistory returns the sessions created in a month, newest first (admin)
+// GET /api/admin/sessions/history?token_id=&month=2024-06&format=json|csv&page=&limit=
+// JSON is paginated; CSV exports every record of the month matching token_id
+func (h *SessionHandler) ListSessionHistory(c *gin.Context) {
+	var query dto.SessionHistoryQueryParams
+	if err := c.ShouldBindQuery(&query); err != nil {
+		panic(errors.NewBadRequestError("INVALID_REQUEST", "Invalid query parameters", err.Error()))
+	}
+	if query.Month.IsZero() {
+		query.Month = time.Now()
+	}
+	month := entities.SessionHistoryMonth(query.Month).Format("2006-01")
+
+	var paging core.Paging
+	if err := c.ShouldBindQuery(&paging); err != nil {
+		panic(errors.NewBadRequestError("INVALID_REQUEST", "Invalid pagination parameters", err.Error()))
+	}
+	paging.Process()
+
+	records, err := h.history.List(c.Request.Context(), query.Month, query.TokenID)
+	if err != nil {
+		h.logger.Withs(sctx.Fields{"error": err, "month": month}).Error("Failed to read session history")
+		panic(errors.NewInternalServerError("failed to read session history: " + err.Error()))
+	}
+
+	if query.Format == "csv" {
+		var b bytes.Buffer
+		w := csv.NewWriter(&b)
+		_ = w.Write(dto.SessionHistoryCSVHeader)
+		for _, record := range records {
+			_ = w.Write(dto.ToSessionHistoryRecordDTO(record).CSVRow())
+		}
+		w.Flush()
+
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", "session-history-"+month+".csv"))
+		c.Data(http.StatusOK, "text/csv; charset=utf-8", b.Bytes())
+		return
+	}
+
+	paging.Total = int64(len(records))
+	start := min((paging.Page-1)*paging.Limit, len(records))
+	end := min(start+paging.Limit, len(records))
+	responses := make([]*dto.SessionHistoryRecordDTO, 0, end-start)
+	for _, record := range records[start:end] {
+		responses = append(responses, dto.ToSessionHistoryRecordDTO(record))
+	}
+
+	c.JSON(http.StatusOK, dto.ListSessionHistoryResponse{
+		Month:   month,
+		Records: responses,
+		Paging:  paging,
 	})
 }
 
+// RevokeSessions revokes every session matching a filter (admin)
+// POST /api/admin/sessions/revoke {"token_id": "...", "ip": "...", "created_before": "...", "all": true}
+func (h *SessionHandler) RevokeSessions(c *gin.Context) {
+	var req dto.RevokeSessionsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		panic(errors.NewBadRequestError("INVALID_REQUEST", "Invalid request body", err.Error()))
+	}
+	if !req.HasFilter() && !req.All {
+		panic(errors.NewBadRequestError(
+			"FILTER_REQUIRED", "Refusing to revoke every session",
+			"set token_id, ip or created_before, or all: true to revoke every session",
+		))
+	}
+
+	revoked, err := h.sessionService.RevokeSessions(c.Request.Context(), &req)
+	if err != nil {
+		h.logger.Withs(sctx.Fields{"error": err}).Error("Failed to revoke sessions")
+		panic(errors.NewInternalServerError("failed to revoke sessions: " + err.Error()))
+	}
+
+	fields := sctx.Fields{
+		"admin":    c.GetString(middleware.AdminIdentityContextKey),
+		"revoked":  len(revoked),
+		"token_id": req.TokenID,
+		"ip":       req.IP,
+		"all":      req.All,
+	}
+	if req.CreatedBefore != nil {
+		fields["created_before"] = req.CreatedBefore.Format(time.RFC3339)
+	}
+	h.logger.Withs(fields).Warn("Sessions revoked in bulk via API")
+
+	c.JSON(http.StatusOK, dto.ToRevokeSessionsResponse(revoked))
+}
+
 // RevokeSession revokes a specific session
 // DELETE /api/sessions/:id
 func (h *SessionHandler) RevokeSession(c *gin.Context) {
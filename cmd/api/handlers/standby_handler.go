@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	stderrors "errors"
+	"net/http"
+	"time"
+
+	"claude-proxy/modules/auth/domain/entities"
+	"claude-proxy/modules/auth/domain/interfaces"
+	"claude-proxy/pkg/errors"
+
+	"github.com/gin-gonic/gin"
+)
+
+// StandbyHandler handles the warm standby endpoints
+type StandbyHandler struct {
+	standbyService interfaces.StandbyService
+}
+
+// NewStandbyHandler creates a new standby handler
+func NewStandbyHandler(standbyService interfaces.StandbyService) *StandbyHandler {
+	return &StandbyHandler{
+		standbyService: standbyService,
+	}
+}
+
+// GetStandby handles GET /api/admin/standby
+func (h *StandbyHandler) GetStandby(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"standby": standbyStatus(h.standbyService.Status()),
+	})
+}
+
+// Promote handles POST /api/admin/promote
+// Stops pulling from the primary and starts serving /v1 with the state mirrored so far
+func (h *StandbyHandler) Promote(c *gin.Context) {
+	status, err := h.standbyService.Promote(c.Request.Context())
+	if err != nil {
+		if stderrors.Is(err, entities.ErrNotStandby) {
+			panic(errors.NewConflictError("NOT_STANDBY", "Instance is not a standby", err.Error()))
+		}
+		panic(errors.NewInternalError("PROMOTE_FAILED", "Failed to promote standby", err.Error()))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "standby promoted, serving proxy traffic",
+		"standby": standbyStatus(status),
+	})
+}
+
+// standbyStatus reports the standby state and its last pulls from the primary; sync_age_seconds (time since
+// the last pull that reached the primary, until promoted) is what staleness alerts watch
+func standbyStatus(status entities.StandbyStatus) gin.H {
+	result := gin.H{
+		"enabled": status.Enabled,
+		"standby": status.Standby,
+	}
+	if !status.Enabled {
+		return result
+	}
+
+	result["primary_url"] = status.PrimaryURL
+	result["accounts"] = status.Accounts
+	result["tokens"] = status.Tokens
+	result["sessions"] = status.Sessions
+	if status.LastSyncAt != nil {
+		result["last_sync_at"] = status.LastSyncAt.UTC()
+		if status.Standby {
+			result["sync_age_seconds"] = int64(time.Since(*status.LastSyncAt).Seconds())
+		}
+	}
+	if status.LastChangeAt != nil {
+		result["last_change_at"] = status.LastChangeAt.UTC()
+	}
+	if status.LastAttemptAt != nil {
+		result["last_attempt_at"] = status.LastAttemptAt.UTC()
+	}
+	if status.LastError != "" {
+		result["last_error"] = status.LastError
+	}
+	if status.PromotedAt != nil {
+		result["promoted_at"] = status.PromotedAt.UTC()
+	}
+	return result
+}
@@ -1,27 +1,101 @@
 package handlers
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
 	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
+	"claude-proxy/modules/auth/application/dto"
+	"claude-proxy/modules/auth/domain/entities"
 	"claude-proxy/modules/auth/domain/interfaces"
+	proxydto "claude-proxy/modules/proxy/application/dto"
+	proxyentities "claude-proxy/modules/proxy/domain/entities"
+	proxyinterfaces "claude-proxy/modules/proxy/domain/interfaces"
+	"claude-proxy/pkg/middleware"
+	"claude-proxy/pkg/storage"
 
 	"github.com/gin-gonic/gin"
 	sctx "github.com/phathdt/service-context"
 )
 
+const (
+	defaultStatsPeriod = 24 * time.Hour
+	defaultStatsBucket = time.Hour
+
+	// maskedNameLength is how many characters of an account or token name viewers see
+	maskedNameLength = 3
+
+	// statisticsVersion is bumped when GET /api/admin/statistics gains sections (older fields are kept)
+	statisticsVersion = 13
+)
+
 // StatisticsHandler handles statistics-related requests
 type StatisticsHandler struct {
 	accountService interfaces.AccountService
+	tokenService   interfaces.TokenService
+	statsService   interfaces.StatisticsService
+	sessionService interfaces.SessionService
+	breaker        proxyinterfaces.CircuitBreaker
+	metrics        proxyinterfaces.TrafficMetrics
+	queue          proxyinterfaces.RequestQueue
+	history        proxyinterfaces.RequestHistory
+	clock          proxyinterfaces.ClockMonitor
+	authGuard      proxyinterfaces.AuthFailureGuard
+	shadow         proxyinterfaces.ShadowMirror
+	upstreamUsage  proxyinterfaces.UpstreamUsage
+	usageDays      int // Days compared in the upstream usage of admin-capable accounts (accounts.upstream_usage_days)
+	canary         proxyinterfaces.CanaryRouter
+	tiers          proxyinterfaces.PriorityTierTracker
+	caches         interfaces.CacheMonitor
+	storage        *storage.Monitor
 	logger         sctx.Logger
 }
 
 // NewStatisticsHandler creates a new statistics handler
 func NewStatisticsHandler(
 	accountService interfaces.AccountService,
+	tokenService interfaces.TokenService,
+	statsService interfaces.StatisticsService,
+	sessionService interfaces.SessionService,
+	breaker proxyinterfaces.CircuitBreaker,
+	metrics proxyinterfaces.TrafficMetrics,
+	queue proxyinterfaces.RequestQueue,
+	history proxyinterfaces.RequestHistory,
+	clock proxyinterfaces.ClockMonitor,
+	authGuard proxyinterfaces.AuthFailureGuard,
+	shadow proxyinterfaces.ShadowMirror,
+	upstreamUsage proxyinterfaces.UpstreamUsage,
+	usageDays int,
+	canary proxyinterfaces.CanaryRouter,
+	tiers proxyinterfaces.PriorityTierTracker,
+	caches interfaces.CacheMonitor,
+	storageMonitor *storage.Monitor,
 	logger sctx.Logger,
 ) *StatisticsHandler {
 	return &StatisticsHandler{
 		accountService: accountService,
+		tokenService:   tokenService,
+		statsService:   statsService,
+		sessionService: sessionService,
+		breaker:        breaker,
+		metrics:        metrics,
+		queue:          queue,
+		history:        history,
+		clock:          clock,
+		authGuard:      authGuard,
+		shadow:         shadow,
+		upstreamUsage:  upstreamUsage,
+		usageDays:      usageDays,
+		canary:         canary,
+		tiers:          tiers,
+		caches:         caches,
+		storage:        storageMonitor,
 		logger:         logger,
 	}
 }
@@ -40,7 +114,535 @@ func (h *StatisticsHandler) GetStatistics(c *gin.Context) {
 		return
 	}
 
+	h.addBreakerStatistics(statistics)
+	h.addRequestHistoryStatistics(statistics)
+	h.addShadowStatistics(statistics)
+	h.addUpstreamUsageStatistics(statistics)
+	statistics["version"] = statisticsVersion
+	statistics["traffic"] = h.trafficStatistics()
+	statistics["sessions"] = h.sessionStatistics(c.Request.Context())
+	statistics["queue"] = h.queueStatistics()
+	statistics["canary"] = h.canaryStatistics()
+	statistics["priority_tiers"] = h.priorityTierStatistics(c.Request.Context())
+	statistics["clock"] = h.clockStatistics()
+	statistics["caches"] = h.cacheStatistics()
+	statistics["storage"] = storageStatus(h.storage)
+	if middleware.IsViewer(c) {
+		maskStatistics(statistics)
+	}
+
 	h.logger.Debug("Statistics retrieved successfully")
 
 	c.JSON(http.StatusOK, statistics)
 }
+
+// addBreakerStatistics adds each account's circuit breaker state and window metrics to the statistics
+func (h *StatisticsHandler) addBreakerStatistics(statistics map[string]interface{}) {
+	if !h.breaker.Enabled() {
+		return
+	}
+
+	accountUsage, _ := statistics["account_usage"].([]map[string]interface{})
+	openCount := 0
+	for _, usage := range accountUsage {
+		accountID, _ := usage["account_id"].(string)
+		status := h.breaker.Status(accountID)
+		if status.State != proxyentities.BreakerStateClosed {
+			openCount++
+		}
+
+		usage["breaker_state"] = status.State
+		usage["breaker_requests"] = status.Requests
+		usage["breaker_p95_latency_ms"] = status.P95Latency.Milliseconds()
+		usage["breaker_error_rate"] = status.ErrorRate
+		if !status.OpenedAt.IsZero() {
+			usage["breaker_opened_at"] = status.OpenedAt.Format(time.RFC3339)
+		}
+	}
+	statistics["open_circuit_breakers"] = openCount
+}
+
+// addRequestHistoryStatistics adds each account's last request and the errors among its recent requests
+func (h *StatisticsHandler) addRequestHistoryStatistics(statistics map[string]interface{}) {
+	accountUsage, _ := statistics["account_usage"].([]map[string]interface{})
+	for _, usage := range accountUsage {
+		accountID, _ := usage["account_id"].(string)
+		recent := h.history.Recent(accountID)
+
+		errorCount := 0
+		for i := range recent {
+			if recent[i].IsError() {
+				errorCount++
+			}
+		}
+		usage["recent_requests"] = len(recent)
+		usage["recent_errors"] = errorCount
+		if len(recent) > 0 {
+			usage["last_request_at"] = recent[0].Timestamp.Format(time.RFC3339)
+			usage["last_status_code"] = recent[0].StatusCode
+			if recent[0].Error != "" {
+				usage["last_error"] = recent[0].Error
+			}
+		}
+	}
+}
+
+// addShadowStatistics adds the mirrored request counts of each shadow account to the statistics
+func (h *StatisticsHandler) addShadowStatistics(statistics map[string]interface{}) {
+	shadowStats := h.shadow.Stats()
+	accountUsage, _ := statistics["account_usage"].([]map[string]interface{})
+	for _, usage := range accountUsage {
+		if shadow, _ := usage["shadow"].(bool); !shadow {
+			continue
+		}
+		accountID, _ := usage["account_id"].(string)
+		stats := shadowStats[accountID]
+
+		usage["shadow_sent"] = stats.Sent
+		usage["shadow_failed"] = stats.Failed
+		usage["shadow_dropped"] = stats.Dropped
+		if stats.Sent > 0 {
+			usage["shadow_error_rate"] = float64(stats.Failed) / float64(stats.Sent)
+			usage["shadow_last_at"] = stats.LastAt.Format(time.RFC3339)
+			usage["shadow_last_latency_ms"] = stats.LastLatency.Milliseconds()
+		}
+		if stats.LastError != "" {
+			usage["shadow_last_error"] = stats.LastError
+		}
+	}
+	statistics["shadow_mirroring"] = h.shadow.Enabled()
+}
+
+// addUpstreamUsageStatistics adds the usage Claude API reported for each admin-capable account over the last
+// accounts.upstream_usage_days days next to the usage the proxy counted for it
+func (h *StatisticsHandler) addUpstreamUsageStatistics(statistics map[string]interface{}) {
+	to := time.Now().UTC()
+	from := to.AddDate(0, 0, -(h.usageDays - 1))
+
+	accountUsage, _ := statistics["account_usage"].([]map[string]interface{})
+	for _, usage := range accountUsage {
+		if adminCapable, _ := usage["admin_capable"].(bool); !adminCapable {
+			continue
+		}
+		accountID, _ := usage["account_id"].(string)
+		upstream := h.upstreamUsage.Get(accountID)
+		if upstream == nil {
+			upstream = &proxyentities.AccountUpstreamUsage{AccountID: accountID}
+		}
+
+		official, local := upstream.Totals(from, to)
+		report := gin.H{
+			"status":   upstream.Status,
+			"from":     proxyentities.UsageDate(from),
+			"to":       proxyentities.UsageDate(to),
+			"official": proxydto.ToDailyUsageDTO(official),
+			"local":    proxydto.ToDailyUsageDTO(local),
+		}
+		if !upstream.LastSuccessAt.IsZero() {
+			report["last_success_at"] = upstream.LastSuccessAt.Format(time.RFC3339)
+		}
+		if upstream.LastError != "" {
+			report["last_error"] = upstream.LastError
+		}
+		usage["upstream_usage"] = report
+	}
+}
+
+// trafficStatistics reports the proxied traffic counted since startup
+func (h *StatisticsHandler) trafficStatistics() gin.H {
+	traffic := h.metrics.Snapshot()
+	totalTokens := traffic.InputTokens + traffic.OutputTokens + traffic.CacheCreationTokens + traffic.CacheReadTokens
+	return gin.H{
+		"started_at":       traffic.StartedAt.Format(time.RFC3339),
+		"uptime_seconds":   int64(time.Since(traffic.StartedAt).Seconds()),
+		"requests":         traffic.Requests,
+		"errors":           traffic.Errors,
+		"in_flight":        traffic.InFlight,
+		"requests_last_1m": traffic.RequestsLastMinute,
+		"requests_last_5m": traffic.RequestsLast5Min,
+		"requests_last_1h": traffic.RequestsLastHour,
+		"errors_last_1m":   traffic.ErrorsLastMinute,
+		"errors_last_5m":   traffic.ErrorsLast5Min,
+		"errors_last_1h":   traffic.ErrorsLastHour,
+		"avg_latency_ms":   traffic.AverageLatency.Milliseconds(),
+		"p95_latency_ms":   traffic.P95Latency.Milliseconds(),
+		"input_tokens":     traffic.InputTokens,
+		"output_tokens":    traffic.OutputTokens,
+		"total_tokens":     totalTokens,
+
+		"cache_creation_input_tokens": traffic.CacheCreationTokens,
+		"cache_read_input_tokens":     traffic.CacheReadTokens,
+		"cache_hit_ratio": entities.CacheHitRatio(
+			int(traffic.InputTokens), int(traffic.CacheCreationTokens), int(traffic.CacheReadTokens),
+		),
+	}
+}
+
+// canaryStatistics reports the split of sessions between canary accounts and the rest of the pool, and the
+// requests each cohort's accounts served since startup
+func (h *StatisticsHandler) canaryStatistics() gin.H {
+	cohorts := gin.H{}
+	for cohort, traffic := range h.canary.Stats() {
+		errorRate := 0.0
+		if traffic.Requests > 0 {
+			errorRate = float64(traffic.Errors) / float64(traffic.Requests)
+		}
+		cohorts[string(cohort)] = gin.H{
+			"requests":         traffic.Requests,
+			"errors":           traffic.Errors,
+			"error_rate":       errorRate,
+			"requests_last_5m": traffic.RequestsLast5Min,
+			"requests_last_1h": traffic.RequestsLastHour,
+			"errors_last_5m":   traffic.ErrorsLast5Min,
+			"errors_last_1h":   traffic.ErrorsLastHour,
+			"avg_latency_ms":   traffic.AverageLatency.Milliseconds(),
+			"p95_latency_ms":   traffic.P95Latency.Milliseconds(),
+		}
+	}
+	return gin.H{
+		"enabled": h.canary.Enabled(),
+		"percent": h.canary.Percent(),
+		"cohorts": cohorts,
+	}
+}
+
+// priorityTierStatistics reports, for each priority tier, its accounts (shadow ones excluded), those a request
+// could be sent through now, and the accounts selected from it over the last hour
+func (h *StatisticsHandler) priorityTierStatistics(ctx context.Context) []gin.H {
+	accounts, err := h.accountService.ListAccounts(ctx)
+	if err != nil {
+		h.logger.Withs(sctx.Fields{"error": err.Error()}).Warn("Failed to list accounts for priority tier statistics")
+	}
+
+	now := time.Now()
+	selections := h.tiers.Selections(now)
+	totals := make(map[int]int)
+	available := make(map[int]int)
+	for _, account := range accounts {
+		if account.Shadow {
+			continue
+		}
+		totals[account.Priority]++
+		if account.IsAvailableForProxy() && account.IsWithinAvailability(now) && account.IsUsableNow() &&
+			!account.IsOverQuota() && !account.IsCoolingDown() && h.breaker.Selectable(account.ID) {
+			available[account.Priority]++
+		}
+	}
+
+	// Tiers emptied since their last selections are still listed
+	priorities := make([]int, 0, len(totals))
+	for priority := range totals {
+		priorities = append(priorities, priority)
+	}
+	for priority := range selections {
+		if _, ok := totals[priority]; !ok {
+			priorities = append(priorities, priority)
+		}
+	}
+	sort.Ints(priorities)
+
+	tiers := make([]gin.H, 0, len(priorities))
+	for _, priority := range priorities {
+		tiers = append(tiers, gin.H{
+			"priority":           priority,
+			"accounts":           totals[priority],
+			"available_accounts": available[priority],
+			"selections_last_1h": selections[priority],
+		})
+	}
+	return tiers
+}
+
+// queueStatistics reports the requests waiting for an account and how long queued requests waited
+func (h *StatisticsHandler) queueStatistics() gin.H {
+	queue := h.queue.Status()
+	return gin.H{
+		"enabled":       queue.Enabled,
+		"max_wait_ms":   queue.MaxWait.Milliseconds(),
+		"size":          queue.Size,
+		"depth":         queue.Depth,
+		"served":        queue.Served,
+		"timed_out":     queue.TimedOut,
+		"canceled":      queue.Canceled,
+		"rejected":      queue.Rejected,
+		"avg_wait_ms":   queue.AverageWait.Milliseconds(),
+		"max_waited_ms": queue.MaxWaited.Milliseconds(),
+	}
+}
+
+// clockStatistics reports the local clock's skew against Claude API and the upstream 401s held because of it
+func (h *StatisticsHandler) clockStatistics() gin.H {
+	clock := h.clock.Status()
+	auth := h.authGuard.Status()
+
+	stats := gin.H{
+		"measured":                clock.Measured,
+		"skew_ms":                 clock.Skew.Milliseconds(),
+		"max_skew_ms":             clock.MaxSkew.Milliseconds(),
+		"exceeded":                clock.Exceeded(),
+		"aggressive_invalidation": auth.Aggressive,
+		"auth_failure_window_ms":  auth.Window.Milliseconds(),
+		"held_401_accounts":       auth.HeldAccounts,
+	}
+	if clock.Measured {
+		stats["source"] = clock.Source
+		stats["checked_at"] = clock.CheckedAt.Format(time.RFC3339)
+	}
+	if !auth.OutageSince.IsZero() {
+		stats["auth_outage_since"] = auth.OutageSince.Format(time.RFC3339)
+	}
+	return stats
+}
+
+// cacheStatistics reports the entry count, estimated memory usage and soft limit of each in-memory cache
+func (h *StatisticsHandler) cacheStatistics() gin.H {
+	stats := gin.H{}
+	for name, cache := range h.caches.Stats() {
+		stats[name] = gin.H{
+			"entries":         cache.Entries,
+			"approx_bytes":    cache.ApproxBytes,
+			"soft_limit":      cache.SoftLimit,
+			"over_soft_limit": cache.OverSoftLimit(),
+		}
+	}
+	return stats
+}
+
+// sessionStatistics reports the active session count, overall and per token (empty when sessions are disabled)
+func (h *StatisticsHandler) sessionStatistics(ctx context.Context) gin.H {
+	sessions, err := h.sessionService.GetAllSessions(ctx)
+	if err != nil {
+		h.logger.Withs(sctx.Fields{"error": err.Error()}).Warn("Failed to list sessions for statistics")
+	}
+
+	active := 0
+	perToken := make(map[string]int)
+	for _, session := range sessions {
+		if !session.IsActive || session.IsExpired() {
+			continue
+		}
+		active++
+		perToken[session.TokenID]++
+	}
+
+	tokens := make([]gin.H, 0, len(perToken))
+	for tokenID, count := range perToken {
+		name := ""
+		if token, err := h.tokenService.GetTokenByID(ctx, tokenID); err == nil {
+			name = token.Name
+		}
+		tokens = append(tokens, gin.H{"token_id": tokenID, "token_name": name, "active": count})
+	}
+	sort.Slice(tokens, func(i, j int) bool {
+		if tokens[i]["active"].(int) != tokens[j]["active"].(int) {
+			return tokens[i]["active"].(int) > tokens[j]["active"].(int)
+		}
+		return tokens[i]["token_id"].(string) < tokens[j]["token_id"].(string)
+	})
+
+	return gin.H{
+		"active":    active,
+		"per_token": tokens,
+	}
+}
+
+// GetTokenStats handles GET /api/tokens/:id/stats?period=7d&bucket=1h
+func (h *StatisticsHandler) GetTokenStats(c *gin.Context) {
+	id := c.Param("id")
+
+	var query dto.UsageStatsQueryParams
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	period, err := parseStatsDuration(query.Period, defaultStatsPeriod)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid period: " + err.Error()})
+		return
+	}
+	bucket, err := parseStatsDuration(query.Bucket, defaultStatsBucket)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid bucket: " + err.Error()})
+		return
+	}
+
+	// Statistics outlive deleted tokens, but per-token queries are only served for existing ones
+	token, err := h.tokenService.GetTokenByID(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Token not found"})
+		return
+	}
+
+	buckets, err := h.statsService.GetTokenStats(c.Request.Context(), token.ID, period, bucket)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	total := &entities.UsageBucket{TokenID: token.ID}
+	for _, b := range buckets {
+		total.Merge(b)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"token_id":   token.ID,
+		"token_name": token.Name,
+		"period":     period.String(),
+		"bucket":     bucket.String(),
+		"buckets":    dto.ToUsageBucketResponses(buckets),
+		"totals":     dto.ToTokenUsageRankingResponse(total, token.Name),
+	})
+}
+
+// GetTokenUsers handles GET /api/tokens/:id/users?period=7d&limit=50
+// Lists the distinct end users (metadata.user_id) of a token with their request counts, busiest first
+func (h *StatisticsHandler) GetTokenUsers(c *gin.Context) {
+	id := c.Param("id")
+
+	var query dto.UsageStatsQueryParams
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	period, err := parseStatsDuration(query.Period, defaultStatsPeriod)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid period: " + err.Error()})
+		return
+	}
+
+	token, err := h.tokenService.GetTokenByID(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Token not found"})
+		return
+	}
+
+	users, err := h.statsService.ListTokenUsers(c.Request.Context(), token.ID, period)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	total := len(users)
+	if query.Limit > 0 && len(users) > query.Limit {
+		users = users[:query.Limit]
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"token_id":    token.ID,
+		"token_name":  token.Name,
+		"period":      period.String(),
+		"total_users": total,
+		"users":       dto.ToEndUserUsageResponses(users),
+	})
+}
+
+// GetTokenRanking handles GET /api/admin/stats/tokens?period=24h&limit=10
+func (h *StatisticsHandler) GetTokenRanking(c *gin.Context) {
+	var query dto.UsageStatsQueryParams
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	period, err := parseStatsDuration(query.Period, defaultStatsPeriod)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid period: " + err.Error()})
+		return
+	}
+
+	ranking, err := h.statsService.RankTokens(c.Request.Context(), period)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if query.Limit > 0 && len(ranking) > query.Limit {
+		ranking = ranking[:query.Limit]
+	}
+
+	// Resolve names for the ranked tokens (deleted tokens keep their ID only)
+	responses := make([]*dto.TokenUsageRankingResponse, len(ranking))
+	for i, total := range ranking {
+		name := ""
+		if token, err := h.tokenService.GetTokenByID(c.Request.Context(), total.TokenID); err == nil {
+			name = token.Name
+		}
+		if middleware.IsViewer(c) {
+			name = maskName(name)
+		}
+		responses[i] = dto.ToTokenUsageRankingResponse(total, name)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"period": period.String(),
+		"tokens": responses,
+	})
+}
+
+// maskStatistics masks the identifiers viewers may not see: account and token names are truncated and
+// organization UUIDs replaced by a stable hash, so entries can still be told apart
+func maskStatistics(statistics map[string]interface{}) {
+	accountUsage, _ := statistics["account_usage"].([]map[string]interface{})
+	for _, usage := range accountUsage {
+		name, _ := usage["account_name"].(string)
+		usage["account_name"] = maskName(name)
+		orgUUID, _ := usage["organization_uuid"].(string)
+		usage["organization_uuid"] = hashIdentifier(orgUUID)
+	}
+
+	sessions, _ := statistics["sessions"].(gin.H)
+	perToken, _ := sessions["per_token"].([]gin.H)
+	for _, token := range perToken {
+		name, _ := token["token_name"].(string)
+		token["token_name"] = maskName(name)
+	}
+}
+
+// maskName keeps the first maskedNameLength characters of a name ("Personal Max" -> "Per…"), or only the
+// first one of shorter names
+func maskName(name string) string {
+	runes := []rune(name)
+	switch {
+	case len(runes) == 0:
+		return ""
+	case len(runes) <= maskedNameLength:
+		return string(runes[:1]) + "…"
+	default:
+		return string(runes[:maskedNameLength]) + "…"
+	}
+}
+
+// hashIdentifier replaces an identifier with the start of its SHA-256 (empty stays empty)
+func hashIdentifier(id string) string {
+	if id == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(id))
+	return hex.EncodeToString(sum[:6])
+}
+
+// parseStatsDuration parses a Go duration, additionally accepting whole days ("7d")
+func parseStatsDuration(value string, fallback time.Duration) (time.Duration, error) {
+	if value == "" {
+		return fallback, nil
+	}
+
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n <= 0 {
+			return 0, fmt.Errorf("%q is not a positive number of days", value)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, err
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("%q must be positive", value)
+	}
+	return d, nil
+}
@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"claude-proxy/modules/auth/domain/entities"
+	"claude-proxy/pkg/errors"
+	"claude-proxy/pkg/middleware"
+
+	"github.com/gin-gonic/gin"
+	sctx "github.com/phathdt/service-context"
+)
+
+// DataReloader merges the data files into the in-memory stores (the sync scheduler)
+type DataReloader interface {
+	Reload(ctx context.Context) (accounts, tokens *entities.ReconcileReport, err error)
+}
+
+// StorageHandler handles data folder maintenance endpoints
+type StorageHandler struct {
+	reloader DataReloader
+	logger   sctx.Logger
+}
+
+// NewStorageHandler creates a new storage handler
+func NewStorageHandler(reloader DataReloader, appLogger sctx.Logger) *StorageHandler {
+	return &StorageHandler{
+		reloader: reloader,
+		logger:   appLogger.Withs(sctx.Fields{"component": "storage-handler"}),
+	}
+}
+
+// reconcileResponse represents how one data file was merged into the cache
+type reconcileResponse struct {
+	Changed   bool     `json:"changed"`    // Modified by another writer since the server last loaded or saved it
+	Added     []string `json:"added"`      // Only in the file, loaded
+	Updated   []string `json:"updated"`    // Changed in the file, replacing the cached version
+	CacheOnly []string `json:"cache_only"` // Missing from the file, kept and written back by the next sync
+	Skipped   []string `json:"skipped"`    // Conflicting with another cached record, left as cached
+	Conflicts []string `json:"conflicts"`  // Changed in the file and in the cache, the newer version kept
+}
+
+// toReconcileResponse converts a reconcile report to its response shape (empty lists rather than null)
+func toReconcileResponse(report *entities.ReconcileReport) reconcileResponse {
+	orEmpty := func(ids []string) []string {
+		if ids == nil {
+			return []string{}
+		}
+		return ids
+	}
+	return reconcileResponse{
+		Changed:   report.Changed,
+		Added:     orEmpty(report.Added),
+		Updated:   orEmpty(report.Updated),
+		CacheOnly: orEmpty(report.CacheOnly),
+		Skipped:   orEmpty(report.Skipped),
+		Conflicts: orEmpty(report.Conflicts),
+	}
+}
+
+// Reload handles POST /api/admin/reload
+// Merges accounts.json and tokens.json into the cache right away instead of at the next sync
+func (h *StorageHandler) Reload(c *gin.Context) {
+	accounts, tokens, err := h.reloader.Reload(c.Request.Context())
+	if err != nil {
+		panic(errors.NewInternalError("RELOAD_FAILED", "Failed to reload data files", err.Error()))
+	}
+
+	h.logger.Withs(sctx.Fields{
+		"admin":            c.GetString(middleware.AdminIdentityContextKey),
+		"accounts_changed": accounts.Changed,
+		"tokens_changed":   tokens.Changed,
+	}).Info("Data files reloaded on request")
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "data files reloaded",
+		"accounts": toReconcileResponse(accounts),
+		"tokens":   toReconcileResponse(tokens),
+	})
+}
@@ -6,6 +6,7 @@ import (
 	"claude-proxy/modules/auth/application/dto"
 	"claude-proxy/modules/auth/domain/entities"
 	"claude-proxy/modules/auth/domain/interfaces"
+	"claude-proxy/pkg/middleware"
 
 	"github.com/gin-gonic/gin"
 	"github.com/phathdt/service-context/core"
@@ -107,6 +108,16 @@ func (h *TokenHandler) CreateToken(c *gin.Context) {
 		return
 	}
 
+	if err := middleware.ValidatePathPatterns(req.AllowedPaths); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": gin.H{
+				"type":    "invalid_request_error",
+				"message": err.Error(),
+			},
+		})
+		return
+	}
+
 	// Call service to create token
 	token, err := h.tokenService.CreateToken(
 		c.Request.Context(),
@@ -125,6 +136,73 @@ func (h *TokenHandler) CreateToken(c *gin.Context) {
 		return
 	}
 
+	if len(req.AllowedPaths) > 0 {
+		token, err = h.tokenService.UpdateTokenAllowedPaths(c.Request.Context(), token.ID, req.AllowedPaths)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": gin.H{
+					"type":    "invalid_request_error",
+					"message": err.Error(),
+				},
+			})
+			return
+		}
+	}
+
+	if req.MaxSessions > 0 {
+		token, err = h.tokenService.UpdateTokenMaxSessions(c.Request.Context(), token.ID, req.MaxSessions)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": gin.H{
+					"type":    "invalid_request_error",
+					"message": err.Error(),
+				},
+			})
+			return
+		}
+	}
+
+	if req.UsageSummary {
+		token, err = h.tokenService.UpdateTokenUsageSummary(c.Request.Context(), token.ID, true)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": gin.H{
+					"type":    "invalid_request_error",
+					"message": err.Error(),
+				},
+			})
+			return
+		}
+	}
+
+	if req.CaptureFailures {
+		token, err = h.tokenService.UpdateTokenCaptureFailures(c.Request.Context(), token.ID, true)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": gin.H{
+					"type":    "invalid_request_error",
+					"message": err.Error(),
+				},
+			})
+			return
+		}
+	}
+
+	if req.ExternalUserIDHeader != "" {
+		token, err = h.tokenService.UpdateTokenExternalUserIDHeader(
+			c.Request.Context(), token.ID, req.ExternalUserIDHeader,
+		)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": gin.H{
+					"type":    "invalid_request_error",
+					"message": err.Error(),
+				},
+			})
+			return
+		}
+	}
+
 	c.JSON(http.StatusCreated, gin.H{
 		"success": true,
 		"message": "Token created successfully",
@@ -166,7 +244,8 @@ func (h *TokenHandler) UpdateToken(c *gin.Context) {
 		name = *req.Name
 	}
 
-	key := existingToken.Key
+	// Keys are stored hashed; an empty key keeps the current one
+	key := ""
 	if req.Key != nil {
 		key = *req.Key
 	}
@@ -181,6 +260,18 @@ func (h *TokenHandler) UpdateToken(c *gin.Context) {
 		role = entities.TokenRole(*req.Role)
 	}
 
+	if req.AllowedPaths != nil {
+		if err := middleware.ValidatePathPatterns(*req.AllowedPaths); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": gin.H{
+					"type":    "invalid_request_error",
+					"message": err.Error(),
+				},
+			})
+			return
+		}
+	}
+
 	// Call service to update token
 	token, err := h.tokenService.UpdateToken(
 		c.Request.Context(),
@@ -200,6 +291,71 @@ func (h *TokenHandler) UpdateToken(c *gin.Context) {
 		return
 	}
 
+	if req.AllowedPaths != nil {
+		token, err = h.tokenService.UpdateTokenAllowedPaths(c.Request.Context(), id, *req.AllowedPaths)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": gin.H{
+					"type":    "invalid_request_error",
+					"message": err.Error(),
+				},
+			})
+			return
+		}
+	}
+
+	if req.MaxSessions != nil {
+		token, err = h.tokenService.UpdateTokenMaxSessions(c.Request.Context(), id, *req.MaxSessions)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": gin.H{
+					"type":    "invalid_request_error",
+					"message": err.Error(),
+				},
+			})
+			return
+		}
+	}
+
+	if req.UsageSummary != nil {
+		token, err = h.tokenService.UpdateTokenUsageSummary(c.Request.Context(), id, *req.UsageSummary)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": gin.H{
+					"type":    "invalid_request_error",
+					"message": err.Error(),
+				},
+			})
+			return
+		}
+	}
+
+	if req.CaptureFailures != nil {
+		token, err = h.tokenService.UpdateTokenCaptureFailures(c.Request.Context(), id, *req.CaptureFailures)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": gin.H{
+					"type":    "invalid_request_error",
+					"message": err.Error(),
+				},
+			})
+			return
+		}
+	}
+
+	if req.ExternalUserIDHeader != nil {
+		token, err = h.tokenService.UpdateTokenExternalUserIDHeader(c.Request.Context(), id, *req.ExternalUserIDHeader)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": gin.H{
+					"type":    "invalid_request_error",
+					"message": err.Error(),
+				},
+			})
+			return
+		}
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "Token updated successfully",
@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"net/http"
+
+	"claude-proxy/modules/proxy/application/dto"
+	"claude-proxy/modules/proxy/domain/interfaces"
+	"claude-proxy/pkg/errors"
+
+	"github.com/gin-gonic/gin"
+)
+
+// WebhookHandler handles HTTP requests for usage event webhooks
+type WebhookHandler struct {
+	dispatcher interfaces.WebhookDispatcher
+}
+
+// NewWebhookHandler creates a new webhook handler
+func NewWebhookHandler(dispatcher interfaces.WebhookDispatcher) *WebhookHandler {
+	return &WebhookHandler{
+		dispatcher: dispatcher,
+	}
+}
+
+// GetFailures handles GET /api/admin/webhooks/failures
+// Returns the delivery counters (delivered, failed, dropped on queue overflow) and the most recent failures
+func (h *WebhookHandler) GetFailures(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"webhooks": dto.ToWebhookStatusResponse(h.dispatcher.Status()),
+	})
+}
+
+// SendTest handles POST /api/admin/webhooks/test
+// Delivers a sample event once, synchronously; a failed delivery is reported in the response body
+func (h *WebhookHandler) SendTest(c *gin.Context) {
+	if !h.dispatcher.Status().Enabled {
+		panic(errors.NewConflictError("WEBHOOKS_DISABLED", "Webhooks are disabled", "set webhooks.enabled in config"))
+	}
+
+	statusCode, err := h.dispatcher.SendTest(c.Request.Context())
+	resp := dto.WebhookTestResponse{Delivered: err == nil, StatusCode: statusCode}
+	if err != nil {
+		resp.Error = err.Error()
+	}
+	c.JSON(http.StatusOK, resp)
+}
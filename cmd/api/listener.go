@@ -0,0 +1,65 @@
+package api
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+
+	"claude-proxy/config"
+)
+
+// listen opens the server listener: a TCP port, or a Unix socket when server.listen selects one
+func listen(cfg config.ServerConfig) (net.Listener, error) {
+	network, address := cfg.ListenAddress()
+	if network != "unix" {
+		return net.Listen(network, address)
+	}
+
+	// A socket left behind by a crashed process blocks the bind; anything else at the path is not ours to remove
+	if info, err := os.Lstat(address); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("%s exists and is not a socket", address)
+		}
+		if err := os.Remove(address); err != nil {
+			return nil, fmt.Errorf("failed to remove stale socket %s: %w", address, err)
+		}
+	}
+
+	listener, err := net.Listen(network, address)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(address, cfg.SocketFileMode()); err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("failed to set permissions of %s: %w", address, err)
+	}
+	return listener, nil
+}
+
+// serve runs the server on the listener, over TLS when certificate files are configured
+func serve(server *http.Server, listener net.Listener, cfg config.ServerConfig) error {
+	if !cfg.TLSEnabled() {
+		return server.Serve(listener)
+	}
+	server.TLSConfig = newTLSConfig()
+	return server.ServeTLS(listener, cfg.TLS.CertFile, cfg.TLS.KeyFile)
+}
+
+// newTLSConfig returns modern TLS defaults: TLS 1.2+ with forward-secret AEAD ciphers and HTTP/2 negotiation
+func newTLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		// TLS 1.3 suites are not configurable and always enabled
+		CipherSuites: []uint16{
+			tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256,
+			tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256,
+		},
+		NextProtos: []string{"h2", "http/1.1"},
+	}
+}
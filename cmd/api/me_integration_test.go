@@ -0,0 +1,102 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"claude-proxy/config"
+	authentities "claude-proxy/modules/auth/domain/entities"
+)
+
+const otherTokenKey = "sk-proxy-other-token"
+
+// meSessions lists the sessions GET /v1/me/sessions returns for key
+func meSessions(t *testing.T, stack *testStack, key, query string) []string {
+	t.Helper()
+
+	resp := stack.do(t, http.MethodGet, "/v1/me/sessions"+query, "", http.Header{"Authorization": {"Bearer " + key}})
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET /v1/me/sessions status = %d, want 200", resp.StatusCode)
+	}
+	var page struct {
+		Sessions []struct {
+			ID      string `json:"id"`
+			TokenID string `json:"token_id"`
+		} `json:"sessions"`
+		Total int `json:"total"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&page); err != nil {
+		t.Fatal(err)
+	}
+	ids := make([]string, len(page.Sessions))
+	for i, session := range page.Sessions {
+		ids[i] = session.ID
+	}
+	if page.Total != len(ids) {
+		t.Errorf("total = %d for %d sessions", page.Total, len(ids))
+	}
+	return ids
+}
+
+// newSessionStack starts a test stack with session limiting and a second token, each token holding one session
+func newSessionStack(t *testing.T) (stack *testStack, other *authentities.Token, own, others string) {
+	t.Helper()
+
+	stack = newTestStack(t, answerMessage, func(cfg *config.Config) {
+		cfg.Session.Enabled = true
+	})
+	other, err := stack.tokens.CreateToken(
+		context.Background(), "other", otherTokenKey, authentities.TokenStatusActive, authentities.TokenRoleUser,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body := `{"model":"claude-sonnet-4","max_tokens":10,"messages":[{"role":"user","content":"hi"}]}`
+	for _, key := range []string{testTokenKey, otherTokenKey} {
+		resp := stack.do(t, http.MethodPost, "/v1/messages", body, http.Header{"Authorization": {"Bearer " + key}})
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("POST /v1/messages status = %d, want 200", resp.StatusCode)
+		}
+	}
+
+	ownSessions := meSessions(t, stack, testTokenKey, "")
+	otherSessions := meSessions(t, stack, otherTokenKey, "")
+	if len(ownSessions) != 1 || len(otherSessions) != 1 {
+		t.Fatalf("tokens hold %d and %d sessions, want 1 each", len(ownSessions), len(otherSessions))
+	}
+	return stack, other, ownSessions[0], otherSessions[0]
+}
+
+func TestIntegrationMeSessionsListsOnlyOwnSessions(t *testing.T) {
+	stack, other, own, _ := newSessionStack(t)
+
+	// Naming the other token in the query changes nothing
+	for _, query := range []string{"", "?token_id=" + other.ID, "?active=true"} {
+		if got := meSessions(t, stack, testTokenKey, query); len(got) != 1 || got[0] != own {
+			t.Errorf("GET /v1/me/sessions%s = %v, want only %s", query, got, own)
+		}
+	}
+}
+
+func TestIntegrationMeSessionsRevokesOnlyOwnSessions(t *testing.T) {
+	stack, _, own, others := newSessionStack(t)
+
+	resp := stack.do(t, http.MethodDelete, "/v1/me/sessions/"+others, "", nil)
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("revoking another token's session: status = %d, want 404", resp.StatusCode)
+	}
+
+	resp = stack.do(t, http.MethodDelete, "/v1/me/sessions/"+own, "", nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("revoking an own session: status = %d, want 200", resp.StatusCode)
+	}
+	if got := meSessions(t, stack, testTokenKey, "?active=true"); len(got) != 0 {
+		t.Errorf("active sessions after revocation = %v, want none", got)
+	}
+	if got := meSessions(t, stack, otherTokenKey, "?active=true"); len(got) != 1 || got[0] != others {
+		t.Errorf("other token's active sessions = %v, want %s", got, others)
+	}
+}
@@ -1,23 +1,34 @@
 package api
 
 import (
+	"bytes"
 	"context"
 	"fmt"
+	"io/fs"
 	"net/http"
+	"os"
+	"path/filepath"
 	"time"
 
 	"claude-proxy/cmd/api/handlers"
 	"claude-proxy/config"
 	authservices "claude-proxy/modules/auth/application/services"
+	authentities "claude-proxy/modules/auth/domain/entities"
 	authinterfaces "claude-proxy/modules/auth/domain/interfaces"
 	authclients "claude-proxy/modules/auth/infrastructure/clients"
 	authjobs "claude-proxy/modules/auth/infrastructure/jobs"
 	authrepos "claude-proxy/modules/auth/infrastructure/repositories"
 	proxyservices "claude-proxy/modules/proxy/application/services"
+	proxyentities "claude-proxy/modules/proxy/domain/entities"
 	proxyinterfaces "claude-proxy/modules/proxy/domain/interfaces"
 	proxyclients "claude-proxy/modules/proxy/infrastructure/clients"
 	proxyjobs "claude-proxy/modules/proxy/infrastructure/jobs"
+	proxyrepos "claude-proxy/modules/proxy/infrastructure/repositories"
 	"claude-proxy/pkg/errors"
+	"claude-proxy/pkg/health"
+	"claude-proxy/pkg/logging"
+	"claude-proxy/pkg/middleware"
+	"claude-proxy/pkg/storage"
 	"claude-proxy/pkg/telegram"
 
 	"github.com/gin-gonic/gin"
@@ -29,7 +40,7 @@ import (
 var CoreProviders = fx.Options(
 	fx.Provide(
 		LoadConfig,
-		func(cfg *config.Config) (sctx.ServiceContext, sctx.Logger, error) {
+		func(cfg *config.Config) (sctx.ServiceContext, sctx.Logger, *logging.Registry, error) {
 			return InitServiceContext(cfg)
 		},
 	),
@@ -53,6 +64,10 @@ var CloveProviders = fx.Options(
 			NewMemorySessionRepository,
 			fx.ResultTags(`name:"cacheSessionRepo"`),
 		),
+		fx.Annotate(
+			NewMemoryUsageStatsRepository,
+			fx.ResultTags(`name:"cacheUsageStatsRepo"`),
+		),
 		// Infrastructure - JSON Repositories (persistence layer)
 		fx.Annotate(
 			NewJSONAccountRepository,
@@ -66,6 +81,20 @@ var CloveProviders = fx.Options(
 			NewJSONSessionRepository,
 			fx.ResultTags(`name:"persistenceSessionRepo"`),
 		),
+		fx.Annotate(
+			NewJSONUsageStatsRepository,
+			fx.ResultTags(`name:"persistenceUsageStatsRepo"`),
+		),
+		NewJSONAdminKeyRepository,
+		NewJSONInviteRepository,
+		NewJSONLMutationAuditRepository,
+		NewJSONLSessionHistoryRepository,
+		NewJSONDeviceAuthRepository,
+		NewJSONModelCacheRepository,
+		NewJSONMaintenanceRepository,
+		NewJSONUpstreamUsageRepository,
+		NewJSONBatchRepository,
+		NewJSONFileRepository,
 		// Infrastructure - Clients
 		NewClaudeAPIClient,
 		// Application - Services (hybrid storage)
@@ -79,13 +108,68 @@ var CloveProviders = fx.Options(
 		),
 		fx.Annotate(
 			NewSessionService,
-			fx.ParamTags(`name:"cacheSessionRepo"`, `name:"persistenceSessionRepo"`, ``, ``),
+			fx.ParamTags(`name:"cacheSessionRepo"`, `name:"persistenceSessionRepo"`, ``, ``, ``),
+		),
+		fx.Annotate(
+			NewStatisticsService,
+			fx.ParamTags(`name:"cacheUsageStatsRepo"`, `name:"persistenceUsageStatsRepo"`, ``, ``),
+		),
+		fx.Annotate(
+			authservices.NewCacheMonitor,
+			fx.ParamTags(
+				`name:"cacheAccountRepo"`,
+				`name:"cacheTokenRepo"`,
+				`name:"cacheSessionRepo"`,
+				`name:"cacheUsageStatsRepo"`,
+			),
 		),
+		NewAdminKeyService,
+		NewMutationAuditService,
+		NewSessionHistoryService,
+		NewInviteService,
+		NewDeviceAuthService,
+		NewModelCatalog,
+		NewMaintenanceService,
+		NewUpstreamUsageService,
+		NewBatchService,
+		NewFileService,
+		NewCircuitBreaker,
+		NewTrafficMetrics,
+		NewRequestHistory,
+		NewWebhookDispatcher,
+		NewRequestQueue,
+		NewClockMonitor,
+		NewAuthFailureGuard,
+		NewAccountFailureTracker,
+		NewShadowMirror,
+		NewCanaryRouter,
+		proxyservices.NewPriorityTierTracker,
+		NewAlertEvaluator,
+		proxyservices.NewFailureCapture,
+		NewReplayService,
+		NewSessionToucher,
 		NewProxyService,
+		NewStandbyService,
+		fx.Annotate(
+			NewBackupService,
+			fx.ParamTags(
+				``, ``, ``, ``,
+				`name:"persistenceAccountRepo"`,
+				`name:"persistenceTokenRepo"`,
+				`name:"persistenceSessionRepo"`,
+				`name:"persistenceUsageStatsRepo"`,
+				``, ``, ``,
+			),
+		),
 		// Infrastructure - Jobs
 		NewSyncScheduler,
 		NewTokenRefreshScheduler,
 		NewSessionCleanupScheduler,
+		NewBackupScheduler,
+		NewWarmupScheduler,
+		NewClockCheckScheduler,
+		NewUpstreamUsageScheduler,
+		NewAlertScheduler,
 		// Handlers
 		NewTokenHandler,
 		NewProxyHandler,
@@ -93,7 +177,28 @@ var CloveProviders = fx.Options(
 		NewAccountHandler,
 		NewOAuthHandler,
 		NewStatisticsHandler,
+		NewMetricsHandler,
 		NewSessionHandler,
+		NewMeHandler,
+		NewBackupHandler,
+		NewStorageHandler,
+		NewAdminKeyHandler,
+		NewInviteHandler,
+		NewDeviceAuthHandler,
+		NewMaintenanceHandler,
+		NewWebhookHandler,
+		NewAlertHandler,
+		NewAuditHandler,
+		NewBatchHandler,
+		NewFileHandler,
+		NewReplayHandler,
+		NewLogLevelHandler,
+		NewConfigHandler,
+		NewStandbyHandler,
+		NewHealthHandler,
+		// Health checks
+		NewHealthChecker,
+		NewStorageMonitor,
 		// Telegram client (optional)
 		NewTelegramClient,
 	),
@@ -107,9 +212,23 @@ var APIProviders = fx.Options(
 		NewGinEngine,
 	),
 	fx.Invoke(
+		// Run before any service loads the data files
+		MigrateLegacyAccountFiles,
+		MigrateLegacyAccountIDs,
+		RegisterReadinessChecks,
+		StartStandby,
 		StartSyncScheduler,
+		// After the sync scheduler, so the last touches are flushed before the final sync
+		StartSessionToucher,
 		StartTokenRefreshScheduler,
 		StartSessionCleanupScheduler,
+		StartSessionHistory,
+		StartBackupScheduler,
+		StartWebhookDispatcher,
+		StartWarmupScheduler,
+		StartClockCheckScheduler,
+		StartUpstreamUsageScheduler,
+		StartAlertScheduler,
 	),
 )
 
@@ -119,7 +238,8 @@ func LoadConfig(configPath string) (*config.Config, error) {
 }
 
 // InitServiceContext creates and loads the service context with database component and sets up global logger
-func InitServiceContext(cfg *config.Config) (sctx.ServiceContext, sctx.Logger, error) {
+// The returned logger takes its level from the log level registry, which allows runtime changes per component
+func InitServiceContext(cfg *config.Config) (sctx.ServiceContext, sctx.Logger, *logging.Registry, error) {
 	// Set up global logger first
 	loggerConfig := &sctx.Config{
 		DefaultLevel: cfg.Logger.Level,
@@ -136,22 +256,39 @@ func InitServiceContext(cfg *config.Config) (sctx.ServiceContext, sctx.Logger, e
 
 	// Load all components
 	if err := sc.Load(); err != nil {
-		return nil, nil, fmt.Errorf("failed to load service context: %w", err)
+		return nil, nil, nil, fmt.Errorf("failed to load service context: %w", err)
+	}
+
+	// The registry filters by level itself, so its base logger writes every level
+	registry, err := logging.NewRegistry(cfg.Logger.Level)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("invalid logger.level: %w", err)
 	}
+	base := sctx.NewAppLogger(&sctx.Config{
+		DefaultLevel: "trace",
+		BasePrefix:   "claude-proxy",
+		Format:       cfg.Logger.Format,
+	}).GetLogger("main")
 
-	return sc, sctx.GlobalLogger().GetLogger("main"), nil
+	return sc, registry.Wrap(base), registry, nil
 }
 
 // NewGinEngine creates a new Gin engine with middleware
-func NewGinEngine(cfg *config.Config) *gin.Engine {
+func NewGinEngine(cfg *config.Config, appLogger sctx.Logger) (*gin.Engine, error) {
 	gin.SetMode(gin.ReleaseMode)
 
 	engine := gin.New()
+	// Forwarded client IPs are only believed from the configured reverse proxies, so clients can't pick the
+	// IP their rate limits are keyed by
+	if err := engine.SetTrustedProxies(cfg.Server.TrustedProxies); err != nil {
+		return nil, fmt.Errorf("invalid server.trusted_proxies: %w", err)
+	}
 
-	engine.Use(ginLoggerMiddleware())
+	logger := appLogger.Withs(sctx.Fields{"component": "gin"})
+	engine.Use(middleware.RequestID())
+	engine.Use(ginLoggerMiddleware(logger))
 
 	engine.Use(gin.CustomRecovery(func(c *gin.Context, recovered any) {
-		logger := sctx.GlobalLogger().GetLogger("gin")
 		logger.Withs(sctx.Fields{"panic": recovered}).Error("PANIC RECOVERED")
 
 		// Check if it's an AppError panic (our custom error handling pattern)
@@ -162,6 +299,21 @@ func NewGinEngine(cfg *config.Config) *gin.Engine {
 				"error_detail": appErr.Details(),
 			}).Debug("Handling custom app error panic")
 
+			// Headers attached to the error (Retry-After of proxy-generated rate limits) go out with it
+			for key, values := range errors.HeadersOf(appErr) {
+				c.Writer.Header()[key] = values
+			}
+
+			// Claude API routes answer in the Anthropic error envelope
+			if middleware.UsesAnthropicErrorFormat(c) {
+				message := appErr.Message()
+				if appErr.Details() != "" {
+					message += ": " + appErr.Details()
+				}
+				middleware.AbortWithAnthropicErrorCode(c, appErr.StatusCode(), appErr.ErrorCode(), message)
+				return
+			}
+
 			c.JSON(appErr.StatusCode(), gin.H{
 				"code":    appErr.ErrorCode(),
 				"message": appErr.Message(),
@@ -172,6 +324,10 @@ func NewGinEngine(cfg *config.Config) *gin.Engine {
 		}
 
 		// Handle other error types
+		if middleware.UsesAnthropicErrorFormat(c) {
+			middleware.AbortWithAnthropicError(c, http.StatusInternalServerError, "An unexpected error occurred")
+			return
+		}
 		if err, ok := recovered.(error); ok {
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"error":   "Internal server error",
@@ -197,20 +353,12 @@ func NewGinEngine(cfg *config.Config) *gin.Engine {
 		c.Abort()
 	}))
 
-	// CORS middleware - Allow all domains
-	engine.Use(func(c *gin.Context) {
-		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
-		c.Writer.Header().
-			Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With, X-API-Key")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE")
-
-		if c.Request.Method == "OPTIONS" {
-			c.AbortWithStatus(204)
-			return
-		}
-
-		c.Next()
-	})
+	// CORS middleware - per-route-group policies (defaults allow all domains)
+	cors, err := middleware.CORS(corsPolicy(cfg.Server.CORS.Proxy), corsPolicy(cfg.Server.CORS.Admin))
+	if err != nil {
+		return nil, fmt.Errorf("invalid server.cors: %w", err)
+	}
+	engine.Use(cors)
 
 	// Timeout middleware - use configurable timeout for LLM API requests
 	engine.Use(func(c *gin.Context) {
@@ -221,11 +369,22 @@ func NewGinEngine(cfg *config.Config) *gin.Engine {
 		c.Next()
 	})
 
-	return engine
+	return engine, nil
+}
+
+// corsPolicy converts a server.cors policy to the middleware's
+func corsPolicy(cfg config.CORSPolicyConfig) middleware.CORSPolicy {
+	return middleware.CORSPolicy{
+		AllowedOrigins:   cfg.AllowedOrigins,
+		AllowedHeaders:   cfg.AllowedHeaders,
+		ExposedHeaders:   cfg.ExposedHeaders,
+		AllowCredentials: cfg.AllowCredentials,
+		MaxAge:           cfg.MaxAge,
+	}
 }
 
 // ginLoggerMiddleware creates a Gin middleware for structured logging
-func ginLoggerMiddleware() gin.HandlerFunc {
+func ginLoggerMiddleware(logger sctx.Logger) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		start := time.Now()
 		path := c.Request.URL.Path
@@ -243,8 +402,6 @@ func ginLoggerMiddleware() gin.HandlerFunc {
 			path = path + "?" + raw
 		}
 
-		logger := sctx.GlobalLogger().GetLogger("gin")
-
 		fields := sctx.Fields{
 			"method":      method,
 			"path":        path,
@@ -252,6 +409,7 @@ func ginLoggerMiddleware() gin.HandlerFunc {
 			"status_code": statusCode,
 			"latency":     latency.String(),
 			"user_agent":  c.Request.UserAgent(),
+			"request_id":  c.GetString(middleware.RequestIDKey),
 		}
 
 		if errorMessage != "" {
@@ -291,6 +449,10 @@ func NewOAuthClient(cfg *config.Config, appLogger sctx.Logger) authinterfaces.OA
 		cfg.OAuth.TokenURL,
 		cfg.OAuth.RedirectURI,
 		cfg.OAuth.Scope,
+		cfg.OAuth.OrganizationsURL,
+		cfg.Retry.MaxRetries,
+		cfg.Retry.RetryDelay,
+		cfg.Claude.Headers.Map(),
 		logger,
 	)
 }
@@ -299,19 +461,39 @@ func NewOAuthClient(cfg *config.Config, appLogger sctx.Logger) authinterfaces.OA
 // Memory Repository Providers (Fast in-memory operations)
 // ============================================================================
 
-// NewMemoryAccountRepository creates a new in-memory account repository (cache)
-func NewMemoryAccountRepository(appLogger sctx.Logger) authinterfaces.CacheRepository {
-	return authrepos.NewMemoryAccountRepository(appLogger)
+// NewMemoryAccountRepository creates a new in-memory account repository (cache), recording admin changes
+// in the audit trail
+func NewMemoryAccountRepository(
+	cfg *config.Config,
+	audit authinterfaces.MutationAuditService,
+	appLogger sctx.Logger,
+) authinterfaces.CacheRepository {
+	repo := authrepos.NewMemoryAccountRepository(max(cfg.Storage.CacheSoftLimits.Accounts, 0), appLogger)
+	return authrepos.NewAuditedAccountRepository(repo, audit)
 }
 
-// NewMemoryTokenRepository creates a new in-memory token repository (cache)
-func NewMemoryTokenRepository(appLogger sctx.Logger) authinterfaces.TokenCacheRepository {
-	return authrepos.NewMemoryTokenRepository(appLogger)
+// NewMemoryTokenRepository creates a new in-memory token repository (cache), recording admin changes in the
+// audit trail
+func NewMemoryTokenRepository(
+	cfg *config.Config,
+	audit authinterfaces.MutationAuditService,
+	appLogger sctx.Logger,
+) authinterfaces.TokenCacheRepository {
+	repo := authrepos.NewMemoryTokenRepository(max(cfg.Storage.CacheSoftLimits.Tokens, 0), appLogger)
+	return authrepos.NewAuditedTokenRepository(repo, audit)
 }
 
 // NewMemorySessionRepository creates a new in-memory session repository (cache)
-func NewMemorySessionRepository(appLogger sctx.Logger) authinterfaces.SessionCacheRepository {
-	return authrepos.NewMemorySessionRepository(appLogger)
+func NewMemorySessionRepository(cfg *config.Config, appLogger sctx.Logger) authinterfaces.SessionCacheRepository {
+	return authrepos.NewMemorySessionRepository(max(cfg.Storage.CacheSoftLimits.Sessions, 0), appLogger)
+}
+
+// NewMemoryUsageStatsRepository creates a new in-memory usage stats repository (cache)
+func NewMemoryUsageStatsRepository(
+	cfg *config.Config,
+	appLogger sctx.Logger,
+) authinterfaces.UsageStatsCacheRepository {
+	return authrepos.NewMemoryUsageStatsRepository(max(cfg.Storage.CacheSoftLimits.UsageBuckets, 0), appLogger)
 }
 
 // ============================================================================
@@ -322,7 +504,7 @@ func NewMemorySessionRepository(appLogger sctx.Logger) authinterfaces.SessionCac
 func NewJSONAccountRepository(cfg *config.Config, appLogger sctx.Logger) (authinterfaces.PersistenceRepository, error) {
 	logger := appLogger.Withs(sctx.Fields{"component": "json-account-persistence-repository"})
 
-	repo, err := authrepos.NewJSONAccountPersistenceRepository(cfg.Storage.DataFolder)
+	repo, err := authrepos.NewJSONAccountPersistenceRepository(cfg.Storage.DataFolder, cfg.Storage.Fsync, logger)
 	if err != nil {
 		logger.Withs(sctx.Fields{"error": err}).Error("Failed to create JSON account persistence repository")
 		return nil, fmt.Errorf("failed to create JSON account persistence repository: %w", err)
@@ -339,7 +521,7 @@ func NewJSONTokenRepository(
 ) (authinterfaces.TokenPersistenceRepository, error) {
 	logger := appLogger.Withs(sctx.Fields{"component": "json-token-repository"})
 
-	repo, err := authrepos.NewJSONTokenRepository(cfg.Storage.DataFolder)
+	repo, err := authrepos.NewJSONTokenRepository(cfg.Storage.DataFolder, cfg.Storage.Fsync, logger)
 	if err != nil {
 		logger.Withs(sctx.Fields{"error": err}).Error("Failed to create JSON token repository")
 		return nil, fmt.Errorf("failed to create JSON token repository: %w", err)
@@ -361,7 +543,9 @@ func NewJSONSessionRepository(
 
 	logger := appLogger.Withs(sctx.Fields{"component": "json-session-repository"})
 
-	repo, err := authrepos.NewJSONSessionRepository(cfg.Storage.DataFolder)
+	repo, err := authrepos.NewJSONSessionRepository(
+		cfg.Storage.DataFolder, cfg.Storage.Fsync, cfg.Session.Retention, cfg.Session.Archive, logger,
+	)
 	if err != nil {
 		logger.Withs(sctx.Fields{"error": err}).Error("Failed to create JSON session repository")
 		return nil, fmt.Errorf("failed to create JSON session repository: %w", err)
@@ -371,6 +555,197 @@ func NewJSONSessionRepository(
 	return repo, nil
 }
 
+// NewJSONUsageStatsRepository creates a new JSON usage stats repository
+func NewJSONUsageStatsRepository(
+	cfg *config.Config,
+	appLogger sctx.Logger,
+) (authinterfaces.UsageStatsPersistenceRepository, error) {
+	logger := appLogger.Withs(sctx.Fields{"component": "json-usage-stats-repository"})
+
+	repo, err := authrepos.NewJSONUsageStatsRepository(cfg.Storage.DataFolder, cfg.Storage.Fsync)
+	if err != nil {
+		logger.Withs(sctx.Fields{"error": err}).Error("Failed to create JSON usage stats repository")
+		return nil, fmt.Errorf("failed to create JSON usage stats repository: %w", err)
+	}
+
+	logger.Info("JSON usage stats repository initialized successfully")
+	return repo, nil
+}
+
+// NewJSONAdminKeyRepository creates a new JSON admin key repository
+func NewJSONAdminKeyRepository(
+	cfg *config.Config,
+	appLogger sctx.Logger,
+) (authinterfaces.AdminKeyRepository, error) {
+	logger := appLogger.Withs(sctx.Fields{"component": "json-admin-key-repository"})
+
+	repo, err := authrepos.NewJSONAdminKeyRepository(cfg.Storage.DataFolder, cfg.Storage.Fsync)
+	if err != nil {
+		logger.Withs(sctx.Fields{"error": err}).Error("Failed to create JSON admin key repository")
+		return nil, fmt.Errorf("failed to create JSON admin key repository: %w", err)
+	}
+
+	logger.Info("JSON admin key repository initialized successfully")
+	return repo, nil
+}
+
+// NewJSONInviteRepository creates a new JSON account invite repository
+func NewJSONInviteRepository(
+	cfg *config.Config,
+	appLogger sctx.Logger,
+) (authinterfaces.InviteRepository, error) {
+	logger := appLogger.Withs(sctx.Fields{"component": "json-invite-repository"})
+
+	repo, err := authrepos.NewJSONInviteRepository(cfg.Storage.DataFolder, cfg.Storage.Fsync)
+	if err != nil {
+		logger.Withs(sctx.Fields{"error": err}).Error("Failed to create JSON invite repository")
+		return nil, fmt.Errorf("failed to create JSON invite repository: %w", err)
+	}
+
+	logger.Info("JSON invite repository initialized successfully")
+	return repo, nil
+}
+
+// NewJSONLMutationAuditRepository creates the admin mutation audit trail (admin_audit.jsonl)
+func NewJSONLMutationAuditRepository(
+	cfg *config.Config,
+	appLogger sctx.Logger,
+) (authinterfaces.MutationAuditRepository, error) {
+	logger := appLogger.Withs(sctx.Fields{"component": "jsonl-mutation-audit-repository"})
+
+	repo, err := authrepos.NewJSONLMutationAuditRepository(cfg.Storage.DataFolder, cfg.Storage.Fsync, logger)
+	if err != nil {
+		logger.Withs(sctx.Fields{"error": err}).Error("Failed to create mutation audit repository")
+		return nil, fmt.Errorf("failed to create mutation audit repository: %w", err)
+	}
+
+	logger.Info("Mutation audit repository initialized successfully")
+	return repo, nil
+}
+
+// NewJSONLSessionHistoryRepository creates the monthly session history files (session-history-YYYY-MM.jsonl)
+func NewJSONLSessionHistoryRepository(
+	cfg *config.Config,
+	appLogger sctx.Logger,
+) (authinterfaces.SessionHistoryRepository, error) {
+	logger := appLogger.Withs(sctx.Fields{"component": "jsonl-session-history-repository"})
+
+	repo, err := authrepos.NewJSONLSessionHistoryRepository(cfg.Storage.DataFolder, cfg.Storage.Fsync, logger)
+	if err != nil {
+		logger.Withs(sctx.Fields{"error": err}).Error("Failed to create session history repository")
+		return nil, fmt.Errorf("failed to create session history repository: %w", err)
+	}
+
+	logger.Info("Session history repository initialized successfully")
+	return repo, nil
+}
+
+// NewJSONDeviceAuthRepository creates a new JSON device authorization repository
+func NewJSONDeviceAuthRepository(
+	cfg *config.Config,
+	appLogger sctx.Logger,
+) (authinterfaces.DeviceAuthRepository, error) {
+	logger := appLogger.Withs(sctx.Fields{"component": "json-device-auth-repository"})
+
+	repo, err := authrepos.NewJSONDeviceAuthRepository(cfg.Storage.DataFolder, cfg.Storage.Fsync)
+	if err != nil {
+		logger.Withs(sctx.Fields{"error": err}).Error("Failed to create JSON device authorization repository")
+		return nil, fmt.Errorf("failed to create JSON device authorization repository: %w", err)
+	}
+
+	logger.Info("JSON device authorization repository initialized successfully")
+	return repo, nil
+}
+
+// NewJSONModelCacheRepository creates a new JSON repository for the cached GET /v1/models response
+func NewJSONModelCacheRepository(
+	cfg *config.Config,
+	appLogger sctx.Logger,
+) (proxyinterfaces.ModelCacheRepository, error) {
+	logger := appLogger.Withs(sctx.Fields{"component": "json-model-cache-repository"})
+
+	repo, err := proxyrepos.NewJSONModelCacheRepository(authrepos.ExpandPath(cfg.Storage.DataFolder), cfg.Storage.Fsync)
+	if err != nil {
+		logger.Withs(sctx.Fields{"error": err}).Error("Failed to create JSON model cache repository")
+		return nil, fmt.Errorf("failed to create JSON model cache repository: %w", err)
+	}
+
+	logger.Info("JSON model cache repository initialized successfully")
+	return repo, nil
+}
+
+// NewJSONMaintenanceRepository creates a new JSON repository for the maintenance mode state
+func NewJSONMaintenanceRepository(
+	cfg *config.Config,
+	appLogger sctx.Logger,
+) (proxyinterfaces.MaintenanceRepository, error) {
+	logger := appLogger.Withs(sctx.Fields{"component": "json-maintenance-repository"})
+
+	repo, err := proxyrepos.NewJSONMaintenanceRepository(
+		authrepos.ExpandPath(cfg.Storage.DataFolder), cfg.Storage.Fsync,
+	)
+	if err != nil {
+		logger.Withs(sctx.Fields{"error": err}).Error("Failed to create JSON maintenance repository")
+		return nil, fmt.Errorf("failed to create JSON maintenance repository: %w", err)
+	}
+
+	logger.Info("JSON maintenance repository initialized successfully")
+	return repo, nil
+}
+
+// NewJSONUpstreamUsageRepository creates a new JSON repository for the official and local daily account usage
+func NewJSONUpstreamUsageRepository(
+	cfg *config.Config,
+	appLogger sctx.Logger,
+) (proxyinterfaces.UpstreamUsageRepository, error) {
+	logger := appLogger.Withs(sctx.Fields{"component": "json-upstream-usage-repository"})
+
+	repo, err := proxyrepos.NewJSONUpstreamUsageRepository(
+		authrepos.ExpandPath(cfg.Storage.DataFolder), cfg.Storage.Fsync,
+	)
+	if err != nil {
+		logger.Withs(sctx.Fields{"error": err}).Error("Failed to create JSON upstream usage repository")
+		return nil, fmt.Errorf("failed to create JSON upstream usage repository: %w", err)
+	}
+
+	logger.Info("JSON upstream usage repository initialized successfully")
+	return repo, nil
+}
+
+// NewJSONBatchRepository creates a new JSON repository for the message batch index
+func NewJSONBatchRepository(
+	cfg *config.Config,
+	appLogger sctx.Logger,
+) (proxyinterfaces.BatchRepository, error) {
+	logger := appLogger.Withs(sctx.Fields{"component": "json-batch-repository"})
+
+	repo, err := proxyrepos.NewJSONBatchRepository(authrepos.ExpandPath(cfg.Storage.DataFolder), cfg.Storage.Fsync)
+	if err != nil {
+		logger.Withs(sctx.Fields{"error": err}).Error("Failed to create JSON batch repository")
+		return nil, fmt.Errorf("failed to create JSON batch repository: %w", err)
+	}
+
+	logger.Info("JSON batch repository initialized successfully")
+	return repo, nil
+}
+
+// NewJSONFileRepository creates a new JSON repository for the uploaded file index
+func NewJSONFileRepository(
+	cfg *config.Config,
+	appLogger sctx.Logger,
+) (proxyinterfaces.FileRepository, error) {
+	logger := appLogger.Withs(sctx.Fields{"component": "json-file-repository"})
+
+	repo, err := proxyrepos.NewJSONFileRepository(authrepos.ExpandPath(cfg.Storage.DataFolder), cfg.Storage.Fsync)
+	if err != nil {
+		logger.Withs(sctx.Fields{"error": err}).Error("Failed to create JSON file repository")
+		return nil, fmt.Errorf("failed to create JSON file repository: %w", err)
+	}
+
+	logger.Info("JSON file repository initialized successfully")
+	return repo, nil
+}
+
 // ============================================================================
 // Service Providers (Hybrid storage - inject both memory and JSON repos)
 // ============================================================================
@@ -389,19 +764,40 @@ func NewAccountService(
 	cacheRepo authinterfaces.CacheRepository,
 	persistenceRepo authinterfaces.PersistenceRepository,
 	oauthClient authinterfaces.OAuthClient,
+	cfg *config.Config,
 	appLogger sctx.Logger,
 ) authinterfaces.AccountService {
-	return authservices.NewAccountService(cacheRepo, persistenceRepo, oauthClient, appLogger)
+	return authservices.NewAccountService(
+		cacheRepo, persistenceRepo, oauthClient, cfg.Accounts.NewAccountCooldown, appLogger,
+	)
 }
 
 // NewSessionService creates a new session service with cache and persistence layers
 func NewSessionService(
 	cacheRepo authinterfaces.SessionCacheRepository,
 	persistenceRepo authinterfaces.SessionPersistenceRepository,
+	history authinterfaces.SessionHistoryService,
 	cfg *config.Config,
 	appLogger sctx.Logger,
 ) authinterfaces.SessionService {
-	return authservices.NewSessionService(cacheRepo, persistenceRepo, cfg, appLogger)
+	return authservices.NewSessionService(cacheRepo, persistenceRepo, history, cfg, appLogger)
+}
+
+// NewStatisticsService creates a new usage statistics service with cache and persistence layers
+func NewStatisticsService(
+	cacheRepo authinterfaces.UsageStatsCacheRepository,
+	persistenceRepo authinterfaces.UsageStatsPersistenceRepository,
+	cfg *config.Config,
+	appLogger sctx.Logger,
+) authinterfaces.StatisticsService {
+	return authservices.NewStatisticsService(
+		cacheRepo,
+		persistenceRepo,
+		cfg.Stats.Resolution,
+		cfg.Stats.Retention,
+		cfg.Privacy.HashUserIDs,
+		appLogger,
+	)
 }
 
 // NewProxyService creates a new proxy service (only injects auth services)
@@ -409,112 +805,616 @@ func NewProxyService(
 	accountSvc authinterfaces.AccountService,
 	claudeClient *proxyclients.ClaudeAPIClient,
 	sessionSvc authinterfaces.SessionService,
+	statsSvc authinterfaces.StatisticsService,
+	models *proxyservices.ModelCatalog,
+	breaker proxyinterfaces.CircuitBreaker,
+	metrics proxyinterfaces.TrafficMetrics,
+	history proxyinterfaces.RequestHistory,
+	webhooks proxyinterfaces.WebhookDispatcher,
+	batches proxyinterfaces.BatchService,
+	files proxyinterfaces.FileService,
+	queue proxyinterfaces.RequestQueue,
+	clock proxyinterfaces.ClockMonitor,
+	authGuard proxyinterfaces.AuthFailureGuard,
+	failures proxyinterfaces.AccountFailureTracker,
+	shadow proxyinterfaces.ShadowMirror,
+	captures proxyinterfaces.FailureCapture,
+	upstreamUsage proxyinterfaces.UpstreamUsage,
+	canary proxyinterfaces.CanaryRouter,
+	tiers proxyinterfaces.PriorityTierTracker,
+	touches proxyinterfaces.SessionToucher,
+	cfg *config.Config,
 	appLogger sctx.Logger,
-) proxyinterfaces.ProxyService {
+) (proxyinterfaces.ProxyService, error) {
+	if err := middleware.ValidatePathPatterns(cfg.Session.ExemptPaths); err != nil {
+		return nil, fmt.Errorf("invalid session.exempt_paths: %w", err)
+	}
+
 	logger := appLogger.Withs(sctx.Fields{"component": "proxy-service"})
-	return proxyservices.NewProxyService(accountSvc, claudeClient, sessionSvc, logger)
+	shaper := proxyservices.NewRequestShaper(cfg.Proxy.RequestShaping)
+	normalizer := proxyservices.NewMessageNormalizer(cfg.Proxy.NormalizeMessages)
+	thinking := proxyservices.NewThinkingFixer(cfg.Proxy.ThinkingFix)
+	return proxyservices.NewProxyService(
+		accountSvc, claudeClient, sessionSvc, statsSvc, shaper, normalizer, thinking, models,
+		cfg.Proxy.WindowAware, cfg.Proxy.FilterModels, cfg.Session.ExemptPaths, cfg.Proxy.ForwardedHeaders, breaker, metrics, history, webhooks,
+		batches, files, queue, clock, authGuard, failures, shadow, captures, upstreamUsage, canary, tiers, touches,
+		logger,
+	), nil
 }
 
-// ============================================================================
-// Infrastructure - Clients
-// ============================================================================
-
-// NewClaudeAPIClient creates a new Claude API client
-func NewClaudeAPIClient(cfg *config.Config, appLogger sctx.Logger) *proxyclients.ClaudeAPIClient {
-	logger := appLogger.Withs(sctx.Fields{"component": "claude-api-client"})
-	return proxyclients.NewClaudeAPIClient(cfg.Claude.BaseURL, cfg.Server.RequestTimeout, logger)
+// NewSessionToucher creates the batcher recording the session activity of proxied requests
+func NewSessionToucher(sessionSvc authinterfaces.SessionService, appLogger sctx.Logger) proxyinterfaces.SessionToucher {
+	logger := appLogger.Withs(sctx.Fields{"component": "session-toucher"})
+	return proxyservices.NewSessionToucher(sessionSvc, logger)
 }
 
-// ============================================================================
-// Job Providers
-// ============================================================================
+// NewReplayService creates the service resending captured requests through a chosen account
+func NewReplayService(
+	accountSvc authinterfaces.AccountService,
+	claudeClient *proxyclients.ClaudeAPIClient,
+	history proxyinterfaces.RequestHistory,
+	appLogger sctx.Logger,
+) proxyinterfaces.ReplayService {
+	logger := appLogger.Withs(sctx.Fields{"component": "replay-service"})
+	return proxyservices.NewReplayService(accountSvc, claudeClient, history, logger)
+}
 
-// NewSyncScheduler creates a new sync scheduler for hybrid storage
-func NewSyncScheduler(
-	accountService authinterfaces.AccountService,
-	tokenService authinterfaces.TokenService,
-	sessionService authinterfaces.SessionService,
+// NewShadowMirror creates the mirror copying a sample of message requests to shadow accounts
+func NewShadowMirror(
+	accountSvc authinterfaces.AccountService,
+	claudeClient *proxyclients.ClaudeAPIClient,
+	breaker proxyinterfaces.CircuitBreaker,
+	history proxyinterfaces.RequestHistory,
 	cfg *config.Config,
 	appLogger sctx.Logger,
-) *authjobs.SyncScheduler {
-	// Default sync interval: 1 minute
-	syncInterval := 1 * time.Minute
-	if cfg.Storage.SyncInterval > 0 {
-		syncInterval = cfg.Storage.SyncInterval
-	}
-
-	return authjobs.NewSyncScheduler(
-		accountService,
-		tokenService,
-		sessionService,
-		syncInterval,
-		appLogger,
+) proxyinterfaces.ShadowMirror {
+	logger := appLogger.Withs(sctx.Fields{"component": "shadow-mirror"})
+	return proxyservices.NewShadowMirror(
+		accountSvc, claudeClient, breaker, history, cfg.Proxy.ShadowSamplePercent, logger,
 	)
 }
 
-// StartSyncScheduler starts the sync scheduler with lifecycle management
-func StartSyncScheduler(
-	lc fx.Lifecycle,
-	scheduler *authjobs.SyncScheduler,
-	logger sctx.Logger,
-) error {
-	if err := scheduler.Start(); err != nil {
-		return err
-	}
+// NewCanaryRouter creates the router splitting sessions between canary accounts and the rest of the pool
+func NewCanaryRouter(accountSvc authinterfaces.AccountService, cfg *config.Config) proxyinterfaces.CanaryRouter {
+	return proxyservices.NewCanaryRouter(accountSvc, cfg.Proxy.CanaryPercent)
+}
 
-	lc.Append(fx.Hook{
-		OnStop: func(ctx context.Context) error {
-			logger.Info("Performing final sync before shutdown")
-			scheduler.Stop()
-			if err := scheduler.FinalSync(); err != nil {
-				logger.Withs(sctx.Fields{"error": err}).Error("Final sync failed")
-				return err
-			}
-			return nil
-		},
-	})
+// NewClockMonitor creates the monitor of the local clock's skew against Claude API
+func NewClockMonitor(
+	telegramClient *telegram.Client,
+	cfg *config.Config,
+	appLogger sctx.Logger,
+) proxyinterfaces.ClockMonitor {
+	logger := appLogger.Withs(sctx.Fields{"component": "clock-monitor"})
+	return proxyservices.NewClockMonitor(cfg.Clock.MaxSkew, telegramClient, logger)
+}
 
-	return nil
+// NewAccountFailureTracker creates the tracker disabling accounts after consecutive upstream 403s
+func NewAccountFailureTracker(
+	accountSvc authinterfaces.AccountService,
+	telegramClient *telegram.Client,
+	cfg *config.Config,
+	appLogger sctx.Logger,
+) proxyinterfaces.AccountFailureTracker {
+	logger := appLogger.Withs(sctx.Fields{"component": "account-failures"})
+	return proxyservices.NewAccountFailureTracker(accountSvc, telegramClient, cfg.Proxy.AutoDisableFailures, logger)
 }
 
-// NewTokenRefreshScheduler creates a new token refresh scheduler
-func NewTokenRefreshScheduler(
+// NewAuthFailureGuard creates the guard deciding which upstream 401s mark accounts invalid
+func NewAuthFailureGuard(
 	accountSvc authinterfaces.AccountService,
-	logger sctx.Logger,
-) *proxyjobs.Scheduler {
-	return proxyjobs.NewScheduler(accountSvc, logger)
+	clock proxyinterfaces.ClockMonitor,
+	telegramClient *telegram.Client,
+	cfg *config.Config,
+	appLogger sctx.Logger,
+) proxyinterfaces.AuthFailureGuard {
+	logger := appLogger.Withs(sctx.Fields{"component": "auth-failure-guard"})
+	return proxyservices.NewAuthFailureGuard(
+		accountSvc, clock, telegramClient, cfg.Clock.AuthFailureWindow, cfg.Clock.AggressiveInvalidation, logger,
+	)
 }
 
-// StartTokenRefreshScheduler starts the token refresh scheduler with lifecycle management
-func StartTokenRefreshScheduler(
-	lc fx.Lifecycle,
-	scheduler *proxyjobs.Scheduler,
-	logger sctx.Logger,
-) error {
-	if err := scheduler.Start(); err != nil {
-		return err
+// NewWebhookDispatcher creates the usage event webhook dispatcher (no-op unless enabled)
+func NewWebhookDispatcher(cfg *config.Config, appLogger sctx.Logger) (proxyinterfaces.WebhookDispatcher, error) {
+	logger := appLogger.Withs(sctx.Fields{"component": "webhook-dispatcher"})
+	client := proxyclients.NewWebhookClient(cfg.Webhooks.URL, cfg.Webhooks.Secret, cfg.Webhooks.Timeout)
+	dispatcher, err := proxyservices.NewWebhookDispatcher(cfg.Webhooks, client, logger)
+	if err != nil {
+		return nil, fmt.Errorf("invalid webhooks config: %w", err)
 	}
-
-	lc.Append(fx.Hook{
-		OnStop: func(ctx context.Context) error {
-			logger.Info("Stopping token refresh scheduler")
-			scheduler.Stop()
-			return nil
-		},
-	})
-
-	return nil
+	return dispatcher, nil
 }
 
-// NewSessionCleanupScheduler creates a session cleanup scheduler
-func NewSessionCleanupScheduler(
-	sessionService authinterfaces.SessionService,
+// NewAlertEvaluator creates the evaluator of the alert rules (the defaults merged with alerts.rules; none when
+// alerts.disabled)
+func NewAlertEvaluator(
+	metrics proxyinterfaces.TrafficMetrics,
+	accountSvc authinterfaces.AccountService,
+	telegramClient *telegram.Client,
+	webhooks proxyinterfaces.WebhookDispatcher,
 	cfg *config.Config,
-	logger sctx.Logger,
-) *authjobs.SessionCleanupScheduler {
-	if !cfg.Session.Enabled || !cfg.Session.CleanupEnabled {
-		logger.Info("Session cleanup disabled")
-		return nil
+	appLogger sctx.Logger,
+) proxyinterfaces.AlertEvaluator {
+	logger := appLogger.Withs(sctx.Fields{"component": "alert-evaluator"})
+	var rules []config.AlertRuleConfig
+	if !cfg.Alerts.Disabled {
+		rules = cfg.Alerts.EffectiveRules()
+	}
+	return proxyservices.NewAlertEvaluator(rules, metrics, accountSvc, telegramClient, webhooks, logger)
+}
+
+// NewTrafficMetrics creates the in-memory proxied traffic counters
+func NewTrafficMetrics() proxyinterfaces.TrafficMetrics {
+	return proxyservices.NewTrafficMetrics()
+}
+
+// NewRequestHistory creates the in-memory ring of recent requests per account
+func NewRequestHistory(cfg *config.Config) proxyinterfaces.RequestHistory {
+	return proxyservices.NewRequestHistory(cfg.Proxy.RequestHistorySize)
+}
+
+// NewRequestQueue creates the queue of requests waiting for an account to recover (disabled without a max wait)
+func NewRequestQueue(cfg *config.Config) proxyinterfaces.RequestQueue {
+	return proxyservices.NewRequestQueue(cfg.Proxy.MaxQueueWait, cfg.Proxy.MaxQueueSize)
+}
+
+// NewCircuitBreaker creates the per-account latency/error circuit breaker (no-op unless enabled)
+func NewCircuitBreaker(cfg *config.Config, appLogger sctx.Logger) proxyinterfaces.CircuitBreaker {
+	logger := appLogger.Withs(sctx.Fields{"component": "circuit-breaker"})
+	return proxyservices.NewCircuitBreaker(cfg.Proxy.CircuitBreaker, logger)
+}
+
+// NewModelCatalog creates the GET /v1/models cache with config-defined aliases
+func NewModelCatalog(
+	repo proxyinterfaces.ModelCacheRepository,
+	cfg *config.Config,
+	appLogger sctx.Logger,
+) *proxyservices.ModelCatalog {
+	logger := appLogger.Withs(sctx.Fields{"component": "model-catalog"})
+	return proxyservices.NewModelCatalog(repo, cfg.Proxy.ModelAliases, logger)
+}
+
+// NewBatchService creates the message batch → account index (restored from batches.json)
+func NewBatchService(
+	repo proxyinterfaces.BatchRepository,
+	cfg *config.Config,
+	appLogger sctx.Logger,
+) proxyinterfaces.BatchService {
+	logger := appLogger.Withs(sctx.Fields{"component": "batch-service"})
+	return proxyservices.NewBatchService(repo, cfg.Proxy.BatchTTL, logger)
+}
+
+// NewFileService creates the uploaded file → account index (restored from files.json)
+func NewFileService(
+	repo proxyinterfaces.FileRepository,
+	cfg *config.Config,
+	appLogger sctx.Logger,
+) proxyinterfaces.FileService {
+	logger := appLogger.Withs(sctx.Fields{"component": "file-service"})
+	return proxyservices.NewFileService(repo, cfg.Proxy.FileTTL, logger)
+}
+
+// NewUpstreamUsageService creates the service keeping the official and locally counted daily account usage
+// (restored from upstream_usage.json)
+func NewUpstreamUsageService(
+	accountSvc authinterfaces.AccountService,
+	claudeClient *proxyclients.ClaudeAPIClient,
+	repo proxyinterfaces.UpstreamUsageRepository,
+	cfg *config.Config,
+	appLogger sctx.Logger,
+) proxyinterfaces.UpstreamUsage {
+	logger := appLogger.Withs(sctx.Fields{"component": "upstream-usage"})
+	return proxyservices.NewUpstreamUsageService(
+		accountSvc, claudeClient, repo, cfg.Accounts.UpstreamUsageDays, logger,
+	)
+}
+
+// NewMaintenanceService creates the maintenance mode service (state restored from maintenance.json)
+func NewMaintenanceService(
+	repo proxyinterfaces.MaintenanceRepository,
+	telegramClient *telegram.Client,
+	appLogger sctx.Logger,
+) proxyinterfaces.MaintenanceService {
+	logger := appLogger.Withs(sctx.Fields{"component": "maintenance-service"})
+	return proxyservices.NewMaintenanceService(repo, telegramClient, logger)
+}
+
+// NewAdminKeyService creates the admin key service (config api_key is the bootstrap fallback)
+func NewAdminKeyService(
+	repo authinterfaces.AdminKeyRepository,
+	cfg *config.Config,
+	appLogger sctx.Logger,
+) (authinterfaces.AdminKeyService, error) {
+	return authservices.NewAdminKeyService(repo, cfg.Auth.APIKey, cfg.Auth.KeyRotationGrace, appLogger)
+}
+
+// NewSessionHistoryService creates the recorder of new sessions for security reviews (session.history_enabled)
+func NewSessionHistoryService(
+	repo authinterfaces.SessionHistoryRepository,
+	cfg *config.Config,
+	appLogger sctx.Logger,
+) authinterfaces.SessionHistoryService {
+	logger := appLogger.Withs(sctx.Fields{"component": "session-history"})
+	return authservices.NewSessionHistoryService(repo, cfg.Session, logger)
+}
+
+// NewMutationAuditService creates the service recording admin changes of accounts and tokens
+func NewMutationAuditService(
+	repo authinterfaces.MutationAuditRepository,
+	appLogger sctx.Logger,
+) authinterfaces.MutationAuditService {
+	return authservices.NewMutationAuditService(repo, appLogger)
+}
+
+// NewInviteService creates the account invite service
+func NewInviteService(
+	repo authinterfaces.InviteRepository,
+	appLogger sctx.Logger,
+) (authinterfaces.InviteService, error) {
+	return authservices.NewInviteService(repo, appLogger)
+}
+
+// NewDeviceAuthService creates the device authorization service (OAuth flow for headless servers)
+func NewDeviceAuthService(
+	repo authinterfaces.DeviceAuthRepository,
+	accountSvc authinterfaces.AccountService,
+	oauthClient authinterfaces.OAuthClient,
+	appLogger sctx.Logger,
+) (authinterfaces.DeviceAuthService, error) {
+	return authservices.NewDeviceAuthService(repo, accountSvc, oauthClient, appLogger)
+}
+
+// NewBackupService creates a backup service over the JSON persistence repositories
+func NewBackupService(
+	accountSvc authinterfaces.AccountService,
+	tokenSvc authinterfaces.TokenService,
+	sessionSvc authinterfaces.SessionService,
+	statsSvc authinterfaces.StatisticsService,
+	accountRepo authinterfaces.PersistenceRepository,
+	tokenRepo authinterfaces.TokenPersistenceRepository,
+	sessionRepo authinterfaces.SessionPersistenceRepository,
+	statsRepo authinterfaces.UsageStatsPersistenceRepository,
+	adminKeyRepo authinterfaces.AdminKeyRepository,
+	inviteRepo authinterfaces.InviteRepository,
+	cfg *config.Config,
+	appLogger sctx.Logger,
+) authinterfaces.BackupService {
+	// Snapshot every file-backed repository (session repo is nil when session limiting is disabled)
+	var sources []authinterfaces.SnapshotSource
+	for _, repo := range []any{accountRepo, tokenRepo, sessionRepo, statsRepo, adminKeyRepo, inviteRepo} {
+		if source, ok := repo.(authinterfaces.SnapshotSource); ok {
+			sources = append(sources, source)
+		}
+	}
+
+	return authservices.NewBackupService(
+		accountSvc,
+		tokenSvc,
+		sessionSvc,
+		statsSvc,
+		sources,
+		authrepos.ExpandPath(cfg.Storage.BackupFolder),
+		cfg.Storage.BackupKeep,
+		appLogger,
+	)
+}
+
+// ============================================================================
+// Infrastructure - Clients
+// ============================================================================
+
+// NewClaudeAPIClient creates a new Claude API client
+func NewClaudeAPIClient(cfg *config.Config, appLogger sctx.Logger) *proxyclients.ClaudeAPIClient {
+	logger := appLogger.Withs(sctx.Fields{"component": "claude-api-client"})
+	return proxyclients.NewClaudeAPIClient(
+		cfg.Claude.BaseURL,
+		cfg.Claude.Timeout,
+		cfg.Claude.StreamTimeout,
+		cfg.Claude.Headers.Map(),
+		cfg.Logger.LogBodies,
+		cfg.Logger.BodySamplePercent,
+		logger,
+	)
+}
+
+// ============================================================================
+// Job Providers
+// ============================================================================
+
+// NewSyncScheduler creates a new sync scheduler for hybrid storage
+func NewSyncScheduler(
+	accountService authinterfaces.AccountService,
+	tokenService authinterfaces.TokenService,
+	sessionService authinterfaces.SessionService,
+	statsService authinterfaces.StatisticsService,
+	batchService proxyinterfaces.BatchService,
+	fileService proxyinterfaces.FileService,
+	upstreamUsage proxyinterfaces.UpstreamUsage,
+	cfg *config.Config,
+	appLogger sctx.Logger,
+) *authjobs.SyncScheduler {
+	// Default sync interval: 1 minute
+	syncInterval := 1 * time.Minute
+	if cfg.Storage.SyncInterval > 0 {
+		syncInterval = cfg.Storage.SyncInterval
+	}
+
+	return authjobs.NewSyncScheduler(
+		accountService,
+		tokenService,
+		sessionService,
+		statsService,
+		batchService,
+		fileService,
+		upstreamUsage,
+		syncInterval,
+		appLogger,
+	)
+}
+
+// StartSyncScheduler starts the sync scheduler with lifecycle management
+func StartSyncScheduler(
+	lc fx.Lifecycle,
+	scheduler *authjobs.SyncScheduler,
+	storageMonitor *storage.Monitor,
+	logger sctx.Logger,
+) error {
+	if syncDisabled(storageMonitor) {
+		logger.Warn("Sync scheduler disabled: the data folder is read-only (storage.allow_readonly)")
+		return nil
+	}
+
+	if err := scheduler.Start(); err != nil {
+		return err
+	}
+
+	lc.Append(fx.Hook{
+		OnStop: func(ctx context.Context) error {
+			logger.Info("Performing final sync before shutdown")
+			scheduler.Stop()
+			if err := scheduler.FinalSync(); err != nil {
+				logger.Withs(sctx.Fields{"error": err}).Error("Final sync failed")
+				return err
+			}
+			return nil
+		},
+	})
+
+	return nil
+}
+
+// syncDisabled returns true if the data folder is read-only and storage.allow_readonly accepts it, in which
+// case the sync job would only fail every interval
+func syncDisabled(storageMonitor *storage.Monitor) bool {
+	return storageMonitor.ReadOnly() && storageMonitor.AllowReadOnly()
+}
+
+// MigrateLegacyAccountFiles converts the account files of older releases (a single-account account.json, an
+// accounts.json keyed by organization UUID) into the current accounts.json format
+func MigrateLegacyAccountFiles(cfg *config.Config, appLogger sctx.Logger) error {
+	logger := appLogger.Withs(sctx.Fields{"component": "legacy-migration"})
+
+	result, err := authrepos.MigrateLegacyAccountFiles(cfg.Storage.DataFolder, cfg.Storage.Fsync, logger)
+	if err != nil {
+		return fmt.Errorf("failed to migrate legacy account files: %w", err)
+	}
+	if result.ConvertedMapAccounts > 0 {
+		logger.Withs(sctx.Fields{"accounts": result.ConvertedMapAccounts}).
+			Warn("Legacy accounts.json layout (keyed by organization UUID) rewritten in the current format")
+	}
+	if result.ImportedAccountFile {
+		logger.Warn("Legacy account.json imported into accounts.json and renamed to account.json.migrated")
+	}
+	if result.SkippedAccountFile {
+		logger.Warn("Legacy account.json ignored because accounts.json already holds accounts; remove it once checked")
+	}
+	return nil
+}
+
+// MigrateLegacyAccountIDs replaces account IDs written by older releases (not UUIDs) with UUIDv7 ones,
+// recording them in id_migration.json, and rewrites the invites, batches and files still referencing them
+func MigrateLegacyAccountIDs(
+	cfg *config.Config,
+	inviteRepo authinterfaces.InviteRepository,
+	batchRepo proxyinterfaces.BatchRepository,
+	fileRepo proxyinterfaces.FileRepository,
+	appLogger sctx.Logger,
+) error {
+	logger := appLogger.Withs(sctx.Fields{"component": "id-migration"})
+
+	migrated, mapping, err := authrepos.MigrateLegacyAccountIDs(cfg.Storage.DataFolder, cfg.Storage.Fsync, logger)
+	if err != nil {
+		return fmt.Errorf("failed to migrate legacy account IDs: %w", err)
+	}
+	if migrated > 0 {
+		logger.Withs(sctx.Fields{"accounts": migrated}).Warn("Legacy account IDs replaced with UUIDv7 (see id_migration.json)")
+	}
+	if len(mapping) == 0 {
+		return nil
+	}
+
+	ctx := context.Background()
+	invites, err := inviteRepo.LoadAll(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load invites: %w", err)
+	}
+	if rewriteAccountIDs(mapping, invites, func(i *authentities.Invite) *string { return &i.AccountID }) > 0 {
+		if err := inviteRepo.SaveAll(ctx, invites); err != nil {
+			return fmt.Errorf("failed to save invites: %w", err)
+		}
+	}
+
+	batches, err := batchRepo.LoadAll(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load batches: %w", err)
+	}
+	if rewriteAccountIDs(mapping, batches, func(b *proxyentities.Batch) *string { return &b.AccountID }) > 0 {
+		if err := batchRepo.SaveAll(ctx, batches); err != nil {
+			return fmt.Errorf("failed to save batches: %w", err)
+		}
+	}
+
+	files, err := fileRepo.LoadAll(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load files: %w", err)
+	}
+	if rewriteAccountIDs(mapping, files, func(f *proxyentities.File) *string { return &f.AccountID }) > 0 {
+		if err := fileRepo.SaveAll(ctx, files); err != nil {
+			return fmt.Errorf("failed to save files: %w", err)
+		}
+	}
+	return nil
+}
+
+// rewriteAccountIDs replaces the legacy account IDs (mapping keys) of items with their UUIDv7 and returns how
+// many it changed
+func rewriteAccountIDs[T any](mapping map[string]string, items []T, accountID func(T) *string) int {
+	changed := 0
+	for _, item := range items {
+		id := accountID(item)
+		if newID, ok := mapping[*id]; ok {
+			*id = newID
+			changed++
+		}
+	}
+	return changed
+}
+
+// RegisterReadinessChecks registers the dependency checks behind GET /health/ready
+func RegisterReadinessChecks(
+	checker *health.Checker,
+	accountSvc authinterfaces.AccountService,
+	syncScheduler *authjobs.SyncScheduler,
+	clock proxyinterfaces.ClockMonitor,
+	storageMonitor *storage.Monitor,
+	standby authinterfaces.StandbyService,
+) {
+	checker.Register("accounts", func(ctx context.Context) error {
+		accounts, err := accountSvc.ListAccounts(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to list accounts: %w", err)
+		}
+		for _, account := range accounts {
+			if account.IsUsableNow() && !account.Shadow {
+				return nil
+			}
+		}
+		return fmt.Errorf("no account available for proxy (%d accounts)", len(accounts))
+	})
+	checker.Register("storage", storageMonitor.CheckHealth)
+	// Nothing is synced on an accepted read-only data folder (storage.allow_readonly)
+	if !syncDisabled(storageMonitor) {
+		checker.Register("sync", syncScheduler.CheckHealth)
+	}
+	checker.Register("clock", clock.CheckHealth)
+	// A standby is not ready until promoted, so load balancers keep sending traffic to the primary
+	if status := standby.Status(); status.Enabled {
+		checker.Register("standby", func(ctx context.Context) error {
+			if standby.Standby() {
+				return fmt.Errorf("standby of %s, not serving until promoted", status.PrimaryURL)
+			}
+			return nil
+		})
+	}
+}
+
+// NewStandbyService creates the warm standby pulling the primary's state (inactive unless standby.enabled)
+func NewStandbyService(
+	cfg *config.Config,
+	accountSvc authinterfaces.AccountService,
+	tokenSvc authinterfaces.TokenService,
+	sessionSvc authinterfaces.SessionService,
+	appLogger sctx.Logger,
+) authinterfaces.StandbyService {
+	return authservices.NewStandbyService(cfg.Standby, accountSvc, tokenSvc, sessionSvc, appLogger)
+}
+
+// StartStandby starts pulling the primary's state with lifecycle management (a no-op unless standby.enabled)
+func StartStandby(lc fx.Lifecycle, standby authinterfaces.StandbyService, logger sctx.Logger) {
+	standby.Start()
+
+	lc.Append(fx.Hook{
+		OnStop: func(ctx context.Context) error {
+			if standby.Standby() {
+				logger.Info("Stopping standby pulls")
+			}
+			standby.Stop()
+			return nil
+		},
+	})
+}
+
+// startWhenActive starts a job now, or once promoted while the instance is a warm standby (standby.enabled)
+// On promotion the instance is already serving, so a job failing to start then is only logged.
+func startWhenActive(
+	standby authinterfaces.StandbyService,
+	job string,
+	start func() error,
+	logger sctx.Logger,
+) error {
+	if !standby.Standby() {
+		return start()
+	}
+
+	logger.Withs(sctx.Fields{"job": job}).Info("Job deferred until the standby is promoted")
+	standby.OnPromote(func() {
+		if err := start(); err != nil {
+			logger.Withs(sctx.Fields{"job": job, "error": err.Error()}).Error("Failed to start job on promotion")
+		}
+	})
+	return nil
+}
+
+// NewTokenRefreshScheduler creates a new token refresh scheduler
+func NewTokenRefreshScheduler(
+	accountSvc authinterfaces.AccountService,
+	telegramClient *telegram.Client,
+	cfg *config.Config,
+	logger sctx.Logger,
+) *proxyjobs.Scheduler {
+	options := proxyjobs.RefreshOptions{
+		MaxConcurrent: cfg.Refresh.MaxConcurrent,
+		Spread:        cfg.Refresh.Spread,
+		Jitter:        cfg.Refresh.Jitter,
+		AlertFailures: cfg.Refresh.AlertFailures,
+
+		AlertUnavailable: cfg.Accounts.AvailabilityAlert,
+	}
+	return proxyjobs.NewScheduler(accountSvc, telegramClient, options, logger)
+}
+
+// StartTokenRefreshScheduler starts the token refresh scheduler with lifecycle management
+// On a warm standby it starts once promoted: refreshing rotates the refresh tokens the primary still uses
+func StartTokenRefreshScheduler(
+	lc fx.Lifecycle,
+	scheduler *proxyjobs.Scheduler,
+	standby authinterfaces.StandbyService,
+	logger sctx.Logger,
+) error {
+	if err := startWhenActive(standby, "token-refresh", scheduler.Start, logger); err != nil {
+		return err
+	}
+
+	lc.Append(fx.Hook{
+		OnStop: func(ctx context.Context) error {
+			logger.Info("Stopping token refresh scheduler")
+			scheduler.Stop()
+			return nil
+		},
+	})
+
+	return nil
+}
+
+// NewSessionCleanupScheduler creates a session cleanup scheduler
+func NewSessionCleanupScheduler(
+	sessionService authinterfaces.SessionService,
+	cfg *config.Config,
+	logger sctx.Logger,
+) *authjobs.SessionCleanupScheduler {
+	if !cfg.Session.Enabled || !cfg.Session.CleanupEnabled {
+		logger.Info("Session cleanup disabled")
+		return nil
 	}
 
 	return authjobs.NewSessionCleanupScheduler(sessionService, cfg, logger)
@@ -547,6 +1447,254 @@ func StartSessionCleanupScheduler(
 	return nil
 }
 
+// NewBackupScheduler creates a backup scheduler
+func NewBackupScheduler(
+	backupService authinterfaces.BackupService,
+	cfg *config.Config,
+	logger sctx.Logger,
+) *authjobs.BackupScheduler {
+	if !cfg.Storage.BackupEnabled {
+		logger.Info("Scheduled backups disabled")
+		return nil
+	}
+
+	return authjobs.NewBackupScheduler(backupService, cfg.Storage.BackupInterval, logger)
+}
+
+// StartBackupScheduler starts the backup scheduler with lifecycle management
+// On a warm standby it starts once promoted: the primary backs up the data the standby mirrors
+func StartBackupScheduler(
+	lc fx.Lifecycle,
+	scheduler *authjobs.BackupScheduler,
+	standby authinterfaces.StandbyService,
+	cfg *config.Config,
+	logger sctx.Logger,
+) error {
+	if !cfg.Storage.BackupEnabled || scheduler == nil {
+		logger.Info("Backup scheduler not started (disabled or scheduler is nil)")
+		return nil
+	}
+
+	if err := startWhenActive(standby, "backup", scheduler.Start, logger); err != nil {
+		return err
+	}
+
+	lc.Append(fx.Hook{
+		OnStop: func(ctx context.Context) error {
+			logger.Info("Stopping backup scheduler")
+			scheduler.Stop()
+			return nil
+		},
+	})
+
+	return nil
+}
+
+// StartSessionHistory starts the session history writer with lifecycle management
+func StartSessionHistory(lc fx.Lifecycle, history authinterfaces.SessionHistoryService, logger sctx.Logger) {
+	history.Start()
+
+	lc.Append(fx.Hook{
+		OnStop: func(ctx context.Context) error {
+			logger.Info("Stopping session history writer")
+			history.Stop()
+			return nil
+		},
+	})
+}
+
+// StartSessionToucher starts recording session activity in batches with lifecycle management
+func StartSessionToucher(lc fx.Lifecycle, toucher proxyinterfaces.SessionToucher) {
+	toucher.Start()
+
+	lc.Append(fx.Hook{
+		OnStop: func(ctx context.Context) error {
+			toucher.Stop()
+			return nil
+		},
+	})
+}
+
+// StartWebhookDispatcher starts the webhook delivery workers with lifecycle management
+// On a warm standby they start once promoted, so the primary's events are not delivered twice
+func StartWebhookDispatcher(
+	lc fx.Lifecycle,
+	dispatcher proxyinterfaces.WebhookDispatcher,
+	standby authinterfaces.StandbyService,
+	logger sctx.Logger,
+) error {
+	start := func() error {
+		dispatcher.Start()
+		return nil
+	}
+	if err := startWhenActive(standby, "webhooks", start, logger); err != nil {
+		return err
+	}
+
+	lc.Append(fx.Hook{
+		OnStop: func(ctx context.Context) error {
+			logger.Info("Stopping webhook dispatcher")
+			dispatcher.Stop()
+			return nil
+		},
+	})
+
+	return nil
+}
+
+// NewWarmupScheduler creates the prompt cache warmup scheduler (nil when warmup is disabled)
+func NewWarmupScheduler(
+	accountSvc authinterfaces.AccountService,
+	claudeClient *proxyclients.ClaudeAPIClient,
+	cfg *config.Config,
+	logger sctx.Logger,
+) (*proxyjobs.WarmupScheduler, error) {
+	warmup := cfg.Proxy.Warmup
+	if !warmup.Enabled {
+		return nil, nil
+	}
+
+	prompt, err := os.ReadFile(authrepos.ExpandPath(warmup.PromptFile))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read proxy.warmup.prompt_file: %w", err)
+	}
+	if len(bytes.TrimSpace(prompt)) == 0 {
+		return nil, fmt.Errorf("proxy.warmup.prompt_file %s is empty", warmup.PromptFile)
+	}
+
+	options := proxyjobs.WarmupOptions{
+		Prompt:   string(prompt),
+		Model:    warmup.Model,
+		Interval: warmup.Interval,
+	}
+	return proxyjobs.NewWarmupScheduler(accountSvc, claudeClient, options, logger)
+}
+
+// StartWarmupScheduler starts the prompt cache warmup scheduler with lifecycle management
+// On a warm standby it starts once promoted
+func StartWarmupScheduler(
+	lc fx.Lifecycle,
+	scheduler *proxyjobs.WarmupScheduler,
+	standby authinterfaces.StandbyService,
+	logger sctx.Logger,
+) error {
+	if scheduler == nil {
+		return nil
+	}
+
+	if err := startWhenActive(standby, "warmup", scheduler.Start, logger); err != nil {
+		return err
+	}
+
+	lc.Append(fx.Hook{
+		OnStop: func(ctx context.Context) error {
+			logger.Info("Stopping warmup scheduler")
+			scheduler.Stop()
+			return nil
+		},
+	})
+
+	return nil
+}
+
+// NewClockCheckScheduler creates the clock skew check scheduler
+func NewClockCheckScheduler(
+	claudeClient *proxyclients.ClaudeAPIClient,
+	clock proxyinterfaces.ClockMonitor,
+	cfg *config.Config,
+	logger sctx.Logger,
+) *proxyjobs.ClockCheckScheduler {
+	return proxyjobs.NewClockCheckScheduler(
+		claudeClient, clock, cfg.Clock.CheckInterval, cfg.Clock.StartupCheck, logger,
+	)
+}
+
+// StartClockCheckScheduler runs the startup clock check and starts the periodic ones with lifecycle management
+// Under clock.startup_check: block, an excessive skew aborts startup
+func StartClockCheckScheduler(
+	lc fx.Lifecycle,
+	scheduler *proxyjobs.ClockCheckScheduler,
+	logger sctx.Logger,
+) error {
+	if err := scheduler.Start(context.Background()); err != nil {
+		return err
+	}
+
+	lc.Append(fx.Hook{
+		OnStop: func(ctx context.Context) error {
+			logger.Info("Stopping clock check scheduler")
+			scheduler.Stop()
+			return nil
+		},
+	})
+
+	return nil
+}
+
+// NewAlertScheduler creates the scheduler evaluating the alert rules every alerts.interval
+func NewAlertScheduler(
+	evaluator proxyinterfaces.AlertEvaluator,
+	cfg *config.Config,
+	logger sctx.Logger,
+) *proxyjobs.AlertScheduler {
+	return proxyjobs.NewAlertScheduler(evaluator, cfg.Alerts.Interval, logger)
+}
+
+// StartAlertScheduler starts the alert evaluation scheduler with lifecycle management
+// On a warm standby it starts once promoted, so the primary's alerts are not sent twice
+func StartAlertScheduler(
+	lc fx.Lifecycle,
+	scheduler *proxyjobs.AlertScheduler,
+	standby authinterfaces.StandbyService,
+	logger sctx.Logger,
+) error {
+	if err := startWhenActive(standby, "alerts", scheduler.Start, logger); err != nil {
+		return err
+	}
+
+	lc.Append(fx.Hook{
+		OnStop: func(ctx context.Context) error {
+			logger.Info("Stopping alert scheduler")
+			scheduler.Stop()
+			return nil
+		},
+	})
+
+	return nil
+}
+
+// NewUpstreamUsageScheduler creates the scheduler collecting the usage reports of admin-capable accounts
+func NewUpstreamUsageScheduler(
+	upstreamUsage proxyinterfaces.UpstreamUsage,
+	cfg *config.Config,
+	logger sctx.Logger,
+) *proxyjobs.UpstreamUsageScheduler {
+	return proxyjobs.NewUpstreamUsageScheduler(upstreamUsage, cfg.Accounts.UpstreamUsageInterval, logger)
+}
+
+// StartUpstreamUsageScheduler starts the upstream usage collection scheduler with lifecycle management
+// On a warm standby it starts once promoted
+func StartUpstreamUsageScheduler(
+	lc fx.Lifecycle,
+	scheduler *proxyjobs.UpstreamUsageScheduler,
+	standby authinterfaces.StandbyService,
+	logger sctx.Logger,
+) error {
+	if err := startWhenActive(standby, "upstream-usage", scheduler.Start, logger); err != nil {
+		return err
+	}
+
+	lc.Append(fx.Hook{
+		OnStop: func(ctx context.Context) error {
+			logger.Info("Stopping upstream usage scheduler")
+			scheduler.Stop()
+			return nil
+		},
+	})
+
+	return nil
+}
+
 // ============================================================================
 // Handler Providers
 // ============================================================================
@@ -562,39 +1710,232 @@ func NewProxyHandler(proxyService proxyinterfaces.ProxyService) *handlers.ProxyH
 }
 
 // NewAuthHandler creates a new auth handler
-func NewAuthHandler(tokenService authinterfaces.TokenService, cfg *config.Config) *handlers.AuthHandler {
-	return handlers.NewAuthHandler(tokenService, cfg)
+func NewAuthHandler(
+	tokenService authinterfaces.TokenService,
+	adminKeyService authinterfaces.AdminKeyService,
+) *handlers.AuthHandler {
+	return handlers.NewAuthHandler(tokenService, adminKeyService)
 }
 
 // NewAccountHandler creates a new account handler
 func NewAccountHandler(
 	accountService authinterfaces.AccountService,
+	breaker proxyinterfaces.CircuitBreaker,
+	history proxyinterfaces.RequestHistory,
+	failures proxyinterfaces.AccountFailureTracker,
+	upstreamUsage proxyinterfaces.UpstreamUsage,
+	cfg *config.Config,
 ) *handlers.AccountHandler {
-	return handlers.NewAccountHandler(accountService)
+	return handlers.NewAccountHandler(
+		accountService, breaker, history, failures, upstreamUsage, cfg.Claude.BaseURL, cfg.Accounts.UpstreamUsageDays,
+	)
 }
 
 // NewOAuthHandler creates a new OAuth handler
 func NewOAuthHandler(
 	oauthClient authinterfaces.OAuthClient,
 	accountSvc authinterfaces.AccountService,
+	deviceSvc authinterfaces.DeviceAuthService,
 	cfg *config.Config,
 ) *handlers.OAuthHandler {
-	return handlers.NewOAuthHandler(oauthClient, accountSvc, cfg.Claude.BaseURL)
+	return handlers.NewOAuthHandler(oauthClient, accountSvc, deviceSvc, cfg.Claude.BaseURL)
+}
+
+// NewInviteHandler creates a new account invite handler
+func NewInviteHandler(
+	inviteSvc authinterfaces.InviteService,
+	accountSvc authinterfaces.AccountService,
+	deviceSvc authinterfaces.DeviceAuthService,
+	oauthClient authinterfaces.OAuthClient,
+	cfg *config.Config,
+) *handlers.InviteHandler {
+	return handlers.NewInviteHandler(
+		inviteSvc, accountSvc, deviceSvc, oauthClient, cfg.OAuth.InviteTTL, cfg.Server.BasePath,
+	)
+}
+
+// NewDeviceAuthHandler creates a new device authorization handler
+func NewDeviceAuthHandler(
+	deviceSvc authinterfaces.DeviceAuthService,
+	accountSvc authinterfaces.AccountService,
+	cfg *config.Config,
+) *handlers.DeviceAuthHandler {
+	return handlers.NewDeviceAuthHandler(deviceSvc, accountSvc, cfg.OAuth.DeviceTTL, cfg.Server.BasePath)
 }
 
 // NewStatisticsHandler creates a new statistics handler
 func NewStatisticsHandler(
 	accountService authinterfaces.AccountService,
+	tokenService authinterfaces.TokenService,
+	statsService authinterfaces.StatisticsService,
+	sessionService authinterfaces.SessionService,
+	breaker proxyinterfaces.CircuitBreaker,
+	metrics proxyinterfaces.TrafficMetrics,
+	queue proxyinterfaces.RequestQueue,
+	history proxyinterfaces.RequestHistory,
+	clock proxyinterfaces.ClockMonitor,
+	authGuard proxyinterfaces.AuthFailureGuard,
+	shadow proxyinterfaces.ShadowMirror,
+	upstreamUsage proxyinterfaces.UpstreamUsage,
+	canary proxyinterfaces.CanaryRouter,
+	tiers proxyinterfaces.PriorityTierTracker,
+	caches authinterfaces.CacheMonitor,
+	storageMonitor *storage.Monitor,
+	cfg *config.Config,
 	appLogger sctx.Logger,
 ) *handlers.StatisticsHandler {
 	logger := appLogger.Withs(sctx.Fields{"component": "statistics-handler"})
-	return handlers.NewStatisticsHandler(accountService, logger)
+	return handlers.NewStatisticsHandler(
+		accountService, tokenService, statsService, sessionService, breaker, metrics, queue, history, clock,
+		authGuard, shadow, upstreamUsage, cfg.Accounts.UpstreamUsageDays, canary, tiers, caches, storageMonitor,
+		logger,
+	)
+}
+
+// NewMetricsHandler creates the Prometheus metrics handler
+func NewMetricsHandler(caches authinterfaces.CacheMonitor) *handlers.MetricsHandler {
+	return handlers.NewMetricsHandler(caches)
 }
 
 // NewSessionHandler creates a new session handler
 func NewSessionHandler(
 	sessionService authinterfaces.SessionService,
+	history authinterfaces.SessionHistoryService,
 	appLogger sctx.Logger,
 ) *handlers.SessionHandler {
-	return handlers.NewSessionHandler(sessionService, appLogger)
+	return handlers.NewSessionHandler(sessionService, history, appLogger)
+}
+
+// NewMeHandler creates the self-service handler for token holders
+func NewMeHandler(
+	tokenService authinterfaces.TokenService,
+	sessionService authinterfaces.SessionService,
+	cfg *config.Config,
+	appLogger sctx.Logger,
+) *handlers.MeHandler {
+	return handlers.NewMeHandler(tokenService, sessionService, cfg.Session, appLogger)
+}
+
+// NewBackupHandler creates a new backup handler
+func NewBackupHandler(backupService authinterfaces.BackupService) *handlers.BackupHandler {
+	return handlers.NewBackupHandler(backupService)
+}
+
+// NewStorageHandler creates a new storage handler reloading data files through the sync scheduler
+func NewStorageHandler(syncScheduler *authjobs.SyncScheduler, appLogger sctx.Logger) *handlers.StorageHandler {
+	return handlers.NewStorageHandler(syncScheduler, appLogger)
+}
+
+// NewAdminKeyHandler creates a new admin key handler
+func NewAdminKeyHandler(adminKeyService authinterfaces.AdminKeyService) *handlers.AdminKeyHandler {
+	return handlers.NewAdminKeyHandler(adminKeyService)
+}
+
+// NewMaintenanceHandler creates a new maintenance mode handler
+func NewMaintenanceHandler(maintenanceService proxyinterfaces.MaintenanceService) *handlers.MaintenanceHandler {
+	return handlers.NewMaintenanceHandler(maintenanceService)
+}
+
+// NewAlertHandler creates a new alert handler
+func NewAlertHandler(evaluator proxyinterfaces.AlertEvaluator) *handlers.AlertHandler {
+	return handlers.NewAlertHandler(evaluator)
+}
+
+// NewAuditHandler creates a new audit handler
+func NewAuditHandler(auditService authinterfaces.MutationAuditService) *handlers.AuditHandler {
+	return handlers.NewAuditHandler(auditService)
+}
+
+// NewWebhookHandler creates a new webhook handler
+func NewWebhookHandler(dispatcher proxyinterfaces.WebhookDispatcher) *handlers.WebhookHandler {
+	return handlers.NewWebhookHandler(dispatcher)
+}
+
+// NewBatchHandler creates a new message batch handler
+func NewBatchHandler(
+	batchService proxyinterfaces.BatchService,
+	accountService authinterfaces.AccountService,
+) *handlers.BatchHandler {
+	return handlers.NewBatchHandler(batchService, accountService)
+}
+
+// NewFileHandler creates a new uploaded file handler
+func NewFileHandler(
+	fileService proxyinterfaces.FileService,
+	accountService authinterfaces.AccountService,
+) *handlers.FileHandler {
+	return handlers.NewFileHandler(fileService, accountService)
+}
+
+// NewReplayHandler creates the handler for captured failed requests and their replay
+func NewReplayHandler(
+	replayService proxyinterfaces.ReplayService,
+	captures proxyinterfaces.FailureCapture,
+	tokenService authinterfaces.TokenService,
+	appLogger sctx.Logger,
+) *handlers.ReplayHandler {
+	logger := appLogger.Withs(sctx.Fields{"component": "replay-handler"})
+	return handlers.NewReplayHandler(replayService, captures, tokenService, logger)
+}
+
+// NewConfigHandler creates a new effective configuration handler
+func NewConfigHandler(cfg *config.Config) *handlers.ConfigHandler {
+	dataFolder := authrepos.ExpandPath(cfg.Storage.DataFolder)
+	if abs, err := filepath.Abs(dataFolder); err == nil {
+		dataFolder = abs
+	}
+	_, err := fs.Stat(FrontendFS, "frontend/dist/index.html")
+	return handlers.NewConfigHandler(cfg, dataFolder, err == nil)
+}
+
+// NewLogLevelHandler creates a new runtime log level handler
+func NewLogLevelHandler(registry *logging.Registry) *handlers.LogLevelHandler {
+	return handlers.NewLogLevelHandler(registry)
+}
+
+// NewStandbyHandler creates a new warm standby handler
+func NewStandbyHandler(standbyService authinterfaces.StandbyService) *handlers.StandbyHandler {
+	return handlers.NewStandbyHandler(standbyService)
+}
+
+// NewHealthHandler creates the liveness and readiness probe handler
+func NewHealthHandler(
+	checker *health.Checker,
+	clock proxyinterfaces.ClockMonitor,
+	storageMonitor *storage.Monitor,
+	standby authinterfaces.StandbyService,
+) *handlers.HealthHandler {
+	return handlers.NewHealthHandler(checker, clock, storageMonitor, standby)
+}
+
+// NewHealthChecker creates the readiness check registry (checks are added by RegisterReadinessChecks)
+func NewHealthChecker() *health.Checker {
+	return health.NewChecker()
+}
+
+// NewStorageMonitor probes whether the data folder is writable, logging a banner when it is not
+func NewStorageMonitor(cfg *config.Config, appLogger sctx.Logger) *storage.Monitor {
+	logger := appLogger.Withs(sctx.Fields{"component": "storage-monitor"})
+	dataFolder := authrepos.ExpandPath(cfg.Storage.DataFolder)
+
+	// A missing folder is created like the repositories do, so it isn't mistaken for a read-only one
+	_ = os.MkdirAll(dataFolder, 0o700)
+	monitor := storage.NewMonitor(dataFolder, cfg.Storage.AllowReadOnly)
+
+	status := monitor.Status()
+	if !status.ReadOnly {
+		return monitor
+	}
+	fields := sctx.Fields{"data_folder": dataFolder, "error": status.Error}
+	if status.AllowReadOnly {
+		logger.Withs(fields).Warn(
+			"READ-ONLY STORAGE: running in memory only (storage.allow_readonly), every change is lost on restart",
+		)
+		return monitor
+	}
+	logger.Withs(fields).Error(
+		"Data folder is read-only: account, token and OAuth changes are refused with 503 until it is writable " +
+			"(set storage.allow_readonly: true to run in memory only)",
+	)
+	return monitor
 }
@@ -0,0 +1,63 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"testing"
+)
+
+func TestIntegrationForwardedContentLengthMatchesBody(t *testing.T) {
+	tests := []struct {
+		name          string
+		body          string
+		wantMaxTokens int
+	}{
+		{
+			name: "thinking fixer fires",
+			body: `{"model":"claude-sonnet-4","max_tokens":100,"thinking":{"type":"enabled","budget_tokens":2048},` +
+				`"messages":[{"role":"user","content":"hi"}]}`,
+			wantMaxTokens: 3072,
+		},
+		{
+			name:          "body forwarded as sent",
+			body:          `{"model":"claude-sonnet-4","max_tokens":100,"messages":[{"role":"user","content":"hi"}]}`,
+			wantMaxTokens: 100,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stack := newTestStack(t, answerMessage, nil)
+
+			resp := stack.do(t, http.MethodPost, "/v1/messages", tt.body, nil)
+			if resp.StatusCode != http.StatusOK {
+				body, _ := io.ReadAll(resp.Body)
+				t.Fatalf("status = %d (%s), want 200", resp.StatusCode, body)
+			}
+
+			requests := stack.upstream.Requests()
+			if len(requests) != 1 {
+				t.Fatalf("upstream received %d requests, want 1", len(requests))
+			}
+			received := requests[0]
+			if got, want := received.Header.Get("Content-Length"), strconv.Itoa(len(received.Body)); got != want {
+				t.Errorf("Content-Length = %q, want %s (the length of the body received)", got, want)
+			}
+			if received.ContentLength != int64(len(received.Body)) {
+				t.Errorf("request length = %d, want %d", received.ContentLength, len(received.Body))
+			}
+
+			var sent struct {
+				MaxTokens int `json:"max_tokens"`
+			}
+			if err := json.Unmarshal(received.Body, &sent); err != nil {
+				t.Fatalf("upstream received invalid JSON %q: %v", received.Body, err)
+			}
+			if sent.MaxTokens != tt.wantMaxTokens {
+				t.Errorf("max_tokens = %d, want %d", sent.MaxTokens, tt.wantMaxTokens)
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"claude-proxy/config"
+)
+
+// TestIntegrationRateLimitIgnoresSpoofedForwardedFor sends key validations from one connection address with a
+// new X-Forwarded-For each time: without trusted proxies they all share the connection's bucket
+func TestIntegrationRateLimitIgnoresSpoofedForwardedFor(t *testing.T) {
+	tests := []struct {
+		name           string
+		trustedProxies []string
+		wantLimited    bool
+	}{
+		{"no trusted proxies", nil, true},
+		{"from a trusted proxy", []string{"127.0.0.1"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stack := newTestStack(t, answerMessage, func(cfg *config.Config) {
+				cfg.Server.TrustedProxies = tt.trustedProxies
+				cfg.Server.PublicRateLimit.Burst = 3
+			})
+
+			limited := false
+			for i := range 5 {
+				header := http.Header{"X-Forwarded-For": {fmt.Sprintf("203.0.113.%d", i+1)}}
+				resp := stack.do(t, http.MethodPost, "/api/auth/validate", `{"api_key":"`+testAdminKey+`"}`, header)
+				switch resp.StatusCode {
+				case http.StatusOK:
+				case http.StatusTooManyRequests:
+					limited = true
+				default:
+					t.Fatalf("request %d: status = %d, want 200 or 429", i+1, resp.StatusCode)
+				}
+			}
+			if limited != tt.wantLimited {
+				t.Errorf("rate limited = %v, want %v", limited, tt.wantLimited)
+			}
+		})
+	}
+}
+
+// TestIntegrationValidateCountsTowardLoginLockout guesses keys on /api/auth/validate, which answers 200 with
+// "valid": false: the guesses still lock the IP out for login_lockout, even of the right key
+func TestIntegrationValidateCountsTowardLoginLockout(t *testing.T) {
+	stack := newTestStack(t, answerMessage, func(cfg *config.Config) {
+		cfg.Server.PublicRateLimit.Burst = 3
+		cfg.Server.PublicRateLimit.LoginLockout = time.Minute
+	})
+
+	for i := range 3 {
+		resp := stack.do(t, http.MethodPost, "/api/auth/validate", fmt.Sprintf(`{"api_key":"guess-%d"}`, i), nil)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("guess %d: status = %d, want 200", i+1, resp.StatusCode)
+		}
+	}
+	for _, key := range []string{"guess-3", testAdminKey} {
+		resp := stack.do(t, http.MethodPost, "/api/auth/validate", `{"api_key":"`+key+`"}`, nil)
+		if resp.StatusCode != http.StatusTooManyRequests || resp.Header.Get("Retry-After") != "60" {
+			t.Errorf("%s after the guesses: status = %d, Retry-After = %q; want 429, 60", key,
+				resp.StatusCode, resp.Header.Get("Retry-After"))
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package api
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"claude-proxy/config"
+	"claude-proxy/pkg/requestid"
+)
+
+// upstreamRateLimitHeaders are headers Claude API sends with every message response
+var upstreamRateLimitHeaders = http.Header{
+	"Request-Id":                              {"req_011CTest"},
+	"Anthropic-Ratelimit-Requests-Limit":      {"50"},
+	"Anthropic-Ratelimit-Requests-Remaining":  {"49"},
+	"Anthropic-Ratelimit-Requests-Reset":      {"2026-10-17T12:00:01Z"},
+	"Anthropic-Ratelimit-Input-Tokens-Limit":  {"40000"},
+	"Anthropic-Ratelimit-Input-Tokens-Reset":  {"2026-10-17T12:00:01Z"},
+	"Anthropic-Ratelimit-Output-Tokens-Limit": {"8000"},
+	"Anthropic-Ratelimit-Unified-Status":      {"allowed"},
+}
+
+// answerWithHeaders answers like answerMessage with upstreamRateLimitHeaders, or with a rate limit error
+// (and a Retry-After) when the request path asks for it with ?limited
+func answerWithHeaders(w http.ResponseWriter, r *http.Request) {
+	for name, values := range upstreamRateLimitHeaders {
+		w.Header()[name] = values
+	}
+	if !r.URL.Query().Has("limited") {
+		answerMessage(w, r)
+		return
+	}
+	w.Header().Set("Anthropic-Ratelimit-Requests-Remaining", "0")
+	w.Header().Set("Retry-After", "17")
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusTooManyRequests)
+	_, _ = io.WriteString(w, `{"type":"error","error":{"type":"rate_limit_error","message":"Number of requests has `+
+		`exceeded your rate limit"},"request_id":"req_011CTest"}`)
+}
+
+func TestIntegrationRequestIDAndRateLimitHeaders(t *testing.T) {
+	const message = `{"model":"claude-sonnet-4","max_tokens":10,"messages":[{"role":"user","content":"hi"}]}`
+	stack := newTestStack(t, answerWithHeaders, nil)
+
+	tests := []struct {
+		name       string
+		path       string
+		clientID   string // X-Request-Id sent by the client
+		wantStatus int
+		wantRetry  string // Retry-After relayed to the client
+	}{
+		{"success", "/v1/messages", "client-req.1:a_b", http.StatusOK, ""},
+		{"success without request ID", "/v1/messages", "", http.StatusOK, ""},
+		{"success with an invalid request ID", "/v1/messages", "not valid!", http.StatusOK, ""},
+		{"upstream 429", "/v1/messages?limited", "client-req-2", http.StatusTooManyRequests, "17"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			if tt.clientID != "" {
+				header.Set(requestid.Header, tt.clientID)
+			}
+			resp := stack.do(t, http.MethodPost, tt.path, message, header)
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+
+			// The client's valid ID is adopted, any other replaced with a generated one
+			id := resp.Header.Get(requestid.Header)
+			switch {
+			case requestid.Valid(tt.clientID) && id != tt.clientID:
+				t.Errorf("%s = %q, want the client's %q", requestid.Header, id, tt.clientID)
+			case !requestid.Valid(tt.clientID) && (!requestid.Valid(id) || id == tt.clientID):
+				t.Errorf("%s = %q, want a generated request ID", requestid.Header, id)
+			}
+
+			for name, values := range upstreamRateLimitHeaders {
+				want := values[0]
+				if name == "Anthropic-Ratelimit-Requests-Remaining" && tt.wantStatus == http.StatusTooManyRequests {
+					want = "0"
+				}
+				if got := resp.Header.Get(name); got != want {
+					t.Errorf("%s = %q, want %q relayed from upstream", name, got, want)
+				}
+			}
+			if got := resp.Header.Get("Retry-After"); got != tt.wantRetry {
+				t.Errorf("Retry-After = %q, want %q", got, tt.wantRetry)
+			}
+		})
+	}
+}
+
+func TestIntegrationLocalRateLimitHeaders(t *testing.T) {
+	const message = `{"model":"claude-sonnet-4","max_tokens":10,"messages":[{"role":"user","content":"hi"}]}`
+	stack := newTestStack(t, answerWithHeaders, func(cfg *config.Config) {
+		cfg.Session.Enabled = true
+		cfg.Session.Scope = config.SessionScopeGlobal
+		cfg.Session.MaxConcurrent = 1
+		cfg.Session.SessionTTL = 90 * time.Second
+	})
+
+	// The only session slot goes to the first client; another one (its own User-Agent) is refused locally
+	resp := stack.do(t, http.MethodPost, "/v1/messages", message, http.Header{"User-Agent": {"first"}})
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("first client: status = %d, want 200", resp.StatusCode)
+	}
+	before := time.Now()
+	resp = stack.do(t, http.MethodPost, "/v1/messages", message, http.Header{
+		"User-Agent":     {"second"},
+		requestid.Header: {"client-req-3"},
+	})
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("second client: status = %d, want 429", resp.StatusCode)
+	}
+	if len(stack.upstream.Requests()) != 1 {
+		t.Fatal("the refused request reached the upstream")
+	}
+
+	if got := resp.Header.Get(requestid.Header); got != "client-req-3" {
+		t.Errorf("%s = %q, want the client's", requestid.Header, got)
+	}
+	if got := resp.Header.Get(requestid.UpstreamHeader); got != "" {
+		t.Errorf("%s = %q on a response Claude API never sent", requestid.UpstreamHeader, got)
+	}
+
+	// Retry once the first client's session expires
+	retryAfter, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+	if err != nil || retryAfter < 89 || retryAfter > 90 {
+		t.Errorf("Retry-After = %q, want the session TTL (90)", resp.Header.Get("Retry-After"))
+	}
+	if got := resp.Header.Get("Anthropic-Ratelimit-Requests-Limit"); got != "1" {
+		t.Errorf("Anthropic-Ratelimit-Requests-Limit = %q, want the session limit 1", got)
+	}
+	if got := resp.Header.Get("Anthropic-Ratelimit-Requests-Remaining"); got != "0" {
+		t.Errorf("Anthropic-Ratelimit-Requests-Remaining = %q, want 0", got)
+	}
+	resetHeader := resp.Header.Get("Anthropic-Ratelimit-Requests-Reset")
+	reset, err := time.Parse(time.RFC3339, resetHeader)
+	if err != nil || reset.Before(before.Add(88*time.Second)) || reset.After(time.Now().Add(91*time.Second)) {
+		t.Errorf("Anthropic-Ratelimit-Requests-Reset = %q, want in 90s", resetHeader)
+	}
+}
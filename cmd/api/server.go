@@ -3,16 +3,20 @@ package api
 import (
 	"context"
 	"embed"
+	"errors"
 	"fmt"
-	"io/fs"
-	"mime"
+	"net"
 	"net/http"
-	"path/filepath"
+	"os"
+	"slices"
 
 	"claude-proxy/cmd/api/handlers"
 	"claude-proxy/config"
 	"claude-proxy/modules/auth/domain/interfaces"
+	proxyinterfaces "claude-proxy/modules/proxy/domain/interfaces"
 	"claude-proxy/pkg/middleware"
+	"claude-proxy/pkg/ratelimit"
+	"claude-proxy/pkg/storage"
 
 	"github.com/gin-gonic/gin"
 	sctx "github.com/phathdt/service-context"
@@ -33,35 +37,134 @@ func StartAPIServer(
 	authHandler *handlers.AuthHandler,
 	accountHandler *handlers.AccountHandler,
 	oauthHandler *handlers.OAuthHandler,
+	inviteHandler *handlers.InviteHandler,
+	deviceAuthHandler *handlers.DeviceAuthHandler,
 	statisticsHandler *handlers.StatisticsHandler,
+	metricsHandler *handlers.MetricsHandler,
 	sessionHandler *handlers.SessionHandler,
+	meHandler *handlers.MeHandler,
+	backupHandler *handlers.BackupHandler,
+	storageHandler *handlers.StorageHandler,
+	adminKeyHandler *handlers.AdminKeyHandler,
+	maintenanceHandler *handlers.MaintenanceHandler,
+	webhookHandler *handlers.WebhookHandler,
+	alertHandler *handlers.AlertHandler,
+	auditHandler *handlers.AuditHandler,
+	batchHandler *handlers.BatchHandler,
+	fileHandler *handlers.FileHandler,
+	replayHandler *handlers.ReplayHandler,
+	logLevelHandler *handlers.LogLevelHandler,
+	configHandler *handlers.ConfigHandler,
+	standbyHandler *handlers.StandbyHandler,
+	healthHandler *handlers.HealthHandler,
 	tokenService interfaces.TokenService,
-) {
-	// Health check (public)
-	engine.GET("/health", func(c *gin.Context) {
-		c.JSON(http.StatusOK, gin.H{
-			"status":    "healthy",
-			"timestamp": fmt.Sprint(engine),
+	adminKeyService interfaces.AdminKeyService,
+	maintenanceService proxyinterfaces.MaintenanceService,
+	standbyService interfaces.StandbyService,
+	storageMonitor *storage.Monitor,
+) error {
+	unsupportedEndpoints, err := middleware.UnsupportedEndpoints(cfg.Proxy.UnsupportedPaths, appLogger)
+	if err != nil {
+		return fmt.Errorf("invalid proxy.unsupported_paths: %w", err)
+	}
+
+	// With server.admin_port, the admin API, OAuth routes and dashboard get an engine and listener of their own,
+	// so the main port (the one exposed to clients) serves only /v1 and the health checks
+	adminEngine := engine
+	if cfg.Server.AdminListenerEnabled() {
+		if adminEngine, err = NewGinEngine(cfg, appLogger); err != nil {
+			return err
+		}
+		engine.NoRoute(func(c *gin.Context) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "page not found"})
 		})
-	})
+	}
+
+	// Health checks (public): liveness (/health is its alias) and readiness with dependency checks
+	for _, e := range slices.Compact([]*gin.Engine{engine, adminEngine}) {
+		e.GET("/health", healthHandler.Live)
+		e.GET("/health/live", healthHandler.Live)
+		e.GET("/health/ready", healthHandler.Ready)
+	}
 
 	// Protected Claude API proxy routes (user token authentication via Bearer)
 	v1 := engine.Group("/v1")
+	v1.Use(middleware.AnthropicErrorFormat())
+	v1.Use(middleware.Standby(standbyService))
+	v1.Use(middleware.Maintenance(maintenanceService))
 	// v1.Use(middleware.OpenAICompatibility())
 	v1.Use(middleware.BearerTokenAuth(tokenService, appLogger))
+	v1.Use(meHandler.Routes()) // Self-service /v1/me endpoints, scoped to the authenticated token
+	v1.Use(unsupportedEndpoints)
+	v1.Use(middleware.PathPolicy(cfg.Proxy.AllowedPaths, appLogger))
 	{
 		v1.Any("/*path", proxyHandler.ProxyRequest)
 	}
 
+	// Routes changing saved data answer 503 while the data folder is read-only (unless storage.allow_readonly)
+	writable := middleware.RequireWritableStorage(storageMonitor)
+	// Routes changing mirrored data answer 503 while the instance is a warm standby (standby.enabled)
+	active := middleware.RequireActive(standbyService)
+
+	// Unauthenticated routes are throttled per client IP; login and key validation share their own buckets, with
+	// lockouts
+	var publicLimit, loginLimit gin.HandlerFunc = (*gin.Context).Next, (*gin.Context).Next
+	if !cfg.Server.PublicRateLimit.Disabled {
+		publicLimit = middleware.PublicRateLimit(newPublicLimiter(cfg.Server.PublicRateLimit))
+		loginLimit = middleware.LoginRateLimit(newPublicLimiter(cfg.Server.PublicRateLimit))
+	}
+
 	// OAuth routes (public - for account creation)
-	oauth := engine.Group("/oauth")
+	oauth := adminEngine.Group("/oauth")
+	oauth.Use(publicLimit)
 	{
 		oauth.GET("/authorize", oauthHandler.GetAuthorizeURL)
-		oauth.POST("/exchange", oauthHandler.ExchangeCode)
+		oauth.POST("/exchange", active, writable, oauthHandler.ExchangeCode)
+		oauth.POST("/select-org", active, writable, oauthHandler.SelectOrg)
+		oauth.GET("/callback", active, writable, inviteHandler.InviteCallback)
+		oauth.GET("/invite/:token", inviteHandler.InvitePage)
+		oauth.POST("/invite/:token/exchange", active, writable, inviteHandler.ExchangeInviteCode)
+		oauth.POST("/invite/:token/select-org", active, writable, inviteHandler.SelectInviteOrg)
+	}
+
+	// Admin authentication: admin API key or admin-role token; viewer-role tokens reach the read-only
+	// statistics routes, where account names, organization UUIDs and token names are masked
+	viewerRoutes := []string{
+		"GET /api/admin/statistics",
+		"GET /api/admin/stats/tokens",
+		"GET /api/admin/metrics",
+	}
+	// The read-only API key (auth.readonly_api_key) reads accounts, tokens, statistics and sessions; captured
+	// request bodies, invites, admin keys, backups and the config stay behind the full key
+	readOnlyRoutes := append([]string{
+		"GET /api/accounts",
+		"GET /api/accounts/:id",
+		"GET /api/accounts/:id/requests",
+		"GET /api/accounts/:id/upstream-usage",
+		"GET /api/tokens",
+		"GET /api/tokens/:id",
+		"GET /api/tokens/:id/stats",
+		"GET /api/tokens/:id/users",
+		"GET /api/admin/sessions",
+	}, viewerRoutes...)
+	adminAuth := middleware.AdminAuth(
+		adminKeyService, tokenService, viewerRoutes, cfg.Auth.ReadOnlyAPIKey, readOnlyRoutes, appLogger,
+	)
+
+	// Device authorization (headless servers): starting one takes the admin key, completing and polling
+	// take the pasted code#state or the polling code it returned
+	device := oauth.Group("/device")
+	{
+		device.POST("/start", adminAuth, active, deviceAuthHandler.StartDeviceAuth)
+		device.POST("/complete", active, writable, deviceAuthHandler.CompleteDeviceAuth)
+		device.GET("/poll/:code", deviceAuthHandler.PollDeviceAuth)
 	}
 
 	// API routes for admin
-	api := engine.Group("/api")
+	api := adminEngine.Group("/api")
+	if !cfg.Server.Compression.Disabled {
+		api.Use(middleware.Compress(cfg.Server.Compression.MinSize))
+	}
 	{
 		api.GET("/health", func(c *gin.Context) {
 			c.JSON(http.StatusOK, gin.H{
@@ -72,132 +175,263 @@ func StartAPIServer(
 		// Auth routes (public)
 		auth := api.Group("/auth")
 		{
-			auth.POST("/login", authHandler.Login)
-			auth.POST("/validate", authHandler.Validate)
+			auth.POST("/login", loginLimit, authHandler.Login)
+			auth.POST("/validate", loginLimit, authHandler.Validate)
 		}
 
-		// Token routes (protected with API key)
+		// Token routes (protected with API key or admin token)
 		tokens := api.Group("/tokens")
-		tokens.Use(middleware.APIKeyAuth(cfg.Auth.APIKey))
+		tokens.Use(adminAuth)
 		{
 			tokens.GET("", tokenHandler.ListTokens)
-			tokens.POST("", tokenHandler.CreateToken)
+			tokens.POST("", active, writable, tokenHandler.CreateToken)
 			tokens.GET("/:id", tokenHandler.GetToken)
-			tokens.PUT("/:id", tokenHandler.UpdateToken)
-			tokens.DELETE("/:id", tokenHandler.DeleteToken)
+			tokens.GET("/:id/stats", statisticsHandler.GetTokenStats)
+			tokens.GET("/:id/users", statisticsHandler.GetTokenUsers)
+			tokens.GET("/:id/failures", replayHandler.GetTokenFailures)
+			tokens.PUT("/:id", active, writable, tokenHandler.UpdateToken)
+			tokens.DELETE("/:id", active, writable, tokenHandler.DeleteToken)
 		}
 
-		// Account routes (protected with API key)
+		// Account routes (protected with API key or admin token)
 		accounts := api.Group("/accounts")
-		accounts.Use(middleware.APIKeyAuth(cfg.Auth.APIKey))
+		accounts.Use(adminAuth)
 		{
 			accounts.GET("", accountHandler.ListAccounts)
+			accounts.POST("/import-credentials", active, writable, accountHandler.ImportCredentials)
+			accounts.POST("/manual", active, writable, accountHandler.CreateManualAccount)
+			accounts.GET("/invites", inviteHandler.ListInvites)
+			accounts.POST("/invites", active, writable, inviteHandler.CreateInvite)
+			accounts.DELETE("/invites/:id", active, writable, inviteHandler.RevokeInvite)
 			accounts.GET("/:id", accountHandler.GetAccount)
-			accounts.PUT("/:id", accountHandler.UpdateAccount)
-			accounts.DELETE("/:id", accountHandler.DeleteAccount)
+			accounts.GET("/:id/requests", accountHandler.GetAccountRequests)
+			accounts.GET("/:id/upstream-usage", accountHandler.GetUpstreamUsage)
+			accounts.PUT("/:id", active, writable, accountHandler.UpdateAccount)
+			accounts.DELETE("/:id", active, writable, accountHandler.DeleteAccount)
+			accounts.POST("/:id/restore", active, writable, accountHandler.RestoreAccount)
+			accounts.POST("/:id/enable", active, writable, accountHandler.EnableAccount)
+			accounts.POST("/:id/activate", active, writable, accountHandler.ActivateAccount)
+			accounts.PUT("/:id/credentials", active, writable, accountHandler.SetCredentials)
+			accounts.POST("/:id/adopt", active, writable, accountHandler.AdoptAccount)
 		}
 
-		// Admin routes (protected with API key)
+		// Admin routes (protected with API key or admin token)
 		admin := api.Group("/admin")
-		admin.Use(middleware.APIKeyAuth(cfg.Auth.APIKey))
+		admin.Use(adminAuth)
 		{
 			admin.GET("/statistics", statisticsHandler.GetStatistics)
+			admin.GET("/stats/tokens", statisticsHandler.GetTokenRanking)
+			admin.GET("/metrics", metricsHandler.GetMetrics)
 			admin.GET("/sessions", sessionHandler.ListAllSessions)
+			admin.GET("/sessions/history", sessionHandler.ListSessionHistory)
+			admin.POST("/sessions/revoke", active, writable, sessionHandler.RevokeSessions)
+			admin.POST("/backup", backupHandler.CreateBackup)
+			admin.GET("/backups", backupHandler.ListBackups)
+			admin.POST("/export", backupHandler.ExportBundle)
+			admin.POST("/reload", active, storageHandler.Reload)
+			admin.GET("/keys", adminKeyHandler.ListKeys)
+			admin.POST("/keys/rotate", writable, adminKeyHandler.RotateKey)
+			admin.DELETE("/keys/:id", writable, adminKeyHandler.RevokeKey)
+			admin.GET("/maintenance", maintenanceHandler.GetMaintenance)
+			admin.POST("/maintenance", writable, maintenanceHandler.SetMaintenance)
+			admin.GET("/standby", standbyHandler.GetStandby)
+			admin.POST("/promote", writable, standbyHandler.Promote)
+			admin.GET("/webhooks/failures", webhookHandler.GetFailures)
+			admin.POST("/webhooks/test", webhookHandler.SendTest)
+			admin.GET("/alerts", alertHandler.ListAlerts)
+			admin.GET("/audit/mutations", auditHandler.ListMutations)
+			admin.GET("/batches", batchHandler.ListBatches)
+			admin.GET("/files", fileHandler.ListFiles)
+			admin.POST("/replay", middleware.RequireAdminKey(), active, replayHandler.Replay)
+			admin.GET("/log-level", logLevelHandler.GetLogLevels)
+			admin.PUT("/log-level", logLevelHandler.SetLogLevel)
+			admin.GET("/config", middleware.RequireAdminKey(), configHandler.GetConfig)
 		}
 
-		// Session routes (protected with API key)
+		// Session routes (protected with API key or admin token)
 		sessions := api.Group("/sessions")
-		sessions.Use(middleware.APIKeyAuth(cfg.Auth.APIKey))
+		sessions.Use(adminAuth)
 		{
-			sessions.DELETE("/:id", sessionHandler.RevokeSession)
+			sessions.DELETE("/:id", active, writable, sessionHandler.RevokeSession)
 		}
 	}
 
-	// Serve static frontend files
-	staticFS, err := fs.Sub(FrontendFS, "frontend/dist")
-	if err == nil {
-		engine.NoRoute(func(c *gin.Context) {
-			path := c.Request.URL.Path
-			if path == "/" {
-				path = "/index.html"
-			}
-
-			// Try to serve the file
-			filePath := path[1:] // Remove leading slash
-			file, err := staticFS.Open(filePath)
-			if err != nil {
-				// If file not found, serve index.html for SPA routing
-				indexFile, err := staticFS.Open("index.html")
-				if err != nil {
-					c.JSON(http.StatusNotFound, gin.H{"error": "page not found"})
-					return
-				}
-				defer indexFile.Close()
-				c.DataFromReader(http.StatusOK, -1, "text/html; charset=utf-8", indexFile, nil)
-				return
-			}
-			defer file.Close()
-
-			// Detect MIME type from file extension
-			ext := filepath.Ext(filePath)
-			contentType := mime.TypeByExtension(ext)
-			if contentType == "" {
-				contentType = "application/octet-stream"
-			}
-
-			// Serve the file with proper MIME type
-			stat, _ := file.Stat()
-			c.DataFromReader(http.StatusOK, stat.Size(), contentType, file, nil)
-		})
-	}
+	// Serve static frontend files (under server.base_path, optionally behind the admin key)
+	registerDashboard(adminEngine, cfg.Server, adminKeyService, appLogger.Withs(sctx.Fields{"component": "dashboard"}))
 
-	port := cfg.Server.Port
+	network, address := cfg.Server.ListenAddress()
 	server := &http.Server{
-		Addr:    fmt.Sprintf(":%d", port),
-		Handler: engine,
+		Addr:    address,
+		Handler: withBasePath(cfg.Server.BasePath, engine),
+	}
+	var adminServer *http.Server
+	if cfg.Server.AdminListenerEnabled() {
+		adminServer = &http.Server{
+			Addr:    cfg.Server.AdminListenAddress(),
+			Handler: withBasePath(cfg.Server.BasePath, adminEngine),
+		}
 	}
 
 	lc.Append(fx.Hook{
 		OnStart: func(ctx context.Context) error {
-			appLogger.Withs(sctx.Fields{"port": port}).Info("Starting Claude Proxy Server")
+			// Listen before returning so bind errors (port in use, socket path) fail startup
+			listener, err := listen(cfg.Server)
+			if err != nil {
+				return fmt.Errorf("failed to listen on %s %s: %w", network, address, err)
+			}
+			var adminListener net.Listener
+			if adminServer != nil {
+				if adminListener, err = net.Listen("tcp", adminServer.Addr); err != nil {
+					listener.Close()
+					return fmt.Errorf("failed to listen on tcp %s (server.admin_port): %w", adminServer.Addr, err)
+				}
+			}
+
+			if adminServer == nil {
+				appLogger.Withs(sctx.Fields{
+					"network": network,
+					"address": address,
+					"tls":     cfg.Server.TLSEnabled(),
+				}).Info("Starting Claude Proxy Server")
+			} else {
+				appLogger.Withs(sctx.Fields{
+					"network": network,
+					"address": address,
+					"tls":     cfg.Server.TLSEnabled(),
+					"serves":  "/v1, /health",
+				}).Info("Starting Claude Proxy Server: proxy listener")
+				appLogger.Withs(sctx.Fields{
+					"network": "tcp",
+					"address": adminServer.Addr,
+					"tls":     cfg.Server.TLSEnabled(),
+					"serves":  "/api, /oauth, /health, dashboard",
+				}).Info("Starting Claude Proxy Server: admin listener (server.admin_port)")
+			}
 			appLogger.Info("API Endpoints:")
 			appLogger.Info("  Claude API Proxy (requires Bearer token):")
-			appLogger.Info("    ANY  /v1/*path        - Proxy all Claude API requests")
+			appLogger.Info("    ANY  /v1/*path        - Proxy allow-listed Claude API requests")
+			appLogger.Info("  Self-service (requires Bearer token, scoped to that token):")
+			appLogger.Info("    GET    /v1/me              - Token usage, active sessions and limits")
+			appLogger.Info("    POST   /v1/me/rotate       - Replace the token's key (new key shown once)")
+			appLogger.Info("    GET    /v1/me/sessions     - List the token's sessions")
+			appLogger.Info("    DELETE /v1/me/sessions/:id - Revoke one of the token's sessions")
+			appLogger.Info("  Unsupported OpenAI endpoints (answered locally with a 404, never proxied):")
+			for _, pattern := range append(append([]string{}, middleware.DefaultUnsupportedPaths...), cfg.Proxy.UnsupportedPaths...) {
+				appLogger.Infof("    ANY  %s", pattern)
+			}
 			appLogger.Info("  Health:")
-			appLogger.Info("    GET  /health          - Health check")
+			appLogger.Info("    GET  /health          - Liveness check (alias of /health/live)")
+			appLogger.Info("    GET  /health/live     - Liveness check")
+			appLogger.Info("    GET  /health/ready    - Readiness check (accounts, storage, sync; 503 when not ready)")
 			appLogger.Info("    GET  /api/health      - Health check (legacy)")
 			appLogger.Info("  OAuth (public):")
 			appLogger.Info("    GET  /oauth/authorize - Get OAuth authorization URL")
 			appLogger.Info("    POST /oauth/exchange  - Exchange OAuth code for account")
-			appLogger.Info("    GET  /oauth/callback  - OAuth callback handler")
+			appLogger.Info("    POST /oauth/select-org - Finalize account with chosen organization")
+			appLogger.Info("    GET  /oauth/callback  - OAuth callback (completes account invites and device authorizations)")
+			appLogger.Info("    GET  /oauth/invite/:token - One-time account invite page")
+			appLogger.Info("    POST /oauth/device/start - Start a device authorization (requires API key or admin token)")
+			appLogger.Info("    POST /oauth/device/complete - Complete a device authorization with the pasted code#state")
+			appLogger.Info("    GET  /oauth/device/poll/:code - Poll a device authorization until its account exists")
 			appLogger.Info("  Auth (public):")
 			appLogger.Info("    POST /api/auth/login    - Admin login")
 			appLogger.Info("    POST /api/auth/validate - Validate API key")
-			appLogger.Info("  Token Management (requires API key):")
+			appLogger.Info("  Token Management (requires API key or admin token):")
 			appLogger.Info("    GET    /api/tokens    - List all tokens")
 			appLogger.Info("    POST   /api/tokens    - Create new token")
 			appLogger.Info("    GET    /api/tokens/:id - Get token by ID")
 			appLogger.Info("    PUT    /api/tokens/:id - Update token")
 			appLogger.Info("    DELETE /api/tokens/:id - Delete token")
-			appLogger.Info("  Account Management (requires API key):")
+			appLogger.Info("  Account Management (requires API key or admin token):")
 			appLogger.Info("    GET    /api/accounts         - List all accounts")
+			appLogger.Info("    POST   /api/accounts/import-credentials - Import a Claude Code credentials file")
+			appLogger.Info("    POST   /api/accounts/manual  - Create an account from pasted tokens")
+			appLogger.Info("    GET    /api/accounts/invites - List account invites (pending, used, expired)")
+			appLogger.Info("    POST   /api/accounts/invites - Create a one-time account invite link")
+			appLogger.Info("    DELETE /api/accounts/invites/:id - Revoke an account invite")
 			appLogger.Info("    GET    /api/accounts/:id     - Get account by ID")
+			appLogger.Info("    GET    /api/accounts/:id/requests - Last requests proxied through the account")
+			appLogger.Info("    GET    /api/accounts/:id/upstream-usage - Official vs locally counted daily usage")
 			appLogger.Info("    PUT    /api/accounts/:id     - Update account")
 			appLogger.Info("    DELETE /api/accounts/:id     - Delete account")
-			appLogger.Info("  Session Management (requires API key):")
-			appLogger.Info("    GET    /api/admin/sessions  - List all sessions")
+			appLogger.Info("    POST   /api/accounts/:id/enable - Reactivate an account and clear its failure count")
+			appLogger.Info("    POST   /api/accounts/:id/activate - End a new account's cooldown")
+			appLogger.Info("    PUT    /api/accounts/:id/credentials - Replace account tokens with pasted ones")
+			appLogger.Info("  Session Management (requires API key or admin token):")
+			appLogger.Info("    GET    /api/admin/sessions  - List sessions (filters, sorting, pagination)")
 			appLogger.Info("    DELETE /api/sessions/:id    - Revoke session by ID")
+			appLogger.Info("    POST   /api/admin/sessions/revoke - Revoke every session matching a filter")
+			appLogger.Info("    GET    /api/admin/sessions/history - Sessions created in a month (JSON or CSV)")
+			appLogger.Info("  Backups (requires API key or admin token):")
+			appLogger.Info("    POST   /api/admin/backup    - Create backup now")
+			appLogger.Info("    GET    /api/admin/backups   - List backups")
+			appLogger.Info("    POST   /api/admin/export    - Download an encrypted state bundle")
+			appLogger.Info("    POST   /api/admin/reload    - Merge hand edits of accounts.json and tokens.json now")
+			appLogger.Info("  Admin Keys (requires API key or admin token):")
+			appLogger.Info("    GET    /api/admin/keys        - List admin keys")
+			appLogger.Info("    POST   /api/admin/keys/rotate - Rotate admin key (previous key kept for grace period)")
+			appLogger.Info("    DELETE /api/admin/keys/:id    - Revoke admin key")
+			appLogger.Info("  Warm Standby (requires API key or admin token):")
+			appLogger.Info("    GET    /api/admin/standby     - Standby state and last sync with the primary")
+			appLogger.Info("    POST   /api/admin/promote     - Stop following the primary and serve proxy traffic")
+			appLogger.Info("  Webhooks (requires API key or admin token):")
+			appLogger.Info("    GET    /api/admin/webhooks/failures - Delivery counters and recent failures")
+			appLogger.Info("    POST   /api/admin/webhooks/test     - Send a sample usage event")
+			appLogger.Info("  Alerts (requires API key or admin token):")
+			appLogger.Info("    GET    /api/admin/alerts      - Alert rules and their current state")
+			appLogger.Info("  Audit (requires API key or admin token):")
+			appLogger.Info("    GET    /api/admin/audit/mutations - Creates, updates and deletes of accounts and tokens")
+			appLogger.Info("  Message Batches (requires API key or admin token):")
+			appLogger.Info("    GET    /api/admin/batches     - List known batches and their pinned accounts")
+			appLogger.Info("  Uploaded Files (requires API key or admin token):")
+			appLogger.Info("    GET    /api/admin/files       - List known files and their pinned accounts")
+			appLogger.Info("  Logging (requires API key or admin token):")
+			appLogger.Info("    GET    /api/admin/log-level   - List log levels per component")
+			appLogger.Info("    PUT    /api/admin/log-level   - Change a component's log level (optional ttl)")
+			appLogger.Info("  Configuration (requires an admin API key):")
+			appLogger.Info("    GET    /api/admin/config      - Effective configuration (secrets redacted) and build info")
+			appLogger.Info("  Dashboard:")
+			appLogger.Infof("    GET    %s/ - Admin dashboard (dashboard_auth: %t)", cfg.Server.BasePath, cfg.Server.DashboardAuth)
 
 			go func() {
-				if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				if err := serve(server, listener, cfg.Server); err != nil && err != http.ErrServerClosed {
 					appLogger.Withs(sctx.Fields{"error": err}).Fatal("API server failed to start")
 				}
 			}()
+			if adminServer != nil {
+				go func() {
+					if err := serve(adminServer, adminListener, cfg.Server); err != nil && err != http.ErrServerClosed {
+						appLogger.Withs(sctx.Fields{"error": err}).Fatal("Admin server failed to start")
+					}
+				}()
+			}
 			return nil
 		},
 		OnStop: func(ctx context.Context) error {
 			appLogger.Info("Stopping API server...")
-			return server.Shutdown(ctx)
+			err := server.Shutdown(ctx)
+			if adminServer != nil {
+				err = errors.Join(err, adminServer.Shutdown(ctx))
+			}
+			// Closing a Unix listener removes its socket file; this covers a shutdown that timed out
+			if network == "unix" {
+				if removeErr := os.Remove(address); removeErr != nil && !os.IsNotExist(removeErr) {
+					appLogger.Withs(sctx.Fields{"error": removeErr.Error()}).Warn("Failed to remove socket file")
+				}
+			}
+			return err
 		},
 	})
+	return nil
+}
+
+// newPublicLimiter creates the per-IP token buckets of server.public_rate_limit
+func newPublicLimiter(cfg config.PublicRateLimitConfig) *ratelimit.IPLimiter {
+	return ratelimit.NewIPLimiter(ratelimit.IPLimiterConfig{
+		RequestsPerMinute: cfg.RequestsPerMinute,
+		Burst:             cfg.Burst,
+		Lockout:           cfg.LoginLockout,
+		MaxLockout:        cfg.LoginMaxLockout,
+		IdleTimeout:       cfg.IdleTimeout,
+	})
 }
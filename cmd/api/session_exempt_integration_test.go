@@ -0,0 +1,80 @@
+package api
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"testing"
+
+	"claude-proxy/config"
+)
+
+func TestIntegrationSessionExemptPathsAtSessionLimit(t *testing.T) {
+	const (
+		message = `{"model":"claude-sonnet-4","max_tokens":10,"messages":[{"role":"user","content":"hi"}]}`
+		stream  = `{"model":"claude-sonnet-4","max_tokens":10,"stream":true,` +
+			`"messages":[{"role":"user","content":"hi"}]}`
+	)
+	stack := newTestStack(t, answerGzip, func(cfg *config.Config) {
+		cfg.Session.Enabled = true
+		cfg.Session.Scope = config.SessionScopeGlobal
+		cfg.Session.MaxConcurrent = 1
+		cfg.Session.ExemptPaths = []string{"/v1/files/*"}
+		cfg.Proxy.AllowedPaths = []string{"/v1/files", "/v1/files/**"}
+	})
+
+	// The only session slot goes to the first client
+	resp := stack.do(t, http.MethodPost, "/v1/messages", message, http.Header{"User-Agent": {"first"}})
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("first client: status = %d, want 200", resp.StatusCode)
+	}
+
+	// A second client (its own User-Agent) reaches the exempt paths, but can't start a session
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		wantStatus int
+	}{
+		{"count_tokens", http.MethodPost, "/v1/messages/count_tokens", message, http.StatusOK},
+		{"count_tokens with a trailing slash", http.MethodPost, "/v1/messages/count_tokens/", message, http.StatusOK},
+		{"configured exempt path", http.MethodGet, "/v1/files/file_1", "", http.StatusOK},
+		{"message stream", http.MethodPost, "/v1/messages", stream, http.StatusTooManyRequests},
+		{"message", http.MethodPost, "/v1/messages", message, http.StatusTooManyRequests},
+		{"not exempt file listing", http.MethodGet, "/v1/files", "", http.StatusTooManyRequests},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := len(stack.upstream.Requests())
+			resp := stack.do(t, tt.method, tt.path, tt.body, http.Header{"User-Agent": {"second"}})
+			body, _ := io.ReadAll(resp.Body)
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d (%s), want %d", resp.StatusCode, body, tt.wantStatus)
+			}
+
+			forwarded, want := len(stack.upstream.Requests())-before, 0
+			if tt.wantStatus == http.StatusOK {
+				want = 1
+			}
+			if forwarded != want {
+				t.Errorf("upstream received %d requests, want %d", forwarded, want)
+			}
+		})
+	}
+
+	// Exempt requests never took a session of their own
+	sessions, err := stack.sessions.GetAllSessions(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	live := 0
+	for _, session := range sessions {
+		if session.IsActive && !session.IsExpired() {
+			live++
+		}
+	}
+	if live != 1 {
+		t.Errorf("%d live sessions, want only the first client's", live)
+	}
+}
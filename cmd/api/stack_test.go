@@ -0,0 +1,187 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"claude-proxy/config"
+	authentities "claude-proxy/modules/auth/domain/entities"
+	authinterfaces "claude-proxy/modules/auth/domain/interfaces"
+	"claude-proxy/pkg/logging"
+
+	"github.com/gin-gonic/gin"
+	sctx "github.com/phathdt/service-context"
+	"go.uber.org/fx"
+)
+
+const (
+	testAdminKey = "test-admin-key"
+	testTokenKey = "sk-proxy-test-token"
+)
+
+// testMessageResponse is the fake Claude API's answer to message requests
+const testMessageResponse = `{"id":"msg_1","type":"message","role":"assistant","model":"claude-sonnet-4",` +
+	`"content":[{"type":"text","text":"hi"}],"usage":{"input_tokens":3,"output_tokens":1}}`
+
+// upstreamRequest is a request as the fake Claude API received it
+type upstreamRequest struct {
+	Method        string
+	Path          string
+	Header        http.Header
+	ContentLength int64
+	Body          []byte
+}
+
+// recordingUpstream is a fake Claude API recording every request before its handler answers it
+type recordingUpstream struct {
+	*httptest.Server
+	mu       sync.Mutex
+	requests []upstreamRequest
+}
+
+func newRecordingUpstream(t *testing.T, handler http.HandlerFunc) *recordingUpstream {
+	t.Helper()
+
+	u := &recordingUpstream{}
+	u.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		u.mu.Lock()
+		u.requests = append(u.requests, upstreamRequest{
+			Method:        r.Method,
+			Path:          r.URL.RequestURI(),
+			Header:        r.Header.Clone(),
+			ContentLength: r.ContentLength,
+			Body:          body,
+		})
+		u.mu.Unlock()
+
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		handler(w, r)
+	}))
+	t.Cleanup(u.Close)
+	return u
+}
+
+// Requests returns the requests received so far
+func (u *recordingUpstream) Requests() []upstreamRequest {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return append([]upstreamRequest{}, u.requests...)
+}
+
+// answerMessage answers every request with a JSON message
+func answerMessage(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write([]byte(testMessageResponse))
+}
+
+// testStack is the API server wired as in production (routes, middleware, services and JSON storage in a
+// temporary data folder) in front of a recording fake Claude API, without its listeners or background jobs
+type testStack struct {
+	server   *httptest.Server
+	upstream *recordingUpstream
+	cfg      *config.Config
+	accounts authinterfaces.AccountService
+	tokens   authinterfaces.TokenService
+	sessions authinterfaces.SessionService
+	account  *authentities.Account // API key account serving every request
+	token    *authentities.Token   // User token whose key is testTokenKey
+}
+
+// newTestStack starts a test stack whose upstream answers with handler; configure (optional) adjusts the
+// default configuration first
+func newTestStack(t *testing.T, handler http.HandlerFunc, configure func(cfg *config.Config)) *testStack {
+	t.Helper()
+
+	// Only the defaults apply: no config file, no CLAUDE_PROXY_CONFIG_JSON
+	t.Setenv(config.ConfigJSONEnv, "")
+	dataFolder := t.TempDir()
+	cfg, err := config.LoadConfig(filepath.Join(dataFolder, "config.yaml"))
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+
+	s := &testStack{upstream: newRecordingUpstream(t, handler), cfg: cfg}
+	cfg.Storage.DataFolder = dataFolder
+	cfg.Claude.BaseURL = s.upstream.URL
+	cfg.Auth.APIKey = testAdminKey
+	if configure != nil {
+		configure(cfg)
+	}
+
+	registry, err := logging.NewRegistry("error")
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := registry.Wrap(sctx.GlobalLogger().GetLogger("test"))
+
+	// StartAPIServer registers the routes as it is invoked; the app is never started, so nothing listens
+	var engine *gin.Engine
+	app := fx.New(
+		fx.NopLogger,
+		CloveProviders,
+		fx.Supply(cfg, registry),
+		fx.Provide(func() sctx.Logger { return logger }),
+		fx.Provide(NewGinEngine),
+		fx.Invoke(StartAPIServer),
+		fx.Populate(&engine, &s.accounts, &s.tokens, &s.sessions),
+	)
+	if err := app.Err(); err != nil {
+		t.Fatalf("failed to build the API server: %v", err)
+	}
+
+	ctx := context.Background()
+	if s.account, err = s.accounts.CreateAPIKeyAccount(ctx, "test", "sk-ant-api-test"); err != nil {
+		t.Fatalf("CreateAPIKeyAccount() error = %v", err)
+	}
+	s.token, err = s.tokens.CreateToken(
+		ctx, "test", testTokenKey, authentities.TokenStatusActive, authentities.TokenRoleUser,
+	)
+	if err != nil {
+		t.Fatalf("CreateToken() error = %v", err)
+	}
+
+	s.server = httptest.NewServer(engine)
+	t.Cleanup(s.server.Close)
+	return s
+}
+
+// rawClient never decodes responses itself, so tests see the exact bytes and Content-Encoding the stack sent
+var rawClient = &http.Client{Transport: &http.Transport{DisableCompression: true}}
+
+// do sends a request authenticated with the test token, failing the test on transport errors
+func (s *testStack) do(t *testing.T, method, path, body string, header http.Header) *http.Response {
+	t.Helper()
+
+	var reader io.Reader
+	if body != "" {
+		reader = strings.NewReader(body)
+	}
+	req, err := http.NewRequest(method, s.server.URL+path, reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	if req.Header.Get("Authorization") == "" {
+		req.Header.Set("Authorization", "Bearer "+testTokenKey)
+	}
+	if body != "" && req.Header.Get("Content-Type") == "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := rawClient.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s failed: %v", method, path, err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
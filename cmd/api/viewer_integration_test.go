@@ -0,0 +1,173 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	authentities "claude-proxy/modules/auth/domain/entities"
+)
+
+const (
+	testViewerKey = "sk-proxy-test-viewer"
+	testOrgUUID   = "0b9e3c3e-8a4f-4c53-9d0e-2f6d1c1a7b01"
+)
+
+// newViewerStack starts a test stack holding a viewer token and an OAuth account with an organization, after
+// one message request through the test token so the statistics have usage
+func newViewerStack(t *testing.T) *testStack {
+	t.Helper()
+
+	stack := newTestStack(t, answerMessage, nil)
+	ctx := context.Background()
+	_, err := stack.tokens.CreateToken(
+		ctx, "dashboard", testViewerKey, authentities.TokenStatusActive, authentities.TokenRoleViewer,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = stack.accounts.CreateManualAccount(ctx, "Personal Max", testOrgUUID, &authentities.ManualCredentials{
+		AccessToken:  "sk-ant-oat01-personal",
+		RefreshToken: "sk-ant-ort01-personal",
+		ExpiresIn:    3600,
+	}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body := `{"model":"claude-sonnet-4","max_tokens":10,"messages":[{"role":"user","content":"hi"}]}`
+	if resp := stack.do(t, http.MethodPost, "/v1/messages", body, nil); resp.StatusCode != http.StatusOK {
+		t.Fatalf("POST /v1/messages status = %d, want 200", resp.StatusCode)
+	}
+	return stack
+}
+
+// getJSON decodes the JSON answer of a GET authenticated with key, which must succeed
+func getJSON(t *testing.T, stack *testStack, path, key string, v any) {
+	t.Helper()
+
+	resp := stack.do(t, http.MethodGet, path, "", http.Header{"Authorization": {"Bearer " + key}})
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		t.Fatalf("GET %s status = %d (%s), want 200", path, resp.StatusCode, body)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestIntegrationViewerStatisticsAreMasked(t *testing.T) {
+	stack := newViewerStack(t)
+
+	type statistics struct {
+		AccountUsage []struct {
+			AccountName      string `json:"account_name"`
+			OrganizationUUID string `json:"organization_uuid"`
+		} `json:"account_usage"`
+	}
+	tests := []struct {
+		name     string
+		key      string
+		wantName string
+		masked   bool
+	}{
+		{"admin key", testAdminKey, "Personal Max", false},
+		{"viewer token", testViewerKey, "Per…", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stats statistics
+			getJSON(t, stack, "/api/admin/statistics", tt.key, &stats)
+			found := false
+			for _, usage := range stats.AccountUsage {
+				if usage.AccountName != tt.wantName {
+					continue
+				}
+				found = true
+				masked := usage.OrganizationUUID != testOrgUUID
+				if masked != tt.masked || usage.OrganizationUUID == "" {
+					t.Errorf("organization_uuid = %q, want masked %t", usage.OrganizationUUID, tt.masked)
+				}
+			}
+			if !found {
+				t.Errorf("account_usage = %+v, want an account named %q", stats.AccountUsage, tt.wantName)
+			}
+			for _, usage := range stats.AccountUsage {
+				if tt.masked && (usage.AccountName == "Personal Max" || usage.AccountName == "test") {
+					t.Errorf("viewer sees account name %q", usage.AccountName)
+				}
+			}
+
+			var ranking struct {
+				Tokens []struct {
+					TokenName string `json:"token_name"`
+				} `json:"tokens"`
+			}
+			getJSON(t, stack, "/api/admin/stats/tokens", tt.key, &ranking)
+			wantToken := "test"
+			if tt.masked {
+				wantToken = "tes…"
+			}
+			if len(ranking.Tokens) != 1 || ranking.Tokens[0].TokenName != wantToken {
+				t.Errorf("token ranking = %+v, want only %q", ranking.Tokens, wantToken)
+			}
+		})
+	}
+}
+
+func TestIntegrationViewerIsRefusedMutations(t *testing.T) {
+	stack := newViewerStack(t)
+	message := `{"model":"claude-sonnet-4","max_tokens":10,"messages":[{"role":"user","content":"hi"}]}`
+
+	tests := []struct {
+		method string
+		path   string
+		body   string
+	}{
+		{http.MethodGet, "/api/tokens", ""},
+		{http.MethodPost, "/api/tokens", `{"name":"mine","role":"admin"}`},
+		{http.MethodPut, "/api/tokens/" + stack.token.ID, `{"name":"renamed"}`},
+		{http.MethodDelete, "/api/tokens/" + stack.token.ID, ""},
+		{http.MethodGet, "/api/accounts", ""},
+		{http.MethodPut, "/api/accounts/" + stack.account.ID, `{"name":"renamed"}`},
+		{http.MethodDelete, "/api/accounts/" + stack.account.ID, ""},
+		{http.MethodPost, "/api/admin/sessions/revoke", `{"all":true}`},
+		{http.MethodPost, "/api/admin/maintenance", `{"enabled":true}`},
+		{http.MethodPost, "/api/admin/keys/rotate", ""},
+		{http.MethodPost, "/v1/messages", message},
+		{http.MethodPost, "/v1/messages/count_tokens", message},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			before := len(stack.upstream.Requests())
+			resp := stack.do(t, tt.method, tt.path, tt.body, http.Header{"Authorization": {"Bearer " + testViewerKey}})
+			body, _ := io.ReadAll(resp.Body)
+			if resp.StatusCode != http.StatusForbidden {
+				t.Errorf("status = %d (%s), want 403", resp.StatusCode, body)
+			}
+			if strings.HasPrefix(tt.path, "/v1/") {
+				want := `{"error":{"message":"viewer tokens can only read admin statistics",` +
+					`"type":"permission_error"},"type":"error"}`
+				if string(body) != want {
+					t.Errorf("body = %s, want %s", body, want)
+				}
+			}
+			if forwarded := len(stack.upstream.Requests()) - before; forwarded != 0 {
+				t.Errorf("upstream received %d requests, want none", forwarded)
+			}
+		})
+	}
+
+	// Nothing changed
+	token, err := stack.tokens.GetTokenByID(context.Background(), stack.token.ID)
+	if err != nil || token.Name != "test" {
+		t.Errorf("user token after the viewer's requests: %+v, %v", token, err)
+	}
+	account, err := stack.accounts.GetAccount(context.Background(), stack.account.ID)
+	if err != nil || account.Name != "test" {
+		t.Errorf("account after the viewer's requests: %+v, %v", account, err)
+	}
+}
@@ -0,0 +1,178 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+	"time"
+)
+
+// AlertsConfig holds the built-in alert rules, evaluated against the in-memory statistics counters
+type AlertsConfig struct {
+	Disabled bool          `yaml:"disabled" mapstructure:"disabled"` // Turns off the evaluator (default rules included)
+	Interval time.Duration `yaml:"interval" mapstructure:"interval"` // How often rules are evaluated (default 30s)
+	// Rules are added to the default rules; a rule named like a default one changes the settings it sets
+	// (disabled: true turns it off)
+	Rules []AlertRuleConfig `yaml:"rules" mapstructure:"rules"`
+}
+
+// AlertRuleConfig fires an alert while metric compares to threshold (e.g. server_error_rate > 5 over 5m)
+type AlertRuleConfig struct {
+	Name      string        `yaml:"name"      mapstructure:"name"`
+	Metric    string        `yaml:"metric"    mapstructure:"metric"`    // One of AlertMetrics
+	Operator  string        `yaml:"operator"  mapstructure:"operator"`  // >, >=, < or <=
+	Threshold float64       `yaml:"threshold" mapstructure:"threshold"` // Rates are percentages (0-100)
+	Window    time.Duration `yaml:"window"    mapstructure:"window"`    // Counted metrics only (default 5m)
+	Severity  string        `yaml:"severity"  mapstructure:"severity"`  // info, warning (default) or critical
+	// MinRequests keeps rate metrics from firing on too few requests over the window
+	MinRequests int64 `yaml:"min_requests" mapstructure:"min_requests"`
+	Disabled    bool  `yaml:"disabled"     mapstructure:"disabled"`
+}
+
+// Alert metrics
+const (
+	AlertMetricRequests        = "requests"          // Completed requests over the window
+	AlertMetricErrors          = "errors"            // Requests without an upstream response or with an error status
+	AlertMetricErrorRate       = "error_rate"        // errors / requests over the window, in percent
+	AlertMetricServerErrors    = "server_errors"     // Requests without an upstream response or with a 5xx status
+	AlertMetricServerErrorRate = "server_error_rate" // server_errors / requests over the window, in percent
+	AlertMetricInFlight        = "in_flight"         // Requests in flight now
+	AlertMetricActiveAccounts  = "active_accounts"   // Accounts a request could be sent through now
+	AlertMetricRefreshFailures = "refresh_failures"  // Accounts whose last token refresh failed within the window
+)
+
+// AlertMetrics lists the metrics alert rules can watch
+var AlertMetrics = []string{
+	AlertMetricRequests, AlertMetricErrors, AlertMetricErrorRate, AlertMetricServerErrors,
+	AlertMetricServerErrorRate, AlertMetricInFlight, AlertMetricActiveAccounts, AlertMetricRefreshFailures,
+}
+
+// AlertMetricWindowed returns true if metric is counted over the rule's window (the others are read as is)
+func AlertMetricWindowed(metric string) bool {
+	return metric != AlertMetricInFlight && metric != AlertMetricActiveAccounts
+}
+
+// Alert severities
+const (
+	AlertSeverityInfo     = "info"
+	AlertSeverityWarning  = "warning"
+	AlertSeverityCritical = "critical"
+)
+
+// DefaultAlertRules are evaluated unless disabled by a rule of the same name
+func DefaultAlertRules() []AlertRuleConfig {
+	return []AlertRuleConfig{
+		{
+			Name:      "no_active_accounts",
+			Metric:    AlertMetricActiveAccounts,
+			Operator:  "<",
+			Threshold: 1,
+			Severity:  AlertSeverityCritical,
+		},
+		{
+			Name:        "high_error_rate",
+			Metric:      AlertMetricServerErrorRate,
+			Operator:    ">",
+			Threshold:   5,
+			Window:      5 * time.Minute,
+			Severity:    AlertSeverityCritical,
+			MinRequests: 20,
+		},
+		{
+			Name:      "refresh_failure_spike",
+			Metric:    AlertMetricRefreshFailures,
+			Operator:  ">=",
+			Threshold: 3,
+			Window:    15 * time.Minute,
+			Severity:  AlertSeverityWarning,
+		},
+	}
+}
+
+// EffectiveRules returns the default rules merged with the configured ones, without the disabled rules
+// A configured rule named like a default one overrides the settings it sets (non-zero values)
+func (a AlertsConfig) EffectiveRules() []AlertRuleConfig {
+	rules := DefaultAlertRules()
+	for _, rule := range a.Rules {
+		i := slices.IndexFunc(rules, func(r AlertRuleConfig) bool { return r.Name == rule.Name })
+		if i < 0 {
+			rules = append(rules, rule)
+			continue
+		}
+		rules[i] = mergeAlertRule(rules[i], rule)
+	}
+
+	rules = slices.DeleteFunc(rules, func(r AlertRuleConfig) bool { return r.Disabled })
+	for i := range rules {
+		if rules[i].Severity == "" {
+			rules[i].Severity = AlertSeverityWarning
+		}
+		if rules[i].Window == 0 && AlertMetricWindowed(rules[i].Metric) {
+			rules[i].Window = 5 * time.Minute
+		}
+	}
+	return rules
+}
+
+// mergeAlertRule applies the non-zero settings of override to base
+func mergeAlertRule(base, override AlertRuleConfig) AlertRuleConfig {
+	if override.Metric != "" {
+		base.Metric = override.Metric
+	}
+	if override.Operator != "" {
+		base.Operator = override.Operator
+	}
+	if override.Threshold != 0 {
+		base.Threshold = override.Threshold
+	}
+	if override.Window != 0 {
+		base.Window = override.Window
+	}
+	if override.Severity != "" {
+		base.Severity = override.Severity
+	}
+	if override.MinRequests != 0 {
+		base.MinRequests = override.MinRequests
+	}
+	base.Disabled = override.Disabled
+	return base
+}
+
+// validate checks the evaluation interval and every enabled rule
+func (a AlertsConfig) validate() error {
+	if a.Interval < 0 {
+		return fmt.Errorf("alerts.interval must not be negative")
+	}
+
+	names := make(map[string]bool)
+	for _, rule := range a.Rules {
+		if rule.Name == "" {
+			return fmt.Errorf("alerts.rules: every rule needs a name")
+		}
+		if names[rule.Name] {
+			return fmt.Errorf("alerts.rules: duplicate rule %q", rule.Name)
+		}
+		names[rule.Name] = true
+	}
+
+	for _, rule := range a.EffectiveRules() {
+		if !slices.Contains(AlertMetrics, rule.Metric) {
+			return fmt.Errorf("alert rule %q: unknown metric %q, expected one of %v", rule.Name, rule.Metric, AlertMetrics)
+		}
+		switch rule.Operator {
+		case ">", ">=", "<", "<=":
+		default:
+			return fmt.Errorf("alert rule %q: invalid operator %q, expected >, >=, < or <=", rule.Name, rule.Operator)
+		}
+		switch rule.Severity {
+		case AlertSeverityInfo, AlertSeverityWarning, AlertSeverityCritical:
+		default:
+			return fmt.Errorf(
+				"alert rule %q: invalid severity %q, expected info, warning or critical", rule.Name, rule.Severity,
+			)
+		}
+		if rule.Window < 0 || rule.MinRequests < 0 {
+			return fmt.Errorf("alert rule %q: window and min_requests must not be negative", rule.Name)
+		}
+	}
+	return nil
+}
@@ -1,10 +1,19 @@
 package config
 
 import (
+	"errors"
 	"fmt"
+	"io/fs"
+	"net/textproto"
+	"net/url"
+	"os"
+	"reflect"
+	"slices"
 	"strings"
 	"time"
 
+	"claude-proxy/pkg/httpproxy"
+
 	"github.com/joho/godotenv"
 	"github.com/spf13/viper"
 )
@@ -14,11 +23,23 @@ type Config struct {
 	Logger   LoggerConfig   `yaml:"logger"   mapstructure:"logger"`
 	Auth     AuthConfig     `yaml:"auth"     mapstructure:"auth"`
 	OAuth    OAuthConfig    `yaml:"oauth"    mapstructure:"oauth"`
+	Accounts AccountsConfig `yaml:"accounts" mapstructure:"accounts"`
 	Claude   ClaudeConfig   `yaml:"claude"   mapstructure:"claude"`
 	Storage  StorageConfig  `yaml:"storage"  mapstructure:"storage"`
 	Retry    RetryConfig    `yaml:"retry"    mapstructure:"retry"`
+	Refresh  RefreshConfig  `yaml:"refresh"  mapstructure:"refresh"`
 	Session  SessionConfig  `yaml:"session"  mapstructure:"session"`
+	Proxy    ProxyConfig    `yaml:"proxy"    mapstructure:"proxy"`
+	Stats    StatsConfig    `yaml:"stats"    mapstructure:"stats"`
+	Privacy  PrivacyConfig  `yaml:"privacy"  mapstructure:"privacy"`
 	Telegram TelegramConfig `yaml:"telegram" mapstructure:"telegram"`
+	Webhooks WebhooksConfig `yaml:"webhooks" mapstructure:"webhooks"`
+	Clock    ClockConfig    `yaml:"clock"    mapstructure:"clock"`
+	Alerts   AlertsConfig   `yaml:"alerts"   mapstructure:"alerts"`
+	Standby  StandbyConfig  `yaml:"standby"  mapstructure:"standby"`
+
+	sources  map[string]string // Where each top-level section comes from (see Sources)
+	indirect map[string]bool   // Settings resolved from ${env:...}, ${file:...} or ${exec:...}, never shown
 }
 
 type TelegramConfig struct {
@@ -31,17 +52,105 @@ type TelegramConfig struct {
 type LoggerConfig struct {
 	Level  string `yaml:"level"  mapstructure:"level"`
 	Format string `yaml:"format" mapstructure:"format"`
+	// LogBodies controls how much of the Claude API request and response bodies the client logs: none,
+	// metadata (default: sizes, model and message count), redacted (content replaced by its length) or full
+	LogBodies string `yaml:"log_bodies" mapstructure:"log_bodies"`
+	// BodySamplePercent is the share (0-100) of requests whose bodies are logged under log_bodies redacted or
+	// full (default 100)
+	BodySamplePercent float64 `yaml:"body_sample_percent" mapstructure:"body_sample_percent"`
 }
 
+// How much of the Claude API request and response bodies is logged
+const (
+	LogBodiesNone     = "none"     // nothing about the bodies
+	LogBodiesMetadata = "metadata" // sizes, model and message count
+	LogBodiesRedacted = "redacted" // JSON structure with message content replaced by its length
+	LogBodiesFull     = "full"     // bodies under 10KB, secrets masked
+)
+
 type ServerConfig struct {
 	Host           string        `yaml:"host"            mapstructure:"host"`
 	Port           int           `yaml:"port"            mapstructure:"port"`
 	RequestTimeout time.Duration `yaml:"request_timeout" mapstructure:"request_timeout"`
+	// AdminPort serves the admin API, OAuth routes and dashboard on their own listener, leaving only /v1 and the
+	// health checks on the main one (0 = everything on the main listener)
+	AdminPort int    `yaml:"admin_port" mapstructure:"admin_port"`
+	AdminHost string `yaml:"admin_host" mapstructure:"admin_host"` // Interface of the admin listener (default: host)
+	// Listen replaces host/port with a Unix socket ("unix:///var/run/claude-proxy.sock")
+	Listen     string `yaml:"listen"      mapstructure:"listen"`
+	SocketMode string `yaml:"socket_mode" mapstructure:"socket_mode"` // Octal permissions of the socket file (default 0660)
+	// TLS serves HTTPS (with HTTP/2) directly when both files are set
+	TLS TLSConfig `yaml:"tls" mapstructure:"tls"`
+	// BasePath serves the dashboard (and accepts every route) under a path prefix such as "/claude-proxy"
+	BasePath string `yaml:"base_path" mapstructure:"base_path"`
+	// DashboardAuth requires the admin API key (Basic auth password or cookie) for every dashboard asset
+	DashboardAuth bool `yaml:"dashboard_auth" mapstructure:"dashboard_auth"`
+	// CORS controls cross-origin browser access, separately for the /v1 proxy and the other routes
+	CORS CORSConfig `yaml:"cors" mapstructure:"cors"`
+	// Compression of admin API responses and dashboard assets (never of /v1 proxy responses)
+	Compression CompressionConfig `yaml:"compression" mapstructure:"compression"`
+	// PublicRateLimit throttles the unauthenticated routes (OAuth, login) per client IP
+	PublicRateLimit PublicRateLimitConfig `yaml:"public_rate_limit" mapstructure:"public_rate_limit"`
+	// TrustedProxies lists the reverse proxies (IPs or CIDRs) whose X-Forwarded-For/X-Real-IP headers name the
+	// client IP of rate limits and logs (default none: the address of the connection is used)
+	TrustedProxies []string `yaml:"trusted_proxies" mapstructure:"trusted_proxies"`
+}
+
+// PublicRateLimitConfig holds the per-IP token buckets of the unauthenticated routes
+type PublicRateLimitConfig struct {
+	Disabled          bool `yaml:"disabled"            mapstructure:"disabled"`
+	RequestsPerMinute int  `yaml:"requests_per_minute" mapstructure:"requests_per_minute"` // Refill rate (default 30)
+	Burst             int  `yaml:"burst"               mapstructure:"burst"`               // Bucket size (default 10)
+	// LoginLockout locks out an IP exceeding the limit on /api/auth/login, doubled per lockout (default 1m)
+	LoginLockout    time.Duration `yaml:"login_lockout"     mapstructure:"login_lockout"`
+	LoginMaxLockout time.Duration `yaml:"login_max_lockout" mapstructure:"login_max_lockout"` // Default 1h
+	// IdleTimeout drops the bucket of an IP without requests for this long (default 10m)
+	IdleTimeout time.Duration `yaml:"idle_timeout" mapstructure:"idle_timeout"`
+}
+
+// CompressionConfig holds the zstd/gzip compression of the responses the proxy produces itself
+type CompressionConfig struct {
+	Disabled bool `yaml:"disabled" mapstructure:"disabled"`
+	MinSize  int  `yaml:"min_size" mapstructure:"min_size"` // Smallest body compressed, in bytes (default 1024)
+}
+
+// CORSConfig holds the cross-origin policies of the proxy and admin routes (defaults allow any origin)
+type CORSConfig struct {
+	Proxy CORSPolicyConfig `yaml:"proxy" mapstructure:"proxy"` // /v1 routes
+	Admin CORSPolicyConfig `yaml:"admin" mapstructure:"admin"` // /api, /oauth, dashboard and /health routes
+}
+
+// CORSPolicyConfig is the cross-origin access allowed to a group of routes
+type CORSPolicyConfig struct {
+	// AllowedOrigins lists exact origins (https://dash.example.com) or "*" for any origin (default ["*"])
+	AllowedOrigins []string `yaml:"allowed_origins" mapstructure:"allowed_origins"`
+	// AllowedHeaders lists the request headers allowed cross-origin (default: Authorization, X-API-Key,
+	// Content-Type and the other common ones, plus the anthropic-* headers and any header a preflight asks for
+	// on the proxy routes); "*" also allows any header a preflight asks for
+	AllowedHeaders []string `yaml:"allowed_headers" mapstructure:"allowed_headers"`
+	// ExposedHeaders lists the response headers browsers may read (default: none, and request-id, X-Request-Id,
+	// Retry-After and the anthropic-ratelimit-* headers on the proxy routes)
+	ExposedHeaders []string `yaml:"exposed_headers" mapstructure:"exposed_headers"`
+	// AllowCredentials allows cookies and HTTP auth; the matching origin is echoed instead of "*"
+	AllowCredentials bool `yaml:"allow_credentials" mapstructure:"allow_credentials"`
+	// MaxAge is how long browsers may cache preflight results (0 = no Access-Control-Max-Age header)
+	MaxAge time.Duration `yaml:"max_age" mapstructure:"max_age"`
+}
+
+// TLSConfig holds the certificate used to serve HTTPS without a reverse proxy
+type TLSConfig struct {
+	CertFile string `yaml:"cert_file" mapstructure:"cert_file"`
+	KeyFile  string `yaml:"key_file"  mapstructure:"key_file"`
 }
 
 // AuthConfig holds API key authentication configuration
 type AuthConfig struct {
-	APIKey string `yaml:"api_key" mapstructure:"api_key"`
+	APIKey string `yaml:"api_key" mapstructure:"api_key"` // Bootstrap key, used only until the first rotation
+	// ReadOnlyAPIKey reads accounts, tokens, statistics and sessions, and nothing else (empty = none)
+	// Unlike api_key it is never rotated out: change it in the config and restart
+	ReadOnlyAPIKey string `yaml:"readonly_api_key" mapstructure:"readonly_api_key"`
+	// KeyRotationGrace is how long the previous admin key keeps working after a rotation
+	KeyRotationGrace time.Duration `yaml:"key_rotation_grace" mapstructure:"key_rotation_grace"`
 }
 
 // OAuthConfig holds OAuth 2.0 configuration for Claude authentication
@@ -51,17 +160,89 @@ type OAuthConfig struct {
 	TokenURL     string `yaml:"token_url"     mapstructure:"token_url"`
 	RedirectURI  string `yaml:"redirect_uri"  mapstructure:"redirect_uri"`
 	Scope        string `yaml:"scope"         mapstructure:"scope"`
+	// OrganizationsURL lists the organizations of an OAuth user (defaults to {claude.base_url}/api/organizations)
+	OrganizationsURL string `yaml:"organizations_url" mapstructure:"organizations_url"`
+	// InviteTTL is how long account invite links stay valid unless created with expires_in (default 24h)
+	InviteTTL time.Duration `yaml:"invite_ttl" mapstructure:"invite_ttl"`
+	// DeviceTTL is how long a device authorization (POST /oauth/device/start) can be completed unless started
+	// with expires_in (default 15m)
+	DeviceTTL time.Duration `yaml:"device_ttl" mapstructure:"device_ttl"`
+}
+
+// AccountsConfig holds how new accounts enter the rotation
+type AccountsConfig struct {
+	// NewAccountCooldown keeps newly added accounts out of the rotation until it elapses or an admin activates
+	// them (0 = used right away)
+	NewAccountCooldown time.Duration `yaml:"new_account_cooldown" mapstructure:"new_account_cooldown"`
+	// AvailabilityAlert sends a Telegram alert when accounts exist but none is within its availability windows
+	AvailabilityAlert bool `yaml:"availability_alert" mapstructure:"availability_alert"`
+	// UpstreamUsageInterval is how often the usage reports of admin-capable accounts are collected from Claude
+	// API (default 6h); UpstreamUsageDays is how many days back each collection covers (default 7, at most 31)
+	UpstreamUsageInterval time.Duration `yaml:"upstream_usage_interval" mapstructure:"upstream_usage_interval"`
+	UpstreamUsageDays     int           `yaml:"upstream_usage_days"     mapstructure:"upstream_usage_days"`
 }
 
 // ClaudeConfig holds Claude API configuration
 type ClaudeConfig struct {
 	BaseURL string `yaml:"base_url" mapstructure:"base_url"`
+	// Timeout bounds non-streaming upstream requests, body included (defaults to server.request_timeout)
+	Timeout time.Duration `yaml:"timeout" mapstructure:"timeout"`
+	// StreamTimeout bounds streaming ("stream": true) upstream requests; 0 leaves them bounded only by
+	// server.request_timeout and the client connection
+	StreamTimeout time.Duration `yaml:"stream_timeout" mapstructure:"stream_timeout"`
+	// Headers identify the client on every API and OAuth request (accounts may override them)
+	Headers ClaudeHeadersConfig `yaml:"headers" mapstructure:"headers"`
+}
+
+// DefaultClaudeUserAgent is the User-Agent sent upstream when claude.headers.user_agent is not set,
+// matching the one Claude Code sends
+const DefaultClaudeUserAgent = "claude-cli/2.0.14 (external, cli)"
+
+// ClaudeHeadersConfig holds the client identification headers sent to Anthropic
+type ClaudeHeadersConfig struct {
+	UserAgent string `yaml:"user_agent" mapstructure:"user_agent"` // Defaults to DefaultClaudeUserAgent
+	XApp      string `yaml:"x_app"      mapstructure:"x_app"`      // x-app header (defaults to "cli")
+	// Extra static headers added to every request (names are case-insensitive)
+	Extra map[string]string `yaml:"extra" mapstructure:"extra"`
+}
+
+// Map returns every configured header by canonical name; user_agent and x_app take precedence over extra
+func (h ClaudeHeadersConfig) Map() map[string]string {
+	headers := make(map[string]string, len(h.Extra)+2)
+	for name, value := range h.Extra {
+		headers[textproto.CanonicalMIMEHeaderKey(name)] = value
+	}
+	headers["User-Agent"] = h.UserAgent
+	headers["X-App"] = h.XApp
+	return headers
 }
 
 // StorageConfig holds data storage configuration
 type StorageConfig struct {
 	DataFolder   string        `yaml:"data_folder"   mapstructure:"data_folder"`
 	SyncInterval time.Duration `yaml:"sync_interval" mapstructure:"sync_interval"`
+	// Fsync flushes each data file and its folder to disk on save, so a power loss can't lose a completed save
+	Fsync bool `yaml:"fsync" mapstructure:"fsync"`
+	// AllowReadOnly accepts a read-only data folder (ephemeral deployments): changes stay in memory and the sync
+	// job is disabled; otherwise changes that could not be saved are refused with 503 STORAGE_READ_ONLY
+	AllowReadOnly bool `yaml:"allow_readonly" mapstructure:"allow_readonly"`
+	// Scheduled backups of accounts.json, tokens.json, sessions.json and stats.json
+	BackupEnabled  bool          `yaml:"backup_enabled"  mapstructure:"backup_enabled"`
+	BackupFolder   string        `yaml:"backup_folder"   mapstructure:"backup_folder"`
+	BackupInterval time.Duration `yaml:"backup_interval" mapstructure:"backup_interval"`
+	BackupKeep     int           `yaml:"backup_keep"     mapstructure:"backup_keep"` // Most recent backups to retain
+	// CacheSoftLimits are the entry counts above which the in-memory caches log a warning
+	CacheSoftLimits CacheSoftLimitsConfig `yaml:"cache_soft_limits" mapstructure:"cache_soft_limits"`
+}
+
+// CacheSoftLimitsConfig holds the soft limits of the in-memory caches (0 = default, -1 = no limit)
+// Nothing is evicted past a limit: the data files are the source of truth and hold everything the caches do,
+// so the limit only flags growth worth looking at (e.g. sessions piling up without expiring)
+type CacheSoftLimitsConfig struct {
+	Accounts     int `yaml:"accounts"      mapstructure:"accounts"`
+	Tokens       int `yaml:"tokens"        mapstructure:"tokens"`
+	Sessions     int `yaml:"sessions"      mapstructure:"sessions"`
+	UsageBuckets int `yaml:"usage_buckets" mapstructure:"usage_buckets"` // Per-token usage buckets across all tokens
 }
 
 // RetryConfig holds retry logic configuration
@@ -70,13 +251,190 @@ type RetryConfig struct {
 	RetryDelay time.Duration `yaml:"retry_delay" mapstructure:"retry_delay"`
 }
 
+// RefreshConfig holds the hourly background token refresh settings
+type RefreshConfig struct {
+	// MaxConcurrent caps the OAuth refresh requests in flight at once (default 1)
+	MaxConcurrent int `yaml:"max_concurrent" mapstructure:"max_concurrent"`
+	// Spread is the window the refreshes of one run are evenly spaced over (default 5m)
+	Spread time.Duration `yaml:"spread" mapstructure:"spread"`
+	// Jitter adds a random delay of up to this much to each refresh (default 5s)
+	Jitter time.Duration `yaml:"jitter" mapstructure:"jitter"`
+	// AlertFailures sends a Telegram alert when a run has at least this many failed refreshes (default 3)
+	AlertFailures int `yaml:"alert_failures" mapstructure:"alert_failures"`
+}
+
+// ClockConfig holds the clock skew checks against Claude API and the upstream 401 handling they protect
+// Token expiry is computed with the local clock, so a skewed clock sends expired access tokens upstream
+type ClockConfig struct {
+	// MaxSkew is the largest difference from Claude API's Date header tolerated (default 30s)
+	MaxSkew time.Duration `yaml:"max_skew" mapstructure:"max_skew"`
+	// CheckInterval is how often the skew is measured in the background (default 15m, 0 = default)
+	CheckInterval time.Duration `yaml:"check_interval" mapstructure:"check_interval"`
+	// StartupCheck handles a skew above max_skew at startup: block (default), warn or off
+	StartupCheck string `yaml:"startup_check" mapstructure:"startup_check"`
+	// AuthFailureWindow is how far back upstream 401s count toward an all-accounts failure (default 5m)
+	AuthFailureWindow time.Duration `yaml:"auth_failure_window" mapstructure:"auth_failure_window"`
+	// AggressiveInvalidation marks an account invalid on its first upstream 401, even when every account fails
+	AggressiveInvalidation bool `yaml:"aggressive_invalidation" mapstructure:"aggressive_invalidation"`
+}
+
+// Startup clock check policies
+const (
+	ClockStartupBlock = "block" // refuse to start when the skew exceeds max_skew
+	ClockStartupWarn  = "warn"  // log the skew and start anyway
+	ClockStartupOff   = "off"   // no startup check (background checks still run)
+)
+
 // SessionConfig holds session limiting configuration (in-memory storage)
 type SessionConfig struct {
 	Enabled         bool          `yaml:"enabled"          mapstructure:"enabled"`
 	MaxConcurrent   int           `yaml:"max_concurrent"   mapstructure:"max_concurrent"`
-	SessionTTL      time.Duration `yaml:"session_ttl"      mapstructure:"session_ttl"`
+	Scope           string        `yaml:"scope"            mapstructure:"scope"`         // global, token or both
+	MaxPerToken     int           `yaml:"max_per_token"    mapstructure:"max_per_token"` // Default per-token limit (token/both scopes)
+	SessionTTL      time.Duration `yaml:"session_ttl"      mapstructure:"session_ttl"`   // Idle timeout
 	CleanupEnabled  bool          `yaml:"cleanup_enabled"  mapstructure:"cleanup_enabled"`
 	CleanupInterval time.Duration `yaml:"cleanup_interval" mapstructure:"cleanup_interval"`
+	// TTLByRole overrides session_ttl per token role (user, admin)
+	TTLByRole map[string]time.Duration `yaml:"ttl_by_role" mapstructure:"ttl_by_role"`
+	// MaxLifetime ends sessions this long after creation however active they are (0 = no maximum, the default)
+	MaxLifetime time.Duration `yaml:"max_lifetime" mapstructure:"max_lifetime"`
+	// MaxLifetimeByRole overrides max_lifetime per token role (0 = no maximum for that role)
+	MaxLifetimeByRole map[string]time.Duration `yaml:"max_lifetime_by_role" mapstructure:"max_lifetime_by_role"`
+	// Retention is how long ended (expired or revoked) sessions stay in sessions.json (default 24h)
+	Retention time.Duration `yaml:"retention" mapstructure:"retention"`
+	// Archive moves pruned sessions to monthly sessions-archive-YYYY-MM.json files instead of dropping them
+	Archive bool `yaml:"archive" mapstructure:"archive"`
+	// HistoryEnabled records every new session (token, IP, User-Agent) in monthly session-history-YYYY-MM.jsonl
+	// files, kept after the sessions themselves are pruned
+	HistoryEnabled bool `yaml:"history_enabled" mapstructure:"history_enabled"`
+	// HistoryRetention is how long session history files are kept after their month ends (default 8760h)
+	HistoryRetention time.Duration `yaml:"history_retention" mapstructure:"history_retention"`
+	// HistoryMaskIPs records IPv4 addresses truncated to their /24 (IPv6 to their /48) instead of in full
+	HistoryMaskIPs bool `yaml:"history_mask_ips" mapstructure:"history_mask_ips"`
+	// ExemptPaths extends the default list of paths that never create or use a session (glob patterns)
+	ExemptPaths []string `yaml:"exempt_paths" mapstructure:"exempt_paths"`
+}
+
+// sessionRoles are the token roles accepted as ttl_by_role and max_lifetime_by_role keys
+var sessionRoles = []string{"user", "admin"}
+
+// Session limit scopes
+const (
+	SessionScopeGlobal = "global" // max_concurrent across all tokens
+	SessionScopeToken  = "token"  // max_per_token (or the token's max_sessions) per token
+	SessionScopeBoth   = "both"   // both limits must hold
+)
+
+// ProxyConfig holds Claude API proxy routing configuration
+type ProxyConfig struct {
+	// AllowedPaths extends the default allow-list of proxied paths (glob patterns, "/**" suffix for sub-paths)
+	AllowedPaths []string `yaml:"allowed_paths" mapstructure:"allowed_paths"`
+	// UnsupportedPaths extends the default list of OpenAI endpoints answered locally with a 404
+	// (embeddings, legacy completions...) instead of being proxied
+	UnsupportedPaths []string `yaml:"unsupported_paths" mapstructure:"unsupported_paths"`
+	// RequestShaping transforms POST /v1/messages bodies before they are forwarded
+	RequestShaping RequestShapingConfig `yaml:"request_shaping" mapstructure:"request_shaping"`
+	// ModelAliases are appended to GET /v1/models responses (live or cached) when not already listed
+	ModelAliases []ModelAliasConfig `yaml:"model_aliases" mapstructure:"model_aliases"`
+	// WindowAware selects the account with the least usage in its current 5-hour window instead of round-robin
+	WindowAware bool `yaml:"window_aware" mapstructure:"window_aware"`
+	// FilterModels limits GET /v1/models lists to the models some account serves (accounts' supported_models)
+	FilterModels bool `yaml:"filter_models" mapstructure:"filter_models"`
+	// NormalizeMessages repairs invalid POST /v1/messages sequences (same-role runs, empty or orphaned blocks)
+	NormalizeMessages bool `yaml:"normalize_messages" mapstructure:"normalize_messages"`
+	// ThinkingFix handles requests whose max_tokens doesn't exceed thinking.budget_tokens: autofix (default),
+	// reject or off
+	ThinkingFix string `yaml:"thinking_fix" mapstructure:"thinking_fix"`
+	// CircuitBreaker temporarily excludes accounts whose recent requests are too slow or failing
+	CircuitBreaker CircuitBreakerConfig `yaml:"circuit_breaker" mapstructure:"circuit_breaker"`
+	// ForwardedHeaders adds Via and X-Forwarded-For (the client's address) to upstream requests
+	ForwardedHeaders bool `yaml:"forwarded_headers" mapstructure:"forwarded_headers"`
+	// BatchTTL is how long a message batch stays pinned to the account that created it (default 29 days,
+	// as long as Claude API keeps batch results)
+	BatchTTL time.Duration `yaml:"batch_ttl" mapstructure:"batch_ttl"`
+	// FileTTL is how long an uploaded file stays pinned to the account it was uploaded to (default 30 days;
+	// Claude API keeps files until deleted, deletions through the proxy drop the mapping at once)
+	FileTTL time.Duration `yaml:"file_ttl" mapstructure:"file_ttl"`
+	// MaxQueueWait lets requests wait this long for a rate limited or over-quota account to recover instead of
+	// failing at once (0 = no queueing)
+	MaxQueueWait time.Duration `yaml:"max_queue_wait" mapstructure:"max_queue_wait"`
+	// MaxQueueSize bounds the requests waiting at once (default 100)
+	MaxQueueSize int `yaml:"max_queue_size" mapstructure:"max_queue_size"`
+	// AutoDisableFailures is how many consecutive upstream 403s disable an account (default 5, -1 = never)
+	AutoDisableFailures int `yaml:"auto_disable_failures" mapstructure:"auto_disable_failures"`
+	// RequestHistorySize is how many recent requests are kept in memory per account (default 50)
+	RequestHistorySize int `yaml:"request_history_size" mapstructure:"request_history_size"`
+	// ShadowSamplePercent is the share (0-100) of non-streaming POST /v1/messages requests mirrored to every
+	// shadow account, responses discarded (0 = no mirroring)
+	ShadowSamplePercent float64 `yaml:"shadow_sample_percent" mapstructure:"shadow_sample_percent"`
+	// CanaryPercent is the share (0-100) of sessions served by accounts flagged canary, the others by the rest
+	// of the pool (0 = canary flags are ignored)
+	CanaryPercent float64 `yaml:"canary_percent" mapstructure:"canary_percent"`
+	// Warmup primes the prompt cache of accounts when they become available (startup, recovery, new accounts)
+	Warmup WarmupConfig `yaml:"warmup" mapstructure:"warmup"`
+}
+
+// Policies for requests whose max_tokens doesn't exceed thinking.budget_tokens
+const (
+	ThinkingFixAutofix = "autofix" // raise max_tokens above the budget
+	ThinkingFixReject  = "reject"  // answer 400 without forwarding
+	ThinkingFixOff     = "off"     // forward untouched
+)
+
+// WarmupConfig holds the priming request sent through accounts that become available
+type WarmupConfig struct {
+	Enabled    bool          `yaml:"enabled"     mapstructure:"enabled"`
+	PromptFile string        `yaml:"prompt_file" mapstructure:"prompt_file"` // System prompt to cache (required when enabled)
+	Model      string        `yaml:"model"       mapstructure:"model"`       // Model of the priming request
+	Interval   time.Duration `yaml:"interval"    mapstructure:"interval"`    // How often accounts are checked
+}
+
+// CircuitBreakerConfig holds the per-account latency/error circuit breaker thresholds
+type CircuitBreakerConfig struct {
+	Enabled       bool          `yaml:"enabled"         mapstructure:"enabled"`
+	Window        time.Duration `yaml:"window"          mapstructure:"window"`          // Rolling window of requests evaluated
+	MinRequests   int           `yaml:"min_requests"    mapstructure:"min_requests"`    // Requests in the window before the breaker can open
+	MaxP95Latency time.Duration `yaml:"max_p95_latency" mapstructure:"max_p95_latency"` // Opens when p95 time-to-response exceeds this
+	MaxErrorRate  float64       `yaml:"max_error_rate"  mapstructure:"max_error_rate"`  // Opens when this fraction of requests failed (0-1)
+	Cooldown      time.Duration `yaml:"cooldown"        mapstructure:"cooldown"`        // Time open before a probe request is let through
+}
+
+// ModelAliasConfig is a custom entry for the GET /v1/models list
+type ModelAliasConfig struct {
+	ID          string `yaml:"id"           mapstructure:"id"`
+	DisplayName string `yaml:"display_name" mapstructure:"display_name"` // Defaults to the ID
+}
+
+// RequestShapingConfig holds transformations applied to every message request
+type RequestShapingConfig struct {
+	SystemPrompt     string   `yaml:"system_prompt"      mapstructure:"system_prompt"`      // Prepended to the request's system prompt
+	DropFields       []string `yaml:"drop_fields"        mapstructure:"drop_fields"`        // Fields to remove (dotted paths, e.g. metadata.user_id)
+	DefaultModel     string   `yaml:"default_model"      mapstructure:"default_model"`      // Used when the request has no model
+	DefaultMaxTokens int      `yaml:"default_max_tokens" mapstructure:"default_max_tokens"` // Used when the request has no max_tokens
+}
+
+// StatsConfig holds per-token usage statistics configuration
+type StatsConfig struct {
+	Resolution time.Duration `yaml:"resolution" mapstructure:"resolution"` // Smallest bucket size
+	Retention  time.Duration `yaml:"retention"  mapstructure:"retention"`  // Buckets older than this are dropped
+}
+
+// PrivacyConfig holds what identifying data the proxy keeps
+type PrivacyConfig struct {
+	HashUserIDs bool `yaml:"hash_user_ids" mapstructure:"hash_user_ids"` // Store end user IDs (metadata.user_id) as SHA-256 hashes
+}
+
+// WebhooksConfig holds the outbound usage event webhook (events are signed with HMAC-SHA256 of Secret)
+type WebhooksConfig struct {
+	Enabled    bool          `yaml:"enabled"     mapstructure:"enabled"`
+	URL        string        `yaml:"url"         mapstructure:"url"`
+	Secret     string        `yaml:"secret"      mapstructure:"secret"`
+	Events     []string      `yaml:"events"      mapstructure:"events"`      // Event types to deliver (default: all)
+	QueueSize  int           `yaml:"queue_size"  mapstructure:"queue_size"`  // Pending events before new ones are dropped
+	Workers    int           `yaml:"workers"     mapstructure:"workers"`     // Concurrent deliveries
+	MaxRetries int           `yaml:"max_retries" mapstructure:"max_retries"` // Retries after a failed delivery attempt
+	RetryDelay time.Duration `yaml:"retry_delay" mapstructure:"retry_delay"` // First retry delay, doubled on each retry
+	Timeout    time.Duration `yaml:"timeout"     mapstructure:"timeout"`     // Per delivery attempt
 }
 
 func LoadConfig(configPath string) (*Config, error) {
@@ -87,18 +445,30 @@ func LoadConfig(configPath string) (*Config, error) {
 		fmt.Printf("Warning: failed to load .env file: %v\n", err)
 	}
 
-	// Configure viper for YAML file
-	v.SetConfigFile(configPath)
-	v.SetConfigType("yaml")
-
-	// Read YAML configuration file
-	if err := v.ReadInConfig(); err != nil {
-		return nil, fmt.Errorf("failed to read config file: %w", err)
+	// Read the configuration from CLAUDE_PROXY_CONFIG_JSON, else the YAML file; without either, the defaults
+	// and environment variables make up the whole configuration
+	if configJSON := os.Getenv(ConfigJSONEnv); configJSON != "" {
+		v.SetConfigType("json")
+		if err := v.ReadConfig(strings.NewReader(configJSON)); err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", ConfigJSONEnv, err)
+		}
+	} else {
+		v.SetConfigFile(configPath)
+		v.SetConfigType("yaml")
+		if err := v.ReadInConfig(); err != nil {
+			if !errors.Is(err, fs.ErrNotExist) {
+				return nil, fmt.Errorf("failed to read config file: %w", err)
+			}
+			fmt.Printf("Notice: config file %s not found, using defaults and environment variables\n", configPath)
+		}
 	}
 
-	// Configure environment variable support
+	// Configure environment variable support, for every setting whether or not the file has it
 	v.SetEnvKeyReplacer(strings.NewReplacer(".", "__"))
 	v.AutomaticEnv()
+	if err := bindEnvKeys(v, reflect.TypeOf(Config{}), ""); err != nil {
+		return nil, fmt.Errorf("failed to bind environment variables: %w", err)
+	}
 
 	var config Config
 
@@ -106,6 +476,13 @@ func LoadConfig(configPath string) (*Config, error) {
 	if err := v.Unmarshal(&config); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal config: %w", err)
 	}
+	config.sources = configSources(v)
+
+	// Resolve ${env:...}, ${file:...} and ${exec:...} values (file or environment) before defaults and validation
+	config.indirect = make(map[string]bool)
+	if err := resolveIndirections(reflect.ValueOf(&config).Elem(), "", config.indirect); err != nil {
+		return nil, fmt.Errorf("failed to resolve config value: %w", err)
+	}
 
 	// Set default logger config if not specified
 	if config.Logger.Level == "" {
@@ -114,6 +491,32 @@ func LoadConfig(configPath string) (*Config, error) {
 	if config.Logger.Format == "" {
 		config.Logger.Format = "text"
 	}
+	if config.Logger.LogBodies == "" {
+		config.Logger.LogBodies = LogBodiesMetadata
+	}
+	switch config.Logger.LogBodies {
+	case LogBodiesNone, LogBodiesMetadata, LogBodiesRedacted, LogBodiesFull:
+	default:
+		return nil, fmt.Errorf(
+			"invalid logger.log_bodies %q: expected none, metadata, redacted or full", config.Logger.LogBodies,
+		)
+	}
+	if config.Logger.BodySamplePercent < 0 || config.Logger.BodySamplePercent > 100 {
+		return nil, fmt.Errorf(
+			"logger.body_sample_percent must be between 0 and 100, got %v", config.Logger.BodySamplePercent,
+		)
+	}
+	if config.Logger.BodySamplePercent == 0 {
+		config.Logger.BodySamplePercent = 100
+	}
+
+	// Set default auth config if not specified
+	if config.Auth.ReadOnlyAPIKey != "" && config.Auth.ReadOnlyAPIKey == config.Auth.APIKey {
+		return nil, fmt.Errorf("auth.readonly_api_key must differ from auth.api_key")
+	}
+	if config.Auth.KeyRotationGrace == 0 {
+		config.Auth.KeyRotationGrace = 24 * time.Hour
+	}
 
 	// Set default OAuth config if not specified
 	if config.OAuth.AuthorizeURL == "" {
@@ -123,7 +526,35 @@ func LoadConfig(configPath string) (*Config, error) {
 		config.OAuth.TokenURL = "https://api.claude.ai/oauth/token"
 	}
 	if config.OAuth.RedirectURI == "" {
-		config.OAuth.RedirectURI = fmt.Sprintf("http://%s:%d/oauth/callback", config.Server.Host, config.Server.Port)
+		config.OAuth.RedirectURI = config.Server.AdminBaseURL() + "/oauth/callback"
+	}
+	if config.OAuth.InviteTTL < 0 {
+		return nil, fmt.Errorf("oauth.invite_ttl must not be negative")
+	}
+	if config.OAuth.InviteTTL == 0 {
+		config.OAuth.InviteTTL = 24 * time.Hour
+	}
+	if config.OAuth.DeviceTTL < 0 {
+		return nil, fmt.Errorf("oauth.device_ttl must not be negative")
+	}
+	if config.OAuth.DeviceTTL == 0 {
+		config.OAuth.DeviceTTL = 15 * time.Minute
+	}
+
+	if config.Accounts.NewAccountCooldown < 0 {
+		return nil, fmt.Errorf("accounts.new_account_cooldown must not be negative")
+	}
+	if config.Accounts.UpstreamUsageInterval == 0 {
+		config.Accounts.UpstreamUsageInterval = 6 * time.Hour
+	}
+	if config.Accounts.UpstreamUsageInterval < time.Minute {
+		return nil, fmt.Errorf("accounts.upstream_usage_interval must be at least 1m")
+	}
+	if config.Accounts.UpstreamUsageDays == 0 {
+		config.Accounts.UpstreamUsageDays = 7
+	}
+	if config.Accounts.UpstreamUsageDays < 1 || config.Accounts.UpstreamUsageDays > 31 {
+		return nil, fmt.Errorf("accounts.upstream_usage_days must be between 1 and 31")
 	}
 	if config.OAuth.Scope == "" {
 		config.OAuth.Scope = "user:profile user:inference"
@@ -133,11 +564,41 @@ func LoadConfig(configPath string) (*Config, error) {
 	if config.Claude.BaseURL == "" {
 		config.Claude.BaseURL = "https://api.claude.ai"
 	}
+	if config.OAuth.OrganizationsURL == "" {
+		config.OAuth.OrganizationsURL = strings.TrimSuffix(config.Claude.BaseURL, "/") + "/api/organizations"
+	}
 
 	// Set default storage config if not specified
 	if config.Storage.DataFolder == "" {
 		config.Storage.DataFolder = "~/.claude-proxy/data"
 	}
+	if config.Storage.BackupFolder == "" {
+		config.Storage.BackupFolder = "~/.claude-proxy/backups"
+	}
+	if config.Storage.BackupInterval == 0 {
+		config.Storage.BackupInterval = 6 * time.Hour
+	}
+	if config.Storage.BackupKeep == 0 {
+		config.Storage.BackupKeep = 7
+	}
+	limits := &config.Storage.CacheSoftLimits
+	for _, limit := range []struct {
+		name  string
+		value *int
+		def   int
+	}{
+		{"accounts", &limits.Accounts, 500},
+		{"tokens", &limits.Tokens, 10000},
+		{"sessions", &limits.Sessions, 50000},
+		{"usage_buckets", &limits.UsageBuckets, 500000},
+	} {
+		if *limit.value < -1 {
+			return nil, fmt.Errorf("storage.cache_soft_limits.%s must be positive, or -1 for no limit", limit.name)
+		}
+		if *limit.value == 0 {
+			*limit.value = limit.def
+		}
+	}
 
 	// Set default retry config if not specified
 	if config.Retry.MaxRetries == 0 {
@@ -147,21 +608,299 @@ func LoadConfig(configPath string) (*Config, error) {
 		config.Retry.RetryDelay = 1 * time.Second
 	}
 
+	// Set default token refresh config if not specified
+	if config.Refresh.MaxConcurrent == 0 {
+		config.Refresh.MaxConcurrent = 1
+	}
+	if config.Refresh.Spread == 0 {
+		config.Refresh.Spread = 5 * time.Minute
+	}
+	if config.Refresh.Jitter == 0 {
+		config.Refresh.Jitter = 5 * time.Second
+	}
+	if config.Refresh.AlertFailures == 0 {
+		config.Refresh.AlertFailures = 3
+	}
+	if config.Refresh.MaxConcurrent < 0 || config.Refresh.Spread < 0 || config.Refresh.Jitter < 0 ||
+		config.Refresh.AlertFailures < 0 {
+		return nil, fmt.Errorf("refresh settings must not be negative")
+	}
+	if config.Refresh.Spread+config.Refresh.Jitter >= time.Hour {
+		return nil, fmt.Errorf("refresh.spread plus refresh.jitter must be under the 1h refresh interval")
+	}
+
 	// Set default server config if not specified
 	if config.Server.RequestTimeout == 0 {
 		config.Server.RequestTimeout = 5 * time.Minute // 5 minutes for LLM API requests
 	}
+	if config.Server.SocketMode == "" {
+		config.Server.SocketMode = "0660"
+	}
+	config.Server.BasePath = normalizeBasePath(config.Server.BasePath)
+	if config.Server.Compression.MinSize == 0 {
+		config.Server.Compression.MinSize = 1024
+	}
+	if config.Server.PublicRateLimit.RequestsPerMinute == 0 {
+		config.Server.PublicRateLimit.RequestsPerMinute = 30
+	}
+	if config.Server.PublicRateLimit.Burst == 0 {
+		config.Server.PublicRateLimit.Burst = 10
+	}
+	if config.Server.PublicRateLimit.LoginLockout == 0 {
+		config.Server.PublicRateLimit.LoginLockout = time.Minute
+	}
+	if config.Server.PublicRateLimit.LoginMaxLockout == 0 {
+		config.Server.PublicRateLimit.LoginMaxLockout = time.Hour
+	}
+	if config.Server.PublicRateLimit.IdleTimeout == 0 {
+		config.Server.PublicRateLimit.IdleTimeout = 10 * time.Minute
+	}
+	if err := config.Server.validate(); err != nil {
+		return nil, err
+	}
+	if config.Claude.Timeout == 0 {
+		config.Claude.Timeout = config.Server.RequestTimeout
+	}
+	if config.Claude.Timeout < 0 || config.Claude.StreamTimeout < 0 {
+		return nil, fmt.Errorf("claude.timeout and claude.stream_timeout must not be negative")
+	}
+	if config.Claude.Headers.UserAgent == "" {
+		config.Claude.Headers.UserAgent = DefaultClaudeUserAgent
+	}
+	if config.Claude.Headers.XApp == "" {
+		config.Claude.Headers.XApp = "cli"
+	}
+	if err := httpproxy.ValidateStaticHeaders(config.Claude.Headers.Map()); err != nil {
+		return nil, fmt.Errorf("invalid claude.headers: %w", err)
+	}
 
 	// Set default session config if not specified
 	if config.Session.MaxConcurrent == 0 {
 		config.Session.MaxConcurrent = 3
 	}
+	if config.Session.Scope == "" {
+		config.Session.Scope = SessionScopeGlobal
+	}
+	switch config.Session.Scope {
+	case SessionScopeGlobal, SessionScopeToken, SessionScopeBoth:
+	default:
+		return nil, fmt.Errorf("invalid session.scope %q: expected global, token or both", config.Session.Scope)
+	}
+	if config.Session.MaxPerToken == 0 {
+		config.Session.MaxPerToken = config.Session.MaxConcurrent
+	}
 	if config.Session.SessionTTL == 0 {
 		config.Session.SessionTTL = 5 * time.Minute
 	}
+	if config.Session.SessionTTL < 0 {
+		return nil, fmt.Errorf("session.session_ttl must not be negative")
+	}
+	for role, ttl := range config.Session.TTLByRole {
+		if !slices.Contains(sessionRoles, role) {
+			return nil, fmt.Errorf("invalid session.ttl_by_role key %q: expected user or admin", role)
+		}
+		if ttl <= 0 {
+			return nil, fmt.Errorf("session.ttl_by_role.%s must be positive", role)
+		}
+	}
+	if config.Session.MaxLifetime < 0 {
+		return nil, fmt.Errorf("session.max_lifetime must not be negative")
+	}
+	for role, lifetime := range config.Session.MaxLifetimeByRole {
+		if !slices.Contains(sessionRoles, role) {
+			return nil, fmt.Errorf("invalid session.max_lifetime_by_role key %q: expected user or admin", role)
+		}
+		if lifetime < 0 {
+			return nil, fmt.Errorf("session.max_lifetime_by_role.%s must not be negative", role)
+		}
+	}
 	if config.Session.CleanupInterval == 0 {
 		config.Session.CleanupInterval = 1 * time.Minute
 	}
+	if config.Session.Retention == 0 {
+		config.Session.Retention = 24 * time.Hour
+	}
+	if config.Session.Retention < 0 {
+		return nil, fmt.Errorf("session.retention must not be negative")
+	}
+	if config.Session.HistoryRetention == 0 {
+		config.Session.HistoryRetention = 365 * 24 * time.Hour
+	}
+	if config.Session.HistoryRetention < 0 {
+		return nil, fmt.Errorf("session.history_retention must not be negative")
+	}
+
+	// Batch results stay downloadable for 29 days after creation
+	if config.Proxy.BatchTTL == 0 {
+		config.Proxy.BatchTTL = 29 * 24 * time.Hour
+	}
+	if config.Proxy.BatchTTL < 0 {
+		return nil, fmt.Errorf("proxy.batch_ttl must not be negative")
+	}
+
+	// Uploaded files are kept by Claude API until deleted
+	if config.Proxy.FileTTL == 0 {
+		config.Proxy.FileTTL = 30 * 24 * time.Hour
+	}
+	if config.Proxy.FileTTL < 0 {
+		return nil, fmt.Errorf("proxy.file_ttl must not be negative")
+	}
+
+	// Requests wait for an account only when a maximum wait is set
+	if config.Proxy.MaxQueueWait < 0 || config.Proxy.MaxQueueSize < 0 {
+		return nil, fmt.Errorf("proxy.max_queue_wait and proxy.max_queue_size must not be negative")
+	}
+	if config.Proxy.MaxQueueSize == 0 {
+		config.Proxy.MaxQueueSize = 100
+	}
+	if config.Proxy.AutoDisableFailures == 0 {
+		config.Proxy.AutoDisableFailures = 5
+	}
+	if config.Proxy.AutoDisableFailures < -1 {
+		return nil, fmt.Errorf("proxy.auto_disable_failures must be positive, or -1 to never disable accounts")
+	}
+	if config.Proxy.RequestHistorySize == 0 {
+		config.Proxy.RequestHistorySize = 50
+	}
+	if config.Proxy.RequestHistorySize < 0 {
+		return nil, fmt.Errorf("proxy.request_history_size must not be negative")
+	}
+	if config.Proxy.ShadowSamplePercent < 0 || config.Proxy.ShadowSamplePercent > 100 {
+		return nil, fmt.Errorf(
+			"proxy.shadow_sample_percent must be between 0 and 100, got %v", config.Proxy.ShadowSamplePercent,
+		)
+	}
+	if config.Proxy.CanaryPercent < 0 || config.Proxy.CanaryPercent > 100 {
+		return nil, fmt.Errorf("proxy.canary_percent must be between 0 and 100, got %v", config.Proxy.CanaryPercent)
+	}
+
+	if config.Proxy.ThinkingFix == "" {
+		config.Proxy.ThinkingFix = ThinkingFixAutofix
+	}
+	switch config.Proxy.ThinkingFix {
+	case ThinkingFixAutofix, ThinkingFixReject, ThinkingFixOff:
+	default:
+		return nil, fmt.Errorf(
+			"invalid proxy.thinking_fix %q: expected autofix, reject or off", config.Proxy.ThinkingFix,
+		)
+	}
+
+	// Validate prompt cache warmup and set defaults
+	if config.Proxy.Warmup.Enabled && config.Proxy.Warmup.PromptFile == "" {
+		return nil, fmt.Errorf("proxy.warmup.prompt_file is required when warmup is enabled")
+	}
+	if config.Proxy.Warmup.Model == "" {
+		config.Proxy.Warmup.Model = "claude-sonnet-4-5"
+	}
+	if config.Proxy.Warmup.Interval == 0 {
+		config.Proxy.Warmup.Interval = time.Minute
+	}
+	if config.Proxy.Warmup.Interval < 0 {
+		return nil, fmt.Errorf("proxy.warmup.interval must not be negative")
+	}
+
+	// Set default circuit breaker config if not specified
+	if config.Proxy.CircuitBreaker.Window == 0 {
+		config.Proxy.CircuitBreaker.Window = 5 * time.Minute
+	}
+	if config.Proxy.CircuitBreaker.MinRequests == 0 {
+		config.Proxy.CircuitBreaker.MinRequests = 10
+	}
+	if config.Proxy.CircuitBreaker.MaxP95Latency == 0 {
+		config.Proxy.CircuitBreaker.MaxP95Latency = 60 * time.Second
+	}
+	if config.Proxy.CircuitBreaker.MaxErrorRate == 0 {
+		config.Proxy.CircuitBreaker.MaxErrorRate = 0.5
+	}
+	if config.Proxy.CircuitBreaker.MaxErrorRate < 0 || config.Proxy.CircuitBreaker.MaxErrorRate > 1 {
+		return nil, fmt.Errorf(
+			"invalid proxy.circuit_breaker.max_error_rate %v: expected a fraction between 0 and 1",
+			config.Proxy.CircuitBreaker.MaxErrorRate,
+		)
+	}
+	if config.Proxy.CircuitBreaker.Cooldown == 0 {
+		config.Proxy.CircuitBreaker.Cooldown = 2 * time.Minute
+	}
+
+	// Set default stats config if not specified
+	if config.Stats.Resolution == 0 {
+		config.Stats.Resolution = 5 * time.Minute
+	}
+	if config.Stats.Retention == 0 {
+		config.Stats.Retention = 7 * 24 * time.Hour
+	}
+
+	// Validate the webhook target and set defaults
+	if config.Webhooks.Enabled {
+		u, err := url.Parse(config.Webhooks.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("webhooks.url must be an http:// or https:// URL, got %q", config.Webhooks.URL)
+		}
+		if config.Webhooks.Secret == "" {
+			return nil, fmt.Errorf("webhooks.secret is required when webhooks are enabled")
+		}
+	}
+	if config.Webhooks.QueueSize == 0 {
+		config.Webhooks.QueueSize = 1000
+	}
+	if config.Webhooks.Workers == 0 {
+		config.Webhooks.Workers = 2
+	}
+	if config.Webhooks.MaxRetries == 0 {
+		config.Webhooks.MaxRetries = 5
+	}
+	if config.Webhooks.RetryDelay == 0 {
+		config.Webhooks.RetryDelay = time.Second
+	}
+	if config.Webhooks.Timeout == 0 {
+		config.Webhooks.Timeout = 10 * time.Second
+	}
+	if config.Webhooks.QueueSize < 0 || config.Webhooks.Workers < 0 || config.Webhooks.MaxRetries < 0 {
+		return nil, fmt.Errorf("webhooks.queue_size, workers and max_retries must not be negative")
+	}
+
+	// Set default clock check config if not specified
+	if config.Clock.MaxSkew == 0 {
+		config.Clock.MaxSkew = 30 * time.Second
+	}
+	if config.Clock.CheckInterval == 0 {
+		config.Clock.CheckInterval = 15 * time.Minute
+	}
+	if config.Clock.AuthFailureWindow == 0 {
+		config.Clock.AuthFailureWindow = 5 * time.Minute
+	}
+	if config.Clock.MaxSkew < 0 || config.Clock.CheckInterval < 0 || config.Clock.AuthFailureWindow < 0 {
+		return nil, fmt.Errorf("clock.max_skew, check_interval and auth_failure_window must not be negative")
+	}
+	if config.Clock.StartupCheck == "" {
+		config.Clock.StartupCheck = ClockStartupBlock
+	}
+	switch config.Clock.StartupCheck {
+	case ClockStartupBlock, ClockStartupWarn, ClockStartupOff:
+	default:
+		return nil, fmt.Errorf(
+			"invalid clock.startup_check %q: expected block, warn or off", config.Clock.StartupCheck,
+		)
+	}
+
+	// Set default alert evaluation config if not specified
+	if config.Alerts.Interval == 0 {
+		config.Alerts.Interval = 30 * time.Second
+	}
+	if err := config.Alerts.validate(); err != nil {
+		return nil, err
+	}
+
+	// Set default standby polling config if not specified
+	if config.Standby.Interval == 0 {
+		config.Standby.Interval = 30 * time.Second
+	}
+	if config.Standby.Timeout == 0 {
+		config.Standby.Timeout = 30 * time.Second
+	}
+	if err := config.Standby.validate(); err != nil {
+		return nil, err
+	}
 
 	return &config, nil
 }
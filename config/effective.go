@@ -0,0 +1,137 @@
+package config
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// RedactedValue replaces set secrets in the effective configuration
+const RedactedValue = "***"
+
+// Where the settings of a top-level section come from
+const (
+	SourceDefault = "default" // not in the config file: built-in defaults only
+	SourceFile    = "file"    // set in the config file
+	SourceEnv     = "env"     // at least one setting overridden by an environment variable
+)
+
+// secretKeys are the settings Effective never shows (dotted YAML paths)
+var secretKeys = map[string]bool{
+	"auth.api_key":          true,
+	"auth.readonly_api_key": true,
+	"telegram.bot_token":    true,
+	"webhooks.secret":       true,
+	"standby.api_key":       true,
+	"standby.passphrase":    true,
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// Effective returns the resolved configuration (defaults applied, environment overrides included) as nested
+// maps keyed like the YAML file, with durations rendered as strings ("1m0s") and set secrets, and settings
+// resolved from ${env:...}, ${file:...} or ${exec:...}, replaced by RedactedValue
+func (c *Config) Effective() map[string]any {
+	hidden := maps.Clone(secretKeys)
+	maps.Copy(hidden, c.indirect)
+	return effectiveValue(reflect.ValueOf(*c), "", hidden).(map[string]any)
+}
+
+// Sources returns where each top-level section comes from: SourceDefault, SourceFile or SourceEnv
+func (c *Config) Sources() map[string]string {
+	return maps.Clone(c.sources)
+}
+
+// effectiveValue converts a configuration value to its JSON-ready form; path is its dotted YAML path
+// and hidden the paths whose set values are redacted
+func effectiveValue(v reflect.Value, path string, hidden map[string]bool) any {
+	if v.Type() == durationType {
+		return time.Duration(v.Int()).String()
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		out := make(map[string]any, v.NumField())
+		for i := range v.NumField() {
+			field := v.Type().Field(i)
+			name, _, _ := strings.Cut(field.Tag.Get("mapstructure"), ",")
+			if !field.IsExported() || name == "" || name == "-" {
+				continue
+			}
+			key := joinKey(path, name)
+			if hidden[key] {
+				out[name] = ""
+				if !v.Field(i).IsZero() {
+					out[name] = RedactedValue
+				}
+				continue
+			}
+			out[name] = effectiveValue(v.Field(i), key, hidden)
+		}
+		return out
+	case reflect.Map:
+		out := make(map[string]any, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			key := fmt.Sprint(iter.Key().Interface())
+			if hidden[joinKey(path, key)] {
+				out[key] = RedactedValue
+				continue
+			}
+			out[key] = effectiveValue(iter.Value(), joinKey(path, key), hidden)
+		}
+		return out
+	case reflect.Slice:
+		out := make([]any, v.Len())
+		for i := range v.Len() {
+			out[i] = effectiveValue(v.Index(i), path, hidden)
+		}
+		return out
+	default:
+		return v.Interface()
+	}
+}
+
+// joinKey appends a key to a dotted path
+func joinKey(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// configSources determines where each top-level section of a loaded configuration comes from
+// The file is the config file or CLAUDE_PROXY_CONFIG_JSON; every setting is bound to its environment variable
+func configSources(v *viper.Viper) map[string]string {
+	keys := v.AllKeys()
+	sources := make(map[string]string)
+
+	configType := reflect.TypeOf(Config{})
+	for i := range configType.NumField() {
+		section, _, _ := strings.Cut(configType.Field(i).Tag.Get("mapstructure"), ",")
+		if section == "" || section == "-" {
+			continue
+		}
+
+		source := SourceDefault
+		if v.InConfig(section) {
+			source = SourceFile
+		}
+		for _, key := range keys {
+			if key != section && !strings.HasPrefix(key, section+".") {
+				continue
+			}
+			if _, ok := os.LookupEnv(EnvName(key)); ok {
+				source = SourceEnv
+				break
+			}
+		}
+		sources[section] = source
+	}
+	return sources
+}
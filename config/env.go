@@ -0,0 +1,42 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// ConfigJSONEnv holds the whole configuration as one JSON document, read instead of the config file
+const ConfigJSONEnv = "CLAUDE_PROXY_CONFIG_JSON"
+
+// EnvName returns the environment variable overriding a setting: its dotted YAML path in upper case with "__"
+// for nesting (proxy.circuit_breaker.enabled -> PROXY__CIRCUIT_BREAKER__ENABLED)
+func EnvName(key string) string {
+	return strings.ToUpper(strings.ReplaceAll(key, ".", "__"))
+}
+
+// bindEnvKeys binds every setting of the Config struct to its environment variable
+// AutomaticEnv alone only overrides the settings viper already knows about, the ones in the config file, so
+// settings left out of the file (or running without one) could not be set from the environment.
+func bindEnvKeys(v *viper.Viper, t reflect.Type, path string) error {
+	for i := range t.NumField() {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("mapstructure"), ",")
+		if !field.IsExported() || name == "" || name == "-" {
+			continue
+		}
+
+		key := joinKey(path, name)
+		if field.Type.Kind() == reflect.Struct {
+			if err := bindEnvKeys(v, field.Type, key); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := v.BindEnv(key, EnvName(key)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
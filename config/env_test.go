@@ -0,0 +1,216 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// envField is a setting of the Config struct with the environment variable overriding it
+type envField struct {
+	key   string
+	index []int // Field index in Config, for reflect.Value.FieldByIndex
+	typ   reflect.Type
+}
+
+// envFields lists the settings of t, walking nested structs like bindEnvKeys
+func envFields(t reflect.Type, path string, index []int) []envField {
+	var fields []envField
+	for i := range t.NumField() {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("mapstructure"), ",")
+		if !field.IsExported() || name == "" || name == "-" {
+			continue
+		}
+
+		key := joinKey(path, name)
+		fieldIndex := append(append([]int{}, index...), i)
+		if field.Type.Kind() == reflect.Struct {
+			fields = append(fields, envFields(field.Type, key, fieldIndex)...)
+			continue
+		}
+		fields = append(fields, envField{key: key, index: fieldIndex, typ: field.Type})
+	}
+	return fields
+}
+
+// envValue returns the environment value setting a field of type typ, with the value it decodes to;
+// ok is false for maps and lists of structs, which a single variable can't hold
+func envValue(key string, typ reflect.Type) (env string, want any, ok bool) {
+	if typ == reflect.TypeOf(time.Duration(0)) {
+		return "7s", 7 * time.Second, true
+	}
+	switch typ.Kind() {
+	case reflect.String:
+		return "env-" + key, "env-" + key, true
+	case reflect.Bool:
+		return "true", true, true
+	case reflect.Int:
+		return "7", 7, true
+	case reflect.Float64:
+		return "12.5", 12.5, true
+	case reflect.Slice:
+		if typ.Elem().Kind() == reflect.String {
+			return "a,b", []string{"a", "b"}, true
+		}
+	}
+	return "", nil, false
+}
+
+func TestBindEnvKeysCoversEveryField(t *testing.T) {
+	fields := envFields(reflect.TypeOf(Config{}), "", nil)
+	if len(fields) < 100 {
+		t.Fatalf("found %d settings, the walk is missing some", len(fields))
+	}
+
+	var composite []envField
+	for _, field := range fields {
+		env, _, ok := envValue(field.key, field.typ)
+		if !ok {
+			composite = append(composite, field)
+			continue
+		}
+		t.Setenv(EnvName(field.key), env)
+	}
+
+	v := viper.New()
+	if err := bindEnvKeys(v, reflect.TypeOf(Config{}), ""); err != nil {
+		t.Fatal(err)
+	}
+	var cfg Config
+	if err := v.Unmarshal(&cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	value := reflect.ValueOf(cfg)
+	for _, field := range fields {
+		_, want, ok := envValue(field.key, field.typ)
+		if !ok {
+			continue
+		}
+		if got := value.FieldByIndex(field.index).Interface(); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s from %s = %v, want %v", field.key, EnvName(field.key), got, want)
+		}
+	}
+
+	// Maps and lists of structs come from the config file or CLAUDE_PROXY_CONFIG_JSON, but their variables
+	// are still bound
+	for _, field := range composite {
+		t.Setenv(EnvName(field.key), "bound")
+		if got := v.Get(field.key); got != "bound" {
+			t.Errorf("%s is not bound to %s (got %v)", field.key, EnvName(field.key), got)
+		}
+	}
+}
+
+func TestEnvName(t *testing.T) {
+	tests := []struct {
+		key  string
+		want string
+	}{
+		{"server.port", "SERVER__PORT"},
+		{"proxy.circuit_breaker.enabled", "PROXY__CIRCUIT_BREAKER__ENABLED"},
+		{"storage.cache_soft_limits.usage_buckets", "STORAGE__CACHE_SOFT_LIMITS__USAGE_BUCKETS"},
+	}
+	for _, tt := range tests {
+		if got := EnvName(tt.key); got != tt.want {
+			t.Errorf("EnvName(%q) = %q, want %q", tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestLoadConfigSources(t *testing.T) {
+	const fileConfig = "server:\n  port: 8000\nlogger:\n  level: warn\n"
+
+	tests := []struct {
+		name        string
+		file        string // Content of config.yaml; empty for no file
+		configJSON  string
+		env         map[string]string
+		wantPort    int
+		wantLevel   string
+		wantSources map[string]string
+	}{
+		{
+			name:        "config file",
+			file:        fileConfig,
+			wantPort:    8000,
+			wantLevel:   "warn",
+			wantSources: map[string]string{"server": SourceFile, "logger": SourceFile, "proxy": SourceDefault},
+		},
+		{
+			name:        "environment over config file",
+			file:        fileConfig,
+			env:         map[string]string{"LOGGER__LEVEL": "debug", "PROXY__WINDOW_AWARE": "true"},
+			wantPort:    8000,
+			wantLevel:   "debug",
+			wantSources: map[string]string{"server": SourceFile, "logger": SourceEnv, "proxy": SourceEnv},
+		},
+		{
+			name:        "config JSON instead of config file",
+			file:        fileConfig,
+			configJSON:  `{"server":{"port":9100}}`,
+			wantPort:    9100,
+			wantLevel:   "info",
+			wantSources: map[string]string{"server": SourceFile, "logger": SourceDefault},
+		},
+		{
+			name:        "environment over config JSON",
+			configJSON:  `{"server":{"port":9100},"logger":{"level":"warn"}}`,
+			env:         map[string]string{"SERVER__PORT": "9200"},
+			wantPort:    9200,
+			wantLevel:   "warn",
+			wantSources: map[string]string{"server": SourceEnv, "logger": SourceFile},
+		},
+		{
+			name:        "missing config file",
+			env:         map[string]string{"SERVER__PORT": "9300"},
+			wantPort:    9300,
+			wantLevel:   "info",
+			wantSources: map[string]string{"server": SourceEnv, "logger": SourceDefault},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.yaml")
+			if tt.file != "" {
+				if err := os.WriteFile(path, []byte(tt.file), 0o600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			t.Setenv(ConfigJSONEnv, tt.configJSON)
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+
+			cfg, err := LoadConfig(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.Server.Port != tt.wantPort || cfg.Logger.Level != tt.wantLevel {
+				t.Errorf("port, level = %d, %q; want %d, %q",
+					cfg.Server.Port, cfg.Logger.Level, tt.wantPort, tt.wantLevel)
+			}
+			sources := cfg.Sources()
+			for section, want := range tt.wantSources {
+				if sources[section] != want {
+					t.Errorf("source of %s = %q, want %q", section, sources[section], want)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadConfigRejectsInvalidConfigJSON(t *testing.T) {
+	t.Setenv(ConfigJSONEnv, `{"server":`)
+
+	if _, err := LoadConfig(filepath.Join(t.TempDir(), "config.yaml")); err == nil ||
+		!strings.Contains(err.Error(), ConfigJSONEnv) {
+		t.Errorf("LoadConfig() error = %v, want one naming %s", err, ConfigJSONEnv)
+	}
+}
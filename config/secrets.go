@@ -0,0 +1,109 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// secretCommandTimeout bounds a ${exec:...} command
+const secretCommandTimeout = 10 * time.Second
+
+// indirectionPattern matches a string setting whose whole value points elsewhere:
+// ${env:VAR}, ${file:/run/secrets/api_key} or ${exec:pass show claude-proxy/api-key}
+var indirectionPattern = regexp.MustCompile(`^\$\{(env|file|exec):(.+)\}$`)
+
+// resolveIndirections replaces the string settings of v written as ${env:...}, ${file:...} or ${exec:...}
+// with the value they point to, adding their dotted YAML paths to resolved
+// Errors name the setting and the source, never the value.
+func resolveIndirections(v reflect.Value, path string, resolved map[string]bool) error {
+	switch v.Kind() {
+	case reflect.Struct:
+		for i := range v.NumField() {
+			field := v.Type().Field(i)
+			name, _, _ := strings.Cut(field.Tag.Get("mapstructure"), ",")
+			if !field.IsExported() || name == "" || name == "-" {
+				continue
+			}
+			if err := resolveIndirections(v.Field(i), joinKey(path, name), resolved); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		if v.Type().Elem().Kind() != reflect.String {
+			return nil
+		}
+		iter := v.MapRange()
+		for iter.Next() {
+			key := joinKey(path, fmt.Sprint(iter.Key().Interface()))
+			value, ok, err := resolveIndirection(key, iter.Value().String())
+			if err != nil {
+				return err
+			}
+			if ok {
+				v.SetMapIndex(iter.Key(), reflect.ValueOf(value).Convert(v.Type().Elem()))
+				resolved[key] = true
+			}
+		}
+	case reflect.Slice:
+		for i := range v.Len() {
+			if err := resolveIndirections(v.Index(i), fmt.Sprintf("%s[%d]", path, i), resolved); err != nil {
+				return err
+			}
+		}
+	case reflect.String:
+		value, ok, err := resolveIndirection(path, v.String())
+		if err != nil {
+			return err
+		}
+		if ok {
+			v.SetString(value)
+			// Slice elements are redacted with their whole setting
+			setting, _, _ := strings.Cut(path, "[")
+			resolved[setting] = true
+		}
+	}
+	return nil
+}
+
+// resolveIndirection returns the value an indirect setting points to, and false if raw is a plain value
+func resolveIndirection(key, raw string) (string, bool, error) {
+	match := indirectionPattern.FindStringSubmatch(raw)
+	if match == nil {
+		return raw, false, nil
+	}
+	source, ref := match[1], strings.TrimSpace(match[2])
+
+	switch source {
+	case "env":
+		value, ok := os.LookupEnv(ref)
+		if !ok {
+			return "", false, fmt.Errorf("%s: environment variable %s is not set", key, ref)
+		}
+		return value, true, nil
+	case "file":
+		data, err := os.ReadFile(ref)
+		if err != nil {
+			return "", false, fmt.Errorf("%s: failed to read secret file: %w", key, err)
+		}
+		return strings.TrimRight(string(data), "\r\n"), true, nil
+	default:
+		ctx, cancel := context.WithTimeout(context.Background(), secretCommandTimeout)
+		defer cancel()
+
+		// The command's stderr stays out of the error, as a failing secret tool may print the secret there
+		output, err := exec.CommandContext(ctx, "sh", "-c", ref).Output()
+		if err != nil {
+			if ctx.Err() != nil {
+				err = fmt.Errorf("timed out after %s", secretCommandTimeout)
+			}
+			return "", false, fmt.Errorf("%s: secret command %q failed: %w", key, ref, err)
+		}
+		return strings.TrimRight(string(output), "\r\n"), true, nil
+	}
+}
@@ -0,0 +1,117 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestResolveIndirection(t *testing.T) {
+	dir := t.TempDir()
+	secretFile := filepath.Join(dir, "api_key")
+	if err := os.WriteFile(secretFile, []byte("file-secret\r\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("CLAUDE_PROXY_TEST_SECRET", "env-secret")
+	t.Setenv("CLAUDE_PROXY_TEST_EMPTY", "")
+
+	tests := []struct {
+		name      string
+		raw       string
+		want      string
+		wantOK    bool
+		wantErr   string // Substring of the error; empty for success
+		forbidden string // Must not appear in the error
+	}{
+		{name: "plain value", raw: "sk-plain", want: "sk-plain"},
+		{name: "not a whole-value reference", raw: "x-${env:HOME}", want: "x-${env:HOME}"},
+		{name: "env", raw: "${env:CLAUDE_PROXY_TEST_SECRET}", want: "env-secret", wantOK: true},
+		{name: "env set but empty", raw: "${env:CLAUDE_PROXY_TEST_EMPTY}", want: "", wantOK: true},
+		{
+			name:    "env not set",
+			raw:     "${env:CLAUDE_PROXY_TEST_MISSING}",
+			wantErr: "auth.api_key: environment variable CLAUDE_PROXY_TEST_MISSING is not set",
+		},
+		{name: "file", raw: "${file:" + secretFile + "}", want: "file-secret", wantOK: true},
+		{name: "file path with spaces", raw: "${file: " + secretFile + " }", want: "file-secret", wantOK: true},
+		{
+			name:    "missing file",
+			raw:     "${file:" + filepath.Join(dir, "missing") + "}",
+			wantErr: "auth.api_key: failed to read secret file",
+		},
+		{name: "exec", raw: "${exec:printf 'exec-secret\\n'}", want: "exec-secret", wantOK: true},
+		{
+			name:      "failed exec",
+			raw:       "${exec:printf 'leak%s' ed >&2; exit 3}",
+			wantErr:   "auth.api_key: secret command \"printf 'leak%s' ed >&2; exit 3\" failed: exit status 3",
+			forbidden: "leaked", // What the command printed to stderr
+		},
+		{
+			name:    "exec of a missing command",
+			raw:     "${exec:claude-proxy-test-no-such-command}",
+			wantErr: "exit status 127",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok, err := resolveIndirection("auth.api_key", tt.raw)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want one containing %q", err, tt.wantErr)
+				}
+				if tt.forbidden != "" && strings.Contains(err.Error(), tt.forbidden) {
+					t.Errorf("error = %q, includes %q", err, tt.forbidden)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("resolveIndirection() = %q, %v; want %q, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestResolveIndirections(t *testing.T) {
+	t.Setenv("CLAUDE_PROXY_TEST_SECRET", "env-secret")
+
+	cfg := Config{}
+	cfg.Auth.APIKey = "${env:CLAUDE_PROXY_TEST_SECRET}"
+	cfg.Telegram.BotToken = "plain"
+	cfg.Claude.Headers.Extra = map[string]string{"x-secret": "${exec:echo header-secret}", "x-plain": "plain"}
+	cfg.Proxy.AllowedPaths = []string{"/v1/messages", "${env:CLAUDE_PROXY_TEST_SECRET}"}
+
+	resolved := make(map[string]bool)
+	if err := resolveIndirections(reflect.ValueOf(&cfg).Elem(), "", resolved); err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.Auth.APIKey != "env-secret" || cfg.Telegram.BotToken != "plain" {
+		t.Errorf("secrets = %q, %q", cfg.Auth.APIKey, cfg.Telegram.BotToken)
+	}
+	if cfg.Claude.Headers.Extra["x-secret"] != "header-secret" || cfg.Claude.Headers.Extra["x-plain"] != "plain" {
+		t.Errorf("extra headers = %v", cfg.Claude.Headers.Extra)
+	}
+	if cfg.Proxy.AllowedPaths[1] != "env-secret" {
+		t.Errorf("allowed paths = %v", cfg.Proxy.AllowedPaths)
+	}
+
+	want := map[string]bool{"auth.api_key": true, "claude.headers.extra.x-secret": true, "proxy.allowed_paths": true}
+	if !reflect.DeepEqual(resolved, want) {
+		t.Errorf("resolved = %v, want %v", resolved, want)
+	}
+}
+
+func TestResolveIndirectionsNamesFailingSetting(t *testing.T) {
+	cfg := Config{}
+	cfg.Storage.DataFolder = "${file:" + filepath.Join(t.TempDir(), "missing") + "}"
+
+	err := resolveIndirections(reflect.ValueOf(&cfg).Elem(), "", make(map[string]bool))
+	if err == nil || !strings.HasPrefix(err.Error(), "storage.data_folder: ") {
+		t.Errorf("error = %v, want one naming storage.data_folder", err)
+	}
+}
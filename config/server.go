@@ -0,0 +1,137 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+)
+
+const unixListenPrefix = "unix://"
+
+// SocketPath returns the Unix socket path when server.listen selects one ("" for host/port)
+func (s ServerConfig) SocketPath() string {
+	return strings.TrimPrefix(s.Listen, unixListenPrefix)
+}
+
+// ListenAddress returns the network ("tcp" or "unix") and address the server listens on
+func (s ServerConfig) ListenAddress() (string, string) {
+	if s.Listen != "" {
+		return "unix", s.SocketPath()
+	}
+	return "tcp", net.JoinHostPort(s.Host, strconv.Itoa(s.Port))
+}
+
+// AdminListenerEnabled returns true if server.admin_port moves the admin surface to its own listener
+func (s ServerConfig) AdminListenerEnabled() bool {
+	return s.AdminPort != 0
+}
+
+// AdminListenAddress returns the TCP address of the admin listener (server.admin_host, else server.host)
+func (s ServerConfig) AdminListenAddress() string {
+	host := s.AdminHost
+	if host == "" {
+		host = s.Host
+	}
+	return net.JoinHostPort(host, strconv.Itoa(s.AdminPort))
+}
+
+// TLSEnabled returns true if the server serves HTTPS itself
+func (s ServerConfig) TLSEnabled() bool {
+	return s.TLS.CertFile != "" && s.TLS.KeyFile != ""
+}
+
+// SocketFileMode returns the permissions applied to the Unix socket file
+func (s ServerConfig) SocketFileMode() os.FileMode {
+	mode, _ := strconv.ParseUint(s.SocketMode, 8, 32) // Validated by LoadConfig
+	return os.FileMode(mode)
+}
+
+// BaseURL returns the URL clients on this host use to reach the server
+// Unix sockets have no URL of their own: they are expected behind a reverse proxy serving localhost
+func (s ServerConfig) BaseURL() string {
+	scheme := "http"
+	if s.TLSEnabled() {
+		scheme = "https"
+	}
+	if s.Listen != "" {
+		return scheme + "://localhost"
+	}
+
+	return fmt.Sprintf("%s://%s", scheme, net.JoinHostPort(localHost(s.Host), strconv.Itoa(s.Port)))
+}
+
+// AdminBaseURL returns the URL clients on this host use to reach the admin API, OAuth routes and dashboard
+func (s ServerConfig) AdminBaseURL() string {
+	if !s.AdminListenerEnabled() {
+		return s.BaseURL()
+	}
+
+	scheme := "http"
+	if s.TLSEnabled() {
+		scheme = "https"
+	}
+	host := s.AdminHost
+	if host == "" {
+		host = s.Host
+	}
+	return fmt.Sprintf("%s://%s", scheme, net.JoinHostPort(localHost(host), strconv.Itoa(s.AdminPort)))
+}
+
+// localHost returns the host to reach a listener bound to host from the same machine
+func localHost(host string) string {
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		return "localhost"
+	}
+	return host
+}
+
+// validate checks the listen mode, admin listener, TLS and public rate limit settings
+func (s ServerConfig) validate() error {
+	if s.Listen != "" && (!strings.HasPrefix(s.Listen, unixListenPrefix) || s.SocketPath() == "") {
+		return fmt.Errorf("invalid server.listen %q: expected unix:///path/to/socket", s.Listen)
+	}
+	if mode, err := strconv.ParseUint(s.SocketMode, 8, 32); err != nil || mode > 0o777 {
+		return fmt.Errorf("invalid server.socket_mode %q: expected octal permissions such as 0660", s.SocketMode)
+	}
+	if (s.TLS.CertFile == "") != (s.TLS.KeyFile == "") {
+		return fmt.Errorf("server.tls requires both cert_file and key_file")
+	}
+	if s.BasePath != "" && (path.Clean(s.BasePath) != s.BasePath || strings.ContainsAny(s.BasePath, basePathForbidden)) {
+		return fmt.Errorf("invalid server.base_path %q: expected a plain path such as /claude-proxy", s.BasePath)
+	}
+	if s.AdminPort < 0 || s.AdminPort > 65535 {
+		return fmt.Errorf("invalid server.admin_port %d: expected 1-65535 (0 = a single listener)", s.AdminPort)
+	}
+	if s.AdminHost != "" && !s.AdminListenerEnabled() {
+		return fmt.Errorf("server.admin_host requires server.admin_port")
+	}
+	if s.AdminListenerEnabled() && s.Listen == "" && s.AdminPort == s.Port {
+		return fmt.Errorf("server.admin_port must differ from server.port")
+	}
+	if s.Compression.MinSize < 0 {
+		return fmt.Errorf("server.compression.min_size must not be negative")
+	}
+	limit := s.PublicRateLimit
+	if limit.RequestsPerMinute < 0 || limit.Burst < 0 || limit.LoginLockout < 0 || limit.IdleTimeout < 0 {
+		return fmt.Errorf("server.public_rate_limit settings must not be negative")
+	}
+	if limit.LoginMaxLockout < limit.LoginLockout {
+		return fmt.Errorf("server.public_rate_limit.login_max_lockout must not be shorter than login_lockout")
+	}
+	return nil
+}
+
+// basePathForbidden are characters a base path can't hold: it is matched literally and written into HTML
+const basePathForbidden = " \"'<>?#%&\\"
+
+// normalizeBasePath adds the leading slash of a base path and drops its trailing one ("/" becomes "")
+func normalizeBasePath(basePath string) string {
+	basePath = strings.TrimRight(strings.TrimSpace(basePath), "/")
+	if basePath != "" && !strings.HasPrefix(basePath, "/") {
+		basePath = "/" + basePath
+	}
+	return basePath
+}
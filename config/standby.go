@@ -0,0 +1,41 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// StandbyConfig runs the instance as a warm standby of a primary instance: it pulls the primary's accounts,
+// tokens and sessions every interval and refuses proxy traffic until promoted (POST /api/admin/promote)
+type StandbyConfig struct {
+	Enabled    bool   `yaml:"enabled"     mapstructure:"enabled"`
+	PrimaryURL string `yaml:"primary_url" mapstructure:"primary_url"` // Admin API of the primary (http://10.0.0.1:4000)
+	APIKey     string `yaml:"api_key"     mapstructure:"api_key"`     // An admin key of the primary
+	// Passphrase encrypts the state bundles exported by the primary (at least 8 characters)
+	Passphrase string        `yaml:"passphrase" mapstructure:"passphrase"`
+	Interval   time.Duration `yaml:"interval"   mapstructure:"interval"` // How often the primary is polled (default 30s)
+	Timeout    time.Duration `yaml:"timeout"    mapstructure:"timeout"`  // Per pull (default 30s)
+}
+
+// validate checks the primary's address and credentials of an enabled standby
+func (s StandbyConfig) validate() error {
+	if s.Interval < 0 || s.Timeout < 0 {
+		return fmt.Errorf("standby.interval and standby.timeout must not be negative")
+	}
+	if !s.Enabled {
+		return nil
+	}
+
+	u, err := url.Parse(s.PrimaryURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("standby.primary_url must be an http:// or https:// URL, got %q", s.PrimaryURL)
+	}
+	if s.APIKey == "" {
+		return fmt.Errorf("standby.api_key is required when standby is enabled")
+	}
+	if len(s.Passphrase) < 8 {
+		return fmt.Errorf("standby.passphrase must be at least 8 characters when standby is enabled")
+	}
+	return nil
+}
@@ -11,6 +11,7 @@ import { AdminLayout } from './components/layout/admin-layout'
 import { useAuth } from './hooks/useAuth'
 import { Loader2 } from 'lucide-react'
 import { ThemeProvider } from './hooks/useTheme'
+import { BASE_PATH } from './lib/base-path'
 
 const queryClient = new QueryClient({
   defaultOptions: {
@@ -48,7 +49,7 @@ function App() {
   return (
     <ThemeProvider>
       <QueryClientProvider client={queryClient}>
-        <BrowserRouter>
+        <BrowserRouter basename={BASE_PATH || '/'}>
           <Routes>
             <Route path="/login" element={<LoginPage />} />
             <Route
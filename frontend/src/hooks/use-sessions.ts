@@ -1,15 +1,20 @@
-import { useQuery, useMutation, useQueryClient } from '@tanstack/react-query'
+import { keepPreviousData, useQuery, useMutation, useQueryClient } from '@tanstack/react-query'
 import { sessionApi } from '@/lib/api'
-import type { ListSessionsResponse, RevokeSessionResponse } from '@/types/session'
+import type {
+  ListSessionsResponse,
+  RevokeSessionResponse,
+  SessionQueryParams,
+} from '@/types/session'
 
 /**
- * Hook to fetch all sessions (admin)
+ * Hook to fetch a page of sessions (admin)
  * Sessions track concurrent requests per client (IP + User-Agent)
  */
-export function useAllSessions() {
+export function useAllSessions(params?: SessionQueryParams) {
   return useQuery<ListSessionsResponse>({
-    queryKey: ['sessions', 'all'],
-    queryFn: () => sessionApi.listAll(),
+    queryKey: ['sessions', 'all', params],
+    queryFn: () => sessionApi.listAll(params),
+    placeholderData: keepPreviousData, // Keep the current page (and the filter inputs) while the next loads
   })
 }
 
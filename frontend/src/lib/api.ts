@@ -7,14 +7,19 @@ import type {
   TokenListResponse,
 } from '@/types/token'
 import type { Statistics } from '@/types/statistics'
-import type { ListSessionsResponse, RevokeSessionResponse } from '@/types/session'
+import type {
+  ListSessionsResponse,
+  RevokeSessionResponse,
+  SessionQueryParams,
+} from '@/types/session'
 import { convertKeysToSnake, convertKeysToCamel } from './case-converter'
+import { BASE_PATH } from './base-path'
 
 // API base URL
 // In development: use VITE_API_URL env var (defaults to http://localhost:4000 via Vite proxy)
-// In production: use relative URL '' to call same domain automatically
+// In production: call the same domain under the server's base path
 const API_BASE_URL =
-  import.meta.env.VITE_API_URL || (import.meta.env.DEV ? 'http://localhost:4000' : '')
+  import.meta.env.VITE_API_URL || (import.meta.env.DEV ? 'http://localhost:4000' : BASE_PATH)
 
 // Axios instance with default config
 const apiClient = axios.create({
@@ -276,9 +281,9 @@ export const statisticsApi = {
 
 // Session API (session management)
 export const sessionApi = {
-  // List all sessions (admin)
-  listAll: async (): Promise<ListSessionsResponse> => {
-    const response = await apiClient.get('/api/admin/sessions')
+  // List sessions with filters and pagination (admin)
+  listAll: async (params?: SessionQueryParams): Promise<ListSessionsResponse> => {
+    const response = await apiClient.get('/api/admin/sessions', { params })
     return response.data
   },
 
@@ -0,0 +1,4 @@
+// Base path the server mounts the dashboard under (server.base_path), injected into index.html
+// Empty when served at the root or by the Vite dev server
+export const BASE_PATH =
+  document.querySelector('meta[name="claude-proxy-base-path"]')?.getAttribute('content') ?? ''
@@ -10,14 +10,29 @@ import {
   TableRow,
 } from '@/components/ui/table'
 import { Badge } from '@/components/ui/badge'
+import { Input } from '@/components/ui/input'
 import { Card, CardContent, CardDescription, CardHeader, CardTitle } from '@/components/ui/card'
 import { AlertCircle, Loader2, Trash2, RefreshCw } from 'lucide-react'
 import { Alert, AlertDescription, AlertTitle } from '@/components/ui/alert'
 import { Dialog } from '@/components/ui/dialog'
 import type { Session } from '@/types/session'
 
+type StatusFilter = '' | 'active' | 'expired'
+
 export default function SessionsPage() {
-  const { data, isLoading, error, refetch } = useAllSessions()
+  const [statusFilter, setStatusFilter] = useState<StatusFilter>('')
+  const [tokenIdFilter, setTokenIdFilter] = useState('')
+  const [ipFilter, setIpFilter] = useState('')
+  const [page, setPage] = useState(1)
+
+  const { data, isLoading, error, refetch } = useAllSessions({
+    tokenId: tokenIdFilter.trim() || undefined,
+    ip: ipFilter.trim() || undefined,
+    active: statusFilter === 'active' || undefined,
+    expired: statusFilter === 'expired' || undefined,
+    page,
+    limit: 50,
+  })
   const revokeSessionMutation = useRevokeSession()
   const [sessionToRevoke, setSessionToRevoke] = useState<string | null>(null)
 
@@ -68,6 +83,9 @@ export default function SessionsPage() {
 
   const sessions = data?.sessions || []
   const totalSessions = data?.total || 0
+  const totals = data?.totals
+  const paging = data?.paging
+  const totalPages = paging ? Math.max(1, Math.ceil(paging.total / paging.limit)) : 1
 
   return (
     <div className="space-y-6">
@@ -84,15 +102,57 @@ export default function SessionsPage() {
         </Button>
       </div>
 
+      {/* Filters */}
+      <div className="flex flex-wrap items-center justify-between gap-3">
+        <div className="flex flex-wrap items-center gap-3">
+          <select
+            aria-label="Filter by status"
+            value={statusFilter}
+            onChange={(e) => {
+              setStatusFilter(e.target.value as StatusFilter)
+              setPage(1) // Reset to first page on filter change
+            }}
+            className="border-input bg-background text-foreground focus:border-ring focus:ring-ring rounded-md border px-3 py-2 text-sm ring-2 focus:outline-none"
+          >
+            <option value="">All Sessions</option>
+            <option value="active">Active</option>
+            <option value="expired">Expired</option>
+          </select>
+          <Input
+            placeholder="Token ID"
+            value={tokenIdFilter}
+            onChange={(e) => {
+              setTokenIdFilter(e.target.value)
+              setPage(1)
+            }}
+            className="w-72"
+          />
+          <Input
+            placeholder="IP address"
+            value={ipFilter}
+            onChange={(e) => {
+              setIpFilter(e.target.value)
+              setPage(1)
+            }}
+            className="w-44"
+          />
+        </div>
+        {totals && (
+          <p className="text-muted-foreground text-sm">
+            {totals.active.toLocaleString()} active of {totals.all.toLocaleString()} total
+          </p>
+        )}
+      </div>
+
       <Card>
         <CardHeader>
-          <CardTitle>Sessions ({totalSessions})</CardTitle>
-          <CardDescription>All active sessions with concurrent usage tracking</CardDescription>
+          <CardTitle>Sessions ({totalSessions.toLocaleString()})</CardTitle>
+          <CardDescription>Sessions matching the filters, most recently seen first</CardDescription>
         </CardHeader>
         <CardContent>
           {sessions.length === 0 ? (
             <div className="text-muted-foreground flex h-32 items-center justify-center">
-              No active sessions
+              No sessions found
             </div>
           ) : (
             <Table>
@@ -157,6 +217,33 @@ export default function SessionsPage() {
         </CardContent>
       </Card>
 
+      {/* Pagination */}
+      {totalPages > 1 && (
+        <div className="flex items-center justify-between">
+          <p className="text-muted-foreground text-sm">
+            Page {page} of {totalPages}
+          </p>
+          <div className="flex gap-2">
+            <Button
+              variant="outline"
+              size="sm"
+              onClick={() => setPage((p) => Math.max(1, p - 1))}
+              disabled={page === 1}
+            >
+              Previous
+            </Button>
+            <Button
+              variant="outline"
+              size="sm"
+              onClick={() => setPage((p) => Math.min(totalPages, p + 1))}
+              disabled={page === totalPages}
+            >
+              Next
+            </Button>
+          </div>
+        </div>
+      )}
+
       <Dialog
         open={!!sessionToRevoke}
         onClose={() => setSessionToRevoke(null)}
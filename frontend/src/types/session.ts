@@ -1,3 +1,5 @@
+import type { Paging } from '@/types/token'
+
 /**
  * Session entity representing an active client session
  * Sessions track concurrent requests per client (IP + User-Agent)
@@ -14,12 +16,38 @@ export interface Session {
   requestPath: string
 }
 
+/**
+ * Filters, sorting and pagination for the session list
+ */
+export interface SessionQueryParams {
+  tokenId?: string
+  ip?: string
+  active?: boolean
+  expired?: boolean
+  createdAfter?: string // RFC3339
+  sort?: 'last_seen_at' | 'created_at'
+  order?: 'asc' | 'desc'
+  page?: number
+  limit?: number
+}
+
+/**
+ * Session counts by state, regardless of filters
+ */
+export interface SessionTotals {
+  all: number
+  active: number
+  expired: number
+}
+
 /**
  * Response from list all sessions endpoint
  */
 export interface ListSessionsResponse {
   sessions: Session[]
-  total: number
+  total: number // Sessions matching the filters
+  paging: Paging
+  totals: SessionTotals
 }
 
 /**
@@ -4,6 +4,8 @@ import path from 'path'
 
 // https://vite.dev/config/
 export default defineConfig({
+  // Relative asset URLs, resolved against the <base> the server injects for server.base_path
+  base: './',
   plugins: [react()],
   resolve: {
     alias: {
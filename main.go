@@ -4,9 +4,11 @@ import (
 	"embed"
 	"log"
 	"os"
+	"time"
 
 	mycli "claude-proxy/cli"
 	"claude-proxy/cmd/api"
+	"claude-proxy/pkg/version"
 
 	"github.com/urfave/cli/v2"
 )
@@ -18,12 +20,13 @@ func main() {
 	// Set the frontend FS for the API server
 	api.FrontendFS = frontendFS
 	app := &cli.App{
-		Name:  "claude-proxy",
-		Usage: "Claude API proxy service with in-memory job scheduler",
+		Name:    "claude-proxy",
+		Usage:   "Claude API proxy service with in-memory job scheduler",
+		Version: version.Version,
 		Commands: []*cli.Command{
 			{
 				Name:    "server",
-				Aliases: []string{"s"},
+				Aliases: []string{"s", "api", "a"}, // "api" was a separate command starting the same server
 				Usage:   "Start the API server",
 				Flags: []cli.Flag{
 					&cli.StringFlag{
@@ -36,9 +39,8 @@ func main() {
 				Action: mycli.RunServer,
 			},
 			{
-				Name:    "api",
-				Aliases: []string{"a"},
-				Usage:   "Start the API server (same as server)",
+				Name:  "healthcheck",
+				Usage: "Check that the local server answers GET /health (or /health/ready) on its configured listener",
 				Flags: []cli.Flag{
 					&cli.StringFlag{
 						Name:    "config",
@@ -46,8 +48,171 @@ func main() {
 						Value:   "config.yaml",
 						Usage:   "Configuration file path",
 					},
+					&cli.DurationFlag{
+						Name:  "timeout",
+						Value: 3 * time.Second,
+						Usage: "Request timeout",
+					},
+					&cli.BoolFlag{
+						Name:  "ready",
+						Usage: "Check readiness (accounts, storage, sync) instead of liveness",
+					},
+				},
+				Action: mycli.RunHealthcheck,
+			},
+			{
+				Name:  "restore",
+				Usage: "Restore a backup archive into the data folder (server should be stopped)",
+				Flags: tokenFlags(
+					&cli.StringFlag{
+						Name:     "backup",
+						Aliases:  []string{"b"},
+						Usage:    "Backup archive path (.tar.gz)",
+						Required: true,
+					},
+				),
+				Action: mycli.RunRestore,
+			},
+			{
+				Name:  "export",
+				Usage: "Write all data files to an encrypted state bundle for disaster recovery",
+				Flags: tokenFlags(
+					&cli.StringFlag{
+						Name:     "out",
+						Aliases:  []string{"o"},
+						Usage:    "Bundle output path (e.g. state.tar.gz.enc)",
+						Required: true,
+					},
+					&cli.StringFlag{
+						Name:     "passphrase-env",
+						Usage:    "Environment variable holding the bundle passphrase (at least 8 characters)",
+						Required: true,
+					},
+				),
+				Action: mycli.RunExport,
+			},
+			{
+				Name:  "import",
+				Usage: "Restore an encrypted state bundle into an empty data folder (server should be stopped)",
+				Flags: tokenFlags(
+					&cli.StringFlag{
+						Name:     "in",
+						Aliases:  []string{"i"},
+						Usage:    "Bundle path",
+						Required: true,
+					},
+					&cli.StringFlag{
+						Name:     "passphrase-env",
+						Usage:    "Environment variable holding the bundle passphrase",
+						Required: true,
+					},
+				),
+				Action: mycli.RunImport,
+			},
+			{
+				Name:  "account",
+				Usage: "Manage Claude accounts (import works on the data folder with the server stopped, add needs it running)",
+				Subcommands: []*cli.Command{
+					{
+						Name:  "import",
+						Usage: "Import a Claude Code credentials file (~/.claude/.credentials.json) as an account",
+						Flags: tokenFlags(
+							&cli.StringFlag{
+								Name:     "file",
+								Aliases:  []string{"f"},
+								Usage:    "Credentials file path",
+								Required: true,
+							},
+							&cli.StringFlag{
+								Name:  "name",
+								Value: "imported",
+								Usage: "Account name",
+							},
+						),
+						Action: mycli.AccountImport,
+					},
+					{
+						Name: "add",
+						Usage: "Add an account through the running server: prints an authorization URL to open in any " +
+							"browser and waits until the code Claude shows is pasted",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:    "config",
+								Aliases: []string{"c"},
+								Value:   "config.yaml",
+								Usage:   "Configuration file path",
+							},
+							&cli.StringFlag{
+								Name:     "name",
+								Usage:    "Account name",
+								Required: true,
+							},
+							&cli.StringFlag{
+								Name:  "org",
+								Usage: "Organization UUID to pin the authorization to",
+							},
+							&cli.StringFlag{
+								Name:  "key-env",
+								Value: "CLAUDE_PROXY_ADMIN_KEY",
+								Usage: "Environment variable holding the admin API key (default: auth.api_key)",
+							},
+						},
+						Action: mycli.AccountAdd,
+					},
+				},
+			},
+			{
+				Name:  "token",
+				Usage: "Manage API tokens directly on the data folder (server should be stopped)",
+				Subcommands: []*cli.Command{
+					{
+						Name:  "create",
+						Usage: "Create a token with a generated key (key is printed once)",
+						Flags: tokenFlags(
+							&cli.StringFlag{
+								Name:     "name",
+								Usage:    "Token name",
+								Required: true,
+							},
+							&cli.StringFlag{
+								Name:  "role",
+								Value: "user",
+								Usage: "Token role (user, admin or viewer)",
+							},
+						),
+						Action: mycli.TokenCreate,
+					},
+					{
+						Name:   "list",
+						Usage:  "List tokens with masked keys",
+						Flags:  tokenFlags(),
+						Action: mycli.TokenList,
+					},
+					{
+						Name:  "revoke",
+						Usage: "Revoke a token",
+						Flags: tokenFlags(
+							&cli.StringFlag{
+								Name:     "id",
+								Usage:    "Token ID",
+								Required: true,
+							},
+						),
+						Action: mycli.TokenRevoke,
+					},
+					{
+						Name:  "rotate",
+						Usage: "Generate a new key for a token, keeping its metadata",
+						Flags: tokenFlags(
+							&cli.StringFlag{
+								Name:     "id",
+								Usage:    "Token ID",
+								Required: true,
+							},
+						),
+						Action: mycli.TokenRotate,
+					},
 				},
-				Action: mycli.RunAPI,
 			},
 		},
 		Action: func(c *cli.Context) error {
@@ -61,3 +226,20 @@ func main() {
 		log.Fatal(err)
 	}
 }
+
+// tokenFlags returns the common flags for offline data commands plus any extra flags
+func tokenFlags(extra ...cli.Flag) []cli.Flag {
+	flags := []cli.Flag{
+		&cli.StringFlag{
+			Name:    "config",
+			Aliases: []string{"c"},
+			Value:   "config.yaml",
+			Usage:   "Configuration file path",
+		},
+		&cli.BoolFlag{
+			Name:  "force",
+			Usage: "Run even if a server appears to be running",
+		},
+	}
+	return append(flags, extra...)
+}
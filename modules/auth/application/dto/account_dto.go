@@ -4,6 +4,7 @@ import (
 	"time"
 
 	"claude-proxy/modules/auth/domain/entities"
+	"claude-proxy/pkg/actor"
 )
 
 // RFC3339 is the datetime format for API responses and persistence (ISO 8601)
@@ -13,34 +14,192 @@ const RFC3339 = time.RFC3339
 // Persistence DTOs (for JSON file storage)
 // ============================================================================
 
+// OrganizationDTO represents a Claude organization in persistence and API responses
+type OrganizationDTO struct {
+	UUID     string `json:"uuid"`
+	Name     string `json:"name"`
+	PlanType string `json:"plan_type,omitempty"` // max, pro or team (omitted if unknown)
+}
+
+// ToOrganizationDTOs converts organization entities to DTOs
+func ToOrganizationDTOs(orgs []entities.Organization) []OrganizationDTO {
+	result := make([]OrganizationDTO, len(orgs))
+	for i, org := range orgs {
+		result[i] = OrganizationDTO{UUID: org.UUID, Name: org.Name, PlanType: string(org.PlanType)}
+	}
+	return result
+}
+
+// FromOrganizationDTOs converts organization DTOs to entities
+func FromOrganizationDTOs(orgs []OrganizationDTO) []entities.Organization {
+	if len(orgs) == 0 {
+		return nil
+	}
+	result := make([]entities.Organization, len(orgs))
+	for i, org := range orgs {
+		result[i] = entities.Organization{UUID: org.UUID, Name: org.Name, PlanType: entities.PlanType(org.PlanType)}
+	}
+	return result
+}
+
+// AvailabilityWindowDTO represents an account availability window in persistence, requests and responses
+type AvailabilityWindowDTO struct {
+	Days     []string `json:"days,omitempty"` // mon ... sun (empty = every day)
+	Start    string   `json:"start"`          // HH:MM
+	End      string   `json:"end"`            // HH:MM, at or before start = the next day
+	Timezone string   `json:"timezone,omitempty"`
+}
+
+// ToAvailabilityWindowDTOs converts availability windows to DTOs
+func ToAvailabilityWindowDTOs(windows []entities.AvailabilityWindow) []AvailabilityWindowDTO {
+	if len(windows) == 0 {
+		return nil
+	}
+	result := make([]AvailabilityWindowDTO, len(windows))
+	for i, window := range windows {
+		result[i] = AvailabilityWindowDTO(window)
+	}
+	return result
+}
+
+// FromAvailabilityWindowDTOs converts availability window DTOs to entities
+func FromAvailabilityWindowDTOs(windows []AvailabilityWindowDTO) []entities.AvailabilityWindow {
+	if len(windows) == 0 {
+		return nil
+	}
+	result := make([]entities.AvailabilityWindow, len(windows))
+	for i, window := range windows {
+		result[i] = entities.AvailabilityWindow(window)
+	}
+	return result
+}
+
+// RefreshTokenRecordDTO represents a refresh token fingerprint of an account's lineage (never the token)
+type RefreshTokenRecordDTO struct {
+	Fingerprint string `json:"fingerprint"` // SHA-256 prefix of the refresh token
+	Source      string `json:"source"`      // oauth, refresh or manual
+	AdoptedAt   string `json:"adopted_at"`  // RFC3339/ISO 8601 datetime
+}
+
+// ToRefreshTokenRecordDTOs converts a refresh token lineage to DTOs
+func ToRefreshTokenRecordDTOs(records []entities.RefreshTokenRecord) []RefreshTokenRecordDTO {
+	if len(records) == 0 {
+		return nil
+	}
+	result := make([]RefreshTokenRecordDTO, len(records))
+	for i, record := range records {
+		result[i] = RefreshTokenRecordDTO{
+			Fingerprint: record.Fingerprint,
+			Source:      string(record.Source),
+			AdoptedAt:   record.AdoptedAt.Format(RFC3339),
+		}
+	}
+	return result
+}
+
+// FromRefreshTokenRecordDTOs converts refresh token lineage DTOs to entities
+func FromRefreshTokenRecordDTOs(records []RefreshTokenRecordDTO) []entities.RefreshTokenRecord {
+	if len(records) == 0 {
+		return nil
+	}
+	result := make([]entities.RefreshTokenRecord, len(records))
+	for i, record := range records {
+		adoptedAt, _ := time.Parse(RFC3339, record.AdoptedAt)
+		result[i] = entities.RefreshTokenRecord{
+			Fingerprint: record.Fingerprint,
+			Source:      entities.RefreshTokenSource(record.Source),
+			AdoptedAt:   adoptedAt,
+		}
+	}
+	return result
+}
+
 // AccountPersistenceDTO represents the JSON structure for account persistence
 type AccountPersistenceDTO struct {
-	ID               string  `json:"id"`
-	Name             string  `json:"name"`
-	OrganizationUUID string  `json:"organization_uuid"`
-	AccessToken      string  `json:"access_token"`
-	RefreshToken     string  `json:"refresh_token"`
-	ExpiresAt        string  `json:"expires_at"` // RFC3339/ISO 8601 datetime
-	Status           string  `json:"status"`
-	RateLimitedUntil *string `json:"rate_limited_until,omitempty"` // RFC3339/ISO 8601 datetime
-	LastRefreshError string  `json:"last_refresh_error,omitempty"`
-	CreatedAt        string  `json:"created_at"` // RFC3339/ISO 8601 datetime
-	UpdatedAt        string  `json:"updated_at"` // RFC3339/ISO 8601 datetime
+	ID               string            `json:"id"`
+	ExternalID       string            `json:"external_id,omitempty"` // Legacy ID replaced by ID
+	Name             string            `json:"name"`
+	AuthType         string            `json:"auth_type,omitempty"` // Empty (older files) means oauth
+	APIKey           string            `json:"api_key,omitempty"`
+	OrganizationUUID string            `json:"organization_uuid"`
+	Organizations    []OrganizationDTO `json:"organizations,omitempty"`
+	AccessToken      string            `json:"access_token"`
+	RefreshToken     string            `json:"refresh_token"`
+	ExpiresAt        string            `json:"expires_at"` // RFC3339/ISO 8601 datetime
+	Status           string            `json:"status"`
+	RateLimitedUntil *string           `json:"rate_limited_until,omitempty"` // RFC3339/ISO 8601 datetime
+	LastRefreshError string            `json:"last_refresh_error,omitempty"`
+	RefreshFailures  int               `json:"refresh_failures,omitempty"`
+	RefreshFailedAt  *string           `json:"refresh_failed_at,omitempty"` // RFC3339/ISO 8601 datetime
+	AutoRefresh      *bool             `json:"auto_refresh,omitempty"`      // Nil (older files) means true
+	Shadow           bool              `json:"shadow,omitempty"`
+	AdminCapable     bool              `json:"admin_capable,omitempty"`
+	Canary           bool              `json:"canary,omitempty"`
+	Priority         int               `json:"priority,omitempty"`
+	ProxyURL         string            `json:"proxy_url,omitempty"`
+	BaseURL          string            `json:"base_url,omitempty"`
+	Headers          map[string]string `json:"headers,omitempty"`
+	SupportedModels  []string          `json:"supported_models,omitempty"`
+	QuotaRequests    int               `json:"quota_requests,omitempty"`
+	QuotaTokens      int               `json:"quota_tokens,omitempty"`
+	UsageWindowStart *string           `json:"usage_window_start,omitempty"` // RFC3339/ISO 8601 datetime
+	WindowRequests   int               `json:"window_requests,omitempty"`
+	WindowTokens     int               `json:"window_tokens,omitempty"`
+	// Weekly time ranges the account serves in (empty = always)
+	Availability []AvailabilityWindowDTO `json:"availability,omitempty"`
+	// Recent refresh token fingerprints (newest first), and when another client was found rotating the token
+	RefreshLineage     []RefreshTokenRecordDTO `json:"refresh_lineage,omitempty"`
+	ConflictDetectedAt *string                 `json:"conflict_detected_at,omitempty"` // RFC3339/ISO 8601 datetime
+	// Input-side share of window_tokens (absent in files written before it was tracked)
+	WindowInputTokens         int     `json:"window_input_tokens,omitempty"`
+	WindowCacheCreationTokens int     `json:"window_cache_creation_tokens,omitempty"`
+	WindowCacheReadTokens     int     `json:"window_cache_read_tokens,omitempty"`
+	DeletedAt                 *string `json:"deleted_at,omitempty"`     // RFC3339/ISO 8601 datetime, nil if not deleted
+	CooldownUntil             *string `json:"cooldown_until,omitempty"` // RFC3339/ISO 8601 datetime, nil once activated
+	CreatedAt                 string  `json:"created_at"`               // RFC3339/ISO 8601 datetime
+	UpdatedAt                 string  `json:"updated_at"`               // RFC3339/ISO 8601 datetime
+	CreatedBy                 string  `json:"created_by,omitempty"`     // Admin identity, absent in older files
+	UpdatedBy                 string  `json:"updated_by,omitempty"`
 }
 
 // ToAccountPersistenceDTO converts entity to persistence DTO (includes sensitive tokens)
 func ToAccountPersistenceDTO(account *entities.Account) *AccountPersistenceDTO {
+	autoRefresh := account.AutoRefresh
 	dto := &AccountPersistenceDTO{
 		ID:               account.ID,
+		ExternalID:       account.ExternalID,
 		Name:             account.Name,
+		AuthType:         string(account.AuthType),
+		APIKey:           account.APIKey,
 		OrganizationUUID: account.OrganizationUUID,
 		AccessToken:      account.AccessToken,
 		RefreshToken:     account.RefreshToken,
 		ExpiresAt:        account.ExpiresAt.Format(RFC3339),
 		Status:           string(account.Status),
 		LastRefreshError: account.LastRefreshError,
+		RefreshFailures:  account.RefreshFailures,
+		AutoRefresh:      &autoRefresh,
+		Shadow:           account.Shadow,
+		AdminCapable:     account.AdminCapable,
+		Canary:           account.Canary,
+		Priority:         account.Priority,
+		ProxyURL:         account.ProxyURL,
+		BaseURL:          account.BaseURL,
+		Headers:          account.Headers,
+		SupportedModels:  account.SupportedModels,
+		Availability:     ToAvailabilityWindowDTOs(account.Availability),
+		QuotaRequests:    account.QuotaRequests,
+		QuotaTokens:      account.QuotaTokens,
+		WindowRequests:   account.WindowRequests,
+		WindowTokens:     account.WindowTokens,
 		CreatedAt:        account.CreatedAt.Format(RFC3339),
 		UpdatedAt:        account.UpdatedAt.Format(RFC3339),
+		CreatedBy:        account.CreatedBy,
+		UpdatedBy:        account.UpdatedBy,
+
+		WindowInputTokens:         account.WindowInputTokens,
+		WindowCacheCreationTokens: account.WindowCacheCreationTokens,
+		WindowCacheReadTokens:     account.WindowCacheReadTokens,
 	}
 
 	// Convert RateLimitedUntil pointer
@@ -49,6 +208,37 @@ func ToAccountPersistenceDTO(account *entities.Account) *AccountPersistenceDTO {
 		dto.RateLimitedUntil = &timestamp
 	}
 
+	if account.RefreshFailedAt != nil {
+		timestamp := account.RefreshFailedAt.Format(RFC3339)
+		dto.RefreshFailedAt = &timestamp
+	}
+
+	if len(account.Organizations) > 0 {
+		dto.Organizations = ToOrganizationDTOs(account.Organizations)
+	}
+
+	// Persist usage window so quota counters survive restarts
+	if !account.UsageWindowStart.IsZero() {
+		timestamp := account.UsageWindowStart.Format(RFC3339)
+		dto.UsageWindowStart = &timestamp
+	}
+
+	if account.CooldownUntil != nil {
+		timestamp := account.CooldownUntil.Format(RFC3339)
+		dto.CooldownUntil = &timestamp
+	}
+
+	dto.RefreshLineage = ToRefreshTokenRecordDTOs(account.RefreshLineage)
+	if account.ConflictDetectedAt != nil {
+		timestamp := account.ConflictDetectedAt.Format(RFC3339)
+		dto.ConflictDetectedAt = &timestamp
+	}
+
+	if account.DeletedAt != nil {
+		timestamp := account.DeletedAt.Format(RFC3339)
+		dto.DeletedAt = &timestamp
+	}
+
 	return dto
 }
 
@@ -60,15 +250,41 @@ func FromAccountPersistenceDTO(dto *AccountPersistenceDTO) *entities.Account {
 
 	account := &entities.Account{
 		ID:               dto.ID,
+		ExternalID:       dto.ExternalID,
 		Name:             dto.Name,
+		AuthType:         entities.AccountAuthType(dto.AuthType),
+		APIKey:           dto.APIKey,
 		OrganizationUUID: dto.OrganizationUUID,
+		Organizations:    FromOrganizationDTOs(dto.Organizations),
 		AccessToken:      dto.AccessToken,
 		RefreshToken:     dto.RefreshToken,
 		ExpiresAt:        expiresAt,
 		Status:           entities.AccountStatus(dto.Status),
 		LastRefreshError: dto.LastRefreshError,
+		RefreshFailures:  dto.RefreshFailures,
+		AutoRefresh:      dto.AutoRefresh == nil || *dto.AutoRefresh,
+		Shadow:           dto.Shadow,
+		AdminCapable:     dto.AdminCapable,
+		Canary:           dto.Canary,
+		Priority:         dto.Priority,
+		ProxyURL:         dto.ProxyURL,
+		BaseURL:          dto.BaseURL,
+		Headers:          dto.Headers,
+		SupportedModels:  dto.SupportedModels,
+		Availability:     FromAvailabilityWindowDTOs(dto.Availability),
+		RefreshLineage:   FromRefreshTokenRecordDTOs(dto.RefreshLineage),
+		QuotaRequests:    dto.QuotaRequests,
+		QuotaTokens:      dto.QuotaTokens,
+		WindowRequests:   dto.WindowRequests,
+		WindowTokens:     dto.WindowTokens,
 		CreatedAt:        createdAt,
 		UpdatedAt:        updatedAt,
+		CreatedBy:        dto.CreatedBy,
+		UpdatedBy:        dto.UpdatedBy,
+
+		WindowInputTokens:         dto.WindowInputTokens,
+		WindowCacheCreationTokens: dto.WindowCacheCreationTokens,
+		WindowCacheReadTokens:     dto.WindowCacheReadTokens,
 	}
 
 	// Convert RateLimitedUntil pointer
@@ -77,9 +293,47 @@ func FromAccountPersistenceDTO(dto *AccountPersistenceDTO) *entities.Account {
 		account.RateLimitedUntil = &t
 	}
 
+	if dto.RefreshFailedAt != nil {
+		t, _ := time.Parse(RFC3339, *dto.RefreshFailedAt)
+		account.RefreshFailedAt = &t
+	}
+
+	if dto.UsageWindowStart != nil {
+		account.UsageWindowStart, _ = time.Parse(RFC3339, *dto.UsageWindowStart)
+	}
+
+	if dto.CooldownUntil != nil {
+		t, _ := time.Parse(RFC3339, *dto.CooldownUntil)
+		account.CooldownUntil = &t
+	}
+
+	if dto.ConflictDetectedAt != nil {
+		t, _ := time.Parse(RFC3339, *dto.ConflictDetectedAt)
+		account.ConflictDetectedAt = &t
+	}
+
+	if dto.DeletedAt != nil {
+		t, _ := time.Parse(RFC3339, *dto.DeletedAt)
+		account.DeletedAt = &t
+	}
+
+	if account.AuthType == "" {
+		account.AuthType = entities.AccountAuthOAuth
+	}
+
 	return account
 }
 
+// AccountsFileSchemaVersion is the schema version of accounts.json written by this build
+// Version 1 is the bare JSON array written before the file carried a header
+const AccountsFileSchemaVersion = 2
+
+// AccountsFileDTO is the layout of accounts.json: a schema header followed by the accounts
+type AccountsFileDTO struct {
+	SchemaVersion int                      `json:"schema_version"`
+	Accounts      []*AccountPersistenceDTO `json:"accounts"`
+}
+
 // ============================================================================
 // API Response DTOs (for HTTP responses - no sensitive data)
 // ============================================================================
@@ -90,36 +344,193 @@ type CreateAccountRequest struct {
 	OrgID string `json:"org_id,omitempty"`
 }
 
+// AccountQueryParams represents query parameters for listing accounts
+type AccountQueryParams struct {
+	IncludeDeleted bool `form:"include_deleted"` // Include soft-deleted accounts
+}
+
+// DeleteAccountParams represents query parameters for deleting an account
+type DeleteAccountParams struct {
+	Permanent bool `form:"permanent"` // Remove the account and its credentials instead of soft-deleting
+}
+
 // UpdateAccountRequest represents the request to update an account
 type UpdateAccountRequest struct {
-	Name   *string `json:"name,omitempty"`
-	Status *string `json:"status,omitempty" binding:"omitempty,oneof=active inactive rate_limited invalid"`
+	Name          *string `json:"name,omitempty"`
+	Status        *string `json:"status,omitempty"         binding:"omitempty,oneof=active inactive rate_limited invalid"`
+	QuotaRequests *int    `json:"quota_requests,omitempty" binding:"omitempty,min=0"` // 0 = unlimited
+	QuotaTokens   *int    `json:"quota_tokens,omitempty"   binding:"omitempty,min=0"` // 0 = unlimited
+	// ProxyURL sets the egress proxy (http://, https:// or socks5://); empty string clears it
+	ProxyURL *string `json:"proxy_url,omitempty"`
+	// BaseURL routes the account's API requests through another https Claude API host (e.g. an
+	// enterprise gateway); empty string restores claude.base_url
+	BaseURL *string `json:"base_url,omitempty"`
+	// Headers replace the account's identification header overrides (User-Agent, x-app or any static
+	// header sent upstream); an empty object restores the configured headers
+	Headers *map[string]string `json:"headers,omitempty"`
+	// SupportedModels restricts the account to requests for matching models (glob patterns such as
+	// claude-3-5-*); an empty list serves every model
+	SupportedModels *[]string `json:"supported_models,omitempty"`
+	// Availability limits the account to weekly time ranges, e.g. [{"days": ["mon", "tue", "wed", "thu", "fri"],
+	// "start": "22:00", "end": "07:00", "timezone": "Europe/Berlin"}]; an empty list makes it always available
+	Availability *[]AvailabilityWindowDTO `json:"availability,omitempty"`
+	// OrganizationUUID switches the active organization (must be one of the account's organizations)
+	OrganizationUUID *string `json:"organization_uuid,omitempty"`
+	// AutoRefresh false puts the account in manual mode: its tokens are never refreshed by the proxy
+	AutoRefresh *bool `json:"auto_refresh,omitempty"`
+	// Shadow true keeps the account out of the rotation; it only receives mirrored copies of message requests
+	Shadow *bool `json:"shadow,omitempty"`
+	// AdminCapable true collects the organization's usage reports from Claude API through the account
+	// (requires organization admin access); setting it again retries an account whose reports were refused
+	AdminCapable *bool `json:"admin_capable,omitempty"`
+	// Canary true makes the account serve the canary cohort (proxy.canary_percent of sessions) only
+	Canary *bool `json:"canary,omitempty"`
+	// Priority moves the account to another failover tier: accounts of the lowest tier with a usable account
+	// serve every request (default 0)
+	Priority *int `json:"priority,omitempty" binding:"omitempty,min=0,max=1000"`
+}
+
+// SetCredentialsRequest represents the request to replace an account's tokens manually
+type SetCredentialsRequest struct {
+	// AccessToken may be omitted: the account then refreshes with RefreshToken on first use
+	AccessToken  string `json:"access_token,omitempty"`
+	RefreshToken string `json:"refresh_token"          binding:"required"`
+	ExpiresIn    int    `json:"expires_in,omitempty"   binding:"required_with=AccessToken,min=0"` // Seconds
+}
+
+// CreateManualAccountRequest represents the request to create an account from pasted tokens or an API key
+// OAuth accounts (the default) need the organization and the refresh token, api_key accounts only the API key.
+type CreateManualAccountRequest struct {
+	Name             string `json:"name"                   binding:"required"`
+	AuthType         string `json:"auth_type,omitempty"    binding:"omitempty,oneof=oauth api_key"`
+	APIKey           string `json:"api_key,omitempty"      binding:"required_if=AuthType api_key"`
+	OrganizationUUID string `json:"organization_uuid"      binding:"required_unless=AuthType api_key,omitempty,uuid"`
+	AccessToken      string `json:"access_token,omitempty"`
+	RefreshToken     string `json:"refresh_token"          binding:"required_unless=AuthType api_key"`
+	ExpiresIn        int    `json:"expires_in,omitempty"   binding:"required_with=AccessToken,min=0"` // Seconds
+}
+
+// IsAPIKey returns true if the request creates an account authenticating with an API key
+func (r *CreateManualAccountRequest) IsAPIKey() bool {
+	return r.AuthType == string(entities.AccountAuthAPIKey)
+}
+
+// ManualCredentialsParams represents query parameters for pasting account tokens
+type ManualCredentialsParams struct {
+	Verify bool `form:"verify"` // Validate the refresh token with an immediate refresh
+}
+
+// ToManualCredentials converts pasted tokens to the entity (an omitted access token counts as expired)
+func ToManualCredentials(accessToken, refreshToken string, expiresIn int) *entities.ManualCredentials {
+	if accessToken == "" {
+		expiresIn = 0
+	}
+	return &entities.ManualCredentials{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    expiresIn,
+	}
 }
 
 // AccountResponse represents the account response
 type AccountResponse struct {
-	ID               string  `json:"id"`
-	Name             string  `json:"name"`
-	OrganizationUUID string  `json:"organization_uuid"`
-	ExpiresAt        string  `json:"expires_at"` // RFC3339/ISO 8601 datetime
-	Status           string  `json:"status"`
-	RateLimitedUntil *string `json:"rate_limited_until,omitempty"` // RFC3339/ISO 8601 datetime, nil if not rate limited
-	LastRefreshError string  `json:"last_refresh_error,omitempty"` // Error message from last refresh attempt
-	CreatedAt        string  `json:"created_at"`                   // RFC3339/ISO 8601 datetime
-	UpdatedAt        string  `json:"updated_at"`                   // RFC3339/ISO 8601 datetime
+	ID               string            `json:"id"`
+	ExternalID       string            `json:"external_id,omitempty"` // Legacy ID the account had before its UUIDv7 (deprecated)
+	Name             string            `json:"name"`
+	AuthType         string            `json:"auth_type"` // oauth or api_key (the key itself is never returned)
+	OrganizationUUID string            `json:"organization_uuid"`
+	Organizations    []OrganizationDTO `json:"organizations"`
+	PlanType         string            `json:"plan_type,omitempty"` // Plan of the active organization (max, pro, team)
+	ExpiresAt        string            `json:"expires_at"`          // RFC3339/ISO 8601 datetime
+	Status           string            `json:"status"`
+	RateLimitedUntil *string           `json:"rate_limited_until,omitempty"` // RFC3339/ISO 8601 datetime, nil if not rate limited
+	LastRefreshError string            `json:"last_refresh_error,omitempty"` // Error message from last refresh attempt
+	RefreshFailures  int               `json:"refresh_failures"`             // Consecutive failed refresh attempts
+	RefreshFailedAt  *string           `json:"refresh_failed_at,omitempty"`  // RFC3339/ISO 8601 datetime of the last failed refresh
+	Usable           bool              `json:"usable"`                       // Selectable now (status, access token, refresh health and availability)
+	AutoRefresh      bool              `json:"auto_refresh"`                 // False in manual mode
+	Shadow           bool              `json:"shadow"`                       // Only receives mirrored requests
+	AdminCapable     bool              `json:"admin_capable"`                // Upstream usage reports are collected
+	Canary           bool              `json:"canary"`                       // Serves the canary cohort
+	Priority         int               `json:"priority"`                     // Failover tier (lower = preferred)
+	ProxyURL         string            `json:"proxy_url,omitempty"`          // Egress proxy with credentials masked
+	BaseURL          string            `json:"base_url,omitempty"`           // Effective Claude API base URL
+	BaseURLOverride  bool              `json:"base_url_override"`            // True when BaseURL is the account's own
+	Headers          map[string]string `json:"headers,omitempty"`            // Identification header overrides
+	SupportedModels  []string          `json:"supported_models,omitempty"`   // Model patterns served (empty = all)
+	QuotaRequests    int               `json:"quota_requests"`               // Max requests per usage window (0 = unlimited)
+	QuotaTokens      int               `json:"quota_tokens"`                 // Max tokens per usage window (0 = unlimited)
+	WindowRequests   int               `json:"window_requests"`              // Requests in current usage window
+	WindowTokens     int               `json:"window_tokens"`                // Tokens in current usage window
+	// Availability lists the weekly time ranges the account serves in (empty = always);
+	// CurrentlyAvailable is true within an availability window (or without any); NextAvailabilityChange is when
+	// the account next enters or leaves one (RFC3339/ISO 8601 datetime, nil without windows)
+	Availability           []AvailabilityWindowDTO `json:"availability,omitempty"`
+	CurrentlyAvailable     bool                    `json:"currently_available"`
+	NextAvailabilityChange *string                 `json:"next_availability_change,omitempty"`
+	// Recent refresh token fingerprints (newest first), and when another client was found rotating the token
+	// (RFC3339/ISO 8601 datetime, set while the account is conflicted)
+	RefreshLineage     []RefreshTokenRecordDTO `json:"refresh_lineage,omitempty"`
+	ConflictDetectedAt *string                 `json:"conflict_detected_at,omitempty"`
+	// Prompt cache tokens in the current usage window, and the share of its input tokens read from the cache
+	WindowCacheCreationTokens int     `json:"window_cache_creation_tokens"`
+	WindowCacheReadTokens     int     `json:"window_cache_read_tokens"`
+	WindowCacheHitRatio       float64 `json:"window_cache_hit_ratio"`
+	WindowStartedAt           *string `json:"window_started_at,omitempty"` // RFC3339/ISO 8601 datetime, nil if no active window
+	WindowResetsAt            *string `json:"window_resets_at,omitempty"`  // RFC3339/ISO 8601 datetime, nil if no active window
+	BreakerState              string  `json:"breaker_state,omitempty"`     // closed, open or half_open (omitted if the breaker is disabled)
+	ConsecutiveFails          int     `json:"consecutive_failures"`        // Upstream 403s since the last success
+	OverQuota                 bool    `json:"over_quota"`
+	Pending                   bool    `json:"pending"`                    // New account kept out of the rotation by its cooldown
+	CooldownUntil             *string `json:"cooldown_until,omitempty"`   // RFC3339/ISO 8601 datetime, nil if not pending
+	CooldownSeconds           int64   `json:"cooldown_remaining_seconds"` // Seconds until a pending account enters the rotation
+	DeletedAt                 *string `json:"deleted_at,omitempty"`       // RFC3339/ISO 8601 datetime, nil if not deleted
+	CreatedAt                 string  `json:"created_at"`                 // RFC3339/ISO 8601 datetime
+	UpdatedAt                 string  `json:"updated_at"`                 // RFC3339/ISO 8601 datetime
+	CreatedBy                 string  `json:"created_by"`                 // Admin identity, "unknown" if not recorded
+	UpdatedBy                 string  `json:"updated_by"`                 // Identity of the last admin change
 }
 
 // ToAccountResponse converts entity to response DTO (without sensitive tokens)
 func ToAccountResponse(account *entities.Account) *AccountResponse {
+	now := time.Now()
 	resp := &AccountResponse{
 		ID:               account.ID,
+		ExternalID:       account.ExternalID,
 		Name:             account.Name,
+		AuthType:         string(account.AuthType),
 		OrganizationUUID: account.OrganizationUUID,
+		Organizations:    ToOrganizationDTOs(account.Organizations),
+		PlanType:         string(account.PlanType()),
 		ExpiresAt:        account.ExpiresAt.Format(RFC3339),
 		Status:           string(account.Status),
 		LastRefreshError: account.LastRefreshError,
-		CreatedAt:        account.CreatedAt.Format(RFC3339),
-		UpdatedAt:        account.UpdatedAt.Format(RFC3339),
+		RefreshFailures:  account.RefreshFailures,
+		Usable:           account.IsUsableNow() && account.IsWithinAvailability(now),
+		AutoRefresh:      account.AutoRefresh,
+		Shadow:           account.Shadow,
+		AdminCapable:     account.AdminCapable,
+		Canary:           account.Canary,
+		Priority:         account.Priority,
+		ProxyURL:         account.MaskedProxyURL(),
+		BaseURL:          account.BaseURL,
+		BaseURLOverride:  account.BaseURL != "",
+		Headers:          account.Headers,
+		SupportedModels:  account.SupportedModels,
+		Availability:     ToAvailabilityWindowDTOs(account.Availability),
+		RefreshLineage:   ToRefreshTokenRecordDTOs(account.RefreshLineage),
+		QuotaRequests:    account.QuotaRequests,
+		QuotaTokens:      account.QuotaTokens,
+		OverQuota:        account.IsOverQuota(),
+
+		CurrentlyAvailable: account.IsWithinAvailability(now),
+		CreatedAt:          account.CreatedAt.Format(RFC3339),
+		UpdatedAt:          account.UpdatedAt.Format(RFC3339),
+		CreatedBy:          actor.OrUnknown(account.CreatedBy),
+		UpdatedBy:          actor.OrUnknown(account.UpdatedBy),
+	}
+	if account.IsAPIKey() {
+		resp.ExpiresAt = "" // API keys have no expiry
 	}
 
 	// Include rate limited until if present
@@ -128,9 +539,65 @@ func ToAccountResponse(account *entities.Account) *AccountResponse {
 		resp.RateLimitedUntil = &timestamp
 	}
 
+	if account.RefreshFailedAt != nil {
+		timestamp := account.RefreshFailedAt.Format(RFC3339)
+		resp.RefreshFailedAt = &timestamp
+	}
+
+	if change, ok := account.NextAvailabilityChange(now); ok {
+		timestamp := change.Format(RFC3339)
+		resp.NextAvailabilityChange = &timestamp
+	}
+
+	if account.ConflictDetectedAt != nil {
+		timestamp := account.ConflictDetectedAt.Format(RFC3339)
+		resp.ConflictDetectedAt = &timestamp
+	}
+
+	// Include current usage window consumption
+	resp.WindowRequests, resp.WindowTokens = account.CurrentWindowUsage()
+	_, resp.WindowCacheCreationTokens, resp.WindowCacheReadTokens = account.CurrentWindowCacheUsage()
+	resp.WindowCacheHitRatio = account.WindowCacheHitRatio()
+	if account.HasActiveUsageWindow() {
+		startedAt := account.UsageWindowStart.Format(RFC3339)
+		resetsAt := account.UsageWindowEnd().Format(RFC3339)
+		resp.WindowStartedAt = &startedAt
+		resp.WindowResetsAt = &resetsAt
+	}
+
+	if account.IsCoolingDown() {
+		timestamp := account.CooldownUntil.Format(RFC3339)
+		resp.Pending = true
+		resp.CooldownUntil = &timestamp
+		resp.CooldownSeconds = int64(account.CooldownRemaining().Round(time.Second).Seconds())
+	}
+
+	if account.DeletedAt != nil {
+		timestamp := account.DeletedAt.Format(RFC3339)
+		resp.DeletedAt = &timestamp
+	}
+
 	return resp
 }
 
+// PendingAccountResponse represents an account awaiting organization selection
+type PendingAccountResponse struct {
+	SelectionID   string            `json:"selection_id"`
+	Name          string            `json:"name"`
+	Organizations []OrganizationDTO `json:"organizations"`
+	ExpiresAt     string            `json:"expires_at"` // RFC3339/ISO 8601 datetime
+}
+
+// ToPendingAccountResponse converts a pending account to response DTO (without tokens)
+func ToPendingAccountResponse(pending *entities.PendingAccount) *PendingAccountResponse {
+	return &PendingAccountResponse{
+		SelectionID:   pending.ID,
+		Name:          pending.Name,
+		Organizations: ToOrganizationDTOs(pending.Organizations),
+		ExpiresAt:     pending.ExpiresAt.Format(RFC3339),
+	}
+}
+
 // ToAccountResponses converts entity slice to response DTO slice
 func ToAccountResponses(accounts []*entities.Account) []*AccountResponse {
 	responses := make([]*AccountResponse, len(accounts))
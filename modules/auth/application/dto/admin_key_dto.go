@@ -0,0 +1,93 @@
+package dto
+
+import (
+	"time"
+
+	"claude-proxy/modules/auth/domain/entities"
+)
+
+// ============================================================================
+// Persistence DTOs (for JSON file storage)
+// ============================================================================
+
+// AdminKeyPersistenceDTO represents the JSON structure for admin key persistence
+type AdminKeyPersistenceDTO struct {
+	ID        string  `json:"id"`
+	KeyHash   string  `json:"key_hash"` // Hex-encoded SHA-256, the key itself is never stored
+	Hint      string  `json:"hint"`
+	CreatedAt string  `json:"created_at"`           // RFC3339/ISO 8601 datetime
+	ExpiresAt *string `json:"expires_at,omitempty"` // RFC3339/ISO 8601 datetime
+}
+
+// ToAdminKeyPersistenceDTO converts admin key entity to persistence DTO
+func ToAdminKeyPersistenceDTO(key *entities.AdminKey) *AdminKeyPersistenceDTO {
+	dto := &AdminKeyPersistenceDTO{
+		ID:        key.ID,
+		KeyHash:   key.KeyHash,
+		Hint:      key.Hint,
+		CreatedAt: key.CreatedAt.Format(RFC3339),
+	}
+
+	if key.ExpiresAt != nil {
+		expiresAt := key.ExpiresAt.Format(RFC3339)
+		dto.ExpiresAt = &expiresAt
+	}
+
+	return dto
+}
+
+// FromAdminKeyPersistenceDTO converts persistence DTO to admin key entity
+func FromAdminKeyPersistenceDTO(dto *AdminKeyPersistenceDTO) *entities.AdminKey {
+	createdAt, _ := time.Parse(RFC3339, dto.CreatedAt)
+
+	key := &entities.AdminKey{
+		ID:        dto.ID,
+		KeyHash:   dto.KeyHash,
+		Hint:      dto.Hint,
+		CreatedAt: createdAt,
+	}
+
+	if dto.ExpiresAt != nil {
+		expiresAt, _ := time.Parse(RFC3339, *dto.ExpiresAt)
+		key.ExpiresAt = &expiresAt
+	}
+
+	return key
+}
+
+// ============================================================================
+// API Response DTOs (for HTTP responses - no sensitive data)
+// ============================================================================
+
+// AdminKeyResponse represents an admin key (never includes the key or its hash)
+type AdminKeyResponse struct {
+	ID        string  `json:"id"`
+	Hint      string  `json:"hint"`
+	CreatedAt string  `json:"created_at"`           // RFC3339/ISO 8601 datetime
+	ExpiresAt *string `json:"expires_at,omitempty"` // RFC3339/ISO 8601 datetime, nil if not expiring
+}
+
+// ToAdminKeyResponse converts entity to response DTO
+func ToAdminKeyResponse(key *entities.AdminKey) *AdminKeyResponse {
+	resp := &AdminKeyResponse{
+		ID:        key.ID,
+		Hint:      key.Hint,
+		CreatedAt: key.CreatedAt.Format(RFC3339),
+	}
+
+	if key.ExpiresAt != nil {
+		expiresAt := key.ExpiresAt.Format(RFC3339)
+		resp.ExpiresAt = &expiresAt
+	}
+
+	return resp
+}
+
+// ToAdminKeyResponses converts entity slice to response DTO slice
+func ToAdminKeyResponses(keys []*entities.AdminKey) []*AdminKeyResponse {
+	responses := make([]*AdminKeyResponse, len(keys))
+	for i, key := range keys {
+		responses[i] = ToAdminKeyResponse(key)
+	}
+	return responses
+}
@@ -0,0 +1,51 @@
+package dto
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"claude-proxy/modules/auth/domain/entities"
+)
+
+// ClaudeCodeCredentialsDTO represents a Claude Code credentials file (~/.claude/.credentials.json)
+// Both the nested {"claudeAiOauth": {...}} shape and a flat object with the same fields are accepted
+type ClaudeCodeCredentialsDTO struct {
+	ClaudeAiOauth *ClaudeCodeOAuthDTO `json:"claudeAiOauth,omitempty"`
+	ClaudeCodeOAuthDTO
+}
+
+// ClaudeCodeOAuthDTO holds the OAuth fields of a Claude Code credentials file
+// accessToken and expiresAt are accepted but unused: importing always refreshes the tokens
+type ClaudeCodeOAuthDTO struct {
+	AccessToken      string          `json:"accessToken"`
+	RefreshToken     string          `json:"refreshToken"`
+	ExpiresAt        json.RawMessage `json:"expiresAt,omitempty"`
+	OrganizationUUID string          `json:"organizationUuid,omitempty"`
+}
+
+// ImportAccountParams represents query parameters for importing credentials
+type ImportAccountParams struct {
+	Name string `form:"name"` // Account name (default "imported")
+}
+
+// ParseClaudeCodeCredentials parses the contents of a Claude Code credentials file
+func ParseClaudeCodeCredentials(data []byte) (*entities.ImportedCredentials, error) {
+	var file ClaudeCodeCredentialsDTO
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("invalid credentials JSON: %w", err)
+	}
+
+	oauth := file.ClaudeCodeOAuthDTO
+	if file.ClaudeAiOauth != nil {
+		oauth = *file.ClaudeAiOauth
+	}
+
+	if oauth.RefreshToken == "" {
+		return nil, fmt.Errorf("credentials have no refresh token")
+	}
+
+	return &entities.ImportedCredentials{
+		RefreshToken:     oauth.RefreshToken,
+		OrganizationUUID: oauth.OrganizationUUID,
+	}, nil
+}
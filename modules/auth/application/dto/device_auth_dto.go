@@ -0,0 +1,142 @@
+package dto
+
+import (
+	"time"
+
+	"claude-proxy/modules/auth/domain/entities"
+)
+
+// ============================================================================
+// Persistence DTOs (for JSON file storage)
+// ============================================================================
+
+// DeviceAuthPersistenceDTO represents the JSON structure for device authorization persistence
+type DeviceAuthPersistenceDTO struct {
+	ID           string  `json:"id"`
+	PollCodeHash string  `json:"poll_code_hash"` // Hex-encoded SHA-256, the polling code itself is never stored
+	State        string  `json:"state"`
+	CodeVerifier string  `json:"code_verifier"`
+	AuthorizeURL string  `json:"authorize_url"`
+	AccountName  string  `json:"account_name"`
+	OrgID        string  `json:"org_id,omitempty"`
+	CreatedBy    string  `json:"created_by"`
+	CreatedAt    string  `json:"created_at"`             // RFC3339/ISO 8601 datetime
+	ExpiresAt    string  `json:"expires_at"`             // RFC3339/ISO 8601 datetime
+	CompletedAt  *string `json:"completed_at,omitempty"` // RFC3339/ISO 8601 datetime
+	AccountID    string  `json:"account_id,omitempty"`
+	SelectionID  string  `json:"selection_id,omitempty"`
+	LastError    string  `json:"last_error,omitempty"`
+}
+
+// ToDeviceAuthPersistenceDTO converts device authorization entity to persistence DTO
+func ToDeviceAuthPersistenceDTO(auth *entities.DeviceAuthorization) *DeviceAuthPersistenceDTO {
+	dto := &DeviceAuthPersistenceDTO{
+		ID:           auth.ID,
+		PollCodeHash: auth.PollCodeHash,
+		State:        auth.State,
+		CodeVerifier: auth.CodeVerifier,
+		AuthorizeURL: auth.AuthorizeURL,
+		AccountName:  auth.AccountName,
+		OrgID:        auth.OrgID,
+		CreatedBy:    auth.CreatedBy,
+		CreatedAt:    auth.CreatedAt.Format(RFC3339),
+		ExpiresAt:    auth.ExpiresAt.Format(RFC3339),
+		AccountID:    auth.AccountID,
+		SelectionID:  auth.SelectionID,
+		LastError:    auth.LastError,
+	}
+
+	if auth.CompletedAt != nil {
+		completedAt := auth.CompletedAt.Format(RFC3339)
+		dto.CompletedAt = &completedAt
+	}
+
+	return dto
+}
+
+// FromDeviceAuthPersistenceDTO converts persistence DTO to device authorization entity
+func FromDeviceAuthPersistenceDTO(dto *DeviceAuthPersistenceDTO) *entities.DeviceAuthorization {
+	createdAt, _ := time.Parse(RFC3339, dto.CreatedAt)
+	expiresAt, _ := time.Parse(RFC3339, dto.ExpiresAt)
+
+	auth := &entities.DeviceAuthorization{
+		ID:           dto.ID,
+		PollCodeHash: dto.PollCodeHash,
+		State:        dto.State,
+		CodeVerifier: dto.CodeVerifier,
+		AuthorizeURL: dto.AuthorizeURL,
+		AccountName:  dto.AccountName,
+		OrgID:        dto.OrgID,
+		CreatedBy:    dto.CreatedBy,
+		CreatedAt:    createdAt,
+		ExpiresAt:    expiresAt,
+		AccountID:    dto.AccountID,
+		SelectionID:  dto.SelectionID,
+		LastError:    dto.LastError,
+	}
+
+	if dto.CompletedAt != nil {
+		completedAt, _ := time.Parse(RFC3339, *dto.CompletedAt)
+		auth.CompletedAt = &completedAt
+	}
+
+	return auth
+}
+
+// ============================================================================
+// API Request DTOs (for HTTP requests)
+// ============================================================================
+
+// StartDeviceAuthRequest represents the request to start a device authorization
+type StartDeviceAuthRequest struct {
+	Name      string `json:"name"                 binding:"required"` // Name of the account the authorization creates
+	OrgID     string `json:"org_id,omitempty"`                        // Pin the authorization to an organization
+	ExpiresIn int    `json:"expires_in,omitempty"`                    // Seconds until it expires (default oauth.device_ttl)
+}
+
+// CompleteDeviceAuthRequest represents the authorization code pasted to finish a device authorization
+type CompleteDeviceAuthRequest struct {
+	Code string `json:"code" binding:"required"` // "code#state", as shown by Claude after authorization
+}
+
+// ============================================================================
+// API Response DTOs (for HTTP responses - no sensitive data)
+// ============================================================================
+
+// DeviceAuthResponse represents a device authorization (never includes the polling code or PKCE verifier)
+type DeviceAuthResponse struct {
+	ID          string  `json:"id"`
+	AccountName string  `json:"account_name"`
+	OrgID       string  `json:"org_id,omitempty"`
+	Status      string  `json:"status"` // pending, requires_org_selection, completed or expired
+	CreatedBy   string  `json:"created_by"`
+	CreatedAt   string  `json:"created_at"`             // RFC3339/ISO 8601 datetime
+	ExpiresAt   string  `json:"expires_at"`             // RFC3339/ISO 8601 datetime
+	CompletedAt *string `json:"completed_at,omitempty"` // RFC3339/ISO 8601 datetime
+	AccountID   string  `json:"account_id,omitempty"`
+	SelectionID string  `json:"selection_id,omitempty"`
+	LastError   string  `json:"last_error,omitempty"`
+}
+
+// ToDeviceAuthResponse converts entity to response DTO
+func ToDeviceAuthResponse(auth *entities.DeviceAuthorization) *DeviceAuthResponse {
+	resp := &DeviceAuthResponse{
+		ID:          auth.ID,
+		AccountName: auth.AccountName,
+		OrgID:       auth.OrgID,
+		Status:      auth.Status(time.Now()),
+		CreatedBy:   auth.CreatedBy,
+		CreatedAt:   auth.CreatedAt.Format(RFC3339),
+		ExpiresAt:   auth.ExpiresAt.Format(RFC3339),
+		AccountID:   auth.AccountID,
+		SelectionID: auth.SelectionID,
+		LastError:   auth.LastError,
+	}
+
+	if auth.CompletedAt != nil {
+		completedAt := auth.CompletedAt.Format(RFC3339)
+		resp.CompletedAt = &completedAt
+	}
+
+	return resp
+}
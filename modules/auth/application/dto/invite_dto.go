@@ -0,0 +1,130 @@
+package dto
+
+import (
+	"time"
+
+	"claude-proxy/modules/auth/domain/entities"
+)
+
+// ============================================================================
+// Persistence DTOs (for JSON file storage)
+// ============================================================================
+
+// InvitePersistenceDTO represents the JSON structure for invite persistence
+type InvitePersistenceDTO struct {
+	ID          string  `json:"id"`
+	TokenHash   string  `json:"token_hash"` // Hex-encoded SHA-256, the token itself is never stored
+	AccountName string  `json:"account_name"`
+	OrgID       string  `json:"org_id,omitempty"`
+	CreatedBy   string  `json:"created_by"`
+	CreatedAt   string  `json:"created_at"`        // RFC3339/ISO 8601 datetime
+	ExpiresAt   string  `json:"expires_at"`        // RFC3339/ISO 8601 datetime
+	UsedAt      *string `json:"used_at,omitempty"` // RFC3339/ISO 8601 datetime
+	AccountID   string  `json:"account_id,omitempty"`
+	SelectionID string  `json:"selection_id,omitempty"`
+}
+
+// ToInvitePersistenceDTO converts invite entity to persistence DTO
+func ToInvitePersistenceDTO(invite *entities.Invite) *InvitePersistenceDTO {
+	dto := &InvitePersistenceDTO{
+		ID:          invite.ID,
+		TokenHash:   invite.TokenHash,
+		AccountName: invite.AccountName,
+		OrgID:       invite.OrgID,
+		CreatedBy:   invite.CreatedBy,
+		CreatedAt:   invite.CreatedAt.Format(RFC3339),
+		ExpiresAt:   invite.ExpiresAt.Format(RFC3339),
+		AccountID:   invite.AccountID,
+		SelectionID: invite.SelectionID,
+	}
+
+	if invite.UsedAt != nil {
+		usedAt := invite.UsedAt.Format(RFC3339)
+		dto.UsedAt = &usedAt
+	}
+
+	return dto
+}
+
+// FromInvitePersistenceDTO converts persistence DTO to invite entity
+func FromInvitePersistenceDTO(dto *InvitePersistenceDTO) *entities.Invite {
+	createdAt, _ := time.Parse(RFC3339, dto.CreatedAt)
+	expiresAt, _ := time.Parse(RFC3339, dto.ExpiresAt)
+
+	invite := &entities.Invite{
+		ID:          dto.ID,
+		TokenHash:   dto.TokenHash,
+		AccountName: dto.AccountName,
+		OrgID:       dto.OrgID,
+		CreatedBy:   dto.CreatedBy,
+		CreatedAt:   createdAt,
+		ExpiresAt:   expiresAt,
+		AccountID:   dto.AccountID,
+		SelectionID: dto.SelectionID,
+	}
+
+	if dto.UsedAt != nil {
+		usedAt, _ := time.Parse(RFC3339, *dto.UsedAt)
+		invite.UsedAt = &usedAt
+	}
+
+	return invite
+}
+
+// ============================================================================
+// API Request DTOs (for HTTP requests)
+// ============================================================================
+
+// CreateInviteRequest represents the request to create an account invite
+type CreateInviteRequest struct {
+	Name      string `json:"name"                 binding:"required"` // Name of the account the invite creates
+	OrgID     string `json:"org_id,omitempty"`                        // Pin the authorization to an organization
+	ExpiresIn int    `json:"expires_in,omitempty"`                    // Seconds until the link expires (default oauth.invite_ttl)
+}
+
+// ============================================================================
+// API Response DTOs (for HTTP responses - no sensitive data)
+// ============================================================================
+
+// InviteResponse represents an invite (never includes the invite token or its hash)
+type InviteResponse struct {
+	ID          string  `json:"id"`
+	AccountName string  `json:"account_name"`
+	OrgID       string  `json:"org_id,omitempty"`
+	Status      string  `json:"status"` // pending, used or expired
+	CreatedBy   string  `json:"created_by"`
+	CreatedAt   string  `json:"created_at"`        // RFC3339/ISO 8601 datetime
+	ExpiresAt   string  `json:"expires_at"`        // RFC3339/ISO 8601 datetime
+	UsedAt      *string `json:"used_at,omitempty"` // RFC3339/ISO 8601 datetime
+	AccountID   string  `json:"account_id,omitempty"`
+}
+
+// ToInviteResponse converts entity to response DTO
+func ToInviteResponse(invite *entities.Invite) *InviteResponse {
+	resp := &InviteResponse{
+		ID:          invite.ID,
+		AccountName: invite.AccountName,
+		OrgID:       invite.OrgID,
+		Status:      invite.Status(time.Now()),
+		CreatedBy:   invite.CreatedBy,
+		CreatedAt:   invite.CreatedAt.Format(RFC3339),
+		ExpiresAt:   invite.ExpiresAt.Format(RFC3339),
+		AccountID:   invite.AccountID,
+	}
+
+	if invite.UsedAt != nil {
+		usedAt := invite.UsedAt.Format(RFC3339)
+		resp.UsedAt = &usedAt
+	}
+
+	return resp
+}
+
+// ToInviteResponses converts entity slice to response DTO slice
+func ToInviteResponses(invites []*entities.Invite) []*InviteResponse {
+	responses := make([]*InviteResponse, len(invites))
+	for i, invite := range invites {
+		responses[i] = ToInviteResponse(invite)
+	}
+	return responses
+}
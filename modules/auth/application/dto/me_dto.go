@@ -0,0 +1,33 @@
+package dto
+
+import "claude-proxy/modules/auth/domain/entities"
+
+// ============================================================================
+// Self-service DTOs (GET /v1/me and friends, authenticated with the token itself)
+// ============================================================================
+
+// MeResponse describes the authenticated token to its own holder
+type MeResponse struct {
+	*TokenResponse
+	ActiveSessions int              `json:"active_sessions"`
+	Limits         MeLimitsResponse `json:"limits"`
+}
+
+// MeLimitsResponse lists the limits applied to the authenticated token
+type MeLimitsResponse struct {
+	SessionsEnabled bool   `json:"sessions_enabled"`
+	SessionScope    string `json:"session_scope,omitempty"` // global, token or both
+	// MaxSessions is the token's concurrent session limit (scopes token and both)
+	MaxSessions int `json:"max_sessions,omitempty"`
+	// MaxConcurrent is the limit shared by all tokens (scopes global and both)
+	MaxConcurrent int `json:"max_concurrent,omitempty"`
+}
+
+// ToMeResponse converts the authenticated token to its self-service response (key masked)
+func ToMeResponse(token *entities.Token, activeSessions int, limits MeLimitsResponse) *MeResponse {
+	return &MeResponse{
+		TokenResponse:  ToTokenResponse(token),
+		ActiveSessions: activeSessions,
+		Limits:         limits,
+	}
+}
@@ -0,0 +1,89 @@
+package dto
+
+import (
+	"time"
+
+	"claude-proxy/modules/auth/domain/entities"
+)
+
+// FieldChangeDTO is the value of a field before and after a change
+type FieldChangeDTO struct {
+	Old interface{} `json:"old"`
+	New interface{} `json:"new"`
+}
+
+// MutationAuditEntryDTO is an admin change of an account or a token: one line of admin_audit.jsonl, and an
+// entry of the audit API response
+type MutationAuditEntryDTO struct {
+	ID         string                    `json:"id"`
+	Timestamp  string                    `json:"timestamp"` // RFC3339/ISO 8601 datetime
+	Actor      string                    `json:"actor"`
+	Action     string                    `json:"action"`      // create, update or delete
+	EntityType string                    `json:"entity_type"` // account or token
+	EntityID   string                    `json:"entity_id"`
+	Changes    map[string]FieldChangeDTO `json:"changes,omitempty"` // By field, secrets shown as "***"
+}
+
+// ToMutationAuditEntryDTO converts an audit entry to its DTO
+func ToMutationAuditEntryDTO(entry *entities.MutationAuditEntry) *MutationAuditEntryDTO {
+	dto := &MutationAuditEntryDTO{
+		ID:         entry.ID,
+		Timestamp:  entry.Timestamp.Format(RFC3339),
+		Actor:      entry.Actor,
+		Action:     string(entry.Action),
+		EntityType: string(entry.EntityType),
+		EntityID:   entry.EntityID,
+	}
+	if len(entry.Changes) > 0 {
+		dto.Changes = make(map[string]FieldChangeDTO, len(entry.Changes))
+		for field, change := range entry.Changes {
+			dto.Changes[field] = FieldChangeDTO{Old: change.Old, New: change.New}
+		}
+	}
+	return dto
+}
+
+// FromMutationAuditEntryDTO converts a DTO to an audit entry
+func FromMutationAuditEntryDTO(dto *MutationAuditEntryDTO) *entities.MutationAuditEntry {
+	timestamp, _ := time.Parse(RFC3339, dto.Timestamp)
+	entry := &entities.MutationAuditEntry{
+		ID:         dto.ID,
+		Timestamp:  timestamp,
+		Actor:      dto.Actor,
+		Action:     entities.MutationAction(dto.Action),
+		EntityType: entities.MutationEntityType(dto.EntityType),
+		EntityID:   dto.EntityID,
+	}
+	if len(dto.Changes) > 0 {
+		entry.Changes = make(map[string]entities.FieldChange, len(dto.Changes))
+		for field, change := range dto.Changes {
+			entry.Changes[field] = entities.FieldChange{Old: change.Old, New: change.New}
+		}
+	}
+	return entry
+}
+
+// MutationAuditQueryParams represents query parameters for listing audit entries
+type MutationAuditQueryParams struct {
+	EntityType string `form:"entity_type" binding:"omitempty,oneof=account token"`
+	EntityID   string `form:"entity_id"`
+	// From and To keep the entries at or after From and before To (RFC3339 times)
+	From  time.Time `form:"from"  time_format:"2006-01-02T15:04:05Z07:00"`
+	To    time.Time `form:"to"    time_format:"2006-01-02T15:04:05Z07:00"`
+	Limit int       `form:"limit" binding:"omitempty,min=1,max=1000"` // Newest entries returned (default 100)
+}
+
+// Filter converts the query parameters to an audit filter, defaulting to the newest 100 entries
+func (q *MutationAuditQueryParams) Filter() entities.MutationAuditFilter {
+	limit := q.Limit
+	if limit == 0 {
+		limit = 100
+	}
+	return entities.MutationAuditFilter{
+		EntityType: entities.MutationEntityType(q.EntityType),
+		EntityID:   q.EntityID,
+		From:       q.From,
+		To:         q.To,
+		Limit:      limit,
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"time"
 
 	"claude-proxy/modules/auth/domain/entities"
+
+	"github.com/phathdt/service-context/core"
 )
 
 // ============================================================================
@@ -12,20 +14,23 @@ import (
 
 // SessionPersistenceDTO represents the JSON structure for session persistence
 type SessionPersistenceDTO struct {
-	ID          string `json:"id"`
-	TokenID     string `json:"token_id"`
-	UserAgent   string `json:"user_agent"`
-	IPAddress   string `json:"ip_address"`
-	CreatedAt   string `json:"created_at"`   // RFC3339/ISO 8601 datetime
-	LastSeenAt  string `json:"last_seen_at"` // RFC3339/ISO 8601 datetime
-	ExpiresAt   string `json:"expires_at"`   // RFC3339/ISO 8601 datetime
-	IsActive    bool   `json:"is_active"`
-	RequestPath string `json:"request_path,omitempty"`
+	ID         string `json:"id"`
+	TokenID    string `json:"token_id"`
+	UserAgent  string `json:"user_agent"`
+	IPAddress  string `json:"ip_address"`
+	CreatedAt  string `json:"created_at"`   // RFC3339/ISO 8601 datetime
+	LastSeenAt string `json:"last_seen_at"` // RFC3339/ISO 8601 datetime
+	ExpiresAt  string `json:"expires_at"`   // RFC3339/ISO 8601 datetime
+	// HardExpiresAt is the maximum lifetime (RFC3339); empty when the session has none (and in older files)
+	HardExpiresAt string `json:"hard_expires_at,omitempty"`
+	TokenRole     string `json:"token_role,omitempty"`
+	IsActive      bool   `json:"is_active"`
+	RequestPath   string `json:"request_path,omitempty"`
 }
 
 // ToSessionPersistenceDTO converts session entity to persistence DTO
 func ToSessionPersistenceDTO(session *entities.Session) *SessionPersistenceDTO {
-	return &SessionPersistenceDTO{
+	persisted := &SessionPersistenceDTO{
 		ID:          session.ID,
 		TokenID:     session.TokenID,
 		UserAgent:   session.UserAgent,
@@ -33,9 +38,14 @@ func ToSessionPersistenceDTO(session *entities.Session) *SessionPersistenceDTO {
 		CreatedAt:   session.CreatedAt.Format(time.RFC3339),
 		LastSeenAt:  session.LastSeenAt.Format(time.RFC3339),
 		ExpiresAt:   session.ExpiresAt.Format(time.RFC3339),
+		TokenRole:   string(session.TokenRole),
 		IsActive:    session.IsActive,
 		RequestPath: session.RequestPath,
 	}
+	if !session.HardExpiresAt.IsZero() {
+		persisted.HardExpiresAt = session.HardExpiresAt.Format(time.RFC3339)
+	}
+	return persisted
 }
 
 // FromSessionPersistenceDTO converts persistence DTO to session entity
@@ -43,17 +53,23 @@ func FromSessionPersistenceDTO(dto *SessionPersistenceDTO) *entities.Session {
 	createdAt, _ := time.Parse(time.RFC3339, dto.CreatedAt)
 	lastSeenAt, _ := time.Parse(time.RFC3339, dto.LastSeenAt)
 	expiresAt, _ := time.Parse(time.RFC3339, dto.ExpiresAt)
+	var hardExpiresAt time.Time
+	if dto.HardExpiresAt != "" {
+		hardExpiresAt, _ = time.Parse(time.RFC3339, dto.HardExpiresAt)
+	}
 
 	return &entities.Session{
-		ID:          dto.ID,
-		TokenID:     dto.TokenID,
-		UserAgent:   dto.UserAgent,
-		IPAddress:   dto.IPAddress,
-		CreatedAt:   createdAt,
-		LastSeenAt:  lastSeenAt,
-		ExpiresAt:   expiresAt,
-		IsActive:    dto.IsActive,
-		RequestPath: dto.RequestPath,
+		ID:            dto.ID,
+		TokenID:       dto.TokenID,
+		UserAgent:     dto.UserAgent,
+		IPAddress:     dto.IPAddress,
+		CreatedAt:     createdAt,
+		LastSeenAt:    lastSeenAt,
+		ExpiresAt:     expiresAt,
+		HardExpiresAt: hardExpiresAt,
+		TokenRole:     entities.TokenRole(dto.TokenRole),
+		IsActive:      dto.IsActive,
+		RequestPath:   dto.RequestPath,
 	}
 }
 
@@ -63,21 +79,67 @@ func FromSessionPersistenceDTO(dto *SessionPersistenceDTO) *entities.Session {
 
 // SessionResponse represents a session in API responses
 type SessionResponse struct {
-	ID          string `json:"id"`
-	TokenID     string `json:"token_id"`
-	UserAgent   string `json:"user_agent"`
-	IPAddress   string `json:"ip_address"`
-	CreatedAt   string `json:"created_at"`   // RFC3339/ISO 8601 datetime
-	LastSeenAt  string `json:"last_seen_at"` // RFC3339/ISO 8601 datetime
-	ExpiresAt   string `json:"expires_at"`   // RFC3339/ISO 8601 datetime
-	IsActive    bool   `json:"is_active"`
-	RequestPath string `json:"request_path"`
+	ID         string `json:"id"`
+	TokenID    string `json:"token_id"`
+	UserAgent  string `json:"user_agent"`
+	IPAddress  string `json:"ip_address"`
+	CreatedAt  string `json:"created_at"`   // RFC3339/ISO 8601 datetime
+	LastSeenAt string `json:"last_seen_at"` // RFC3339/ISO 8601 datetime
+	ExpiresAt  string `json:"expires_at"`   // Idle expiry, RFC3339/ISO 8601 datetime
+	// HardExpiresAt is the maximum lifetime the idle expiry never passes (RFC3339); null when there is none
+	HardExpiresAt *string `json:"hard_expires_at"`
+	TokenRole     string  `json:"token_role,omitempty"`
+	IsActive      bool    `json:"is_active"`
+	RequestPath   string  `json:"request_path"`
 }
 
-// ListSessionsResponse represents a list of sessions
+// ListSessionsResponse represents a page of sessions
 type ListSessionsResponse struct {
-	Sessions []*SessionResponse `json:"sessions"`
-	Total    int                `json:"total"`
+	Sessions []*SessionResponse    `json:"sessions"`
+	Total    int                   `json:"total"` // Sessions matching the filters (same as paging.total)
+	Paging   core.Paging           `json:"paging"`
+	Totals   SessionTotalsResponse `json:"totals"`
+}
+
+// SessionTotalsResponse counts all sessions by state, regardless of the filters
+type SessionTotalsResponse struct {
+	All     int `json:"all"`
+	Active  int `json:"active"`
+	Expired int `json:"expired"`
+}
+
+// ToSessionResponse converts a session entity to its response DTO
+func ToSessionResponse(session *entities.Session) *SessionResponse {
+	response := &SessionResponse{
+		ID:          session.ID,
+		TokenID:     session.TokenID,
+		UserAgent:   session.UserAgent,
+		IPAddress:   session.IPAddress,
+		CreatedAt:   session.CreatedAt.Format(RFC3339),
+		LastSeenAt:  session.LastSeenAt.Format(RFC3339),
+		ExpiresAt:   session.ExpiresAt.Format(RFC3339),
+		TokenRole:   string(session.TokenRole),
+		IsActive:    session.IsActive,
+		RequestPath: session.RequestPath,
+	}
+	if !session.HardExpiresAt.IsZero() {
+		hardExpiresAt := session.HardExpiresAt.Format(RFC3339)
+		response.HardExpiresAt = &hardExpiresAt
+	}
+	return response
+}
+
+// SessionQueryParams represents query parameters for listing sessions
+type SessionQueryParams struct {
+	TokenID string `form:"token_id"` // Sessions of one token
+	IP      string `form:"ip"`       // Exact client IP
+	Active  bool   `form:"active"`   // Only active, unexpired sessions
+	Expired bool   `form:"expired"`  // Only expired sessions
+	// CreatedAfter keeps sessions created after this RFC3339 time
+	CreatedAfter time.Time `form:"created_after" time_format:"2006-01-02T15:04:05Z07:00"`
+	// Sort orders by last_seen_at (default) or created_at, Order is desc (default) or asc
+	Sort  string `form:"sort"  binding:"omitempty,oneof=last_seen_at created_at"`
+	Order string `form:"order" binding:"omitempty,oneof=asc desc"`
 }
 
 // RevokeSessionRequest represents a request to revoke a session
@@ -85,6 +147,54 @@ type RevokeSessionRequest struct {
 	SessionID string `json:"session_id" binding:"required"`
 }
 
+// RevokeSessionsRequest represents a bulk revocation: every session matching all the given filters is revoked
+// At least one filter is required, unless All explicitly asks to revoke every session.
+type RevokeSessionsRequest struct {
+	TokenID       string     `json:"token_id,omitempty"`       // Sessions of one token
+	IP            string     `json:"ip,omitempty"`             // Exact client IP
+	CreatedBefore *time.Time `json:"created_before,omitempty"` // RFC3339, sessions created before this time
+	All           bool       `json:"all,omitempty"`            // Allows revoking without any filter
+}
+
+// HasFilter returns true if the request narrows the sessions down by at least one field
+func (r *RevokeSessionsRequest) HasFilter() bool {
+	return r.TokenID != "" || r.IP != "" || r.CreatedBefore != nil
+}
+
+// Matches returns true if the session passes every filter of the request
+func (r *RevokeSessionsRequest) Matches(session *entities.Session) bool {
+	if r.TokenID != "" && session.TokenID != r.TokenID {
+		return false
+	}
+	if r.IP != "" && session.IPAddress != r.IP {
+		return false
+	}
+	return r.CreatedBefore == nil || session.CreatedAt.Before(*r.CreatedBefore)
+}
+
+// RevokeSessionsResponse represents the outcome of a bulk revocation
+type RevokeSessionsResponse struct {
+	Revoked    int      `json:"revoked"`     // Number of sessions revoked
+	SessionIDs []string `json:"session_ids"` // Revoked session IDs, at most MaxRevokedSessionIDs
+	Truncated  bool     `json:"truncated"`   // True if SessionIDs lists only part of the revoked sessions
+}
+
+// MaxRevokedSessionIDs bounds the session IDs listed in a bulk revocation response
+const MaxRevokedSessionIDs = 100
+
+// ToRevokeSessionsResponse converts the revoked session IDs to the response DTO
+func ToRevokeSessionsResponse(sessionIDs []string) *RevokeSessionsResponse {
+	listed := sessionIDs
+	if len(listed) > MaxRevokedSessionIDs {
+		listed = listed[:MaxRevokedSessionIDs]
+	}
+	return &RevokeSessionsResponse{
+		Revoked:    len(sessionIDs),
+		SessionIDs: append([]string{}, listed...),
+		Truncated:  len(listed) < len(sessionIDs),
+	}
+}
+
 // RevokeSessionResponse represents a response to session revocation
 type RevokeSessionResponse struct {
 	Success bool   `json:"success"`
@@ -0,0 +1,86 @@
+package dto
+
+import (
+	"strings"
+	"time"
+
+	"claude-proxy/modules/auth/domain/entities"
+
+	"github.com/phathdt/service-context/core"
+)
+
+// SessionHistoryRecordDTO is a session creation: one line of session-history-YYYY-MM.jsonl, and a record of the
+// session history API response
+type SessionHistoryRecordDTO struct {
+	SessionID     string `json:"session_id"`
+	TokenID       string `json:"token_id"`
+	IPAddress     string `json:"ip_address"`      // Full IP, or its /24 (IPv6: /48) with session.history_mask_ips
+	UserAgentHash string `json:"user_agent_hash"` // Hex prefix of the SHA-256 of the full User-Agent
+	UserAgent     string `json:"user_agent"`      // Truncated to 200 characters
+	RequestPath   string `json:"request_path"`
+	CreatedAt     string `json:"created_at"` // RFC3339/ISO 8601 datetime
+}
+
+// ToSessionHistoryRecordDTO converts a session history record to its DTO
+func ToSessionHistoryRecordDTO(record *entities.SessionHistoryRecord) *SessionHistoryRecordDTO {
+	return &SessionHistoryRecordDTO{
+		SessionID:     record.SessionID,
+		TokenID:       record.TokenID,
+		IPAddress:     record.IPAddress,
+		UserAgentHash: record.UserAgentHash,
+		UserAgent:     record.UserAgent,
+		RequestPath:   record.RequestPath,
+		CreatedAt:     record.CreatedAt.Format(RFC3339),
+	}
+}
+
+// FromSessionHistoryRecordDTO converts a DTO to a session history record
+func FromSessionHistoryRecordDTO(dto *SessionHistoryRecordDTO) *entities.SessionHistoryRecord {
+	createdAt, _ := time.Parse(RFC3339, dto.CreatedAt)
+	return &entities.SessionHistoryRecord{
+		SessionID:     dto.SessionID,
+		TokenID:       dto.TokenID,
+		IPAddress:     dto.IPAddress,
+		UserAgentHash: dto.UserAgentHash,
+		UserAgent:     dto.UserAgent,
+		RequestPath:   dto.RequestPath,
+		CreatedAt:     createdAt,
+	}
+}
+
+// SessionHistoryCSVHeader is the header row of the CSV session history export
+var SessionHistoryCSVHeader = []string{
+	"created_at", "session_id", "token_id", "ip_address", "user_agent_hash", "user_agent", "request_path",
+}
+
+// CSVRow returns the record as a row of the CSV export, in the order of SessionHistoryCSVHeader
+// Client-supplied values starting like a spreadsheet formula are prefixed with a quote, so opening the export
+// never evaluates them.
+func (d *SessionHistoryRecordDTO) CSVRow() []string {
+	return []string{
+		d.CreatedAt, d.SessionID, d.TokenID, d.IPAddress, d.UserAgentHash,
+		csvSafeCell(d.UserAgent), csvSafeCell(d.RequestPath),
+	}
+}
+
+// csvSafeCell neutralizes a value a spreadsheet would read as a formula (leading =, +, -, @, tab or CR)
+func csvSafeCell(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+// SessionHistoryQueryParams represents query parameters for reading the session history
+type SessionHistoryQueryParams struct {
+	TokenID string    `form:"token_id"`                                    // Sessions of one token
+	Month   time.Time `form:"month"    time_format:"2006-01" time_utc:"1"` // UTC month (default current)
+	Format  string    `form:"format"   binding:"omitempty,oneof=json csv"` // json (default, paginated) or csv
+}
+
+// ListSessionHistoryResponse represents a page of session history records
+type ListSessionHistoryResponse struct {
+	Month   string                     `json:"month"` // YYYY-MM
+	Records []*SessionHistoryRecordDTO `json:"records"`
+	Paging  core.Paging                `json:"paging"` // total = records of the month matching the filters
+}
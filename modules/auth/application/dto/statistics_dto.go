@@ -17,6 +17,7 @@ type StatisticsResponse struct {
 	InactiveAccounts    int `json:"inactive_accounts"`
 	RateLimitedAccounts int `json:"rate_limited_accounts"`
 	InvalidAccounts     int `json:"invalid_accounts"`
+	ConflictedAccounts  int `json:"conflicted_accounts"` // Refresh token rotated by another client
 
 	// Token health metrics
 	AccountsNeedingRefresh int     `json:"accounts_needing_refresh"`
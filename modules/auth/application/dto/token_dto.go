@@ -4,36 +4,73 @@ import (
 	"time"
 
 	"claude-proxy/modules/auth/domain/entities"
+	"claude-proxy/pkg/actor"
 )
 
 // ============================================================================
 // Persistence DTOs (for JSON file storage)
 // ============================================================================
 
+// TokensFileSchemaVersion is the current layout of tokens.json
+// Version 1 stored cleartext keys; version 2 stores only their hash and display prefix
+const TokensFileSchemaVersion = 2
+
 // TokenPersistenceDTO represents the JSON structure for token persistence
 type TokenPersistenceDTO struct {
-	ID         string  `json:"id"`
-	Name       string  `json:"name"`
-	Key        string  `json:"key"`
-	Status     string  `json:"status"`
-	Role       string  `json:"role"`       // user or admin
-	CreatedAt  string  `json:"created_at"` // RFC3339/ISO 8601 datetime
-	UpdatedAt  string  `json:"updated_at"` // RFC3339/ISO 8601 datetime
-	UsageCount int     `json:"usage_count"`
-	LastUsedAt *string `json:"last_used_at,omitempty"` // RFC3339/ISO 8601 datetime
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	// Key is the cleartext key of schema version 1 files; it is hashed on load and never written
+	Key                  string   `json:"key,omitempty"`
+	KeyHash              string   `json:"key_hash"`
+	KeyPrefix            string   `json:"key_prefix"`
+	Status               string   `json:"status"`
+	Role                 string   `json:"role"`       // user or admin
+	CreatedAt            string   `json:"created_at"` // RFC3339/ISO 8601 datetime
+	UpdatedAt            string   `json:"updated_at"` // RFC3339/ISO 8601 datetime
+	UsageCount           int      `json:"usage_count"`
+	LastUsedAt           *string  `json:"last_used_at,omitempty"` // RFC3339/ISO 8601 datetime
+	AllowedPaths         []string `json:"allowed_paths,omitempty"`
+	MaxSessions          int      `json:"max_sessions,omitempty"`
+	UsageSummary         bool     `json:"usage_summary,omitempty"`
+	ExternalUserIDHeader string   `json:"external_user_id_header,omitempty"`
+	CaptureFailures      bool     `json:"capture_failures,omitempty"`
+	CreatedBy            string   `json:"created_by,omitempty"` // Admin identity, absent in older files
+	UpdatedBy            string   `json:"updated_by,omitempty"`
+}
+
+// HashLegacyKey replaces a cleartext key of schema version 1 with its hash and display prefix,
+// reporting whether there was one
+func (d *TokenPersistenceDTO) HashLegacyKey() bool {
+	if d.Key == "" {
+		return false
+	}
+	if d.KeyHash == "" {
+		d.KeyHash = entities.HashTokenKey(d.Key)
+		d.KeyPrefix = entities.TokenKeyPrefix(d.Key)
+	}
+	d.Key = ""
+	return true
 }
 
-// ToTokenPersistenceDTO converts token entity to persistence DTO (includes sensitive data)
+// ToTokenPersistenceDTO converts token entity to persistence DTO (the key is stored hashed)
 func ToTokenPersistenceDTO(token *entities.Token) *TokenPersistenceDTO {
 	dto := &TokenPersistenceDTO{
-		ID:         token.ID,
-		Name:       token.Name,
-		Key:        token.Key,
-		Status:     string(token.Status),
-		Role:       string(token.Role),
-		CreatedAt:  token.CreatedAt.Format(RFC3339),
-		UpdatedAt:  token.UpdatedAt.Format(RFC3339),
-		UsageCount: token.UsageCount,
+		ID:                   token.ID,
+		Name:                 token.Name,
+		KeyHash:              token.KeyHash,
+		KeyPrefix:            token.KeyPrefix,
+		Status:               string(token.Status),
+		Role:                 string(token.Role),
+		CreatedAt:            token.CreatedAt.Format(RFC3339),
+		UpdatedAt:            token.UpdatedAt.Format(RFC3339),
+		UsageCount:           token.UsageCount,
+		AllowedPaths:         token.AllowedPaths,
+		MaxSessions:          token.MaxSessions,
+		UsageSummary:         token.UsageSummary,
+		ExternalUserIDHeader: token.ExternalUserIDHeader,
+		CaptureFailures:      token.CaptureFailures,
+		CreatedBy:            token.CreatedBy,
+		UpdatedBy:            token.UpdatedBy,
 	}
 
 	if token.LastUsedAt != nil {
@@ -44,8 +81,9 @@ func ToTokenPersistenceDTO(token *entities.Token) *TokenPersistenceDTO {
 	return dto
 }
 
-// FromTokenPersistenceDTO converts persistence DTO to token entity
+// FromTokenPersistenceDTO converts persistence DTO to token entity (cleartext keys are hashed)
 func FromTokenPersistenceDTO(dto *TokenPersistenceDTO) *entities.Token {
+	dto.HashLegacyKey()
 	createdAt, _ := time.Parse(RFC3339, dto.CreatedAt)
 	updatedAt, _ := time.Parse(RFC3339, dto.UpdatedAt)
 
@@ -56,14 +94,22 @@ func FromTokenPersistenceDTO(dto *TokenPersistenceDTO) *entities.Token {
 	}
 
 	token := &entities.Token{
-		ID:         dto.ID,
-		Name:       dto.Name,
-		Key:        dto.Key,
-		Status:     entities.TokenStatus(dto.Status),
-		Role:       role,
-		CreatedAt:  createdAt,
-		UpdatedAt:  updatedAt,
-		UsageCount: dto.UsageCount,
+		ID:                   dto.ID,
+		Name:                 dto.Name,
+		KeyHash:              dto.KeyHash,
+		KeyPrefix:            dto.KeyPrefix,
+		Status:               entities.TokenStatus(dto.Status),
+		Role:                 role,
+		CreatedAt:            createdAt,
+		UpdatedAt:            updatedAt,
+		UsageCount:           dto.UsageCount,
+		AllowedPaths:         dto.AllowedPaths,
+		MaxSessions:          dto.MaxSessions,
+		UsageSummary:         dto.UsageSummary,
+		CaptureFailures:      dto.CaptureFailures,
+		ExternalUserIDHeader: dto.ExternalUserIDHeader,
+		CreatedBy:            dto.CreatedBy,
+		UpdatedBy:            dto.UpdatedBy,
 	}
 
 	if dto.LastUsedAt != nil {
@@ -82,7 +128,7 @@ func FromTokenPersistenceDTO(dto *TokenPersistenceDTO) *entities.Token {
 type TokenQueryParams struct {
 	Role   string `form:"role"`   // Filter by role (user/admin)
 	Status string `form:"status"` // Filter by status (active/inactive/revoked)
-	Search string `form:"search"` // Search by name or key
+	Search string `form:"search"` // Search by name, key prefix or full key
 }
 
 // ============================================================================
@@ -94,7 +140,17 @@ type CreateTokenRequest struct {
 	Name   string `json:"name"   binding:"required"`
 	Key    string `json:"key"    binding:"required"`
 	Status string `json:"status" binding:"required,oneof=active inactive revoked"`
-	Role   string `json:"role"   binding:"required,oneof=user admin"`
+	Role   string `json:"role"   binding:"required,oneof=user admin viewer"`
+	// AllowedPaths lists extra proxy path patterns for this token (optional)
+	AllowedPaths []string `json:"allowed_paths,omitempty"`
+	// MaxSessions overrides the per-token concurrent session limit (optional, 0 uses the default)
+	MaxSessions int `json:"max_sessions,omitempty" binding:"omitempty,min=0"`
+	// UsageSummary appends a proxy_usage event with token usage to streaming responses (optional)
+	UsageSummary bool `json:"usage_summary,omitempty"`
+	// ExternalUserIDHeader names a request header whose value becomes metadata.user_id (optional)
+	ExternalUserIDHeader string `json:"external_user_id_header,omitempty"`
+	// CaptureFailures keeps the envelopes of the token's last failed requests for replay (optional)
+	CaptureFailures bool `json:"capture_failures,omitempty"`
 }
 
 // UpdateTokenRequest represents the request to update a token
@@ -102,7 +158,17 @@ type UpdateTokenRequest struct {
 	Name   *string `json:"name,omitempty"`
 	Key    *string `json:"key,omitempty"`
 	Status *string `json:"status,omitempty" binding:"omitempty,oneof=active inactive revoked"`
-	Role   *string `json:"role,omitempty"   binding:"omitempty,oneof=user admin"`
+	Role   *string `json:"role,omitempty"   binding:"omitempty,oneof=user admin viewer"`
+	// AllowedPaths replaces the token's extra proxy path patterns (empty list clears them)
+	AllowedPaths *[]string `json:"allowed_paths,omitempty"`
+	// MaxSessions replaces the per-token concurrent session limit override (0 clears it)
+	MaxSessions *int `json:"max_sessions,omitempty" binding:"omitempty,min=0"`
+	// UsageSummary enables or disables the proxy_usage event appended to streaming responses
+	UsageSummary *bool `json:"usage_summary,omitempty"`
+	// ExternalUserIDHeader replaces the header metadata.user_id is taken from ("" clears it)
+	ExternalUserIDHeader *string `json:"external_user_id_header,omitempty"`
+	// CaptureFailures enables or disables keeping the envelopes of the token's failed requests
+	CaptureFailures *bool `json:"capture_failures,omitempty"`
 }
 
 // ============================================================================
@@ -111,36 +177,47 @@ type UpdateTokenRequest struct {
 
 // TokenResponse represents the token response
 type TokenResponse struct {
-	ID         string  `json:"id"`
-	Name       string  `json:"name"`
-	Key        string  `json:"key"` // Masked for security (first 6 + last 6 chars)
-	Status     string  `json:"status"`
-	Role       string  `json:"role"`
-	CreatedAt  string  `json:"created_at"` // RFC3339/ISO 8601 datetime
-	UpdatedAt  string  `json:"updated_at"` // RFC3339/ISO 8601 datetime
-	UsageCount int     `json:"usage_count"`
-	LastUsedAt *string `json:"last_used_at,omitempty"` // RFC3339/ISO 8601 datetime
+	ID                   string   `json:"id"`
+	Name                 string   `json:"name"`
+	Key                  string   `json:"key"` // Masked for security (display prefix only)
+	Status               string   `json:"status"`
+	Role                 string   `json:"role"`
+	CreatedAt            string   `json:"created_at"` // RFC3339/ISO 8601 datetime
+	UpdatedAt            string   `json:"updated_at"` // RFC3339/ISO 8601 datetime
+	UsageCount           int      `json:"usage_count"`
+	LastUsedAt           *string  `json:"last_used_at,omitempty"` // RFC3339/ISO 8601 datetime
+	AllowedPaths         []string `json:"allowed_paths,omitempty"`
+	MaxSessions          int      `json:"max_sessions,omitempty"`
+	UsageSummary         bool     `json:"usage_summary,omitempty"`
+	ExternalUserIDHeader string   `json:"external_user_id_header,omitempty"`
+	CaptureFailures      bool     `json:"capture_failures,omitempty"`
+	CreatedBy            string   `json:"created_by"` // Admin identity, "unknown" if not recorded
+	UpdatedBy            string   `json:"updated_by"` // Admin identity of the last change, "unknown" if not recorded
 }
 
-// maskKey masks the API key showing only first 6 and last 6 characters
-func maskKey(key string) string {
-	if len(key) <= 12 {
-		return "***"
-	}
-	return key[:6] + "***" + key[len(key)-6:]
+// maskKey masks the API key showing only its display prefix
+func maskKey(token *entities.Token) string {
+	return token.KeyPrefix + "***"
 }
 
 // ToTokenResponse converts entity to response DTO with masked key
 func ToTokenResponse(token *entities.Token) *TokenResponse {
 	resp := &TokenResponse{
-		ID:         token.ID,
-		Name:       token.Name,
-		Key:        maskKey(token.Key),
-		Status:     string(token.Status),
-		Role:       string(token.Role),
-		CreatedAt:  token.CreatedAt.Format(RFC3339),
-		UpdatedAt:  token.UpdatedAt.Format(RFC3339),
-		UsageCount: token.UsageCount,
+		ID:                   token.ID,
+		Name:                 token.Name,
+		Key:                  maskKey(token),
+		Status:               string(token.Status),
+		Role:                 string(token.Role),
+		CreatedAt:            token.CreatedAt.Format(RFC3339),
+		UpdatedAt:            token.UpdatedAt.Format(RFC3339),
+		UsageCount:           token.UsageCount,
+		AllowedPaths:         token.AllowedPaths,
+		MaxSessions:          token.MaxSessions,
+		UsageSummary:         token.UsageSummary,
+		ExternalUserIDHeader: token.ExternalUserIDHeader,
+		CaptureFailures:      token.CaptureFailures,
+		CreatedBy:            actor.OrUnknown(token.CreatedBy),
+		UpdatedBy:            actor.OrUnknown(token.UpdatedBy),
 	}
 
 	if token.LastUsedAt != nil {
@@ -151,17 +228,30 @@ func ToTokenResponse(token *entities.Token) *TokenResponse {
 	return resp
 }
 
-// ToTokenResponseWithFullKey converts entity to response DTO with full key (use only for Create)
+// ToTokenResponseWithFullKey converts entity to response DTO with full key (use only right after the key is set,
+// the only time it is known; otherwise the key is masked)
 func ToTokenResponseWithFullKey(token *entities.Token) *TokenResponse {
+	key := token.Key // Full key, not masked
+	if key == "" {
+		key = maskKey(token)
+	}
+
 	resp := &TokenResponse{
-		ID:         token.ID,
-		Name:       token.Name,
-		Key:        token.Key, // Full key, not masked
-		Status:     string(token.Status),
-		Role:       string(token.Role),
-		CreatedAt:  token.CreatedAt.Format(RFC3339),
-		UpdatedAt:  token.UpdatedAt.Format(RFC3339),
-		UsageCount: token.UsageCount,
+		ID:                   token.ID,
+		Name:                 token.Name,
+		Key:                  key,
+		Status:               string(token.Status),
+		Role:                 string(token.Role),
+		CreatedAt:            token.CreatedAt.Format(RFC3339),
+		UpdatedAt:            token.UpdatedAt.Format(RFC3339),
+		UsageCount:           token.UsageCount,
+		AllowedPaths:         token.AllowedPaths,
+		MaxSessions:          token.MaxSessions,
+		UsageSummary:         token.UsageSummary,
+		ExternalUserIDHeader: token.ExternalUserIDHeader,
+		CaptureFailures:      token.CaptureFailures,
+		CreatedBy:            actor.OrUnknown(token.CreatedBy),
+		UpdatedBy:            actor.OrUnknown(token.UpdatedBy),
 	}
 
 	if token.LastUsedAt != nil {
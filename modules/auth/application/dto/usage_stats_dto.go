@@ -0,0 +1,218 @@
+package dto
+
+import (
+	"sort"
+	"time"
+
+	"claude-proxy/modules/auth/domain/entities"
+)
+
+// ============================================================================
+// Persistence DTOs (for JSON file storage)
+// ============================================================================
+
+// UsageBucketPersistenceDTO represents the JSON structure for usage bucket persistence
+type UsageBucketPersistenceDTO struct {
+	TokenID      string `json:"token_id"`
+	Start        string `json:"start"` // RFC3339/ISO 8601 datetime
+	Requests     int    `json:"requests"`
+	Errors       int    `json:"errors,omitempty"`
+	InputTokens  int    `json:"input_tokens,omitempty"`
+	OutputTokens int    `json:"output_tokens,omitempty"`
+	// Prompt cache tokens (absent in files written before they were tracked)
+	CacheCreationTokens int   `json:"cache_creation_input_tokens,omitempty"`
+	CacheReadTokens     int   `json:"cache_read_input_tokens,omitempty"`
+	TotalLatencyMs      int64 `json:"total_latency_ms,omitempty"`
+	// ErrorCodes counts proxy-side failures by classification code
+	ErrorCodes map[string]int `json:"error_codes,omitempty"`
+	// Users splits the requests by end user (metadata.user_id, hashed with privacy.hash_user_ids)
+	Users []*EndUserUsagePersistenceDTO `json:"users,omitempty"`
+}
+
+// EndUserUsagePersistenceDTO represents the JSON structure of an end user's totals in a usage bucket
+type EndUserUsagePersistenceDTO struct {
+	UserID       string `json:"user_id"`
+	Requests     int    `json:"requests"`
+	InputTokens  int    `json:"input_tokens,omitempty"`
+	OutputTokens int    `json:"output_tokens,omitempty"`
+	LastSeen     string `json:"last_seen"` // RFC3339/ISO 8601 datetime
+}
+
+// ToUsageBucketPersistenceDTO converts usage bucket entity to persistence DTO
+func ToUsageBucketPersistenceDTO(bucket *entities.UsageBucket) *UsageBucketPersistenceDTO {
+	dto := &UsageBucketPersistenceDTO{
+		TokenID:        bucket.TokenID,
+		Start:          bucket.Start.Format(RFC3339),
+		Requests:       bucket.Requests,
+		Errors:         bucket.Errors,
+		InputTokens:    bucket.InputTokens,
+		OutputTokens:   bucket.OutputTokens,
+		TotalLatencyMs: bucket.TotalLatencyMs,
+		ErrorCodes:     bucket.ErrorCodes,
+
+		CacheCreationTokens: bucket.CacheCreationTokens,
+		CacheReadTokens:     bucket.CacheReadTokens,
+	}
+
+	for _, user := range bucket.Users {
+		dto.Users = append(dto.Users, &EndUserUsagePersistenceDTO{
+			UserID:       user.UserID,
+			Requests:     user.Requests,
+			InputTokens:  user.InputTokens,
+			OutputTokens: user.OutputTokens,
+			LastSeen:     user.LastSeen.Format(RFC3339),
+		})
+	}
+	// Stable order so unchanged buckets serialize identically
+	sort.Slice(dto.Users, func(i, j int) bool {
+		return dto.Users[i].UserID < dto.Users[j].UserID
+	})
+
+	return dto
+}
+
+// FromUsageBucketPersistenceDTO converts persistence DTO to usage bucket entity
+func FromUsageBucketPersistenceDTO(dto *UsageBucketPersistenceDTO) *entities.UsageBucket {
+	start, _ := time.Parse(RFC3339, dto.Start)
+
+	bucket := &entities.UsageBucket{
+		TokenID:        dto.TokenID,
+		Start:          start,
+		Requests:       dto.Requests,
+		Errors:         dto.Errors,
+		InputTokens:    dto.InputTokens,
+		OutputTokens:   dto.OutputTokens,
+		TotalLatencyMs: dto.TotalLatencyMs,
+		ErrorCodes:     dto.ErrorCodes,
+
+		CacheCreationTokens: dto.CacheCreationTokens,
+		CacheReadTokens:     dto.CacheReadTokens,
+	}
+
+	if len(dto.Users) > 0 {
+		bucket.Users = make(map[string]*entities.EndUserUsage, len(dto.Users))
+		for _, user := range dto.Users {
+			lastSeen, _ := time.Parse(RFC3339, user.LastSeen)
+			bucket.Users[user.UserID] = &entities.EndUserUsage{
+				UserID:       user.UserID,
+				Requests:     user.Requests,
+				InputTokens:  user.InputTokens,
+				OutputTokens: user.OutputTokens,
+				LastSeen:     lastSeen,
+			}
+		}
+	}
+
+	return bucket
+}
+
+// ============================================================================
+// Query DTOs
+// ============================================================================
+
+// UsageStatsQueryParams represents query parameters for usage statistics
+type UsageStatsQueryParams struct {
+	Period string `form:"period"` // e.g. 24h, 7d (default 24h)
+	Bucket string `form:"bucket"` // e.g. 5m, 1h, 1d (default 1h)
+	Limit  int    `form:"limit"`  // Max tokens in ranking or users listed (default all)
+}
+
+// ============================================================================
+// API Response DTOs
+// ============================================================================
+
+// UsageBucketResponse represents one time bucket of token usage
+type UsageBucketResponse struct {
+	Start        string  `json:"start"` // RFC3339/ISO 8601 datetime
+	Requests     int     `json:"requests"`
+	Errors       int     `json:"errors"`
+	InputTokens  int     `json:"input_tokens"`
+	OutputTokens int     `json:"output_tokens"`
+	AvgLatencyMs float64 `json:"avg_latency_ms"`
+	// Prompt cache tokens, and the share of all input tokens read from the cache
+	CacheCreationTokens int     `json:"cache_creation_input_tokens"`
+	CacheReadTokens     int     `json:"cache_read_input_tokens"`
+	CacheHitRatio       float64 `json:"cache_hit_ratio"`
+	// ErrorCodes counts proxy-side failures by classification code (e.g. UPSTREAM_TIMEOUT)
+	ErrorCodes map[string]int `json:"error_codes,omitempty"`
+}
+
+// ToUsageBucketResponses converts buckets to response DTOs
+func ToUsageBucketResponses(buckets []*entities.UsageBucket) []*UsageBucketResponse {
+	responses := make([]*UsageBucketResponse, len(buckets))
+	for i, bucket := range buckets {
+		responses[i] = &UsageBucketResponse{
+			Start:        bucket.Start.Format(RFC3339),
+			Requests:     bucket.Requests,
+			Errors:       bucket.Errors,
+			InputTokens:  bucket.InputTokens,
+			OutputTokens: bucket.OutputTokens,
+			AvgLatencyMs: bucket.AverageLatencyMs(),
+			ErrorCodes:   bucket.ErrorCodes,
+
+			CacheCreationTokens: bucket.CacheCreationTokens,
+			CacheReadTokens:     bucket.CacheReadTokens,
+			CacheHitRatio:       bucket.CacheHitRatio(),
+		}
+	}
+	return responses
+}
+
+// TokenUsageRankingResponse represents a token's totals in the usage ranking
+type TokenUsageRankingResponse struct {
+	TokenID      string  `json:"token_id"`
+	TokenName    string  `json:"token_name,omitempty"` // Empty if the token was deleted
+	Requests     int     `json:"requests"`
+	Errors       int     `json:"errors"`
+	InputTokens  int     `json:"input_tokens"`
+	OutputTokens int     `json:"output_tokens"`
+	AvgLatencyMs float64 `json:"avg_latency_ms"`
+	// Prompt cache tokens, and the share of all input tokens read from the cache
+	CacheCreationTokens int     `json:"cache_creation_input_tokens"`
+	CacheReadTokens     int     `json:"cache_read_input_tokens"`
+	CacheHitRatio       float64 `json:"cache_hit_ratio"`
+	// ErrorCodes counts proxy-side failures by classification code
+	ErrorCodes map[string]int `json:"error_codes,omitempty"`
+}
+
+// ToTokenUsageRankingResponse converts a token's aggregated bucket to a ranking entry
+func ToTokenUsageRankingResponse(total *entities.UsageBucket, tokenName string) *TokenUsageRankingResponse {
+	return &TokenUsageRankingResponse{
+		TokenID:      total.TokenID,
+		TokenName:    tokenName,
+		Requests:     total.Requests,
+		Errors:       total.Errors,
+		InputTokens:  total.InputTokens,
+		OutputTokens: total.OutputTokens,
+		AvgLatencyMs: total.AverageLatencyMs(),
+		ErrorCodes:   total.ErrorCodes,
+
+		CacheCreationTokens: total.CacheCreationTokens,
+		CacheReadTokens:     total.CacheReadTokens,
+		CacheHitRatio:       total.CacheHitRatio(),
+	}
+}
+
+// EndUserUsageResponse represents an end user's totals for a token over the queried period
+type EndUserUsageResponse struct {
+	UserID       string `json:"user_id"` // metadata.user_id as sent, or its hash with privacy.hash_user_ids
+	Requests     int    `json:"requests"`
+	InputTokens  int    `json:"input_tokens"`
+	OutputTokens int    `json:"output_tokens"`
+	LastSeen     string `json:"last_seen"` // RFC3339/ISO 8601 datetime
+}
+
+// ToEndUserUsageResponses converts end user totals to response DTOs
+func ToEndUserUsageResponses(users []*entities.EndUserUsage) []*EndUserUsageResponse {
+	responses := make([]*EndUserUsageResponse, len(users))
+	for i, user := range users {
+		responses[i] = &EndUserUsageResponse{
+			UserID:       user.UserID,
+			Requests:     user.Requests,
+			InputTokens:  user.InputTokens,
+			OutputTokens: user.OutputTokens,
+			LastSeen:     user.LastSeen.Format(RFC3339),
+		}
+	}
+	return responses
+}
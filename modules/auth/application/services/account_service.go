@@ -2,15 +2,27 @@ package services
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
 	"sync"
 	"time"
 
+	"claude-proxy/modules/auth/application/dto"
 	"claude-proxy/modules/auth/domain/entities"
 	"claude-proxy/modules/auth/domain/interfaces"
+	"claude-proxy/modules/auth/infrastructure/clients"
+	"claude-proxy/pkg/httpproxy"
+	"claude-proxy/pkg/uid"
 
-	"github.com/google/uuid"
 	sctx "github.com/phathdt/service-context"
+	"golang.org/x/sync/singleflight"
 )
 
 // AccountService implements account management with hybrid storage pattern
@@ -20,7 +32,13 @@ type AccountService struct {
 	persistenceRepo interfaces.PersistenceRepository
 	oauthClient     interfaces.OAuthClient
 	dirty           bool
+	removed         bool              // Entries were removed from the cache since the last save
+	fileHashes      map[string]string // Hash of each account as last loaded from or saved to the file, by ID
 	mu              sync.RWMutex
+	refreshes       singleflight.Group                  // Token refreshes in flight, by account ID
+	pending         map[string]*entities.PendingAccount // Code exchanges awaiting organization selection
+	pendingMu       sync.Mutex
+	cooldown        time.Duration // How long new accounts stay out of the rotation (0 = none)
 	logger          sctx.Logger
 }
 
@@ -29,6 +47,7 @@ func NewAccountService(
 	cacheRepo interfaces.CacheRepository,
 	persistenceRepo interfaces.PersistenceRepository,
 	oauthClient interfaces.OAuthClient,
+	newAccountCooldown time.Duration,
 	appLogger sctx.Logger,
 ) interfaces.AccountService {
 	logger := appLogger.Withs(sctx.Fields{"component": "account-service"})
@@ -38,6 +57,8 @@ func NewAccountService(
 		persistenceRepo: persistenceRepo,
 		oauthClient:     oauthClient,
 		dirty:           false,
+		pending:         make(map[string]*entities.PendingAccount),
+		cooldown:        newAccountCooldown,
 		logger:          logger,
 	}
 
@@ -68,6 +89,7 @@ func (s *AccountService) loadFromPersistence() error {
 			}).Warn("Failed to load account into cache")
 		}
 	}
+	s.fileHashes = accountFileHashes(accounts)
 
 	s.logger.Withs(sctx.Fields{"count": len(accounts)}).Info("Accounts loaded from persistence to cache")
 	return nil
@@ -87,141 +109,1408 @@ func (s *AccountService) isDirty() bool {
 	return s.dirty
 }
 
-// clearDirty clears the dirty flag
-func (s *AccountService) clearDirty() {
+// markRemoved marks data as changed by removing entries, so the next save may leave the storage empty
+func (s *AccountService) markRemoved() {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	s.dirty = true
+	s.removed = true
+}
+
+// clearDirty clears the dirty flag, returning whether entries were removed since the last save
+func (s *AccountService) clearDirty() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	removed := s.removed
 	s.dirty = false
+	s.removed = false
+	return removed
+}
+
+// restoreDirty marks data as changed again after a failed save
+func (s *AccountService) restoreDirty(removed bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.dirty = true
+	s.removed = s.removed || removed
+}
+
+// Sync syncs cache data to persistent storage (called every 1 minute)
+// Changes another writer made to the storage since it was last loaded are merged into the cache
+// first, so they are not overwritten by the save
+func (s *AccountService) Sync(ctx context.Context) error {
+	changed, err := s.persistenceRepo.Changed(ctx)
+	if err != nil {
+		s.logger.Withs(sctx.Fields{"error": err}).Warn("Failed to check accounts storage for external changes")
+	} else if changed {
+		if _, err := s.reconcile(ctx, true); err != nil {
+			return fmt.Errorf("failed to reconcile accounts: %w", err)
+		}
+	}
+
+	if !s.isDirty() {
+		return nil // No changes, skip sync
+	}
+
+	s.logger.Debug("Syncing accounts to persistent storage")
+
+	// Clear before listing so changes made during the save mark the data dirty again
+	removed := s.clearDirty()
+
+	// Get all accounts from cache
+	accounts, err := s.cacheRepo.List(ctx)
+	if err != nil {
+		s.restoreDirty(removed)
+		return fmt.Errorf("failed to list accounts from cache: %w", err)
+	}
+
+	// Batch save all accounts to persistent storage
+	if err := s.persistenceRepo.SaveAll(ctx, accounts, removed); err != nil {
+		s.restoreDirty(removed)
+		s.logger.Withs(sctx.Fields{
+			"error": err,
+		}).Error("Failed to save accounts to persistence")
+		return fmt.Errorf("failed to save accounts: %w", err)
+	}
+	s.setFileHashes(accounts)
+
+	s.logger.Withs(sctx.Fields{"count": len(accounts)}).Info("Accounts synced to persistent storage")
+	return nil
+}
+
+// Reload merges accounts.json into the cache now, whether or not another writer modified it
+func (s *AccountService) Reload(ctx context.Context) (*entities.ReconcileReport, error) {
+	changed, err := s.persistenceRepo.Changed(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check accounts storage: %w", err)
+	}
+
+	report, err := s.reconcile(ctx, changed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reconcile accounts: %w", err)
+	}
+	return report, nil
+}
+
+// Mirror replaces the cached accounts with a primary instance's (warm standby)
+// Accounts the primary no longer holds are removed first, then every account of the primary is added or
+// replaced as is, whatever its update time; the next sync writes the result to accounts.json.
+func (s *AccountService) Mirror(ctx context.Context, accounts []*entities.Account) (*entities.MirrorResult, error) {
+	cached, err := s.cacheRepo.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list accounts from cache: %w", err)
+	}
+
+	primary := make(map[string]bool, len(accounts))
+	for _, account := range accounts {
+		primary[account.ID] = true
+	}
+	existing := make(map[string]bool, len(cached))
+	result := &entities.MirrorResult{}
+	for _, account := range cached {
+		if primary[account.ID] {
+			existing[account.ID] = true
+			continue
+		}
+		if err := s.cacheRepo.Delete(ctx, account.ID); err != nil {
+			return nil, fmt.Errorf("failed to remove account %s: %w", account.ID, err)
+		}
+		result.Removed++
+	}
+
+	for _, account := range accounts {
+		if existing[account.ID] {
+			err = s.cacheRepo.Update(ctx, account)
+		} else {
+			err = s.cacheRepo.Create(ctx, account)
+		}
+		if err != nil {
+			result.Skipped++
+			s.logger.Withs(sctx.Fields{"account_id": account.ID, "error": err.Error()}).Warn(
+				"Account of the primary conflicts with the cache, skipped",
+			)
+			continue
+		}
+		if existing[account.ID] {
+			result.Updated++
+		} else {
+			result.Added++
+		}
+	}
+
+	if result.Removed > 0 {
+		s.markRemoved()
+	} else {
+		s.markDirty()
+	}
+	return result, nil
+}
+
+// reconcile merges the accounts in storage into the cache
+// Accounts only on disk are added, and accounts changed on disk since the last load or save replace the cached
+// version, keeping the usage counted in the cache. When an account changed in the cache too, the conflict is
+// logged and the most recently updated version is kept. Accounts only in the cache are kept and written back
+// by the next save. Every merged account is logged.
+func (s *AccountService) reconcile(ctx context.Context, changed bool) (*entities.ReconcileReport, error) {
+	stored, err := s.persistenceRepo.LoadAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	cached, err := s.cacheRepo.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[string]*entities.Account, len(cached))
+	for _, account := range cached {
+		byID[account.ID] = account
+	}
+
+	s.mu.RLock()
+	base := s.fileHashes
+	s.mu.RUnlock()
+
+	report := &entities.ReconcileReport{Changed: changed}
+	for _, account := range stored {
+		_, ok := byID[account.ID]
+		switch {
+		case !ok:
+			if err := s.cacheRepo.Create(ctx, account); err != nil {
+				report.Skipped = append(report.Skipped, account.ID)
+				s.logger.Withs(sctx.Fields{"account_id": account.ID, "error": err.Error()}).Warn(
+					"Account in accounts file conflicts with the cache, keeping the cached version",
+				)
+				break
+			}
+			report.Added = append(report.Added, account.ID)
+			s.logger.Withs(sctx.Fields{
+				"account_id":   account.ID,
+				"account_name": account.Name,
+				"status":       string(account.Status),
+			}).Info("Account added from accounts file")
+		case accountFileHash(account) != base[account.ID]:
+			s.mergeStoredAccount(ctx, account, base[account.ID], report)
+		}
+		delete(byID, account.ID)
+	}
+	for id := range byID {
+		report.CacheOnly = append(report.CacheOnly, id)
+	}
+	slices.Sort(report.CacheOnly)
+	s.setFileHashes(stored)
+
+	// The cache holds accounts or changes the file lacks: write them back
+	if len(report.CacheOnly) > 0 || len(report.Added)+len(report.Updated) < len(stored) {
+		s.markDirty()
+	}
+
+	fields := sctx.Fields{
+		"added":      len(report.Added),
+		"updated":    len(report.Updated),
+		"cache_only": len(report.CacheOnly),
+		"skipped":    len(report.Skipped),
+		"conflicts":  len(report.Conflicts),
+	}
+	if len(report.CacheOnly) > 0 {
+		fields["cache_only_ids"] = report.CacheOnly
+	}
+	if changed {
+		s.logger.Withs(fields).Warn("Accounts file was modified by another writer, merged its changes into the cache")
+	} else {
+		s.logger.Withs(fields).Info("Accounts file reloaded into the cache")
+	}
+	return report, nil
+}
+
+// errKeepCached aborts replacing a cached record by the file version during a reconcile
+var errKeepCached = errors.New("cached version kept")
+
+// mergeStoredAccount replaces the cached account by its version in the file, which changed since the last load
+// or save (baseHash), unless the cached account changed too and was updated more recently
+func (s *AccountService) mergeStoredAccount(
+	ctx context.Context,
+	account *entities.Account,
+	baseHash string,
+	report *entities.ReconcileReport,
+) {
+	fileHash := accountFileHash(account)
+	var previous *entities.Account
+	conflict := false
+	_, err := s.cacheRepo.UpdateWith(ctx, account.ID, func(cached *entities.Account) error {
+		cachedHash := accountFileHash(cached)
+		if cachedHash == fileHash {
+			return errKeepCached // Both sides made the same change
+		}
+		previous = cached.Clone()
+		conflict = cachedHash != baseHash
+		// Persisted times have second precision
+		if conflict && !account.UpdatedAt.After(cached.UpdatedAt.Truncate(time.Second)) {
+			return errKeepCached
+		}
+
+		// Usage is not part of the hash: keep what the cache counted since the file was written
+		*cached = *account.Clone()
+		cached.UsageWindowStart = previous.UsageWindowStart
+		cached.WindowRequests = previous.WindowRequests
+		cached.WindowTokens = previous.WindowTokens
+		cached.WindowInputTokens = previous.WindowInputTokens
+		cached.WindowCacheCreationTokens = previous.WindowCacheCreationTokens
+		cached.WindowCacheReadTokens = previous.WindowCacheReadTokens
+		return nil
+	})
+	if err != nil && !errors.Is(err, errKeepCached) {
+		report.Skipped = append(report.Skipped, account.ID)
+		s.logger.Withs(sctx.Fields{"account_id": account.ID, "error": err.Error()}).Warn(
+			"Account in accounts file conflicts with the cache, keeping the cached version",
+		)
+		return
+	}
+	if previous == nil {
+		return
+	}
+
+	fields := sctx.Fields{
+		"account_id":        account.ID,
+		"account_name":      account.Name,
+		"status":            string(account.Status),
+		"cached_status":     string(previous.Status),
+		"updated_at":        account.UpdatedAt.Format(time.RFC3339),
+		"cached_updated_at": previous.UpdatedAt.Format(time.RFC3339),
+	}
+	if conflict {
+		report.Conflicts = append(report.Conflicts, account.ID)
+		fields["kept"] = "cache"
+		if err == nil {
+			fields["kept"] = "file"
+		}
+		s.logger.Withs(fields).Warn(
+			"Account changed both in accounts file and in the cache, keeping the most recently updated version",
+		)
+	}
+	if err == nil {
+		report.Updated = append(report.Updated, account.ID)
+		if !conflict {
+			s.logger.Withs(fields).Info("Account replaced by the version changed in accounts file")
+		}
+	}
+}
+
+// setFileHashes records the accounts now in the file, as the base of the next reconcile
+// The map is replaced rather than modified, so readers may keep using it after releasing the lock.
+func (s *AccountService) setFileHashes(accounts []*entities.Account) {
+	hashes := accountFileHashes(accounts)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fileHashes = hashes
+}
+
+// accountFileHashes returns the accountFileHash of each account, by ID
+func accountFileHashes(accounts []*entities.Account) map[string]string {
+	hashes := make(map[string]string, len(accounts))
+	for _, account := range accounts {
+		hashes[account.ID] = accountFileHash(account)
+	}
+	return hashes
+}
+
+// accountFileHash returns the hash of the account as written to the file, leaving out the usage window,
+// which changes with every proxied request
+func accountFileHash(account *entities.Account) string {
+	record := dto.ToAccountPersistenceDTO(account)
+	record.UsageWindowStart = nil
+	record.WindowRequests, record.WindowTokens = 0, 0
+	record.WindowInputTokens, record.WindowCacheCreationTokens, record.WindowCacheReadTokens = 0, 0, 0
+	return fileRecordHash(record)
 }
 
-// Sync syncs cache data to persistent storage (called every 1 minute)
-func (s *AccountService) Sync(ctx context.Context) error {
-	if !s.isDirty() {
-		return nil // No changes, skip sync
+// fileRecordHash returns the hex SHA-256 of the JSON encoding of a persisted record
+func fileRecordHash(record any) string {
+	data, _ := json.Marshal(record)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// FinalSync performs final sync on graceful shutdown
+func (s *AccountService) FinalSync(ctx context.Context) error {
+	s.logger.Info("Performing final sync of accounts")
+	return s.Sync(ctx)
+}
+
+// CreateAccount creates a new account from OAuth code
+// When the user belongs to several organizations and no orgID was given, no account is created;
+// a PendingAccount is returned instead and must be finalized with SelectOrganization.
+func (s *AccountService) CreateAccount(
+	ctx context.Context,
+	name, code, codeVerifier, orgID string,
+) (*entities.Account, *entities.PendingAccount, error) {
+	// Exchange code for tokens using PKCE code verifier
+	tokenResp, err := s.oauthClient.ExchangeCodeForToken(ctx, code, codeVerifier)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to exchange code: %w", err)
+	}
+
+	orgs := s.discoverOrganizations(ctx, tokenResp)
+
+	// Several organizations and no explicit choice: hold the tokens until the caller selects one
+	if orgID == "" && len(orgs) > 1 {
+		now := time.Now()
+		pending := &entities.PendingAccount{
+			ID:            uid.New(),
+			Name:          name,
+			AccessToken:   tokenResp.AccessToken,
+			RefreshToken:  tokenResp.RefreshToken,
+			ExpiresIn:     tokenResp.ExpiresIn,
+			Organizations: orgs,
+			CreatedAt:     now,
+			ExpiresAt:     now.Add(entities.PendingAccountTTL),
+		}
+
+		s.pendingMu.Lock()
+		s.prunePendingLocked()
+		s.pending[pending.ID] = pending
+		s.pendingMu.Unlock()
+
+		s.logger.Withs(sctx.Fields{
+			"pending_id": pending.ID,
+			"name":       name,
+			"org_count":  len(orgs),
+		}).Info("Account requires organization selection")
+
+		return nil, pending, nil
+	}
+
+	// Use the provided organization UUID from the request, or the only discovered organization
+	orgUUID := orgID
+	if orgUUID == "" && len(orgs) == 1 {
+		orgUUID = orgs[0].UUID
+	}
+	if orgID != "" && len(orgs) > 0 && !containsOrganization(orgs, orgID) {
+		return nil, nil, fmt.Errorf("organization %s is not available to this account", orgID)
+	}
+
+	account, err := s.createAccount(
+		ctx,
+		name,
+		orgUUID,
+		orgs,
+		tokenResp.AccessToken,
+		tokenResp.RefreshToken,
+		tokenResp.ExpiresIn,
+		entities.RefreshTokenSourceOAuth,
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+	return account, nil, nil
+}
+
+// SelectOrganization finalizes a pending account creation with the chosen organization
+func (s *AccountService) SelectOrganization(
+	ctx context.Context,
+	pendingID, orgUUID string,
+) (*entities.Account, error) {
+	s.pendingMu.Lock()
+	s.prunePendingLocked()
+	pending, exists := s.pending[pendingID]
+	if exists && pending.HasOrganization(orgUUID) {
+		delete(s.pending, pendingID)
+	}
+	s.pendingMu.Unlock()
+
+	if !exists {
+		return nil, fmt.Errorf("organization selection not found or expired")
+	}
+	if !pending.HasOrganization(orgUUID) {
+		return nil, fmt.Errorf("organization %s is not available to this account", orgUUID)
+	}
+
+	// Token lifetime counts from the original exchange
+	expiresIn := pending.ExpiresIn - int(time.Since(pending.CreatedAt).Seconds())
+
+	return s.createAccount(
+		ctx,
+		pending.Name,
+		orgUUID,
+		pending.Organizations,
+		pending.AccessToken,
+		pending.RefreshToken,
+		expiresIn,
+		entities.RefreshTokenSourceOAuth,
+	)
+}
+
+// ImportAccount creates an account from credentials of an existing client login (e.g. Claude Code)
+// The refresh token is validated by refreshing it once; the rotated tokens are stored on the account.
+// When several organizations are available and none was given, the token's own organization
+// (or the first one discovered) becomes active; it can be switched later.
+func (s *AccountService) ImportAccount(
+	ctx context.Context,
+	name string,
+	creds *entities.ImportedCredentials,
+) (*entities.Account, error) {
+	if err := s.checkCredentialsUnused(ctx, creds.RefreshToken, ""); err != nil {
+		return nil, err
+	}
+
+	tokenResp, err := s.oauthClient.RefreshAccessToken(ctx, creds.RefreshToken, "", nil)
+	if err != nil {
+		return nil, fmt.Errorf("refresh token verification failed: %w", err)
+	}
+
+	orgs := s.discoverOrganizations(ctx, tokenResp)
+
+	orgUUID := creds.OrganizationUUID
+	if orgUUID != "" && len(orgs) > 0 && !containsOrganization(orgs, orgUUID) {
+		return nil, fmt.Errorf("organization %s is not available to this account", orgUUID)
+	}
+	if orgUUID == "" {
+		if tokenResp.Organization != nil && containsOrganization(orgs, tokenResp.Organization.UUID) {
+			orgUUID = tokenResp.Organization.UUID
+		} else if len(orgs) > 0 {
+			orgUUID = orgs[0].UUID
+		}
+	}
+
+	account, err := s.createAccount(
+		ctx,
+		name,
+		orgUUID,
+		orgs,
+		tokenResp.AccessToken,
+		tokenResp.RefreshToken,
+		tokenResp.ExpiresIn,
+		entities.RefreshTokenSourceRefresh,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.Withs(sctx.Fields{
+		"account_id": account.ID,
+		"org_count":  len(orgs),
+	}).Info("Account imported from existing credentials")
+	return account, nil
+}
+
+// CreateManualAccount creates an account from pasted tokens, bypassing the OAuth code exchange
+// Without verify the organization is taken as given. With verify the refresh token is validated with one
+// refresh (the rotated tokens are stored) and the organization must be one of those discovered.
+func (s *AccountService) CreateManualAccount(
+	ctx context.Context,
+	name, orgUUID string,
+	creds *entities.ManualCredentials,
+	verify bool,
+) (*entities.Account, error) {
+	if err := s.checkCredentialsUnused(ctx, creds.RefreshToken, ""); err != nil {
+		return nil, err
+	}
+
+	orgs := []entities.Organization{{UUID: orgUUID}}
+	if verify {
+		tokenResp, err := s.oauthClient.RefreshAccessToken(ctx, creds.RefreshToken, "", nil)
+		if err != nil {
+			return nil, fmt.Errorf("refresh token verification failed: %w", err)
+		}
+
+		if discovered := s.discoverOrganizations(ctx, tokenResp); len(discovered) > 0 {
+			if !containsOrganization(discovered, orgUUID) {
+				return nil, fmt.Errorf("organization %s is not available to this account", orgUUID)
+			}
+			orgs = discovered
+		}
+		creds = &entities.ManualCredentials{
+			AccessToken:  tokenResp.AccessToken,
+			RefreshToken: tokenResp.RefreshToken,
+			ExpiresIn:    tokenResp.ExpiresIn,
+		}
+	}
+
+	account, err := s.createAccount(
+		ctx,
+		name,
+		orgUUID,
+		orgs,
+		creds.AccessToken,
+		creds.RefreshToken,
+		creds.ExpiresIn,
+		entities.RefreshTokenSourceManual,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.Withs(sctx.Fields{
+		"account_id": account.ID,
+		"verified":   verify,
+	}).Info("Account created from manual credentials")
+	return account, nil
+}
+
+// CreateAPIKeyAccount creates an account authenticating with an Anthropic API key instead of OAuth tokens
+// The key never expires, so the account has no refresh lifecycle (it is created with auto-refresh off).
+func (s *AccountService) CreateAPIKeyAccount(ctx context.Context, name, apiKey string) (*entities.Account, error) {
+	accounts, err := s.cacheRepo.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, existing := range accounts {
+		if existing.IsAPIKey() && existing.APIKey == apiKey {
+			return nil, fmt.Errorf("%w: %s", entities.ErrAPIKeyAlreadyImported, existing.ID)
+		}
+	}
+
+	now := time.Now()
+	account := &entities.Account{
+		ID:        uid.New(),
+		Name:      name,
+		AuthType:  entities.AccountAuthAPIKey,
+		APIKey:    apiKey,
+		Status:    entities.AccountStatusActive,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if s.cooldown > 0 {
+		account.StartCooldown(s.cooldown)
+	}
+
+	if err := s.cacheRepo.Create(ctx, account); err != nil {
+		return nil, fmt.Errorf("failed to create account: %w", err)
+	}
+
+	s.markDirty()
+	fields := sctx.Fields{
+		"account_id": account.ID,
+		"name":       name,
+		"auth_type":  account.AuthType,
+	}
+	if account.CooldownUntil != nil {
+		fields["cooldown_until"] = account.CooldownUntil.Format(time.RFC3339)
+	}
+	s.logger.Withs(fields).Info("Account created")
+	return account, nil
+}
+
+// SetCredentials replaces an account's tokens with pasted ones, clearing its error and rate-limit state
+// With verify the refresh token is validated with one refresh first (the rotated tokens are stored);
+// the account is left unchanged when verification fails.
+func (s *AccountService) SetCredentials(
+	ctx context.Context,
+	id string,
+	creds *entities.ManualCredentials,
+	verify bool,
+) (*entities.Account, error) {
+	account, err := s.getLiveAccount(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if account.IsAPIKey() {
+		return nil, entities.ErrAPIKeyAccount
+	}
+	if err := s.checkCredentialsUnused(ctx, creds.RefreshToken, id); err != nil {
+		return nil, err
+	}
+	// Verifying rotates the refresh token, invalidating the copy held by whoever pushes tokens in manual mode
+	if verify && !account.AutoRefresh {
+		return nil, fmt.Errorf("%w: verify would rotate the refresh token", entities.ErrAutoRefreshDisabled)
+	}
+
+	source := entities.RefreshTokenSourceManual
+	if verify {
+		tokenResp, err := s.oauthClient.RefreshAccessToken(ctx, creds.RefreshToken, account.ProxyURL, account.Headers)
+		if err != nil {
+			return nil, fmt.Errorf("refresh token verification failed: %w", err)
+		}
+		creds = &entities.ManualCredentials{
+			AccessToken:  tokenResp.AccessToken,
+			RefreshToken: tokenResp.RefreshToken,
+			ExpiresIn:    tokenResp.ExpiresIn,
+		}
+		source = entities.RefreshTokenSourceRefresh
+	}
+
+	account, err = s.updateLiveAccount(ctx, id, func(account *entities.Account) error {
+		if account.IsAPIKey() {
+			return entities.ErrAPIKeyAccount
+		}
+		account.UpdateTokens(creds.AccessToken, creds.RefreshToken, creds.ExpiresIn)
+		account.ConflictDetectedAt = nil
+		account.RecordRefreshToken(source)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.Withs(sctx.Fields{
+		"account_id": id,
+		"verified":   verify,
+	}).Info("Account credentials replaced manually")
+	return account, nil
+}
+
+// AdoptConflictedAccount acknowledges a refresh token conflict and takes the account back with fresh
+// credentials (see SetCredentials); fails with ErrAccountNotConflicted if the account has no conflict
+func (s *AccountService) AdoptConflictedAccount(
+	ctx context.Context,
+	id string,
+	creds *entities.ManualCredentials,
+	verify bool,
+) (*entities.Account, error) {
+	account, err := s.getLiveAccount(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !account.IsConflicted() {
+		return nil, entities.ErrAccountNotConflicted
+	}
+
+	detectedAt := account.ConflictDetectedAt
+	account, err = s.SetCredentials(ctx, id, creds, verify)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := sctx.Fields{"account_id": id}
+	if detectedAt != nil {
+		fields["conflict_detected_at"] = detectedAt.Format(time.RFC3339)
+	}
+	s.logger.Withs(fields).Info("Conflicted account re-adopted")
+	return account, nil
+}
+
+// checkCredentialsUnused rejects a refresh token already used by an account other than exceptID
+func (s *AccountService) checkCredentialsUnused(ctx context.Context, refreshToken, exceptID string) error {
+	accounts, err := s.cacheRepo.List(ctx)
+	if err != nil {
+		return err
+	}
+	for _, existing := range accounts {
+		if existing.ID != exceptID && existing.RefreshToken == refreshToken {
+			return fmt.Errorf("%w: %s", entities.ErrCredentialsAlreadyImported, existing.ID)
+		}
+	}
+	return nil
+}
+
+// createAccount builds and stores a new active account, in its new-account cooldown when one is configured
+func (s *AccountService) createAccount(
+	ctx context.Context,
+	name, orgUUID string,
+	orgs []entities.Organization,
+	accessToken, refreshToken string,
+	expiresIn int,
+	source entities.RefreshTokenSource,
+) (*entities.Account, error) {
+	now := time.Now()
+	account := &entities.Account{
+		ID:               uid.New(),
+		Name:             name,
+		AuthType:         entities.AccountAuthOAuth,
+		OrganizationUUID: orgUUID,
+		Organizations:    orgs,
+		AccessToken:      accessToken,
+		RefreshToken:     refreshToken,
+		ExpiresAt:        now.Add(time.Duration(expiresIn) * time.Second),
+		RefreshAt:        now,
+		Status:           entities.AccountStatusActive,
+		AutoRefresh:      true,
+		CreatedAt:        now,
+		UpdatedAt:        now,
+	}
+	account.RecordRefreshToken(source)
+	if s.cooldown > 0 {
+		account.StartCooldown(s.cooldown)
+	}
+
+	// Save to cache
+	if err := s.cacheRepo.Create(ctx, account); err != nil {
+		return nil, fmt.Errorf("failed to create account: %w", err)
+	}
+
+	s.markDirty()
+	fields := sctx.Fields{
+		"account_id": account.ID,
+		"name":       name,
+		"org_uuid":   orgUUID,
+	}
+	if account.CooldownUntil != nil {
+		fields["cooldown_until"] = account.CooldownUntil.Format(time.RFC3339)
+	}
+	s.logger.Withs(fields).Info("Account created")
+
+	return account, nil
+}
+
+// discoverOrganizations lists the organizations available to a freshly issued token
+// Falls back to the organization embedded in the token response when listing fails
+func (s *AccountService) discoverOrganizations(
+	ctx context.Context,
+	tokenResp *clients.TokenResponse,
+) []entities.Organization {
+	var orgs []entities.Organization
+
+	listed, err := s.oauthClient.ListOrganizations(ctx, tokenResp.AccessToken)
+	if err != nil {
+		s.logger.Withs(sctx.Fields{"error": err.Error()}).Warn("Failed to list organizations")
+	}
+	for _, org := range listed {
+		if org.UUID != "" {
+			orgs = append(orgs, entities.Organization{
+				UUID:     org.UUID,
+				Name:     org.Name,
+				PlanType: entities.PlanTypeFromCapabilities(org.Capabilities),
+			})
+		}
+	}
+
+	if len(orgs) == 0 && tokenResp.Organization != nil && tokenResp.Organization.UUID != "" {
+		orgs = append(orgs, entities.Organization{
+			UUID: tokenResp.Organization.UUID,
+			Name: tokenResp.Organization.Name,
+		})
+	}
+
+	return orgs
+}
+
+// prunePendingLocked drops expired pending accounts (caller must hold pendingMu)
+func (s *AccountService) prunePendingLocked() {
+	for id, pending := range s.pending {
+		if pending.IsExpired() {
+			delete(s.pending, id)
+		}
+	}
+}
+
+// containsOrganization returns true if orgUUID is in the list
+func containsOrganization(orgs []entities.Organization, orgUUID string) bool {
+	for _, org := range orgs {
+		if org.UUID == orgUUID {
+			return true
+		}
+	}
+	return false
+}
+
+// GetAccount retrieves account by ID (soft-deleted accounts included)
+func (s *AccountService) GetAccount(ctx context.Context, id string) (*entities.Account, error) {
+	return s.cacheRepo.GetByID(ctx, id)
+}
+
+// getLiveAccount retrieves an account by ID, rejecting soft-deleted accounts
+func (s *AccountService) getLiveAccount(ctx context.Context, id string) (*entities.Account, error) {
+	account, err := s.cacheRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if account.IsDeleted() {
+		return nil, fmt.Errorf("account is deleted: %s (restore it first)", id)
+	}
+	return account, nil
+}
+
+// updateLiveAccount atomically applies mutate to an account that is not soft-deleted and marks the data dirty
+func (s *AccountService) updateLiveAccount(
+	ctx context.Context,
+	id string,
+	mutate func(account *entities.Account) error,
+) (*entities.Account, error) {
+	account, err := s.cacheRepo.UpdateWith(ctx, id, func(account *entities.Account) error {
+		if account.IsDeleted() {
+			return fmt.Errorf("account is deleted: %s (restore it first)", id)
+		}
+		return mutate(account)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.markDirty()
+	return account, nil
+}
+
+// ListAccounts retrieves all accounts that are not soft-deleted
+func (s *AccountService) ListAccounts(ctx context.Context) ([]*entities.Account, error) {
+	accounts, err := s.cacheRepo.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return excludeDeleted(accounts), nil
+}
+
+// ListAllAccounts retrieves all accounts including soft-deleted ones
+func (s *AccountService) ListAllAccounts(ctx context.Context) ([]*entities.Account, error) {
+	return s.cacheRepo.List(ctx)
+}
+
+// excludeDeleted filters out soft-deleted accounts
+func excludeDeleted(accounts []*entities.Account) []*entities.Account {
+	result := make([]*entities.Account, 0, len(accounts))
+	for _, account := range accounts {
+		if !account.IsDeleted() {
+			result = append(result, account)
+		}
+	}
+	return result
+}
+
+// UpdateAccount updates an existing account
+func (s *AccountService) UpdateAccount(
+	ctx context.Context,
+	id, name string,
+	status entities.AccountStatus,
+) (*entities.Account, error) {
+	account, err := s.updateLiveAccount(ctx, id, func(account *entities.Account) error {
+		account.Update(name, status)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.Withs(sctx.Fields{"account_id": id}).Info("Account updated")
+	return account, nil
+}
+
+// UpdateAccountQuota sets per-window request and token budgets (0 = unlimited)
+func (s *AccountService) UpdateAccountQuota(
+	ctx context.Context,
+	id string,
+	quotaRequests, quotaTokens int,
+) (*entities.Account, error) {
+	account, err := s.updateLiveAccount(ctx, id, func(account *entities.Account) error {
+		account.SetQuota(quotaRequests, quotaTokens)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.Withs(sctx.Fields{
+		"account_id":     id,
+		"quota_requests": quotaRequests,
+		"quota_tokens":   quotaTokens,
+	}).Info("Account quota updated")
+	return account, nil
+}
+
+// UpdateAccountOrganization switches the account's active organization to another discovered one
+func (s *AccountService) UpdateAccountOrganization(
+	ctx context.Context,
+	id, orgUUID string,
+) (*entities.Account, error) {
+	account, err := s.updateLiveAccount(ctx, id, func(account *entities.Account) error {
+		return account.SetActiveOrganization(orgUUID)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.Withs(sctx.Fields{
+		"account_id": id,
+		"org_uuid":   orgUUID,
+	}).Info("Account organization updated")
+	return account, nil
+}
+
+// UpdateAccountProxy sets or clears the account's egress proxy URL (validated)
+func (s *AccountService) UpdateAccountProxy(ctx context.Context, id, proxyURL string) (*entities.Account, error) {
+	account, err := s.updateLiveAccount(ctx, id, func(account *entities.Account) error {
+		return account.SetProxyURL(proxyURL)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.Withs(sctx.Fields{
+		"account_id": id,
+		"proxy_url":  account.MaskedProxyURL(),
+	}).Info("Account egress proxy updated")
+	return account, nil
+}
+
+// UpdateAccountBaseURL sets or clears the account's Claude API base URL override (validated https)
+func (s *AccountService) UpdateAccountBaseURL(ctx context.Context, id, baseURL string) (*entities.Account, error) {
+	account, err := s.updateLiveAccount(ctx, id, func(account *entities.Account) error {
+		return account.SetBaseURL(baseURL)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.Withs(sctx.Fields{
+		"account_id": id,
+		"base_url":   account.BaseURL,
+	}).Info("Account base URL updated")
+	return account, nil
+}
+
+// UpdateAccountHeaders replaces the account's identification header overrides (validated)
+func (s *AccountService) UpdateAccountHeaders(
+	ctx context.Context,
+	id string,
+	headers map[string]string,
+) (*entities.Account, error) {
+	if _, err := s.getLiveAccount(ctx, id); err != nil {
+		return nil, err
+	}
+	if err := httpproxy.ValidateStaticHeaders(headers); err != nil {
+		return nil, err
+	}
+
+	account, err := s.updateLiveAccount(ctx, id, func(account *entities.Account) error {
+		account.SetHeaders(headers)
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	s.logger.Debug("Syncing accounts to persistent storage")
+	s.logger.Withs(sctx.Fields{
+		"account_id": id,
+		"headers":    slices.Sorted(maps.Keys(account.Headers)),
+	}).Info("Account headers updated")
+	return account, nil
+}
 
-	// Get all accounts from cache
-	accounts, err := s.cacheRepo.List(ctx)
+// UpdateAccountSupportedModels replaces the model patterns the account serves (validated; empty = every model)
+func (s *AccountService) UpdateAccountSupportedModels(
+	ctx context.Context,
+	id string,
+	patterns []string,
+) (*entities.Account, error) {
+	if _, err := s.getLiveAccount(ctx, id); err != nil {
+		return nil, err
+	}
+	if err := entities.ValidateModelPatterns(patterns); err != nil {
+		return nil, err
+	}
+
+	account, err := s.updateLiveAccount(ctx, id, func(account *entities.Account) error {
+		account.SetSupportedModels(patterns)
+		return nil
+	})
 	if err != nil {
-		return fmt.Errorf("failed to list accounts from cache: %w", err)
+		return nil, err
 	}
 
-	// Batch save all accounts to persistent storage
-	if err := s.persistenceRepo.SaveAll(ctx, accounts); err != nil {
-		s.logger.Withs(sctx.Fields{
-			"error": err,
-		}).Error("Failed to save accounts to persistence")
-		return fmt.Errorf("failed to save accounts: %w", err)
+	s.logger.Withs(sctx.Fields{
+		"account_id":       id,
+		"supported_models": account.SupportedModels,
+	}).Info("Account supported models updated")
+	return account, nil
+}
+
+// UpdateAccountAvailability replaces the weekly time ranges the account serves in (validated; empty = always)
+func (s *AccountService) UpdateAccountAvailability(
+	ctx context.Context,
+	id string,
+	windows []entities.AvailabilityWindow,
+) (*entities.Account, error) {
+	if _, err := s.getLiveAccount(ctx, id); err != nil {
+		return nil, err
+	}
+	if err := entities.ValidateAvailability(windows); err != nil {
+		return nil, err
 	}
 
-	s.clearDirty()
-	s.logger.Withs(sctx.Fields{"count": len(accounts)}).Info("Accounts synced to persistent storage")
-	return nil
+	account, err := s.updateLiveAccount(ctx, id, func(account *entities.Account) error {
+		account.SetAvailability(windows)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.Withs(sctx.Fields{
+		"account_id":          id,
+		"availability":        len(account.Availability),
+		"currently_available": account.IsWithinAvailability(time.Now()),
+	}).Info("Account availability updated")
+	return account, nil
 }
 
-// FinalSync performs final sync on graceful shutdown
-func (s *AccountService) FinalSync(ctx context.Context) error {
-	s.logger.Info("Performing final sync of accounts")
-	return s.Sync(ctx)
+// UpdateAccountAutoRefresh enables or disables token refresh for the account (disabled = manual mode)
+func (s *AccountService) UpdateAccountAutoRefresh(
+	ctx context.Context,
+	id string,
+	enabled bool,
+) (*entities.Account, error) {
+	account, err := s.updateLiveAccount(ctx, id, func(account *entities.Account) error {
+		if enabled && account.IsAPIKey() {
+			return entities.ErrAPIKeyAccount
+		}
+		account.SetAutoRefresh(enabled)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.Withs(sctx.Fields{
+		"account_id":   id,
+		"auto_refresh": enabled,
+	}).Info("Account auto-refresh updated")
+	return account, nil
 }
 
-// CreateAccount creates a new account from OAuth code
-func (s *AccountService) CreateAccount(
+// UpdateAccountShadow puts the account in or out of shadow mode
+func (s *AccountService) UpdateAccountShadow(
 	ctx context.Context,
-	name, code, codeVerifier, orgID string,
+	id string,
+	shadow bool,
 ) (*entities.Account, error) {
-	// Exchange code for tokens using PKCE code verifier
-	tokenResp, err := s.oauthClient.ExchangeCodeForToken(ctx, code, codeVerifier)
+	account, err := s.updateLiveAccount(ctx, id, func(account *entities.Account) error {
+		account.SetShadow(shadow)
+		return nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to exchange code: %w", err)
+		return nil, err
 	}
 
-	// Use the provided organization UUID from the request
-	// The org_id is included in the OAuth authorization URL and passed through the flow
-	orgUUID := orgID
+	s.logger.Withs(sctx.Fields{
+		"account_id": id,
+		"shadow":     shadow,
+	}).Info("Account shadow mode updated")
+	return account, nil
+}
 
-	// Create account entity
-	now := time.Now()
-	account := &entities.Account{
-		ID:               uuid.Must(uuid.NewV7()).String(),
-		Name:             name,
-		OrganizationUUID: orgUUID,
-		AccessToken:      tokenResp.AccessToken,
-		RefreshToken:     tokenResp.RefreshToken,
-		ExpiresAt:        now.Add(time.Duration(tokenResp.ExpiresIn) * time.Second),
-		RefreshAt:        now,
-		Status:           entities.AccountStatusActive,
-		CreatedAt:        now,
-		UpdatedAt:        now,
+// UpdateAccountAdminCapable flags the account as having organization admin access (upstream usage reports)
+func (s *AccountService) UpdateAccountAdminCapable(
+	ctx context.Context,
+	id string,
+	adminCapable bool,
+) (*entities.Account, error) {
+	account, err := s.updateLiveAccount(ctx, id, func(account *entities.Account) error {
+		account.SetAdminCapable(adminCapable)
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	// Save to cache
-	if err := s.cacheRepo.Create(ctx, account); err != nil {
-		return nil, fmt.Errorf("failed to create account: %w", err)
+	s.logger.Withs(sctx.Fields{
+		"account_id":    id,
+		"admin_capable": adminCapable,
+	}).Info("Account admin capability updated")
+	return account, nil
+}
+
+// UpdateAccountCanary moves the account in or out of the canary set
+func (s *AccountService) UpdateAccountCanary(
+	ctx context.Context,
+	id string,
+	canary bool,
+) (*entities.Account, error) {
+	account, err := s.updateLiveAccount(ctx, id, func(account *entities.Account) error {
+		account.SetCanary(canary)
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	s.markDirty()
-	s.logger.Withs(sctx.Fields{"account_id": account.ID, "name": name}).Info("Account created")
+	s.logger.Withs(sctx.Fields{
+		"account_id": id,
+		"canary":     canary,
+	}).Info("Account canary flag updated")
+	return account, nil
+}
+
+// UpdateAccountPriority moves the account to another failover tier
+func (s *AccountService) UpdateAccountPriority(
+	ctx context.Context,
+	id string,
+	priority int,
+) (*entities.Account, error) {
+	account, err := s.updateLiveAccount(ctx, id, func(account *entities.Account) error {
+		account.SetPriority(priority)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
 
+	s.logger.Withs(sctx.Fields{
+		"account_id": id,
+		"priority":   priority,
+	}).Info("Account priority updated")
 	return account, nil
 }
 
-// GetAccount retrieves account by ID
-func (s *AccountService) GetAccount(ctx context.Context, id string) (*entities.Account, error) {
-	return s.cacheRepo.GetByID(ctx, id)
+// RecordEgressError records an egress proxy connection failure on the account (status unchanged)
+// Only this account is affected; other accounts keep using their own egress path
+func (s *AccountService) RecordEgressError(ctx context.Context, accountID, errMsg string) error {
+	_, err := s.cacheRepo.UpdateWith(ctx, accountID, func(account *entities.Account) error {
+		account.UpdateRefreshError(errMsg)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	s.markDirty()
+	return nil
 }
 
-// ListAccounts retrieves all accounts
-func (s *AccountService) ListAccounts(ctx context.Context) ([]*entities.Account, error) {
-	return s.cacheRepo.List(ctx)
+// DisableAccount marks an account inactive, recording the reason as its last error
+func (s *AccountService) DisableAccount(ctx context.Context, accountID, reason string) error {
+	account, err := s.updateLiveAccount(ctx, accountID, func(account *entities.Account) error {
+		account.Disable(reason)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	s.logger.Withs(sctx.Fields{
+		"account_id":   account.ID,
+		"account_name": account.Name,
+		"reason":       reason,
+	}).Warn("Account disabled")
+	return nil
 }
 
-// UpdateAccount updates an existing account
-func (s *AccountService) UpdateAccount(
-	ctx context.Context,
-	id, name string,
-	status entities.AccountStatus,
-) (*entities.Account, error) {
-	account, err := s.cacheRepo.GetByID(ctx, id)
+// EnableAccount marks an account active again, clearing its last error
+func (s *AccountService) EnableAccount(ctx context.Context, accountID string) (*entities.Account, error) {
+	account, err := s.updateLiveAccount(ctx, accountID, func(account *entities.Account) error {
+		account.Enable()
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	account.Update(name, status)
+	s.logger.Withs(sctx.Fields{"account_id": account.ID}).Info("Account enabled")
+	return account, nil
+}
 
-	if err := s.cacheRepo.Update(ctx, account); err != nil {
+// ActivateAccount ends an account's new-account cooldown, letting it into the rotation right away
+func (s *AccountService) ActivateAccount(ctx context.Context, accountID string) (*entities.Account, error) {
+	account, err := s.updateLiveAccount(ctx, accountID, func(account *entities.Account) error {
+		account.EndCooldown()
+		return nil
+	})
+	if err != nil {
 		return nil, err
 	}
 
-	s.markDirty()
-	s.logger.Withs(sctx.Fields{"account_id": id}).Info("Account updated")
+	s.logger.Withs(sctx.Fields{
+		"account_id":   account.ID,
+		"account_name": account.Name,
+	}).Info("Account activated before its cooldown ended")
 	return account, nil
 }
 
-// DeleteAccount deletes an account
-func (s *AccountService) DeleteAccount(ctx context.Context, id string) error {
-	if err := s.cacheRepo.Delete(ctx, id); err != nil {
+// EndElapsedCooldowns clears the cooldown of accounts whose new-account cooldown has elapsed and returns them
+func (s *AccountService) EndElapsedCooldowns(ctx context.Context) ([]*entities.Account, error) {
+	accounts, err := s.ListAccounts(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var ended []*entities.Account
+	for _, listed := range accounts {
+		if listed.CooldownUntil == nil || listed.IsCoolingDown() {
+			continue
+		}
+		// Checked again under the cache's lock: an admin may have restarted the cooldown since the listing
+		elapsed := false
+		account, err := s.cacheRepo.UpdateWith(ctx, listed.ID, func(account *entities.Account) error {
+			if elapsed = account.CooldownUntil != nil && !account.IsCoolingDown(); elapsed {
+				account.EndCooldown()
+			}
+			return nil
+		})
+		if err != nil {
+			s.logger.Withs(sctx.Fields{
+				"account_id": listed.ID,
+				"error":      err,
+			}).Warn("Failed to end account cooldown")
+			continue
+		}
+		if !elapsed {
+			continue
+		}
+		s.markDirty()
+		s.logger.Withs(sctx.Fields{
+			"account_id":   account.ID,
+			"account_name": account.Name,
+		}).Info("Account cooldown ended")
+		ended = append(ended, account)
+	}
+	return ended, nil
+}
+
+// InvalidateAccount marks an account invalid after Claude API rejected its credentials
+// Deleted and already invalid accounts are left unchanged
+func (s *AccountService) InvalidateAccount(ctx context.Context, accountID, reason string) error {
+	invalidated := false
+	account, err := s.cacheRepo.UpdateWith(ctx, accountID, func(account *entities.Account) error {
+		if account.IsDeleted() || account.Status == entities.AccountStatusInvalid || account.IsConflicted() {
+			return nil
+		}
+		account.MarkInvalid(reason)
+		invalidated = true
+		return nil
+	})
+	if err != nil || !invalidated {
+		return err
+	}
+
+	s.markDirty()
+
+	s.logger.Withs(sctx.Fields{
+		"account_id":   account.ID,
+		"account_name": account.Name,
+		"reason":       reason,
+	}).Warn("Account marked invalid")
+	return nil
+}
+
+// RecordUsage adds a proxied request and its token usage to the account's current usage window
+func (s *AccountService) RecordUsage(ctx context.Context, accountID string, usage entities.TokenUsage) error {
+	wasOverQuota := false
+	account, err := s.cacheRepo.UpdateWith(ctx, accountID, func(account *entities.Account) error {
+		wasOverQuota = account.IsOverQuota()
+		account.RecordUsage(usage)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	s.markDirty()
+
+	if !wasOverQuota && account.IsOverQuota() {
+		requests, windowTokens := account.CurrentWindowUsage()
+		s.logger.Withs(sctx.Fields{
+			"account_id":       account.ID,
+			"account_name":     account.Name,
+			"window_requests":  requests,
+			"window_tokens":    windowTokens,
+			"quota_requests":   account.QuotaRequests,
+			"quota_tokens":     account.QuotaTokens,
+			"window_resets_at": account.UsageWindowEnd().Format(time.RFC3339),
+		}).Warn("Account reached usage quota, excluding from rotation until window resets")
+	}
+
+	return nil
+}
+
+// SyncUsageWindow aligns the account's usage window with a reset time reported by Claude API
+func (s *AccountService) SyncUsageWindow(ctx context.Context, accountID string, resetAt time.Time) error {
+	aligned := false
+	account, err := s.cacheRepo.UpdateWith(ctx, accountID, func(account *entities.Account) error {
+		aligned = account.AlignUsageWindow(resetAt)
+		return nil
+	})
+	if err != nil || !aligned {
+		return err
+	}
+
+	s.markDirty()
+	s.logger.Withs(sctx.Fields{
+		"account_id":        account.ID,
+		"window_started_at": account.UsageWindowStart.Format(time.RFC3339),
+		"window_resets_at":  resetAt.Format(time.RFC3339),
+	}).Debug("Usage window aligned with upstream reset")
+	return nil
+}
+
+// DeleteAccount soft-deletes an account, or removes it with its credentials when permanent is set
+// Soft-deleted accounts keep their refresh token and are excluded from listing, refresh and proxying
+func (s *AccountService) DeleteAccount(ctx context.Context, id string, permanent bool) error {
+	if permanent {
+		if err := s.cacheRepo.Delete(ctx, id); err != nil {
+			return err
+		}
+
+		s.markRemoved()
+		s.logger.Withs(sctx.Fields{"account_id": id}).Info("Account permanently deleted")
+		return nil
+	}
+
+	deleted := false
+	_, err := s.cacheRepo.UpdateWith(ctx, id, func(account *entities.Account) error {
+		if account.IsDeleted() {
+			return nil // Already soft-deleted
+		}
+		account.SoftDelete()
+		deleted = true
+		return nil
+	})
+	if err != nil || !deleted {
 		return err
 	}
 
 	s.markDirty()
-	s.logger.Withs(sctx.Fields{"account_id": id}).Info("Account deleted")
+	s.logger.Withs(sctx.Fields{"account_id": id}).Info("Account soft-deleted")
 	return nil
 }
 
-// GetActiveAccounts retrieves all active accounts
+// RestoreAccount restores a soft-deleted account after verifying its refresh token still works
+// A single refresh is attempted; on failure the account stays deleted and the error is recorded
+func (s *AccountService) RestoreAccount(ctx context.Context, id string) (*entities.Account, error) {
+	account, err := s.cacheRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !account.IsDeleted() {
+		return nil, fmt.Errorf("account is not deleted: %s", id)
+	}
+
+	// Accounts in manual mode can't be verified without rotating the refresh token their owner holds
+	if account.AutoRefresh {
+		if err := s.refreshToken(ctx, account); err != nil {
+			s.logger.Withs(sctx.Fields{
+				"account_id": id,
+				"error":      err.Error(),
+			}).Warn("Account restore failed: refresh token no longer works")
+			return nil, fmt.Errorf("refresh token verification failed: %w", err)
+		}
+	}
+
+	// Successful refresh already reactivated the account (status active, errors cleared)
+	account, err = s.cacheRepo.UpdateWith(ctx, id, func(account *entities.Account) error {
+		account.Restore()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.markDirty()
+	s.logger.Withs(sctx.Fields{"account_id": id}).Info("Account restored")
+	return account, nil
+}
+
+// GetActiveAccounts retrieves all active accounts (soft-deleted accounts excluded)
 func (s *AccountService) GetActiveAccounts(ctx context.Context) ([]*entities.Account, error) {
-	return s.cacheRepo.GetActiveAccounts(ctx)
+	accounts, err := s.cacheRepo.GetActiveAccounts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return excludeDeleted(accounts), nil
 }
 
-// GetValidToken returns a valid access token for an account (with auto-refresh)
+// GetValidToken returns a valid access token for an account (with auto-refresh), or the API key of an api_key account
+// Accounts in manual mode are never refreshed: their token is returned until it expires
 func (s *AccountService) GetValidToken(ctx context.Context, accountID string) (string, error) {
-	account, err := s.cacheRepo.GetByID(ctx, accountID)
+	account, err := s.getLiveAccount(ctx, accountID)
 	if err != nil {
 		return "", err
 	}
+	if account.IsAPIKey() {
+		return account.APIKey, nil
+	}
+
+	if !account.AutoRefresh {
+		if account.IsExpired() {
+			return "", fmt.Errorf("access token expired: %w", entities.ErrAutoRefreshDisabled)
+		}
+		return account.AccessToken, nil
+	}
 
 	// Check if needs refresh
 	if account.NeedsRefresh() {
@@ -232,75 +1521,159 @@ func (s *AccountService) GetValidToken(ctx context.Context, accountID string) (s
 			}).Warn("Failed to refresh token")
 			return "", fmt.Errorf("failed to refresh token: %w", err)
 		}
+		// The refresh may have been another caller's, so the token is read back from the cache
+		if account, err = s.cacheRepo.GetByID(ctx, accountID); err != nil {
+			return "", err
+		}
 	}
 
 	return account.AccessToken, nil
 }
 
-// refreshToken refreshes account tokens
+// refreshToken refreshes account tokens (never for accounts in manual mode nor API-key accounts)
+// Refreshes of an account run one at a time: callers arriving while one is in flight share its result, and a
+// refresh token already rotated since account was read is never spent again.
 func (s *AccountService) refreshToken(ctx context.Context, account *entities.Account) error {
-	tokenResp, err := s.oauthClient.RefreshAccessToken(ctx, account.RefreshToken)
-	if err != nil {
-		account.UpdateRefreshError(err.Error())
-		s.cacheRepo.Update(ctx, account)
-		s.markDirty()
-		return err
+	if account.IsAPIKey() {
+		return entities.ErrAPIKeyAccount
 	}
-
-	account.UpdateTokens(tokenResp.AccessToken, tokenResp.RefreshToken, tokenResp.ExpiresIn)
-	if err := s.cacheRepo.Update(ctx, account); err != nil {
-		return err
+	if !account.AutoRefresh {
+		return entities.ErrAutoRefreshDisabled
 	}
 
-	s.markDirty()
-	s.logger.Withs(sctx.Fields{"account_id": account.ID}).Info("Token refreshed")
-	return nil
+	_, err, _ := s.refreshes.Do(account.ID, func() (interface{}, error) {
+		return nil, s.refreshOnce(ctx, account.ID, account.RefreshToken)
+	})
+	return err
 }
 
-// RefreshAllAccounts refreshes tokens for all accounts that need it
-func (s *AccountService) RefreshAllAccounts(ctx context.Context) (int, int, int, error) {
-	accounts, err := s.cacheRepo.GetActiveAccounts(ctx)
+// refreshOnce spends the account's refresh token, unless it is no longer seen, and stores the rotated tokens
+// Only one refreshOnce runs per account at a time (see refreshToken)
+func (s *AccountService) refreshOnce(ctx context.Context, accountID, seen string) error {
+	account, err := s.cacheRepo.GetByID(ctx, accountID)
 	if err != nil {
-		return 0, 0, 0, err
+		return err
+	}
+	if account.RefreshToken != seen {
+		return nil // Rotated by a refresh that just finished, or replaced by new credentials
 	}
 
-	refreshed, failed, skipped := 0, 0, 0
+	spent := account.RefreshToken
+	tokenResp, err := s.oauthClient.RefreshAccessToken(ctx, spent, account.ProxyURL, account.Headers)
+	if err != nil {
+		errMsg := err.Error()
 
-	for _, account := range accounts {
-		if !account.NeedsRefresh() {
-			skipped++
-			continue
+		// Make egress proxy failures obvious (no HTTP response means the proxy itself failed)
+		var reqErr *clients.TokenRequestError
+		if account.ProxyURL != "" && errors.As(err, &reqErr) && reqErr.StatusCode == 0 {
+			errMsg = fmt.Sprintf("egress proxy %s connection failed: %v", account.MaskedProxyURL(), err)
 		}
 
-		if err := s.refreshToken(ctx, account); err != nil {
-			failed++
-			continue
+		// A refresh token this proxy got from its own refresh can only have been spent by someone else
+		if isInvalidGrant(err) && account.RotatedHereRecently() {
+			updated, updateErr := s.cacheRepo.UpdateWith(ctx, accountID, func(account *entities.Account) error {
+				if account.RefreshToken != spent {
+					return errRefreshSuperseded
+				}
+				account.MarkConflicted(fmt.Sprintf("concurrent refresher detected: %v", err))
+				return nil
+			})
+			if updateErr != nil {
+				return err
+			}
+			s.markDirty()
+			s.logger.Withs(sctx.Fields{
+				"account_id":   updated.ID,
+				"account_name": updated.Name,
+				"fingerprint":  updated.RefreshLineage[0].Fingerprint,
+			}).Warn("Concurrent refresher detected: another client rotated the account's refresh token")
+			return fmt.Errorf("%w: %v", entities.ErrRefreshConflict, err)
+		}
+
+		if _, updateErr := s.cacheRepo.UpdateWith(ctx, accountID, func(account *entities.Account) error {
+			if account.RefreshToken != spent {
+				return errRefreshSuperseded
+			}
+			account.RecordRefreshFailure(errMsg)
+			return nil
+		}); updateErr == nil {
+			s.markDirty()
 		}
+		return err
+	}
 
-		refreshed++
+	// New credentials set while the refresh was in flight win over the rotated tokens
+	_, err = s.cacheRepo.UpdateWith(ctx, accountID, func(account *entities.Account) error {
+		if account.RefreshToken != spent {
+			return errRefreshSuperseded
+		}
+		account.UpdateTokens(tokenResp.AccessToken, tokenResp.RefreshToken, tokenResp.ExpiresIn)
+		account.RecordRefreshToken(entities.RefreshTokenSourceRefresh)
+		return nil
+	})
+	if errors.Is(err, errRefreshSuperseded) {
+		s.logger.Withs(sctx.Fields{"account_id": accountID}).Warn(
+			"Account credentials were replaced during a refresh, discarded the refreshed tokens",
+		)
+		return nil
+	}
+	if err != nil {
+		return err
 	}
 
-	return refreshed, failed, skipped, nil
+	s.markDirty()
+	s.logger.Withs(sctx.Fields{"account_id": accountID}).Info("Token refreshed")
+	return nil
+}
+
+// errRefreshSuperseded aborts storing a refresh's outcome when the account got new credentials meanwhile
+var errRefreshSuperseded = errors.New("refresh token was replaced during the refresh")
+
+// isInvalidGrant returns true if the OAuth server rejected the refresh token itself (invalid_grant)
+func isInvalidGrant(err error) bool {
+	var reqErr *clients.TokenRequestError
+	return errors.As(err, &reqErr) && reqErr.StatusCode == http.StatusBadRequest &&
+		strings.Contains(reqErr.Body, "invalid_grant")
+}
+
+// RefreshAccount refreshes an account's tokens now, whether or not they are about to expire
+func (s *AccountService) RefreshAccount(ctx context.Context, accountID string) error {
+	account, err := s.getLiveAccount(ctx, accountID)
+	if err != nil {
+		return err
+	}
+	return s.refreshToken(ctx, account)
 }
 
 // RecoverRateLimitedAccounts checks and recovers accounts with expired rate limits
 func (s *AccountService) RecoverRateLimitedAccounts(ctx context.Context) (int, error) {
-	accounts, err := s.cacheRepo.List(ctx)
+	accounts, err := s.ListAccounts(ctx)
 	if err != nil {
 		return 0, err
 	}
 
 	recovered := 0
-	for _, account := range accounts {
-		if account.Status == entities.AccountStatusRateLimited && account.IsRateLimitExpired() {
-			account.RecoverFromRateLimit()
-			if err := s.cacheRepo.Update(ctx, account); err != nil {
-				s.logger.Withs(sctx.Fields{
-					"account_id": account.ID,
-					"error":      err,
-				}).Warn("Failed to recover rate limited account")
-				continue
+	for _, listed := range accounts {
+		if listed.Status != entities.AccountStatusRateLimited || !listed.IsRateLimitExpired() {
+			continue
+		}
+		// Checked again under the cache's lock: a request may have hit a new rate limit since the listing
+		expired := false
+		_, err := s.cacheRepo.UpdateWith(ctx, listed.ID, func(account *entities.Account) error {
+			expired = account.Status == entities.AccountStatusRateLimited && account.IsRateLimitExpired()
+			if expired {
+				account.RecoverFromRateLimit()
 			}
+			return nil
+		})
+		if err != nil {
+			s.logger.Withs(sctx.Fields{
+				"account_id": listed.ID,
+				"error":      err,
+			}).Warn("Failed to recover rate limited account")
+			continue
+		}
+		if expired {
 			s.markDirty()
 			recovered++
 		}
@@ -311,17 +1684,28 @@ func (s *AccountService) RecoverRateLimitedAccounts(ctx context.Context) (int, e
 
 // GetStatistics returns system statistics including account counts and health metrics
 func (s *AccountService) GetStatistics(ctx context.Context) (map[string]interface{}, error) {
-	accounts, err := s.cacheRepo.List(ctx)
+	allAccounts, err := s.cacheRepo.List(ctx)
 	if err != nil {
 		return nil, err
 	}
 
+	// Soft-deleted accounts are only counted, never part of health metrics
+	accounts := excludeDeleted(allAccounts)
+	deletedCount := len(allAccounts) - len(accounts)
+
 	// Count by status
 	activeCount := 0
 	inactiveCount := 0
 	rateLimitedCount := 0
 	invalidCount := 0
+	conflictedCount := 0
 	needsRefreshCount := 0
+	manualCount := 0
+	manualExpiredCount := 0
+	apiKeyCount := 0
+	overQuotaCount := 0
+	pendingCount := 0
+	accountUsage := make([]map[string]interface{}, 0, len(accounts))
 
 	var oldestTokenAge time.Duration
 	now := time.Now()
@@ -336,23 +1720,71 @@ func (s *AccountService) GetStatistics(ctx context.Context) (map[string]interfac
 			rateLimitedCount++
 		case entities.AccountStatusInvalid:
 			invalidCount++
+		case entities.AccountStatusConflicted:
+			conflictedCount++
 		}
 
-		// Check if account needs refresh (within 60s of expiry)
-		if account.NeedsRefresh() {
+		// Check if account needs refresh (within 60s of expiry); manual accounts wait for pushed tokens
+		if account.IsAPIKey() {
+			apiKeyCount++
+		} else if !account.AutoRefresh {
+			manualCount++
+			if account.IsExpired() {
+				manualExpiredCount++
+			}
+		} else if account.NeedsRefresh() {
 			needsRefreshCount++
 		}
 
-		// Track oldest token age
+		if account.IsCoolingDown() {
+			pendingCount++
+		}
+
+		// Current usage window consumption
+		if account.IsOverQuota() {
+			overQuotaCount++
+		}
+		windowRequests, windowTokens := account.CurrentWindowUsage()
+		_, cacheCreationTokens, cacheReadTokens := account.CurrentWindowCacheUsage()
+		usage := map[string]interface{}{
+			"account_id":                   account.ID,
+			"account_name":                 account.Name,
+			"auth_type":                    account.AuthType,
+			"organization_uuid":            account.OrganizationUUID,
+			"window_requests":              windowRequests,
+			"window_tokens":                windowTokens,
+			"window_cache_creation_tokens": cacheCreationTokens,
+			"window_cache_read_tokens":     cacheReadTokens,
+			"window_cache_hit_ratio":       account.WindowCacheHitRatio(),
+			"quota_requests":               account.QuotaRequests,
+			"quota_tokens":                 account.QuotaTokens,
+			"over_quota":                   account.IsOverQuota(),
+			"shadow":                       account.Shadow,
+			"admin_capable":                account.AdminCapable,
+			"canary":                       account.Canary,
+			"priority":                     account.Priority,
+		}
+		if plan := account.PlanType(); plan != "" {
+			usage["plan_type"] = plan
+		}
+		if account.HasActiveUsageWindow() {
+			resetsAt := account.UsageWindowEnd()
+			usage["window_started_at"] = account.UsageWindowStart.Format(time.RFC3339)
+			usage["window_resets_at"] = resetsAt.Format(time.RFC3339)
+			usage["window_resets_in_seconds"] = int(time.Until(resetsAt).Seconds())
+		}
+		accountUsage = append(accountUsage, usage)
+
+		// Track oldest token age (API keys have no token)
 		tokenAge := now.Sub(account.ExpiresAt.Add(-1 * time.Hour)) // Tokens valid for 1 hour
-		if tokenAge > oldestTokenAge {
+		if !account.IsAPIKey() && tokenAge > oldestTokenAge {
 			oldestTokenAge = tokenAge
 		}
 	}
 
 	// Calculate system health
 	systemHealth := "healthy"
-	if invalidCount > 0 || rateLimitedCount > len(accounts)/2 {
+	if invalidCount > 0 || conflictedCount > 0 || rateLimitedCount > len(accounts)/2 {
 		systemHealth = "unhealthy"
 	} else if rateLimitedCount > 0 || needsRefreshCount > len(accounts)/2 {
 		systemHealth = "degraded"
@@ -364,7 +1796,15 @@ func (s *AccountService) GetStatistics(ctx context.Context) (map[string]interfac
 	stats["inactive_accounts"] = inactiveCount
 	stats["rate_limited_accounts"] = rateLimitedCount
 	stats["invalid_accounts"] = invalidCount
+	stats["conflicted_accounts"] = conflictedCount
 	stats["accounts_needing_refresh"] = needsRefreshCount
+	stats["manual_refresh_accounts"] = manualCount
+	stats["manual_refresh_expired_accounts"] = manualExpiredCount
+	stats["api_key_accounts"] = apiKeyCount
+	stats["over_quota_accounts"] = overQuotaCount
+	stats["pending_accounts"] = pendingCount
+	stats["deleted_accounts"] = deletedCount
+	stats["account_usage"] = accountUsage
 	stats["oldest_token_age_hours"] = oldestTokenAge.Hours()
 	stats["system_health"] = systemHealth
 
@@ -0,0 +1,269 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"claude-proxy/modules/auth/domain/entities"
+	"claude-proxy/modules/auth/domain/interfaces"
+	"claude-proxy/modules/auth/infrastructure/clients"
+	"claude-proxy/modules/auth/infrastructure/repositories"
+)
+
+// storedAccounts is persistence holding accounts, which accepts every save
+type storedAccounts struct {
+	interfaces.PersistenceRepository
+	accounts []*entities.Account
+}
+
+func (s *storedAccounts) LoadAll(context.Context) ([]*entities.Account, error) {
+	return s.accounts, nil
+}
+
+// rotatingOAuth refreshes tokens into ones that expire at once, so every use of an account refreshes it again
+// Like the real server, it takes a while and rejects a refresh token that was already spent with invalid_grant
+type rotatingOAuth struct {
+	interfaces.OAuthClient
+	refreshes atomic.Int64
+	mu        sync.Mutex
+	spent     map[string]bool
+	reused    atomic.Int64 // Refresh tokens presented again after being spent
+}
+
+func (o *rotatingOAuth) RefreshAccessToken(
+	_ context.Context,
+	refreshToken, _ string,
+	_ map[string]string,
+) (*clients.TokenResponse, error) {
+	time.Sleep(time.Millisecond) // A round trip, during which the account keeps being used
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.spent[refreshToken] {
+		o.reused.Add(1)
+		return nil, &clients.TokenRequestError{StatusCode: http.StatusBadRequest, Body: `{"error":"invalid_grant"}`}
+	}
+	o.spent[refreshToken] = true
+
+	n := o.refreshes.Add(1)
+	return &clients.TokenResponse{
+		AccessToken:  fmt.Sprintf("sk-ant-oat01-%d", n),
+		RefreshToken: fmt.Sprintf("sk-ant-ort01-%d", n),
+		ExpiresIn:    0,
+	}, nil
+}
+
+// TestAccountServiceConcurrentRefresh gets tokens of accounts due for refresh while they are refreshed, listed
+// and used concurrently; every refresh token must be spent once, and no refresh may drop recorded usage or a
+// rotated token. Run with -race, it also fails if the service changes accounts shared with the cache.
+func TestAccountServiceConcurrentRefresh(t *testing.T) {
+	const accounts = 4
+	logger := quietLogger(t)
+	persistence := &storedAccounts{}
+	for i := range accounts {
+		persistence.accounts = append(persistence.accounts, &entities.Account{
+			ID:           fmt.Sprintf("acc_%d", i),
+			Name:         fmt.Sprintf("account %d", i),
+			AuthType:     entities.AccountAuthOAuth,
+			Status:       entities.AccountStatusActive,
+			AutoRefresh:  true,
+			AccessToken:  "sk-ant-oat01-expired",
+			RefreshToken: fmt.Sprintf("sk-ant-ort01-initial-%d", i),
+			ExpiresAt:    time.Now().Add(-time.Minute),
+			Headers:      map[string]string{"User-Agent": "claude-cli/1.0.0"},
+		})
+	}
+	oauth := &rotatingOAuth{spent: make(map[string]bool)}
+	svc := NewAccountService(repositories.NewMemoryAccountRepository(0, logger), persistence, oauth, 0, logger)
+	ctx := context.Background()
+
+	var recorded [accounts]atomic.Int64
+	var wg sync.WaitGroup
+	for g := range 12 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range 100 {
+				n := (g + i) % accounts
+				id := fmt.Sprintf("acc_%d", n)
+				switch g % 4 {
+				case 0:
+					token, err := svc.GetValidToken(ctx, id)
+					if err != nil || !strings.HasPrefix(token, "sk-ant-oat01-") || token == "sk-ant-oat01-expired" {
+						t.Errorf("GetValidToken(%s) = %q, %v; want a refreshed token", id, token, err)
+					}
+				case 1:
+					if err := svc.RefreshAccount(ctx, id); err != nil {
+						t.Errorf("RefreshAccount(%s) = %v", id, err)
+					}
+				case 2:
+					if err := svc.RecordUsage(ctx, id, entities.TokenUsage{InputTokens: 3, OutputTokens: 7}); err != nil {
+						t.Errorf("RecordUsage(%s) = %v", id, err)
+						continue
+					}
+					recorded[n].Add(1)
+				default:
+					accounts, err := svc.ListAccounts(ctx)
+					if err != nil {
+						t.Error(err)
+						continue
+					}
+					for _, account := range accounts {
+						_ = account.AccessToken + account.Headers["User-Agent"]
+						account.Headers["User-Agent"] = "changed by a caller"
+					}
+					if _, err := svc.RecoverRateLimitedAccounts(ctx); err != nil {
+						t.Error(err)
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if oauth.refreshes.Load() == 0 {
+		t.Fatal("no account was refreshed")
+	}
+	if reused := oauth.reused.Load(); reused > 0 {
+		t.Errorf("%d refresh tokens were spent twice", reused)
+	}
+	for n := range accounts {
+		account, err := svc.GetAccount(ctx, fmt.Sprintf("acc_%d", n))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if account.AccessToken == "sk-ant-oat01-expired" || !account.IsActive() {
+			t.Errorf("%s: token %q, status %s; want refreshed and active", account.ID, account.AccessToken,
+				account.Status)
+		}
+		if oauth.spent[account.RefreshToken] {
+			t.Errorf("%s: holds the spent refresh token %s, a rotated one was lost", account.ID, account.RefreshToken)
+		}
+		if account.Headers["User-Agent"] != "claude-cli/1.0.0" {
+			t.Errorf("%s: a caller's change to a listed account reached the cache", account.ID)
+		}
+		requests, tokens := account.CurrentWindowUsage()
+		if want := int(recorded[n].Load()); requests != want || tokens != 10*want {
+			t.Errorf("%s: window usage %d requests / %d tokens, want %d / %d", account.ID, requests, tokens, want,
+				10*want)
+		}
+	}
+}
+
+// editDataFile changes each record of a data file (an array, or the accounts of accounts.json) like a hand edit,
+// leaving updated_at as it is unless edit sets it
+func editDataFile(t *testing.T, path string, edit func(record map[string]any)) {
+	t.Helper()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatal(err)
+	}
+	records, _ := doc.([]any)
+	if file, ok := doc.(map[string]any); ok {
+		records, _ = file["accounts"].([]any)
+	}
+	for _, record := range records {
+		edit(record.(map[string]any))
+	}
+	if data, err = json.MarshalIndent(doc, "", "  "); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestAccountServiceSyncKeepsFileEdits edits accounts.json between syncs without bumping updated_at: an account
+// changed only in the file takes the edit and keeps the usage counted meanwhile, and an account changed on both
+// sides keeps the more recently updated version
+func TestAccountServiceSyncKeepsFileEdits(t *testing.T) {
+	logger := quietLogger(t)
+	dir := t.TempDir()
+	persistence, err := repositories.NewJSONAccountPersistenceRepository(dir, false, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	updatedAt := time.Now().Add(-time.Hour)
+	var initial []*entities.Account
+	for _, id := range []string{"acc_edited", "acc_both"} {
+		initial = append(initial, &entities.Account{
+			ID: id, Name: id, AuthType: entities.AccountAuthOAuth, Status: entities.AccountStatusActive,
+			ExpiresAt: time.Now().Add(time.Hour), CreatedAt: updatedAt, UpdatedAt: updatedAt,
+		})
+	}
+	if err := persistence.SaveAll(context.Background(), initial, false); err != nil {
+		t.Fatal(err)
+	}
+	svc := NewAccountService(repositories.NewMemoryAccountRepository(0, logger), persistence, nil, 0, logger)
+	ctx := context.Background()
+	usage := entities.TokenUsage{InputTokens: 3, OutputTokens: 7}
+
+	if err := svc.RecordUsage(ctx, "acc_edited", usage); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.Sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	accountsFile := filepath.Join(dir, "accounts.json")
+	editDataFile(t, accountsFile, func(record map[string]any) { record["priority"] = 5 })
+	if _, err := svc.UpdateAccountPriority(ctx, "acc_both", 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.RecordUsage(ctx, "acc_edited", usage); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.Sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	edited, err := svc.GetAccount(ctx, "acc_edited")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if requests, _ := edited.CurrentWindowUsage(); edited.Priority != 5 || requests != 2 {
+		t.Errorf("acc_edited: priority %d, %d window requests; want the file's 5 and 2", edited.Priority, requests)
+	}
+	both, err := svc.GetAccount(ctx, "acc_both")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if both.Priority != 1 {
+		t.Errorf("acc_both: priority %d, want the more recent cached 1", both.Priority)
+	}
+
+	// Both sides change again, the file more recently this time
+	editDataFile(t, accountsFile, func(record map[string]any) {
+		if record["id"] == "acc_both" {
+			record["priority"] = 9
+			record["updated_at"] = time.Now().Add(time.Hour).Format(time.RFC3339)
+		}
+	})
+	if _, err := svc.UpdateAccountPriority(ctx, "acc_both", 2); err != nil {
+		t.Fatal(err)
+	}
+	report, err := svc.Reload(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(report.Conflicts, []string{"acc_both"}) || !slices.Equal(report.Updated, []string{"acc_both"}) {
+		t.Errorf("reload conflicts %v, updated %v; want acc_both in both", report.Conflicts, report.Updated)
+	}
+	if both, err = svc.GetAccount(ctx, "acc_both"); err != nil || both.Priority != 9 {
+		t.Errorf("acc_both after reload: %+v, %v; want the more recent file priority 9", both, err)
+	}
+}
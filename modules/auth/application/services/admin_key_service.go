@@ -0,0 +1,228 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+
+	"claude-proxy/modules/auth/domain/entities"
+	"claude-proxy/modules/auth/domain/interfaces"
+	"claude-proxy/pkg/uid"
+
+	sctx "github.com/phathdt/service-context"
+)
+
+// AdminKeyPrefix is prepended to generated admin keys
+const AdminKeyPrefix = "sk-admin-"
+
+// AdminKeyService manages hashed admin API keys with a dual-key rotation window
+// Admin keys change rarely and must never be lost once handed out, so every change is written
+// to persistence immediately instead of going through the periodic sync
+type AdminKeyService struct {
+	repo         interfaces.AdminKeyRepository
+	configAPIKey string        // Bootstrap fallback, accepted only while no managed keys exist
+	gracePeriod  time.Duration // How long rotated-out keys stay valid
+	keys         []*entities.AdminKey
+	mu           sync.RWMutex
+	logger       sctx.Logger
+}
+
+// NewAdminKeyService creates a new admin key service and loads managed keys from persistence
+func NewAdminKeyService(
+	repo interfaces.AdminKeyRepository,
+	configAPIKey string,
+	gracePeriod time.Duration,
+	appLogger sctx.Logger,
+) (interfaces.AdminKeyService, error) {
+	logger := appLogger.Withs(sctx.Fields{"component": "admin-key-service"})
+
+	keys, err := repo.LoadAll(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("failed to load admin keys: %w", err)
+	}
+
+	svc := &AdminKeyService{
+		repo:         repo,
+		configAPIKey: configAPIKey,
+		gracePeriod:  gracePeriod,
+		keys:         keys,
+		logger:       logger,
+	}
+
+	if len(keys) == 0 {
+		logger.Info("No managed admin keys, using auth.api_key from config")
+	} else {
+		logger.Withs(sctx.Fields{"count": len(keys)}).Info("Managed admin keys loaded")
+	}
+
+	return svc, nil
+}
+
+// Authenticate checks a key against the managed keys (or the config key while none exist)
+func (s *AdminKeyService) Authenticate(ctx context.Context, key string) (string, bool) {
+	if key == "" {
+		return "", false
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if len(s.keys) == 0 {
+		if s.configAPIKey != "" && subtle.ConstantTimeCompare([]byte(key), []byte(s.configAPIKey)) == 1 {
+			return "config_api_key", true
+		}
+		return "", false
+	}
+
+	now := time.Now()
+	for _, adminKey := range s.keys {
+		if !adminKey.IsExpired(now) && adminKey.Matches(key) {
+			return "admin_key:" + adminKey.ID, true
+		}
+	}
+
+	return "", false
+}
+
+// RotateKey mints a new admin key (returned once) and expires the previous keys after the grace period
+// On the first rotation the config key is imported as the previous key, so existing clients keep
+// working through the grace period
+func (s *AdminKeyService) RotateKey(ctx context.Context) (string, *entities.AdminKey, error) {
+	key, err := generateAdminKey()
+	if err != nil {
+		return "", nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	graceEnd := now.Add(s.gracePeriod)
+
+	keys := s.unexpiredLocked(now)
+
+	// Migration: bring the bootstrap config key under management for the grace period
+	if len(keys) == 0 && s.configAPIKey != "" {
+		keys = append(keys, &entities.AdminKey{
+			ID:        uid.New(),
+			KeyHash:   entities.HashAdminKey(s.configAPIKey),
+			Hint:      entities.AdminKeyHint(s.configAPIKey),
+			CreatedAt: now,
+			ExpiresAt: &graceEnd,
+		})
+	}
+
+	// Previous keys keep working until the grace period ends
+	for _, previous := range keys {
+		previous.ExpireAt(graceEnd)
+	}
+
+	adminKey := &entities.AdminKey{
+		ID:        uid.New(),
+		KeyHash:   entities.HashAdminKey(key),
+		Hint:      entities.AdminKeyHint(key),
+		CreatedAt: now,
+	}
+	keys = append(keys, adminKey)
+
+	if err := s.saveLocked(ctx, keys); err != nil {
+		return "", nil, err
+	}
+
+	s.logger.Withs(sctx.Fields{
+		"key_id":          adminKey.ID,
+		"previous_keys":   len(keys) - 1,
+		"grace_period":    s.gracePeriod.String(),
+		"previous_expiry": graceEnd.Format(time.RFC3339),
+	}).Info("Admin key rotated")
+
+	return key, adminKey, nil
+}
+
+// RevokeKey removes an admin key immediately
+// The last key without an expiry cannot be revoked: the keys left in their grace period would expire, and with
+// expired keys on file neither they nor the config key would be accepted any more
+func (s *AdminKeyService) RevokeKey(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keys := s.unexpiredLocked(time.Now())
+
+	remaining := make([]*entities.AdminKey, 0, len(keys))
+	found := false
+	lasting := 0 // Remaining keys without an expiry
+	for _, adminKey := range keys {
+		if adminKey.ID == id {
+			found = true
+			continue
+		}
+		remaining = append(remaining, adminKey)
+		if adminKey.ExpiresAt == nil {
+			lasting++
+		}
+	}
+
+	if !found {
+		return fmt.Errorf("admin key not found: %s", id)
+	}
+	if lasting == 0 {
+		return fmt.Errorf("cannot revoke the last admin key without an expiry, rotate first")
+	}
+
+	if err := s.saveLocked(ctx, remaining); err != nil {
+		return err
+	}
+
+	s.logger.Withs(sctx.Fields{"key_id": id}).Info("Admin key revoked")
+	return nil
+}
+
+// ListKeys returns the unexpired admin keys
+func (s *AdminKeyService) ListKeys(ctx context.Context) ([]*entities.AdminKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	now := time.Now()
+	keys := make([]*entities.AdminKey, 0, len(s.keys))
+	for _, adminKey := range s.keys {
+		if !adminKey.IsExpired(now) {
+			copied := *adminKey
+			keys = append(keys, &copied)
+		}
+	}
+	return keys, nil
+}
+
+// unexpiredLocked returns copies of keys still valid at now, dropping expired ones (caller must hold mu)
+func (s *AdminKeyService) unexpiredLocked(now time.Time) []*entities.AdminKey {
+	keys := make([]*entities.AdminKey, 0, len(s.keys))
+	for _, adminKey := range s.keys {
+		if !adminKey.IsExpired(now) {
+			copied := *adminKey
+			keys = append(keys, &copied)
+		}
+	}
+	return keys
+}
+
+// saveLocked persists keys and, on success, makes them the active set (caller must hold mu)
+func (s *AdminKeyService) saveLocked(ctx context.Context, keys []*entities.AdminKey) error {
+	if err := s.repo.SaveAll(ctx, keys); err != nil {
+		return fmt.Errorf("failed to save admin keys: %w", err)
+	}
+	s.keys = keys
+	return nil
+}
+
+// generateAdminKey generates a new random admin key (prefix + 48 hex chars)
+func generateAdminKey() (string, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate admin key: %w", err)
+	}
+	return AdminKeyPrefix + hex.EncodeToString(buf), nil
+}
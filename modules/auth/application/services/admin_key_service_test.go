@@ -0,0 +1,58 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"claude-proxy/modules/auth/domain/entities"
+)
+
+// storedAdminKeys is persistence holding admin keys in memory
+type storedAdminKeys struct {
+	keys []*entities.AdminKey
+}
+
+func (s *storedAdminKeys) SaveAll(_ context.Context, keys []*entities.AdminKey) error {
+	s.keys = keys
+	return nil
+}
+
+func (s *storedAdminKeys) LoadAll(context.Context) ([]*entities.AdminKey, error) {
+	return s.keys, nil
+}
+
+// TestAdminKeyServiceRevokeKeepsLastingKey revokes keys after rotations: the only key without an expiry can't be
+// revoked, as the keys left in their grace period would expire and lock every admin out
+func TestAdminKeyServiceRevokeKeepsLastingKey(t *testing.T) {
+	ctx := context.Background()
+	svc, err := NewAdminKeyService(&storedAdminKeys{}, "config-key", time.Hour, quietLogger(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first, firstKey, err := svc.RotateKey(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.RevokeKey(ctx, firstKey.ID); err == nil {
+		t.Fatal("revoked the only key without an expiry, leaving the config key in its grace period")
+	}
+
+	second, secondKey, err := svc.RotateKey(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.RevokeKey(ctx, secondKey.ID); err == nil {
+		t.Fatal("revoked the new key, leaving only keys in their grace period")
+	}
+	if err := svc.RevokeKey(ctx, firstKey.ID); err != nil {
+		t.Fatalf("revoking a key in its grace period: %v", err)
+	}
+
+	for key, want := range map[string]bool{"config-key": true, first: false, second: true} {
+		if _, ok := svc.Authenticate(ctx, key); ok != want {
+			t.Errorf("Authenticate(%s) = %v, want %v", key, ok, want)
+		}
+	}
+}
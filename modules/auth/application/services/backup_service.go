@@ -0,0 +1,399 @@
+package services
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"claude-proxy/modules/auth/domain/entities"
+	"claude-proxy/modules/auth/domain/interfaces"
+
+	sctx "github.com/phathdt/service-context"
+)
+
+const (
+	backupFilePrefix = "claude-proxy-backup-"
+	backupFileSuffix = ".tar.gz"
+	backupTimeLayout = "20060102-150405"
+)
+
+// backupDataFiles are the only files a backup may contain (and a restore may write)
+var backupDataFiles = map[string]bool{
+	"accounts.json":   true,
+	"tokens.json":     true,
+	"sessions.json":   true,
+	"stats.json":      true,
+	"admin_keys.json": true,
+	"invites.json":    true,
+}
+
+// BackupService creates and lists timestamped tar.gz archives of the data folder
+type BackupService struct {
+	accountSvc   interfaces.AccountService
+	tokenSvc     interfaces.TokenService
+	sessionSvc   interfaces.SessionService
+	statsSvc     interfaces.StatisticsService
+	sources      []interfaces.SnapshotSource
+	backupFolder string
+	keep         int
+	mu           sync.Mutex // Serializes backup creation and pruning
+	logger       sctx.Logger
+}
+
+// snapshotFile is a data file captured while its repository was locked
+type snapshotFile struct {
+	name string
+	data []byte
+}
+
+// NewBackupService creates a new backup service
+// sources are the file-backed persistence repositories to snapshot; keep is how many backups to retain
+func NewBackupService(
+	accountSvc interfaces.AccountService,
+	tokenSvc interfaces.TokenService,
+	sessionSvc interfaces.SessionService,
+	statsSvc interfaces.StatisticsService,
+	sources []interfaces.SnapshotSource,
+	backupFolder string,
+	keep int,
+	appLogger sctx.Logger,
+) interfaces.BackupService {
+	return &BackupService{
+		accountSvc:   accountSvc,
+		tokenSvc:     tokenSvc,
+		sessionSvc:   sessionSvc,
+		statsSvc:     statsSvc,
+		sources:      sources,
+		backupFolder: backupFolder,
+		keep:         keep,
+		logger:       appLogger.Withs(sctx.Fields{"component": "backup-service"}),
+	}
+}
+
+// CreateBackup syncs in-memory data and writes a timestamped archive of the data files
+func (s *BackupService) CreateBackup(ctx context.Context) (*entities.Backup, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	start := time.Now()
+
+	// Flush pending changes so the snapshot reflects the current in-memory state
+	if err := s.syncAll(ctx); err != nil {
+		return nil, err
+	}
+
+	// Copy all files while holding every repository's write lock (consistent point-in-time view)
+	files, err := snapshotFiles(s.sources, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to snapshot data files: %w", err)
+	}
+
+	if err := os.MkdirAll(s.backupFolder, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create backup folder: %w", err)
+	}
+
+	now := time.Now()
+	name := backupFilePrefix + now.Format(backupTimeLayout) + backupFileSuffix
+	path := filepath.Join(s.backupFolder, name)
+
+	if err := writeBackupArchive(path, files, now); err != nil {
+		return nil, err
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat backup: %w", err)
+	}
+
+	s.logger.Withs(sctx.Fields{
+		"backup":   name,
+		"files":    len(files),
+		"size":     info.Size(),
+		"duration": time.Since(start).String(),
+	}).Info("Backup created")
+
+	s.prune()
+
+	return &entities.Backup{
+		Name:      name,
+		Path:      path,
+		Size:      info.Size(),
+		CreatedAt: now,
+	}, nil
+}
+
+// ExportBundle syncs in-memory data and returns the data files as an encrypted state bundle
+// The bundle is nil when ifNoneMatch is the ETag of the current data files (nothing changed since that export)
+func (s *BackupService) ExportBundle(
+	ctx context.Context,
+	passphrase string,
+	ifNoneMatch string,
+) ([]byte, *entities.StateBundleManifest, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := checkBundlePassphrase(passphrase); err != nil {
+		return nil, nil, err
+	}
+	if err := s.syncAll(ctx); err != nil {
+		return nil, nil, err
+	}
+
+	bundle, manifest, err := ExportStateBundle(s.sources, passphrase, ifNoneMatch)
+	if err != nil {
+		return nil, nil, err
+	}
+	if bundle == nil {
+		return nil, manifest, nil
+	}
+
+	s.logger.Withs(sctx.Fields{
+		"files": len(manifest.Files),
+		"size":  len(bundle),
+	}).Info("State bundle exported")
+	return bundle, manifest, nil
+}
+
+// syncAll flushes pending in-memory changes of every service to the data files
+func (s *BackupService) syncAll(ctx context.Context) error {
+	if err := s.accountSvc.Sync(ctx); err != nil {
+		return fmt.Errorf("failed to sync accounts: %w", err)
+	}
+	if err := s.tokenSvc.Sync(ctx); err != nil {
+		return fmt.Errorf("failed to sync tokens: %w", err)
+	}
+	if err := s.sessionSvc.Sync(ctx); err != nil {
+		return fmt.Errorf("failed to sync sessions: %w", err)
+	}
+	if err := s.statsSvc.Sync(ctx); err != nil {
+		return fmt.Errorf("failed to sync usage statistics: %w", err)
+	}
+	return nil
+}
+
+// ListBackups returns available backups, newest first
+func (s *BackupService) ListBackups(ctx context.Context) ([]*entities.Backup, error) {
+	entries, err := os.ReadDir(s.backupFolder)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []*entities.Backup{}, nil
+		}
+		return nil, fmt.Errorf("failed to read backup folder: %w", err)
+	}
+
+	backups := make([]*entities.Backup, 0, len(entries))
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, backupFilePrefix) || !strings.HasSuffix(name, backupFileSuffix) {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+
+		stamp := strings.TrimSuffix(strings.TrimPrefix(name, backupFilePrefix), backupFileSuffix)
+		createdAt, err := time.ParseInLocation(backupTimeLayout, stamp, time.Local)
+		if err != nil {
+			createdAt = info.ModTime()
+		}
+
+		backups = append(backups, &entities.Backup{
+			Name:      name,
+			Path:      filepath.Join(s.backupFolder, name),
+			Size:      info.Size(),
+			CreatedAt: createdAt,
+		})
+	}
+
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].CreatedAt.After(backups[j].CreatedAt)
+	})
+
+	return backups, nil
+}
+
+// prune removes backups beyond the retention count (caller must hold mu)
+func (s *BackupService) prune() {
+	if s.keep <= 0 {
+		return
+	}
+
+	backups, err := s.ListBackups(context.Background())
+	if err != nil {
+		s.logger.Withs(sctx.Fields{"error": err.Error()}).Warn("Failed to list backups for pruning")
+		return
+	}
+
+	for _, backup := range backups[min(s.keep, len(backups)):] {
+		if err := os.Remove(backup.Path); err != nil {
+			s.logger.Withs(sctx.Fields{
+				"backup": backup.Name,
+				"error":  err.Error(),
+			}).Warn("Failed to prune old backup")
+			continue
+		}
+		s.logger.Withs(sctx.Fields{"backup": backup.Name}).Info("Pruned old backup")
+	}
+}
+
+// snapshotFiles reads each source's data file, acquiring the write locks one after another
+// and holding all of them until every file has been read
+func snapshotFiles(sources []interfaces.SnapshotSource, files []snapshotFile) ([]snapshotFile, error) {
+	if len(sources) == 0 {
+		return files, nil
+	}
+
+	var result []snapshotFile
+	err := sources[0].WithWriteLock(func(dataFile string) error {
+		data, err := os.ReadFile(dataFile)
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to read %s: %w", filepath.Base(dataFile), err)
+		}
+		if err == nil {
+			files = append(files, snapshotFile{name: filepath.Base(dataFile), data: data})
+		}
+
+		result, err = snapshotFiles(sources[1:], files)
+		return err
+	})
+	return result, err
+}
+
+// writeBackupArchive writes files to a tar.gz at path (via a temp file and atomic rename)
+func writeBackupArchive(path string, files []snapshotFile, modTime time.Time) error {
+	tmpPath := path + ".tmp"
+	out, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to create backup file: %w", err)
+	}
+
+	gz := gzip.NewWriter(out)
+	tw := tar.NewWriter(gz)
+
+	writeErr := func() error {
+		for _, file := range files {
+			header := &tar.Header{
+				Name:    file.name,
+				Mode:    0o600,
+				Size:    int64(len(file.data)),
+				ModTime: modTime,
+			}
+			if err := tw.WriteHeader(header); err != nil {
+				return err
+			}
+			if _, err := tw.Write(file.data); err != nil {
+				return err
+			}
+		}
+		if err := tw.Close(); err != nil {
+			return err
+		}
+		if err := gz.Close(); err != nil {
+			return err
+		}
+		return out.Sync()
+	}()
+
+	if closeErr := out.Close(); writeErr == nil {
+		writeErr = closeErr
+	}
+	if writeErr != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write backup archive: %w", writeErr)
+	}
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to finalize backup archive: %w", err)
+	}
+
+	return nil
+}
+
+// RestoreBackup extracts a backup archive into dataFolder and returns the restored file names
+// Only known data files are accepted; each is validated as JSON and written atomically.
+// Existing files are kept alongside as <name>.pre-restore. The server must not be running.
+func RestoreBackup(backupPath, dataFolder string) ([]string, error) {
+	in, err := os.Open(backupPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open backup: %w", err)
+	}
+	defer in.Close()
+
+	gz, err := gzip.NewReader(in)
+	if err != nil {
+		return nil, fmt.Errorf("invalid backup archive: %w", err)
+	}
+	defer gz.Close()
+
+	// Read and validate everything before touching the data folder
+	var files []snapshotFile
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid backup archive: %w", err)
+		}
+
+		if header.Typeflag != tar.TypeReg || !backupDataFiles[header.Name] {
+			return nil, fmt.Errorf("unexpected entry in backup archive: %s", header.Name)
+		}
+
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s from backup: %w", header.Name, err)
+		}
+		if !json.Valid(data) {
+			return nil, fmt.Errorf("%s in backup is not valid JSON", header.Name)
+		}
+
+		files = append(files, snapshotFile{name: header.Name, data: data})
+	}
+
+	if len(files) == 0 {
+		return nil, fmt.Errorf("backup archive contains no data files")
+	}
+
+	if err := os.MkdirAll(dataFolder, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create data folder: %w", err)
+	}
+
+	restored := make([]string, 0, len(files))
+	for _, file := range files {
+		target := filepath.Join(dataFolder, file.name)
+
+		// Keep the current file so a bad restore can be undone by hand
+		if _, err := os.Stat(target); err == nil {
+			if err := os.Rename(target, target+".pre-restore"); err != nil {
+				return restored, fmt.Errorf("failed to keep existing %s: %w", file.name, err)
+			}
+		}
+
+		tmpFile := target + ".tmp"
+		if err := os.WriteFile(tmpFile, file.data, 0o600); err != nil {
+			return restored, fmt.Errorf("failed to write %s: %w", file.name, err)
+		}
+		if err := os.Rename(tmpFile, target); err != nil {
+			os.Remove(tmpFile)
+			return restored, fmt.Errorf("failed to restore %s: %w", file.name, err)
+		}
+
+		restored = append(restored, file.name)
+	}
+
+	return restored, nil
+}
@@ -0,0 +1,39 @@
+package services
+
+import (
+	"claude-proxy/modules/auth/domain/entities"
+	"claude-proxy/modules/auth/domain/interfaces"
+)
+
+// CacheMonitor reports the sizes of the in-memory cache repositories
+type CacheMonitor struct {
+	accounts   interfaces.CacheRepository
+	tokens     interfaces.TokenCacheRepository
+	sessions   interfaces.SessionCacheRepository
+	usageStats interfaces.UsageStatsCacheRepository
+}
+
+// NewCacheMonitor creates a monitor over the in-memory cache repositories
+func NewCacheMonitor(
+	accounts interfaces.CacheRepository,
+	tokens interfaces.TokenCacheRepository,
+	sessions interfaces.SessionCacheRepository,
+	usageStats interfaces.UsageStatsCacheRepository,
+) interfaces.CacheMonitor {
+	return &CacheMonitor{
+		accounts:   accounts,
+		tokens:     tokens,
+		sessions:   sessions,
+		usageStats: usageStats,
+	}
+}
+
+// Stats returns the size of each cache, keyed accounts, tokens, sessions and usage_buckets
+func (m *CacheMonitor) Stats() map[string]entities.CacheStats {
+	return map[string]entities.CacheStats{
+		"accounts":      m.accounts.CacheStats(),
+		"tokens":        m.tokens.CacheStats(),
+		"sessions":      m.sessions.CacheStats(),
+		"usage_buckets": m.usageStats.CacheStats(),
+	}
+}
@@ -0,0 +1,299 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"claude-proxy/modules/auth/domain/entities"
+	"claude-proxy/modules/auth/domain/interfaces"
+	"claude-proxy/pkg/actor"
+	"claude-proxy/pkg/uid"
+
+	sctx "github.com/phathdt/service-context"
+)
+
+// deviceAuthRetention is how long completed and expired device authorizations stay pollable before they are
+// dropped
+const deviceAuthRetention = time.Hour
+
+// deviceAuthExchangeTimeout bounds the code exchange (and organization lookup) of a device authorization
+const deviceAuthExchangeTimeout = 30 * time.Second
+
+// DeviceAuthService runs the OAuth flow of headless servers
+// Like invites, authorizations are written to persistence on every change, so a flow started before a restart
+// can still be completed and polled after it
+type DeviceAuthService struct {
+	repo        interfaces.DeviceAuthRepository
+	accountSvc  interfaces.AccountService
+	oauthClient interfaces.OAuthClient
+	auths       []*entities.DeviceAuthorization
+	completing  map[string]bool // Authorization IDs with a code exchange in flight
+	mu          sync.Mutex
+	logger      sctx.Logger
+}
+
+// NewDeviceAuthService creates a new device authorization service and loads authorizations from persistence
+func NewDeviceAuthService(
+	repo interfaces.DeviceAuthRepository,
+	accountSvc interfaces.AccountService,
+	oauthClient interfaces.OAuthClient,
+	appLogger sctx.Logger,
+) (interfaces.DeviceAuthService, error) {
+	logger := appLogger.Withs(sctx.Fields{"component": "device-auth-service"})
+
+	auths, err := repo.LoadAll(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("failed to load device authorizations: %w", err)
+	}
+	logger.Withs(sctx.Fields{"count": len(auths)}).Info("Device authorizations loaded")
+
+	return &DeviceAuthService{
+		repo:        repo,
+		accountSvc:  accountSvc,
+		oauthClient: oauthClient,
+		auths:       auths,
+		completing:  make(map[string]bool),
+		logger:      logger,
+	}, nil
+}
+
+// Start creates an authorization valid for ttl and returns its polling code (shown once) with it
+func (s *DeviceAuthService) Start(
+	ctx context.Context,
+	accountName, orgID, createdBy string,
+	ttl time.Duration,
+) (string, *entities.DeviceAuthorization, error) {
+	challenge, err := s.oauthClient.GeneratePKCEChallenge()
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to generate OAuth challenge: %w", err)
+	}
+	pollCode, err := generateDevicePollCode()
+	if err != nil {
+		return "", nil, err
+	}
+
+	now := time.Now()
+	auth := &entities.DeviceAuthorization{
+		ID:           uid.New(),
+		PollCodeHash: entities.HashDevicePollCode(pollCode),
+		State:        challenge.State,
+		CodeVerifier: challenge.CodeVerifier,
+		AuthorizeURL: s.oauthClient.BuildAuthorizationURL(challenge, orgID),
+		AccountName:  accountName,
+		OrgID:        orgID,
+		CreatedBy:    createdBy,
+		CreatedAt:    now,
+		ExpiresAt:    now.Add(ttl),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.saveLocked(ctx, append(s.retainedLocked(now), auth)); err != nil {
+		return "", nil, err
+	}
+
+	s.logger.Withs(sctx.Fields{
+		"device_auth_id": auth.ID,
+		"account_name":   accountName,
+		"created_by":     createdBy,
+		"expires_at":     auth.ExpiresAt.Format(time.RFC3339),
+	}).Info("Device authorization started")
+
+	return entities.FormatDevicePollCode(pollCode), auth.Clone(), nil
+}
+
+// Poll returns the authorization of a polling code (entities.ErrDeviceAuthNotFound if none)
+func (s *DeviceAuthService) Poll(ctx context.Context, pollCode string) (*entities.DeviceAuthorization, error) {
+	hash := entities.HashDevicePollCode(pollCode)
+	return s.find(func(auth *entities.DeviceAuthorization) bool {
+		return auth.PollCodeHash == hash
+	})
+}
+
+// FindByState returns the authorization whose URL carries an OAuth state (entities.ErrDeviceAuthNotFound
+// if none)
+func (s *DeviceAuthService) FindByState(ctx context.Context, state string) (*entities.DeviceAuthorization, error) {
+	return s.find(func(auth *entities.DeviceAuthorization) bool {
+		return state != "" && auth.State == state
+	})
+}
+
+// Complete exchanges an authorization code ("code#state", or a bare code with its state) for the
+// authorization whose URL carried that state and creates its account; for a user with several
+// organizations, the pending organization selection is returned instead
+func (s *DeviceAuthService) Complete(
+	ctx context.Context,
+	code, state string,
+) (*entities.DeviceAuthorization, *entities.PendingAccount, error) {
+	code = strings.TrimSpace(code)
+	if _, codeState, found := strings.Cut(code, "#"); found {
+		state = codeState
+	} else if state != "" {
+		code += "#" + state
+	}
+
+	auth, err := s.beginComplete(state)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// The account is created on behalf of the admin who started the authorization
+	exchangeCtx, cancel := context.WithTimeout(actor.NewContext(ctx, auth.CreatedBy), deviceAuthExchangeTimeout)
+	defer cancel()
+
+	acc, pending, err := s.accountSvc.CreateAccount(exchangeCtx, auth.AccountName, code, auth.CodeVerifier, auth.OrgID)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.completing, auth.ID)
+	now := time.Now()
+	updated, saveErr := s.updateLocked(ctx, auth.ID, func(auth *entities.DeviceAuthorization) {
+		if err != nil {
+			auth.LastError = err.Error()
+			return
+		}
+		auth.CompletedAt = &now
+		auth.LastError = ""
+		if acc != nil {
+			auth.AccountID = acc.ID
+		}
+		if pending != nil {
+			auth.SelectionID = pending.ID
+		}
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create account: %w", err)
+	}
+	if saveErr != nil {
+		return nil, nil, saveErr
+	}
+
+	s.logger.Withs(sctx.Fields{
+		"device_auth_id": auth.ID,
+		"account_id":     updated.AccountID,
+		"selection_id":   updated.SelectionID,
+	}).Info("Device authorization completed")
+	return updated, pending, nil
+}
+
+// CompleteSelection records the account created once the organization of an authorization was selected
+// (no-op if no authorization waits for that selection)
+func (s *DeviceAuthService) CompleteSelection(ctx context.Context, selectionID, accountID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, auth := range s.auths {
+		if selectionID != "" && auth.SelectionID == selectionID {
+			_, err := s.updateLocked(ctx, auth.ID, func(auth *entities.DeviceAuthorization) {
+				auth.AccountID = accountID
+				auth.SelectionID = ""
+			})
+			return err
+		}
+	}
+	return nil
+}
+
+// beginComplete reserves a usable authorization for one code exchange; concurrent exchanges get
+// entities.ErrDeviceAuthBusy
+func (s *DeviceAuthService) beginComplete(state string) (*entities.DeviceAuthorization, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, auth := range s.auths {
+		if state == "" || auth.State != state {
+			continue
+		}
+		if err := auth.Usable(time.Now()); err != nil {
+			return nil, err
+		}
+		if s.completing[auth.ID] {
+			return nil, entities.ErrDeviceAuthBusy
+		}
+		s.completing[auth.ID] = true
+		return auth.Clone(), nil
+	}
+	return nil, entities.ErrDeviceAuthNotFound
+}
+
+// find returns a copy of the first retained authorization matching fn
+func (s *DeviceAuthService) find(
+	fn func(auth *entities.DeviceAuthorization) bool,
+) (*entities.DeviceAuthorization, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, auth := range s.retainedLocked(time.Now()) {
+		if fn(auth) {
+			return auth, nil
+		}
+	}
+	return nil, entities.ErrDeviceAuthNotFound
+}
+
+// updateLocked applies fn to a copy of an authorization, persists the change and returns the updated copy
+// (caller must hold mu)
+func (s *DeviceAuthService) updateLocked(
+	ctx context.Context,
+	id string,
+	fn func(auth *entities.DeviceAuthorization),
+) (*entities.DeviceAuthorization, error) {
+	auths := s.retainedLocked(time.Now())
+	for _, auth := range auths {
+		if auth.ID == id {
+			fn(auth)
+			if err := s.saveLocked(ctx, auths); err != nil {
+				return nil, err
+			}
+			return auth.Clone(), nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", entities.ErrDeviceAuthNotFound, id)
+}
+
+// retainedLocked returns copies of the authorizations, dropping those completed or expired longer than
+// deviceAuthRetention ago (caller must hold mu)
+func (s *DeviceAuthService) retainedLocked(now time.Time) []*entities.DeviceAuthorization {
+	cutoff := now.Add(-deviceAuthRetention)
+	auths := make([]*entities.DeviceAuthorization, 0, len(s.auths))
+	for _, auth := range s.auths {
+		if auth.CompletedAt != nil && auth.CompletedAt.Before(cutoff) {
+			continue
+		}
+		if auth.CompletedAt == nil && auth.ExpiresAt.Before(cutoff) {
+			continue
+		}
+		auths = append(auths, auth.Clone())
+	}
+	return auths
+}
+
+// saveLocked persists authorizations and, on success, makes them the current set (caller must hold mu)
+func (s *DeviceAuthService) saveLocked(ctx context.Context, auths []*entities.DeviceAuthorization) error {
+	if err := s.repo.SaveAll(ctx, auths); err != nil {
+		return fmt.Errorf("failed to save device authorizations: %w", err)
+	}
+	s.auths = auths
+	return nil
+}
+
+// generateDevicePollCode generates a random polling code from entities.DevicePollCodeAlphabet (unformatted)
+func generateDevicePollCode() (string, error) {
+	alphabetSize := big.NewInt(int64(len(entities.DevicePollCodeAlphabet)))
+	code := make([]byte, entities.DevicePollCodeLength)
+	for i := range code {
+		n, err := rand.Int(rand.Reader, alphabetSize)
+		if err != nil {
+			return "", fmt.Errorf("failed to generate polling code: %w", err)
+		}
+		code[i] = entities.DevicePollCodeAlphabet[n.Int64()]
+	}
+	return string(code), nil
+}
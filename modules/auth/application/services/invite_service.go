@@ -0,0 +1,258 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"claude-proxy/modules/auth/domain/entities"
+	"claude-proxy/modules/auth/domain/interfaces"
+	"claude-proxy/pkg/uid"
+
+	sctx "github.com/phathdt/service-context"
+)
+
+// InviteTokenPrefix is prepended to generated invite tokens
+const InviteTokenPrefix = "inv-"
+
+// inviteRetention is how long used and expired invites stay listed before they are dropped
+const inviteRetention = 7 * 24 * time.Hour
+
+// InviteService manages one-time account invite links
+// Like admin keys, invites are written to persistence on every change: a link handed out (or used)
+// must not come back to life after a restart
+type InviteService struct {
+	repo      interfaces.InviteRepository
+	invites   []*entities.Invite
+	redeeming map[string]bool // Invite IDs with a code exchange in flight
+	mu        sync.Mutex
+	logger    sctx.Logger
+}
+
+// NewInviteService creates a new invite service and loads invites from persistence
+func NewInviteService(repo interfaces.InviteRepository, appLogger sctx.Logger) (interfaces.InviteService, error) {
+	logger := appLogger.Withs(sctx.Fields{"component": "invite-service"})
+
+	invites, err := repo.LoadAll(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("failed to load invites: %w", err)
+	}
+	logger.Withs(sctx.Fields{"count": len(invites)}).Info("Account invites loaded")
+
+	return &InviteService{
+		repo:      repo,
+		invites:   invites,
+		redeeming: make(map[string]bool),
+		logger:    logger,
+	}, nil
+}
+
+// CreateInvite creates an invite valid for ttl and returns its token (shown once) with the invite
+func (s *InviteService) CreateInvite(
+	ctx context.Context,
+	accountName, orgID, createdBy string,
+	ttl time.Duration,
+) (string, *entities.Invite, error) {
+	token, err := generateInviteToken()
+	if err != nil {
+		return "", nil, err
+	}
+
+	now := time.Now()
+	invite := &entities.Invite{
+		ID:          uid.New(),
+		TokenHash:   entities.HashInviteToken(token),
+		AccountName: accountName,
+		OrgID:       orgID,
+		CreatedBy:   createdBy,
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(ttl),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.saveLocked(ctx, append(s.retainedLocked(now), invite)); err != nil {
+		return "", nil, err
+	}
+
+	s.logger.Withs(sctx.Fields{
+		"invite_id":    invite.ID,
+		"account_name": accountName,
+		"created_by":   createdBy,
+		"expires_at":   invite.ExpiresAt.Format(time.RFC3339),
+	}).Info("Account invite created")
+
+	return token, invite.Clone(), nil
+}
+
+// ListInvites returns the invites, most recently created first
+func (s *InviteService) ListInvites(ctx context.Context) ([]*entities.Invite, error) {
+	s.mu.Lock()
+	invites := s.retainedLocked(time.Now())
+	s.mu.Unlock()
+
+	sort.Slice(invites, func(i, j int) bool {
+		return invites[i].CreatedAt.After(invites[j].CreatedAt)
+	})
+	return invites, nil
+}
+
+// RevokeInvite deletes an invite, so its link stops working
+func (s *InviteService) RevokeInvite(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	remaining := make([]*entities.Invite, 0, len(s.invites))
+	found := false
+	for _, invite := range s.retainedLocked(time.Now()) {
+		if invite.ID == id {
+			found = true
+			continue
+		}
+		remaining = append(remaining, invite)
+	}
+	if !found {
+		return fmt.Errorf("%w: %s", entities.ErrInviteNotFound, id)
+	}
+
+	if err := s.saveLocked(ctx, remaining); err != nil {
+		return err
+	}
+
+	s.logger.Withs(sctx.Fields{"invite_id": id}).Info("Account invite revoked")
+	return nil
+}
+
+// FindInvite returns the invite of a token (entities.ErrInviteNotFound if none)
+func (s *InviteService) FindInvite(ctx context.Context, token string) (*entities.Invite, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	invite := s.findLocked(token)
+	if invite == nil {
+		return nil, entities.ErrInviteNotFound
+	}
+	return invite.Clone(), nil
+}
+
+// BeginRedeem reserves a usable invite for one code exchange; concurrent redeems get entities.ErrInviteBusy
+func (s *InviteService) BeginRedeem(ctx context.Context, token string) (*entities.Invite, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	invite := s.findLocked(token)
+	if invite == nil {
+		return nil, entities.ErrInviteNotFound
+	}
+	if err := invite.Usable(time.Now()); err != nil {
+		return nil, err
+	}
+	if s.redeeming[invite.ID] {
+		return nil, entities.ErrInviteBusy
+	}
+	s.redeeming[invite.ID] = true
+	return invite.Clone(), nil
+}
+
+// FinishRedeem ends a redeem: a failed exchange (err set) releases the invite, a successful one
+// marks it used with the created account, or the pending organization selection
+func (s *InviteService) FinishRedeem(ctx context.Context, id, accountID, selectionID string, err error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.redeeming, id)
+	if err != nil {
+		return nil
+	}
+
+	now := time.Now()
+	saveErr := s.updateLocked(ctx, id, func(invite *entities.Invite) {
+		invite.UsedAt = &now
+		invite.AccountID = accountID
+		invite.SelectionID = selectionID
+	})
+	if saveErr != nil {
+		return saveErr
+	}
+
+	s.logger.Withs(sctx.Fields{
+		"invite_id":    id,
+		"account_id":   accountID,
+		"selection_id": selectionID,
+	}).Info("Account invite used")
+	return nil
+}
+
+// CompleteSelection records the account created once the organization of a used invite was selected
+func (s *InviteService) CompleteSelection(ctx context.Context, id, accountID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.updateLocked(ctx, id, func(invite *entities.Invite) {
+		invite.AccountID = accountID
+		invite.SelectionID = ""
+	})
+}
+
+// findLocked returns the invite of a token, or nil (caller must hold mu)
+func (s *InviteService) findLocked(token string) *entities.Invite {
+	hash := entities.HashInviteToken(token)
+	for _, invite := range s.invites {
+		if invite.TokenHash == hash {
+			return invite
+		}
+	}
+	return nil
+}
+
+// updateLocked applies fn to a copy of an invite and persists the change (caller must hold mu)
+func (s *InviteService) updateLocked(ctx context.Context, id string, fn func(invite *entities.Invite)) error {
+	invites := s.retainedLocked(time.Now())
+	for _, invite := range invites {
+		if invite.ID == id {
+			fn(invite)
+			return s.saveLocked(ctx, invites)
+		}
+	}
+	return fmt.Errorf("%w: %s", entities.ErrInviteNotFound, id)
+}
+
+// retainedLocked returns copies of the invites, dropping those used or expired longer than
+// inviteRetention ago (caller must hold mu)
+func (s *InviteService) retainedLocked(now time.Time) []*entities.Invite {
+	cutoff := now.Add(-inviteRetention)
+	invites := make([]*entities.Invite, 0, len(s.invites))
+	for _, invite := range s.invites {
+		if invite.UsedAt != nil && invite.UsedAt.Before(cutoff) {
+			continue
+		}
+		if invite.UsedAt == nil && invite.ExpiresAt.Before(cutoff) {
+			continue
+		}
+		invites = append(invites, invite.Clone())
+	}
+	return invites
+}
+
+// saveLocked persists invites and, on success, makes them the current set (caller must hold mu)
+func (s *InviteService) saveLocked(ctx context.Context, invites []*entities.Invite) error {
+	if err := s.repo.SaveAll(ctx, invites); err != nil {
+		return fmt.Errorf("failed to save invites: %w", err)
+	}
+	s.invites = invites
+	return nil
+}
+
+// generateInviteToken generates a new random invite token (prefix + 48 hex chars)
+func generateInviteToken() (string, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate invite token: %w", err)
+	}
+	return InviteTokenPrefix + hex.EncodeToString(buf), nil
+}
@@ -0,0 +1,206 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"time"
+
+	"claude-proxy/modules/auth/application/dto"
+	"claude-proxy/modules/auth/domain/entities"
+	"claude-proxy/modules/auth/domain/interfaces"
+	"claude-proxy/pkg/actor"
+	"claude-proxy/pkg/uid"
+
+	sctx "github.com/phathdt/service-context"
+)
+
+// redactedAuditValue replaces the values of secret fields in audit entries
+const redactedAuditValue = "***"
+
+// auditSecretFields are persisted fields whose changes are recorded without their values
+var auditSecretFields = map[string]bool{
+	"access_token":  true,
+	"refresh_token": true,
+	"api_key":       true,
+	"key":           true,
+	"key_hash":      true,
+}
+
+// auditIgnoredFields are persisted fields that change on their own (usage counters, bookkeeping), so they are
+// not part of the recorded changes
+var auditIgnoredFields = map[string]bool{
+	"updated_at":                   true,
+	"updated_by":                   true,
+	"usage_count":                  true,
+	"last_used_at":                 true,
+	"usage_window_start":           true,
+	"window_requests":              true,
+	"window_tokens":                true,
+	"window_input_tokens":          true,
+	"window_cache_creation_tokens": true,
+	"window_cache_read_tokens":     true,
+}
+
+// MutationAuditService records admin changes of accounts and tokens in the audit trail
+// Changes are the persisted fields (as in accounts.json and tokens.json) that differ before and after,
+// so the trail reads like a diff of the data files.
+type MutationAuditService struct {
+	repo   interfaces.MutationAuditRepository
+	logger sctx.Logger
+}
+
+// NewMutationAuditService creates a new mutation audit service
+func NewMutationAuditService(
+	repo interfaces.MutationAuditRepository,
+	appLogger sctx.Logger,
+) interfaces.MutationAuditService {
+	return &MutationAuditService{
+		repo:   repo,
+		logger: appLogger.Withs(sctx.Fields{"component": "mutation-audit"}),
+	}
+}
+
+// RecordAccount records a change of an account; soft-deleting an account is recorded as a delete
+func (s *MutationAuditService) RecordAccount(ctx context.Context, before, after *entities.Account) {
+	var id string
+	var old, updated interface{}
+	action := mutationAction(before != nil, after != nil)
+	if before != nil {
+		id = before.ID
+		old = auditAccountFields(before)
+	}
+	if after != nil {
+		id = after.ID
+		updated = auditAccountFields(after)
+		if before != nil && before.DeletedAt == nil && after.DeletedAt != nil {
+			action = entities.MutationActionDelete
+		}
+	}
+	s.record(ctx, action, entities.MutationEntityAccount, id, old, updated)
+}
+
+// RecordToken records a change of a token
+func (s *MutationAuditService) RecordToken(ctx context.Context, before, after *entities.Token) {
+	var id string
+	var old, updated interface{}
+	if before != nil {
+		id = before.ID
+		old = dto.ToTokenPersistenceDTO(before)
+	}
+	if after != nil {
+		id = after.ID
+		updated = dto.ToTokenPersistenceDTO(after)
+	}
+	s.record(ctx, mutationAction(before != nil, after != nil), entities.MutationEntityToken, id, old, updated)
+}
+
+// ListMutations returns the recorded changes matching filter, newest first
+func (s *MutationAuditService) ListMutations(
+	ctx context.Context,
+	filter entities.MutationAuditFilter,
+) ([]*entities.MutationAuditEntry, error) {
+	return s.repo.List(ctx, filter)
+}
+
+// record appends an entry with the changed fields of two persistence DTOs (nil when absent)
+// Failures are logged: the change itself has already been made
+func (s *MutationAuditService) record(
+	ctx context.Context,
+	action entities.MutationAction,
+	entityType entities.MutationEntityType,
+	entityID string,
+	before, after interface{},
+) {
+	var changes map[string]entities.FieldChange
+	if after != nil {
+		changes = auditChanges(auditFields(before), auditFields(after))
+		if action == entities.MutationActionUpdate && len(changes) == 0 {
+			return
+		}
+	}
+
+	entry := &entities.MutationAuditEntry{
+		ID:         uid.New(),
+		Timestamp:  time.Now(),
+		Actor:      actor.OrUnknown(actor.FromContext(ctx)),
+		Action:     action,
+		EntityType: entityType,
+		EntityID:   entityID,
+		Changes:    changes,
+	}
+	fields := sctx.Fields{
+		"actor":       entry.Actor,
+		"action":      string(action),
+		"entity_type": string(entityType),
+		"entity_id":   entityID,
+	}
+	if err := s.repo.Append(ctx, entry); err != nil {
+		fields["error"] = err.Error()
+		s.logger.Withs(fields).Error("Failed to record admin change in the audit trail")
+		return
+	}
+	s.logger.Withs(fields).Debug("Admin change recorded")
+}
+
+// auditAccountFields returns the persisted fields of an account, with the egress proxy credentials masked
+func auditAccountFields(account *entities.Account) *dto.AccountPersistenceDTO {
+	fields := dto.ToAccountPersistenceDTO(account)
+	fields.ProxyURL = account.MaskedProxyURL()
+	return fields
+}
+
+// mutationAction returns the action of a change from whether the entity existed before and after it
+func mutationAction(existed, exists bool) entities.MutationAction {
+	switch {
+	case !existed:
+		return entities.MutationActionCreate
+	case !exists:
+		return entities.MutationActionDelete
+	default:
+		return entities.MutationActionUpdate
+	}
+}
+
+// auditFields returns the JSON fields of a persistence DTO (none for nil)
+func auditFields(v interface{}) map[string]interface{} {
+	fields := make(map[string]interface{})
+	if v == nil {
+		return fields
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fields
+	}
+	_ = json.Unmarshal(data, &fields)
+	return fields
+}
+
+// auditChanges returns the fields that differ between before and after, with secrets redacted
+func auditChanges(before, after map[string]interface{}) map[string]entities.FieldChange {
+	changes := make(map[string]entities.FieldChange)
+	for _, fields := range []map[string]interface{}{before, after} {
+		for field := range fields {
+			if _, seen := changes[field]; seen || auditIgnoredFields[field] {
+				continue
+			}
+			old, updated := before[field], after[field]
+			if reflect.DeepEqual(old, updated) {
+				continue
+			}
+			if auditSecretFields[field] {
+				old, updated = redactAuditValue(old), redactAuditValue(updated)
+			}
+			changes[field] = entities.FieldChange{Old: old, New: updated}
+		}
+	}
+	return changes
+}
+
+// redactAuditValue hides a secret value, keeping whether it was set
+func redactAuditValue(value interface{}) interface{} {
+	if value == nil || value == "" {
+		return value
+	}
+	return redactedAuditValue
+}
@@ -0,0 +1,6 @@
+//go:build !race
+
+package services
+
+// raceEnabled is set when tests run with the race detector, which slows them down too much for timing checks
+const raceEnabled = false
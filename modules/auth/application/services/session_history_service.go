@@ -0,0 +1,167 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"claude-proxy/config"
+	"claude-proxy/modules/auth/domain/entities"
+	"claude-proxy/modules/auth/domain/interfaces"
+
+	sctx "github.com/phathdt/service-context"
+)
+
+const (
+	// sessionHistoryQueueSize bounds the records waiting to be written; more are dropped
+	sessionHistoryQueueSize = 1024
+
+	// sessionHistoryPruneInterval is how often files past session.history_retention are deleted
+	sessionHistoryPruneInterval = 24 * time.Hour
+
+	// sessionHistoryDropLogInterval rate-limits the queue overflow warning
+	sessionHistoryDropLogInterval = time.Minute
+)
+
+// SessionHistoryService writes the history records of new sessions from a background goroutine
+// Session creation only does a non-blocking channel send: a full queue drops the record and counts it, and a
+// failed write is logged and loses its records, so a slow or failing disk never delays nor fails requests.
+type SessionHistoryService struct {
+	repo      interfaces.SessionHistoryRepository
+	enabled   bool
+	maskIPs   bool          // Record IPv4 /24 and IPv6 /48 networks instead of full addresses
+	retention time.Duration // How long files are kept after their month ends
+	queue     chan *entities.SessionHistoryRecord
+
+	done        chan struct{} // Closed by Stop
+	wg          sync.WaitGroup
+	dropped     atomic.Int64
+	lastDropLog atomic.Int64 // Unix nanoseconds of the last overflow warning
+
+	logger sctx.Logger
+}
+
+// NewSessionHistoryService creates the session history recorder configured by session.history_*
+func NewSessionHistoryService(
+	repo interfaces.SessionHistoryRepository,
+	cfg config.SessionConfig,
+	logger sctx.Logger,
+) interfaces.SessionHistoryService {
+	return &SessionHistoryService{
+		repo:      repo,
+		enabled:   cfg.HistoryEnabled,
+		maskIPs:   cfg.HistoryMaskIPs,
+		retention: cfg.HistoryRetention,
+		queue:     make(chan *entities.SessionHistoryRecord, sessionHistoryQueueSize),
+		done:      make(chan struct{}),
+		logger:    logger,
+	}
+}
+
+// Record queues the history record of a new session without blocking; it is dropped when the queue is full
+func (s *SessionHistoryService) Record(session *entities.Session) {
+	if !s.enabled || session == nil {
+		return
+	}
+
+	select {
+	case s.queue <- entities.NewSessionHistoryRecord(session, s.maskIPs):
+	default:
+		dropped := s.dropped.Add(1)
+		now := time.Now().UnixNano()
+		last := s.lastDropLog.Load()
+		if now-last >= int64(sessionHistoryDropLogInterval) && s.lastDropLog.CompareAndSwap(last, now) {
+			s.logger.Withs(sctx.Fields{
+				"queue_size": sessionHistoryQueueSize,
+				"dropped":    dropped,
+			}).Warn("Session history queue full, dropping records")
+		}
+	}
+}
+
+// List returns the records of a month, of one token unless tokenID is empty, newest first
+// Files recorded before history was disabled can still be read.
+func (s *SessionHistoryService) List(
+	ctx context.Context,
+	month time.Time,
+	tokenID string,
+) ([]*entities.SessionHistoryRecord, error) {
+	return s.repo.List(ctx, entities.SessionHistoryMonth(month), tokenID)
+}
+
+// Start launches the background writer
+func (s *SessionHistoryService) Start() {
+	if !s.enabled {
+		return
+	}
+	s.wg.Add(1)
+	go s.run()
+	s.logger.Withs(sctx.Fields{
+		"retention": s.retention.String(),
+		"mask_ips":  s.maskIPs,
+	}).Info("Session history recording started")
+}
+
+// Stop writes the queued records and waits for the background writer
+func (s *SessionHistoryService) Stop() {
+	close(s.done)
+	s.wg.Wait()
+}
+
+// run writes queued records until Stop, pruning old files at start and then daily
+func (s *SessionHistoryService) run() {
+	defer s.wg.Done()
+
+	s.prune()
+	ticker := time.NewTicker(sessionHistoryPruneInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.done:
+			s.write(s.drain(nil))
+			return
+		case <-ticker.C:
+			s.prune()
+		case record := <-s.queue:
+			s.write(s.drain([]*entities.SessionHistoryRecord{record}))
+		}
+	}
+}
+
+// drain appends the records already queued to batch, so a burst of sessions is written at once
+func (s *SessionHistoryService) drain(batch []*entities.SessionHistoryRecord) []*entities.SessionHistoryRecord {
+	for {
+		select {
+		case record := <-s.queue:
+			batch = append(batch, record)
+		default:
+			return batch
+		}
+	}
+}
+
+// write appends a batch of records, logging (and losing) them on failure
+func (s *SessionHistoryService) write(batch []*entities.SessionHistoryRecord) {
+	if len(batch) == 0 {
+		return
+	}
+	if err := s.repo.Append(context.Background(), batch); err != nil {
+		s.logger.Withs(sctx.Fields{
+			"error":   err.Error(),
+			"records": len(batch),
+		}).Warn("Failed to write session history records")
+	}
+}
+
+// prune deletes the files of the months that ended longer than the retention ago
+func (s *SessionHistoryService) prune() {
+	pruned, err := s.repo.Prune(context.Background(), time.Now().Add(-s.retention))
+	if err != nil {
+		s.logger.Withs(sctx.Fields{"error": err.Error()}).Warn("Failed to prune session history")
+	}
+	if len(pruned) > 0 {
+		s.logger.Withs(sctx.Fields{"months": pruned}).Info("Pruned session history past retention")
+	}
+}
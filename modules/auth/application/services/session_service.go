@@ -5,17 +5,20 @@ import (
 	"fmt"
 	"net"
 	"net/http"
-	"strings"
+	"sort"
 	"sync"
 	"time"
 
 	"claude-proxy/config"
+	"claude-proxy/modules/auth/application/dto"
 	"claude-proxy/modules/auth/domain/entities"
 	"claude-proxy/modules/auth/domain/interfaces"
 	"claude-proxy/pkg/errors"
+	"claude-proxy/pkg/httpproxy"
+	"claude-proxy/pkg/uid"
 
-	"github.com/google/uuid"
 	sctx "github.com/phathdt/service-context"
+	"github.com/phathdt/service-context/core"
 )
 
 // SessionService implements session management with hybrid storage pattern
@@ -23,10 +26,17 @@ import (
 type SessionService struct {
 	cacheRepo       interfaces.SessionCacheRepository
 	persistenceRepo interfaces.SessionPersistenceRepository
+	history         interfaces.SessionHistoryService // Records new sessions for security reviews
 	maxConcurrent   int
-	sessionTTL      time.Duration
+	maxPerToken     int
+	scope           string
+	sessionTTL      time.Duration                        // Default idle timeout
+	ttlByRole       map[entities.TokenRole]time.Duration // Idle timeout per token role
+	maxLifetime     time.Duration                        // Default maximum lifetime (0 = none)
+	lifetimeByRole  map[entities.TokenRole]time.Duration // Maximum lifetime per token role
 	enabled         bool
 	dirty           bool
+	removed         bool // Entries were removed from the cache since the last save
 	mu              sync.RWMutex
 	logger          sctx.Logger
 }
@@ -35,6 +45,7 @@ type SessionService struct {
 func NewSessionService(
 	cacheRepo interfaces.SessionCacheRepository,
 	persistenceRepo interfaces.SessionPersistenceRepository,
+	history interfaces.SessionHistoryService,
 	cfg *config.Config,
 	appLogger sctx.Logger,
 ) interfaces.SessionService {
@@ -43,8 +54,14 @@ func NewSessionService(
 	svc := &SessionService{
 		cacheRepo:       cacheRepo,
 		persistenceRepo: persistenceRepo,
+		history:         history,
 		maxConcurrent:   cfg.Session.MaxConcurrent,
+		maxPerToken:     cfg.Session.MaxPerToken,
+		scope:           cfg.Session.Scope,
 		sessionTTL:      cfg.Session.SessionTTL,
+		ttlByRole:       roleDurations(cfg.Session.TTLByRole),
+		maxLifetime:     cfg.Session.MaxLifetime,
+		lifetimeByRole:  roleDurations(cfg.Session.MaxLifetimeByRole),
 		enabled:         cfg.Session.Enabled,
 		dirty:           false,
 		logger:          logger,
@@ -60,14 +77,46 @@ func NewSessionService(
 	return svc
 }
 
+// roleDurations keys configured per-role durations by token role
+func roleDurations(byRole map[string]time.Duration) map[entities.TokenRole]time.Duration {
+	durations := make(map[entities.TokenRole]time.Duration, len(byRole))
+	for role, d := range byRole {
+		durations[entities.TokenRole(role)] = d
+	}
+	return durations
+}
+
+// idleTTL returns the idle timeout of sessions created by a token of the given role
+func (s *SessionService) idleTTL(role entities.TokenRole) time.Duration {
+	if ttl, ok := s.ttlByRole[role]; ok {
+		return ttl
+	}
+	return s.sessionTTL
+}
+
+// lifetime returns the maximum lifetime of sessions created by a token of the given role (0 = none)
+func (s *SessionService) lifetime(role entities.TokenRole) time.Duration {
+	if lifetime, ok := s.lifetimeByRole[role]; ok {
+		return lifetime
+	}
+	return s.maxLifetime
+}
+
 // loadFromPersistence loads all sessions from persistent storage into cache
 func (s *SessionService) loadFromPersistence() error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	sessions, err := s.persistenceRepo.LoadAll(context.Background())
+	// Prune sessions past retention from the file first, so they never reach the cache; the sessions kept come
+	// from the same read, so a large file is parsed once
+	sessions, pruned, err := s.persistenceRepo.Compact(context.Background())
 	if err != nil {
-		return fmt.Errorf("failed to load sessions from persistence: %w", err)
+		s.logger.Withs(sctx.Fields{"error": err.Error()}).Warn("Failed to compact sessions file")
+		if sessions, err = s.persistenceRepo.LoadAll(context.Background()); err != nil {
+			return fmt.Errorf("failed to load sessions from persistence: %w", err)
+		}
+	} else if pruned > 0 {
+		s.logger.Withs(sctx.Fields{"pruned": pruned}).Info("Pruned ended sessions past retention")
 	}
 
 	// Load each session into cache
@@ -98,11 +147,30 @@ func (s *SessionService) isDirty() bool {
 	return s.dirty
 }
 
-// clearDirty clears the dirty flag
-func (s *SessionService) clearDirty() {
+// markRemoved marks data as changed by removing entries, so the next save may leave the storage empty
+func (s *SessionService) markRemoved() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.dirty = true
+	s.removed = true
+}
+
+// clearDirty clears the dirty flag, returning whether entries were removed since the last save
+func (s *SessionService) clearDirty() bool {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	removed := s.removed
 	s.dirty = false
+	s.removed = false
+	return removed
+}
+
+// restoreDirty marks data as changed again after a failed save
+func (s *SessionService) restoreDirty(removed bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.dirty = true
+	s.removed = s.removed || removed
 }
 
 // Sync syncs cache data to persistent storage (called every 1 minute)
@@ -117,35 +185,102 @@ func (s *SessionService) Sync(ctx context.Context) error {
 
 	s.logger.Debug("Syncing sessions to persistent storage")
 
+	// Clear before listing so changes made during the save mark the data dirty again
+	removed := s.clearDirty()
+
 	// Get all sessions from cache
 	sessions, err := s.cacheRepo.ListAllSessions(ctx)
 	if err != nil {
+		s.restoreDirty(removed)
 		return fmt.Errorf("failed to list sessions from cache: %w", err)
 	}
 
 	// Batch save all sessions to persistent storage
-	if err := s.persistenceRepo.SaveAll(ctx, sessions); err != nil {
+	if err := s.persistenceRepo.SaveAll(ctx, sessions, removed); err != nil {
+		s.restoreDirty(removed)
 		s.logger.Withs(sctx.Fields{
 			"error": err,
 		}).Error("Failed to save sessions to persistence")
 		return fmt.Errorf("failed to save sessions: %w", err)
 	}
 
-	s.clearDirty()
 	s.logger.Withs(sctx.Fields{"count": len(sessions)}).Info("Sessions synced to persistent storage")
 	return nil
 }
 
+// Mirror replaces the cached sessions with a primary instance's (warm standby)
+// Sessions the primary no longer holds are removed, and every session of the primary is added or replaced as
+// is; the next sync writes the result to sessions.json.
+func (s *SessionService) Mirror(ctx context.Context, sessions []*entities.Session) (*entities.MirrorResult, error) {
+	if !s.enabled || s.cacheRepo == nil {
+		return &entities.MirrorResult{}, nil
+	}
+
+	cached, err := s.cacheRepo.ListAllSessions(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions from cache: %w", err)
+	}
+
+	primary := make(map[string]bool, len(sessions))
+	for _, session := range sessions {
+		primary[session.ID] = true
+	}
+	existing := make(map[string]bool, len(cached))
+	var stale []string
+	for _, session := range cached {
+		if primary[session.ID] {
+			existing[session.ID] = true
+		} else {
+			stale = append(stale, session.ID)
+		}
+	}
+
+	result := &entities.MirrorResult{}
+	removed, err := s.cacheRepo.DeleteSessions(ctx, stale)
+	if err != nil {
+		return nil, fmt.Errorf("failed to remove sessions: %w", err)
+	}
+	result.Removed = len(removed)
+
+	for _, session := range sessions {
+		if existing[session.ID] {
+			err = s.cacheRepo.UpdateSession(ctx, session)
+		} else {
+			err = s.cacheRepo.CreateSession(ctx, session)
+		}
+		if err != nil {
+			result.Skipped++
+			s.logger.Withs(sctx.Fields{"session_id": session.ID, "error": err.Error()}).Warn(
+				"Session of the primary conflicts with the cache, skipped",
+			)
+			continue
+		}
+		if existing[session.ID] {
+			result.Updated++
+		} else {
+			result.Added++
+		}
+	}
+
+	if result.Removed > 0 {
+		s.markRemoved()
+	} else {
+		s.markDirty()
+	}
+	return result, nil
+}
+
 // FinalSync performs final sync on graceful shutdown
 func (s *SessionService) FinalSync(ctx context.Context) error {
 	s.logger.Info("Performing final sync of sessions")
 	return s.Sync(ctx)
 }
 
-// CreateSession creates a new session or reuses existing one (per client: IP + UserAgent)
+// CreateSession creates a new session or reuses existing one (per client: token + IP + UserAgent)
+// New sessions are checked against the global and/or per-token limits selected by session.scope
 func (s *SessionService) CreateSession(
 	ctx context.Context,
-	tokenID string,
+	token *entities.Token,
 	req *http.Request,
 ) (*entities.Session, error) {
 	// If session limiting is disabled, skip
@@ -157,11 +292,11 @@ func (s *SessionService) CreateSession(
 	ipWithoutPort := s.getIPWithoutPort(req.RemoteAddr)
 	userAgent := req.UserAgent()
 
-	// Check if there's an existing active session for this IP + User-Agent
-	existingSession := s.findExistingSession(ctx, ipWithoutPort, userAgent)
+	// Check if there's an existing active session for this token + IP + User-Agent
+	existingSession := s.findExistingSession(ctx, token.ID, ipWithoutPort, userAgent)
 	if existingSession != nil {
 		// Reuse existing session - just refresh it
-		existingSession.Refresh(s.sessionTTL)
+		existingSession.Refresh(s.idleTTL(existingSession.TokenRole))
 		if err := s.cacheRepo.UpdateSession(ctx, existingSession); err != nil {
 			s.logger.Withs(sctx.Fields{"error": err}).Warn("Failed to refresh existing session")
 		} else {
@@ -174,42 +309,28 @@ func (s *SessionService) CreateSession(
 		return existingSession, nil
 	}
 
-	// No existing session found - check global active session count
-	activeCount, err := s.cacheRepo.CountActiveSessions(ctx)
-	if err != nil {
-		s.logger.Withs(sctx.Fields{"error": err}).Error("Failed to count active sessions")
-		return nil, fmt.Errorf("failed to count active sessions: %w", err)
+	// No existing session found - check the applicable limits
+	if err := s.checkSessionLimits(ctx, token); err != nil {
+		return nil, err
 	}
 
-	// Check if global limit is exceeded
-	if activeCount >= s.maxConcurrent {
-		s.logger.Withs(sctx.Fields{
-			"active_count":   activeCount,
-			"max_concurrent": s.maxConcurrent,
-		}).Warn("Global session limit exceeded")
-
-		return nil, errors.NewRateLimitError(
-			fmt.Sprintf("concurrent session limit exceeded: %d/%d active sessions", activeCount, s.maxConcurrent),
-			map[string]interface{}{
-				"active_count":   activeCount,
-				"max_concurrent": s.maxConcurrent,
-			},
-		)
-	}
-
-	// Create new session
+	// Create new session: the idle expiry slides on activity, the maximum lifetime is fixed now
 	now := time.Now()
 	session := &entities.Session{
-		ID:          uuid.Must(uuid.NewV7()).String(),
-		TokenID:     tokenID,
+		ID:          uid.New(),
+		TokenID:     token.ID,
+		TokenRole:   token.Role,
 		UserAgent:   userAgent,
 		IPAddress:   ipWithoutPort, // Store IP without port for consistency
 		CreatedAt:   now,
 		LastSeenAt:  now,
-		ExpiresAt:   now.Add(s.sessionTTL),
 		IsActive:    true,
 		RequestPath: req.URL.Path,
 	}
+	if lifetime := s.lifetime(token.Role); lifetime > 0 {
+		session.HardExpiresAt = now.Add(lifetime)
+	}
+	session.Refresh(s.idleTTL(token.Role))
 
 	// Save to memory
 	if err := s.cacheRepo.CreateSession(ctx, session); err != nil {
@@ -218,15 +339,124 @@ func (s *SessionService) CreateSession(
 	}
 
 	s.markDirty()
+	s.history.Record(session)
 	s.logger.Withs(sctx.Fields{
 		"session_id": session.ID,
-		"token_id":   tokenID,
+		"token_id":   token.ID,
 		"ip_address": session.IPAddress,
 	}).Info("New session created")
 
 	return session, nil
 }
 
+// checkSessionLimits returns a rate limit error if a new session for token would exceed
+// the global limit (scope global/both) or the token's limit (scope token/both)
+func (s *SessionService) checkSessionLimits(ctx context.Context, token *entities.Token) error {
+	checkGlobal := s.scope != config.SessionScopeToken
+	checkToken := s.scope != config.SessionScopeGlobal
+
+	activeCount := 0
+	if checkGlobal {
+		count, err := s.cacheRepo.CountActiveSessions(ctx)
+		if err != nil {
+			s.logger.Withs(sctx.Fields{"error": err}).Error("Failed to count active sessions")
+			return fmt.Errorf("failed to count active sessions: %w", err)
+		}
+		activeCount = count
+	}
+
+	tokenActiveCount, tokenLimit := 0, 0
+	if checkToken {
+		count, err := s.cacheRepo.CountActiveSessionsByToken(ctx, token.ID)
+		if err != nil {
+			s.logger.Withs(sctx.Fields{"error": err, "token_id": token.ID}).Error("Failed to count token sessions")
+			return fmt.Errorf("failed to count active sessions for token: %w", err)
+		}
+		tokenActiveCount = count
+		tokenLimit = token.SessionLimit(s.maxPerToken)
+	}
+
+	globalExceeded := checkGlobal && activeCount >= s.maxConcurrent
+	tokenExceeded := checkToken && tokenActiveCount >= tokenLimit
+	if !globalExceeded && !tokenExceeded {
+		return nil
+	}
+
+	// Report the token limit first: it is the one the caller can act on
+	limit := "global"
+	message := fmt.Sprintf(
+		"concurrent session limit exceeded: %d/%d active sessions", activeCount, s.maxConcurrent,
+	)
+	if tokenExceeded {
+		limit = "token"
+		message = fmt.Sprintf(
+			"per-token session limit exceeded: %d/%d active sessions for this token", tokenActiveCount, tokenLimit,
+		)
+		if checkGlobal {
+			message += fmt.Sprintf(" (global: %d/%d)", activeCount, s.maxConcurrent)
+		}
+	} else if checkToken {
+		message += fmt.Sprintf(" (token: %d/%d)", tokenActiveCount, tokenLimit)
+	}
+
+	details := map[string]interface{}{"limit": limit}
+	if checkGlobal {
+		details["active_count"] = activeCount
+		details["max_concurrent"] = s.maxConcurrent
+	}
+	if checkToken {
+		details["token_active_count"] = tokenActiveCount
+		details["token_max_sessions"] = tokenLimit
+	}
+
+	// Clients are told to retry once the exceeded limits have a free slot again
+	now := time.Now()
+	retryAt, reportedLimit := now, s.maxConcurrent
+	if globalExceeded {
+		retryAt = s.nextSessionExpiry(ctx, "", now)
+	}
+	if tokenExceeded {
+		reportedLimit = tokenLimit
+		if next := s.nextSessionExpiry(ctx, token.ID, now); next.After(retryAt) {
+			retryAt = next
+		}
+	}
+
+	s.logger.Withs(sctx.Fields{
+		"token_id":    token.ID,
+		"scope":       s.scope,
+		"details":     details,
+		"retry_after": retryAt.Sub(now).Round(time.Second).String(),
+	}).Warn("Session limit exceeded")
+
+	return errors.WithHeaders(
+		errors.NewRateLimitError(message, details), httpproxy.RateLimitHeaders(reportedLimit, retryAt, now),
+	)
+}
+
+// nextSessionExpiry returns when the first live session (of tokenID, or of any token when empty) expires,
+// freeing its slot; a full idle timeout from now if it can't be determined
+func (s *SessionService) nextSessionExpiry(ctx context.Context, tokenID string, now time.Time) time.Time {
+	var sessions []*entities.Session
+	var err error
+	if tokenID != "" {
+		sessions, err = s.cacheRepo.ListSessionsByToken(ctx, tokenID)
+	} else {
+		sessions, err = s.cacheRepo.ListAllSessions(ctx)
+	}
+
+	next := now.Add(s.sessionTTL)
+	if err != nil {
+		return next
+	}
+	for _, session := range sessions {
+		if session.IsLive() && session.ExpiresAt.Before(next) {
+			next = session.ExpiresAt
+		}
+	}
+	return next
+}
+
 // getIPWithoutPort extracts IP address without port
 func (s *SessionService) getIPWithoutPort(address string) string {
 	// Handle IPv6 addresses like [::1]:12345 or IPv4 like 127.0.0.1:12345
@@ -238,29 +468,18 @@ func (s *SessionService) getIPWithoutPort(address string) string {
 	return host
 }
 
-// findExistingSession looks for an active session with the same IP and User-Agent
+// findExistingSession looks for an active session of tokenID with the same IP and User-Agent
+// The token is part of the fingerprint so clients sharing a NAT address and client version stay
+// separate sessions; the lookup goes through the repository's fingerprint index
 func (s *SessionService) findExistingSession(
 	ctx context.Context,
-	ipWithoutPort, userAgent string,
+	tokenID, ipWithoutPort, userAgent string,
 ) *entities.Session {
-	sessions, err := s.cacheRepo.ListAllSessions(ctx)
+	session, err := s.cacheRepo.FindLiveSession(ctx, tokenID, ipWithoutPort, userAgent)
 	if err != nil {
 		return nil
 	}
-
-	now := time.Now()
-	for _, session := range sessions {
-		// Match by IP (without port) and User-Agent
-		sessionIP := s.getIPWithoutPort(session.IPAddress)
-		if sessionIP == ipWithoutPort &&
-			strings.EqualFold(session.UserAgent, userAgent) &&
-			session.IsActive &&
-			now.Before(session.ExpiresAt) {
-			return session
-		}
-	}
-
-	return nil
+	return session
 }
 
 // ValidateSession checks if a session is valid and within limits
@@ -292,7 +511,7 @@ func (s *SessionService) RefreshSession(ctx context.Context, sessionID string) e
 		return err
 	}
 
-	session.Refresh(s.sessionTTL)
+	session.Refresh(s.idleTTL(session.TokenRole))
 
 	if err := s.cacheRepo.UpdateSession(ctx, session); err != nil {
 		s.logger.Withs(sctx.Fields{"error": err, "session_id": sessionID}).Error("Failed to refresh session")
@@ -304,6 +523,32 @@ func (s *SessionService) RefreshSession(ctx context.Context, sessionID string) e
 	return nil
 }
 
+// TouchSession records activity on a session and extends its expiry (sliding window)
+// Only the cache is updated; the dirty flag folds the change into the next periodic sync
+func (s *SessionService) TouchSession(ctx context.Context, sessionID string) error {
+	if !s.enabled || s.cacheRepo == nil {
+		return nil
+	}
+
+	session, err := s.cacheRepo.GetSession(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+
+	if !session.IsActive {
+		return nil
+	}
+
+	session.Refresh(s.idleTTL(session.TokenRole))
+
+	if err := s.cacheRepo.UpdateSession(ctx, session); err != nil {
+		return fmt.Errorf("failed to touch session: %w", err)
+	}
+
+	s.markDirty()
+	return nil
+}
+
 // RevokeSession manually revokes a session
 func (s *SessionService) RevokeSession(ctx context.Context, sessionID string) error {
 	if !s.enabled || s.cacheRepo == nil {
@@ -315,11 +560,67 @@ func (s *SessionService) RevokeSession(ctx context.Context, sessionID string) er
 		return fmt.Errorf("failed to revoke session: %w", err)
 	}
 
-	s.markDirty()
+	s.markRemoved()
 	s.logger.Withs(sctx.Fields{"session_id": sessionID}).Info("Session revoked")
 	return nil
 }
 
+// RevokeTokenSession revokes a session only if it belongs to tokenID (self-service)
+// Sessions of other tokens are reported as not found, so their IDs can't be probed
+func (s *SessionService) RevokeTokenSession(ctx context.Context, tokenID, sessionID string) error {
+	if !s.enabled || s.cacheRepo == nil {
+		return fmt.Errorf("session limiting is not enabled")
+	}
+
+	session, err := s.cacheRepo.GetSession(ctx, sessionID)
+	if err != nil || session.TokenID != tokenID {
+		return fmt.Errorf("session not found: %s", sessionID)
+	}
+
+	return s.RevokeSession(ctx, sessionID)
+}
+
+// RevokeSessions revokes every session matching the filter (admin)
+// A token_id filter reads that token's sessions through the cache's token index; the matching sessions are
+// deleted in one pass and the sync is scheduled once
+func (s *SessionService) RevokeSessions(ctx context.Context, filter *dto.RevokeSessionsRequest) ([]string, error) {
+	if !s.enabled || s.cacheRepo == nil {
+		return nil, fmt.Errorf("session limiting is not enabled")
+	}
+	if !filter.HasFilter() && !filter.All {
+		return nil, fmt.Errorf("a filter (token_id, ip, created_before) or all is required")
+	}
+
+	var sessions []*entities.Session
+	var err error
+	if filter.TokenID != "" {
+		sessions, err = s.cacheRepo.ListSessionsByToken(ctx, filter.TokenID)
+	} else {
+		sessions, err = s.cacheRepo.ListAllSessions(ctx)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var matching []string
+	for _, session := range sessions {
+		if filter.Matches(session) {
+			matching = append(matching, session.ID)
+		}
+	}
+	sort.Strings(matching)
+
+	revoked, err := s.cacheRepo.DeleteSessions(ctx, matching)
+	if err != nil {
+		s.logger.Withs(sctx.Fields{"error": err}).Error("Failed to revoke sessions")
+		return nil, fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+	if len(revoked) > 0 {
+		s.markRemoved()
+	}
+	return revoked, nil
+}
+
 // GetAllSessions retrieves all active sessions (admin)
 func (s *SessionService) GetAllSessions(ctx context.Context) ([]*entities.Session, error) {
 	if !s.enabled || s.cacheRepo == nil {
@@ -328,6 +629,75 @@ func (s *SessionService) GetAllSessions(ctx context.Context) ([]*entities.Sessio
 	return s.cacheRepo.ListAllSessions(ctx)
 }
 
+// ListSessions retrieves sessions matching the query, sorted and paginated (admin)
+// A token_id filter reads that token's sessions through the cache's token index instead of every session
+func (s *SessionService) ListSessions(
+	ctx context.Context,
+	query *dto.SessionQueryParams,
+	paging *core.Paging,
+) ([]*entities.Session, *entities.SessionCounts, error) {
+	if !s.enabled || s.cacheRepo == nil {
+		return []*entities.Session{}, &entities.SessionCounts{}, nil
+	}
+
+	counts, err := s.cacheRepo.CountSessions(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var sessions []*entities.Session
+	if query.TokenID != "" {
+		sessions, err = s.cacheRepo.ListSessionsByToken(ctx, query.TokenID)
+	} else {
+		sessions, err = s.cacheRepo.ListAllSessions(ctx)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	filtered := make([]*entities.Session, 0, len(sessions))
+	for _, session := range sessions {
+		if query.IP != "" && session.IPAddress != query.IP {
+			continue
+		}
+		if query.Active && !session.IsLive() {
+			continue
+		}
+		if query.Expired && !session.IsExpired() {
+			continue
+		}
+		if !query.CreatedAfter.IsZero() && !session.CreatedAt.After(query.CreatedAfter) {
+			continue
+		}
+		filtered = append(filtered, session)
+	}
+
+	sortSessions(filtered, query.Sort, query.Order == "asc")
+
+	// Set total count and return the requested page
+	paging.Total = int64(len(filtered))
+	start := min((paging.Page-1)*paging.Limit, len(filtered))
+	end := min(start+paging.Limit, len(filtered))
+	return filtered[start:end], counts, nil
+}
+
+// sortSessions orders sessions by created_at or last_seen_at (default), newest first unless ascending
+// Ties are broken by ID, so pages stay stable between requests
+func sortSessions(sessions []*entities.Session, field string, ascending bool) {
+	timeOf := func(session *entities.Session) time.Time { return session.LastSeenAt }
+	if field == "created_at" {
+		timeOf = func(session *entities.Session) time.Time { return session.CreatedAt }
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		ti, tj := timeOf(sessions[i]), timeOf(sessions[j])
+		if ti.Equal(tj) {
+			return sessions[i].ID < sessions[j].ID
+		}
+		return ti.Before(tj) == ascending
+	})
+}
+
 // CleanupExpiredSessions removes expired sessions
 func (s *SessionService) CleanupExpiredSessions(ctx context.Context) (int, error) {
 	if !s.enabled || s.cacheRepo == nil {
@@ -341,7 +711,7 @@ func (s *SessionService) CleanupExpiredSessions(ctx context.Context) (int, error
 	}
 
 	if count > 0 {
-		s.markDirty()
+		s.markRemoved()
 		s.logger.Withs(sctx.Fields{"cleaned_count": count}).Info("Expired sessions cleaned up")
 	}
 
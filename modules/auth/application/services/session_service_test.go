@@ -0,0 +1,404 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"claude-proxy/config"
+	"claude-proxy/modules/auth/application/dto"
+	"claude-proxy/modules/auth/domain/entities"
+	"claude-proxy/modules/auth/domain/interfaces"
+	"claude-proxy/modules/auth/infrastructure/repositories"
+	apperrors "claude-proxy/pkg/errors"
+	"claude-proxy/pkg/logging"
+
+	sctx "github.com/phathdt/service-context"
+)
+
+// quietLogger returns a logger dropping everything below errors
+func quietLogger(tb testing.TB) sctx.Logger {
+	tb.Helper()
+
+	registry, err := logging.NewRegistry("error")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return registry.Wrap(sctx.GlobalLogger().GetLogger("test"))
+}
+
+// newTestSessionService creates a session service with a global limit of maxConcurrent sessions, over an
+// in-memory cache without persistence
+func newTestSessionService(
+	tb testing.TB,
+	maxConcurrent int,
+) (*SessionService, interfaces.SessionCacheRepository) {
+	tb.Helper()
+
+	cfg := &config.Config{Session: config.SessionConfig{
+		Enabled:       true,
+		MaxConcurrent: maxConcurrent,
+		MaxPerToken:   maxConcurrent,
+		Scope:         config.SessionScopeGlobal,
+		SessionTTL:    5 * time.Minute,
+	}}
+	logger := quietLogger(tb)
+	cache := repositories.NewMemorySessionRepository(0, logger)
+	history := NewSessionHistoryService(nil, cfg.Session, logger)
+	return NewSessionService(cache, nil, history, cfg, logger).(*SessionService), cache
+}
+
+// clientRequest is a proxied request from remoteAddr with the given User-Agent
+func clientRequest(remoteAddr, userAgent string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/v1/messages", nil)
+	req.RemoteAddr = remoteAddr
+	req.Header.Set("User-Agent", userAgent)
+	return req
+}
+
+func TestCreateSessionSeparatesClientsBehindNAT(t *testing.T) {
+	svc, _ := newTestSessionService(t, 100)
+	ctx := context.Background()
+	alice := &entities.Token{ID: "tok_alice", Role: entities.TokenRoleUser}
+	bob := &entities.Token{ID: "tok_bob", Role: entities.TokenRoleUser}
+
+	create := func(token *entities.Token, remoteAddr, userAgent string) string {
+		t.Helper()
+		session, err := svc.CreateSession(ctx, token, clientRequest(remoteAddr, userAgent))
+		if err != nil {
+			t.Fatalf("CreateSession(%s, %s, %s) error = %v", token.ID, remoteAddr, userAgent, err)
+		}
+		return session.ID
+	}
+
+	// Two users of the same office NAT address and client version
+	aliceSession := create(alice, "203.0.113.7:50001", "claude-cli/1.0.0")
+	bobSession := create(bob, "203.0.113.7:50002", "claude-cli/1.0.0")
+	if aliceSession == bobSession {
+		t.Fatal("two tokens behind one NAT address share a session")
+	}
+
+	tests := []struct {
+		name       string
+		token      *entities.Token
+		remoteAddr string
+		userAgent  string
+		wantReuse  string // Session expected to be reused; empty for a new one
+	}{
+		{"new connection port", alice, "203.0.113.7:61000", "claude-cli/1.0.0", aliceSession},
+		{"User-Agent case", alice, "203.0.113.7:61001", "Claude-CLI/1.0.0", aliceSession},
+		{"other token", bob, "203.0.113.7:61002", "claude-cli/1.0.0", bobSession},
+		{"other client version", alice, "203.0.113.7:61003", "claude-cli/1.1.0", ""},
+		{"other address", alice, "198.51.100.4:61004", "claude-cli/1.0.0", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := create(tt.token, tt.remoteAddr, tt.userAgent)
+			switch {
+			case tt.wantReuse != "" && got != tt.wantReuse:
+				t.Errorf("session = %s, want %s reused", got, tt.wantReuse)
+			case tt.wantReuse == "" && (got == aliceSession || got == bobSession):
+				t.Errorf("session %s reused, want a new one", got)
+			}
+		})
+	}
+}
+
+func TestCreateSessionDoesNotReuseRevokedSessions(t *testing.T) {
+	svc, _ := newTestSessionService(t, 100)
+	ctx := context.Background()
+	token := &entities.Token{ID: "tok_alice", Role: entities.TokenRoleUser}
+
+	first, err := svc.CreateSession(ctx, token, clientRequest("203.0.113.7:50001", "claude-cli/1.0.0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.RevokeSession(ctx, first.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := svc.CreateSession(ctx, token, clientRequest("203.0.113.7:50002", "claude-cli/1.0.0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.ID == first.ID {
+		t.Error("a revoked session was reused")
+	}
+}
+
+func TestCreateSessionLimitsByScope(t *testing.T) {
+	const (
+		maxConcurrent = 3
+		maxPerToken   = 2
+	)
+	tests := []struct {
+		name        string
+		scope       string
+		maxSessions int      // Alice's max_sessions override (0 uses max_per_token)
+		alice, bob  int      // Live sessions each token holds already
+		wantMessage string   // Message of the 429; empty when the session is created
+		wantDetails []string // "key: value" entries of the 429 details
+	}{
+		{
+			name: "token scope, token at its limit with global capacity left", scope: config.SessionScopeToken,
+			alice: 2, wantMessage: "per-token session limit exceeded: 2/2 active sessions for this token",
+			wantDetails: []string{"limit: token", "token_active_count: 2", "token_max_sessions: 2"},
+		},
+		{
+			name: "token scope, global limit reached with token capacity left", scope: config.SessionScopeToken,
+			alice: 1, bob: 2,
+		},
+		{
+			name: "global scope, token at its limit with global capacity left", scope: config.SessionScopeGlobal,
+			alice: 2,
+		},
+		{
+			name: "global scope, global limit reached with token capacity left", scope: config.SessionScopeGlobal,
+			bob: 3, wantMessage: "concurrent session limit exceeded: 3/3 active sessions",
+			wantDetails: []string{"limit: global", "active_count: 3", "max_concurrent: 3"},
+		},
+		{
+			name: "both scopes, token at its limit with global capacity left", scope: config.SessionScopeBoth,
+			alice: 2, wantMessage: "per-token session limit exceeded: 2/2 active sessions for this token " +
+				"(global: 2/3)",
+			wantDetails: []string{"limit: token", "active_count: 2", "max_concurrent: 3", "token_active_count: 2",
+				"token_max_sessions: 2"},
+		},
+		{
+			name: "both scopes, global limit reached with token capacity left", scope: config.SessionScopeBoth,
+			alice: 1, bob: 2, wantMessage: "concurrent session limit exceeded: 3/3 active sessions (token: 1/2)",
+			wantDetails: []string{"limit: global", "active_count: 3", "max_concurrent: 3", "token_active_count: 1",
+				"token_max_sessions: 2"},
+		},
+		{
+			name: "both scopes, both limits reached", scope: config.SessionScopeBoth,
+			alice: 2, bob: 1, wantMessage: "per-token session limit exceeded: 2/2 active sessions for this token " +
+				"(global: 3/3)",
+			wantDetails: []string{"limit: token", "active_count: 3", "token_active_count: 2"},
+		},
+		{
+			name: "max_sessions raising the token limit", scope: config.SessionScopeToken, maxSessions: 3,
+			alice: 2,
+		},
+		{
+			name: "max_sessions lowering the token limit", scope: config.SessionScopeToken, maxSessions: 1,
+			alice: 1, wantMessage: "per-token session limit exceeded: 1/1 active sessions for this token",
+			wantDetails: []string{"limit: token", "token_active_count: 1", "token_max_sessions: 1"},
+		},
+		{
+			name: "max_sessions above the global limit", scope: config.SessionScopeBoth, maxSessions: 5,
+			alice: 3, wantMessage: "concurrent session limit exceeded: 3/3 active sessions (token: 3/5)",
+			wantDetails: []string{"limit: global", "token_max_sessions: 5"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, cache := newTestSessionService(t, maxConcurrent)
+			svc.maxPerToken = maxPerToken
+			svc.scope = tt.scope
+			ctx := context.Background()
+			alice := &entities.Token{ID: "tok_alice", Role: entities.TokenRoleUser, MaxSessions: tt.maxSessions}
+
+			now := time.Now()
+			for i := range tt.alice + tt.bob {
+				tokenID := "tok_alice"
+				if i >= tt.alice {
+					tokenID = "tok_bob"
+				}
+				err := cache.CreateSession(ctx, &entities.Session{
+					ID:         fmt.Sprintf("ses_%d", i),
+					TokenID:    tokenID,
+					UserAgent:  fmt.Sprintf("client %d", i),
+					IPAddress:  "203.0.113.7",
+					CreatedAt:  now,
+					LastSeenAt: now,
+					ExpiresAt:  now.Add(time.Minute),
+					IsActive:   true,
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			session, err := svc.CreateSession(ctx, alice, clientRequest("203.0.113.7:50001", "new client"))
+			if tt.wantMessage == "" {
+				if err != nil || session == nil {
+					t.Fatalf("CreateSession() = %v, %v; want a new session", session, err)
+				}
+				return
+			}
+
+			appErr, ok := err.(apperrors.AppError)
+			if !ok {
+				t.Fatalf("CreateSession() = %v, %v; want a rate limit error", session, err)
+			}
+			if appErr.StatusCode() != http.StatusTooManyRequests || appErr.ErrorCode() != "RATE_LIMIT_EXCEEDED" {
+				t.Errorf("error = %d %s, want 429 RATE_LIMIT_EXCEEDED", appErr.StatusCode(), appErr.ErrorCode())
+			}
+			if appErr.Message() != tt.wantMessage {
+				t.Errorf("message = %q, want %q", appErr.Message(), tt.wantMessage)
+			}
+			for _, detail := range tt.wantDetails {
+				if !strings.Contains(appErr.Details(), detail+",") {
+					t.Errorf("details = %q, want %q", appErr.Details(), detail)
+				}
+			}
+			if apperrors.HeadersOf(appErr).Get("Retry-After") == "" {
+				t.Error("429 without Retry-After")
+			}
+		})
+	}
+}
+
+// TestSessionServiceStartupPrunesLargeFile starts the service on a sessions.json of 50k sessions, a quarter
+// of each kind: live, ended within retention, expired and revoked past retention
+func TestSessionServiceStartupPrunesLargeFile(t *testing.T) {
+	const (
+		count     = 50_000
+		retention = 24 * time.Hour
+	)
+	dir := t.TempDir()
+	now := time.Now()
+	sessions := make([]*dto.SessionPersistenceDTO, count)
+	for i := range sessions {
+		session := &entities.Session{
+			ID:         fmt.Sprintf("ses_%06d", i),
+			TokenID:    fmt.Sprintf("tok_%02d", i%10),
+			UserAgent:  "claude-cli/1.0.0",
+			IPAddress:  fmt.Sprintf("10.%d.%d.%d", i>>16&0xff, i>>8&0xff, i&0xff),
+			CreatedAt:  now.Add(-time.Hour),
+			LastSeenAt: now,
+			ExpiresAt:  now.Add(time.Hour),
+			IsActive:   true,
+		}
+		switch i % 4 {
+		case 1: // Expired within retention
+			session.ExpiresAt = now.Add(-time.Hour)
+		case 2: // Expired past retention
+			session.ExpiresAt = now.Add(-3 * retention)
+		case 3: // Revoked past retention, in another month than the expired ones
+			session.CreatedAt = now.Add(-45 * retention)
+			session.LastSeenAt = now.Add(-40 * retention)
+			session.IsActive = false
+		}
+		sessions[i] = dto.ToSessionPersistenceDTO(session)
+	}
+	data, err := json.Marshal(sessions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "sessions.json"), data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.Config{Session: config.SessionConfig{
+		Enabled:    true,
+		Scope:      config.SessionScopeGlobal,
+		SessionTTL: time.Hour,
+		Retention:  retention,
+		Archive:    true,
+	}}
+	logger := quietLogger(t)
+	persistence, err := repositories.NewJSONSessionRepository(dir, false, retention, true, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache := repositories.NewMemorySessionRepository(0, logger)
+	history := NewSessionHistoryService(nil, cfg.Session, logger)
+
+	started := time.Now()
+	NewSessionService(cache, persistence, history, cfg, logger)
+	if elapsed := time.Since(started); elapsed > time.Second && !raceEnabled {
+		t.Errorf("startup took %v, want under 1s", elapsed)
+	}
+
+	// The cache and the file only hold sessions within retention
+	cached, err := cache.ListAllSessions(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	active, err := cache.CountActiveSessions(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cached) != count/2 || active != count/4 {
+		t.Errorf("cache holds %d sessions (%d active), want %d (%d)", len(cached), active, count/2, count/4)
+	}
+	kept, err := persistence.LoadAll(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(kept) != count/2 {
+		t.Errorf("sessions.json holds %d sessions, want %d", len(kept), count/2)
+	}
+
+	// Pruned sessions are archived once, in the month they ended
+	archives, err := filepath.Glob(filepath.Join(dir, "sessions-archive-*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	archived := 0
+	for _, archive := range archives {
+		data, err := os.ReadFile(archive)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var records []*dto.SessionPersistenceDTO
+		if err := json.Unmarshal(data, &records); err != nil {
+			t.Fatalf("%s: %v", filepath.Base(archive), err)
+		}
+		for _, record := range records {
+			month := dto.FromSessionPersistenceDTO(record).EndedAt().UTC().Format("2006-01")
+			if filepath.Base(archive) != "sessions-archive-"+month+".json" {
+				t.Fatalf("%s ended in %s, archived in %s", record.ID, month, filepath.Base(archive))
+			}
+		}
+		archived += len(records)
+	}
+	if archived != count/2 {
+		t.Errorf("%d sessions archived, want %d", archived, count/2)
+	}
+}
+
+// BenchmarkFindExistingSession looks up one client's session among a shared token's sessions: the time per
+// lookup stays flat as the session count grows
+func BenchmarkFindExistingSession(b *testing.B) {
+	for _, count := range []int{1_000, 10_000, 50_000} {
+		b.Run(fmt.Sprintf("sessions=%d", count), func(b *testing.B) {
+			svc, cache := newTestSessionService(b, count)
+			ctx := context.Background()
+			now := time.Now()
+			for i := range count {
+				err := cache.CreateSession(ctx, &entities.Session{
+					ID:         fmt.Sprintf("ses_%06d", i),
+					TokenID:    "tok_shared",
+					UserAgent:  "claude-cli/1.0.0",
+					IPAddress:  fmt.Sprintf("10.%d.%d.%d", i>>16&0xff, i>>8&0xff, i&0xff),
+					CreatedAt:  now,
+					LastSeenAt: now,
+					ExpiresAt:  now.Add(time.Hour),
+					IsActive:   true,
+				})
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+
+			i := 0
+			for b.Loop() {
+				ip := fmt.Sprintf("10.%d.%d.%d", i>>16&0xff, i>>8&0xff, i&0xff)
+				if svc.findExistingSession(ctx, "tok_shared", ip, "claude-cli/1.0.0") == nil {
+					b.Fatalf("session of %s not found", ip)
+				}
+				i = (i + 7919) % count
+			}
+		})
+	}
+}
@@ -0,0 +1,327 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"claude-proxy/config"
+	"claude-proxy/modules/auth/application/dto"
+	"claude-proxy/modules/auth/domain/entities"
+	"claude-proxy/modules/auth/domain/interfaces"
+	"claude-proxy/modules/auth/infrastructure/clients"
+
+	sctx "github.com/phathdt/service-context"
+)
+
+// mirroredCounts is the number of records of each kind a pull mirrored
+type mirroredCounts struct {
+	accounts, tokens, sessions int
+}
+
+// StandbyService pulls the primary's state bundle every standby.interval and mirrors its accounts, tokens and
+// sessions into the local caches, which the regular syncs then write to the data folder
+// The primary wins every conflict: records are replaced as pulled and records it no longer holds are removed.
+// Pulls send the ETag of the last applied bundle, so an unchanged primary answers 304 without building one.
+type StandbyService struct {
+	client     *clients.PrimaryClient
+	accountSvc interfaces.AccountService
+	tokenSvc   interfaces.TokenService
+	sessionSvc interfaces.SessionService
+	passphrase string
+	interval   time.Duration
+	timeout    time.Duration
+
+	status    entities.StandbyStatus
+	onPromote []func()
+	mu        sync.Mutex
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+	logger sctx.Logger
+}
+
+// NewStandbyService creates the standby of the primary configured by standby.*
+// When standby is disabled, the instance is active from the start and the service does nothing.
+func NewStandbyService(
+	cfg config.StandbyConfig,
+	accountSvc interfaces.AccountService,
+	tokenSvc interfaces.TokenService,
+	sessionSvc interfaces.SessionService,
+	appLogger sctx.Logger,
+) interfaces.StandbyService {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &StandbyService{
+		client:     clients.NewPrimaryClient(cfg.PrimaryURL, cfg.APIKey, cfg.Timeout),
+		accountSvc: accountSvc,
+		tokenSvc:   tokenSvc,
+		sessionSvc: sessionSvc,
+		passphrase: cfg.Passphrase,
+		interval:   cfg.Interval,
+		timeout:    cfg.Timeout,
+		status: entities.StandbyStatus{
+			Enabled:    cfg.Enabled,
+			Standby:    cfg.Enabled,
+			PrimaryURL: cfg.PrimaryURL,
+		},
+		ctx:    ctx,
+		cancel: cancel,
+		logger: appLogger.Withs(sctx.Fields{"component": "standby-service"}),
+	}
+}
+
+// Standby returns true while the instance follows its primary and refuses proxy traffic
+func (s *StandbyService) Standby() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.status.Standby
+}
+
+// Status returns the standby state and the outcome of the last pulls
+func (s *StandbyService) Status() entities.StandbyStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.status
+}
+
+// OnPromote registers fn to run once the instance is promoted, or runs it now when it is not a standby
+func (s *StandbyService) OnPromote(fn func()) {
+	s.mu.Lock()
+	if s.status.Standby {
+		s.onPromote = append(s.onPromote, fn)
+		s.mu.Unlock()
+		return
+	}
+	s.mu.Unlock()
+	fn()
+}
+
+// Promote stops the pull loop (waiting for a pull in progress), then switches the instance to normal operation
+// No final pull is attempted: the primary is usually promoted away from because it is unreachable.
+func (s *StandbyService) Promote(ctx context.Context) (entities.StandbyStatus, error) {
+	s.mu.Lock()
+	if !s.status.Standby {
+		s.mu.Unlock()
+		return entities.StandbyStatus{}, entities.ErrNotStandby
+	}
+	s.mu.Unlock()
+
+	s.Stop()
+
+	s.mu.Lock()
+	if !s.status.Standby {
+		s.mu.Unlock()
+		return entities.StandbyStatus{}, entities.ErrNotStandby // Promoted concurrently
+	}
+	now := time.Now()
+	s.status.Standby = false
+	s.status.PromotedAt = &now
+	status := s.status
+	onPromote := s.onPromote
+	s.onPromote = nil
+	s.mu.Unlock()
+
+	// Write the mirrored state now rather than at the next periodic sync
+	if err := s.syncAll(ctx); err != nil {
+		s.logger.Withs(sctx.Fields{"error": err.Error()}).Warn("Failed to sync mirrored state on promotion")
+	}
+	for _, fn := range onPromote {
+		fn()
+	}
+
+	fields := sctx.Fields{"primary_url": status.PrimaryURL}
+	if status.LastSyncAt != nil {
+		fields["last_sync_at"] = status.LastSyncAt.Format(time.RFC3339)
+	}
+	s.logger.Withs(fields).Warn("Standby promoted to active, serving proxy traffic")
+	return status, nil
+}
+
+// Start launches the pull loop, which pulls at once and then every interval
+func (s *StandbyService) Start() {
+	if !s.Standby() {
+		return
+	}
+	s.wg.Add(1)
+	go s.run()
+	s.logger.Withs(sctx.Fields{
+		"primary_url": s.status.PrimaryURL,
+		"interval":    s.interval.String(),
+	}).Info("Standby started, mirroring the primary")
+}
+
+// Stop cancels the pull loop and waits for it
+func (s *StandbyService) Stop() {
+	s.cancel()
+	s.wg.Wait()
+}
+
+// run pulls from the primary until stopped
+func (s *StandbyService) run() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		s.pull()
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// pull fetches the primary's bundle unless unchanged since the last one applied, and mirrors it
+func (s *StandbyService) pull() {
+	ctx, cancel := context.WithTimeout(s.ctx, s.timeout)
+	defer cancel()
+
+	s.mu.Lock()
+	etag := s.status.ETag
+	s.mu.Unlock()
+
+	start := time.Now()
+	counts, newETag, err := s.pullOnce(ctx, etag)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status.LastAttemptAt = &start
+	if err != nil {
+		if s.ctx.Err() != nil {
+			return // Stopped or promoted during the pull
+		}
+		if s.status.LastError == "" {
+			s.logger.Withs(sctx.Fields{"error": err.Error()}).Warn("Failed to pull state from the primary")
+		}
+		s.status.LastError = err.Error()
+		return
+	}
+
+	if s.status.LastError != "" {
+		s.logger.Info("Pulling state from the primary recovered")
+	}
+	s.status.LastError = ""
+	s.status.LastSyncAt = &start
+	if counts == nil {
+		return // Unchanged
+	}
+	s.status.LastChangeAt = &start
+	s.status.ETag = newETag
+	s.status.Accounts = counts.accounts
+	s.status.Tokens = counts.tokens
+	s.status.Sessions = counts.sessions
+}
+
+// pullOnce fetches and applies one bundle, returning the number of records mirrored (nil if unchanged) and
+// the ETag to send next; records the caches refused clear it, so the next pull applies the bundle again
+func (s *StandbyService) pullOnce(ctx context.Context, etag string) (*mirroredCounts, string, error) {
+	bundle, newETag, err := s.client.ExportBundle(ctx, s.passphrase, etag)
+	if err != nil {
+		return nil, "", err
+	}
+	if bundle == nil {
+		return nil, etag, nil
+	}
+
+	_, files, err := OpenStateBundle(bundle, s.passphrase)
+	if err != nil {
+		return nil, "", err
+	}
+
+	counts := &mirroredCounts{}
+	results := make(map[string]*entities.MirrorResult)
+	if data, ok := files["accounts.json"]; ok {
+		accounts, err := parseMirroredAccounts(data)
+		if err != nil {
+			return nil, "", err
+		}
+		if results["accounts"], err = s.accountSvc.Mirror(ctx, accounts); err != nil {
+			return nil, "", fmt.Errorf("failed to mirror accounts: %w", err)
+		}
+		counts.accounts = len(accounts)
+	}
+	if data, ok := files["tokens.json"]; ok {
+		var dtos []*dto.TokenPersistenceDTO
+		if err := unmarshalMirrored(data, &dtos); err != nil {
+			return nil, "", fmt.Errorf("invalid tokens.json from the primary: %w", err)
+		}
+		tokens := make([]*entities.Token, 0, len(dtos))
+		for _, d := range dtos {
+			tokens = append(tokens, dto.FromTokenPersistenceDTO(d))
+		}
+		if results["tokens"], err = s.tokenSvc.Mirror(ctx, tokens); err != nil {
+			return nil, "", fmt.Errorf("failed to mirror tokens: %w", err)
+		}
+		counts.tokens = len(tokens)
+	}
+	if data, ok := files["sessions.json"]; ok {
+		var dtos []*dto.SessionPersistenceDTO
+		if err := unmarshalMirrored(data, &dtos); err != nil {
+			return nil, "", fmt.Errorf("invalid sessions.json from the primary: %w", err)
+		}
+		sessions := make([]*entities.Session, 0, len(dtos))
+		for _, d := range dtos {
+			sessions = append(sessions, dto.FromSessionPersistenceDTO(d))
+		}
+		if results["sessions"], err = s.sessionSvc.Mirror(ctx, sessions); err != nil {
+			return nil, "", fmt.Errorf("failed to mirror sessions: %w", err)
+		}
+		counts.sessions = len(sessions)
+	}
+
+	fields := sctx.Fields{"etag": newETag}
+	skipped := false
+	for kind, result := range results {
+		fields[kind] = fmt.Sprintf("+%d ~%d -%d", result.Added, result.Updated, result.Removed)
+		if result.Skipped > 0 {
+			fields[kind+"_skipped"] = result.Skipped
+			skipped = true
+		}
+	}
+	s.logger.Withs(fields).Info("State mirrored from the primary")
+	if skipped {
+		newETag = ""
+	}
+	return counts, newETag, nil
+}
+
+// syncAll writes the mirrored caches to the data files
+func (s *StandbyService) syncAll(ctx context.Context) error {
+	if err := s.accountSvc.Sync(ctx); err != nil {
+		return fmt.Errorf("failed to sync accounts: %w", err)
+	}
+	if err := s.tokenSvc.Sync(ctx); err != nil {
+		return fmt.Errorf("failed to sync tokens: %w", err)
+	}
+	if err := s.sessionSvc.Sync(ctx); err != nil {
+		return fmt.Errorf("failed to sync sessions: %w", err)
+	}
+	return nil
+}
+
+// parseMirroredAccounts parses the accounts.json of a bundle, already upgraded to the header layout
+func parseMirroredAccounts(data []byte) ([]*entities.Account, error) {
+	var file dto.AccountsFileDTO
+	if err := unmarshalMirrored(data, &file); err != nil {
+		return nil, fmt.Errorf("invalid accounts.json from the primary: %w", err)
+	}
+	accounts := make([]*entities.Account, 0, len(file.Accounts))
+	for _, d := range file.Accounts {
+		accounts = append(accounts, dto.FromAccountPersistenceDTO(d))
+	}
+	return accounts, nil
+}
+
+// unmarshalMirrored parses a data file of a bundle, an empty file holding no records
+func unmarshalMirrored(data []byte, v any) error {
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil
+	}
+	return json.Unmarshal(data, v)
+}
@@ -0,0 +1,329 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"claude-proxy/config"
+	"claude-proxy/modules/auth/application/dto"
+	"claude-proxy/modules/auth/domain/entities"
+	"claude-proxy/modules/auth/domain/interfaces"
+	"claude-proxy/modules/auth/infrastructure/clients"
+
+	sctx "github.com/phathdt/service-context"
+)
+
+const (
+	testStandbyAPIKey     = "primary-admin-key"
+	testStandbyPassphrase = "standby-passphrase"
+)
+
+// fileSource is a SnapshotSource reading a data file of a test directory
+type fileSource string
+
+func (f fileSource) WithWriteLock(fn func(dataFile string) error) error { return fn(string(f)) }
+
+// mirrorAccounts, mirrorTokens and mirrorSessions record what the standby mirrors and syncs
+type mirrorAccounts struct {
+	interfaces.AccountService
+	mirrored []*entities.Account
+	syncs    int
+}
+
+func (m *mirrorAccounts) Mirror(_ context.Context, items []*entities.Account) (*entities.MirrorResult, error) {
+	m.mirrored = items
+	return &entities.MirrorResult{Added: len(items)}, nil
+}
+
+func (m *mirrorAccounts) Sync(context.Context) error {
+	m.syncs++
+	return nil
+}
+
+type mirrorTokens struct {
+	interfaces.TokenService
+	mirrored []*entities.Token
+	skip     int
+	syncs    int
+}
+
+func (m *mirrorTokens) Mirror(_ context.Context, items []*entities.Token) (*entities.MirrorResult, error) {
+	m.mirrored = items
+	return &entities.MirrorResult{Added: len(items) - m.skip, Skipped: m.skip}, nil
+}
+
+func (m *mirrorTokens) Sync(context.Context) error {
+	m.syncs++
+	return nil
+}
+
+type mirrorSessions struct {
+	interfaces.SessionService
+	mirrored []*entities.Session
+	syncs    int
+}
+
+func (m *mirrorSessions) Mirror(_ context.Context, items []*entities.Session) (*entities.MirrorResult, error) {
+	m.mirrored = items
+	return &entities.MirrorResult{Added: len(items)}, nil
+}
+
+func (m *mirrorSessions) Sync(context.Context) error {
+	m.syncs++
+	return nil
+}
+
+// fakePrimary serves one state bundle from its export endpoint, honoring If-None-Match
+type fakePrimary struct {
+	server   *httptest.Server
+	bundle   []byte
+	etag     string
+	requests atomic.Int32
+	exports  atomic.Int32
+}
+
+func newFakePrimary(t *testing.T) *fakePrimary {
+	t.Helper()
+
+	dir := t.TempDir()
+	now := time.Now().UTC().Format(time.RFC3339)
+	files := map[string]any{
+		"accounts.json": dto.AccountsFileDTO{
+			SchemaVersion: dto.AccountsFileSchemaVersion,
+			Accounts: []*dto.AccountPersistenceDTO{
+				{ID: "acc_1", Name: "primary", Status: "active", ExpiresAt: now, CreatedAt: now, UpdatedAt: now},
+			},
+		},
+		"tokens.json": []*dto.TokenPersistenceDTO{
+			{ID: "tok_1", Name: "one", KeyHash: "hash1", Status: "active", Role: "user", CreatedAt: now, UpdatedAt: now},
+			{ID: "tok_2", Name: "two", KeyHash: "hash2", Status: "active", Role: "user", CreatedAt: now, UpdatedAt: now},
+		},
+		"sessions.json": []*dto.SessionPersistenceDTO{
+			{ID: "ses_1", TokenID: "tok_1", CreatedAt: now, LastSeenAt: now, ExpiresAt: now, IsActive: true},
+		},
+	}
+	var sources []interfaces.SnapshotSource
+	for name, content := range files {
+		data, err := json.Marshal(content)
+		if err != nil {
+			t.Fatal(err)
+		}
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, data, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		sources = append(sources, fileSource(path))
+	}
+
+	bundle, manifest, err := ExportStateBundle(sources, testStandbyPassphrase, "")
+	if err != nil {
+		t.Fatalf("ExportStateBundle() error = %v", err)
+	}
+
+	p := &fakePrimary{bundle: bundle, etag: manifest.ETag()}
+	p.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p.requests.Add(1)
+		if r.Method != http.MethodPost || r.URL.Path != "/api/admin/export" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Header.Get("X-API-Key") != testStandbyAPIKey {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.Header.Get("If-None-Match") == strconv.Quote(p.etag) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		p.exports.Add(1)
+		w.Header().Set("ETag", strconv.Quote(p.etag))
+		_, _ = w.Write(p.bundle)
+	}))
+	t.Cleanup(p.server.Close)
+	return p
+}
+
+// newTestStandby creates a standby of primaryURL mirroring into recording services
+func newTestStandby(
+	primaryURL string,
+) (*StandbyService, *mirrorAccounts, *mirrorTokens, *mirrorSessions) {
+	accounts, tokens, sessions := &mirrorAccounts{}, &mirrorTokens{}, &mirrorSessions{}
+	cfg := config.StandbyConfig{
+		Enabled:    true,
+		PrimaryURL: primaryURL,
+		APIKey:     testStandbyAPIKey,
+		Passphrase: testStandbyPassphrase,
+		Interval:   time.Hour,
+		Timeout:    30 * time.Second,
+	}
+	logger := sctx.GlobalLogger().GetLogger("test")
+	svc := NewStandbyService(cfg, accounts, tokens, sessions, logger).(*StandbyService)
+	return svc, accounts, tokens, sessions
+}
+
+func TestStandbyPullOnceMirrorsBundle(t *testing.T) {
+	primary := newFakePrimary(t)
+	svc, accounts, tokens, sessions := newTestStandby(primary.server.URL)
+
+	counts, etag, err := svc.pullOnce(context.Background(), "")
+	if err != nil {
+		t.Fatalf("pullOnce() error = %v", err)
+	}
+	if counts == nil || counts.accounts != 1 || counts.tokens != 2 || counts.sessions != 1 {
+		t.Fatalf("pullOnce() counts = %+v, want 1 account, 2 tokens, 1 session", counts)
+	}
+	if etag != primary.etag {
+		t.Errorf("pullOnce() etag = %q, want %q", etag, primary.etag)
+	}
+	if len(accounts.mirrored) != 1 || accounts.mirrored[0].ID != "acc_1" {
+		t.Errorf("mirrored accounts = %v, want acc_1", accounts.mirrored)
+	}
+	if len(tokens.mirrored) != 2 || len(sessions.mirrored) != 1 {
+		t.Errorf("mirrored %d tokens and %d sessions, want 2 and 1", len(tokens.mirrored), len(sessions.mirrored))
+	}
+}
+
+func TestStandbyPullOnceNotModified(t *testing.T) {
+	primary := newFakePrimary(t)
+	svc, accounts, _, _ := newTestStandby(primary.server.URL)
+
+	counts, etag, err := svc.pullOnce(context.Background(), primary.etag)
+	if err != nil {
+		t.Fatalf("pullOnce() error = %v", err)
+	}
+	if counts != nil {
+		t.Errorf("pullOnce() counts = %+v, want nil for an unchanged primary", counts)
+	}
+	if etag != primary.etag {
+		t.Errorf("pullOnce() etag = %q, want the one sent (%q)", etag, primary.etag)
+	}
+	if primary.exports.Load() != 0 {
+		t.Errorf("primary built %d bundles, want 0", primary.exports.Load())
+	}
+	if accounts.mirrored != nil {
+		t.Error("an unchanged bundle was mirrored")
+	}
+}
+
+func TestStandbyPullOnceSkippedRecordsClearETag(t *testing.T) {
+	primary := newFakePrimary(t)
+	svc, _, tokens, _ := newTestStandby(primary.server.URL)
+	tokens.skip = 1
+
+	counts, etag, err := svc.pullOnce(context.Background(), "")
+	if err != nil {
+		t.Fatalf("pullOnce() error = %v", err)
+	}
+	if counts == nil {
+		t.Fatal("pullOnce() counts = nil, want the mirrored records")
+	}
+	if etag != "" {
+		t.Errorf("pullOnce() etag = %q, want empty so the next pull applies the bundle again", etag)
+	}
+}
+
+func TestStandbyPullOnceRejectsPrimaryErrors(t *testing.T) {
+	primary := newFakePrimary(t)
+
+	svc, _, _, _ := newTestStandby(primary.server.URL)
+	svc.client = clients.NewPrimaryClient(primary.server.URL, "wrong-key", time.Second)
+	if _, _, err := svc.pullOnce(context.Background(), ""); err == nil {
+		t.Error("pullOnce() with the wrong API key succeeded")
+	}
+
+	svc, accounts, _, _ := newTestStandby(primary.server.URL)
+	svc.passphrase = "wrong-passphrase"
+	if _, _, err := svc.pullOnce(context.Background(), ""); err == nil {
+		t.Error("pullOnce() with the wrong passphrase succeeded")
+	}
+	if accounts.mirrored != nil {
+		t.Error("a bundle that failed to open was mirrored")
+	}
+}
+
+func TestStandbyPullRecordsStatus(t *testing.T) {
+	primary := newFakePrimary(t)
+	svc, _, _, _ := newTestStandby(primary.server.URL)
+
+	svc.pull()
+	status := svc.Status()
+	if status.LastError != "" || status.LastSyncAt == nil || status.LastChangeAt == nil {
+		t.Fatalf("status after first pull = %+v, want a successful change", status)
+	}
+	if status.ETag != primary.etag || status.Tokens != 2 {
+		t.Errorf("status = %+v, want etag %q and 2 tokens", status, primary.etag)
+	}
+	firstChange := *status.LastChangeAt
+
+	// The second pull sends the ETag and gets a 304
+	svc.pull()
+	status = svc.Status()
+	if primary.requests.Load() != 2 || primary.exports.Load() != 1 {
+		t.Errorf("primary saw %d requests and built %d bundles, want 2 and 1",
+			primary.requests.Load(), primary.exports.Load())
+	}
+	if !status.LastChangeAt.Equal(firstChange) {
+		t.Error("an unchanged pull moved last_change_at")
+	}
+
+	primary.server.Close()
+	svc.pull()
+	if status = svc.Status(); status.LastError == "" {
+		t.Error("a pull from an unreachable primary left last_error empty")
+	}
+}
+
+func TestStandbyPromote(t *testing.T) {
+	primary := newFakePrimary(t)
+	svc, accounts, tokens, sessions := newTestStandby(primary.server.URL)
+	svc.Start()
+
+	var promoted atomic.Int32
+	svc.OnPromote(func() { promoted.Add(1) })
+	if promoted.Load() != 0 {
+		t.Fatal("OnPromote ran its function on a standby")
+	}
+	if !svc.Standby() {
+		t.Fatal("Standby() = false before promotion")
+	}
+
+	status, err := svc.Promote(context.Background())
+	if err != nil {
+		t.Fatalf("Promote() error = %v", err)
+	}
+	if svc.Standby() || status.Standby || status.PromotedAt == nil {
+		t.Errorf("after Promote(), standby = %t, status = %+v", svc.Standby(), status)
+	}
+	if promoted.Load() != 1 {
+		t.Errorf("OnPromote function ran %d times, want 1", promoted.Load())
+	}
+	if accounts.syncs != 1 || tokens.syncs != 1 || sessions.syncs != 1 {
+		t.Errorf("syncs = %d/%d/%d, want each service synced once", accounts.syncs, tokens.syncs, sessions.syncs)
+	}
+
+	// Functions registered after promotion run at once
+	svc.OnPromote(func() { promoted.Add(1) })
+	if promoted.Load() != 2 {
+		t.Error("OnPromote did not run its function on an active instance")
+	}
+
+	if _, err := svc.Promote(context.Background()); !errors.Is(err, entities.ErrNotStandby) {
+		t.Errorf("second Promote() error = %v, want ErrNotStandby", err)
+	}
+
+	// The pull loop is stopped: no pull reaches the primary any more
+	requests := primary.requests.Load()
+	time.Sleep(50 * time.Millisecond)
+	if primary.requests.Load() != requests {
+		t.Error("the standby kept pulling after promotion")
+	}
+}
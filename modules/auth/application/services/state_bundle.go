@@ -0,0 +1,437 @@
+package services
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"claude-proxy/modules/auth/application/dto"
+	"claude-proxy/modules/auth/domain/entities"
+	"claude-proxy/modules/auth/domain/interfaces"
+	"claude-proxy/pkg/atomicfile"
+	"claude-proxy/pkg/version"
+)
+
+const (
+	stateBundleMagic         = "CPBUNDL1" // Identifies the encrypted container format
+	stateBundleFormatVersion = 1          // Version of the manifest and archive layout
+	stateBundleManifestName  = "manifest.json"
+	stateBundleSaltSize      = 16
+	stateBundleKDFIterations = 600_000 // PBKDF2-SHA256 iterations deriving the AES-256 key
+
+	// minBundlePassphraseLength is the shortest passphrase accepted for state bundles (also in the export request)
+	minBundlePassphraseLength = 8
+)
+
+// stateBundleSchemaVersions is the current schema version of each data file a bundle may contain
+// Bump a file's version together with a stateBundleUpgrades step when its JSON layout changes
+var stateBundleSchemaVersions = map[string]int{
+	"accounts.json":   dto.AccountsFileSchemaVersion,
+	"tokens.json":     dto.TokensFileSchemaVersion,
+	"sessions.json":   1,
+	"stats.json":      1,
+	"admin_keys.json": 1,
+	"invites.json":    1,
+}
+
+// stateBundleUpgrades converts a data file from schema version v (map key) to v+1, per file name
+var stateBundleUpgrades = map[string]map[int]func([]byte) ([]byte, error){
+	"accounts.json": {1: addAccountsFileHeader},
+	"tokens.json":   {1: hashTokenKeys},
+}
+
+// addAccountsFileHeader upgrades accounts.json from a bare array (schema 1) to the file with a schema header
+func addAccountsFileHeader(data []byte) ([]byte, error) {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) == 0 || trimmed[0] != '[' {
+		return data, nil // Already carries a header, or the old CLI object the loader still reads
+	}
+
+	var accounts []*dto.AccountPersistenceDTO
+	if err := json.Unmarshal(trimmed, &accounts); err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(dto.AccountsFileDTO{
+		SchemaVersion: 2,
+		Accounts:      accounts,
+	}, "", "  ")
+}
+
+// hashTokenKeys upgrades tokens.json from cleartext keys (schema 1) to key hashes and display prefixes
+func hashTokenKeys(data []byte) ([]byte, error) {
+	if len(bytes.TrimSpace(data)) == 0 {
+		return data, nil
+	}
+
+	var tokens []*dto.TokenPersistenceDTO
+	if err := json.Unmarshal(data, &tokens); err != nil {
+		return nil, err
+	}
+	for _, token := range tokens {
+		token.HashLegacyKey()
+	}
+	return json.MarshalIndent(tokens, "", "  ")
+}
+
+// ExportStateBundle snapshots the data files of sources and returns them as an encrypted bundle
+// The sources' write locks are held together while reading, as for backups. When ifNoneMatch is the ETag of
+// the snapshot's manifest, only the manifest is returned (nil bundle) and the costly encryption is skipped.
+func ExportStateBundle(
+	sources []interfaces.SnapshotSource,
+	passphrase string,
+	ifNoneMatch string,
+) ([]byte, *entities.StateBundleManifest, error) {
+	if err := checkBundlePassphrase(passphrase); err != nil {
+		return nil, nil, err
+	}
+
+	files, err := snapshotFiles(sources, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to snapshot data files: %w", err)
+	}
+	if len(files) == 0 {
+		return nil, nil, fmt.Errorf("the data folder holds no data files to export")
+	}
+
+	manifest := &entities.StateBundleManifest{
+		FormatVersion: stateBundleFormatVersion,
+		AppVersion:    version.Version,
+		CreatedAt:     time.Now().UTC(),
+		Files:         make(map[string]entities.StateBundleFile, len(files)),
+	}
+	for _, file := range files {
+		sum := sha256.Sum256(file.data)
+		manifest.Files[file.name] = entities.StateBundleFile{
+			SchemaVersion: stateBundleSchemaVersions[file.name],
+			Size:          int64(len(file.data)),
+			SHA256:        hex.EncodeToString(sum[:]),
+		}
+	}
+	if ifNoneMatch != "" && ifNoneMatch == manifest.ETag() {
+		return nil, manifest, nil
+	}
+
+	manifestData, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to marshal bundle manifest: %w", err)
+	}
+	archive, err := writeBundleArchive(
+		append([]snapshotFile{{name: stateBundleManifestName, data: manifestData}}, files...),
+		manifest.CreatedAt,
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	bundle, err := encryptBundle(archive, passphrase)
+	if err != nil {
+		return nil, nil, err
+	}
+	return bundle, manifest, nil
+}
+
+// ImportStateBundle decrypts and validates a bundle, upgrades older data file schemas, and writes the
+// files through the repositories of sources (other repositories' files are left alone)
+// Unless force is set, the import is refused when any target file already holds data; with force,
+// replaced files are kept alongside as <name>.pre-import. The server must not be running.
+func ImportStateBundle(
+	bundle []byte,
+	passphrase string,
+	sources []interfaces.SnapshotSource,
+	force, fsync bool,
+) (*entities.StateBundleManifest, []string, error) {
+	manifest, files, err := OpenStateBundle(bundle, passphrase)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Resolve each file's target through its repository, checking them all before writing any
+	targets := make(map[string]string)
+	for _, source := range sources {
+		err := source.WithWriteLock(func(dataFile string) error {
+			name := filepath.Base(dataFile)
+			if _, ok := files[name]; !ok {
+				return nil
+			}
+			if !force && dataFileHasContent(dataFile) {
+				return fmt.Errorf("%s already holds data; import into an empty data folder or pass --force", name)
+			}
+			targets[name] = dataFile
+			return nil
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	imported := make([]string, 0, len(targets))
+	for _, source := range sources {
+		err := source.WithWriteLock(func(dataFile string) error {
+			name := filepath.Base(dataFile)
+			if targets[name] != dataFile {
+				return nil
+			}
+
+			// Keep the replaced file so a bad import can be undone by hand
+			if _, err := os.Stat(dataFile); err == nil {
+				if err := os.Rename(dataFile, dataFile+".pre-import"); err != nil {
+					return fmt.Errorf("failed to keep existing %s: %w", name, err)
+				}
+			}
+			if err := atomicfile.Write(dataFile, files[name], 0o600, fsync); err != nil {
+				return fmt.Errorf("failed to import %s: %w", name, err)
+			}
+			imported = append(imported, name)
+			return nil
+		})
+		if err != nil {
+			return manifest, imported, err
+		}
+	}
+
+	sort.Strings(imported)
+	return manifest, imported, nil
+}
+
+// OpenStateBundle decrypts and validates a bundle and returns its data files, upgraded to the current schemas
+func OpenStateBundle(bundle []byte, passphrase string) (*entities.StateBundleManifest, map[string][]byte, error) {
+	archive, err := decryptBundle(bundle, passphrase)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	manifest, files, err := readBundleArchive(archive)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for name, data := range files {
+		upgraded, err := upgradeBundleFile(name, manifest.Files[name].SchemaVersion, data)
+		if err != nil {
+			return nil, nil, err
+		}
+		files[name] = upgraded
+	}
+	return manifest, files, nil
+}
+
+// checkBundlePassphrase rejects passphrases too short to protect the account credentials in a bundle
+func checkBundlePassphrase(passphrase string) error {
+	if len(passphrase) < minBundlePassphraseLength {
+		return fmt.Errorf("bundle passphrase must be at least %d characters", minBundlePassphraseLength)
+	}
+	return nil
+}
+
+// dataFileHasContent returns true if a data file exists and holds more than an empty JSON value
+func dataFileHasContent(path string) bool {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false
+	}
+	switch string(bytes.TrimSpace(data)) {
+	case "", "[]", "{}", "null":
+		return false
+	}
+
+	// accounts.json keeps its schema header when empty
+	var file dto.AccountsFileDTO
+	if json.Unmarshal(data, &file) == nil && file.SchemaVersion > 0 && len(file.Accounts) == 0 {
+		return false
+	}
+	return true
+}
+
+// writeBundleArchive writes files into an in-memory tar.gz
+func writeBundleArchive(files []snapshotFile, modTime time.Time) ([]byte, error) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+
+	for _, file := range files {
+		header := &tar.Header{
+			Name:    file.name,
+			Mode:    0o600,
+			Size:    int64(len(file.data)),
+			ModTime: modTime,
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return nil, fmt.Errorf("failed to write bundle archive: %w", err)
+		}
+		if _, err := tw.Write(file.data); err != nil {
+			return nil, fmt.Errorf("failed to write bundle archive: %w", err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to write bundle archive: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return nil, fmt.Errorf("failed to write bundle archive: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// readBundleArchive extracts the manifest and data files of a decrypted bundle and checks them against
+// each other: every listed file must be present with its recorded checksum, and nothing else
+func readBundleArchive(archive []byte) (*entities.StateBundleManifest, map[string][]byte, error) {
+	gz, err := gzip.NewReader(bytes.NewReader(archive))
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid bundle archive: %w", err)
+	}
+	defer gz.Close()
+
+	var manifest *entities.StateBundleManifest
+	files := make(map[string][]byte)
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid bundle archive: %w", err)
+		}
+
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read %s from bundle: %w", header.Name, err)
+		}
+
+		if header.Typeflag == tar.TypeReg && header.Name == stateBundleManifestName {
+			manifest = &entities.StateBundleManifest{}
+			if err := json.Unmarshal(data, manifest); err != nil {
+				return nil, nil, fmt.Errorf("invalid bundle manifest: %w", err)
+			}
+			continue
+		}
+		if _, known := stateBundleSchemaVersions[header.Name]; header.Typeflag != tar.TypeReg || !known {
+			return nil, nil, fmt.Errorf("unexpected entry in bundle: %s", header.Name)
+		}
+		files[header.Name] = data
+	}
+
+	if manifest == nil {
+		return nil, nil, fmt.Errorf("bundle has no %s", stateBundleManifestName)
+	}
+	if manifest.FormatVersion < 1 || manifest.FormatVersion > stateBundleFormatVersion {
+		return nil, nil, fmt.Errorf(
+			"bundle format version %d is not supported (this build reads up to %d); upgrade claude-proxy",
+			manifest.FormatVersion, stateBundleFormatVersion,
+		)
+	}
+	if len(manifest.Files) != len(files) {
+		return nil, nil, fmt.Errorf(
+			"bundle manifest lists %d files but the bundle holds %d", len(manifest.Files), len(files),
+		)
+	}
+
+	for name, data := range files {
+		entry, ok := manifest.Files[name]
+		if !ok {
+			return nil, nil, fmt.Errorf("%s in bundle is not listed in its manifest", name)
+		}
+		sum := sha256.Sum256(data)
+		if hex.EncodeToString(sum[:]) != entry.SHA256 || int64(len(data)) != entry.Size {
+			return nil, nil, fmt.Errorf("%s in bundle does not match its manifest checksum", name)
+		}
+		if entry.SchemaVersion > stateBundleSchemaVersions[name] {
+			return nil, nil, fmt.Errorf(
+				"%s in bundle has schema version %d, newer than this build supports (%d); upgrade claude-proxy",
+				name, entry.SchemaVersion, stateBundleSchemaVersions[name],
+			)
+		}
+		if !json.Valid(data) {
+			return nil, nil, fmt.Errorf("%s in bundle is not valid JSON", name)
+		}
+	}
+
+	return manifest, files, nil
+}
+
+// upgradeBundleFile applies the schema upgrades of a data file from schemaVersion to the current version
+func upgradeBundleFile(name string, schemaVersion int, data []byte) ([]byte, error) {
+	for v := schemaVersion; v < stateBundleSchemaVersions[name]; v++ {
+		upgrade, ok := stateBundleUpgrades[name][v]
+		if !ok {
+			return nil, fmt.Errorf("no upgrade for %s from schema version %d", name, v)
+		}
+
+		var err error
+		if data, err = upgrade(data); err != nil {
+			return nil, fmt.Errorf("failed to upgrade %s from schema version %d: %w", name, v, err)
+		}
+	}
+	return data, nil
+}
+
+// encryptBundle seals data with AES-256-GCM under a key derived from the passphrase
+// Layout: magic | salt | nonce | ciphertext; the magic and salt are authenticated with the data
+func encryptBundle(data []byte, passphrase string) ([]byte, error) {
+	salt := make([]byte, stateBundleSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("failed to generate bundle salt: %w", err)
+	}
+
+	gcm, err := newBundleCipher(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate bundle nonce: %w", err)
+	}
+
+	header := append([]byte(stateBundleMagic), salt...)
+	out := append(append([]byte{}, header...), nonce...)
+	return gcm.Seal(out, nonce, data, header), nil
+}
+
+// decryptBundle opens a bundle sealed by encryptBundle
+func decryptBundle(bundle []byte, passphrase string) ([]byte, error) {
+	headerSize := len(stateBundleMagic) + stateBundleSaltSize
+	if len(bundle) < headerSize || string(bundle[:len(stateBundleMagic)]) != stateBundleMagic {
+		return nil, fmt.Errorf("not a claude-proxy state bundle")
+	}
+
+	gcm, err := newBundleCipher(passphrase, bundle[len(stateBundleMagic):headerSize])
+	if err != nil {
+		return nil, err
+	}
+	if len(bundle) < headerSize+gcm.NonceSize() {
+		return nil, fmt.Errorf("state bundle is truncated")
+	}
+
+	nonce := bundle[headerSize : headerSize+gcm.NonceSize()]
+	data, err := gcm.Open(nil, nonce, bundle[headerSize+gcm.NonceSize():], bundle[:headerSize])
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt state bundle: wrong passphrase or corrupted file")
+	}
+	return data, nil
+}
+
+// newBundleCipher derives the bundle key from the passphrase and salt
+func newBundleCipher(passphrase string, salt []byte) (cipher.AEAD, error) {
+	key, err := pbkdf2.Key(sha256.New, passphrase, salt, stateBundleKDFIterations, 32)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive bundle key: %w", err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create bundle cipher: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,355 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"claude-proxy/modules/auth/domain/entities"
+	"claude-proxy/modules/auth/domain/interfaces"
+
+	sctx "github.com/phathdt/service-context"
+)
+
+// StatisticsService implements per-token usage statistics with hybrid storage pattern
+// Samples are aggregated into fixed-resolution buckets in memory and synced to persistence periodically
+type StatisticsService struct {
+	cacheRepo       interfaces.UsageStatsCacheRepository
+	persistenceRepo interfaces.UsageStatsPersistenceRepository
+	resolution      time.Duration
+	retention       time.Duration
+	hashUserIDs     bool
+	dirty           bool
+	mu              sync.RWMutex
+	logger          sctx.Logger
+}
+
+// NewStatisticsService creates a new statistics service with cache and persistence layers
+// resolution is the smallest bucket size; buckets older than retention are dropped on sync
+// With hashUserIDs, end user IDs are stored as hashes instead of as sent
+func NewStatisticsService(
+	cacheRepo interfaces.UsageStatsCacheRepository,
+	persistenceRepo interfaces.UsageStatsPersistenceRepository,
+	resolution, retention time.Duration,
+	hashUserIDs bool,
+	appLogger sctx.Logger,
+) interfaces.StatisticsService {
+	logger := appLogger.Withs(sctx.Fields{"component": "statistics-service"})
+
+	svc := &StatisticsService{
+		cacheRepo:       cacheRepo,
+		persistenceRepo: persistenceRepo,
+		resolution:      resolution,
+		retention:       retention,
+		hashUserIDs:     hashUserIDs,
+		dirty:           false,
+		logger:          logger,
+	}
+
+	// Load from persistent storage into cache on init
+	if err := svc.loadFromPersistence(); err != nil {
+		logger.Withs(sctx.Fields{"error": err}).Warn("Failed to load usage statistics from persistence")
+	}
+
+	return svc
+}
+
+// loadFromPersistence loads unexpired buckets from persistent storage into cache
+func (s *StatisticsService) loadFromPersistence() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	buckets, err := s.persistenceRepo.LoadAll(context.Background())
+	if err != nil {
+		return fmt.Errorf("failed to load usage statistics from persistence: %w", err)
+	}
+
+	cutoff := time.Now().Add(-s.retention)
+	kept := make([]*entities.UsageBucket, 0, len(buckets))
+	for _, bucket := range buckets {
+		if !bucket.Start.Before(cutoff) {
+			kept = append(kept, bucket)
+		}
+	}
+
+	if err := s.cacheRepo.ReplaceAll(context.Background(), kept); err != nil {
+		return fmt.Errorf("failed to load usage statistics into cache: %w", err)
+	}
+
+	// Expired buckets were dropped, rewrite the file on next sync
+	if len(kept) != len(buckets) {
+		s.dirty = true
+	}
+
+	s.logger.Withs(sctx.Fields{
+		"count":   len(kept),
+		"expired": len(buckets) - len(kept),
+	}).Info("Usage statistics loaded from persistence to cache")
+	return nil
+}
+
+// markDirty marks data as changed
+func (s *StatisticsService) markDirty() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.dirty = true
+}
+
+// isDirty checks if data has changed
+func (s *StatisticsService) isDirty() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.dirty
+}
+
+// clearDirty clears the dirty flag
+func (s *StatisticsService) clearDirty() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.dirty = false
+}
+
+// Sync drops expired buckets and syncs cache data to persistent storage (called every 1 minute)
+func (s *StatisticsService) Sync(ctx context.Context) error {
+	removed, err := s.cacheRepo.DeleteBefore(ctx, time.Now().Add(-s.retention))
+	if err != nil {
+		return fmt.Errorf("failed to drop expired usage buckets: %w", err)
+	}
+	if removed > 0 {
+		s.markDirty()
+	}
+
+	if !s.isDirty() {
+		return nil // No changes, skip sync
+	}
+
+	s.logger.Debug("Syncing usage statistics to persistent storage")
+
+	// Clear before listing so samples recorded during the save mark the data dirty again
+	s.clearDirty()
+
+	buckets, err := s.cacheRepo.List(ctx, time.Time{})
+	if err != nil {
+		s.markDirty()
+		return fmt.Errorf("failed to list usage buckets from cache: %w", err)
+	}
+
+	// Batch save all buckets to persistent storage
+	if err := s.persistenceRepo.SaveAll(ctx, buckets); err != nil {
+		s.markDirty()
+		s.logger.Withs(sctx.Fields{
+			"error": err,
+		}).Error("Failed to save usage statistics to persistence")
+		return fmt.Errorf("failed to save usage statistics: %w", err)
+	}
+
+	s.logger.Withs(sctx.Fields{"count": len(buckets)}).Debug("Usage statistics synced to persistent storage")
+	return nil
+}
+
+// FinalSync performs final sync on graceful shutdown
+func (s *StatisticsService) FinalSync(ctx context.Context) error {
+	s.logger.Info("Performing final sync of usage statistics")
+	return s.Sync(ctx)
+}
+
+// RecordRequest records a proxied request sample
+func (s *StatisticsService) RecordRequest(ctx context.Context, sample *entities.RequestSample) error {
+	if sample.TokenID == "" {
+		return fmt.Errorf("sample has no token ID")
+	}
+	if s.hashUserIDs && sample.UserID != "" {
+		hashed := *sample
+		hashed.UserID = hashUserID(sample.UserID)
+		sample = &hashed
+	}
+
+	if err := s.cacheRepo.AddSample(ctx, sample.Timestamp.Truncate(s.resolution), sample); err != nil {
+		return err
+	}
+
+	s.markDirty()
+	return nil
+}
+
+// GetTokenStats returns contiguous buckets of the given size covering the last period for a token
+// Empty buckets are included so the series has no gaps
+func (s *StatisticsService) GetTokenStats(
+	ctx context.Context,
+	tokenID string,
+	period, bucket time.Duration,
+) ([]*entities.UsageBucket, error) {
+	if err := s.validateWindow(period, bucket); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	first := now.Add(-period).Truncate(bucket)
+	last := now.Truncate(bucket)
+
+	buckets, err := s.cacheRepo.ListByToken(ctx, tokenID, first)
+	if err != nil {
+		return nil, err
+	}
+
+	// Build the empty series, then fold base-resolution buckets into it
+	count := int(last.Sub(first)/bucket) + 1
+	series := make([]*entities.UsageBucket, count)
+	for i := range series {
+		series[i] = &entities.UsageBucket{TokenID: tokenID, Start: first.Add(time.Duration(i) * bucket)}
+	}
+
+	for _, b := range buckets {
+		index := int(b.Start.Truncate(bucket).Sub(first) / bucket)
+		if index < 0 || index >= count {
+			continue
+		}
+		series[index].Merge(b)
+	}
+
+	return series, nil
+}
+
+// RankTokens returns per-token totals over the last period, ordered by request count (highest first)
+func (s *StatisticsService) RankTokens(ctx context.Context, period time.Duration) ([]*entities.UsageBucket, error) {
+	if err := s.validatePeriod(period); err != nil {
+		return nil, err
+	}
+
+	since := time.Now().Add(-period).Truncate(s.resolution)
+	buckets, err := s.cacheRepo.List(ctx, since)
+	if err != nil {
+		return nil, err
+	}
+
+	totals := make(map[string]*entities.UsageBucket)
+	for _, b := range buckets {
+		total, ok := totals[b.TokenID]
+		if !ok {
+			total = &entities.UsageBucket{TokenID: b.TokenID, Start: since}
+			totals[b.TokenID] = total
+		}
+		total.Merge(b)
+	}
+
+	ranking := make([]*entities.UsageBucket, 0, len(totals))
+	for _, total := range totals {
+		ranking = append(ranking, total)
+	}
+
+	sort.Slice(ranking, func(i, j int) bool {
+		if ranking[i].Requests != ranking[j].Requests {
+			return ranking[i].Requests > ranking[j].Requests
+		}
+		if ranking[i].TotalTokens() != ranking[j].TotalTokens() {
+			return ranking[i].TotalTokens() > ranking[j].TotalTokens()
+		}
+		return ranking[i].TokenID < ranking[j].TokenID
+	})
+
+	return ranking, nil
+}
+
+// ListTokenUsers returns the end users of a token over the last period with their totals,
+// ordered by request count (highest first)
+func (s *StatisticsService) ListTokenUsers(
+	ctx context.Context,
+	tokenID string,
+	period time.Duration,
+) ([]*entities.EndUserUsage, error) {
+	if err := s.validatePeriod(period); err != nil {
+		return nil, err
+	}
+
+	since := time.Now().Add(-period).Truncate(s.resolution)
+	buckets, err := s.cacheRepo.ListByToken(ctx, tokenID, since)
+	if err != nil {
+		return nil, err
+	}
+
+	// Not folded with UsageBucket.Merge: the per-bucket user cap doesn't apply to the whole period
+	totals := make(map[string]*entities.EndUserUsage)
+	for _, b := range buckets {
+		for id, user := range b.Users {
+			total, ok := totals[id]
+			if !ok {
+				total = &entities.EndUserUsage{UserID: id}
+				totals[id] = total
+			}
+			total.Requests += user.Requests
+			total.InputTokens += user.InputTokens
+			total.OutputTokens += user.OutputTokens
+			if user.LastSeen.After(total.LastSeen) {
+				total.LastSeen = user.LastSeen
+			}
+		}
+	}
+
+	users := make([]*entities.EndUserUsage, 0, len(totals))
+	for _, total := range totals {
+		users = append(users, total)
+	}
+
+	sort.Slice(users, func(i, j int) bool {
+		if users[i].Requests != users[j].Requests {
+			return users[i].Requests > users[j].Requests
+		}
+		ti := users[i].InputTokens + users[i].OutputTokens
+		tj := users[j].InputTokens + users[j].OutputTokens
+		if ti != tj {
+			return ti > tj
+		}
+		return users[i].UserID < users[j].UserID
+	})
+
+	return users, nil
+}
+
+// hashUserID returns the identifier an end user ID is stored as with privacy.hash_user_ids:
+// the first 32 hex characters of its SHA-256, stable so the same user is still counted together
+func hashUserID(userID string) string {
+	sum := sha256.Sum256([]byte(userID))
+	return hex.EncodeToString(sum[:16])
+}
+
+// Resolution returns the granularity at which samples are aggregated
+func (s *StatisticsService) Resolution() time.Duration {
+	return s.resolution
+}
+
+// Retention returns how long buckets are kept
+func (s *StatisticsService) Retention() time.Duration {
+	return s.retention
+}
+
+// maxSeriesBuckets caps how many buckets a single stats query may return
+const maxSeriesBuckets = 2000
+
+// validatePeriod checks that a query period can be served from retained data
+func (s *StatisticsService) validatePeriod(period time.Duration) error {
+	if period <= 0 {
+		return fmt.Errorf("period must be positive")
+	}
+	if period > s.retention {
+		return fmt.Errorf("period %s exceeds statistics retention %s", period, s.retention)
+	}
+	return nil
+}
+
+// validateWindow checks that a query period and bucket size can be served as a bucket series
+func (s *StatisticsService) validateWindow(period, bucket time.Duration) error {
+	if err := s.validatePeriod(period); err != nil {
+		return err
+	}
+	if bucket < s.resolution || bucket%s.resolution != 0 {
+		return fmt.Errorf("bucket must be a multiple of the statistics resolution %s", s.resolution)
+	}
+	if period/bucket > maxSeriesBuckets {
+		return fmt.Errorf("period %s with bucket %s yields more than %d buckets", period, bucket, maxSeriesBuckets)
+	}
+	return nil
+}
@@ -2,7 +2,11 @@ package services
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
+	"net/textproto"
+	"slices"
 	"strings"
 	"sync"
 	"time"
@@ -10,8 +14,9 @@ import (
 	"claude-proxy/modules/auth/application/dto"
 	"claude-proxy/modules/auth/domain/entities"
 	"claude-proxy/modules/auth/domain/interfaces"
+	"claude-proxy/pkg/httpproxy"
+	"claude-proxy/pkg/uid"
 
-	"github.com/google/uuid"
 	sctx "github.com/phathdt/service-context"
 	"github.com/phathdt/service-context/core"
 )
@@ -22,10 +27,24 @@ type TokenService struct {
 	cacheRepo       interfaces.TokenCacheRepository
 	persistenceRepo interfaces.TokenPersistenceRepository
 	dirty           bool
+	removed         bool              // Entries were removed from the cache since the last save
+	fileHashes      map[string]string // Hash of each token as last loaded from or saved to the file, by ID
 	mu              sync.RWMutex
 	logger          sctx.Logger
 }
 
+// TokenKeyPrefix is prepended to generated token keys
+const TokenKeyPrefix = "sk-proxy-"
+
+// GenerateTokenKey generates a new random token key (prefix + 48 hex chars)
+func GenerateTokenKey() (string, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate token key: %w", err)
+	}
+	return TokenKeyPrefix + hex.EncodeToString(buf), nil
+}
+
 // NewTokenService creates a new token service with cache and persistence layers
 func NewTokenService(
 	cacheRepo interfaces.TokenCacheRepository,
@@ -68,13 +87,18 @@ func (s *TokenService) loadFromPersistence() error {
 			}).Warn("Failed to load token into cache")
 		}
 	}
+	s.fileHashes = tokenFileHashes(tokens)
 
 	s.logger.Withs(sctx.Fields{"count": len(tokens)}).Info("Tokens loaded from persistence to cache")
 	return nil
 }
 
 // markDirty marks data as changed
+// Every validated request calls it, so an already dirty flag is only checked under the read lock
 func (s *TokenService) markDirty() {
+	if s.isDirty() {
+		return
+	}
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	s.dirty = true
@@ -87,40 +111,305 @@ func (s *TokenService) isDirty() bool {
 	return s.dirty
 }
 
-// clearDirty clears the dirty flag
-func (s *TokenService) clearDirty() {
+// markRemoved marks data as changed by removing entries, so the next save may leave the storage empty
+func (s *TokenService) markRemoved() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.dirty = true
+	s.removed = true
+}
+
+// clearDirty clears the dirty flag, returning whether entries were removed since the last save
+func (s *TokenService) clearDirty() bool {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	removed := s.removed
 	s.dirty = false
+	s.removed = false
+	return removed
+}
+
+// restoreDirty marks data as changed again after a failed save
+func (s *TokenService) restoreDirty(removed bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.dirty = true
+	s.removed = s.removed || removed
 }
 
 // Sync syncs cache data to persistent storage (called every 1 minute)
+// Changes another writer made to the storage since it was last loaded are merged into the cache
+// first, so they are not overwritten by the save
 func (s *TokenService) Sync(ctx context.Context) error {
+	changed, err := s.persistenceRepo.Changed(ctx)
+	if err != nil {
+		s.logger.Withs(sctx.Fields{"error": err}).Warn("Failed to check tokens storage for external changes")
+	} else if changed {
+		if _, err := s.reconcile(ctx, true); err != nil {
+			return fmt.Errorf("failed to reconcile tokens: %w", err)
+		}
+	}
+
 	if !s.isDirty() {
 		return nil // No changes, skip sync
 	}
 
 	s.logger.Debug("Syncing tokens to persistent storage")
 
+	// Clear before listing so changes made during the save mark the data dirty again
+	removed := s.clearDirty()
+
 	// Get all tokens from cache
 	tokens, err := s.cacheRepo.List(ctx)
 	if err != nil {
+		s.restoreDirty(removed)
 		return fmt.Errorf("failed to list tokens from cache: %w", err)
 	}
 
 	// Batch save all tokens to persistent storage
-	if err := s.persistenceRepo.SaveAll(ctx, tokens); err != nil {
+	if err := s.persistenceRepo.SaveAll(ctx, tokens, removed); err != nil {
+		s.restoreDirty(removed)
 		s.logger.Withs(sctx.Fields{
 			"error": err,
 		}).Error("Failed to save tokens to persistence")
 		return fmt.Errorf("failed to save tokens: %w", err)
 	}
+	s.setFileHashes(tokens)
 
-	s.clearDirty()
 	s.logger.Withs(sctx.Fields{"count": len(tokens)}).Info("Tokens synced to persistent storage")
 	return nil
 }
 
+// Reload merges tokens.json into the cache now, whether or not another writer modified it
+func (s *TokenService) Reload(ctx context.Context) (*entities.ReconcileReport, error) {
+	changed, err := s.persistenceRepo.Changed(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check tokens storage: %w", err)
+	}
+
+	report, err := s.reconcile(ctx, changed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reconcile tokens: %w", err)
+	}
+	return report, nil
+}
+
+// Mirror replaces the cached tokens with a primary instance's (warm standby)
+// Tokens the primary no longer holds are removed first, then every token of the primary is added or replaced
+// as is, whatever its update time; the next sync writes the result to tokens.json.
+func (s *TokenService) Mirror(ctx context.Context, tokens []*entities.Token) (*entities.MirrorResult, error) {
+	cached, err := s.cacheRepo.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tokens from cache: %w", err)
+	}
+
+	primary := make(map[string]bool, len(tokens))
+	for _, token := range tokens {
+		primary[token.ID] = true
+	}
+	existing := make(map[string]bool, len(cached))
+	result := &entities.MirrorResult{}
+	for _, token := range cached {
+		if primary[token.ID] {
+			existing[token.ID] = true
+			continue
+		}
+		if err := s.cacheRepo.Delete(ctx, token.ID); err != nil {
+			return nil, fmt.Errorf("failed to remove token %s: %w", token.ID, err)
+		}
+		result.Removed++
+	}
+
+	for _, token := range tokens {
+		if existing[token.ID] {
+			err = s.cacheRepo.Update(ctx, token)
+		} else {
+			err = s.cacheRepo.Create(ctx, token)
+		}
+		if err != nil {
+			result.Skipped++
+			s.logger.Withs(sctx.Fields{"token_id": token.ID, "error": err.Error()}).Warn(
+				"Token of the primary conflicts with the cache, skipped",
+			)
+			continue
+		}
+		if existing[token.ID] {
+			result.Updated++
+		} else {
+			result.Added++
+		}
+	}
+
+	if result.Removed > 0 {
+		s.markRemoved()
+	} else {
+		s.markDirty()
+	}
+	return result, nil
+}
+
+// reconcile merges the tokens in storage into the cache
+// Tokens only on disk are added, and tokens changed on disk since the last load or save replace the cached
+// version, with the usage counted since the last save carried over. When a token changed in the cache too, the
+// conflict is logged and the most recently updated version is kept. Tokens only in the cache are kept and
+// written back by the next save. Every merged token is logged.
+func (s *TokenService) reconcile(ctx context.Context, changed bool) (*entities.ReconcileReport, error) {
+	stored, err := s.persistenceRepo.LoadAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	cached, err := s.cacheRepo.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[string]*entities.Token, len(cached))
+	for _, token := range cached {
+		byID[token.ID] = token
+	}
+
+	s.mu.RLock()
+	base := s.fileHashes
+	s.mu.RUnlock()
+
+	report := &entities.ReconcileReport{Changed: changed}
+	for _, token := range stored {
+		current, ok := byID[token.ID]
+		switch {
+		case !ok:
+			if err := s.cacheRepo.Create(ctx, token); err != nil {
+				report.Skipped = append(report.Skipped, token.ID)
+				s.logger.Withs(sctx.Fields{"token_id": token.ID, "error": err.Error()}).Warn(
+					"Token in tokens file conflicts with the cache, keeping the cached version",
+				)
+				break
+			}
+			report.Added = append(report.Added, token.ID)
+			s.logger.Withs(sctx.Fields{
+				"token_id":   token.ID,
+				"token_name": token.Name,
+				"status":     string(token.Status),
+				"role":       string(token.Role),
+			}).Info("Token added from tokens file")
+		case tokenFileHash(token) != base[token.ID]:
+			s.mergeStoredToken(ctx, token, current, base[token.ID], report)
+		}
+		delete(byID, token.ID)
+	}
+	for id := range byID {
+		report.CacheOnly = append(report.CacheOnly, id)
+	}
+	slices.Sort(report.CacheOnly)
+	s.setFileHashes(stored)
+
+	// The cache holds tokens or changes the file lacks: write them back
+	if len(report.CacheOnly) > 0 || len(report.Added)+len(report.Updated) < len(stored) {
+		s.markDirty()
+	}
+
+	fields := sctx.Fields{
+		"added":      len(report.Added),
+		"updated":    len(report.Updated),
+		"cache_only": len(report.CacheOnly),
+		"skipped":    len(report.Skipped),
+		"conflicts":  len(report.Conflicts),
+	}
+	if len(report.CacheOnly) > 0 {
+		fields["cache_only_ids"] = report.CacheOnly
+	}
+	if changed {
+		s.logger.Withs(fields).Warn("Tokens file was modified by another writer, merged its changes into the cache")
+	} else {
+		s.logger.Withs(fields).Info("Tokens file reloaded into the cache")
+	}
+	return report, nil
+}
+
+// mergeStoredToken replaces the cached token by its version in the file, which changed since the last load
+// or save (baseHash), unless the cached token changed too and was updated more recently
+func (s *TokenService) mergeStoredToken(
+	ctx context.Context,
+	token, current *entities.Token,
+	baseHash string,
+	report *entities.ReconcileReport,
+) {
+	cachedHash := tokenFileHash(current)
+	if cachedHash == tokenFileHash(token) {
+		return // Both sides made the same change
+	}
+	conflict := cachedHash != baseHash
+	fields := sctx.Fields{
+		"token_id":          token.ID,
+		"token_name":        token.Name,
+		"status":            string(token.Status),
+		"cached_status":     string(current.Status),
+		"role":              string(token.Role),
+		"cached_role":       string(current.Role),
+		"key_changed":       token.KeyHash != current.KeyHash,
+		"updated_at":        token.UpdatedAt.Format(time.RFC3339),
+		"cached_updated_at": current.UpdatedAt.Format(time.RFC3339),
+	}
+	// Persisted times have second precision
+	if conflict && !token.UpdatedAt.After(current.UpdatedAt.Truncate(time.Second)) {
+		report.Conflicts = append(report.Conflicts, token.ID)
+		fields["kept"] = "cache"
+		s.logger.Withs(fields).Warn(
+			"Token changed both in tokens file and in the cache, keeping the most recently updated version",
+		)
+		return
+	}
+
+	// Usage is not part of the hash: keep what the cache counted since the file was written
+	token.UsageCount = max(token.UsageCount, current.UsageCount)
+	if current.LastUsedAt != nil && (token.LastUsedAt == nil || current.LastUsedAt.After(*token.LastUsedAt)) {
+		token.LastUsedAt = current.LastUsedAt
+	}
+	if err := s.cacheRepo.Update(ctx, token); err != nil {
+		report.Skipped = append(report.Skipped, token.ID)
+		s.logger.Withs(sctx.Fields{"token_id": token.ID, "error": err.Error()}).Warn(
+			"Token in tokens file conflicts with the cache, keeping the cached version",
+		)
+		return
+	}
+	report.Updated = append(report.Updated, token.ID)
+	if conflict {
+		report.Conflicts = append(report.Conflicts, token.ID)
+		fields["kept"] = "file"
+		s.logger.Withs(fields).Warn(
+			"Token changed both in tokens file and in the cache, keeping the most recently updated version",
+		)
+		return
+	}
+	s.logger.Withs(fields).Info("Token replaced by the version changed in tokens file")
+}
+
+// setFileHashes records the tokens now in the file, as the base of the next reconcile
+// The map is replaced rather than modified, so readers may keep using it after releasing the lock.
+func (s *TokenService) setFileHashes(tokens []*entities.Token) {
+	hashes := tokenFileHashes(tokens)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fileHashes = hashes
+}
+
+// tokenFileHashes returns the tokenFileHash of each token, by ID
+func tokenFileHashes(tokens []*entities.Token) map[string]string {
+	hashes := make(map[string]string, len(tokens))
+	for _, token := range tokens {
+		hashes[token.ID] = tokenFileHash(token)
+	}
+	return hashes
+}
+
+// tokenFileHash returns the hash of the token as written to the file, leaving out its usage, which changes
+// with every validated request
+func tokenFileHash(token *entities.Token) string {
+	record := dto.ToTokenPersistenceDTO(token)
+	record.UsageCount = 0
+	record.LastUsedAt = nil
+	return fileRecordHash(record)
+}
+
 // FinalSync performs final sync on graceful shutdown
 func (s *TokenService) FinalSync(ctx context.Context) error {
 	s.logger.Info("Performing final sync of tokens")
@@ -142,15 +431,15 @@ func (s *TokenService) CreateToken(
 	// Create token entity
 	now := time.Now()
 	token := &entities.Token{
-		ID:         uuid.Must(uuid.NewV7()).String(),
+		ID:         uid.New(),
 		Name:       name,
-		Key:        key,
 		Status:     status,
 		Role:       role,
 		UsageCount: 0,
 		CreatedAt:  now,
 		UpdatedAt:  now,
 	}
+	token.SetKey(key)
 
 	if err := s.cacheRepo.Create(ctx, token); err != nil {
 		return nil, err
@@ -178,60 +467,21 @@ func (s *TokenService) ListTokens(
 	query *dto.TokenQueryParams,
 	paging *core.Paging,
 ) ([]*entities.Token, error) {
-	// Get all tokens from cache
-	allTokens, err := s.cacheRepo.List(ctx)
+	filter := entities.TokenFilter{
+		Role:   entities.TokenRole(query.Role),
+		Status: entities.TokenStatus(query.Status),
+		Search: query.Search,
+	}
+	tokens, total, err := s.cacheRepo.Query(ctx, filter, (paging.Page-1)*paging.Limit, paging.Limit)
 	if err != nil {
 		return nil, err
 	}
 
-	// Filter tokens based on query params
-	filtered := make([]*entities.Token, 0)
-	for _, token := range allTokens {
-		// Filter by role
-		if query.Role != "" && string(token.Role) != query.Role {
-			continue
-		}
-
-		// Filter by status
-		if query.Status != "" && string(token.Status) != query.Status {
-			continue
-		}
-
-		// Search by name or key (case-insensitive)
-		if query.Search != "" {
-			searchLower := strings.ToLower(query.Search)
-			nameLower := strings.ToLower(token.Name)
-			keyLower := strings.ToLower(token.Key)
-			if !strings.Contains(nameLower, searchLower) && !strings.Contains(keyLower, searchLower) {
-				continue
-			}
-		}
-
-		filtered = append(filtered, token)
-	}
-
-	// Set total count
-	paging.Total = int64(len(filtered))
-
-	// Apply pagination
-	offset := (paging.Page - 1) * paging.Limit
-	limit := paging.Limit
-
-	// Calculate pagination bounds
-	start := offset
-	end := offset + limit
-	if start > len(filtered) {
-		start = len(filtered)
-	}
-	if end > len(filtered) {
-		end = len(filtered)
-	}
-
-	// Return paginated slice
-	return filtered[start:end], nil
+	paging.Total = int64(total)
+	return tokens, nil
 }
 
-// UpdateToken updates an existing token
+// UpdateToken updates an existing token (an empty key keeps the current one)
 func (s *TokenService) UpdateToken(
 	ctx context.Context,
 	id, name, key string,
@@ -245,7 +495,7 @@ func (s *TokenService) UpdateToken(
 	}
 
 	// Check if key is being changed and if it already exists in another token
-	if token.Key != key {
+	if key != "" && !token.MatchesKey(key) {
 		existingToken, err := s.cacheRepo.GetByKey(ctx, key)
 		if err == nil && existingToken != nil && existingToken.ID != id {
 			return nil, fmt.Errorf("token with key already exists")
@@ -253,11 +503,7 @@ func (s *TokenService) UpdateToken(
 	}
 
 	// Update fields
-	token.Name = name
-	token.Key = key
-	token.Status = status
-	token.Role = role
-	token.UpdatedAt = time.Now()
+	token.Update(name, key, status, role)
 
 	if err := s.cacheRepo.Update(ctx, token); err != nil {
 		return nil, err
@@ -268,13 +514,173 @@ func (s *TokenService) UpdateToken(
 	return token, nil
 }
 
+// UpdateTokenAllowedPaths replaces the extra proxy path patterns allowed for a token
+func (s *TokenService) UpdateTokenAllowedPaths(
+	ctx context.Context,
+	id string,
+	allowedPaths []string,
+) (*entities.Token, error) {
+	token, err := s.cacheRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("token not found: %w", err)
+	}
+
+	token.SetAllowedPaths(allowedPaths)
+
+	if err := s.cacheRepo.Update(ctx, token); err != nil {
+		return nil, err
+	}
+
+	s.markDirty()
+	s.logger.Withs(sctx.Fields{
+		"token_id":      token.ID,
+		"allowed_paths": allowedPaths,
+	}).Info("Token allowed paths updated")
+	return token, nil
+}
+
+// UpdateTokenMaxSessions sets the token's concurrent session limit override
+func (s *TokenService) UpdateTokenMaxSessions(
+	ctx context.Context,
+	id string,
+	maxSessions int,
+) (*entities.Token, error) {
+	if maxSessions < 0 {
+		return nil, fmt.Errorf("max_sessions must not be negative")
+	}
+
+	token, err := s.cacheRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("token not found: %w", err)
+	}
+
+	token.SetMaxSessions(maxSessions)
+
+	if err := s.cacheRepo.Update(ctx, token); err != nil {
+		return nil, err
+	}
+
+	s.markDirty()
+	s.logger.Withs(sctx.Fields{
+		"token_id":     token.ID,
+		"max_sessions": maxSessions,
+	}).Info("Token session limit updated")
+	return token, nil
+}
+
+// UpdateTokenUsageSummary enables or disables the usage summary event appended to streaming responses
+func (s *TokenService) UpdateTokenUsageSummary(
+	ctx context.Context,
+	id string,
+	enabled bool,
+) (*entities.Token, error) {
+	token, err := s.cacheRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("token not found: %w", err)
+	}
+
+	token.SetUsageSummary(enabled)
+
+	if err := s.cacheRepo.Update(ctx, token); err != nil {
+		return nil, err
+	}
+
+	s.markDirty()
+	s.logger.Withs(sctx.Fields{
+		"token_id":      token.ID,
+		"usage_summary": enabled,
+	}).Info("Token usage summary updated")
+	return token, nil
+}
+
+// UpdateTokenCaptureFailures enables or disables keeping the envelopes of the token's failed requests
+func (s *TokenService) UpdateTokenCaptureFailures(
+	ctx context.Context,
+	id string,
+	enabled bool,
+) (*entities.Token, error) {
+	token, err := s.cacheRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("token not found: %w", err)
+	}
+
+	token.SetCaptureFailures(enabled)
+
+	if err := s.cacheRepo.Update(ctx, token); err != nil {
+		return nil, err
+	}
+
+	s.markDirty()
+	s.logger.Withs(sctx.Fields{
+		"token_id":         token.ID,
+		"capture_failures": enabled,
+	}).Info("Token failure capture updated")
+	return token, nil
+}
+
+// UpdateTokenExternalUserIDHeader sets the request header metadata.user_id is taken from ("" clears it)
+func (s *TokenService) UpdateTokenExternalUserIDHeader(
+	ctx context.Context,
+	id string,
+	header string,
+) (*entities.Token, error) {
+	header = strings.TrimSpace(header)
+	if header != "" {
+		if err := httpproxy.ValidateHeaderName(header); err != nil {
+			return nil, err
+		}
+		header = textproto.CanonicalMIMEHeaderKey(header)
+	}
+
+	token, err := s.cacheRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("token not found: %w", err)
+	}
+
+	token.SetExternalUserIDHeader(header)
+
+	if err := s.cacheRepo.Update(ctx, token); err != nil {
+		return nil, err
+	}
+
+	s.markDirty()
+	s.logger.Withs(sctx.Fields{
+		"token_id":                token.ID,
+		"external_user_id_header": header,
+	}).Info("Token external user ID header updated")
+	return token, nil
+}
+
+// RotateTokenKey replaces a token's key with a newly generated one; the old key stops working at once
+// The returned token is the only place the new key is ever available
+func (s *TokenService) RotateTokenKey(ctx context.Context, id string) (*entities.Token, error) {
+	key, err := GenerateTokenKey()
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := s.cacheRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("token not found: %w", err)
+	}
+
+	token.Update(token.Name, key, token.Status, token.Role)
+	if err := s.cacheRepo.Update(ctx, token); err != nil {
+		return nil, err
+	}
+
+	s.markDirty()
+	s.logger.Withs(sctx.Fields{"token_id": token.ID}).Info("Token key rotated")
+	return token, nil
+}
+
 // DeleteToken deletes a token by ID
 func (s *TokenService) DeleteToken(ctx context.Context, id string) error {
 	if err := s.cacheRepo.Delete(ctx, id); err != nil {
 		return err
 	}
 
-	s.markDirty()
+	s.markRemoved()
 	s.logger.Withs(sctx.Fields{"token_id": id}).Info("Token deleted")
 	return nil
 }
@@ -291,9 +697,9 @@ func (s *TokenService) ValidateToken(ctx context.Context, key string) (*entities
 		return nil, fmt.Errorf("token is not active")
 	}
 
-	// Update usage count and last used time
+	// Count the request; the returned copy shows it too
 	token.IncrementUsage()
-	if err := s.cacheRepo.Update(ctx, token); err != nil {
+	if err := s.cacheRepo.RecordUsage(ctx, token.ID, *token.LastUsedAt); err != nil {
 		s.logger.Withs(sctx.Fields{
 			"token_id": token.ID,
 			"error":    err,
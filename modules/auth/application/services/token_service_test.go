@@ -0,0 +1,88 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"claude-proxy/modules/auth/domain/entities"
+	"claude-proxy/modules/auth/infrastructure/repositories"
+)
+
+// TestTokenServiceSyncKeepsFileEdits edits tokens.json between two syncs without bumping updated_at, while the
+// tokens keep being used: the edit must survive the second sync, with the usage counted meanwhile, and a token
+// changed in the cache too keeps the more recently updated version
+func TestTokenServiceSyncKeepsFileEdits(t *testing.T) {
+	logger := quietLogger(t)
+	dir := t.TempDir()
+	persistence, err := repositories.NewJSONTokenRepository(dir, false, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc := NewTokenService(repositories.NewMemoryTokenRepository(0, logger), persistence, logger)
+	ctx := context.Background()
+
+	keys := map[string]string{"edited": "sk-proxy-edited", "both": "sk-proxy-both"}
+	ids := make(map[string]string, len(keys))
+	for name, key := range keys {
+		token, err := svc.CreateToken(ctx, name, key, entities.TokenStatusActive, entities.TokenRoleUser)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids[name] = token.ID
+	}
+	if _, err := svc.ValidateToken(ctx, keys["edited"]); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.Sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	tokensFile := filepath.Join(dir, "tokens.json")
+	editDataFile(t, tokensFile, func(record map[string]any) { record["max_sessions"] = 3 })
+	if _, err := svc.UpdateTokenMaxSessions(ctx, ids["both"], 5); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.ValidateToken(ctx, keys["edited"]); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.Sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	edited, err := svc.GetTokenByID(ctx, ids["edited"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if edited.MaxSessions != 3 || edited.UsageCount != 2 {
+		t.Errorf("edited: max sessions %d, usage %d; want the file's 3 and 2", edited.MaxSessions, edited.UsageCount)
+	}
+	both, err := svc.GetTokenByID(ctx, ids["both"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if both.MaxSessions != 5 {
+		t.Errorf("both: max sessions %d, want the more recent cached 5", both.MaxSessions)
+	}
+
+	// The second sync wrote the merged tokens back rather than the cache's stale copy
+	data, err := os.ReadFile(tokensFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var saved []struct {
+		ID          string `json:"id"`
+		MaxSessions int    `json:"max_sessions"`
+	}
+	if err := json.Unmarshal(data, &saved); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]int{ids["edited"]: 3, ids["both"]: 5}
+	for _, token := range saved {
+		if token.MaxSessions != want[token.ID] {
+			t.Errorf("tokens.json: %s has max sessions %d, want %d", token.ID, token.MaxSessions, want[token.ID])
+		}
+	}
+}
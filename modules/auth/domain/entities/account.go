@@ -1,12 +1,24 @@
 package entities
 
-import "time"
+import (
+	"fmt"
+	"maps"
+	"net/textproto"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+)
 
-// Account represents a Claude OAuth account
+// Account represents a Claude account: OAuth subscription tokens, or a plain API key
 type Account struct {
 	ID               string
+	ExternalID       string // ID the account had before legacy IDs were replaced by UUIDv7 (empty otherwise)
 	Name             string
-	OrganizationUUID string
+	AuthType         AccountAuthType // How requests authenticate (oauth unless api_key)
+	APIKey           string          // Anthropic API key of api_key accounts, sent as x-api-key (empty for OAuth)
+	OrganizationUUID string          // Active organization used for proxied requests
+	Organizations    []Organization  // All organizations discovered during OAuth
 	AccessToken      string
 	RefreshToken     string
 	ExpiresAt        time.Time // When access token expires
@@ -14,8 +26,251 @@ type Account struct {
 	Status           AccountStatus
 	RateLimitedUntil *time.Time // When rate limit expires (nil if not rate limited)
 	LastRefreshError string     // Last error message from token refresh attempt
-	CreatedAt        time.Time
-	UpdatedAt        time.Time
+	RefreshFailures  int        // Consecutive failed refresh attempts (0 after a successful refresh)
+	RefreshFailedAt  *time.Time // When the last refresh attempt failed (nil after a successful refresh)
+	AutoRefresh      bool       // False in manual mode: tokens are only replaced through the credentials endpoint
+	Shadow           bool       // Only receives mirrored copies of requests (proxy.shadow_sample_percent), never selected
+	AdminCapable     bool       // Has organization admin access: upstream usage reports are collected for it
+	Canary           bool       // Serves the canary cohort (proxy.canary_percent of sessions) instead of the others
+	Priority         int        // Failover tier: lower tiers are preferred, the next one only serves when they can't
+	ProxyURL         string     // Optional egress proxy (http, https or socks5) for API and OAuth traffic
+	BaseURL          string     // Optional Claude API base URL (e.g. an enterprise gateway); empty uses claude.base_url
+	// Headers override the configured identification headers (User-Agent, x-app, extras) for this account
+	Headers map[string]string
+	// Availability limits the account to weekly time ranges (e.g. nights only); empty = always available
+	Availability     []AvailabilityWindow
+	SupportedModels  []string  // Model glob patterns (e.g. claude-3-5-*) the account serves; empty serves every model
+	QuotaRequests    int       // Max requests per usage window (0 = unlimited)
+	QuotaTokens      int       // Max tokens per usage window (0 = unlimited)
+	UsageWindowStart time.Time // Start of the current usage window (zero if no usage yet)
+	WindowRequests   int       // Requests served in the current usage window
+	WindowTokens     int       // Tokens consumed in the current usage window
+	// Input-side share of WindowTokens: uncached input, prompt cache writes and prompt cache reads
+	WindowInputTokens         int
+	WindowCacheCreationTokens int
+	WindowCacheReadTokens     int
+	DeletedAt                 *time.Time           // When the account was soft-deleted (nil if not deleted)
+	CooldownUntil             *time.Time           // New account kept out of the rotation until then (nil once activated)
+	ConflictDetectedAt        *time.Time           // When another client was found rotating the refresh token
+	RefreshLineage            []RefreshTokenRecord // Recent refresh token fingerprints, newest first
+	CreatedAt                 time.Time
+	UpdatedAt                 time.Time
+	CreatedBy                 string // Identity that created the account (e.g. "admin_key:<id>"); empty if unknown
+	UpdatedBy                 string // Identity behind the last admin change; empty if unknown
+}
+
+// Organization is a Claude organization an account belongs to
+type Organization struct {
+	UUID     string
+	Name     string
+	PlanType PlanType // Subscription plan detected from the organization's capabilities (empty if unknown)
+}
+
+// PlanType is the Claude subscription plan of an organization
+type PlanType string
+
+const (
+	PlanTypeMax  PlanType = "max"
+	PlanTypePro  PlanType = "pro"
+	PlanTypeTeam PlanType = "team"
+)
+
+// PlanTypeFromCapabilities detects the subscription plan from an organization's capabilities list
+// Returns an empty plan type when no known subscription capability is present (e.g. API-only organizations)
+func PlanTypeFromCapabilities(capabilities []string) PlanType {
+	var plan PlanType
+	for _, capability := range capabilities {
+		switch capability {
+		case "claude_max":
+			return PlanTypeMax
+		case "claude_pro":
+			plan = PlanTypePro
+		case "raven":
+			if plan == "" {
+				plan = PlanTypeTeam
+			}
+		}
+	}
+	return plan
+}
+
+// Clone returns a deep copy of the account that shares no state with the original
+func (a *Account) Clone() *Account {
+	copied := *a
+	copied.Organizations = append([]Organization(nil), a.Organizations...)
+	copied.RateLimitedUntil = cloneTime(a.RateLimitedUntil)
+	copied.RefreshFailedAt = cloneTime(a.RefreshFailedAt)
+	copied.Headers = maps.Clone(a.Headers)
+	copied.SupportedModels = append([]string(nil), a.SupportedModels...)
+	copied.Availability = make([]AvailabilityWindow, 0, len(a.Availability))
+	for _, window := range a.Availability {
+		window.Days = append([]string(nil), window.Days...)
+		copied.Availability = append(copied.Availability, window)
+	}
+	copied.DeletedAt = cloneTime(a.DeletedAt)
+	copied.CooldownUntil = cloneTime(a.CooldownUntil)
+	copied.ConflictDetectedAt = cloneTime(a.ConflictDetectedAt)
+	copied.RefreshLineage = append([]RefreshTokenRecord(nil), a.RefreshLineage...)
+	return &copied
+}
+
+// cloneTime copies an optional timestamp
+func cloneTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	copied := *t
+	return &copied
+}
+
+// HasOrganization returns true if the organization UUID was discovered for this account
+func (a *Account) HasOrganization(orgUUID string) bool {
+	for _, org := range a.Organizations {
+		if org.UUID == orgUUID {
+			return true
+		}
+	}
+	return false
+}
+
+// PlanType returns the subscription plan of the active organization (empty if unknown)
+func (a *Account) PlanType() PlanType {
+	for _, org := range a.Organizations {
+		if org.UUID == a.OrganizationUUID {
+			return org.PlanType
+		}
+	}
+	return ""
+}
+
+// SetActiveOrganization switches the organization used for proxied requests
+// The organization must be one of the account's discovered organizations
+func (a *Account) SetActiveOrganization(orgUUID string) error {
+	if !a.HasOrganization(orgUUID) {
+		return fmt.Errorf("organization %s is not available to this account", orgUUID)
+	}
+	a.OrganizationUUID = orgUUID
+	a.UpdatedAt = time.Now()
+	return nil
+}
+
+// ValidateProxyURL checks that an egress proxy URL uses a supported scheme and has a host
+func ValidateProxyURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("invalid proxy URL: %w", err)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return fmt.Errorf("unsupported proxy scheme %q (use http, https or socks5)", u.Scheme)
+	}
+	if u.Hostname() == "" {
+		return fmt.Errorf("proxy URL must include a host")
+	}
+	return nil
+}
+
+// SetProxyURL sets the egress proxy (empty clears it)
+func (a *Account) SetProxyURL(proxyURL string) error {
+	if proxyURL != "" {
+		if err := ValidateProxyURL(proxyURL); err != nil {
+			return err
+		}
+	}
+	a.ProxyURL = proxyURL
+	a.UpdatedAt = time.Now()
+	return nil
+}
+
+// ValidateBaseURL checks that a Claude API base URL is a well-formed https URL without query or fragment
+func ValidateBaseURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("invalid base URL: %w", err)
+	}
+	if u.Scheme != "https" {
+		return fmt.Errorf("base URL must use https")
+	}
+	if u.Hostname() == "" {
+		return fmt.Errorf("base URL must include a host")
+	}
+	if u.User != nil || u.RawQuery != "" || u.Fragment != "" {
+		return fmt.Errorf("base URL must not include credentials, a query or a fragment")
+	}
+	return nil
+}
+
+// SetBaseURL sets the Claude API base URL override (empty restores claude.base_url)
+// OAuth token refreshes always use the configured OAuth endpoints
+func (a *Account) SetBaseURL(baseURL string) error {
+	baseURL = strings.TrimSuffix(baseURL, "/")
+	if baseURL != "" {
+		if err := ValidateBaseURL(baseURL); err != nil {
+			return err
+		}
+	}
+	a.BaseURL = baseURL
+	a.UpdatedAt = time.Now()
+	return nil
+}
+
+// SetHeaders replaces the account's header overrides (nil or empty restores the configured headers)
+// Names are stored canonicalized, so each header has a single entry
+func (a *Account) SetHeaders(headers map[string]string) {
+	var canonical map[string]string
+	for name, value := range headers {
+		if canonical == nil {
+			canonical = make(map[string]string, len(headers))
+		}
+		canonical[textproto.CanonicalMIMEHeaderKey(name)] = value
+	}
+	a.Headers = canonical
+	a.UpdatedAt = time.Now()
+}
+
+// MaskedProxyURL returns the proxy URL with credentials hidden (safe for responses and logs)
+func (a *Account) MaskedProxyURL() string {
+	return MaskProxyURL(a.ProxyURL)
+}
+
+// MaskProxyURL hides the credentials of a proxy URL
+func MaskProxyURL(proxyURL string) string {
+	if proxyURL == "" {
+		return ""
+	}
+	u, err := url.Parse(proxyURL)
+	if err != nil {
+		return "***"
+	}
+	if u.User != nil {
+		u.User = url.User("***")
+	}
+	return u.String()
+}
+
+// UsageWindow is the length of the quota window, matching Claude's 5-hour usage limit window
+const UsageWindow = 5 * time.Hour
+
+// AccountAuthType is how an account authenticates to Claude API
+type AccountAuthType string
+
+const (
+	AccountAuthOAuth  AccountAuthType = "oauth"   // OAuth tokens (Bearer), refreshed with the refresh token
+	AccountAuthAPIKey AccountAuthType = "api_key" // Plain API key (x-api-key), which never expires nor refreshes
+)
+
+// IsAPIKey returns true if the account authenticates with an API key instead of OAuth tokens
+func (a *Account) IsAPIKey() bool {
+	return a.AuthType == AccountAuthAPIKey
+}
+
+// Credential returns the secret requests are authenticated with: the API key or the access token
+func (a *Account) Credential() string {
+	if a.IsAPIKey() {
+		return a.APIKey
+	}
+	return a.AccessToken
 }
 
 // AccountStatus represents the status of an app account
@@ -26,6 +281,7 @@ const (
 	AccountStatusInactive    AccountStatus = "inactive"     // Manually disabled
 	AccountStatusRateLimited AccountStatus = "rate_limited" // Temporarily rate limited
 	AccountStatusInvalid     AccountStatus = "invalid"      // Auth revoked/invalid
+	AccountStatusConflicted  AccountStatus = "conflicted"   // Another client rotated the refresh token
 )
 
 // IsActive returns true if the account is active
@@ -33,14 +289,19 @@ func (a *Account) IsActive() bool {
 	return a.Status == AccountStatusActive
 }
 
-// IsExpired returns true if the access token is expired
+// IsExpired returns true if the access token is expired (never for API keys)
 func (a *Account) IsExpired() bool {
-	return time.Now().After(a.ExpiresAt)
+	return !a.IsAPIKey() && time.Now().After(a.ExpiresAt)
 }
 
-// NeedsRefresh returns true if the token needs refresh (60s buffer)
+// NeedsRefresh returns true if the token needs refresh (60s buffer, never for API keys)
 func (a *Account) NeedsRefresh() bool {
-	return time.Now().After(a.ExpiresAt.Add(-60 * time.Second))
+	return !a.IsAPIKey() && time.Now().After(a.ExpiresAt.Add(-60*time.Second))
+}
+
+// ExpiresWithin returns true if the access token expires within d from now (never for API keys)
+func (a *Account) ExpiresWithin(d time.Duration) bool {
+	return !a.IsAPIKey() && time.Now().Add(d).After(a.ExpiresAt)
 }
 
 // UpdateTokens updates the access token, refresh token and expiry
@@ -54,6 +315,73 @@ func (a *Account) UpdateTokens(accessToken, refreshToken string, expiresIn int)
 	a.Status = AccountStatusActive
 	a.RateLimitedUntil = nil // Clear rate limit
 	a.LastRefreshError = ""  // Clear error on success
+	a.RefreshFailures = 0
+	a.RefreshFailedAt = nil
+}
+
+// SetShadow puts the account in or out of shadow mode
+func (a *Account) SetShadow(shadow bool) {
+	a.Shadow = shadow
+	a.UpdatedAt = time.Now()
+}
+
+// SetAdminCapable flags the account as having organization admin access (upstream usage reports) or not
+func (a *Account) SetAdminCapable(adminCapable bool) {
+	a.AdminCapable = adminCapable
+	a.UpdatedAt = time.Now()
+}
+
+// SetCanary moves the account in or out of the canary set
+func (a *Account) SetCanary(canary bool) {
+	a.Canary = canary
+	a.UpdatedAt = time.Now()
+}
+
+// SetPriority moves the account to another failover tier (lower = preferred)
+func (a *Account) SetPriority(priority int) {
+	a.Priority = priority
+	a.UpdatedAt = time.Now()
+}
+
+// SetSupportedModels replaces the model patterns the account serves (empty = every model)
+func (a *Account) SetSupportedModels(patterns []string) {
+	a.SupportedModels = nil
+	for _, pattern := range patterns {
+		if pattern = strings.TrimSpace(pattern); pattern != "" {
+			a.SupportedModels = append(a.SupportedModels, pattern)
+		}
+	}
+	a.UpdatedAt = time.Now()
+}
+
+// SupportsModel returns true if the account serves model: it has no model restriction, the model is unknown
+// (empty), or one of its patterns matches
+func (a *Account) SupportsModel(model string) bool {
+	if len(a.SupportedModels) == 0 || model == "" {
+		return true
+	}
+	for _, pattern := range a.SupportedModels {
+		if matched, _ := path.Match(pattern, model); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateModelPatterns checks that supported model patterns are valid globs (*, ? and [...] classes)
+func ValidateModelPatterns(patterns []string) error {
+	for _, pattern := range patterns {
+		if _, err := path.Match(strings.TrimSpace(pattern), ""); err != nil {
+			return fmt.Errorf("invalid model pattern %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// SetAutoRefresh enables or disables refreshing the account's tokens with its refresh token
+func (a *Account) SetAutoRefresh(enabled bool) {
+	a.AutoRefresh = enabled
+	a.UpdatedAt = time.Now()
 }
 
 // Deactivate marks the account as inactive
@@ -68,6 +396,23 @@ func (a *Account) Activate() {
 	a.UpdatedAt = time.Now()
 }
 
+// Disable marks the account as inactive, recording why
+func (a *Account) Disable(reason string) {
+	a.Status = AccountStatusInactive
+	a.LastRefreshError = reason
+	a.UpdatedAt = time.Now()
+}
+
+// Enable marks the account as active again, clearing its last error
+func (a *Account) Enable() {
+	a.Status = AccountStatusActive
+	a.RateLimitedUntil = nil
+	a.LastRefreshError = ""
+	a.RefreshFailures = 0
+	a.RefreshFailedAt = nil
+	a.UpdatedAt = time.Now()
+}
+
 // Update updates the account's name and status
 func (a *Account) Update(name string, status AccountStatus) {
 	if name != "" {
@@ -85,8 +430,88 @@ func (a *Account) UpdateRefreshError(errMsg string) {
 	a.UpdatedAt = time.Now()
 }
 
+// RecordRefreshFailure records a failed token refresh attempt (status unchanged: the failure may be transient)
+func (a *Account) RecordRefreshFailure(errMsg string) {
+	now := time.Now()
+	a.LastRefreshError = errMsg
+	a.RefreshFailures++
+	a.RefreshFailedAt = &now
+	a.UpdatedAt = now
+}
+
+// RefreshRetryDelay is how long after a failed refresh an account with an expired access token stays out of
+// selection; afterwards one request may try refreshing it again
+const RefreshRetryDelay = 5 * time.Minute
+
+// IsRefreshFailing returns true if the last refresh attempt failed less than RefreshRetryDelay ago
+func (a *Account) IsRefreshFailing() bool {
+	return a.RefreshFailedAt != nil && time.Since(*a.RefreshFailedAt) < RefreshRetryDelay
+}
+
+// IsUsableNow returns true if a request sent through the account now can get a valid access token:
+// available for proxying, and its access token unexpired or its refresh not recently failing
+// (an expired token whose refresh just failed would only make the request fail on a second refresh)
+func (a *Account) IsUsableNow() bool {
+	if !a.IsAvailableForProxy() {
+		return false
+	}
+	return !a.IsExpired() || !a.IsRefreshFailing()
+}
+
+// IsDeleted returns true if the account has been soft-deleted
+func (a *Account) IsDeleted() bool {
+	return a.DeletedAt != nil
+}
+
+// SoftDelete marks the account as deleted, keeping its credentials so it can be restored
+func (a *Account) SoftDelete() {
+	now := time.Now()
+	a.DeletedAt = &now
+	a.UpdatedAt = now
+}
+
+// Restore clears the soft-delete marker
+func (a *Account) Restore() {
+	a.DeletedAt = nil
+	a.UpdatedAt = time.Now()
+}
+
+// IsCoolingDown returns true if the account is new and its cooldown hasn't elapsed yet
+func (a *Account) IsCoolingDown() bool {
+	return a.CooldownUntil != nil && time.Now().Before(*a.CooldownUntil)
+}
+
+// CooldownRemaining returns how long the account stays in its new-account cooldown (0 if not cooling down)
+func (a *Account) CooldownRemaining() time.Duration {
+	if !a.IsCoolingDown() {
+		return 0
+	}
+	return time.Until(*a.CooldownUntil)
+}
+
+// StartCooldown keeps a new account out of the rotation for d
+func (a *Account) StartCooldown(d time.Duration) {
+	until := time.Now().Add(d)
+	a.CooldownUntil = &until
+	a.UpdatedAt = time.Now()
+}
+
+// EndCooldown lets the account into the rotation right away
+func (a *Account) EndCooldown() {
+	a.CooldownUntil = nil
+	a.UpdatedAt = time.Now()
+}
+
 // IsAvailableForProxy returns true if account can be used for proxying
 func (a *Account) IsAvailableForProxy() bool {
+	if a.IsDeleted() || a.IsCoolingDown() {
+		return false
+	}
+	// Nothing will refresh an expired token in manual mode
+	if !a.AutoRefresh && a.IsExpired() {
+		return false
+	}
+
 	switch a.Status {
 	case AccountStatusActive:
 		return true
@@ -96,7 +521,7 @@ func (a *Account) IsAvailableForProxy() bool {
 			return true // Rate limit expired, can be recovered
 		}
 		return false
-	case AccountStatusInvalid, AccountStatusInactive:
+	case AccountStatusInvalid, AccountStatusInactive, AccountStatusConflicted:
 		return false
 	default:
 		return false
@@ -139,3 +564,109 @@ func (a *Account) RecoverFromRateLimit() {
 		a.UpdatedAt = time.Now()
 	}
 }
+
+// UsageWindowEnd returns when the current usage window rolls over (zero if no window started)
+func (a *Account) UsageWindowEnd() time.Time {
+	if a.UsageWindowStart.IsZero() {
+		return time.Time{}
+	}
+	return a.UsageWindowStart.Add(UsageWindow)
+}
+
+// isUsageWindowExpired returns true if there is no active usage window
+func (a *Account) isUsageWindowExpired() bool {
+	return a.UsageWindowStart.IsZero() || !time.Now().Before(a.UsageWindowEnd())
+}
+
+// HasActiveUsageWindow returns true if a usage window is currently running
+func (a *Account) HasActiveUsageWindow() bool {
+	return !a.isUsageWindowExpired()
+}
+
+// usageWindowAlignTolerance is how far a reported window reset may drift before the window is realigned
+const usageWindowAlignTolerance = time.Minute
+
+// AlignUsageWindow realigns the usage window with a reset time reported by Claude API
+// An expired window is replaced by the reported one with empty counters; an active window keeps its counters.
+// Returns true if the window changed.
+func (a *Account) AlignUsageWindow(resetAt time.Time) bool {
+	now := time.Now()
+	if !resetAt.After(now) || resetAt.After(now.Add(UsageWindow)) {
+		return false
+	}
+
+	if a.isUsageWindowExpired() {
+		a.clearWindowUsage()
+	} else {
+		drift := a.UsageWindowEnd().Sub(resetAt)
+		if drift < usageWindowAlignTolerance && drift > -usageWindowAlignTolerance {
+			return false
+		}
+	}
+
+	a.UsageWindowStart = resetAt.Add(-UsageWindow)
+	return true
+}
+
+// CurrentWindowUsage returns requests and tokens consumed in the active usage window
+func (a *Account) CurrentWindowUsage() (int, int) {
+	if a.isUsageWindowExpired() {
+		return 0, 0
+	}
+	return a.WindowRequests, a.WindowTokens
+}
+
+// CurrentWindowCacheUsage returns the uncached input, prompt cache write and prompt cache read tokens consumed
+// in the active usage window
+func (a *Account) CurrentWindowCacheUsage() (int, int, int) {
+	if a.isUsageWindowExpired() {
+		return 0, 0, 0
+	}
+	return a.WindowInputTokens, a.WindowCacheCreationTokens, a.WindowCacheReadTokens
+}
+
+// WindowCacheHitRatio returns the share of the active usage window's input tokens read from the prompt cache
+func (a *Account) WindowCacheHitRatio() float64 {
+	return CacheHitRatio(a.CurrentWindowCacheUsage())
+}
+
+// RecordUsage adds a request and its tokens to the current usage window, starting a new window if needed
+func (a *Account) RecordUsage(usage TokenUsage) {
+	if a.isUsageWindowExpired() {
+		a.UsageWindowStart = time.Now()
+		a.clearWindowUsage()
+	}
+	a.WindowRequests++
+	a.WindowTokens += usage.Total()
+	a.WindowInputTokens += usage.InputTokens
+	a.WindowCacheCreationTokens += usage.CacheCreationTokens
+	a.WindowCacheReadTokens += usage.CacheReadTokens
+}
+
+// clearWindowUsage zeroes the usage window counters
+func (a *Account) clearWindowUsage() {
+	a.WindowRequests = 0
+	a.WindowTokens = 0
+	a.WindowInputTokens = 0
+	a.WindowCacheCreationTokens = 0
+	a.WindowCacheReadTokens = 0
+}
+
+// SetQuota sets the per-window request and token budgets (0 = unlimited)
+func (a *Account) SetQuota(quotaRequests, quotaTokens int) {
+	a.QuotaRequests = quotaRequests
+	a.QuotaTokens = quotaTokens
+	a.UpdatedAt = time.Now()
+}
+
+// IsOverQuota returns true if the account has exhausted its budget for the current usage window
+func (a *Account) IsOverQuota() bool {
+	requests, tokens := a.CurrentWindowUsage()
+	if a.QuotaRequests > 0 && requests >= a.QuotaRequests {
+		return true
+	}
+	if a.QuotaTokens > 0 && tokens >= a.QuotaTokens {
+		return true
+	}
+	return false
+}
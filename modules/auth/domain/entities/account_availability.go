@@ -0,0 +1,192 @@
+package entities
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AvailabilityWindow is a weekly time range in which an account serves requests (e.g. weekdays 22:00-07:00
+// Europe/Berlin); outside all of its windows the account is skipped without changing its status
+type AvailabilityWindow struct {
+	Days     []string // Weekdays the range starts on (mon, tue, wed, thu, fri, sat, sun; empty = every day)
+	Start    string   // Local start time (HH:MM)
+	End      string   // Local end time (HH:MM); at or before Start, the range ends the next day
+	Timezone string   // IANA time zone of Start and End (empty = UTC)
+}
+
+// availabilityTimeLayout is the layout of AvailabilityWindow start and end times
+const availabilityTimeLayout = "15:04"
+
+// weekdayNames maps the day names of availability windows to weekdays
+var weekdayNames = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// locations caches loaded time zones by name, windows being evaluated on every account selection
+var locations sync.Map
+
+// ValidateAvailability checks the days, times and time zones of availability windows
+func ValidateAvailability(windows []AvailabilityWindow) error {
+	for i, window := range windows {
+		window = window.normalized()
+		for _, day := range window.Days {
+			if _, ok := weekdayNames[day]; !ok {
+				return fmt.Errorf("availability[%d]: invalid day %q (expected mon, tue, wed, thu, fri, sat or sun)", i, day)
+			}
+		}
+		for _, clock := range []string{window.Start, window.End} {
+			if _, err := time.Parse(availabilityTimeLayout, clock); err != nil {
+				return fmt.Errorf("availability[%d]: invalid time %q (expected HH:MM)", i, clock)
+			}
+		}
+		if _, err := loadLocation(window.Timezone); err != nil {
+			return fmt.Errorf("availability[%d]: invalid timezone %q: %w", i, window.Timezone, err)
+		}
+	}
+	return nil
+}
+
+// SetAvailability replaces the account's availability windows (empty = always available)
+func (a *Account) SetAvailability(windows []AvailabilityWindow) {
+	a.Availability = nil
+	for _, window := range windows {
+		a.Availability = append(a.Availability, window.normalized())
+	}
+	a.UpdatedAt = time.Now()
+}
+
+// IsWithinAvailability returns true if the account has no availability windows or t falls in one of them
+func (a *Account) IsWithinAvailability(t time.Time) bool {
+	if len(a.Availability) == 0 {
+		return true
+	}
+	for _, window := range a.Availability {
+		if window.contains(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// NextAvailabilityChange returns when the account next enters or leaves its availability windows after t
+// (false without windows, or when they cover every hour of the week)
+func (a *Account) NextAvailabilityChange(t time.Time) (time.Time, bool) {
+	if len(a.Availability) == 0 {
+		return time.Time{}, false
+	}
+
+	// Availability can only change where a range starts or ends; a week ahead covers every range
+	var boundaries []time.Time
+	for _, window := range a.Availability {
+		for _, r := range window.ranges(t, -1, 8) {
+			boundaries = append(boundaries, r[0], r[1])
+		}
+	}
+	slices.SortFunc(boundaries, func(x, y time.Time) int { return x.Compare(y) })
+
+	current := a.IsWithinAvailability(t)
+	for _, boundary := range boundaries {
+		if boundary.After(t) && a.IsWithinAvailability(boundary) != current {
+			return boundary, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// normalized returns the window with lowercased day names and trimmed fields
+func (w AvailabilityWindow) normalized() AvailabilityWindow {
+	days := make([]string, 0, len(w.Days))
+	for _, day := range w.Days {
+		days = append(days, strings.ToLower(strings.TrimSpace(day)))
+	}
+	if len(days) == 0 {
+		days = nil
+	}
+	return AvailabilityWindow{
+		Days:     days,
+		Start:    strings.TrimSpace(w.Start),
+		End:      strings.TrimSpace(w.End),
+		Timezone: strings.TrimSpace(w.Timezone),
+	}
+}
+
+// contains returns true if t falls in a range of the window (one starting the same local day or the day before)
+func (w AvailabilityWindow) contains(t time.Time) bool {
+	for _, r := range w.ranges(t, -1, 0) {
+		if !t.Before(r[0]) && t.Before(r[1]) {
+			return true
+		}
+	}
+	return false
+}
+
+// ranges returns the [start, end) ranges of the window starting on the local days from..to relative to t's
+// local day; an invalid window (only possible in hand-edited data files) has none
+func (w AvailabilityWindow) ranges(t time.Time, from, to int) [][2]time.Time {
+	loc, err := loadLocation(w.Timezone)
+	if err != nil {
+		return nil
+	}
+	start, err := time.Parse(availabilityTimeLayout, w.Start)
+	if err != nil {
+		return nil
+	}
+	end, err := time.Parse(availabilityTimeLayout, w.End)
+	if err != nil {
+		return nil
+	}
+
+	local := t.In(loc)
+	var result [][2]time.Time
+	for offset := from; offset <= to; offset++ {
+		day := time.Date(local.Year(), local.Month(), local.Day()+offset, 0, 0, 0, 0, loc)
+		if !w.runsOn(day.Weekday()) {
+			continue
+		}
+		rangeStart := time.Date(day.Year(), day.Month(), day.Day(), start.Hour(), start.Minute(), 0, 0, loc)
+		rangeEnd := time.Date(day.Year(), day.Month(), day.Day(), end.Hour(), end.Minute(), 0, 0, loc)
+		if !rangeEnd.After(rangeStart) {
+			rangeEnd = time.Date(day.Year(), day.Month(), day.Day()+1, end.Hour(), end.Minute(), 0, 0, loc)
+		}
+		result = append(result, [2]time.Time{rangeStart, rangeEnd})
+	}
+	return result
+}
+
+// runsOn returns true if the window has a range starting on the weekday
+func (w AvailabilityWindow) runsOn(weekday time.Weekday) bool {
+	if len(w.Days) == 0 {
+		return true
+	}
+	for _, day := range w.Days {
+		if named, ok := weekdayNames[day]; ok && named == weekday {
+			return true
+		}
+	}
+	return false
+}
+
+// loadLocation returns the time zone named name (empty = UTC), cached after the first load
+func loadLocation(name string) (*time.Location, error) {
+	if name == "" {
+		return time.UTC, nil
+	}
+	if loc, ok := locations.Load(name); ok {
+		return loc.(*time.Location), nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, err
+	}
+	locations.Store(name, loc)
+	return loc, nil
+}
@@ -0,0 +1,131 @@
+package entities
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// accountStatusCase is a status dimension of the selection matrix
+type accountStatusCase struct {
+	name      string
+	available bool // Available for proxying, whatever its token
+	apply     func(a *Account, now time.Time)
+}
+
+// accountExpiryCase is an access token dimension of the selection matrix
+type accountExpiryCase struct {
+	name    string
+	expired bool
+	apply   func(a *Account, now time.Time)
+}
+
+// accountErrorCase is a last refresh error dimension of the selection matrix
+type accountErrorCase struct {
+	name    string
+	failing bool // The last refresh failed less than RefreshRetryDelay ago
+	apply   func(a *Account, now time.Time)
+}
+
+// accountMatrixCell is an account of the selection matrix and whether it can serve a request now
+type accountMatrixCell struct {
+	name    string
+	account *Account
+	usable  bool
+}
+
+// accountSelectionMatrix enumerates the status × access token expiry × last refresh error cells
+func accountSelectionMatrix(now time.Time) []accountMatrixCell {
+	statuses := []accountStatusCase{
+		{"active", true, func(a *Account, now time.Time) {}},
+		{"inactive", false, func(a *Account, now time.Time) { a.Status = AccountStatusInactive }},
+		{"invalid", false, func(a *Account, now time.Time) { a.MarkInvalid("invalid_grant") }},
+		{"conflicted", false, func(a *Account, now time.Time) { a.Status = AccountStatusConflicted }},
+		{"rate limited", false, func(a *Account, now time.Time) { a.MarkRateLimited(now.Add(time.Hour), "429") }},
+		{"rate limit expired", true, func(a *Account, now time.Time) {
+			a.MarkRateLimited(now.Add(-time.Minute), "429")
+		}},
+		{"rate limited without expiry", false, func(a *Account, now time.Time) {
+			a.Status = AccountStatusRateLimited
+		}},
+		{"soft-deleted", false, func(a *Account, now time.Time) { a.DeletedAt = &now }},
+		{"cooling down", false, func(a *Account, now time.Time) { a.StartCooldown(time.Hour) }},
+	}
+	expiries := []accountExpiryCase{
+		{"fresh token", false, func(a *Account, now time.Time) { a.ExpiresAt = now.Add(time.Hour) }},
+		{"token needing refresh", false, func(a *Account, now time.Time) { a.ExpiresAt = now.Add(30 * time.Second) }},
+		{"expired token", true, func(a *Account, now time.Time) { a.ExpiresAt = now.Add(-time.Hour) }},
+		{"API key", false, func(a *Account, now time.Time) {
+			a.AuthType = AccountAuthAPIKey
+			a.APIKey = "sk-ant-api03-test"
+			a.ExpiresAt = time.Time{}
+		}},
+	}
+	lastErrors := []accountErrorCase{
+		{"no refresh error", false, func(a *Account, now time.Time) {}},
+		{"refresh failed just now", true, func(a *Account, now time.Time) { a.RecordRefreshFailure("timeout") }},
+		{"refresh failed long ago", false, func(a *Account, now time.Time) {
+			a.RecordRefreshFailure("timeout")
+			failedAt := now.Add(-2 * RefreshRetryDelay)
+			a.RefreshFailedAt = &failedAt
+		}},
+		{"error message only", false, func(a *Account, now time.Time) { a.UpdateRefreshError("timeout") }},
+	}
+
+	var cells []accountMatrixCell
+	for _, status := range statuses {
+		for _, expiry := range expiries {
+			for _, lastError := range lastErrors {
+				account := &Account{
+					ID:          fmt.Sprintf("acc_%d", len(cells)),
+					Status:      AccountStatusActive,
+					AccessToken: "sk-ant-oat01-test",
+					AutoRefresh: true,
+				}
+				expiry.apply(account, now)
+				lastError.apply(account, now)
+				status.apply(account, now)
+				cells = append(cells, accountMatrixCell{
+					name:    status.name + ", " + expiry.name + ", " + lastError.name,
+					account: account,
+					usable:  status.available && !(expiry.expired && lastError.failing),
+				})
+			}
+		}
+	}
+	return cells
+}
+
+func TestAccountIsUsableNow(t *testing.T) {
+	for _, cell := range accountSelectionMatrix(time.Now()) {
+		if got := cell.account.IsUsableNow(); got != cell.usable {
+			t.Errorf("%s: IsUsableNow() = %v, want %v", cell.name, got, cell.usable)
+		}
+	}
+}
+
+func TestAccountManualModeExpiredToken(t *testing.T) {
+	account := &Account{Status: AccountStatusActive, ExpiresAt: time.Now().Add(-time.Minute)}
+	if account.IsAvailableForProxy() || account.IsUsableNow() {
+		t.Error("an expired account in manual mode is selectable, yet nothing will refresh it")
+	}
+
+	account.ExpiresAt = time.Now().Add(time.Hour)
+	if !account.IsUsableNow() {
+		t.Error("an unexpired account in manual mode is not selectable")
+	}
+}
+
+func TestAccountUpdateTokensClearsRefreshFailure(t *testing.T) {
+	account := &Account{Status: AccountStatusActive, AutoRefresh: true, ExpiresAt: time.Now().Add(-time.Hour)}
+	account.RecordRefreshFailure("timeout")
+	if account.IsUsableNow() {
+		t.Fatal("an expired account whose refresh just failed is selectable")
+	}
+
+	account.UpdateTokens("access", "refresh", 3600)
+	if !account.IsUsableNow() || account.RefreshFailures != 0 || account.LastRefreshError != "" {
+		t.Errorf("after a refresh: usable %v, failures %d, error %q", account.IsUsableNow(),
+			account.RefreshFailures, account.LastRefreshError)
+	}
+}
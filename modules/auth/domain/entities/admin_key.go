@@ -0,0 +1,50 @@
+package entities
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"time"
+)
+
+// AdminKey is a managed admin API key; only its SHA-256 hash is stored
+type AdminKey struct {
+	ID        string
+	KeyHash   string // Hex-encoded SHA-256 of the key
+	Hint      string // Last characters of the key, for identification
+	CreatedAt time.Time
+	ExpiresAt *time.Time // nil = never expires (set when the key is rotated out)
+}
+
+// HashAdminKey returns the hex-encoded SHA-256 hash of a key
+func HashAdminKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// AdminKeyHint returns the last 4 characters of a key for display
+func AdminKeyHint(key string) string {
+	if len(key) <= 8 {
+		return "***"
+	}
+	return "***" + key[len(key)-4:]
+}
+
+// Matches compares a key against the stored hash in constant time
+func (k *AdminKey) Matches(key string) bool {
+	hash := HashAdminKey(key)
+	return subtle.ConstantTimeCompare([]byte(hash), []byte(k.KeyHash)) == 1
+}
+
+// IsExpired returns true if the key's grace period has ended
+func (k *AdminKey) IsExpired(now time.Time) bool {
+	return k.ExpiresAt != nil && !now.Before(*k.ExpiresAt)
+}
+
+// ExpireAt schedules the key to stop working at t (an earlier existing expiry is kept)
+func (k *AdminKey) ExpireAt(t time.Time) {
+	if k.ExpiresAt != nil && k.ExpiresAt.Before(t) {
+		return
+	}
+	k.ExpiresAt = &t
+}
@@ -0,0 +1,49 @@
+package entities
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Backup represents a timestamped archive of the data folder
+type Backup struct {
+	Name      string
+	Path      string
+	Size      int64
+	CreatedAt time.Time
+}
+
+// StateBundleManifest describes the contents of an encrypted state bundle (disaster recovery export)
+type StateBundleManifest struct {
+	FormatVersion int                        `json:"format_version"`
+	AppVersion    string                     `json:"app_version"`
+	CreatedAt     time.Time                  `json:"created_at"`
+	Files         map[string]StateBundleFile `json:"files"` // Data file name -> description
+}
+
+// StateBundleFile describes one data file of a state bundle
+type StateBundleFile struct {
+	SchemaVersion int    `json:"schema_version"`
+	Size          int64  `json:"size"`
+	SHA256        string `json:"sha256"`
+}
+
+// ETag identifies the contents of the bundle's data files, independently of when it was created or encrypted
+// Two exports of unchanged data files have the same ETag.
+func (m *StateBundleManifest) ETag() string {
+	names := make([]string, 0, len(m.Files))
+	for name := range m.Files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	h := sha256.New()
+	for _, name := range names {
+		file := m.Files[name]
+		_, _ = fmt.Fprintf(h, "%s %d %s\n", name, file.SchemaVersion, file.SHA256)
+	}
+	return hex.EncodeToString(h.Sum(nil))[:32]
+}
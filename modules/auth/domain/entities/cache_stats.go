@@ -0,0 +1,20 @@
+package entities
+
+// CacheStats describes the size of an in-memory cache repository
+type CacheStats struct {
+	Entries     int
+	ApproxBytes int64 // Estimated from the entries' contents (strings, slices, maps), not measured
+	SoftLimit   int   // Entries above which a warning is logged (0 = none)
+}
+
+// OverSoftLimit returns true if the cache holds more entries than its soft limit
+func (s CacheStats) OverSoftLimit() bool {
+	return s.SoftLimit > 0 && s.Entries > s.SoftLimit
+}
+
+// TokenFilter selects tokens in TokenCacheRepository.Query (empty fields match every token)
+type TokenFilter struct {
+	Role   TokenRole
+	Status TokenStatus
+	Search string // Name or key prefix substring (case-insensitive), or a full key
+}
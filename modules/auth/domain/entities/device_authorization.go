@@ -0,0 +1,115 @@
+package entities
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+)
+
+// DeviceAuthorization is a pending OAuth authorization started for a headless server
+// The admin opens AuthorizeURL in any browser and pastes the resulting code#state back, while a CLI polls with
+// the polling code until the account exists. Only the SHA-256 hash of the polling code is stored; the PKCE
+// verifier is stored as is, so the flow can be completed after a restart.
+type DeviceAuthorization struct {
+	ID           string
+	PollCodeHash string // Hex-encoded SHA-256 of the polling code
+	State        string // OAuth state carried by the authorization URL (and by the pasted code#state)
+	CodeVerifier string // PKCE verifier the authorization code is exchanged with
+	AuthorizeURL string
+	AccountName  string // Name of the account the authorization creates
+	OrgID        string // Organization the authorization is pinned to (empty = the user's choice)
+	CreatedBy    string // Admin identity that started the flow (e.g. "admin_key:<id>", "token:<id>")
+	CreatedAt    time.Time
+	ExpiresAt    time.Time
+	CompletedAt  *time.Time // Set once an authorization code was exchanged
+	AccountID    string     // Account created (empty while its organization is being selected)
+	SelectionID  string     // Pending organization selection, until an organization is picked
+	LastError    string     // Why the last code exchange failed (the authorization stays pending)
+}
+
+// Device authorization statuses
+const (
+	DeviceAuthStatusPending      = "pending"
+	DeviceAuthStatusSelectingOrg = "requires_org_selection"
+	DeviceAuthStatusCompleted    = "completed"
+	DeviceAuthStatusExpired      = "expired"
+)
+
+const (
+	// DevicePollCodeAlphabet are the characters of polling codes: consonants only, so codes are easy to read
+	// out and never spell words
+	DevicePollCodeAlphabet = "BCDFGHJKLMNPQRSTVWXZ"
+
+	// DevicePollCodeLength is the number of characters of a polling code, shown in two groups ("BCDF-GHJK")
+	DevicePollCodeLength = 8
+)
+
+// Errors returned for device authorizations that can't be polled or completed
+var (
+	ErrDeviceAuthNotFound  = errors.New("device authorization not found")
+	ErrDeviceAuthCompleted = errors.New("device authorization has already been completed")
+	ErrDeviceAuthExpired   = errors.New("device authorization has expired")
+	ErrDeviceAuthBusy      = errors.New("device authorization is being completed")
+)
+
+// NormalizeDevicePollCode uppercases a polling code and drops separators and spaces ("bcdf-ghjk" -> "BCDFGHJK")
+func NormalizeDevicePollCode(code string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' {
+			return -1
+		}
+		return r
+	}, strings.ToUpper(code))
+}
+
+// FormatDevicePollCode groups a normalized polling code for display ("BCDFGHJK" -> "BCDF-GHJK")
+func FormatDevicePollCode(code string) string {
+	if len(code) != DevicePollCodeLength {
+		return code
+	}
+	return code[:DevicePollCodeLength/2] + "-" + code[DevicePollCodeLength/2:]
+}
+
+// HashDevicePollCode returns the hex-encoded SHA-256 hash of a polling code (normalized first)
+func HashDevicePollCode(code string) string {
+	sum := sha256.Sum256([]byte(NormalizeDevicePollCode(code)))
+	return hex.EncodeToString(sum[:])
+}
+
+// Status returns the authorization status at now
+func (d *DeviceAuthorization) Status(now time.Time) string {
+	switch {
+	case d.CompletedAt != nil && d.AccountID == "":
+		return DeviceAuthStatusSelectingOrg
+	case d.CompletedAt != nil:
+		return DeviceAuthStatusCompleted
+	case !now.Before(d.ExpiresAt):
+		return DeviceAuthStatusExpired
+	default:
+		return DeviceAuthStatusPending
+	}
+}
+
+// Usable returns nil if an authorization code can still be exchanged for the authorization at now, or why not
+func (d *DeviceAuthorization) Usable(now time.Time) error {
+	switch d.Status(now) {
+	case DeviceAuthStatusCompleted, DeviceAuthStatusSelectingOrg:
+		return ErrDeviceAuthCompleted
+	case DeviceAuthStatusExpired:
+		return ErrDeviceAuthExpired
+	default:
+		return nil
+	}
+}
+
+// Clone returns a copy of the authorization that shares no state with the original
+func (d *DeviceAuthorization) Clone() *DeviceAuthorization {
+	copied := *d
+	if d.CompletedAt != nil {
+		completedAt := *d.CompletedAt
+		copied.CompletedAt = &completedAt
+	}
+	return &copied
+}
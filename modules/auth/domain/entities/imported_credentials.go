@@ -0,0 +1,30 @@
+package entities
+
+import "errors"
+
+// ErrCredentialsAlreadyImported is returned when imported credentials belong to an existing account
+var ErrCredentialsAlreadyImported = errors.New("refresh token is already associated with an existing account")
+
+// ErrAutoRefreshDisabled is returned when an account in manual mode would need its tokens refreshed
+var ErrAutoRefreshDisabled = errors.New("auto-refresh is disabled for this account")
+
+// ErrAPIKeyAlreadyImported is returned when an API key is already used by another account
+var ErrAPIKeyAlreadyImported = errors.New("API key is already associated with an existing account")
+
+// ErrAPIKeyAccount is returned when an OAuth-only operation (refresh, tokens, auto-refresh) targets an account
+// authenticating with an API key
+var ErrAPIKeyAccount = errors.New("account authenticates with an API key, not OAuth tokens")
+
+// ImportedCredentials are OAuth credentials taken from an existing client login (e.g. Claude Code)
+// Only the refresh token is kept: importing always refreshes, which also validates it
+type ImportedCredentials struct {
+	RefreshToken     string
+	OrganizationUUID string // Empty if the source did not include an organization
+}
+
+// ManualCredentials are OAuth tokens pasted in by an administrator (e.g. obtained with another tool)
+type ManualCredentials struct {
+	AccessToken  string // Empty if only the refresh token is known: the account refreshes on first use
+	RefreshToken string
+	ExpiresIn    int // Seconds until the access token expires
+}
@@ -0,0 +1,78 @@
+package entities
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+)
+
+// Invite lets someone without admin access add their Claude account through a one-time OAuth link
+// Only the SHA-256 hash of the invite token (the secret part of the link) is stored
+type Invite struct {
+	ID          string
+	TokenHash   string // Hex-encoded SHA-256 of the invite token
+	AccountName string // Name of the account the invite creates
+	OrgID       string // Organization the authorization is pinned to (empty = the user's choice)
+	CreatedBy   string // Admin identity that created the invite (e.g. "admin_key:<id>", "token:<id>")
+	CreatedAt   time.Time
+	ExpiresAt   time.Time
+	UsedAt      *time.Time // Set once an authorization code was exchanged through the invite
+	AccountID   string     // Account created through the invite (empty while its organization is being selected)
+	SelectionID string     // Pending organization selection of a used invite, until an organization is picked
+}
+
+// Invite statuses
+const (
+	InviteStatusPending = "pending"
+	InviteStatusUsed    = "used"
+	InviteStatusExpired = "expired"
+)
+
+// Errors returned for invite links that can't be used
+var (
+	ErrInviteNotFound = errors.New("invite not found")
+	ErrInviteUsed     = errors.New("invite has already been used")
+	ErrInviteExpired  = errors.New("invite has expired")
+	ErrInviteBusy     = errors.New("invite is being redeemed")
+)
+
+// HashInviteToken returns the hex-encoded SHA-256 hash of an invite token
+func HashInviteToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// Status returns the invite status at now
+func (i *Invite) Status(now time.Time) string {
+	switch {
+	case i.UsedAt != nil:
+		return InviteStatusUsed
+	case !now.Before(i.ExpiresAt):
+		return InviteStatusExpired
+	default:
+		return InviteStatusPending
+	}
+}
+
+// Usable returns nil if the invite can still be redeemed at now, or why it can't
+func (i *Invite) Usable(now time.Time) error {
+	switch i.Status(now) {
+	case InviteStatusUsed:
+		return ErrInviteUsed
+	case InviteStatusExpired:
+		return ErrInviteExpired
+	default:
+		return nil
+	}
+}
+
+// Clone returns a copy of the invite that shares no state with the original
+func (i *Invite) Clone() *Invite {
+	copied := *i
+	if i.UsedAt != nil {
+		usedAt := *i.UsedAt
+		copied.UsedAt = &usedAt
+	}
+	return &copied
+}
@@ -0,0 +1,60 @@
+package entities
+
+import "time"
+
+// MutationAction is the kind of change an audit entry records
+type MutationAction string
+
+const (
+	MutationActionCreate MutationAction = "create"
+	MutationActionUpdate MutationAction = "update"
+	MutationActionDelete MutationAction = "delete" // Token deletion, or account soft-deletion and purge
+)
+
+// MutationEntityType is the kind of entity an audit entry records a change of
+type MutationEntityType string
+
+const (
+	MutationEntityAccount MutationEntityType = "account"
+	MutationEntityToken   MutationEntityType = "token"
+)
+
+// FieldChange is the value of a field before and after a change (nil when absent, "***" for secrets)
+type FieldChange struct {
+	Old interface{}
+	New interface{}
+}
+
+// MutationAuditEntry records one admin change of an account or a token
+type MutationAuditEntry struct {
+	ID         string
+	Timestamp  time.Time
+	Actor      string // Identity that made the change (e.g. "admin_key:<id>", "token:<id>", "invite:<id>")
+	Action     MutationAction
+	EntityType MutationEntityType
+	EntityID   string
+	Changes    map[string]FieldChange // Changed persisted fields by JSON name (every set field on creation)
+}
+
+// MutationAuditFilter narrows down the audit entries listed; zero values don't filter
+type MutationAuditFilter struct {
+	EntityType MutationEntityType
+	EntityID   string
+	From       time.Time // Entries at or after
+	To         time.Time // Entries before
+	Limit      int       // Newest entries kept (0 = all)
+}
+
+// Matches returns true if the entry passes every filter
+func (f MutationAuditFilter) Matches(entry *MutationAuditEntry) bool {
+	if f.EntityType != "" && entry.EntityType != f.EntityType {
+		return false
+	}
+	if f.EntityID != "" && entry.EntityID != f.EntityID {
+		return false
+	}
+	if !f.From.IsZero() && entry.Timestamp.Before(f.From) {
+		return false
+	}
+	return f.To.IsZero() || entry.Timestamp.Before(f.To)
+}
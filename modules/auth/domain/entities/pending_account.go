@@ -0,0 +1,34 @@
+package entities
+
+import "time"
+
+// PendingAccount holds the result of an OAuth code exchange for a user with several organizations
+// It is finalized into an Account once the caller selects which organization to use
+type PendingAccount struct {
+	ID            string
+	Name          string
+	AccessToken   string
+	RefreshToken  string
+	ExpiresIn     int // Access token lifetime in seconds, as returned by the token exchange
+	Organizations []Organization
+	CreatedAt     time.Time
+	ExpiresAt     time.Time // When the selection window closes
+}
+
+// PendingAccountTTL is how long an organization selection stays open after code exchange
+const PendingAccountTTL = 10 * time.Minute
+
+// IsExpired returns true if the selection window has closed
+func (p *PendingAccount) IsExpired() bool {
+	return time.Now().After(p.ExpiresAt)
+}
+
+// HasOrganization returns true if the organization UUID is one of the discovered organizations
+func (p *PendingAccount) HasOrganization(orgUUID string) bool {
+	for _, org := range p.Organizations {
+		if org.UUID == orgUUID {
+			return true
+		}
+	}
+	return false
+}
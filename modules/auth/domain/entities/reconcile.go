@@ -0,0 +1,14 @@
+package entities
+
+// ReconcileReport describes how a data file was merged into the in-memory cache
+// Records changed in the file since the server last loaded or saved it replace the cached version, unless they
+// changed in the cache too: that conflict keeps the most recently updated version. Records missing from the file
+// are kept and written back by the next save
+type ReconcileReport struct {
+	Changed   bool     // The file was modified by another writer since the server last loaded or saved it
+	Added     []string // IDs only in the file, loaded into the cache
+	Updated   []string // IDs changed in the file, replacing the cached version
+	CacheOnly []string // IDs only in the cache
+	Skipped   []string // IDs whose file version conflicts with another cached record (name, key), left as cached
+	Conflicts []string // IDs changed both in the file and in the cache, resolved by update time
+}
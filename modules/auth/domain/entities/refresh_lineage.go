@@ -0,0 +1,93 @@
+package entities
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+)
+
+// ErrRefreshConflict is returned when a refresh token this proxy received from its own refresh was rejected:
+// another client holding the same account rotated it first
+var ErrRefreshConflict = errors.New("refresh token was rotated by another client using the same account")
+
+// ErrAccountNotConflicted is returned when acknowledging a conflict on an account that has none
+var ErrAccountNotConflicted = errors.New("account has no refresh token conflict")
+
+const (
+	// RefreshLineageSize is how many refresh token fingerprints an account keeps, newest first
+	RefreshLineageSize = 5
+
+	// RefreshConflictWindow is how long after this proxy's own refresh an invalid_grant is blamed on another
+	// client rotating the token rather than on revoked credentials (access tokens last about 8 hours, so a
+	// stale refresh token surfaces at the next refresh)
+	RefreshConflictWindow = 24 * time.Hour
+
+	// refreshFingerprintLength is the number of hex characters of a refresh token's SHA-256 kept as its fingerprint
+	refreshFingerprintLength = 12
+)
+
+// RefreshTokenSource is how an account obtained a refresh token
+type RefreshTokenSource string
+
+const (
+	RefreshTokenSourceOAuth   RefreshTokenSource = "oauth"   // OAuth authorization (account creation)
+	RefreshTokenSourceRefresh RefreshTokenSource = "refresh" // Rotated by this proxy's own token refresh
+	RefreshTokenSourceManual  RefreshTokenSource = "manual"  // Pasted or imported credentials
+)
+
+// RefreshTokenRecord is one refresh token an account held: a fingerprint (never the token), where it came
+// from and when it was adopted
+type RefreshTokenRecord struct {
+	Fingerprint string
+	Source      RefreshTokenSource
+	AdoptedAt   time.Time
+}
+
+// RefreshTokenFingerprint returns the prefix of a refresh token's SHA-256 that identifies it in the lineage
+func RefreshTokenFingerprint(refreshToken string) string {
+	sum := sha256.Sum256([]byte(refreshToken))
+	return hex.EncodeToString(sum[:])[:refreshFingerprintLength]
+}
+
+// RecordRefreshToken adds the account's current refresh token to its lineage, keeping the newest
+// RefreshLineageSize records (a token already at the head is not recorded twice)
+func (a *Account) RecordRefreshToken(source RefreshTokenSource) {
+	fingerprint := RefreshTokenFingerprint(a.RefreshToken)
+	if len(a.RefreshLineage) > 0 && a.RefreshLineage[0].Fingerprint == fingerprint {
+		return
+	}
+
+	record := RefreshTokenRecord{Fingerprint: fingerprint, Source: source, AdoptedAt: time.Now()}
+	a.RefreshLineage = append([]RefreshTokenRecord{record}, a.RefreshLineage...)
+	if len(a.RefreshLineage) > RefreshLineageSize {
+		a.RefreshLineage = a.RefreshLineage[:RefreshLineageSize]
+	}
+}
+
+// RotatedHereRecently returns true if the account's current refresh token came from this proxy's own refresh
+// less than RefreshConflictWindow ago, so a rejection of it points to another client rotating it
+func (a *Account) RotatedHereRecently() bool {
+	if len(a.RefreshLineage) == 0 {
+		return false
+	}
+	head := a.RefreshLineage[0]
+	return head.Source == RefreshTokenSourceRefresh &&
+		head.Fingerprint == RefreshTokenFingerprint(a.RefreshToken) &&
+		time.Since(head.AdoptedAt) < RefreshConflictWindow
+}
+
+// MarkConflicted takes the account out of the rotation after another client rotated its refresh token;
+// only fresh credentials bring it back
+func (a *Account) MarkConflicted(errMsg string) {
+	now := time.Now()
+	a.Status = AccountStatusConflicted
+	a.ConflictDetectedAt = &now
+	a.LastRefreshError = errMsg
+	a.UpdatedAt = now
+}
+
+// IsConflicted returns true if another client was detected rotating the account's refresh token
+func (a *Account) IsConflicted() bool {
+	return a.Status == AccountStatusConflicted
+}
@@ -2,25 +2,71 @@ package entities
 
 import (
 	"fmt"
+	"net"
+	"strings"
 	"time"
 )
 
 // Session represents an active API session (tracks concurrent requests per client)
 type Session struct {
-	ID          string    // Unique session identifier (UUID)
-	TokenID     string    // API token used for this session
-	UserAgent   string    // User agent string
-	IPAddress   string    // Client IP address
-	CreatedAt   time.Time // When session was created
-	LastSeenAt  time.Time // Last activity timestamp
-	ExpiresAt   time.Time // When session expires
-	IsActive    bool      // Whether session is currently active
-	RequestPath string    // Last request path (for debugging)
-}
-
-// IsExpired checks if the session has expired
+	ID         string    // Unique session identifier (UUID)
+	TokenID    string    // API token used for this session
+	TokenRole  TokenRole // Role of the token when the session was created (selects the TTLs)
+	UserAgent  string    // User agent string
+	IPAddress  string    // Client IP address
+	CreatedAt  time.Time // When session was created
+	LastSeenAt time.Time // Last activity timestamp
+	ExpiresAt  time.Time // Idle expiry, slides forward on activity (never past HardExpiresAt)
+	// HardExpiresAt is the absolute end of the session, fixed at creation (zero = no maximum lifetime)
+	HardExpiresAt time.Time
+	IsActive      bool   // Whether session is currently active
+	RequestPath   string // Last request path (for debugging)
+}
+
+// SessionCounts summarizes sessions by state
+type SessionCounts struct {
+	Total   int // Every session in storage
+	Active  int // Not revoked and not expired
+	Expired int // Past their expiry (revoked or not)
+}
+
+// IsLive returns true if the session is active and not expired, so it counts toward limits
+func (s *Session) IsLive() bool {
+	return s.IsActive && !s.IsExpired()
+}
+
+// SessionFingerprint identifies the client a session belongs to: its token, IP address (port ignored) and
+// User-Agent (case-insensitive); requests with the same fingerprint reuse a live session
+func SessionFingerprint(tokenID, ipAddress, userAgent string) string {
+	if host, _, err := net.SplitHostPort(ipAddress); err == nil {
+		ipAddress = host
+	}
+	return tokenID + "\x00" + ipAddress + "\x00" + strings.ToLower(userAgent)
+}
+
+// Fingerprint returns the session's SessionFingerprint
+func (s *Session) Fingerprint() string {
+	return SessionFingerprint(s.TokenID, s.IPAddress, s.UserAgent)
+}
+
+// Clone returns a copy of the session that shares no state with the original
+func (s *Session) Clone() *Session {
+	copied := *s
+	return &copied
+}
+
+// IsExpired checks if the session has expired, by idle timeout or maximum lifetime
 func (s *Session) IsExpired() bool {
-	return time.Now().After(s.ExpiresAt)
+	now := time.Now()
+	return now.After(s.ExpiresAt) || (!s.HardExpiresAt.IsZero() && now.After(s.HardExpiresAt))
+}
+
+// EndedAt returns when the session stopped counting: its expiry, or its last activity once revoked
+func (s *Session) EndedAt() time.Time {
+	if !s.IsActive && s.LastSeenAt.Before(s.ExpiresAt) {
+		return s.LastSeenAt
+	}
+	return s.ExpiresAt
 }
 
 // UpdateLastSeen updates the last seen timestamp
@@ -28,10 +74,14 @@ func (s *Session) UpdateLastSeen() {
 	s.LastSeenAt = time.Now()
 }
 
-// Refresh extends the session expiration
+// Refresh extends the session's idle expiry by ttl, capped at its maximum lifetime
 func (s *Session) Refresh(ttl time.Duration) {
-	s.ExpiresAt = time.Now().Add(ttl)
-	s.LastSeenAt = time.Now()
+	now := time.Now()
+	s.ExpiresAt = now.Add(ttl)
+	if !s.HardExpiresAt.IsZero() && s.ExpiresAt.After(s.HardExpiresAt) {
+		s.ExpiresAt = s.HardExpiresAt
+	}
+	s.LastSeenAt = now
 }
 
 // Deactivate marks the session as inactive
@@ -42,31 +92,51 @@ func (s *Session) Deactivate() {
 // ToMap converts session to map for Redis storage
 func (s *Session) ToMap() map[string]interface{} {
 	return map[string]interface{}{
-		"id":           s.ID,
-		"token_id":     s.TokenID,
-		"user_agent":   s.UserAgent,
-		"ip_address":   s.IPAddress,
-		"created_at":   s.CreatedAt.Unix(),
-		"last_seen_at": s.LastSeenAt.Unix(),
-		"expires_at":   s.ExpiresAt.Unix(),
-		"is_active":    s.IsActive,
-		"request_path": s.RequestPath,
+		"id":              s.ID,
+		"token_id":        s.TokenID,
+		"token_role":      string(s.TokenRole),
+		"user_agent":      s.UserAgent,
+		"ip_address":      s.IPAddress,
+		"created_at":      s.CreatedAt.Unix(),
+		"last_seen_at":    s.LastSeenAt.Unix(),
+		"expires_at":      s.ExpiresAt.Unix(),
+		"hard_expires_at": hardExpiresAtUnix(s.HardExpiresAt),
+		"is_active":       s.IsActive,
+		"request_path":    s.RequestPath,
 	}
 }
 
 // SessionFromMap creates a session from Redis map
 func SessionFromMap(data map[string]string) *Session {
 	return &Session{
-		ID:          data["id"],
-		TokenID:     data["token_id"],
-		UserAgent:   data["user_agent"],
-		IPAddress:   data["ip_address"],
-		CreatedAt:   parseUnixTime(data["created_at"]),
-		LastSeenAt:  parseUnixTime(data["last_seen_at"]),
-		ExpiresAt:   parseUnixTime(data["expires_at"]),
-		IsActive:    data["is_active"] == "true",
-		RequestPath: data["request_path"],
+		ID:            data["id"],
+		TokenID:       data["token_id"],
+		TokenRole:     TokenRole(data["token_role"]),
+		UserAgent:     data["user_agent"],
+		IPAddress:     data["ip_address"],
+		CreatedAt:     parseUnixTime(data["created_at"]),
+		LastSeenAt:    parseUnixTime(data["last_seen_at"]),
+		ExpiresAt:     parseUnixTime(data["expires_at"]),
+		HardExpiresAt: parseOptionalUnixTime(data["hard_expires_at"]),
+		IsActive:      data["is_active"] == "true",
+		RequestPath:   data["request_path"],
+	}
+}
+
+// hardExpiresAtUnix returns the maximum lifetime as a Unix timestamp, 0 when there is none
+func hardExpiresAtUnix(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
+
+// parseOptionalUnixTime parses a Unix timestamp, returning the zero time for 0 or an empty value
+func parseOptionalUnixTime(s string) time.Time {
+	if s == "" || s == "0" {
+		return time.Time{}
 	}
+	return parseUnixTime(s)
 }
 
 func parseUnixTime(s string) time.Time {
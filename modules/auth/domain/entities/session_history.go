@@ -0,0 +1,71 @@
+package entities
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+	"time"
+)
+
+const (
+	// maxHistoryUserAgentLength bounds the User-Agent kept in a session history record (the hash covers all of it)
+	maxHistoryUserAgentLength = 200
+
+	// historyUserAgentHashLength is the number of hex characters of the User-Agent hash kept (64 bits)
+	historyUserAgentHashLength = 16
+)
+
+// SessionHistoryRecord is the compact trace of a session creation kept for security reviews
+type SessionHistoryRecord struct {
+	SessionID     string
+	TokenID       string
+	IPAddress     string // Full client IP, or its /24 (IPv6: /48) network with session.history_mask_ips
+	UserAgentHash string // Hex prefix of the SHA-256 of the full User-Agent, to group clients
+	UserAgent     string // User-Agent truncated to maxHistoryUserAgentLength characters
+	RequestPath   string // Path of the request that created the session
+	CreatedAt     time.Time
+}
+
+// NewSessionHistoryRecord creates the history record of a new session, masking its IP address when maskIP is set
+func NewSessionHistoryRecord(session *Session, maskIP bool) *SessionHistoryRecord {
+	sum := sha256.Sum256([]byte(session.UserAgent))
+	userAgent := []rune(session.UserAgent)
+	if len(userAgent) > maxHistoryUserAgentLength {
+		userAgent = userAgent[:maxHistoryUserAgentLength]
+	}
+
+	ipAddress := session.IPAddress
+	if maskIP {
+		ipAddress = MaskIPAddress(ipAddress)
+	}
+	return &SessionHistoryRecord{
+		SessionID:     session.ID,
+		TokenID:       session.TokenID,
+		IPAddress:     ipAddress,
+		UserAgentHash: hex.EncodeToString(sum[:])[:historyUserAgentHashLength],
+		UserAgent:     string(userAgent),
+		RequestPath:   session.RequestPath,
+		CreatedAt:     session.CreatedAt,
+	}
+}
+
+// MaskIPAddress returns the /24 network of an IPv4 address or the /48 network of an IPv6 one in CIDR notation
+// (e.g. 203.0.113.0/24); anything that isn't an IP address is dropped
+func MaskIPAddress(ipAddress string) string {
+	ip := net.ParseIP(ipAddress)
+	if ip == nil {
+		return ""
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		network := net.IPNet{IP: ip4.Mask(net.CIDRMask(24, 32)), Mask: net.CIDRMask(24, 32)}
+		return network.String()
+	}
+	network := net.IPNet{IP: ip.Mask(net.CIDRMask(48, 128)), Mask: net.CIDRMask(48, 128)}
+	return network.String()
+}
+
+// SessionHistoryMonth returns the first instant (UTC) of the month a time falls in, which names its history file
+func SessionHistoryMonth(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,33 @@
+package entities
+
+import (
+	"errors"
+	"time"
+)
+
+// MirrorResult counts the changes made to a cache when replacing its records with a primary instance's
+type MirrorResult struct {
+	Added   int // Records only on the primary, added to the cache
+	Updated int // Records on both sides, replaced by the primary's version
+	Removed int // Records no longer on the primary, removed from the cache
+	Skipped int // Records the cache refused (e.g. a key held by another record), left for the next pull
+}
+
+// StandbyStatus describes a warm standby instance pulling its state from a primary
+type StandbyStatus struct {
+	Enabled       bool       // standby.enabled is set (stays true after promotion)
+	Standby       bool       // The instance still follows the primary and refuses proxy traffic
+	PrimaryURL    string     // Base URL of the primary instance
+	LastSyncAt    *time.Time // Last pull that reached the primary (data applied or unchanged)
+	LastChangeAt  *time.Time // Last pull that applied changed data
+	LastAttemptAt *time.Time
+	LastError     string // Error of the last pull ("" if it succeeded)
+	ETag          string // ETag of the primary's data files as last applied
+	Accounts      int    // Records held after the last applied pull
+	Tokens        int
+	Sessions      int
+	PromotedAt    *time.Time
+}
+
+// ErrNotStandby is returned when promoting an instance that is not (or no longer) a standby
+var ErrNotStandby = errors.New("instance is not a standby")
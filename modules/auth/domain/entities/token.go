@@ -1,18 +1,61 @@
 package entities
 
-import "time"
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"time"
+)
 
 // Token represents an API token for authentication
 type Token struct {
-	ID         string
-	Name       string
-	Key        string
+	ID   string
+	Name string
+	// Key is the cleartext key, only known right after the key is set (creation or rotation);
+	// tokens loaded from storage carry just KeyHash and KeyPrefix
+	Key string
+	// KeyHash is the hex SHA-256 of the key, used to look up presented keys
+	KeyHash string
+	// KeyPrefix is the start of the key kept in cleartext for display
+	KeyPrefix  string
 	Status     TokenStatus
 	Role       TokenRole
 	CreatedAt  time.Time
 	UpdatedAt  time.Time
 	UsageCount int
 	LastUsedAt *time.Time
+	// AllowedPaths lists extra proxy path patterns permitted for this token on top of the global allow-list
+	AllowedPaths []string
+	// MaxSessions overrides the configured per-token concurrent session limit (0 uses the default)
+	MaxSessions int
+	// UsageSummary appends a proxy_usage event with the request's token usage to streaming responses
+	UsageSummary bool
+	// ExternalUserIDHeader names a request header (e.g. X-End-User) whose value becomes metadata.user_id
+	// of message requests that don't set one
+	ExternalUserIDHeader string
+	// CaptureFailures keeps the envelopes of the token's last failed requests in memory, for replay
+	CaptureFailures bool
+	// CreatedBy and UpdatedBy are the identities (e.g. "admin_key:<id>", "token:<id>") that created the token
+	// and made its last admin change; empty if unknown
+	CreatedBy string
+	UpdatedBy string
+}
+
+// tokenKeyPrefixLength is how many leading key characters are kept for display ("sk-proxy-" + 6 hex chars)
+const tokenKeyPrefixLength = 15
+
+// HashTokenKey returns the hex SHA-256 of a token key
+// Keys are high-entropy random strings, so an unsalted fast hash is enough
+func HashTokenKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// TokenKeyPrefix returns the display prefix of a token key, at most a third of the key so short
+// custom keys are not revealed
+func TokenKeyPrefix(key string) string {
+	n := min(tokenKeyPrefixLength, len(key)/3)
+	return key[:n]
 }
 
 // TokenStatus represents the status of a token
@@ -28,10 +71,39 @@ const (
 type TokenRole string
 
 const (
-	TokenRoleUser  TokenRole = "user"  // Regular API access
-	TokenRoleAdmin TokenRole = "admin" // Admin UI access
+	TokenRoleUser   TokenRole = "user"   // Regular API access
+	TokenRoleAdmin  TokenRole = "admin"  // Admin UI access
+	TokenRoleViewer TokenRole = "viewer" // Read-only statistics access, with account identifiers masked
 )
 
+// Clone returns a deep copy of the token that shares no state with the original
+func (t *Token) Clone() *Token {
+	copied := *t
+	copied.LastUsedAt = cloneTime(t.LastUsedAt)
+	copied.AllowedPaths = append([]string(nil), t.AllowedPaths...)
+	return &copied
+}
+
+// SetKey replaces the token's key, keeping the cleartext key only until the token is stored
+func (t *Token) SetKey(key string) {
+	t.Key = key
+	t.KeyHash = HashTokenKey(key)
+	t.KeyPrefix = TokenKeyPrefix(key)
+}
+
+// MatchesKey reports whether key is the token's key
+func (t *Token) MatchesKey(key string) bool {
+	return t.KeyHash == HashTokenKey(key)
+}
+
+// MatchesSearch returns true if search is a substring of the token's name or key prefix (case-insensitive),
+// or its full key
+func (t *Token) MatchesSearch(search string) bool {
+	searchLower := strings.ToLower(search)
+	return strings.Contains(strings.ToLower(t.Name), searchLower) ||
+		strings.Contains(strings.ToLower(t.KeyPrefix), searchLower) || t.MatchesKey(search)
+}
+
 // IsActive returns true if the token is active
 func (t *Token) IsActive() bool {
 	return t.Status == TokenStatusActive
@@ -42,6 +114,11 @@ func (t *Token) IsAdmin() bool {
 	return t.Role == TokenRoleAdmin
 }
 
+// IsViewer returns true if the token has viewer role
+func (t *Token) IsViewer() bool {
+	return t.Role == TokenRoleViewer
+}
+
 // IncrementUsage increments the usage count and updates last used time
 func (t *Token) IncrementUsage() {
 	t.UsageCount++
@@ -64,10 +141,50 @@ func (t *Token) Revoke() {
 	t.Status = TokenStatusRevoked
 }
 
-// Update updates the token's name, key, status and role
+// SetAllowedPaths replaces the token's extra allowed proxy path patterns
+func (t *Token) SetAllowedPaths(paths []string) {
+	t.AllowedPaths = paths
+	t.UpdatedAt = time.Now()
+}
+
+// SetMaxSessions replaces the token's per-token concurrent session limit override
+func (t *Token) SetMaxSessions(maxSessions int) {
+	t.MaxSessions = maxSessions
+	t.UpdatedAt = time.Now()
+}
+
+// SetUsageSummary enables or disables the usage summary event appended to the token's streaming responses
+func (t *Token) SetUsageSummary(enabled bool) {
+	t.UsageSummary = enabled
+	t.UpdatedAt = time.Now()
+}
+
+// SetCaptureFailures enables or disables keeping the envelopes of the token's failed requests
+func (t *Token) SetCaptureFailures(enabled bool) {
+	t.CaptureFailures = enabled
+	t.UpdatedAt = time.Now()
+}
+
+// SetExternalUserIDHeader replaces the request header metadata.user_id is taken from ("" disables it)
+func (t *Token) SetExternalUserIDHeader(header string) {
+	t.ExternalUserIDHeader = header
+	t.UpdatedAt = time.Now()
+}
+
+// SessionLimit returns the token's concurrent session limit, falling back to defaultLimit
+func (t *Token) SessionLimit(defaultLimit int) int {
+	if t.MaxSessions > 0 {
+		return t.MaxSessions
+	}
+	return defaultLimit
+}
+
+// Update updates the token's name, key, status and role (an empty key keeps the current one)
 func (t *Token) Update(name, key string, status TokenStatus, role TokenRole) {
 	t.Name = name
-	t.Key = key
+	if key != "" {
+		t.SetKey(key)
+	}
 	t.Status = status
 	t.Role = role
 	t.UpdatedAt = time.Now()
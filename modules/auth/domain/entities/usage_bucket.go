@@ -0,0 +1,194 @@
+package entities
+
+import "time"
+
+// RequestSample describes a single proxied request for usage statistics
+type RequestSample struct {
+	TokenID      string
+	Timestamp    time.Time
+	StatusCode   int           // Upstream status code (0 when no response was received)
+	Latency      time.Duration // Time until upstream response headers (or failure)
+	InputTokens  int
+	OutputTokens int
+	// Prompt cache tokens, reported apart from InputTokens (0 for responses from before prompt caching)
+	CacheCreationTokens int
+	CacheReadTokens     int
+	ErrorCode           string // Proxy error classification when no upstream response was relayed (e.g. UPSTREAM_TIMEOUT)
+	UserID              string // End user of a message request (its metadata.user_id, possibly hashed), empty if none
+}
+
+// IsError returns true if the request failed (no response or an error status)
+func (s *RequestSample) IsError() bool {
+	return s.StatusCode == 0 || s.StatusCode >= 400
+}
+
+// IsServerError returns true if the request got no upstream response or a 5xx status
+func (s *RequestSample) IsServerError() bool {
+	return s.StatusCode == 0 || s.StatusCode >= 500
+}
+
+// UsageBucket aggregates request samples for one token over a fixed time slot
+type UsageBucket struct {
+	TokenID      string
+	Start        time.Time
+	Requests     int
+	Errors       int
+	InputTokens  int
+	OutputTokens int
+	// Prompt cache tokens written and read, not included in InputTokens
+	CacheCreationTokens int
+	CacheReadTokens     int
+	TotalLatencyMs      int64
+	ErrorCodes          map[string]int // Proxy-side failures by classification code (nil if none)
+	// Users splits the bucket's requests by end user (nil if none identified one)
+	Users map[string]*EndUserUsage
+}
+
+// EndUserUsage aggregates the requests of one end user (metadata.user_id) of a token
+type EndUserUsage struct {
+	UserID       string
+	Requests     int
+	InputTokens  int
+	OutputTokens int
+	LastSeen     time.Time
+}
+
+// MaxBucketUsers caps the end users tracked per bucket; requests of further users are counted
+// under OtherEndUsers so a client sending random IDs can't grow the statistics without bound
+const MaxBucketUsers = 1000
+
+// OtherEndUsers is the user ID requests beyond MaxBucketUsers distinct users are counted under
+const OtherEndUsers = "(other)"
+
+// merge folds another end user's totals into this one
+func (u *EndUserUsage) merge(other *EndUserUsage) {
+	u.Requests += other.Requests
+	u.InputTokens += other.InputTokens
+	u.OutputTokens += other.OutputTokens
+	if other.LastSeen.After(u.LastSeen) {
+		u.LastSeen = other.LastSeen
+	}
+}
+
+// Add folds a request sample into the bucket
+func (b *UsageBucket) Add(sample *RequestSample) {
+	b.Requests++
+	if sample.IsError() {
+		b.Errors++
+	}
+	if sample.ErrorCode != "" {
+		b.addErrorCode(sample.ErrorCode, 1)
+	}
+	b.InputTokens += sample.InputTokens
+	b.OutputTokens += sample.OutputTokens
+	b.CacheCreationTokens += sample.CacheCreationTokens
+	b.CacheReadTokens += sample.CacheReadTokens
+	b.TotalLatencyMs += sample.Latency.Milliseconds()
+	if sample.UserID != "" {
+		b.addUser(&EndUserUsage{
+			UserID:       sample.UserID,
+			Requests:     1,
+			InputTokens:  sample.InputTokens,
+			OutputTokens: sample.OutputTokens,
+			LastSeen:     sample.Timestamp,
+		})
+	}
+}
+
+// Merge folds another bucket's totals into this one
+func (b *UsageBucket) Merge(other *UsageBucket) {
+	b.Requests += other.Requests
+	b.Errors += other.Errors
+	b.InputTokens += other.InputTokens
+	b.OutputTokens += other.OutputTokens
+	b.CacheCreationTokens += other.CacheCreationTokens
+	b.CacheReadTokens += other.CacheReadTokens
+	b.TotalLatencyMs += other.TotalLatencyMs
+	for code, count := range other.ErrorCodes {
+		b.addErrorCode(code, count)
+	}
+	for _, user := range other.Users {
+		b.addUser(user)
+	}
+}
+
+// Clone returns a copy of the bucket that shares no state with the original
+func (b *UsageBucket) Clone() *UsageBucket {
+	copied := *b
+	copied.ErrorCodes = nil
+	for code, count := range b.ErrorCodes {
+		copied.addErrorCode(code, count)
+	}
+	copied.Users = nil
+	for _, user := range b.Users {
+		copied.addUser(user)
+	}
+	return &copied
+}
+
+// addUser folds an end user's totals into the bucket (under OtherEndUsers once MaxBucketUsers are tracked)
+func (b *UsageBucket) addUser(user *EndUserUsage) {
+	if b.Users == nil {
+		b.Users = make(map[string]*EndUserUsage)
+	}
+	userID := user.UserID
+	if _, ok := b.Users[userID]; !ok && len(b.Users) >= MaxBucketUsers {
+		userID = OtherEndUsers
+	}
+	total, ok := b.Users[userID]
+	if !ok {
+		total = &EndUserUsage{UserID: userID}
+		b.Users[userID] = total
+	}
+	total.merge(user)
+}
+
+// addErrorCode adds count failures for an error classification code
+func (b *UsageBucket) addErrorCode(code string, count int) {
+	if b.ErrorCodes == nil {
+		b.ErrorCodes = make(map[string]int)
+	}
+	b.ErrorCodes[code] += count
+}
+
+// AverageLatencyMs returns the mean latency of the bucket's requests
+func (b *UsageBucket) AverageLatencyMs() float64 {
+	if b.Requests == 0 {
+		return 0
+	}
+	return float64(b.TotalLatencyMs) / float64(b.Requests)
+}
+
+// TotalTokens returns input, output and prompt cache tokens
+func (b *UsageBucket) TotalTokens() int {
+	return b.InputTokens + b.OutputTokens + b.CacheCreationTokens + b.CacheReadTokens
+}
+
+// CacheHitRatio returns the share of the bucket's input tokens read from the prompt cache
+func (b *UsageBucket) CacheHitRatio() float64 {
+	return CacheHitRatio(b.InputTokens, b.CacheCreationTokens, b.CacheReadTokens)
+}
+
+// TokenUsage is the token usage an upstream response reported for one request
+// Prompt cache tokens are counted apart from InputTokens, as Anthropic reports them.
+type TokenUsage struct {
+	InputTokens         int
+	OutputTokens        int
+	CacheCreationTokens int
+	CacheReadTokens     int
+}
+
+// Total returns input, output and prompt cache tokens
+func (u TokenUsage) Total() int {
+	return u.InputTokens + u.OutputTokens + u.CacheCreationTokens + u.CacheReadTokens
+}
+
+// CacheHitRatio returns cacheRead / (input + cacheCreation + cacheRead): the share of all input tokens served
+// from the prompt cache (0 without input)
+func CacheHitRatio(input, cacheCreation, cacheRead int) float64 {
+	total := input + cacheCreation + cacheRead
+	if total == 0 {
+		return 0
+	}
+	return float64(cacheRead) / float64(total)
+}
@@ -2,6 +2,7 @@ package interfaces
 
 import (
 	"context"
+	"time"
 
 	"claude-proxy/modules/auth/domain/entities"
 )
@@ -9,32 +10,141 @@ import (
 // AccountService defines the interface for app account management operations
 type AccountService interface {
 	// CreateAccount creates a new app account from OAuth code
+	// Returns a PendingAccount instead when the user has several organizations and orgID is empty
 	CreateAccount(
 		ctx context.Context,
 		name, code, codeVerifier, orgID string,
+	) (*entities.Account, *entities.PendingAccount, error)
+
+	// SelectOrganization finalizes a pending account creation with the chosen organization
+	SelectOrganization(ctx context.Context, pendingID, orgUUID string) (*entities.Account, error)
+
+	// ImportAccount creates an account from existing OAuth credentials (e.g. a Claude Code login)
+	// The refresh token is validated with one refresh; credentials already used by an account
+	// are rejected with entities.ErrCredentialsAlreadyImported
+	ImportAccount(ctx context.Context, name string, creds *entities.ImportedCredentials) (*entities.Account, error)
+
+	// CreateManualAccount creates an account from pasted tokens, bypassing the OAuth code exchange
+	// With verify the refresh token is validated with one refresh and the organizations are discovered
+	CreateManualAccount(
+		ctx context.Context,
+		name, orgUUID string,
+		creds *entities.ManualCredentials,
+		verify bool,
+	) (*entities.Account, error)
+
+	// CreateAPIKeyAccount creates an account authenticating with an Anthropic API key (no refresh lifecycle)
+	// A key already used by another account is rejected with entities.ErrAPIKeyAlreadyImported
+	CreateAPIKeyAccount(ctx context.Context, name, apiKey string) (*entities.Account, error)
+
+	// SetCredentials replaces an account's tokens with pasted ones, clearing its error and rate-limit state
+	// With verify the refresh token is validated with one refresh first; the account is unchanged on failure
+	SetCredentials(
+		ctx context.Context,
+		id string,
+		creds *entities.ManualCredentials,
+		verify bool,
 	) (*entities.Account, error)
 
-	// GetAccount retrieves an account by ID
+	// AdoptConflictedAccount acknowledges a refresh token conflict and takes the account back with fresh
+	// credentials; fails with entities.ErrAccountNotConflicted if the account has no conflict
+	AdoptConflictedAccount(
+		ctx context.Context,
+		id string,
+		creds *entities.ManualCredentials,
+		verify bool,
+	) (*entities.Account, error)
+
+	// GetAccount retrieves an account by ID (soft-deleted accounts included)
 	GetAccount(ctx context.Context, id string) (*entities.Account, error)
 
-	// ListAccounts retrieves all accounts
+	// ListAccounts retrieves all accounts that are not soft-deleted
 	ListAccounts(ctx context.Context) ([]*entities.Account, error)
 
+	// ListAllAccounts retrieves all accounts including soft-deleted ones
+	ListAllAccounts(ctx context.Context) ([]*entities.Account, error)
+
 	// UpdateAccount updates an existing account
 	UpdateAccount(ctx context.Context, id, name string, status entities.AccountStatus) (*entities.Account, error)
 
-	// DeleteAccount deletes an account
-	DeleteAccount(ctx context.Context, id string) error
+	// UpdateAccountQuota sets per-window request and token budgets (0 = unlimited)
+	UpdateAccountQuota(ctx context.Context, id string, quotaRequests, quotaTokens int) (*entities.Account, error)
+
+	// UpdateAccountOrganization switches the account's active organization to another discovered one
+	UpdateAccountOrganization(ctx context.Context, id, orgUUID string) (*entities.Account, error)
+
+	// UpdateAccountProxy sets or clears the account's egress proxy URL (validated)
+	UpdateAccountProxy(ctx context.Context, id, proxyURL string) (*entities.Account, error)
+
+	// UpdateAccountBaseURL sets or clears the account's Claude API base URL override (validated https)
+	UpdateAccountBaseURL(ctx context.Context, id, baseURL string) (*entities.Account, error)
 
-	// GetValidToken returns a valid access token for an account, refreshing if needed
+	// UpdateAccountHeaders replaces the account's identification header overrides (validated)
+	UpdateAccountHeaders(ctx context.Context, id string, headers map[string]string) (*entities.Account, error)
+
+	// UpdateAccountSupportedModels replaces the model glob patterns the account serves (empty = every model)
+	UpdateAccountSupportedModels(ctx context.Context, id string, patterns []string) (*entities.Account, error)
+
+	// UpdateAccountAvailability replaces the weekly time ranges the account serves in (empty = always)
+	UpdateAccountAvailability(
+		ctx context.Context,
+		id string,
+		windows []entities.AvailabilityWindow,
+	) (*entities.Account, error)
+
+	// UpdateAccountAutoRefresh enables or disables token refresh for the account (disabled = manual mode)
+	UpdateAccountAutoRefresh(ctx context.Context, id string, enabled bool) (*entities.Account, error)
+
+	// UpdateAccountShadow puts the account in or out of shadow mode (mirrored requests only, never selected)
+	UpdateAccountShadow(ctx context.Context, id string, shadow bool) (*entities.Account, error)
+
+	// UpdateAccountAdminCapable flags the account as having organization admin access (upstream usage reports)
+	UpdateAccountAdminCapable(ctx context.Context, id string, adminCapable bool) (*entities.Account, error)
+
+	// UpdateAccountCanary moves the account in or out of the canary set (proxy.canary_percent of sessions)
+	UpdateAccountCanary(ctx context.Context, id string, canary bool) (*entities.Account, error)
+
+	// UpdateAccountPriority moves the account to another failover tier (lower = preferred)
+	UpdateAccountPriority(ctx context.Context, id string, priority int) (*entities.Account, error)
+
+	// RecordEgressError records an egress proxy connection failure on the account (status unchanged)
+	RecordEgressError(ctx context.Context, accountID, errMsg string) error
+
+	// DisableAccount marks an account inactive, recording the reason as its last error
+	DisableAccount(ctx context.Context, accountID, reason string) error
+
+	// EnableAccount marks an account active again, clearing its last error (soft-deleted accounts excluded)
+	EnableAccount(ctx context.Context, accountID string) (*entities.Account, error)
+
+	// ActivateAccount ends an account's new-account cooldown so it enters the rotation now (soft-deleted accounts excluded)
+	ActivateAccount(ctx context.Context, accountID string) (*entities.Account, error)
+
+	// EndElapsedCooldowns clears elapsed new-account cooldowns and returns the accounts that entered the rotation
+	EndElapsedCooldowns(ctx context.Context) ([]*entities.Account, error)
+
+	// InvalidateAccount marks an account invalid after Claude API rejected its credentials
+	InvalidateAccount(ctx context.Context, accountID, reason string) error
+
+	// RecordUsage adds a proxied request and its token usage to the account's current usage window
+	RecordUsage(ctx context.Context, accountID string, usage entities.TokenUsage) error
+
+	// SyncUsageWindow aligns the account's usage window with a reset time reported by Claude API
+	SyncUsageWindow(ctx context.Context, accountID string, resetAt time.Time) error
+
+	// DeleteAccount soft-deletes an account, or removes it with its credentials when permanent is set
+	DeleteAccount(ctx context.Context, id string, permanent bool) error
+
+	// RestoreAccount restores a soft-deleted account after verifying its refresh token still works
+	RestoreAccount(ctx context.Context, id string) (*entities.Account, error)
+
+	// GetValidToken returns a valid access token for an account, refreshing if needed (never in manual mode)
 	GetValidToken(ctx context.Context, accountID string) (string, error)
 
-	// GetActiveAccounts retrieves all active accounts
+	// GetActiveAccounts retrieves all active accounts (soft-deleted accounts excluded)
 	GetActiveAccounts(ctx context.Context) ([]*entities.Account, error)
 
-	// RefreshAllAccounts refreshes tokens for all accounts that need it
-	// Returns refreshed count, failed count, skipped count, and error
-	RefreshAllAccounts(ctx context.Context) (int, int, int, error)
+	// RefreshAccount refreshes an account's tokens now, whether or not they are about to expire
+	RefreshAccount(ctx context.Context, accountID string) error
 
 	// RecoverRateLimitedAccounts checks and recovers accounts with expired rate limits
 	// Returns the number of accounts recovered
@@ -46,6 +156,12 @@ type AccountService interface {
 	// Sync syncs in-memory data to persistent storage
 	Sync(ctx context.Context) error
 
+	// Reload merges accounts.json into the cache now, whether or not another writer modified it
+	Reload(ctx context.Context) (*entities.ReconcileReport, error)
+
+	// Mirror replaces the cached accounts with a primary instance's, which win every conflict (warm standby)
+	Mirror(ctx context.Context, accounts []*entities.Account) (*entities.MirrorResult, error)
+
 	// FinalSync performs final sync on graceful shutdown
 	FinalSync(ctx context.Context) error
 }
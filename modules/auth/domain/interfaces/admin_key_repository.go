@@ -0,0 +1,16 @@
+package interfaces
+
+import (
+	"context"
+
+	"claude-proxy/modules/auth/domain/entities"
+)
+
+// AdminKeyRepository defines the interface for durable admin key storage
+type AdminKeyRepository interface {
+	// SaveAll persists all admin keys (batch operation)
+	SaveAll(ctx context.Context, keys []*entities.AdminKey) error
+
+	// LoadAll loads all admin keys
+	LoadAll(ctx context.Context) ([]*entities.AdminKey, error)
+}
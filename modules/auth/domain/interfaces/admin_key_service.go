@@ -0,0 +1,23 @@
+package interfaces
+
+import (
+	"context"
+
+	"claude-proxy/modules/auth/domain/entities"
+)
+
+// AdminKeyService defines the interface for managed admin API keys
+type AdminKeyService interface {
+	// Authenticate checks a key against the managed keys (or the config key while none exist)
+	// Returns the identity to log (e.g. "admin_key:<id>" or "config_api_key") and whether it matched
+	Authenticate(ctx context.Context, key string) (string, bool)
+
+	// RotateKey mints a new admin key (returned once) and expires the previous keys after the grace period
+	RotateKey(ctx context.Context) (string, *entities.AdminKey, error)
+
+	// RevokeKey removes an admin key immediately
+	RevokeKey(ctx context.Context, id string) error
+
+	// ListKeys returns the unexpired admin keys
+	ListKeys(ctx context.Context) ([]*entities.AdminKey, error)
+}
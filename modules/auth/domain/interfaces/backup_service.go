@@ -0,0 +1,24 @@
+package interfaces
+
+import (
+	"context"
+
+	"claude-proxy/modules/auth/domain/entities"
+)
+
+// BackupService defines the interface for data folder backups
+type BackupService interface {
+	// CreateBackup syncs in-memory data and writes a timestamped archive of the data files
+	CreateBackup(ctx context.Context) (*entities.Backup, error)
+
+	// ExportBundle syncs in-memory data and returns the data files as an encrypted state bundle
+	// The bundle is nil when ifNoneMatch is the ETag of the current data files (nothing changed since that export)
+	ExportBundle(
+		ctx context.Context,
+		passphrase string,
+		ifNoneMatch string,
+	) ([]byte, *entities.StateBundleManifest, error)
+
+	// ListBackups returns available backups, newest first
+	ListBackups(ctx context.Context) ([]*entities.Backup, error)
+}
@@ -0,0 +1,9 @@
+package interfaces
+
+import "claude-proxy/modules/auth/domain/entities"
+
+// CacheMonitor defines the interface for reading the sizes of the in-memory cache repositories
+type CacheMonitor interface {
+	// Stats returns the size of each cache, keyed accounts, tokens, sessions and usage_buckets
+	Stats() map[string]entities.CacheStats
+}
@@ -12,7 +12,7 @@ type CacheRepository interface {
 	// Create creates a new account in cache
 	Create(ctx context.Context, account *entities.Account) error
 
-	// GetByID retrieves an account by ID from cache
+	// GetByID retrieves an account by ID (or the legacy ID of a migrated account) from cache
 	GetByID(ctx context.Context, id string) (*entities.Account, error)
 
 	// List retrieves all accounts from cache
@@ -21,9 +21,17 @@ type CacheRepository interface {
 	// Update updates an existing account in cache
 	Update(ctx context.Context, account *entities.Account) error
 
+	// UpdateWith atomically applies mutate to the account with the given ID (or legacy ID) and stores the result,
+	// returning a copy of it; nothing is stored when mutate fails. mutate runs under the cache's lock, so it must
+	// be quick and must not call back into the cache.
+	UpdateWith(ctx context.Context, id string, mutate func(account *entities.Account) error) (*entities.Account, error)
+
 	// Delete deletes an account by ID from cache
 	Delete(ctx context.Context, id string) error
 
 	// GetActiveAccounts retrieves all active accounts from cache
 	GetActiveAccounts(ctx context.Context) ([]*entities.Account, error)
+
+	// CacheStats returns the cache's entry count, approximate memory usage and soft limit
+	CacheStats() entities.CacheStats
 }
@@ -0,0 +1,16 @@
+package interfaces
+
+import (
+	"context"
+
+	"claude-proxy/modules/auth/domain/entities"
+)
+
+// DeviceAuthRepository defines the interface for durable device authorization storage
+type DeviceAuthRepository interface {
+	// SaveAll persists all device authorizations (batch operation)
+	SaveAll(ctx context.Context, auths []*entities.DeviceAuthorization) error
+
+	// LoadAll loads all device authorizations
+	LoadAll(ctx context.Context) ([]*entities.DeviceAuthorization, error)
+}
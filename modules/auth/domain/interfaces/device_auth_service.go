@@ -0,0 +1,38 @@
+package interfaces
+
+import (
+	"context"
+	"time"
+
+	"claude-proxy/modules/auth/domain/entities"
+)
+
+// DeviceAuthService defines the interface for the OAuth flow of headless servers: an authorization is started
+// on the server, authorized in any browser, and polled for until its account exists
+type DeviceAuthService interface {
+	// Start creates an authorization valid for ttl and returns its polling code (shown once) with it
+	Start(
+		ctx context.Context,
+		accountName, orgID, createdBy string,
+		ttl time.Duration,
+	) (string, *entities.DeviceAuthorization, error)
+
+	// Poll returns the authorization of a polling code (entities.ErrDeviceAuthNotFound if none)
+	Poll(ctx context.Context, pollCode string) (*entities.DeviceAuthorization, error)
+
+	// FindByState returns the authorization whose URL carries an OAuth state (entities.ErrDeviceAuthNotFound
+	// if none)
+	FindByState(ctx context.Context, state string) (*entities.DeviceAuthorization, error)
+
+	// Complete exchanges an authorization code ("code#state", or a bare code with its state) for the
+	// authorization whose URL carried that state and creates its account; for a user with several
+	// organizations, the pending organization selection is returned instead
+	Complete(
+		ctx context.Context,
+		code, state string,
+	) (*entities.DeviceAuthorization, *entities.PendingAccount, error)
+
+	// CompleteSelection records the account created once the organization of an authorization was selected
+	// (no-op if no authorization waits for that selection)
+	CompleteSelection(ctx context.Context, selectionID, accountID string) error
+}
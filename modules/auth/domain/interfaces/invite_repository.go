@@ -0,0 +1,16 @@
+package interfaces
+
+import (
+	"context"
+
+	"claude-proxy/modules/auth/domain/entities"
+)
+
+// InviteRepository defines the interface for durable account invite storage
+type InviteRepository interface {
+	// SaveAll persists all invites (batch operation)
+	SaveAll(ctx context.Context, invites []*entities.Invite) error
+
+	// LoadAll loads all invites
+	LoadAll(ctx context.Context) ([]*entities.Invite, error)
+}
@@ -0,0 +1,37 @@
+package interfaces
+
+import (
+	"context"
+	"time"
+
+	"claude-proxy/modules/auth/domain/entities"
+)
+
+// InviteService defines the interface for one-time account invite links
+type InviteService interface {
+	// CreateInvite creates an invite valid for ttl and returns its token (shown once) with the invite
+	CreateInvite(
+		ctx context.Context,
+		accountName, orgID, createdBy string,
+		ttl time.Duration,
+	) (string, *entities.Invite, error)
+
+	// ListInvites returns the invites, most recently created first
+	ListInvites(ctx context.Context) ([]*entities.Invite, error)
+
+	// RevokeInvite deletes an invite, so its link stops working
+	RevokeInvite(ctx context.Context, id string) error
+
+	// FindInvite returns the invite of a token (entities.ErrInviteNotFound if none)
+	FindInvite(ctx context.Context, token string) (*entities.Invite, error)
+
+	// BeginRedeem reserves a usable invite for one code exchange; concurrent redeems get entities.ErrInviteBusy
+	BeginRedeem(ctx context.Context, token string) (*entities.Invite, error)
+
+	// FinishRedeem ends a redeem: a failed exchange (err set) releases the invite, a successful one
+	// marks it used with the created account, or the pending organization selection
+	FinishRedeem(ctx context.Context, id, accountID, selectionID string, err error) error
+
+	// CompleteSelection records the account created once the organization of a used invite was selected
+	CompleteSelection(ctx context.Context, id, accountID string) error
+}
@@ -0,0 +1,16 @@
+package interfaces
+
+import (
+	"context"
+
+	"claude-proxy/modules/auth/domain/entities"
+)
+
+// MutationAuditRepository defines the interface for the append-only admin mutation audit trail
+type MutationAuditRepository interface {
+	// Append adds an entry at the end of the trail
+	Append(ctx context.Context, entry *entities.MutationAuditEntry) error
+
+	// List returns the entries matching filter, newest first
+	List(ctx context.Context, filter entities.MutationAuditFilter) ([]*entities.MutationAuditEntry, error)
+}
@@ -0,0 +1,20 @@
+package interfaces
+
+import (
+	"context"
+
+	"claude-proxy/modules/auth/domain/entities"
+)
+
+// MutationAuditService records admin changes of accounts and tokens, with their actor and changed fields
+type MutationAuditService interface {
+	// RecordAccount records a change of an account by the actor in ctx: before is nil on creation and after
+	// on deletion; updates changing no audited field are not recorded
+	RecordAccount(ctx context.Context, before, after *entities.Account)
+
+	// RecordToken records a change of a token by the actor in ctx, like RecordAccount
+	RecordToken(ctx context.Context, before, after *entities.Token)
+
+	// ListMutations returns the recorded changes matching filter, newest first
+	ListMutations(ctx context.Context, filter entities.MutationAuditFilter) ([]*entities.MutationAuditEntry, error)
+}
@@ -17,6 +17,15 @@ type OAuthClient interface {
 	// ExchangeCodeForToken exchanges authorization code for access and refresh tokens
 	ExchangeCodeForToken(ctx context.Context, code, codeVerifier string) (*clients.TokenResponse, error)
 
+	// ListOrganizations returns every organization the access token's user belongs to
+	ListOrganizations(ctx context.Context, accessToken string) ([]clients.Organization, error)
+
 	// RefreshAccessToken uses refresh token to get a new access token
-	RefreshAccessToken(ctx context.Context, refreshToken string) (*clients.TokenResponse, error)
+	// proxyURL routes the request through the account's egress proxy (empty = direct) and headers
+	// override the configured identification headers (the account's overrides, nil for none)
+	RefreshAccessToken(
+		ctx context.Context,
+		refreshToken, proxyURL string,
+		headers map[string]string,
+	) (*clients.TokenResponse, error)
 }
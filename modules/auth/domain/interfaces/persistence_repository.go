@@ -11,7 +11,9 @@ import (
 // All operations should persist to disk or permanent storage
 type PersistenceRepository interface {
 	// SaveAll persists all accounts to durable storage (batch operation)
-	SaveAll(ctx context.Context, accounts []*entities.Account) error
+	// An empty save over stored accounts is refused unless force is set, which is only meant for deletes that
+	// emptied the cache (not for a cache that failed to load)
+	SaveAll(ctx context.Context, accounts []*entities.Account, force bool) error
 
 	// LoadAll loads all accounts from durable storage
 	LoadAll(ctx context.Context) ([]*entities.Account, error)
@@ -24,4 +26,8 @@ type PersistenceRepository interface {
 
 	// Delete deletes an account from persistent storage
 	Delete(ctx context.Context, id string) error
+
+	// Changed returns true if the storage was modified by another writer (a CLI command or a hand
+	// edit) since this repository last loaded or saved it
+	Changed(ctx context.Context) (bool, error)
 }
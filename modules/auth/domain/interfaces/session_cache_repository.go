@@ -15,6 +15,10 @@ type SessionCacheRepository interface {
 	// GetSession retrieves a session by ID from cache
 	GetSession(ctx context.Context, sessionID string) (*entities.Session, error)
 
+	// FindLiveSession retrieves a live session with the entities.SessionFingerprint of tokenID, ipAddress and
+	// userAgent from cache (nil if none), through a fingerprint index
+	FindLiveSession(ctx context.Context, tokenID, ipAddress, userAgent string) (*entities.Session, error)
+
 	// ListSessionsByToken retrieves all active sessions for a token from cache
 	ListSessionsByToken(ctx context.Context, tokenID string) ([]*entities.Session, error)
 
@@ -24,12 +28,24 @@ type SessionCacheRepository interface {
 	// DeleteSession deletes a session by ID from cache
 	DeleteSession(ctx context.Context, sessionID string) error
 
+	// DeleteSessions deletes the sessions with the given IDs in one pass, returning the IDs actually deleted
+	DeleteSessions(ctx context.Context, sessionIDs []string) ([]string, error)
+
 	// CountActiveSessions counts total active sessions globally from cache
 	CountActiveSessions(ctx context.Context) (int, error)
 
+	// CountActiveSessionsByToken counts active sessions owned by a token from cache
+	CountActiveSessionsByToken(ctx context.Context, tokenID string) (int, error)
+
 	// CleanupExpiredSessions removes expired sessions from cache
 	CleanupExpiredSessions(ctx context.Context) (int, error)
 
 	// ListAllSessions retrieves all sessions from cache (for admin)
 	ListAllSessions(ctx context.Context) ([]*entities.Session, error)
+
+	// CountSessions counts all sessions in cache by state, without copying them
+	CountSessions(ctx context.Context) (*entities.SessionCounts, error)
+
+	// CacheStats returns the cache's entry count, approximate memory usage and soft limit
+	CacheStats() entities.CacheStats
 }
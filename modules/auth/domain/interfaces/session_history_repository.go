@@ -0,0 +1,21 @@
+package interfaces
+
+import (
+	"context"
+	"time"
+
+	"claude-proxy/modules/auth/domain/entities"
+)
+
+// SessionHistoryRepository defines the interface for the append-only monthly session history files
+type SessionHistoryRepository interface {
+	// Append adds records at the end of the files of the months they were created in
+	Append(ctx context.Context, records []*entities.SessionHistoryRecord) error
+
+	// List returns the records of a month (its first instant, UTC), of one token unless tokenID is empty,
+	// newest first
+	List(ctx context.Context, month time.Time, tokenID string) ([]*entities.SessionHistoryRecord, error)
+
+	// Prune deletes the files of the months that ended before cutoff and returns the months deleted (YYYY-MM)
+	Prune(ctx context.Context, cutoff time.Time) ([]string, error)
+}
@@ -0,0 +1,26 @@
+package interfaces
+
+import (
+	"context"
+	"time"
+
+	"claude-proxy/modules/auth/domain/entities"
+)
+
+// SessionHistoryService records session creations for security reviews (session.history_enabled)
+// Recording never blocks nor fails session creation: records are written in the background and a disk error
+// only loses them.
+type SessionHistoryService interface {
+	// Record queues the history record of a new session (a no-op when history is disabled)
+	Record(session *entities.Session)
+
+	// List returns the records of a month (its first instant, UTC), of one token unless tokenID is empty,
+	// newest first
+	List(ctx context.Context, month time.Time, tokenID string) ([]*entities.SessionHistoryRecord, error)
+
+	// Start launches the background writer and prunes the files past session.history_retention
+	Start()
+
+	// Stop writes the queued records and waits for the background writer
+	Stop()
+}
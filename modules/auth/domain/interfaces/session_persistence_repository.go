@@ -11,7 +11,9 @@ import (
 // All operations should persist to disk or permanent storage
 type SessionPersistenceRepository interface {
 	// SaveAll persists all sessions to durable storage (batch operation)
-	SaveAll(ctx context.Context, sessions []*entities.Session) error
+	// An empty save over stored sessions is refused unless force is set, which is only meant for deletes that
+	// emptied the cache (not for a cache that failed to load)
+	SaveAll(ctx context.Context, sessions []*entities.Session, force bool) error
 
 	// LoadAll loads all sessions from durable storage
 	LoadAll(ctx context.Context) ([]*entities.Session, error)
@@ -24,4 +26,8 @@ type SessionPersistenceRepository interface {
 
 	// DeleteSession deletes a session from persistent storage
 	DeleteSession(ctx context.Context, sessionID string) error
+
+	// Compact rewrites the storage without sessions past the retention period (archiving them when
+	// enabled) and returns the sessions kept with how many were pruned; run at startup, in place of LoadAll
+	Compact(ctx context.Context) ([]*entities.Session, int, error)
 }
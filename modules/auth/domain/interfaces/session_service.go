@@ -4,17 +4,20 @@ import (
 	"context"
 	"net/http"
 
+	"claude-proxy/modules/auth/application/dto"
 	"claude-proxy/modules/auth/domain/entities"
+
+	"github.com/phathdt/service-context/core"
 )
 
 // SessionService defines the interface for session management operations
-// Sessions track concurrent requests per client (IP + UserAgent)
+// Sessions track concurrent requests per client (token + IP + UserAgent)
 type SessionService interface {
-	// CreateSession creates a new session and checks global limits
-	// Returns error if concurrent session limit is exceeded
+	// CreateSession creates a new session and checks the global and/or per-token limits
+	// Returns error if a concurrent session limit is exceeded
 	CreateSession(
 		ctx context.Context,
-		tokenID string,
+		token *entities.Token,
 		req *http.Request,
 	) (*entities.Session, error)
 
@@ -30,21 +33,53 @@ type SessionService interface {
 		sessionID string,
 	) error
 
+	// TouchSession records activity on a session (LastSeenAt) and slides its expiry window
+	// Changes are kept in cache and persisted by the periodic sync
+	TouchSession(
+		ctx context.Context,
+		sessionID string,
+	) error
+
 	// RevokeSession manually revokes a session
 	RevokeSession(
 		ctx context.Context,
 		sessionID string,
 	) error
 
+	// RevokeTokenSession revokes a session only if it belongs to tokenID (self-service)
+	// Sessions of other tokens are reported as not found
+	RevokeTokenSession(
+		ctx context.Context,
+		tokenID, sessionID string,
+	) error
+
+	// RevokeSessions revokes every session matching the filter (admin), returning the revoked session IDs
+	// A filter without any field is refused unless it sets All
+	RevokeSessions(
+		ctx context.Context,
+		filter *dto.RevokeSessionsRequest,
+	) ([]string, error)
+
 	// GetAllSessions retrieves all active sessions (admin)
 	GetAllSessions(ctx context.Context) ([]*entities.Session, error)
 
+	// ListSessions retrieves sessions matching the query, sorted and paginated (admin)
+	// Pagination metadata is injected into the paging pointer; counts cover all sessions, unfiltered
+	ListSessions(
+		ctx context.Context,
+		query *dto.SessionQueryParams,
+		paging *core.Paging,
+	) ([]*entities.Session, *entities.SessionCounts, error)
+
 	// CleanupExpiredSessions removes expired sessions
 	CleanupExpiredSessions(ctx context.Context) (int, error)
 
 	// Sync syncs in-memory data to persistent storage
 	Sync(ctx context.Context) error
 
+	// Mirror replaces the cached sessions with a primary instance's, which win every conflict (warm standby)
+	Mirror(ctx context.Context, sessions []*entities.Session) (*entities.MirrorResult, error)
+
 	// FinalSync performs final sync on graceful shutdown
 	FinalSync(ctx context.Context) error
 }
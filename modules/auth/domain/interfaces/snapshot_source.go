@@ -0,0 +1,8 @@
+package interfaces
+
+// SnapshotSource is implemented by file-backed persistence repositories that support consistent backups
+type SnapshotSource interface {
+	// WithWriteLock runs fn while holding the repository's write lock, passing the path of its data file
+	// No save can modify the file until fn returns
+	WithWriteLock(fn func(dataFile string) error) error
+}
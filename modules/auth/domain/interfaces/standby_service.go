@@ -0,0 +1,30 @@
+package interfaces
+
+import (
+	"context"
+
+	"claude-proxy/modules/auth/domain/entities"
+)
+
+// StandbyService runs the instance as a warm standby of a primary (standby.enabled): it mirrors the primary's
+// accounts, tokens and sessions until promoted, and holds back the work only an active instance does
+type StandbyService interface {
+	// Standby returns true while the instance follows its primary and refuses proxy traffic
+	Standby() bool
+
+	// Status returns the standby state and the outcome of the last pulls
+	Status() entities.StandbyStatus
+
+	// OnPromote registers fn to run once the instance is promoted, or runs it now when it is not a standby
+	OnPromote(fn func())
+
+	// Promote stops the pull loop, switches the instance to normal operation and runs the OnPromote functions
+	// Returns entities.ErrNotStandby when the instance is not a standby
+	Promote(ctx context.Context) (entities.StandbyStatus, error)
+
+	// Start launches the pull loop (a no-op when the instance is not a standby)
+	Start()
+
+	// Stop cancels the pull loop and waits for it
+	Stop()
+}
@@ -0,0 +1,40 @@
+package interfaces
+
+import (
+	"context"
+	"time"
+
+	"claude-proxy/modules/auth/domain/entities"
+)
+
+// StatisticsService defines the interface for per-token usage statistics
+type StatisticsService interface {
+	// RecordRequest records a proxied request sample
+	RecordRequest(ctx context.Context, sample *entities.RequestSample) error
+
+	// GetTokenStats returns contiguous buckets of the given size covering the last period for a token
+	GetTokenStats(
+		ctx context.Context,
+		tokenID string,
+		period, bucket time.Duration,
+	) ([]*entities.UsageBucket, error)
+
+	// RankTokens returns per-token totals over the last period, ordered by request count (highest first)
+	RankTokens(ctx context.Context, period time.Duration) ([]*entities.UsageBucket, error)
+
+	// ListTokenUsers returns the end users of a token over the last period with their totals,
+	// ordered by request count (highest first)
+	ListTokenUsers(ctx context.Context, tokenID string, period time.Duration) ([]*entities.EndUserUsage, error)
+
+	// Resolution returns the granularity at which samples are aggregated
+	Resolution() time.Duration
+
+	// Retention returns how long buckets are kept
+	Retention() time.Duration
+
+	// Sync drops expired buckets and syncs in-memory data to persistent storage
+	Sync(ctx context.Context) error
+
+	// FinalSync performs final sync on graceful shutdown
+	FinalSync(ctx context.Context) error
+}
@@ -2,6 +2,7 @@ package interfaces
 
 import (
 	"context"
+	"time"
 
 	"claude-proxy/modules/auth/domain/entities"
 )
@@ -18,12 +19,23 @@ type TokenCacheRepository interface {
 	// GetByKey retrieves a token by its key from cache
 	GetByKey(ctx context.Context, key string) (*entities.Token, error)
 
+	// RecordUsage counts a request made with the token at the given time (usage count and last use), without
+	// blocking concurrent lookups
+	RecordUsage(ctx context.Context, id string, at time.Time) error
+
 	// List retrieves all tokens from cache
 	List(ctx context.Context) ([]*entities.Token, error)
 
+	// Query returns the page (offset, limit) of the tokens matching filter in ID (creation) order, and how
+	// many match; role and status filters use indexes, so pages cost the same whatever the token count
+	Query(ctx context.Context, filter entities.TokenFilter, offset, limit int) ([]*entities.Token, int, error)
+
 	// Update updates an existing token in cache
 	Update(ctx context.Context, token *entities.Token) error
 
 	// Delete deletes a token by ID from cache
 	Delete(ctx context.Context, id string) error
+
+	// CacheStats returns the cache's entry count, approximate memory usage and soft limit
+	CacheStats() entities.CacheStats
 }
@@ -11,7 +11,9 @@ import (
 // All operations should persist to disk or permanent storage
 type TokenPersistenceRepository interface {
 	// SaveAll persists all tokens to durable storage (batch operation)
-	SaveAll(ctx context.Context, tokens []*entities.Token) error
+	// An empty save over stored tokens is refused unless force is set, which is only meant for deletes that
+	// emptied the cache (not for a cache that failed to load)
+	SaveAll(ctx context.Context, tokens []*entities.Token, force bool) error
 
 	// LoadAll loads all tokens from durable storage
 	LoadAll(ctx context.Context) ([]*entities.Token, error)
@@ -24,4 +26,8 @@ type TokenPersistenceRepository interface {
 
 	// Delete deletes a token from persistent storage
 	Delete(ctx context.Context, id string) error
+
+	// Changed returns true if the storage was modified by another writer (a CLI command or a hand
+	// edit) since this repository last loaded or saved it
+	Changed(ctx context.Context) (bool, error)
 }
@@ -29,7 +29,7 @@ type TokenService interface {
 	// Pagination metadata is injected into the paging pointer
 	ListTokens(ctx context.Context, query *dto.TokenQueryParams, paging *core.Paging) ([]*entities.Token, error)
 
-	// UpdateToken updates an existing token
+	// UpdateToken updates an existing token (an empty key keeps the current one)
 	UpdateToken(
 		ctx context.Context,
 		id, name, key string,
@@ -37,6 +37,24 @@ type TokenService interface {
 		role entities.TokenRole,
 	) (*entities.Token, error)
 
+	// UpdateTokenAllowedPaths replaces the extra proxy path patterns allowed for a token
+	UpdateTokenAllowedPaths(ctx context.Context, id string, allowedPaths []string) (*entities.Token, error)
+
+	// UpdateTokenMaxSessions sets the token's concurrent session limit override (0 uses the configured default)
+	UpdateTokenMaxSessions(ctx context.Context, id string, maxSessions int) (*entities.Token, error)
+
+	// UpdateTokenUsageSummary enables or disables the usage summary event appended to streaming responses
+	UpdateTokenUsageSummary(ctx context.Context, id string, enabled bool) (*entities.Token, error)
+
+	// UpdateTokenCaptureFailures enables or disables keeping the envelopes of the token's failed requests
+	UpdateTokenCaptureFailures(ctx context.Context, id string, enabled bool) (*entities.Token, error)
+
+	// UpdateTokenExternalUserIDHeader sets the request header metadata.user_id is taken from ("" clears it)
+	UpdateTokenExternalUserIDHeader(ctx context.Context, id string, header string) (*entities.Token, error)
+
+	// RotateTokenKey replaces a token's key with a newly generated one, returned only on the token it returns
+	RotateTokenKey(ctx context.Context, id string) (*entities.Token, error)
+
 	// DeleteToken deletes a token by ID
 	DeleteToken(ctx context.Context, id string) error
 
@@ -46,6 +64,12 @@ type TokenService interface {
 	// Sync syncs in-memory data to persistent storage
 	Sync(ctx context.Context) error
 
+	// Reload merges tokens.json into the cache now, whether or not another writer modified it
+	Reload(ctx context.Context) (*entities.ReconcileReport, error)
+
+	// Mirror replaces the cached tokens with a primary instance's, which win every conflict (warm standby)
+	Mirror(ctx context.Context, tokens []*entities.Token) (*entities.MirrorResult, error)
+
 	// FinalSync performs final sync on shutdown
 	FinalSync(ctx context.Context) error
 }
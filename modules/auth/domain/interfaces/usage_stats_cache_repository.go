@@ -0,0 +1,30 @@
+package interfaces
+
+import (
+	"context"
+	"time"
+
+	"claude-proxy/modules/auth/domain/entities"
+)
+
+// UsageStatsCacheRepository defines the interface for fast, volatile usage bucket storage
+// Buckets are keyed by token ID and bucket start time
+type UsageStatsCacheRepository interface {
+	// AddSample folds a sample into the bucket starting at bucketStart, creating it if needed
+	AddSample(ctx context.Context, bucketStart time.Time, sample *entities.RequestSample) error
+
+	// List returns copies of all buckets starting at or after since
+	List(ctx context.Context, since time.Time) ([]*entities.UsageBucket, error)
+
+	// ListByToken returns copies of a token's buckets starting at or after since
+	ListByToken(ctx context.Context, tokenID string, since time.Time) ([]*entities.UsageBucket, error)
+
+	// DeleteBefore removes buckets starting before cutoff and returns how many were removed
+	DeleteBefore(ctx context.Context, cutoff time.Time) (int, error)
+
+	// ReplaceAll replaces the cache contents (used when loading from persistence)
+	ReplaceAll(ctx context.Context, buckets []*entities.UsageBucket) error
+
+	// CacheStats returns the cache's bucket count, approximate memory usage and soft limit
+	CacheStats() entities.CacheStats
+}
@@ -0,0 +1,16 @@
+package interfaces
+
+import (
+	"context"
+
+	"claude-proxy/modules/auth/domain/entities"
+)
+
+// UsageStatsPersistenceRepository defines the interface for durable usage bucket storage
+type UsageStatsPersistenceRepository interface {
+	// SaveAll persists all buckets to durable storage (batch operation)
+	SaveAll(ctx context.Context, buckets []*entities.UsageBucket) error
+
+	// LoadAll loads all buckets from durable storage
+	LoadAll(ctx context.Context) ([]*entities.UsageBucket, error)
+}
@@ -6,11 +6,14 @@ import (
 	"crypto/sha256"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	mrand "math/rand/v2"
 	"net/http"
 	"net/url"
 	"strings"
+	"sync"
 	"time"
 
 	sctx "github.com/phathdt/service-context"
@@ -23,16 +26,52 @@ type OAuthClient struct {
 	tokenURL     string
 	redirectURI  string
 	scope        string
+	orgsURL      string
+	maxRetries   int
+	retryDelay   time.Duration
+	headers      map[string]string // Client identification headers (User-Agent, x-app, extras)
 	httpClient   *http.Client
+	proxyClients map[string]*http.Client // Egress proxy URL -> dedicated client
+	proxyMu      sync.Mutex
 	logger       sctx.Logger
 }
 
+// TokenRequestError is returned when a token endpoint call fails after all attempts
+// StatusCode is 0 when the failure was a network error (no HTTP response)
+type TokenRequestError struct {
+	StatusCode int
+	Body       string
+	Attempts   int
+	Err        error
+}
+
+func (e *TokenRequestError) Error() string {
+	if e.StatusCode != 0 {
+		return fmt.Sprintf("status %d after %d attempt(s): %s", e.StatusCode, e.Attempts, e.Body)
+	}
+	return fmt.Sprintf("request failed after %d attempt(s): %v", e.Attempts, e.Err)
+}
+
+func (e *TokenRequestError) Unwrap() error {
+	return e.Err
+}
+
 // TokenResponse represents the OAuth token response
 type TokenResponse struct {
 	AccessToken  string `json:"access_token"`
 	RefreshToken string `json:"refresh_token"`
 	TokenType    string `json:"token_type"`
 	ExpiresIn    int    `json:"expires_in"`
+	// Organization is the organization the token was issued for (when returned by the OAuth server)
+	Organization *Organization `json:"organization,omitempty"`
+}
+
+// Organization is a Claude organization the authenticated user belongs to
+type Organization struct {
+	UUID string `json:"uuid"`
+	Name string `json:"name"`
+	// Capabilities lists the organization's features (e.g. "claude_max", "claude_pro"), used for plan detection
+	Capabilities []string `json:"capabilities,omitempty"`
 }
 
 // PKCEChallenge holds PKCE challenge data
@@ -43,17 +82,30 @@ type PKCEChallenge struct {
 }
 
 // NewOAuthClient creates a new OAuth client for Claude authentication
-func NewOAuthClient(clientID, authorizeURL, tokenURL, redirectURI, scope string, logger sctx.Logger) *OAuthClient {
+// Token endpoint calls are retried up to maxRetries times with exponential backoff starting at retryDelay;
+// headers are sent on every token and organizations request
+func NewOAuthClient(
+	clientID, authorizeURL, tokenURL, redirectURI, scope, organizationsURL string,
+	maxRetries int,
+	retryDelay time.Duration,
+	headers map[string]string,
+	logger sctx.Logger,
+) *OAuthClient {
 	return &OAuthClient{
 		clientID:     clientID,
 		authorizeURL: authorizeURL,
 		tokenURL:     tokenURL,
 		redirectURI:  redirectURI,
 		scope:        scope,
+		orgsURL:      organizationsURL,
+		maxRetries:   maxRetries,
+		retryDelay:   retryDelay,
+		headers:      headers,
 		httpClient: &http.Client{
 			Timeout: 30 * time.Second,
 		},
-		logger: logger,
+		proxyClients: make(map[string]*http.Client),
+		logger:       logger,
 	}
 }
 
@@ -145,34 +197,80 @@ func (c *OAuthClient) ExchangeCodeForToken(ctx context.Context, code, codeVerifi
 		"payload": string(jsonData),
 	}).Info("Sending token exchange request to Claude OAuth API")
 
-	req, err := http.NewRequestWithContext(ctx, "POST", c.tokenURL, strings.NewReader(string(jsonData)))
+	statusCode, body, attempts, err := c.postTokenRequest(ctx, c.httpClient, "exchange_code", jsonData, nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create token request: %w", err)
+		return nil, fmt.Errorf("failed to exchange code for token: %w", err)
 	}
 
-	req.Header.Set("Content-Type", "application/json")
+	if statusCode != http.StatusOK {
+		return nil, fmt.Errorf(
+			"token exchange failed: %w",
+			&TokenRequestError{StatusCode: statusCode, Body: string(body), Attempts: attempts},
+		)
+	}
+
+	var tokenResp TokenResponse
+	if err := json.Unmarshal(body, &tokenResp); err != nil {
+		return nil, fmt.Errorf("failed to decode token response: %w", err)
+	}
+
+	return &tokenResp, nil
+}
+
+// ListOrganizations returns every organization the access token's user belongs to
+// Accepts both a bare JSON array and a {"data": [...]} envelope
+func (c *OAuthClient) ListOrganizations(ctx context.Context, accessToken string) ([]Organization, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.orgsURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create organizations request: %w", err)
+	}
+	c.setHeaders(req, nil)
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("anthropic-beta", "oauth-2025-04-20")
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to exchange code for token: %w", err)
+		return nil, fmt.Errorf("failed to fetch organizations: %w", err)
 	}
 	defer resp.Body.Close()
 
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read organizations response: %w", err)
+	}
+
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("token exchange failed with status %d: %s", resp.StatusCode, string(body))
+		return nil, fmt.Errorf("organizations request failed with status %d: %s", resp.StatusCode, string(body))
 	}
 
-	var tokenResp TokenResponse
-	if err := json.NewDecoder(resp.Body).Decode(&tokenResp); err != nil {
-		return nil, fmt.Errorf("failed to decode token response: %w", err)
+	var orgs []Organization
+	if err := json.Unmarshal(body, &orgs); err != nil {
+		var envelope struct {
+			Data []Organization `json:"data"`
+		}
+		if err := json.Unmarshal(body, &envelope); err != nil {
+			return nil, fmt.Errorf("failed to decode organizations response: %w", err)
+		}
+		orgs = envelope.Data
 	}
 
-	return &tokenResp, nil
+	c.logger.Withs(sctx.Fields{"count": len(orgs)}).Debug("Fetched organizations")
+	return orgs, nil
 }
 
 // RefreshAccessToken uses refresh token to get a new access token
-func (c *OAuthClient) RefreshAccessToken(ctx context.Context, refreshToken string) (*TokenResponse, error) {
+// headers override the configured identification headers for this request (the account's overrides)
+func (c *OAuthClient) RefreshAccessToken(
+	ctx context.Context,
+	refreshToken, proxyURL string,
+	headers map[string]string,
+) (*TokenResponse, error) {
+	httpClient, err := c.clientFor(proxyURL)
+	if err != nil {
+		return nil, err
+	}
+
 	c.logger.Withs(sctx.Fields{
 		"action": "refresh_token_start",
 		"url":    c.tokenURL,
@@ -195,24 +293,12 @@ func (c *OAuthClient) RefreshAccessToken(ctx context.Context, refreshToken strin
 		return nil, fmt.Errorf("failed to marshal refresh request: %w", err)
 	}
 
-	req, err := http.NewRequestWithContext(ctx, "POST", c.tokenURL, strings.NewReader(string(jsonData)))
-	if err != nil {
-		c.logger.Withs(sctx.Fields{
-			"action": "refresh_token_error",
-			"error":  err.Error(),
-			"stage":  "create_request",
-		}).Error("Failed to create refresh request")
-		return nil, fmt.Errorf("failed to create refresh request: %w", err)
-	}
-
-	req.Header.Set("Content-Type", "application/json")
-
 	c.logger.Withs(sctx.Fields{
 		"action": "refresh_token_request_sent",
 		"url":    c.tokenURL,
 	}).Info("Sending token refresh request to OAuth server")
 
-	resp, err := c.httpClient.Do(req)
+	statusCode, body, attempts, err := c.postTokenRequest(ctx, httpClient, "refresh_token", jsonData, headers)
 	if err != nil {
 		c.logger.Withs(sctx.Fields{
 			"action": "refresh_token_error",
@@ -221,43 +307,31 @@ func (c *OAuthClient) RefreshAccessToken(ctx context.Context, refreshToken strin
 		}).Error("Failed to refresh token (HTTP request failed)")
 		return nil, fmt.Errorf("failed to refresh token: %w", err)
 	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		c.logger.Withs(sctx.Fields{
-			"action":      "refresh_token_error",
-			"error":       err.Error(),
-			"stage":       "read_response",
-			"status_code": resp.StatusCode,
-		}).Error("Failed to read refresh response body")
-		return nil, fmt.Errorf("failed to read response body: %w", err)
-	}
 
 	// Log the entire response for debugging
 	c.logger.Withs(sctx.Fields{
 		"action":      "refresh_token_response",
-		"status_code": resp.StatusCode,
+		"status_code": statusCode,
 	}).Debug("=== OAUTH2 TOKEN REFRESH RESPONSE START ===")
 
-	c.logger.Withs(sctx.Fields{
-		"headers": resp.Header,
-	}).Debug("Response Headers")
-
 	c.logger.Withs(sctx.Fields{
 		"body": string(body),
 	}).Debug("Response Body")
 
 	c.logger.Debug("=== OAUTH2 TOKEN REFRESH RESPONSE END ===")
 
-	if resp.StatusCode != http.StatusOK {
+	if statusCode != http.StatusOK {
 		c.logger.Withs(sctx.Fields{
 			"action":      "refresh_token_error",
-			"status_code": resp.StatusCode,
+			"status_code": statusCode,
 			"error_body":  string(body),
+			"attempts":    attempts,
 			"stage":       "http_status",
 		}).Error("Token refresh failed with non-200 status")
-		return nil, fmt.Errorf("token refresh failed with status %d: %s", resp.StatusCode, string(body))
+		return nil, fmt.Errorf(
+			"token refresh failed: %w",
+			&TokenRequestError{StatusCode: statusCode, Body: string(body), Attempts: attempts},
+		)
 	}
 
 	var tokenResp TokenResponse
@@ -281,6 +355,158 @@ func (c *OAuthClient) RefreshAccessToken(ctx context.Context, refreshToken strin
 	return &tokenResp, nil
 }
 
+// postTokenRequest POSTs a JSON payload to the token endpoint with retries
+// Retries network errors and 5xx/429 responses with exponential backoff and jitter.
+// 4xx responses (bad grant, unauthorized) are returned immediately without retrying.
+// Returns the final status code, body and number of attempts; errors are *TokenRequestError.
+func (c *OAuthClient) postTokenRequest(
+	ctx context.Context,
+	httpClient *http.Client,
+	action string,
+	jsonData []byte,
+	headers map[string]string,
+) (int, []byte, int, error) {
+	maxAttempts := c.maxRetries + 1
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+
+	var lastErr *TokenRequestError
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		statusCode, body, err := c.doTokenRequest(ctx, httpClient, jsonData, headers)
+
+		switch {
+		case err != nil:
+			lastErr = &TokenRequestError{Attempts: attempt, Err: err}
+		case statusCode >= 500 || statusCode == http.StatusTooManyRequests:
+			lastErr = &TokenRequestError{
+				StatusCode: statusCode,
+				Body:       string(body),
+				Attempts:   attempt,
+				Err:        fmt.Errorf("retryable status %d", statusCode),
+			}
+		default:
+			if attempt > 1 {
+				c.logger.Withs(sctx.Fields{
+					"action":      action,
+					"attempt":     attempt,
+					"status_code": statusCode,
+				}).Info("Token endpoint request succeeded after retry")
+			}
+			return statusCode, body, attempt, nil
+		}
+
+		// Never retry once the caller gave up
+		if ctx.Err() != nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			break
+		}
+
+		if attempt == maxAttempts {
+			break
+		}
+
+		delay := c.backoffDelay(attempt)
+		c.logger.Withs(sctx.Fields{
+			"action":       action,
+			"attempt":      attempt,
+			"max_attempts": maxAttempts,
+			"status_code":  lastErr.StatusCode,
+			"error":        lastErr.Error(),
+			"retry_in":     delay.String(),
+		}).Warn("Token endpoint request failed, retrying")
+
+		select {
+		case <-ctx.Done():
+			lastErr.Err = ctx.Err()
+			return 0, nil, lastErr.Attempts, lastErr
+		case <-time.After(delay):
+		}
+	}
+
+	return 0, nil, lastErr.Attempts, lastErr
+}
+
+// doTokenRequest performs a single POST to the token endpoint
+func (c *OAuthClient) doTokenRequest(
+	ctx context.Context,
+	httpClient *http.Client,
+	jsonData []byte,
+	headers map[string]string,
+) (int, []byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", c.tokenURL, strings.NewReader(string(jsonData)))
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to create token request: %w", err)
+	}
+
+	c.setHeaders(req, headers)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	return resp.StatusCode, body, nil
+}
+
+// setHeaders adds the identification headers to req, overrides taking precedence over the configured ones
+func (c *OAuthClient) setHeaders(req *http.Request, overrides map[string]string) {
+	for name, value := range c.headers {
+		req.Header.Set(name, value)
+	}
+	for name, value := range overrides {
+		req.Header.Set(name, value)
+	}
+}
+
+// clientFor returns the HTTP client for an egress proxy URL (the default client when empty)
+// Clients are created once per distinct proxy URL and reused
+func (c *OAuthClient) clientFor(proxyURL string) (*http.Client, error) {
+	if proxyURL == "" {
+		return c.httpClient, nil
+	}
+
+	c.proxyMu.Lock()
+	defer c.proxyMu.Unlock()
+
+	if client, ok := c.proxyClients[proxyURL]; ok {
+		return client, nil
+	}
+
+	u, err := url.Parse(proxyURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid egress proxy URL: %w", err)
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = http.ProxyURL(u)
+
+	client := &http.Client{
+		Timeout:   c.httpClient.Timeout,
+		Transport: transport,
+	}
+	c.proxyClients[proxyURL] = client
+	return client, nil
+}
+
+// backoffDelay returns retryDelay * 2^(attempt-1) plus up to 50% random jitter
+func (c *OAuthClient) backoffDelay(attempt int) time.Duration {
+	base := c.retryDelay
+	if base <= 0 {
+		base = time.Second
+	}
+
+	delay := base << (attempt - 1)
+	jitter := time.Duration(mrand.Int64N(int64(delay)/2 + 1))
+	return delay + jitter
+}
+
 // generateRandomString generates a cryptographically secure random string
 func generateRandomString(length int) (string, error) {
 	bytes := make([]byte, length)
@@ -0,0 +1,160 @@
+package clients
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	sctx "github.com/phathdt/service-context"
+)
+
+const testTokenResponse = `{"access_token":"new-access","refresh_token":"new-refresh","expires_in":3600}`
+
+// flakyTokenServer fails the first failures requests with fail, then answers with a token
+func flakyTokenServer(
+	t *testing.T,
+	failures int32,
+	fail func(w http.ResponseWriter),
+) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if hits.Add(1) <= failures {
+			fail(w)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(testTokenResponse))
+	}))
+	t.Cleanup(server.Close)
+	return server, &hits
+}
+
+// failWithStatus answers with status
+func failWithStatus(status int) func(w http.ResponseWriter) {
+	return func(w http.ResponseWriter) {
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(`{"error":"failed"}`))
+	}
+}
+
+// dropConnection closes the connection without answering (a network error for the client)
+func dropConnection(w http.ResponseWriter) {
+	conn, _, err := w.(http.Hijacker).Hijack()
+	if err == nil {
+		conn.Close()
+	}
+}
+
+func newTestOAuthClient(tokenURL string, maxRetries int) *OAuthClient {
+	return NewOAuthClient(
+		"client-id", "", tokenURL, "", "", "",
+		maxRetries, time.Millisecond, nil,
+		sctx.GlobalLogger().GetLogger("test"),
+	)
+}
+
+func TestRefreshAccessTokenRetriesTransientFailures(t *testing.T) {
+	tests := []struct {
+		name string
+		fail func(w http.ResponseWriter)
+	}{
+		{"server error", failWithStatus(http.StatusInternalServerError)},
+		{"bad gateway", failWithStatus(http.StatusBadGateway)},
+		{"rate limited", failWithStatus(http.StatusTooManyRequests)},
+		{"network error", dropConnection},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, hits := flakyTokenServer(t, 2, tt.fail)
+			client := newTestOAuthClient(server.URL, 3)
+
+			resp, err := client.RefreshAccessToken(context.Background(), "refresh", "", nil)
+			if err != nil {
+				t.Fatalf("RefreshAccessToken() error = %v", err)
+			}
+			if resp.AccessToken != "new-access" {
+				t.Errorf("access token = %q, want new-access", resp.AccessToken)
+			}
+			if hits.Load() != 3 {
+				t.Errorf("token endpoint hit %d times, want 3", hits.Load())
+			}
+		})
+	}
+}
+
+func TestRefreshAccessTokenDoesNotRetryClientErrors(t *testing.T) {
+	for _, status := range []int{http.StatusBadRequest, http.StatusUnauthorized} {
+		t.Run(http.StatusText(status), func(t *testing.T) {
+			server, hits := flakyTokenServer(t, 10, failWithStatus(status))
+			client := newTestOAuthClient(server.URL, 3)
+
+			_, err := client.RefreshAccessToken(context.Background(), "refresh", "", nil)
+			var tokenErr *TokenRequestError
+			if !errors.As(err, &tokenErr) {
+				t.Fatalf("RefreshAccessToken() error = %v, want a *TokenRequestError", err)
+			}
+			if tokenErr.StatusCode != status || tokenErr.Attempts != 1 {
+				t.Errorf("error status = %d, attempts = %d; want %d and 1",
+					tokenErr.StatusCode, tokenErr.Attempts, status)
+			}
+			if hits.Load() != 1 {
+				t.Errorf("token endpoint hit %d times, want 1", hits.Load())
+			}
+		})
+	}
+}
+
+func TestRefreshAccessTokenReportsAttemptsWhenExhausted(t *testing.T) {
+	tests := []struct {
+		name       string
+		fail       func(w http.ResponseWriter)
+		wantStatus int
+	}{
+		{"server error", failWithStatus(http.StatusServiceUnavailable), http.StatusServiceUnavailable},
+		{"network error", dropConnection, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, hits := flakyTokenServer(t, 10, tt.fail)
+			client := newTestOAuthClient(server.URL, 2)
+
+			_, err := client.RefreshAccessToken(context.Background(), "refresh", "", nil)
+			var tokenErr *TokenRequestError
+			if !errors.As(err, &tokenErr) {
+				t.Fatalf("RefreshAccessToken() error = %v, want a *TokenRequestError", err)
+			}
+			if tokenErr.Attempts != 3 || tokenErr.StatusCode != tt.wantStatus {
+				t.Errorf("error attempts = %d, status = %d; want 3 and %d",
+					tokenErr.Attempts, tokenErr.StatusCode, tt.wantStatus)
+			}
+			if hits.Load() != 3 {
+				t.Errorf("token endpoint hit %d times, want 3", hits.Load())
+			}
+		})
+	}
+}
+
+func TestRefreshAccessTokenStopsRetryingWhenCanceled(t *testing.T) {
+	server, hits := flakyTokenServer(t, 10, failWithStatus(http.StatusInternalServerError))
+	client := newTestOAuthClient(server.URL, 5)
+	client.retryDelay = time.Hour
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err := client.RefreshAccessToken(ctx, "refresh", "", nil)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("RefreshAccessToken() error = %v, want context.DeadlineExceeded", err)
+	}
+	if hits.Load() != 1 {
+		t.Errorf("token endpoint hit %d times, want 1", hits.Load())
+	}
+}
@@ -0,0 +1,74 @@
+package clients
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxPrimaryErrorBody caps how much of an error response of the primary is kept in the error
+const maxPrimaryErrorBody = 512
+
+// PrimaryClient pulls state bundles from the export endpoint of a primary instance (warm standby)
+type PrimaryClient struct {
+	exportURL  string
+	apiKey     string
+	httpClient *http.Client
+}
+
+// NewPrimaryClient creates a client of the primary at baseURL, authenticated with one of its admin keys;
+// timeout bounds each pull
+func NewPrimaryClient(baseURL, apiKey string, timeout time.Duration) *PrimaryClient {
+	return &PrimaryClient{
+		exportURL:  strings.TrimRight(baseURL, "/") + "/api/admin/export",
+		apiKey:     apiKey,
+		httpClient: &http.Client{Timeout: timeout},
+	}
+}
+
+// ExportBundle requests a state bundle encrypted with passphrase and returns it with its ETag
+// When etag (the ETag of the last applied bundle) still matches the primary's data, the primary answers
+// 304 Not Modified and the returned bundle is nil.
+func (c *PrimaryClient) ExportBundle(ctx context.Context, passphrase, etag string) ([]byte, string, error) {
+	body, err := json.Marshal(map[string]string{"passphrase": passphrase})
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to marshal export request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.exportURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create export request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "claude-proxy-standby")
+	req.Header.Set("X-API-Key", c.apiKey)
+	if etag != "" {
+		req.Header.Set("If-None-Match", strconv.Quote(etag))
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified {
+		return nil, etag, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, maxPrimaryErrorBody))
+		return nil, "", fmt.Errorf("primary returned status %d: %s", resp.StatusCode, data)
+	}
+
+	bundle, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read state bundle: %w", err)
+	}
+	return bundle, strings.Trim(resp.Header.Get("ETag"), `"`), nil
+}
@@ -0,0 +1,92 @@
+package jobs
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"claude-proxy/modules/auth/domain/interfaces"
+
+	sctx "github.com/phathdt/service-context"
+	"github.com/robfig/cron/v3"
+)
+
+// BackupScheduler handles periodic backups of the data folder
+type BackupScheduler struct {
+	backupService interfaces.BackupService
+	interval      time.Duration
+	cron          *cron.Cron
+	mu            sync.Mutex
+	logger        sctx.Logger
+}
+
+// NewBackupScheduler creates a new backup scheduler
+func NewBackupScheduler(
+	backupService interfaces.BackupService,
+	interval time.Duration,
+	appLogger sctx.Logger,
+) *BackupScheduler {
+	logger := appLogger.Withs(sctx.Fields{"component": "backup-scheduler"})
+
+	return &BackupScheduler{
+		backupService: backupService,
+		interval:      interval,
+		cron:          cron.New(),
+		logger:        logger,
+	}
+}
+
+// Start starts the backup scheduler
+func (s *BackupScheduler) Start() error {
+	s.logger.Withs(sctx.Fields{
+		"interval": s.interval.String(),
+	}).Info("Starting backup scheduler")
+
+	cronExpr := "@every " + s.interval.String()
+
+	_, err := s.cron.AddFunc(cronExpr, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		s.runBackup()
+	})
+	if err != nil {
+		s.logger.Withs(sctx.Fields{"error": err}).Error("Failed to schedule backup job")
+		return err
+	}
+
+	s.cron.Start()
+	s.logger.Info("Backup scheduler started")
+
+	return nil
+}
+
+// Stop stops the backup scheduler
+func (s *BackupScheduler) Stop() {
+	s.logger.Info("Stopping backup scheduler")
+	s.cron.Stop()
+}
+
+// runBackup executes the backup job
+func (s *BackupScheduler) runBackup() {
+	start := time.Now()
+	s.logger.Debug("Running backup job")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	backup, err := s.backupService.CreateBackup(ctx)
+	if err != nil {
+		s.logger.Withs(sctx.Fields{
+			"error":    err.Error(),
+			"duration": time.Since(start).String(),
+		}).Error("Backup job failed")
+		return
+	}
+
+	s.logger.Withs(sctx.Fields{
+		"backup":   backup.Name,
+		"size":     backup.Size,
+		"duration": time.Since(start).String(),
+	}).Info("Backup job completed")
+}
@@ -2,24 +2,40 @@ package jobs
 
 import (
 	"context"
+	"fmt"
 	"sync"
 	"time"
 
+	"claude-proxy/modules/auth/domain/entities"
 	"claude-proxy/modules/auth/domain/interfaces"
 
 	sctx "github.com/phathdt/service-context"
 	"github.com/robfig/cron/v3"
 )
 
+// Syncer is an in-memory store of another module that the sync job also writes to persistent storage
+type Syncer interface {
+	Sync(ctx context.Context) error
+	FinalSync(ctx context.Context) error
+}
+
 // SyncScheduler handles periodic sync of in-memory data to persistent storage
 type SyncScheduler struct {
 	accountService interfaces.AccountService
 	tokenService   interfaces.TokenService
 	sessionService interfaces.SessionService
+	statsService   interfaces.StatisticsService
+	batchService   Syncer // Message batch index of the proxy module
+	fileService    Syncer // Uploaded file index of the proxy module
+	upstreamUsage  Syncer // Official and locally counted daily usage of the proxy module
 	interval       time.Duration
 	cron           *cron.Cron
 	mu             sync.Mutex
 	logger         sctx.Logger
+
+	statusMu sync.RWMutex
+	running  bool
+	lastSync time.Time // Last sync with no failed target (start time until the first one)
 }
 
 // NewSyncScheduler creates a new sync scheduler
@@ -27,6 +43,10 @@ func NewSyncScheduler(
 	accountService interfaces.AccountService,
 	tokenService interfaces.TokenService,
 	sessionService interfaces.SessionService,
+	statsService interfaces.StatisticsService,
+	batchService Syncer,
+	fileService Syncer,
+	upstreamUsage Syncer,
 	syncInterval time.Duration,
 	appLogger sctx.Logger,
 ) *SyncScheduler {
@@ -36,6 +56,10 @@ func NewSyncScheduler(
 		accountService: accountService,
 		tokenService:   tokenService,
 		sessionService: sessionService,
+		statsService:   statsService,
+		batchService:   batchService,
+		fileService:    fileService,
+		upstreamUsage:  upstreamUsage,
 		interval:       syncInterval,
 		cron:           cron.New(),
 		logger:         logger,
@@ -77,6 +101,11 @@ func (s *SyncScheduler) Start() error {
 	}
 
 	s.cron.Start()
+	s.statusMu.Lock()
+	s.running = true
+	s.lastSync = time.Now()
+	s.statusMu.Unlock()
+
 	s.logger.Withs(sctx.Fields{
 		"schedule": cronExpr,
 	}).Info("Sync scheduler started")
@@ -88,9 +117,55 @@ func (s *SyncScheduler) Start() error {
 func (s *SyncScheduler) Stop() {
 	s.logger.Info("Stopping sync scheduler")
 	s.cron.Stop()
+
+	s.statusMu.Lock()
+	s.running = false
+	s.statusMu.Unlock()
+}
+
+// CheckHealth is a readiness check: the scheduler must be running and have synced successfully within
+// the last 3 intervals
+func (s *SyncScheduler) CheckHealth(ctx context.Context) error {
+	s.statusMu.RLock()
+	defer s.statusMu.RUnlock()
+
+	if !s.running {
+		return fmt.Errorf("sync scheduler is not running")
+	}
+	if since := time.Since(s.lastSync); since > 3*s.interval {
+		return fmt.Errorf("last successful sync was %s ago (interval %s)", since.Round(time.Second), s.interval)
+	}
+	return nil
+}
+
+// slowSyncThreshold is the sync job duration above which disk IO is reported as slow
+const slowSyncThreshold = 2 * time.Second
+
+// syncTarget is a service flushed to persistent storage by the sync job
+type syncTarget struct {
+	name  string // Used in log messages
+	field string // Log field holding the service's sync duration
+	sync  func(ctx context.Context) error
+}
+
+// targets returns the services to sync, in write order
+func (s *SyncScheduler) targets() []syncTarget {
+	return []syncTarget{
+		{name: "accounts", field: "accounts_ms", sync: s.accountService.Sync},
+		{name: "tokens", field: "tokens_ms", sync: s.tokenService.Sync},
+		{name: "sessions", field: "sessions_ms", sync: s.sessionService.Sync},
+		// Also drops expired buckets
+		{name: "usage statistics", field: "stats_ms", sync: s.statsService.Sync},
+		// Also drops expired mappings
+		{name: "message batches", field: "batches_ms", sync: s.batchService.Sync},
+		{name: "uploaded files", field: "files_ms", sync: s.fileService.Sync},
+		// Also drops days past retention
+		{name: "upstream usage", field: "upstream_usage_ms", sync: s.upstreamUsage.Sync},
+	}
 }
 
 // runSync executes the sync job
+// Services are written one after another (only the dirty ones touch the disk), with each write timed
 func (s *SyncScheduler) runSync() {
 	start := time.Now()
 	s.logger.Debug("Running sync job")
@@ -98,34 +173,55 @@ func (s *SyncScheduler) runSync() {
 	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
 	defer cancel()
 
-	// Sync accounts
-	if err := s.accountService.Sync(ctx); err != nil {
-		s.logger.Withs(sctx.Fields{
-			"error": err.Error(),
-		}).Error("Failed to sync accounts")
+	fields := sctx.Fields{}
+	failed := false
+	for _, target := range s.targets() {
+		targetStart := time.Now()
+		if err := target.sync(ctx); err != nil {
+			failed = true
+			s.logger.Withs(sctx.Fields{
+				"error": err.Error(),
+			}).Error("Failed to sync " + target.name)
+		}
+		fields[target.field] = time.Since(targetStart).Milliseconds()
 	}
-
-	// Sync tokens
-	if err := s.tokenService.Sync(ctx); err != nil {
-		s.logger.Withs(sctx.Fields{
-			"error": err.Error(),
-		}).Error("Failed to sync tokens")
+	if !failed {
+		s.statusMu.Lock()
+		s.lastSync = time.Now()
+		s.statusMu.Unlock()
 	}
 
-	// Sync sessions
-	if err := s.sessionService.Sync(ctx); err != nil {
-		s.logger.Withs(sctx.Fields{
-			"error": err.Error(),
-		}).Error("Failed to sync sessions")
+	duration := time.Since(start)
+	fields["duration"] = duration.String()
+	if duration >= slowSyncThreshold {
+		s.logger.Withs(fields).Warn("Sync job slow, disk IO may be degraded")
+		return
 	}
+	s.logger.Withs(fields).Debug("Sync job completed")
+}
 
-	s.logger.Withs(sctx.Fields{
-		"duration": time.Since(start).String(),
-	}).Debug("Sync job completed")
+// Reload merges accounts.json and tokens.json into the in-memory stores now, between two sync jobs
+// Used after editing the files by hand, so the edits apply without waiting for the next sync
+func (s *SyncScheduler) Reload(ctx context.Context) (accounts, tokens *entities.ReconcileReport, err error) {
+	// Never interleave with a sync job writing the same files
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if accounts, err = s.accountService.Reload(ctx); err != nil {
+		return nil, nil, err
+	}
+	if tokens, err = s.tokenService.Reload(ctx); err != nil {
+		return nil, nil, err
+	}
+	return accounts, tokens, nil
 }
 
 // FinalSync performs final sync before shutdown
 func (s *SyncScheduler) FinalSync() error {
+	// Wait for a sync job still running after Stop, so the same files are never written concurrently
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	s.logger.Info("Performing final sync before shutdown")
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
@@ -147,6 +243,26 @@ func (s *SyncScheduler) FinalSync() error {
 		return err
 	}
 
+	if err := s.statsService.FinalSync(ctx); err != nil {
+		s.logger.Withs(sctx.Fields{"error": err}).Error("Failed final sync of usage statistics")
+		return err
+	}
+
+	if err := s.batchService.FinalSync(ctx); err != nil {
+		s.logger.Withs(sctx.Fields{"error": err}).Error("Failed final sync of message batches")
+		return err
+	}
+
+	if err := s.fileService.FinalSync(ctx); err != nil {
+		s.logger.Withs(sctx.Fields{"error": err}).Error("Failed final sync of uploaded files")
+		return err
+	}
+
+	if err := s.upstreamUsage.FinalSync(ctx); err != nil {
+		s.logger.Withs(sctx.Fields{"error": err}).Error("Failed final sync of upstream usage")
+		return err
+	}
+
 	s.logger.Info("Final sync completed successfully")
 	return nil
 }
@@ -0,0 +1,176 @@
+package repositories
+
+import (
+	"context"
+
+	"claude-proxy/modules/auth/domain/entities"
+	"claude-proxy/modules/auth/domain/interfaces"
+	"claude-proxy/pkg/actor"
+)
+
+// AuditedAccountRepository wraps the account cache to record admin changes in the audit trail
+// Writes made with an actor in their context (admin requests) stamp CreatedBy/UpdatedBy on the account and
+// are recorded with the fields they changed; background writes (refreshes, usage, reloads at startup) pass
+// through untouched.
+type AuditedAccountRepository struct {
+	interfaces.CacheRepository
+	audit interfaces.MutationAuditService
+}
+
+// NewAuditedAccountRepository wraps an account cache with the audit trail
+func NewAuditedAccountRepository(
+	inner interfaces.CacheRepository,
+	audit interfaces.MutationAuditService,
+) interfaces.CacheRepository {
+	return &AuditedAccountRepository{CacheRepository: inner, audit: audit}
+}
+
+// Create creates the account, recording its actor as creator
+func (r *AuditedAccountRepository) Create(ctx context.Context, account *entities.Account) error {
+	identity := actor.FromContext(ctx)
+	if identity == "" {
+		return r.CacheRepository.Create(ctx, account)
+	}
+
+	if account.CreatedBy == "" {
+		account.CreatedBy = identity
+	}
+	account.UpdatedBy = identity
+	if err := r.CacheRepository.Create(ctx, account); err != nil {
+		return err
+	}
+	r.audit.RecordAccount(ctx, nil, account)
+	return nil
+}
+
+// Update updates the account, recording the fields its actor changed
+func (r *AuditedAccountRepository) Update(ctx context.Context, account *entities.Account) error {
+	identity := actor.FromContext(ctx)
+	if identity == "" {
+		return r.CacheRepository.Update(ctx, account)
+	}
+
+	before, err := r.CacheRepository.GetByID(ctx, account.ID)
+	if err != nil {
+		return r.CacheRepository.Update(ctx, account) // Fails the same way, with the cache's error
+	}
+	account.UpdatedBy = identity
+	if err := r.CacheRepository.Update(ctx, account); err != nil {
+		return err
+	}
+	r.audit.RecordAccount(ctx, before, account)
+	return nil
+}
+
+// UpdateWith updates the account atomically, recording the fields its actor changed
+func (r *AuditedAccountRepository) UpdateWith(
+	ctx context.Context,
+	id string,
+	mutate func(account *entities.Account) error,
+) (*entities.Account, error) {
+	identity := actor.FromContext(ctx)
+	if identity == "" {
+		return r.CacheRepository.UpdateWith(ctx, id, mutate)
+	}
+
+	var before *entities.Account
+	account, err := r.CacheRepository.UpdateWith(ctx, id, func(account *entities.Account) error {
+		before = account.Clone()
+		if err := mutate(account); err != nil {
+			return err
+		}
+		account.UpdatedBy = identity
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	r.audit.RecordAccount(ctx, before, account)
+	return account, nil
+}
+
+// Delete deletes the account, recording its actor
+func (r *AuditedAccountRepository) Delete(ctx context.Context, id string) error {
+	if actor.FromContext(ctx) == "" {
+		return r.CacheRepository.Delete(ctx, id)
+	}
+
+	before, err := r.CacheRepository.GetByID(ctx, id)
+	if err != nil {
+		return r.CacheRepository.Delete(ctx, id)
+	}
+	if err := r.CacheRepository.Delete(ctx, id); err != nil {
+		return err
+	}
+	r.audit.RecordAccount(ctx, before, nil)
+	return nil
+}
+
+// AuditedTokenRepository wraps the token cache to record admin changes in the audit trail, like
+// AuditedAccountRepository
+type AuditedTokenRepository struct {
+	interfaces.TokenCacheRepository
+	audit interfaces.MutationAuditService
+}
+
+// NewAuditedTokenRepository wraps a token cache with the audit trail
+func NewAuditedTokenRepository(
+	inner interfaces.TokenCacheRepository,
+	audit interfaces.MutationAuditService,
+) interfaces.TokenCacheRepository {
+	return &AuditedTokenRepository{TokenCacheRepository: inner, audit: audit}
+}
+
+// Create creates the token, recording its actor as creator
+func (r *AuditedTokenRepository) Create(ctx context.Context, token *entities.Token) error {
+	identity := actor.FromContext(ctx)
+	if identity == "" {
+		return r.TokenCacheRepository.Create(ctx, token)
+	}
+
+	if token.CreatedBy == "" {
+		token.CreatedBy = identity
+	}
+	token.UpdatedBy = identity
+	if err := r.TokenCacheRepository.Create(ctx, token); err != nil {
+		return err
+	}
+	r.audit.RecordToken(ctx, nil, token)
+	return nil
+}
+
+// Update updates the token, recording the fields its actor changed
+func (r *AuditedTokenRepository) Update(ctx context.Context, token *entities.Token) error {
+	identity := actor.FromContext(ctx)
+	if identity == "" {
+		return r.TokenCacheRepository.Update(ctx, token)
+	}
+
+	before, err := r.TokenCacheRepository.GetByID(ctx, token.ID)
+	if err != nil {
+		return r.TokenCacheRepository.Update(ctx, token)
+	}
+	token.UpdatedBy = identity
+	if err := r.TokenCacheRepository.Update(ctx, token); err != nil {
+		return err
+	}
+	r.audit.RecordToken(ctx, before, token)
+	return nil
+}
+
+// Delete deletes the token, recording its actor
+func (r *AuditedTokenRepository) Delete(ctx context.Context, id string) error {
+	if actor.FromContext(ctx) == "" {
+		return r.TokenCacheRepository.Delete(ctx, id)
+	}
+
+	before, err := r.TokenCacheRepository.GetByID(ctx, id)
+	if err != nil {
+		return r.TokenCacheRepository.Delete(ctx, id)
+	}
+	if err := r.TokenCacheRepository.Delete(ctx, id); err != nil {
+		return err
+	}
+	r.audit.RecordToken(ctx, before, nil)
+	return nil
+}
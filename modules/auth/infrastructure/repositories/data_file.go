@@ -0,0 +1,127 @@
+package repositories
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"claude-proxy/pkg/atomicfile"
+	"claude-proxy/pkg/filelock"
+
+	sctx "github.com/phathdt/service-context"
+)
+
+// errEmptySave is returned by SaveAll when asked to replace a data file holding entries with none, without force
+var errEmptySave = errors.New("refusing to replace a non-empty data file with an empty one")
+
+// withFileLock runs fn while holding the cross-process lock of the data file at path, so CLI commands
+// and the server never interleave their read-modify-write cycles on the same file
+func withFileLock(path string, fn func() error) error {
+	lock, err := filelock.Acquire(path)
+	if err != nil {
+		return err
+	}
+	defer lock.Release()
+
+	return fn()
+}
+
+// fileState identifies one version of a data file, to notice writes made by other processes
+type fileState struct {
+	exists  bool
+	size    int64
+	modTime time.Time
+	sum     [sha256.Size]byte
+}
+
+// readFileState reads the data file at path and returns its content with its state
+// A missing file is not an error: it returns no data and a state with exists unset
+func readFileState(path string) ([]byte, fileState, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fileState{}, nil
+		}
+		return nil, fileState{}, err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fileState{}, err
+	}
+	return data, fileState{exists: true, size: info.Size(), modTime: info.ModTime(), sum: sha256.Sum256(data)}, nil
+}
+
+// differs returns true if the file no longer matches state s
+// The checksum is only compared when size or modification time changed, so touching the file
+// without changing its content does not count as a change
+func (s fileState) differs(current fileState) bool {
+	if s.exists != current.exists {
+		return true
+	}
+	if s.size == current.size && s.modTime.Equal(current.modTime) {
+		return false
+	}
+	return s.sum != current.sum
+}
+
+// readDataFile reads the data file at path like readFileState, falling back to its backup (the previous
+// generation kept by atomicfile.WriteVerified) when the file is not valid JSON but the backup is
+// The fallback is logged as an error. The returned state stays the main file's, so Changed compares against it
+// and the next save replaces the corrupt file.
+func readDataFile(path string, logger sctx.Logger) ([]byte, fileState, error) {
+	data, state, err := readFileState(path)
+	if err != nil || !state.exists || json.Valid(data) {
+		return data, state, err
+	}
+
+	backupFile := path + atomicfile.BackupSuffix
+	backup, err := os.ReadFile(backupFile)
+	if err != nil || !json.Valid(backup) {
+		return data, state, nil // Parsing the main file reports the corruption
+	}
+
+	logger.Withs(sctx.Fields{
+		"file":   path,
+		"backup": backupFile,
+	}).Error("Data file is corrupt, loaded its previous generation from the backup instead; changes since are lost")
+	return backup, state, nil
+}
+
+// checkEmptySave refuses, unless force is set, to replace the data file at path with no entries while it holds
+// some (or can't be read): an empty save most likely comes from a cache that failed to load, not from deleting
+// everything
+func checkEmptySave(path string, entries int, force bool, countEntries func(data []byte) (int, error)) error {
+	if entries > 0 || force {
+		return nil
+	}
+
+	data, state, err := readFileState(path)
+	if err != nil {
+		return fmt.Errorf("%w: failed to read %s: %v", errEmptySave, filepath.Base(path), err)
+	}
+	if !state.exists {
+		return nil
+	}
+	count, err := countEntries(data)
+	if err != nil {
+		return fmt.Errorf("%w: %s can't be parsed: %v", errEmptySave, filepath.Base(path), err)
+	}
+	if count > 0 {
+		return fmt.Errorf("%w: %s holds %d entries", errEmptySave, filepath.Base(path), count)
+	}
+	return nil
+}
+
+// countJSONArray counts the entries of a data file holding a JSON array
+func countJSONArray(data []byte) (int, error) {
+	var entries []json.RawMessage
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return 0, err
+	}
+	return len(entries), nil
+}
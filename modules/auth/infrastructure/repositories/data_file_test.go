@@ -0,0 +1,157 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"claude-proxy/modules/auth/domain/entities"
+	"claude-proxy/pkg/atomicfile"
+)
+
+// writeDataFile writes content to name in dir, skipping it when content is nil, and returns its path
+func writeDataFile(t *testing.T, dir, name string, content []byte) string {
+	t.Helper()
+
+	path := filepath.Join(dir, name)
+	if content != nil {
+		if err := os.WriteFile(path, content, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return path
+}
+
+func TestCheckEmptySave(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing []byte // Content of the data file; nil when missing
+		entries  int
+		force    bool
+		wantErr  bool
+	}{
+		{"missing file", nil, 0, false, false},
+		{"empty array", []byte(`[]`), 0, false, false},
+		{"file with entries", []byte(`[{"id":"a"},{"id":"b"}]`), 0, false, true},
+		{"file with entries, forced", []byte(`[{"id":"a"}]`), 0, true, false},
+		{"entries to save", []byte(`[{"id":"a"}]`), 1, false, false},
+		{"truncated file", []byte(`[{"id":"a"},{"i`), 0, false, true},
+		{"truncated file, forced", []byte(`[{"id":"a"},{"i`), 0, true, false},
+		{"empty file", []byte{}, 0, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeDataFile(t, t.TempDir(), "tokens.json", tt.existing)
+
+			err := checkEmptySave(path, tt.entries, tt.force, countJSONArray)
+			if tt.wantErr != errors.Is(err, errEmptySave) {
+				t.Errorf("checkEmptySave() = %v, want refusal %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestReadDataFileFallsBackToBackup(t *testing.T) {
+	const (
+		current = `[{"id":"new"}]`
+		backup  = `[{"id":"old"}]`
+		corrupt = `[{"id":"ne`
+	)
+
+	tests := []struct {
+		name   string
+		main   []byte
+		backup []byte
+		want   string
+	}{
+		{"valid file", []byte(current), []byte(backup), current},
+		{"corrupt file", []byte(corrupt), []byte(backup), backup},
+		{"corrupt file without backup", []byte(corrupt), nil, corrupt},
+		{"corrupt file and backup", []byte(corrupt), []byte(`[{"id":"ol`), corrupt},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := writeDataFile(t, dir, "tokens.json", tt.main)
+			writeDataFile(t, dir, "tokens.json"+atomicfile.BackupSuffix, tt.backup)
+
+			data, state, err := readDataFile(path, quietLogger(t))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != tt.want {
+				t.Errorf("data = %s, want %s", data, tt.want)
+			}
+			if !state.exists || state.size != int64(len(tt.main)) {
+				t.Errorf("state = %+v, want the main file's", state)
+			}
+		})
+	}
+
+	t.Run("missing file", func(t *testing.T) {
+		dir := t.TempDir()
+		writeDataFile(t, dir, "tokens.json"+atomicfile.BackupSuffix, []byte(backup))
+
+		data, state, err := readDataFile(filepath.Join(dir, "tokens.json"), quietLogger(t))
+		if err != nil || data != nil || state.exists {
+			t.Errorf("readDataFile() = %s, %+v, %v; want no data", data, state, err)
+		}
+	})
+}
+
+func TestJSONTokenRepositoryLoadAllFromBackup(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	repo, err := NewJSONTokenRepository(dir, false, quietLogger(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tokens := make([]*entities.Token, 0, 2)
+	for _, id := range []string{"tok_a", "tok_b"} {
+		token := &entities.Token{ID: id, Name: id, Status: entities.TokenStatusActive, Role: entities.TokenRoleUser}
+		token.SetKey("sk-proxy-" + id)
+		tokens = append(tokens, token)
+		if err := repo.SaveAll(ctx, tokens, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A write torn by a crash leaves tokens.json truncated; tokens.json.bak holds the save before it
+	tokensFile := filepath.Join(dir, "tokens.json")
+	data, err := os.ReadFile(tokensFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(tokensFile, data[:len(data)/2], 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded, err := NewJSONTokenRepository(dir, false, quietLogger(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := reloaded.LoadAll(ctx)
+	if err != nil {
+		t.Fatalf("LoadAll() error = %v, want the backup loaded", err)
+	}
+	if len(loaded) != 1 || loaded[0].ID != "tok_a" || !loaded[0].MatchesKey("sk-proxy-tok_a") {
+		t.Fatalf("LoadAll() = %v, want tok_a from the backup", loaded)
+	}
+
+	// The next save replaces the corrupt file, and the corrupt content never becomes the backup
+	if err := reloaded.SaveAll(ctx, loaded, false); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{tokensFile, tokensFile + atomicfile.BackupSuffix} {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if count, err := countJSONArray(content); err != nil || count != 1 {
+			t.Errorf("%s holds %d entries (err = %v), want 1", filepath.Base(path), count, err)
+		}
+	}
+}
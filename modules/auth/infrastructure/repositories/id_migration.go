@@ -0,0 +1,115 @@
+package repositories
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"claude-proxy/modules/auth/domain/entities"
+	"claude-proxy/pkg/atomicfile"
+	"claude-proxy/pkg/uid"
+
+	sctx "github.com/phathdt/service-context"
+)
+
+// idMigrationFileName records the account IDs MigrateLegacyAccountIDs replaced, for external systems to translate
+const idMigrationFileName = "id_migration.json"
+
+// idMigrationFile is the layout of id_migration.json
+type idMigrationFile struct {
+	Accounts   map[string]string `json:"accounts"`    // Legacy ID -> UUIDv7
+	MigratedAt string            `json:"migrated_at"` // RFC3339/ISO 8601 datetime of the last migration
+}
+
+// MigrateLegacyAccountIDs gives accounts whose ID is not a UUID (e.g. app_1716412345123456789, written by
+// older releases) a UUIDv7, in creation order, keeping the legacy ID as their ExternalID, and records each
+// replacement in id_migration.json before accounts.json is rewritten
+// Returns how many accounts were migrated now and every replacement recorded so far (legacy ID -> UUIDv7),
+// so references to legacy IDs in other data files can be rewritten on every start.
+func MigrateLegacyAccountIDs(dataFolder string, fsync bool, logger sctx.Logger) (int, map[string]string, error) {
+	repo := &JSONAccountPersistenceRepository{dataFolder: ExpandPath(dataFolder), fsync: fsync, logger: logger}
+	mappingFile := filepath.Join(repo.dataFolder, idMigrationFileName)
+
+	var migrated int
+	var mapping map[string]string
+	err := withFileLock(repo.accountsFile(), func() error {
+		accounts, _, err := repo.loadFromDisk()
+		if err != nil {
+			return err
+		}
+
+		// New IDs are generated in creation order, so migrated accounts sort by ID as they were created
+		legacy := make([]*entities.Account, 0, len(accounts))
+		for _, account := range accounts {
+			if account.ID != "" && !uid.IsUUID(account.ID) {
+				legacy = append(legacy, account)
+			}
+		}
+		slices.SortStableFunc(legacy, func(a, b *entities.Account) int {
+			return a.CreatedAt.Compare(b.CreatedAt)
+		})
+		replaced := make(map[string]string, len(legacy))
+		for _, account := range legacy {
+			replaced[account.ID] = uid.New()
+		}
+
+		return withFileLock(mappingFile, func() error {
+			file, err := loadIDMigrationFile(mappingFile)
+			if err != nil {
+				return err
+			}
+			if len(replaced) > 0 {
+				// The mapping is durable before any account changes ID
+				maps.Copy(file.Accounts, replaced)
+				file.MigratedAt = time.Now().Format(time.RFC3339)
+				data, err := json.MarshalIndent(file, "", "  ")
+				if err != nil {
+					return fmt.Errorf("failed to marshal ID migration: %w", err)
+				}
+				if err := atomicfile.Write(mappingFile, data, 0o600, fsync); err != nil {
+					return fmt.Errorf("failed to write ID migration file: %w", err)
+				}
+
+				for _, account := range accounts {
+					if newID, ok := replaced[account.ID]; ok {
+						if account.ExternalID == "" {
+							account.ExternalID = account.ID
+						}
+						account.ID = newID
+					}
+				}
+				if err := repo.saveToDisk(accounts); err != nil {
+					return err
+				}
+			}
+
+			migrated = len(replaced)
+			mapping = file.Accounts
+			return nil
+		})
+	})
+	return migrated, mapping, err
+}
+
+// loadIDMigrationFile reads id_migration.json (empty if it doesn't exist yet)
+func loadIDMigrationFile(path string) (*idMigrationFile, error) {
+	file := &idMigrationFile{Accounts: make(map[string]string)}
+
+	data, state, err := readFileState(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ID migration file: %w", err)
+	}
+	if !state.exists {
+		return file, nil
+	}
+	if err := json.Unmarshal(data, file); err != nil {
+		return nil, fmt.Errorf("failed to parse ID migration file: %w", err)
+	}
+	if file.Accounts == nil {
+		file.Accounts = make(map[string]string)
+	}
+	return file, nil
+}
@@ -0,0 +1,101 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"claude-proxy/modules/auth/domain/entities"
+	"claude-proxy/pkg/uid"
+)
+
+func TestMigrateLegacyAccountIDsKeepsCreationOrder(t *testing.T) {
+	dir := t.TempDir()
+	logger := quietLogger(t)
+	repo, err := NewJSONAccountPersistenceRepository(dir, false, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Legacy accounts, stored out of creation order, around one already migrated
+	created := time.Date(2025, 5, 22, 21, 12, 25, 0, time.UTC)
+	var accounts []*entities.Account
+	for _, i := range []int{3, 0, 4, 2, 1} {
+		createdAt := created.Add(time.Duration(i) * time.Hour)
+		accounts = append(accounts, &entities.Account{
+			ID:        fmt.Sprintf("app_%d", createdAt.UnixNano()),
+			Name:      fmt.Sprintf("account %d", i),
+			Status:    entities.AccountStatusActive,
+			CreatedAt: createdAt,
+		})
+	}
+	current := &entities.Account{
+		ID:        uid.New(),
+		Name:      "current",
+		Status:    entities.AccountStatusActive,
+		CreatedAt: created,
+	}
+	accounts = append(accounts, current)
+	if err := repo.SaveAll(context.Background(), accounts, false); err != nil {
+		t.Fatal(err)
+	}
+
+	migrated, mapping, err := MigrateLegacyAccountIDs(dir, false, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if migrated != 5 || len(mapping) != 5 {
+		t.Fatalf("migrated %d accounts (%d recorded), want 5", migrated, len(mapping))
+	}
+	after := uid.New()
+
+	stored, err := repo.LoadAll(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var legacy []*entities.Account
+	for _, account := range stored {
+		switch {
+		case account.Name == "current":
+			if account.ID != current.ID || account.ExternalID != "" {
+				t.Errorf("account already on a UUID became %s (external %q)", account.ID, account.ExternalID)
+			}
+		case !uid.IsUUID(account.ID) || mapping[account.ExternalID] != account.ID:
+			t.Errorf("%s: ID %s (external %s) not migrated as recorded", account.Name, account.ID, account.ExternalID)
+		default:
+			legacy = append(legacy, account)
+		}
+		if account.ID >= after {
+			t.Errorf("%s: ID %s sorts after IDs generated once migrated", account.Name, account.ID)
+		}
+	}
+
+	// Sorted by their new IDs, migrated accounts are in creation order, as their legacy IDs were
+	slices.SortFunc(legacy, func(a, b *entities.Account) int { return strings.Compare(a.ID, b.ID) })
+	for i, account := range legacy {
+		if want := fmt.Sprintf("account %d", i); account.Name != want {
+			t.Errorf("ID order: %s at %d, want %s", account.Name, i, want)
+		}
+	}
+
+	// Running again changes nothing and still returns every replacement
+	again, mappingAgain, err := MigrateLegacyAccountIDs(dir, false, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again != 0 || len(mappingAgain) != 5 {
+		t.Errorf("second run migrated %d accounts (%d recorded), want 0 (5)", again, len(mappingAgain))
+	}
+	restored, err := repo.LoadAll(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, account := range restored {
+		if account.ID != stored[i].ID {
+			t.Errorf("second run changed %s's ID from %s to %s", account.Name, stored[i].ID, account.ID)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package repositories
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
@@ -13,19 +14,33 @@ import (
 	"claude-proxy/modules/auth/application/dto"
 	"claude-proxy/modules/auth/domain/entities"
 	"claude-proxy/modules/auth/domain/interfaces"
+	"claude-proxy/pkg/atomicfile"
+
+	sctx "github.com/phathdt/service-context"
 )
 
 // JSONAccountPersistenceRepository implements PersistenceRepository using JSON file storage
 // This repository ONLY handles disk I/O, no in-memory caching
+// Every operation also holds the file's cross-process lock, as CLI commands may edit the data folder
+// of a running server
 type JSONAccountPersistenceRepository struct {
 	dataFolder string
+	fsync      bool         // Flush writes to disk before reporting success (storage.fsync)
 	mu         sync.RWMutex // Only for file I/O concurrency control
+	seen       fileState    // accounts.json as last loaded or saved by this repository
+	logger     sctx.Logger
 }
 
 // NewJSONAccountPersistenceRepository creates a new JSON persistence repository
-func NewJSONAccountPersistenceRepository(dataFolder string) (interfaces.PersistenceRepository, error) {
+func NewJSONAccountPersistenceRepository(
+	dataFolder string,
+	fsync bool,
+	logger sctx.Logger,
+) (interfaces.PersistenceRepository, error) {
 	repo := &JSONAccountPersistenceRepository{
-		dataFolder: expandPath(dataFolder),
+		dataFolder: ExpandPath(dataFolder),
+		fsync:      fsync,
+		logger:     logger,
 	}
 
 	// Create data folder if it doesn't exist
@@ -36,258 +51,320 @@ func NewJSONAccountPersistenceRepository(dataFolder string) (interfaces.Persiste
 	return repo, nil
 }
 
+// accountsFile returns the path of accounts.json
+func (r *JSONAccountPersistenceRepository) accountsFile() string {
+	return filepath.Join(r.dataFolder, "accounts.json")
+}
+
 // SaveAll persists all accounts to durable storage (batch operation)
-func (r *JSONAccountPersistenceRepository) SaveAll(ctx context.Context, accounts []*entities.Account) error {
+// Without force, no accounts are refused while accounts.json holds some
+func (r *JSONAccountPersistenceRepository) SaveAll(
+	ctx context.Context,
+	accounts []*entities.Account,
+	force bool,
+) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	accountsFile := filepath.Join(r.dataFolder, "accounts.json")
-
-	// Convert entities to DTOs
-	dtos := make([]*dto.AccountPersistenceDTO, 0, len(accounts))
-	for _, account := range accounts {
-		dtos = append(dtos, dto.ToAccountPersistenceDTO(account))
-	}
-
-	// Marshal to JSON
-	data, err := json.MarshalIndent(dtos, "", "  ")
-	if err != nil {
-		return fmt.Errorf("failed to marshal accounts: %w", err)
-	}
-
-	// Write to temporary file first (atomic write)
-	tmpFile := accountsFile + ".tmp"
-	if err := os.WriteFile(tmpFile, data, 0o600); err != nil {
-		return fmt.Errorf("failed to write accounts file: %w", err)
-	}
-
-	// Atomic rename
-	if err := os.Rename(tmpFile, accountsFile); err != nil {
-		os.Remove(tmpFile)
-		return fmt.Errorf("failed to rename accounts file: %w", err)
-	}
-
-	return nil
+	return withFileLock(r.accountsFile(), func() error {
+		if err := checkEmptySave(r.accountsFile(), len(accounts), force, countAccounts); err != nil {
+			return err
+		}
+		return r.saveToDisk(accounts)
+	})
 }
 
 // LoadAll loads all accounts from durable storage
 func (r *JSONAccountPersistenceRepository) LoadAll(ctx context.Context) ([]*entities.Account, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var accounts []*entities.Account
+	err := withFileLock(r.accountsFile(), func() error {
+		var err error
+		accounts, _, err = r.loadFromDisk()
+		return err
+	})
+	return accounts, err
+}
+
+// Changed returns true if accounts.json was modified by another writer since this repository last
+// loaded or saved it
+func (r *JSONAccountPersistenceRepository) Changed(ctx context.Context) (bool, error) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
-	accountsFile := filepath.Join(r.dataFolder, "accounts.json")
-
-	data, err := os.ReadFile(accountsFile)
+	_, current, err := readFileState(r.accountsFile())
 	if err != nil {
-		if os.IsNotExist(err) {
-			return []*entities.Account{}, nil // No accounts yet
-		}
-		return nil, fmt.Errorf("failed to read accounts file: %w", err)
+		return false, fmt.Errorf("failed to read accounts file: %w", err)
 	}
+	return r.seen.differs(current), nil
+}
 
-	// Try to parse as array first (new format)
-	var dtos []*dto.AccountPersistenceDTO
-	if err := json.Unmarshal(data, &dtos); err == nil && len(dtos) > 0 {
-		// Successfully parsed as array
-		accounts := make([]*entities.Account, 0, len(dtos))
-		for _, d := range dtos {
-			accounts = append(accounts, dto.FromAccountPersistenceDTO(d))
+// Create creates and persists a new account
+func (r *JSONAccountPersistenceRepository) Create(ctx context.Context, account *entities.Account) error {
+	return r.modify(func(accounts []*entities.Account) ([]*entities.Account, error) {
+		// Check for duplicates
+		for _, a := range accounts {
+			if strings.EqualFold(a.Name, account.Name) {
+				return nil, fmt.Errorf("account with name already exists")
+			}
+			if account.OrganizationUUID != "" && a.OrganizationUUID == account.OrganizationUUID {
+				return nil, fmt.Errorf("account with organization UUID already exists")
+			}
 		}
-		return accounts, nil
-	}
 
-	// Fallback: try to parse as object/map (old format from CLI)
-	var accountMap map[string]interface{}
-	if err := json.Unmarshal(data, &accountMap); err != nil {
-		return nil, fmt.Errorf("failed to parse accounts file: %w", err)
-	}
+		return append(accounts, account), nil
+	})
+}
 
-	// Convert old format to new format
-	accounts := make([]*entities.Account, 0, len(accountMap))
-	for orgUUID, val := range accountMap {
-		accountData, ok := val.(map[string]interface{})
-		if !ok {
-			continue
+// Update updates and persists an existing account
+func (r *JSONAccountPersistenceRepository) Update(ctx context.Context, account *entities.Account) error {
+	return r.modify(func(accounts []*entities.Account) ([]*entities.Account, error) {
+		for i, a := range accounts {
+			if a.ID == account.ID {
+				accounts[i] = account
+				return accounts, nil
+			}
 		}
+		return nil, fmt.Errorf("account not found: %s", account.ID)
+	})
+}
 
-		// Extract OAuth token info
-		var accessToken, refreshToken string
-		var expiresAt int64
-		if oauthToken, ok := accountData["oauth_token"].(map[string]interface{}); ok {
-			if at, ok := oauthToken["access_token"].(string); ok {
-				accessToken = at
-			}
-			if rt, ok := oauthToken["refresh_token"].(string); ok {
-				refreshToken = rt
-			}
-			if exp, ok := oauthToken["expires_at"].(float64); ok {
-				expiresAt = int64(exp)
+// Delete deletes an account from persistent storage
+func (r *JSONAccountPersistenceRepository) Delete(ctx context.Context, id string) error {
+	return r.modify(func(accounts []*entities.Account) ([]*entities.Account, error) {
+		for i, a := range accounts {
+			if a.ID == id {
+				return append(accounts[:i], accounts[i+1:]...), nil
 			}
 		}
+		return nil, fmt.Errorf("account not found: %s", id)
+	})
+}
 
-		// Extract status
-		status := "active"
-		if s, ok := accountData["status"].(string); ok {
-			status = s
-		}
+// modify loads the accounts, applies fn and saves the result, all under the cross-process lock
+func (r *JSONAccountPersistenceRepository) modify(
+	fn func(accounts []*entities.Account) ([]*entities.Account, error),
+) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 
-		// Create account entity
-		account := &entities.Account{
-			ID:               orgUUID,
-			Name:             orgUUID,
-			OrganizationUUID: orgUUID,
-			AccessToken:      accessToken,
-			RefreshToken:     refreshToken,
-			ExpiresAt:        time.Unix(expiresAt, 0),
-			Status:           entities.AccountStatus(status),
-			CreatedAt:        time.Now(),
-			UpdatedAt:        time.Now(),
+	return withFileLock(r.accountsFile(), func() error {
+		accounts, _, err := r.loadFromDisk()
+		if err != nil {
+			return err
 		}
 
-		accounts = append(accounts, account)
-	}
-
-	return accounts, nil
+		accounts, err = fn(accounts)
+		if err != nil {
+			return err
+		}
+		return r.saveToDisk(accounts)
+	})
 }
 
-// Create creates and persists a new account
-func (r *JSONAccountPersistenceRepository) Create(ctx context.Context, account *entities.Account) error {
-	r.mu.Lock()
-	defer r.mu.Unlock()
-
-	// Load all existing accounts
-	accounts, err := r.loadFromDisk()
+// loadFromDisk loads accounts from disk and returns them with the file's schema version
+// (internal helper, requires both locks)
+func (r *JSONAccountPersistenceRepository) loadFromDisk() ([]*entities.Account, int, error) {
+	data, state, err := readDataFile(r.accountsFile(), r.logger)
 	if err != nil {
-		return err
+		return nil, 0, fmt.Errorf("failed to read accounts file: %w", err)
 	}
-
-	// Check for duplicates
-	for _, a := range accounts {
-		if strings.EqualFold(a.Name, account.Name) {
-			return fmt.Errorf("account with name already exists")
-		}
-		if account.OrganizationUUID != "" && a.OrganizationUUID == account.OrganizationUUID {
-			return fmt.Errorf("account with organization UUID already exists")
-		}
+	if !state.exists {
+		r.seen = state
+		return []*entities.Account{}, dto.AccountsFileSchemaVersion, nil // No accounts yet
 	}
 
-	// Add new account
-	accounts = append(accounts, account)
+	accounts, schemaVersion, err := parseAccountsFile(data)
+	if err != nil {
+		return nil, 0, err
+	}
 
-	// Save all back to disk
-	return r.saveToDisk(accounts)
+	r.seen = state
+	return accounts, schemaVersion, nil
 }
 
-// Update updates and persists an existing account
-func (r *JSONAccountPersistenceRepository) Update(ctx context.Context, account *entities.Account) error {
-	r.mu.Lock()
-	defer r.mu.Unlock()
+// saveToDisk saves accounts to disk (internal helper, requires both locks)
+// It refuses to replace a file written by a newer schema, which this build would silently downgrade
+func (r *JSONAccountPersistenceRepository) saveToDisk(accounts []*entities.Account) error {
+	accountsFile := r.accountsFile()
+
+	if data, state, err := readFileState(accountsFile); err == nil && state.exists {
+		if schemaVersion := accountsFileSchemaVersion(data); schemaVersion > dto.AccountsFileSchemaVersion {
+			return fmt.Errorf(
+				"accounts file was written by a newer version (schema %d, this build supports %d); refusing to overwrite it",
+				schemaVersion, dto.AccountsFileSchemaVersion,
+			)
+		}
+	}
 
-	// Load all existing accounts
-	accounts, err := r.loadFromDisk()
-	if err != nil {
-		return err
+	// Convert entities to DTOs
+	file := dto.AccountsFileDTO{
+		SchemaVersion: dto.AccountsFileSchemaVersion,
+		Accounts:      make([]*dto.AccountPersistenceDTO, 0, len(accounts)),
+	}
+	for _, account := range accounts {
+		file.Accounts = append(file.Accounts, dto.ToAccountPersistenceDTO(account))
 	}
 
-	// Find and update the account
-	found := false
-	for i, a := range accounts {
-		if a.ID == account.ID {
-			accounts[i] = account
-			found = true
-			break
-		}
+	// Marshal to JSON
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal accounts: %w", err)
 	}
 
-	if !found {
-		return fmt.Errorf("account not found: %s", account.ID)
+	// Atomic write (temp file verified, then renamed; previous file kept as .bak), fsynced when storage.fsync is set
+	if err := atomicfile.WriteVerified(accountsFile, data, 0o600, r.fsync, countAccounts, len(accounts)); err != nil {
+		return fmt.Errorf("failed to write accounts file: %w", err)
 	}
 
-	// Save all back to disk
-	return r.saveToDisk(accounts)
+	if _, state, err := readFileState(accountsFile); err == nil {
+		r.seen = state
+	}
+	return nil
 }
 
-// Delete deletes an account from persistent storage
-func (r *JSONAccountPersistenceRepository) Delete(ctx context.Context, id string) error {
+// WithWriteLock runs fn while holding the write lock, so no save can modify the data file meanwhile
+func (r *JSONAccountPersistenceRepository) WithWriteLock(fn func(dataFile string) error) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	// Load all existing accounts
-	accounts, err := r.loadFromDisk()
-	if err != nil {
-		return err
-	}
+	return withFileLock(r.accountsFile(), func() error {
+		return fn(r.accountsFile())
+	})
+}
 
-	// Find and remove the account
-	found := false
-	for i, a := range accounts {
-		if a.ID == id {
-			accounts = append(accounts[:i], accounts[i+1:]...)
-			found = true
-			break
-		}
+// accountsFileSchemaVersion returns the schema version of accounts.json content
+// Files without a header (bare array or the old CLI object) are version 1
+func accountsFileSchemaVersion(data []byte) int {
+	var header struct {
+		SchemaVersion int `json:"schema_version"`
 	}
-
-	if !found {
-		return fmt.Errorf("account not found: %s", id)
+	if json.Unmarshal(data, &header) != nil || header.SchemaVersion == 0 {
+		return 1
 	}
-
-	// Save all back to disk
-	return r.saveToDisk(accounts)
+	return header.SchemaVersion
 }
 
-// loadFromDisk loads accounts from disk (internal helper, requires lock)
-func (r *JSONAccountPersistenceRepository) loadFromDisk() ([]*entities.Account, error) {
-	accountsFile := filepath.Join(r.dataFolder, "accounts.json")
+// parseAccountsFile parses accounts.json content in any of its layouts and returns its schema version
+func parseAccountsFile(data []byte) ([]*entities.Account, int, error) {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) == 0 {
+		return []*entities.Account{}, 1, nil
+	}
 
-	data, err := os.ReadFile(accountsFile)
-	if err != nil {
-		if os.IsNotExist(err) {
-			return []*entities.Account{}, nil
+	// Bare array (schema 1)
+	if trimmed[0] == '[' {
+		var dtos []*dto.AccountPersistenceDTO
+		if err := json.Unmarshal(trimmed, &dtos); err != nil {
+			return nil, 0, fmt.Errorf("failed to parse accounts file: %w", err)
 		}
-		return nil, fmt.Errorf("failed to read accounts file: %w", err)
+		return fromAccountPersistenceDTOs(dtos), 1, nil
 	}
 
-	var dtos []*dto.AccountPersistenceDTO
-	if err := json.Unmarshal(data, &dtos); err != nil {
-		return nil, fmt.Errorf("failed to parse accounts file: %w", err)
+	// Schema header (schema 2 and later)
+	if schemaVersion := accountsFileSchemaVersion(trimmed); schemaVersion > 1 {
+		var file dto.AccountsFileDTO
+		if err := json.Unmarshal(trimmed, &file); err != nil {
+			return nil, 0, fmt.Errorf("failed to parse accounts file: %w", err)
+		}
+		return fromAccountPersistenceDTOs(file.Accounts), schemaVersion, nil
+	}
+
+	// Fallback: object/map keyed by organization UUID (old format from CLI)
+	var accountMap map[string]interface{}
+	if err := json.Unmarshal(trimmed, &accountMap); err != nil {
+		return nil, 0, fmt.Errorf("failed to parse accounts file: %w", err)
 	}
+	return fromLegacyAccountMap(accountMap), 1, nil
+}
+
+// countAccounts counts the accounts of an accounts.json file in any schema
+func countAccounts(data []byte) (int, error) {
+	accounts, _, err := parseAccountsFile(data)
+	return len(accounts), err
+}
 
+// fromAccountPersistenceDTOs converts persisted accounts to entities
+func fromAccountPersistenceDTOs(dtos []*dto.AccountPersistenceDTO) []*entities.Account {
 	accounts := make([]*entities.Account, 0, len(dtos))
 	for _, d := range dtos {
 		accounts = append(accounts, dto.FromAccountPersistenceDTO(d))
 	}
-
-	return accounts, nil
+	return accounts
 }
 
-// saveToDisk saves accounts to disk (internal helper, requires lock)
-func (r *JSONAccountPersistenceRepository) saveToDisk(accounts []*entities.Account) error {
-	accountsFile := filepath.Join(r.dataFolder, "accounts.json")
-
-	// Convert entities to DTOs
-	dtos := make([]*dto.AccountPersistenceDTO, 0, len(accounts))
-	for _, account := range accounts {
-		dtos = append(dtos, dto.ToAccountPersistenceDTO(account))
+// fromLegacyAccountMap converts the old CLI format (accounts keyed by organization UUID) to entities
+func fromLegacyAccountMap(accountMap map[string]interface{}) []*entities.Account {
+	accounts := make([]*entities.Account, 0, len(accountMap))
+	for orgUUID, val := range accountMap {
+		accountData, ok := val.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		account := fromLegacyAccount(accountData)
+		account.ID = orgUUID
+		account.OrganizationUUID = orgUUID
+		if account.Name == "" {
+			account.Name = orgUUID
+		}
+		accounts = append(accounts, account)
 	}
+	return accounts
+}
 
-	// Marshal to JSON
-	data, err := json.MarshalIndent(dtos, "", "  ")
-	if err != nil {
-		return fmt.Errorf("failed to marshal accounts: %w", err)
+// fromLegacyAccount converts one account of the old single-account and CLI formats to an entity (without ID):
+// tokens under "oauth_token" (expires_at in Unix seconds or RFC3339) and a status where "valid" means active
+func fromLegacyAccount(accountData map[string]interface{}) *entities.Account {
+	now := time.Now()
+	account := &entities.Account{
+		AuthType:    entities.AccountAuthOAuth,
+		Status:      entities.AccountStatusActive,
+		AutoRefresh: true,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	account.OrganizationUUID, _ = accountData["organization_uuid"].(string)
+	account.Name, _ = accountData["name"].(string)
+	if account.Name == "" {
+		account.Name, _ = accountData["email"].(string)
 	}
 
-	// Write to temporary file first
-	tmpFile := accountsFile + ".tmp"
-	if err := os.WriteFile(tmpFile, data, 0o600); err != nil {
-		return fmt.Errorf("failed to write accounts file: %w", err)
+	// Tokens are nested under oauth_token, or at the top level in some single-account files
+	tokens, ok := accountData["oauth_token"].(map[string]interface{})
+	if !ok {
+		tokens = accountData
+	}
+	account.AccessToken, _ = tokens["access_token"].(string)
+	account.RefreshToken, _ = tokens["refresh_token"].(string)
+	switch expiresAt := tokens["expires_at"].(type) {
+	case float64:
+		account.ExpiresAt = time.Unix(int64(expiresAt), 0)
+	case string:
+		account.ExpiresAt, _ = time.Parse(time.RFC3339, expiresAt)
 	}
 
-	// Atomic rename
-	if err := os.Rename(tmpFile, accountsFile); err != nil {
-		os.Remove(tmpFile)
-		return fmt.Errorf("failed to rename accounts file: %w", err)
+	if status, ok := accountData["status"].(string); ok {
+		account.Status = legacyAccountStatus(status)
+	}
+	if createdAt, ok := accountData["created_at"].(string); ok {
+		if t, err := time.Parse(time.RFC3339, createdAt); err == nil {
+			account.CreatedAt = t
+		}
 	}
+	return account
+}
 
-	return nil
+// legacyAccountStatus maps a status written by older releases to the current ones ("valid" is now active)
+func legacyAccountStatus(status string) entities.AccountStatus {
+	switch entities.AccountStatus(status) {
+	case entities.AccountStatusActive, entities.AccountStatusInactive, entities.AccountStatusRateLimited,
+		entities.AccountStatusInvalid, entities.AccountStatusConflicted:
+		return entities.AccountStatus(status)
+	case "valid", "":
+		return entities.AccountStatusActive
+	default:
+		return entities.AccountStatusInvalid
+	}
 }
@@ -0,0 +1,101 @@
+package repositories
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"claude-proxy/modules/auth/application/dto"
+	"claude-proxy/modules/auth/domain/entities"
+	"claude-proxy/modules/auth/domain/interfaces"
+	"claude-proxy/pkg/atomicfile"
+)
+
+// JSONAdminKeyRepository implements AdminKeyRepository using JSON file storage
+// This repository ONLY handles disk I/O, no in-memory caching
+type JSONAdminKeyRepository struct {
+	dataFolder string
+	fsync      bool         // Flush writes to disk before reporting success (storage.fsync)
+	mu         sync.RWMutex // Only for file I/O concurrency control
+}
+
+// NewJSONAdminKeyRepository creates a new JSON admin key repository
+func NewJSONAdminKeyRepository(dataFolder string, fsync bool) (interfaces.AdminKeyRepository, error) {
+	repo := &JSONAdminKeyRepository{
+		dataFolder: ExpandPath(dataFolder),
+		fsync:      fsync,
+	}
+
+	// Create data folder if it doesn't exist
+	if err := os.MkdirAll(repo.dataFolder, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create data folder: %w", err)
+	}
+
+	return repo, nil
+}
+
+// SaveAll persists all admin keys to durable storage (batch operation)
+func (r *JSONAdminKeyRepository) SaveAll(ctx context.Context, keys []*entities.AdminKey) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	keysFile := filepath.Join(r.dataFolder, "admin_keys.json")
+
+	// Convert entities to DTOs
+	dtos := make([]*dto.AdminKeyPersistenceDTO, 0, len(keys))
+	for _, key := range keys {
+		dtos = append(dtos, dto.ToAdminKeyPersistenceDTO(key))
+	}
+
+	// Marshal to JSON
+	data, err := json.MarshalIndent(dtos, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal admin keys: %w", err)
+	}
+
+	// Atomic write (temp file + rename), fsynced when storage.fsync is set
+	if err := atomicfile.Write(keysFile, data, 0o600, r.fsync); err != nil {
+		return fmt.Errorf("failed to write admin keys file: %w", err)
+	}
+
+	return nil
+}
+
+// LoadAll loads all admin keys from durable storage
+func (r *JSONAdminKeyRepository) LoadAll(ctx context.Context) ([]*entities.AdminKey, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	keysFile := filepath.Join(r.dataFolder, "admin_keys.json")
+
+	data, err := os.ReadFile(keysFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []*entities.AdminKey{}, nil // No managed keys yet
+		}
+		return nil, fmt.Errorf("failed to read admin keys file: %w", err)
+	}
+
+	var dtos []*dto.AdminKeyPersistenceDTO
+	if err := json.Unmarshal(data, &dtos); err != nil {
+		return nil, fmt.Errorf("failed to parse admin keys file: %w", err)
+	}
+
+	keys := make([]*entities.AdminKey, 0, len(dtos))
+	for _, d := range dtos {
+		keys = append(keys, dto.FromAdminKeyPersistenceDTO(d))
+	}
+
+	return keys, nil
+}
+
+// WithWriteLock runs fn while holding the write lock, so no save can modify the data file meanwhile
+func (r *JSONAdminKeyRepository) WithWriteLock(fn func(dataFile string) error) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return fn(filepath.Join(r.dataFolder, "admin_keys.json"))
+}
@@ -0,0 +1,93 @@
+package repositories
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"claude-proxy/modules/auth/application/dto"
+	"claude-proxy/modules/auth/domain/entities"
+	"claude-proxy/modules/auth/domain/interfaces"
+	"claude-proxy/pkg/atomicfile"
+)
+
+// JSONDeviceAuthRepository implements DeviceAuthRepository using JSON file storage
+// This repository ONLY handles disk I/O, no in-memory caching
+type JSONDeviceAuthRepository struct {
+	dataFolder string
+	fsync      bool         // Flush writes to disk before reporting success (storage.fsync)
+	mu         sync.RWMutex // Only for file I/O concurrency control
+}
+
+// NewJSONDeviceAuthRepository creates a new JSON device authorization repository
+func NewJSONDeviceAuthRepository(dataFolder string, fsync bool) (interfaces.DeviceAuthRepository, error) {
+	repo := &JSONDeviceAuthRepository{
+		dataFolder: ExpandPath(dataFolder),
+		fsync:      fsync,
+	}
+
+	// Create data folder if it doesn't exist
+	if err := os.MkdirAll(repo.dataFolder, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create data folder: %w", err)
+	}
+
+	return repo, nil
+}
+
+// SaveAll persists all device authorizations to durable storage (batch operation)
+func (r *JSONDeviceAuthRepository) SaveAll(ctx context.Context, auths []*entities.DeviceAuthorization) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	authsFile := filepath.Join(r.dataFolder, "device_authorizations.json")
+
+	// Convert entities to DTOs
+	dtos := make([]*dto.DeviceAuthPersistenceDTO, 0, len(auths))
+	for _, auth := range auths {
+		dtos = append(dtos, dto.ToDeviceAuthPersistenceDTO(auth))
+	}
+
+	// Marshal to JSON
+	data, err := json.MarshalIndent(dtos, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal device authorizations: %w", err)
+	}
+
+	// Atomic write (temp file + rename), fsynced when storage.fsync is set
+	if err := atomicfile.Write(authsFile, data, 0o600, r.fsync); err != nil {
+		return fmt.Errorf("failed to write device authorizations file: %w", err)
+	}
+
+	return nil
+}
+
+// LoadAll loads all device authorizations from durable storage
+func (r *JSONDeviceAuthRepository) LoadAll(ctx context.Context) ([]*entities.DeviceAuthorization, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	authsFile := filepath.Join(r.dataFolder, "device_authorizations.json")
+
+	data, err := os.ReadFile(authsFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []*entities.DeviceAuthorization{}, nil // No device authorizations yet
+		}
+		return nil, fmt.Errorf("failed to read device authorizations file: %w", err)
+	}
+
+	var dtos []*dto.DeviceAuthPersistenceDTO
+	if err := json.Unmarshal(data, &dtos); err != nil {
+		return nil, fmt.Errorf("failed to parse device authorizations file: %w", err)
+	}
+
+	auths := make([]*entities.DeviceAuthorization, 0, len(dtos))
+	for _, d := range dtos {
+		auths = append(auths, dto.FromDeviceAuthPersistenceDTO(d))
+	}
+
+	return auths, nil
+}
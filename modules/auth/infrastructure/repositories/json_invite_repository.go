@@ -0,0 +1,101 @@
+package repositories
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"claude-proxy/modules/auth/application/dto"
+	"claude-proxy/modules/auth/domain/entities"
+	"claude-proxy/modules/auth/domain/interfaces"
+	"claude-proxy/pkg/atomicfile"
+)
+
+// JSONInviteRepository implements InviteRepository using JSON file storage
+// This repository ONLY handles disk I/O, no in-memory caching
+type JSONInviteRepository struct {
+	dataFolder string
+	fsync      bool         // Flush writes to disk before reporting success (storage.fsync)
+	mu         sync.RWMutex // Only for file I/O concurrency control
+}
+
+// NewJSONInviteRepository creates a new JSON invite repository
+func NewJSONInviteRepository(dataFolder string, fsync bool) (interfaces.InviteRepository, error) {
+	repo := &JSONInviteRepository{
+		dataFolder: ExpandPath(dataFolder),
+		fsync:      fsync,
+	}
+
+	// Create data folder if it doesn't exist
+	if err := os.MkdirAll(repo.dataFolder, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create data folder: %w", err)
+	}
+
+	return repo, nil
+}
+
+// SaveAll persists all invites to durable storage (batch operation)
+func (r *JSONInviteRepository) SaveAll(ctx context.Context, invites []*entities.Invite) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	invitesFile := filepath.Join(r.dataFolder, "invites.json")
+
+	// Convert entities to DTOs
+	dtos := make([]*dto.InvitePersistenceDTO, 0, len(invites))
+	for _, invite := range invites {
+		dtos = append(dtos, dto.ToInvitePersistenceDTO(invite))
+	}
+
+	// Marshal to JSON
+	data, err := json.MarshalIndent(dtos, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal invites: %w", err)
+	}
+
+	// Atomic write (temp file + rename), fsynced when storage.fsync is set
+	if err := atomicfile.Write(invitesFile, data, 0o600, r.fsync); err != nil {
+		return fmt.Errorf("failed to write invites file: %w", err)
+	}
+
+	return nil
+}
+
+// LoadAll loads all invites from durable storage
+func (r *JSONInviteRepository) LoadAll(ctx context.Context) ([]*entities.Invite, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	invitesFile := filepath.Join(r.dataFolder, "invites.json")
+
+	data, err := os.ReadFile(invitesFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []*entities.Invite{}, nil // No invites yet
+		}
+		return nil, fmt.Errorf("failed to read invites file: %w", err)
+	}
+
+	var dtos []*dto.InvitePersistenceDTO
+	if err := json.Unmarshal(data, &dtos); err != nil {
+		return nil, fmt.Errorf("failed to parse invites file: %w", err)
+	}
+
+	invites := make([]*entities.Invite, 0, len(dtos))
+	for _, d := range dtos {
+		invites = append(invites, dto.FromInvitePersistenceDTO(d))
+	}
+
+	return invites, nil
+}
+
+// WithWriteLock runs fn while holding the write lock, so no save can modify the data file meanwhile
+func (r *JSONInviteRepository) WithWriteLock(fn func(dataFile string) error) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return fn(filepath.Join(r.dataFolder, "invites.json"))
+}
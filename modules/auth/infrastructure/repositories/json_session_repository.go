@@ -6,24 +6,44 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"sync"
+	"time"
 
 	"claude-proxy/modules/auth/application/dto"
 	"claude-proxy/modules/auth/domain/entities"
 	"claude-proxy/modules/auth/domain/interfaces"
+	"claude-proxy/pkg/atomicfile"
+
+	sctx "github.com/phathdt/service-context"
 )
 
 // JSONSessionRepository implements SessionPersistenceRepository using JSON file storage
 // This repository ONLY handles disk I/O, no in-memory caching
 type JSONSessionRepository struct {
 	dataFolder string
-	mu         sync.RWMutex // Only for file I/O concurrency control
+	fsync      bool          // Flush writes to disk before reporting success (storage.fsync)
+	retention  time.Duration // Sessions ended longer ago are pruned on save (0 = keep all)
+	archive    bool          // Append pruned sessions to monthly sessions-archive-YYYY-MM.json files
+	mu         sync.RWMutex  // Only for file I/O concurrency control
+	logger     sctx.Logger
 }
 
 // NewJSONSessionRepository creates a new JSON session repository
-func NewJSONSessionRepository(dataFolder string) (interfaces.SessionPersistenceRepository, error) {
+// Sessions that ended more than retention ago are dropped on every save (0 keeps them all)
+func NewJSONSessionRepository(
+	dataFolder string,
+	fsync bool,
+	retention time.Duration,
+	archive bool,
+	logger sctx.Logger,
+) (interfaces.SessionPersistenceRepository, error) {
 	repo := &JSONSessionRepository{
-		dataFolder: expandPath(dataFolder),
+		dataFolder: ExpandPath(dataFolder),
+		fsync:      fsync,
+		retention:  retention,
+		archive:    archive,
+		logger:     logger,
 	}
 
 	// Create data folder if it doesn't exist
@@ -35,37 +55,41 @@ func NewJSONSessionRepository(dataFolder string) (interfaces.SessionPersistenceR
 }
 
 // SaveAll persists all sessions to durable storage (batch operation)
-func (r *JSONSessionRepository) SaveAll(ctx context.Context, sessions []*entities.Session) error {
+// Without force, no sessions are refused while sessions.json holds some
+func (r *JSONSessionRepository) SaveAll(ctx context.Context, sessions []*entities.Session, force bool) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	sessionsFile := filepath.Join(r.dataFolder, "sessions.json")
-
-	// Convert entities to DTOs
-	dtos := make([]*dto.SessionPersistenceDTO, 0, len(sessions))
-	for _, session := range sessions {
-		dtos = append(dtos, dto.ToSessionPersistenceDTO(session))
+	if err := checkEmptySave(r.sessionsFile(), len(sessions), force, countJSONArray); err != nil {
+		return err
 	}
+	return r.saveToDisk(sessions)
+}
 
-	// Marshal to JSON
-	data, err := json.MarshalIndent(dtos, "", "  ")
+// sessionsFile returns the path of sessions.json
+func (r *JSONSessionRepository) sessionsFile() string {
+	return filepath.Join(r.dataFolder, "sessions.json")
+}
+
+// Compact rewrites sessions.json without the sessions past the retention period, returning the sessions kept
+// and how many were pruned; the file is left untouched when nothing is pruned
+func (r *JSONSessionRepository) Compact(ctx context.Context) ([]*entities.Session, int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	sessions, err := r.loadFromDisk()
 	if err != nil {
-		return fmt.Errorf("failed to marshal sessions: %w", err)
+		return nil, 0, err
 	}
-
-	// Write to temporary file first (atomic write)
-	tmpFile := sessionsFile + ".tmp"
-	if err := os.WriteFile(tmpFile, data, 0o600); err != nil {
-		return fmt.Errorf("failed to write sessions file: %w", err)
+	kept, pruned := r.prune(sessions)
+	if len(pruned) == 0 {
+		return kept, 0, nil
 	}
-
-	// Atomic rename
-	if err := os.Rename(tmpFile, sessionsFile); err != nil {
-		os.Remove(tmpFile)
-		return fmt.Errorf("failed to rename sessions file: %w", err)
+	// saveToDisk prunes (and archives) the same sessions again
+	if err := r.saveToDisk(sessions); err != nil {
+		return nil, 0, err
 	}
-
-	return nil
+	return kept, len(pruned), nil
 }
 
 // LoadAll loads all sessions from durable storage
@@ -73,27 +97,7 @@ func (r *JSONSessionRepository) LoadAll(ctx context.Context) ([]*entities.Sessio
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
-	sessionsFile := filepath.Join(r.dataFolder, "sessions.json")
-
-	data, err := os.ReadFile(sessionsFile)
-	if err != nil {
-		if os.IsNotExist(err) {
-			return []*entities.Session{}, nil // No sessions yet
-		}
-		return nil, fmt.Errorf("failed to read sessions file: %w", err)
-	}
-
-	var dtos []*dto.SessionPersistenceDTO
-	if err := json.Unmarshal(data, &dtos); err != nil {
-		return nil, fmt.Errorf("failed to parse sessions file: %w", err)
-	}
-
-	sessions := make([]*entities.Session, 0, len(dtos))
-	for _, d := range dtos {
-		sessions = append(sessions, dto.FromSessionPersistenceDTO(d))
-	}
-
-	return sessions, nil
+	return r.loadFromDisk()
 }
 
 // CreateSession creates and persists a new session
@@ -181,15 +185,13 @@ func (r *JSONSessionRepository) DeleteSession(ctx context.Context, sessionID str
 
 // loadFromDisk loads sessions from disk (internal helper, requires lock)
 func (r *JSONSessionRepository) loadFromDisk() ([]*entities.Session, error) {
-	sessionsFile := filepath.Join(r.dataFolder, "sessions.json")
-
-	data, err := os.ReadFile(sessionsFile)
+	data, state, err := readDataFile(r.sessionsFile(), r.logger)
 	if err != nil {
-		if os.IsNotExist(err) {
-			return []*entities.Session{}, nil
-		}
 		return nil, fmt.Errorf("failed to read sessions file: %w", err)
 	}
+	if !state.exists {
+		return []*entities.Session{}, nil // No sessions yet
+	}
 
 	var dtos []*dto.SessionPersistenceDTO
 	if err := json.Unmarshal(data, &dtos); err != nil {
@@ -204,9 +206,17 @@ func (r *JSONSessionRepository) loadFromDisk() ([]*entities.Session, error) {
 	return sessions, nil
 }
 
-// saveToDisk saves sessions to disk (internal helper, requires lock)
+// saveToDisk saves sessions to disk, pruning (and archiving) those past retention (internal helper, requires lock)
+// Archives are written first, so a failed save can duplicate archived records but never lose them
 func (r *JSONSessionRepository) saveToDisk(sessions []*entities.Session) error {
-	sessionsFile := filepath.Join(r.dataFolder, "sessions.json")
+	sessionsFile := r.sessionsFile()
+
+	sessions, pruned := r.prune(sessions)
+	if r.archive && len(pruned) > 0 {
+		if err := r.archiveSessions(pruned); err != nil {
+			return err
+		}
+	}
 
 	// Convert entities to DTOs
 	dtos := make([]*dto.SessionPersistenceDTO, 0, len(sessions))
@@ -220,17 +230,87 @@ func (r *JSONSessionRepository) saveToDisk(sessions []*entities.Session) error {
 		return fmt.Errorf("failed to marshal sessions: %w", err)
 	}
 
-	// Write to temporary file first
-	tmpFile := sessionsFile + ".tmp"
-	if err := os.WriteFile(tmpFile, data, 0o600); err != nil {
+	// Atomic write (temp file verified, then renamed; previous file kept as .bak), fsynced when storage.fsync is set
+	if err := atomicfile.WriteVerified(sessionsFile, data, 0o600, r.fsync, countJSONArray, len(dtos)); err != nil {
 		return fmt.Errorf("failed to write sessions file: %w", err)
 	}
 
-	// Atomic rename
-	if err := os.Rename(tmpFile, sessionsFile); err != nil {
-		os.Remove(tmpFile)
-		return fmt.Errorf("failed to rename sessions file: %w", err)
+	return nil
+}
+
+// prune splits sessions into those to keep and those that ended more than retention ago
+func (r *JSONSessionRepository) prune(sessions []*entities.Session) ([]*entities.Session, []*entities.Session) {
+	if r.retention <= 0 {
+		return sessions, nil
 	}
 
+	cutoff := time.Now().Add(-r.retention)
+	kept := make([]*entities.Session, 0, len(sessions))
+	var pruned []*entities.Session
+	for _, session := range sessions {
+		if session.EndedAt().Before(cutoff) {
+			pruned = append(pruned, session)
+			continue
+		}
+		kept = append(kept, session)
+	}
+	return kept, pruned
+}
+
+// archiveSessions appends sessions to the monthly archive of the month they ended in
+// (sessions-archive-YYYY-MM.json, UTC), skipping records already archived (internal helper, requires lock)
+func (r *JSONSessionRepository) archiveSessions(sessions []*entities.Session) error {
+	byMonth := make(map[string][]*entities.Session)
+	for _, session := range sessions {
+		month := session.EndedAt().UTC().Format("2006-01")
+		byMonth[month] = append(byMonth[month], session)
+	}
+
+	months := make([]string, 0, len(byMonth))
+	for month := range byMonth {
+		months = append(months, month)
+	}
+	sort.Strings(months)
+
+	for _, month := range months {
+		archiveFile := filepath.Join(r.dataFolder, "sessions-archive-"+month+".json")
+
+		var dtos []*dto.SessionPersistenceDTO
+		data, err := os.ReadFile(archiveFile)
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to read session archive: %w", err)
+		}
+		if len(data) > 0 {
+			if err := json.Unmarshal(data, &dtos); err != nil {
+				return fmt.Errorf("failed to parse session archive %s: %w", filepath.Base(archiveFile), err)
+			}
+		}
+
+		archived := make(map[string]bool, len(dtos))
+		for _, d := range dtos {
+			archived[d.ID] = true
+		}
+		for _, session := range byMonth[month] {
+			if !archived[session.ID] {
+				dtos = append(dtos, dto.ToSessionPersistenceDTO(session))
+			}
+		}
+
+		data, err = json.MarshalIndent(dtos, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal session archive: %w", err)
+		}
+		if err := atomicfile.Write(archiveFile, data, 0o600, r.fsync); err != nil {
+			return fmt.Errorf("failed to write session archive: %w", err)
+		}
+	}
 	return nil
 }
+
+// WithWriteLock runs fn while holding the write lock, so no save can modify the data file meanwhile
+func (r *JSONSessionRepository) WithWriteLock(fn func(dataFile string) error) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return fn(r.sessionsFile())
+}
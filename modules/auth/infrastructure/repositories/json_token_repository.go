@@ -11,19 +11,33 @@ import (
 	"claude-proxy/modules/auth/application/dto"
 	"claude-proxy/modules/auth/domain/entities"
 	"claude-proxy/modules/auth/domain/interfaces"
+	"claude-proxy/pkg/atomicfile"
+
+	sctx "github.com/phathdt/service-context"
 )
 
 // JSONTokenRepository implements TokenPersistenceRepository using JSON file storage
 // This repository ONLY handles disk I/O, no in-memory caching
+// Every operation also holds the file's cross-process lock, as CLI commands may edit the data folder
+// of a running server
 type JSONTokenRepository struct {
 	dataFolder string
+	fsync      bool         // Flush writes to disk before reporting success (storage.fsync)
 	mu         sync.RWMutex // Only for file I/O concurrency control
+	seen       fileState    // tokens.json as last loaded or saved by this repository
+	logger     sctx.Logger
 }
 
 // NewJSONTokenRepository creates a new JSON token repository
-func NewJSONTokenRepository(dataFolder string) (interfaces.TokenPersistenceRepository, error) {
+func NewJSONTokenRepository(
+	dataFolder string,
+	fsync bool,
+	logger sctx.Logger,
+) (interfaces.TokenPersistenceRepository, error) {
 	repo := &JSONTokenRepository{
-		dataFolder: expandPath(dataFolder),
+		dataFolder: ExpandPath(dataFolder),
+		fsync:      fsync,
+		logger:     logger,
 	}
 
 	// Create data folder if it doesn't exist
@@ -34,66 +48,58 @@ func NewJSONTokenRepository(dataFolder string) (interfaces.TokenPersistenceRepos
 	return repo, nil
 }
 
+// tokensFile returns the path of tokens.json
+func (r *JSONTokenRepository) tokensFile() string {
+	return filepath.Join(r.dataFolder, "tokens.json")
+}
+
 // SaveAll persists all tokens to durable storage (batch operation)
-func (r *JSONTokenRepository) SaveAll(ctx context.Context, tokens []*entities.Token) error {
+// Without force, no tokens are refused while tokens.json holds some
+func (r *JSONTokenRepository) SaveAll(ctx context.Context, tokens []*entities.Token, force bool) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	tokensFile := filepath.Join(r.dataFolder, "tokens.json")
-
-	// Convert entities to DTOs
-	dtos := make([]*dto.TokenPersistenceDTO, 0, len(tokens))
-	for _, token := range tokens {
-		dtos = append(dtos, dto.ToTokenPersistenceDTO(token))
-	}
-
-	// Marshal to JSON
-	data, err := json.MarshalIndent(dtos, "", "  ")
-	if err != nil {
-		return fmt.Errorf("failed to marshal tokens: %w", err)
-	}
-
-	// Write to temporary file first (atomic write)
-	tmpFile := tokensFile + ".tmp"
-	if err := os.WriteFile(tmpFile, data, 0o600); err != nil {
-		return fmt.Errorf("failed to write tokens file: %w", err)
-	}
-
-	// Atomic rename
-	if err := os.Rename(tmpFile, tokensFile); err != nil {
-		os.Remove(tmpFile)
-		return fmt.Errorf("failed to rename tokens file: %w", err)
-	}
-
-	return nil
+	return withFileLock(r.tokensFile(), func() error {
+		if err := checkEmptySave(r.tokensFile(), len(tokens), force, countJSONArray); err != nil {
+			return err
+		}
+		return r.saveToDisk(tokens)
+	})
 }
 
 // LoadAll loads all tokens from durable storage
+// Cleartext keys of schema version 1 files are hashed, and the file is rewritten without them right away
 func (r *JSONTokenRepository) LoadAll(ctx context.Context) ([]*entities.Token, error) {
-	r.mu.RLock()
-	defer r.mu.RUnlock()
-
-	tokensFile := filepath.Join(r.dataFolder, "tokens.json")
+	r.mu.Lock()
+	defer r.mu.Unlock()
 
-	data, err := os.ReadFile(tokensFile)
-	if err != nil {
-		if os.IsNotExist(err) {
-			return []*entities.Token{}, nil // No tokens yet
+	var tokens []*entities.Token
+	err := withFileLock(r.tokensFile(), func() error {
+		var legacy bool
+		var err error
+		tokens, legacy, err = r.readTokensFile()
+		if err != nil || !legacy {
+			return err
 		}
-		return nil, fmt.Errorf("failed to read tokens file: %w", err)
-	}
+		if err := r.saveToDisk(tokens); err != nil {
+			return fmt.Errorf("failed to migrate tokens file to hashed keys: %w", err)
+		}
+		return nil
+	})
+	return tokens, err
+}
 
-	var dtos []*dto.TokenPersistenceDTO
-	if err := json.Unmarshal(data, &dtos); err != nil {
-		return nil, fmt.Errorf("failed to parse tokens file: %w", err)
-	}
+// Changed returns true if tokens.json was modified by another writer since this repository last
+// loaded or saved it
+func (r *JSONTokenRepository) Changed(ctx context.Context) (bool, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
 
-	tokens := make([]*entities.Token, 0, len(dtos))
-	for _, d := range dtos {
-		tokens = append(tokens, dto.FromTokenPersistenceDTO(d))
+	_, current, err := readFileState(r.tokensFile())
+	if err != nil {
+		return false, fmt.Errorf("failed to read tokens file: %w", err)
 	}
-
-	return tokens, nil
+	return r.seen.differs(current), nil
 }
 
 // Create creates and persists a new token
@@ -101,24 +107,26 @@ func (r *JSONTokenRepository) Create(ctx context.Context, token *entities.Token)
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	// Load all existing tokens
-	tokens, err := r.loadFromDisk()
-	if err != nil {
-		return err
-	}
+	return withFileLock(r.tokensFile(), func() error {
+		// Load all existing tokens
+		tokens, err := r.loadFromDisk()
+		if err != nil {
+			return err
+		}
 
-	// Check for duplicates
-	for _, t := range tokens {
-		if t.ID == token.ID {
-			return fmt.Errorf("token with ID already exists: %s", token.ID)
+		// Check for duplicates
+		for _, t := range tokens {
+			if t.ID == token.ID {
+				return fmt.Errorf("token with ID already exists: %s", token.ID)
+			}
 		}
-	}
 
-	// Add new token
-	tokens = append(tokens, token)
+		// Add new token
+		tokens = append(tokens, token)
 
-	// Save all back to disk
-	return r.saveToDisk(tokens)
+		// Save all back to disk
+		return r.saveToDisk(tokens)
+	})
 }
 
 // Update updates and persists an existing token
@@ -126,28 +134,30 @@ func (r *JSONTokenRepository) Update(ctx context.Context, token *entities.Token)
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	// Load all existing tokens
-	tokens, err := r.loadFromDisk()
-	if err != nil {
-		return err
-	}
+	return withFileLock(r.tokensFile(), func() error {
+		// Load all existing tokens
+		tokens, err := r.loadFromDisk()
+		if err != nil {
+			return err
+		}
 
-	// Find and update the token
-	found := false
-	for i, t := range tokens {
-		if t.ID == token.ID {
-			tokens[i] = token
-			found = true
-			break
+		// Find and update the token
+		found := false
+		for i, t := range tokens {
+			if t.ID == token.ID {
+				tokens[i] = token
+				found = true
+				break
+			}
 		}
-	}
 
-	if !found {
-		return fmt.Errorf("token not found: %s", token.ID)
-	}
+		if !found {
+			return fmt.Errorf("token not found: %s", token.ID)
+		}
 
-	// Save all back to disk
-	return r.saveToDisk(tokens)
+		// Save all back to disk
+		return r.saveToDisk(tokens)
+	})
 }
 
 // Delete deletes a token from persistent storage
@@ -155,58 +165,71 @@ func (r *JSONTokenRepository) Delete(ctx context.Context, id string) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	// Load all existing tokens
-	tokens, err := r.loadFromDisk()
-	if err != nil {
-		return err
-	}
+	return withFileLock(r.tokensFile(), func() error {
+		// Load all existing tokens
+		tokens, err := r.loadFromDisk()
+		if err != nil {
+			return err
+		}
 
-	// Find and remove the token
-	found := false
-	for i, t := range tokens {
-		if t.ID == id {
-			tokens = append(tokens[:i], tokens[i+1:]...)
-			found = true
-			break
+		// Find and remove the token
+		found := false
+		for i, t := range tokens {
+			if t.ID == id {
+				tokens = append(tokens[:i], tokens[i+1:]...)
+				found = true
+				break
+			}
 		}
-	}
 
-	if !found {
-		return fmt.Errorf("token not found: %s", id)
-	}
+		if !found {
+			return fmt.Errorf("token not found: %s", id)
+		}
 
-	// Save all back to disk
-	return r.saveToDisk(tokens)
+		// Save all back to disk
+		return r.saveToDisk(tokens)
+	})
 }
 
-// loadFromDisk loads tokens from disk (internal helper, requires lock)
+// loadFromDisk loads tokens from disk (internal helper, requires both locks)
 func (r *JSONTokenRepository) loadFromDisk() ([]*entities.Token, error) {
-	tokensFile := filepath.Join(r.dataFolder, "tokens.json")
+	tokens, _, err := r.readTokensFile()
+	return tokens, err
+}
 
-	data, err := os.ReadFile(tokensFile)
+// readTokensFile loads tokens from disk, hashing cleartext keys and reporting whether there were any
+// (internal helper, requires both locks)
+func (r *JSONTokenRepository) readTokensFile() ([]*entities.Token, bool, error) {
+	data, state, err := readDataFile(r.tokensFile(), r.logger)
 	if err != nil {
-		if os.IsNotExist(err) {
-			return []*entities.Token{}, nil
-		}
-		return nil, fmt.Errorf("failed to read tokens file: %w", err)
+		return nil, false, fmt.Errorf("failed to read tokens file: %w", err)
+	}
+	if !state.exists {
+		r.seen = state
+		return []*entities.Token{}, false, nil
 	}
 
 	var dtos []*dto.TokenPersistenceDTO
 	if err := json.Unmarshal(data, &dtos); err != nil {
-		return nil, fmt.Errorf("failed to parse tokens file: %w", err)
+		return nil, false, fmt.Errorf("failed to parse tokens file: %w", err)
 	}
 
+	legacy := false
 	tokens := make([]*entities.Token, 0, len(dtos))
 	for _, d := range dtos {
+		if d.HashLegacyKey() {
+			legacy = true
+		}
 		tokens = append(tokens, dto.FromTokenPersistenceDTO(d))
 	}
 
-	return tokens, nil
+	r.seen = state
+	return tokens, legacy, nil
 }
 
-// saveToDisk saves tokens to disk (internal helper, requires lock)
+// saveToDisk saves tokens to disk (internal helper, requires both locks)
 func (r *JSONTokenRepository) saveToDisk(tokens []*entities.Token) error {
-	tokensFile := filepath.Join(r.dataFolder, "tokens.json")
+	tokensFile := r.tokensFile()
 
 	// Convert entities to DTOs
 	dtos := make([]*dto.TokenPersistenceDTO, 0, len(tokens))
@@ -220,17 +243,23 @@ func (r *JSONTokenRepository) saveToDisk(tokens []*entities.Token) error {
 		return fmt.Errorf("failed to marshal tokens: %w", err)
 	}
 
-	// Write to temporary file first
-	tmpFile := tokensFile + ".tmp"
-	if err := os.WriteFile(tmpFile, data, 0o600); err != nil {
+	// Atomic write (temp file verified, then renamed; previous file kept as .bak), fsynced when storage.fsync is set
+	if err := atomicfile.WriteVerified(tokensFile, data, 0o600, r.fsync, countJSONArray, len(dtos)); err != nil {
 		return fmt.Errorf("failed to write tokens file: %w", err)
 	}
 
-	// Atomic rename
-	if err := os.Rename(tmpFile, tokensFile); err != nil {
-		os.Remove(tmpFile)
-		return fmt.Errorf("failed to rename tokens file: %w", err)
+	if _, state, err := readFileState(tokensFile); err == nil {
+		r.seen = state
 	}
-
 	return nil
 }
+
+// WithWriteLock runs fn while holding the write lock, so no save can modify the data file meanwhile
+func (r *JSONTokenRepository) WithWriteLock(fn func(dataFile string) error) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return withFileLock(r.tokensFile(), func() error {
+		return fn(r.tokensFile())
+	})
+}
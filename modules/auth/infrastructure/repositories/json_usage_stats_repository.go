@@ -0,0 +1,101 @@
+package repositories
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"claude-proxy/modules/auth/application/dto"
+	"claude-proxy/modules/auth/domain/entities"
+	"claude-proxy/modules/auth/domain/interfaces"
+	"claude-proxy/pkg/atomicfile"
+)
+
+// JSONUsageStatsRepository implements UsageStatsPersistenceRepository using JSON file storage
+// This repository ONLY handles disk I/O, no in-memory caching
+type JSONUsageStatsRepository struct {
+	dataFolder string
+	fsync      bool         // Flush writes to disk before reporting success (storage.fsync)
+	mu         sync.RWMutex // Only for file I/O concurrency control
+}
+
+// NewJSONUsageStatsRepository creates a new JSON usage stats repository
+func NewJSONUsageStatsRepository(dataFolder string, fsync bool) (interfaces.UsageStatsPersistenceRepository, error) {
+	repo := &JSONUsageStatsRepository{
+		dataFolder: ExpandPath(dataFolder),
+		fsync:      fsync,
+	}
+
+	// Create data folder if it doesn't exist
+	if err := os.MkdirAll(repo.dataFolder, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create data folder: %w", err)
+	}
+
+	return repo, nil
+}
+
+// SaveAll persists all buckets to durable storage (batch operation)
+func (r *JSONUsageStatsRepository) SaveAll(ctx context.Context, buckets []*entities.UsageBucket) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	statsFile := filepath.Join(r.dataFolder, "stats.json")
+
+	// Convert entities to DTOs
+	dtos := make([]*dto.UsageBucketPersistenceDTO, 0, len(buckets))
+	for _, bucket := range buckets {
+		dtos = append(dtos, dto.ToUsageBucketPersistenceDTO(bucket))
+	}
+
+	// Compact encoding: this file can hold thousands of buckets
+	data, err := json.Marshal(dtos)
+	if err != nil {
+		return fmt.Errorf("failed to marshal usage stats: %w", err)
+	}
+
+	// Atomic write (temp file + rename), fsynced when storage.fsync is set
+	if err := atomicfile.Write(statsFile, data, 0o600, r.fsync); err != nil {
+		return fmt.Errorf("failed to write usage stats file: %w", err)
+	}
+
+	return nil
+}
+
+// LoadAll loads all buckets from durable storage
+func (r *JSONUsageStatsRepository) LoadAll(ctx context.Context) ([]*entities.UsageBucket, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	statsFile := filepath.Join(r.dataFolder, "stats.json")
+
+	data, err := os.ReadFile(statsFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []*entities.UsageBucket{}, nil // No stats yet
+		}
+		return nil, fmt.Errorf("failed to read usage stats file: %w", err)
+	}
+
+	var dtos []*dto.UsageBucketPersistenceDTO
+	if err := json.Unmarshal(data, &dtos); err != nil {
+		return nil, fmt.Errorf("failed to parse usage stats file: %w", err)
+	}
+
+	buckets := make([]*entities.UsageBucket, 0, len(dtos))
+	for _, d := range dtos {
+		buckets = append(buckets, dto.FromUsageBucketPersistenceDTO(d))
+	}
+
+	return buckets, nil
+}
+
+// WithWriteLock runs fn while holding the write lock, so no save can modify the data file meanwhile
+func (r *JSONUsageStatsRepository) WithWriteLock(fn func(dataFile string) error) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return fn(filepath.Join(r.dataFolder, "stats.json"))
+}
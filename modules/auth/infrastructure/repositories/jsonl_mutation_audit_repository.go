@@ -0,0 +1,125 @@
+package repositories
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+
+	"claude-proxy/modules/auth/application/dto"
+	"claude-proxy/modules/auth/domain/entities"
+	"claude-proxy/modules/auth/domain/interfaces"
+
+	sctx "github.com/phathdt/service-context"
+)
+
+// mutationAuditFileName is the audit trail file in the data folder, one JSON entry per line
+const mutationAuditFileName = "admin_audit.jsonl"
+
+// maxMutationAuditLine bounds the length of an audit line read back (a line is a few KB at most)
+const maxMutationAuditLine = 1 << 20
+
+// JSONLMutationAuditRepository implements MutationAuditRepository as an append-only JSON Lines file
+// Entries are only ever appended, never rewritten; lines that can't be parsed are skipped when listing.
+type JSONLMutationAuditRepository struct {
+	path   string
+	fsync  bool       // Flush appends to disk before reporting success (storage.fsync)
+	mu     sync.Mutex // Serializes appends so lines never interleave
+	logger sctx.Logger
+}
+
+// NewJSONLMutationAuditRepository creates the audit trail repository of a data folder
+func NewJSONLMutationAuditRepository(
+	dataFolder string,
+	fsync bool,
+	logger sctx.Logger,
+) (interfaces.MutationAuditRepository, error) {
+	folder := ExpandPath(dataFolder)
+	if err := os.MkdirAll(folder, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create data folder: %w", err)
+	}
+
+	return &JSONLMutationAuditRepository{
+		path:   filepath.Join(folder, mutationAuditFileName),
+		fsync:  fsync,
+		logger: logger,
+	}, nil
+}
+
+// Append writes the entry as a new line at the end of the file
+func (r *JSONLMutationAuditRepository) Append(ctx context.Context, entry *entities.MutationAuditEntry) error {
+	line, err := json.Marshal(dto.ToMutationAuditEntryDTO(entry))
+	if err != nil {
+		return fmt.Errorf("failed to marshal audit entry: %w", err)
+	}
+	line = append(line, '\n')
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	file, err := os.OpenFile(r.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open audit file: %w", err)
+	}
+	if _, err := file.Write(line); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write audit file: %w", err)
+	}
+	if r.fsync {
+		if err := file.Sync(); err != nil {
+			file.Close()
+			return fmt.Errorf("failed to sync audit file: %w", err)
+		}
+	}
+	return file.Close()
+}
+
+// List reads the file and returns the entries matching filter, newest first
+func (r *JSONLMutationAuditRepository) List(
+	ctx context.Context,
+	filter entities.MutationAuditFilter,
+) ([]*entities.MutationAuditEntry, error) {
+	file, err := os.Open(r.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []*entities.MutationAuditEntry{}, nil // Nothing audited yet
+		}
+		return nil, fmt.Errorf("failed to open audit file: %w", err)
+	}
+	defer file.Close()
+
+	var entries []*entities.MutationAuditEntry
+	skipped := 0
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxMutationAuditLine)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var line dto.MutationAuditEntryDTO
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			skipped++ // A line cut short by a crash, or edited by hand
+			continue
+		}
+		if entry := dto.FromMutationAuditEntryDTO(&line); filter.Matches(entry) {
+			entries = append(entries, entry)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read audit file: %w", err)
+	}
+	if skipped > 0 {
+		r.logger.Withs(sctx.Fields{"file": r.path, "skipped": skipped}).Warn("Skipped unreadable audit lines")
+	}
+
+	// The file is in write order, so the newest entries are at its end
+	if filter.Limit > 0 && len(entries) > filter.Limit {
+		entries = entries[len(entries)-filter.Limit:]
+	}
+	slices.Reverse(entries)
+	return entries, nil
+}
@@ -0,0 +1,185 @@
+package repositories
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"claude-proxy/modules/auth/application/dto"
+	"claude-proxy/modules/auth/domain/entities"
+	"claude-proxy/modules/auth/domain/interfaces"
+
+	sctx "github.com/phathdt/service-context"
+)
+
+const (
+	// sessionHistoryFilePrefix and sessionHistoryFileSuffix surround the month (YYYY-MM) of a session history file
+	sessionHistoryFilePrefix = "session-history-"
+	sessionHistoryFileSuffix = ".jsonl"
+
+	// sessionHistoryMonthLayout formats the month in session history file names
+	sessionHistoryMonthLayout = "2006-01"
+
+	// maxSessionHistoryLine bounds the length of a session history line read back (a line is under 1 KB)
+	maxSessionHistoryLine = 64 * 1024
+)
+
+// JSONLSessionHistoryRepository implements SessionHistoryRepository as one append-only JSON Lines file per month
+// (session-history-YYYY-MM.jsonl, UTC); lines that can't be parsed are skipped when listing
+type JSONLSessionHistoryRepository struct {
+	dataFolder string
+	fsync      bool       // Flush appends to disk before reporting success (storage.fsync)
+	mu         sync.Mutex // Serializes appends so lines never interleave
+	logger     sctx.Logger
+}
+
+// NewJSONLSessionHistoryRepository creates the session history repository of a data folder
+func NewJSONLSessionHistoryRepository(
+	dataFolder string,
+	fsync bool,
+	logger sctx.Logger,
+) (interfaces.SessionHistoryRepository, error) {
+	folder := ExpandPath(dataFolder)
+	if err := os.MkdirAll(folder, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create data folder: %w", err)
+	}
+
+	return &JSONLSessionHistoryRepository{
+		dataFolder: folder,
+		fsync:      fsync,
+		logger:     logger,
+	}, nil
+}
+
+// monthFile returns the file holding the records of a month
+func (r *JSONLSessionHistoryRepository) monthFile(month time.Time) string {
+	name := sessionHistoryFilePrefix + month.UTC().Format(sessionHistoryMonthLayout) + sessionHistoryFileSuffix
+	return filepath.Join(r.dataFolder, name)
+}
+
+// Append writes the records as new lines at the end of the files of their months
+func (r *JSONLSessionHistoryRepository) Append(ctx context.Context, records []*entities.SessionHistoryRecord) error {
+	byFile := make(map[string][]byte)
+	var files []string
+	for _, record := range records {
+		line, err := json.Marshal(dto.ToSessionHistoryRecordDTO(record))
+		if err != nil {
+			return fmt.Errorf("failed to marshal session history record: %w", err)
+		}
+		file := r.monthFile(record.CreatedAt)
+		if _, ok := byFile[file]; !ok {
+			files = append(files, file)
+		}
+		byFile[file] = append(append(byFile[file], line...), '\n')
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, file := range files {
+		if err := r.appendLines(file, byFile[file]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// appendLines appends data to a month file (internal helper, requires lock)
+func (r *JSONLSessionHistoryRepository) appendLines(path string, data []byte) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open session history file: %w", err)
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write session history file: %w", err)
+	}
+	if r.fsync {
+		if err := file.Sync(); err != nil {
+			file.Close()
+			return fmt.Errorf("failed to sync session history file: %w", err)
+		}
+	}
+	return file.Close()
+}
+
+// List reads a month's file and returns its records (of one token unless tokenID is empty), newest first
+func (r *JSONLSessionHistoryRepository) List(
+	ctx context.Context,
+	month time.Time,
+	tokenID string,
+) ([]*entities.SessionHistoryRecord, error) {
+	path := r.monthFile(month)
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []*entities.SessionHistoryRecord{}, nil // No session recorded that month
+		}
+		return nil, fmt.Errorf("failed to open session history file: %w", err)
+	}
+	defer file.Close()
+
+	records := []*entities.SessionHistoryRecord{}
+	skipped := 0
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxSessionHistoryLine)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var line dto.SessionHistoryRecordDTO
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			skipped++ // A line cut short by a crash or a full disk
+			continue
+		}
+		if tokenID == "" || line.TokenID == tokenID {
+			records = append(records, dto.FromSessionHistoryRecordDTO(&line))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read session history file: %w", err)
+	}
+	if skipped > 0 {
+		r.logger.Withs(sctx.Fields{"file": path, "skipped": skipped}).Warn("Skipped unreadable session history lines")
+	}
+
+	// The file is in write order, so the newest records are at its end
+	slices.Reverse(records)
+	return records, nil
+}
+
+// Prune deletes the files of the months that ended before cutoff
+func (r *JSONLSessionHistoryRepository) Prune(ctx context.Context, cutoff time.Time) ([]string, error) {
+	paths, err := filepath.Glob(filepath.Join(r.dataFolder, sessionHistoryFilePrefix+"*"+sessionHistoryFileSuffix))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list session history files: %w", err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var pruned []string
+	for _, path := range paths {
+		name := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(path), sessionHistoryFilePrefix),
+			sessionHistoryFileSuffix)
+		month, err := time.Parse(sessionHistoryMonthLayout, name)
+		if err != nil {
+			continue // Not one of ours
+		}
+		if !month.AddDate(0, 1, 0).Before(cutoff) {
+			continue
+		}
+		if err := os.Remove(path); err != nil {
+			return pruned, fmt.Errorf("failed to delete session history file: %w", err)
+		}
+		pruned = append(pruned, name)
+	}
+	return pruned, nil
+}
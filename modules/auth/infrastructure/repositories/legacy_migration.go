@@ -0,0 +1,132 @@
+package repositories
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"claude-proxy/modules/auth/domain/entities"
+	"claude-proxy/pkg/uid"
+
+	sctx "github.com/phathdt/service-context"
+)
+
+const (
+	// legacyAccountFileName is the single account written by the old single-account deployment
+	legacyAccountFileName = "account.json"
+	// migratedAccountFileSuffix is appended to account.json once its account is in accounts.json
+	migratedAccountFileSuffix = ".migrated"
+)
+
+// LegacyMigrationResult reports what MigrateLegacyAccountFiles converted
+type LegacyMigrationResult struct {
+	ConvertedMapAccounts int  // Accounts of a legacy map-shaped accounts.json rewritten in the current format
+	ImportedAccountFile  bool // account.json was imported into accounts.json and renamed
+	SkippedAccountFile   bool // account.json was left alone because accounts.json already holds accounts
+}
+
+// MigrateLegacyAccountFiles brings account files of older releases into the current accounts.json format:
+// an accounts.json in the old CLI layout (an object keyed by organization UUID) is rewritten in the current
+// format, and the account of a single-account account.json is imported when accounts.json has none, after
+// which account.json is renamed to account.json.migrated
+// Running it again finds nothing left to convert.
+func MigrateLegacyAccountFiles(dataFolder string, fsync bool, logger sctx.Logger) (*LegacyMigrationResult, error) {
+	repo := &JSONAccountPersistenceRepository{dataFolder: ExpandPath(dataFolder), fsync: fsync, logger: logger}
+	legacyFile := filepath.Join(repo.dataFolder, legacyAccountFileName)
+	result := &LegacyMigrationResult{}
+
+	err := withFileLock(repo.accountsFile(), func() error {
+		data, state, err := readFileState(repo.accountsFile())
+		if err != nil {
+			return fmt.Errorf("failed to read accounts file: %w", err)
+		}
+
+		var accounts []*entities.Account
+		if state.exists {
+			if accounts, _, err = parseAccountsFile(data); err != nil {
+				return err
+			}
+			if isLegacyAccountMap(data) {
+				// Saving rewrites the file in the current format; an empty map is simply dropped below
+				if len(accounts) > 0 {
+					if err := repo.saveToDisk(accounts); err != nil {
+						return err
+					}
+				}
+				result.ConvertedMapAccounts = len(accounts)
+			}
+		}
+
+		legacyData, legacyState, err := readFileState(legacyFile)
+		if err != nil {
+			return fmt.Errorf("failed to read legacy account file: %w", err)
+		}
+		if !legacyState.exists {
+			return nil
+		}
+		if len(accounts) > 0 {
+			result.SkippedAccountFile = true
+			return nil
+		}
+
+		imported, err := parseLegacyAccountFile(legacyData)
+		if err != nil {
+			return err
+		}
+		if len(imported) > 0 {
+			if err := repo.saveToDisk(imported); err != nil {
+				return err
+			}
+		}
+		// accounts.json is durable before account.json is moved aside, so a crash in between imports it again
+		if err := os.Rename(legacyFile, legacyFile+migratedAccountFileSuffix); err != nil {
+			return fmt.Errorf("failed to rename legacy account file: %w", err)
+		}
+		result.ImportedAccountFile = true
+		return nil
+	})
+	return result, err
+}
+
+// isLegacyAccountMap returns true if an accounts.json holds the old CLI layout (an object without schema header)
+func isLegacyAccountMap(data []byte) bool {
+	trimmed := bytes.TrimSpace(data)
+	return len(trimmed) > 0 && trimmed[0] == '{' && accountsFileSchemaVersion(trimmed) <= 1
+}
+
+// parseLegacyAccountFile converts account.json to entities with new IDs
+// It holds one account; an object keyed by organization UUID (the old CLI layout) is accepted as well.
+func parseLegacyAccountFile(data []byte) ([]*entities.Account, error) {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) == 0 {
+		return nil, nil
+	}
+
+	var object map[string]interface{}
+	if err := json.Unmarshal(trimmed, &object); err != nil {
+		return nil, fmt.Errorf("failed to parse legacy account file: %w", err)
+	}
+
+	_, nested := object["oauth_token"]
+	_, flat := object["access_token"]
+	if !nested && !flat {
+		// Every entry of the CLI layout is an account; anything else is an account without tokens
+		accounts := fromLegacyAccountMap(object)
+		if len(accounts) < len(object) {
+			return nil, fmt.Errorf("legacy account file holds no OAuth tokens")
+		}
+		return accounts, nil
+	}
+
+	account := fromLegacyAccount(object)
+	if account.AccessToken == "" && account.RefreshToken == "" {
+		return nil, fmt.Errorf("legacy account file holds no OAuth tokens")
+	}
+	account.ID = uid.New()
+	if account.Name == "" {
+		account.Name = "Migrated account"
+	}
+	return []*entities.Account{account}, nil
+}
@@ -0,0 +1,289 @@
+package repositories
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"claude-proxy/modules/auth/application/dto"
+	"claude-proxy/modules/auth/domain/entities"
+	"claude-proxy/pkg/uid"
+)
+
+const (
+	// legacyCLIAccounts is an accounts.json in the old CLI layout: accounts keyed by organization UUID
+	legacyCLIAccounts = `{
+  "0b9e3c3e-8a4f-4c53-9d0e-2f6d1c1a7b01": {
+    "name": "work",
+    "oauth_token": {
+      "access_token": "sk-ant-oat01-work",
+      "refresh_token": "sk-ant-ort01-work",
+      "expires_at": 1900000000
+    },
+    "status": "valid"
+  },
+  "5d2f1a9c-3e7b-4b6a-8c1d-9e0f2a3b4c02": {
+    "email": "home@example.com",
+    "oauth_token": {"access_token": "sk-ant-oat01-home", "refresh_token": "sk-ant-ort01-home"},
+    "status": "invalid"
+  }
+}`
+	// legacySingleAccount is an account.json of the single-account deployment, tokens nested under oauth_token
+	legacySingleAccount = `{
+  "organization_uuid": "7c4e2b1a-6d3f-4a5b-9c8d-1e2f3a4b5c03",
+  "email": "solo@example.com",
+  "oauth_token": {
+    "access_token": "sk-ant-oat01-solo",
+    "refresh_token": "sk-ant-ort01-solo",
+    "expires_at": "2030-03-17T12:00:00Z"
+  },
+  "status": "valid",
+  "created_at": "2024-11-02T08:30:00Z"
+}`
+	// legacyFlatAccount is an account.json with its tokens at the top level
+	legacyFlatAccount = `{"access_token": "sk-ant-oat01-flat", "refresh_token": "sk-ant-ort01-flat", ` +
+		`"expires_at": 1900000000, "status": "rate_limited"}`
+)
+
+// wantAccount is what a migrated account must hold
+type wantAccount struct {
+	name        string
+	orgUUID     string // Also its ID, for accounts keyed by organization UUID; "" for a new UUIDv7 ID
+	accessToken string
+	status      entities.AccountStatus
+	expiresAt   time.Time // Zero when the legacy account had no expiry
+	createdAt   time.Time // Zero when the migration time is used
+	orgIDIsID   bool      // The organization UUID is the ID
+}
+
+func TestMigrateLegacyAccountFiles(t *testing.T) {
+	soloExpiry := time.Date(2030, 3, 17, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name        string
+		accounts    []byte // accounts.json; nil when missing
+		legacy      []byte // account.json; nil when missing
+		want        LegacyMigrationResult
+		wantErr     bool
+		wantRenamed bool          // account.json moved to account.json.migrated
+		wantStored  []wantAccount // accounts.json afterwards, by name
+	}{
+		{
+			name:     "CLI map accounts.json",
+			accounts: []byte(legacyCLIAccounts),
+			want:     LegacyMigrationResult{ConvertedMapAccounts: 2},
+			wantStored: []wantAccount{
+				{name: "home@example.com", orgUUID: "5d2f1a9c-3e7b-4b6a-8c1d-9e0f2a3b4c02",
+					accessToken: "sk-ant-oat01-home", status: entities.AccountStatusInvalid, orgIDIsID: true},
+				{name: "work", orgUUID: "0b9e3c3e-8a4f-4c53-9d0e-2f6d1c1a7b01", accessToken: "sk-ant-oat01-work",
+					status: entities.AccountStatusActive, expiresAt: time.Unix(1900000000, 0), orgIDIsID: true},
+			},
+		},
+		{
+			name:        "single account.json",
+			legacy:      []byte(legacySingleAccount),
+			want:        LegacyMigrationResult{ImportedAccountFile: true},
+			wantRenamed: true,
+			wantStored: []wantAccount{
+				{name: "solo@example.com", orgUUID: "7c4e2b1a-6d3f-4a5b-9c8d-1e2f3a4b5c03",
+					accessToken: "sk-ant-oat01-solo", status: entities.AccountStatusActive, expiresAt: soloExpiry,
+					createdAt: time.Date(2024, 11, 2, 8, 30, 0, 0, time.UTC)},
+			},
+		},
+		{
+			name:        "account.json with top-level tokens",
+			legacy:      []byte(legacyFlatAccount),
+			want:        LegacyMigrationResult{ImportedAccountFile: true},
+			wantRenamed: true,
+			wantStored: []wantAccount{
+				{name: "Migrated account", accessToken: "sk-ant-oat01-flat", status: entities.AccountStatusRateLimited,
+					expiresAt: time.Unix(1900000000, 0)},
+			},
+		},
+		{
+			name:        "account.json in the CLI map layout",
+			legacy:      []byte(legacyCLIAccounts),
+			want:        LegacyMigrationResult{ImportedAccountFile: true},
+			wantRenamed: true,
+			wantStored: []wantAccount{
+				{name: "home@example.com", orgUUID: "5d2f1a9c-3e7b-4b6a-8c1d-9e0f2a3b4c02",
+					accessToken: "sk-ant-oat01-home", status: entities.AccountStatusInvalid, orgIDIsID: true},
+				{name: "work", orgUUID: "0b9e3c3e-8a4f-4c53-9d0e-2f6d1c1a7b01", accessToken: "sk-ant-oat01-work",
+					status: entities.AccountStatusActive, expiresAt: time.Unix(1900000000, 0), orgIDIsID: true},
+			},
+		},
+		{
+			name:        "account.json next to an empty accounts.json",
+			accounts:    []byte(`[]`),
+			legacy:      []byte(legacySingleAccount),
+			want:        LegacyMigrationResult{ImportedAccountFile: true},
+			wantRenamed: true,
+			wantStored: []wantAccount{
+				{name: "solo@example.com", orgUUID: "7c4e2b1a-6d3f-4a5b-9c8d-1e2f3a4b5c03",
+					accessToken: "sk-ant-oat01-solo", status: entities.AccountStatusActive, expiresAt: soloExpiry,
+					createdAt: time.Date(2024, 11, 2, 8, 30, 0, 0, time.UTC)},
+			},
+		},
+		{
+			name:     "account.json next to a CLI map accounts.json",
+			accounts: []byte(legacyCLIAccounts),
+			legacy:   []byte(legacySingleAccount),
+			want:     LegacyMigrationResult{ConvertedMapAccounts: 2, SkippedAccountFile: true},
+			wantStored: []wantAccount{
+				{name: "home@example.com", orgUUID: "5d2f1a9c-3e7b-4b6a-8c1d-9e0f2a3b4c02",
+					accessToken: "sk-ant-oat01-home", status: entities.AccountStatusInvalid, orgIDIsID: true},
+				{name: "work", orgUUID: "0b9e3c3e-8a4f-4c53-9d0e-2f6d1c1a7b01", accessToken: "sk-ant-oat01-work",
+					status: entities.AccountStatusActive, expiresAt: time.Unix(1900000000, 0), orgIDIsID: true},
+			},
+		},
+		{
+			name:    "account.json without tokens",
+			legacy:  []byte(`{"email": "solo@example.com", "status": "valid"}`),
+			wantErr: true,
+		},
+		{
+			name:    "unparsable account.json",
+			legacy:  []byte(`{"oauth_token": {"access_tok`),
+			wantErr: true,
+		},
+		{name: "nothing to migrate"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			accountsFile := writeDataFile(t, dir, "accounts.json", tt.accounts)
+			legacyFile := writeDataFile(t, dir, legacyAccountFileName, tt.legacy)
+			logger := quietLogger(t)
+
+			result, err := MigrateLegacyAccountFiles(dir, false, logger)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("MigrateLegacyAccountFiles() = %+v, want an error", result)
+				}
+				// Nothing is touched: account.json stays for a fixed release to import
+				if data, err := os.ReadFile(legacyFile); err != nil || string(data) != string(tt.legacy) {
+					t.Errorf("account.json changed after a failed migration (%v)", err)
+				}
+				if _, err := os.Stat(accountsFile); !os.IsNotExist(err) {
+					t.Errorf("accounts.json written by a failed migration (%v)", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if *result != tt.want {
+				t.Errorf("result = %+v, want %+v", *result, tt.want)
+			}
+
+			_, legacyErr := os.Stat(legacyFile)
+			_, renamedErr := os.Stat(legacyFile + migratedAccountFileSuffix)
+			switch {
+			case tt.wantRenamed && (!os.IsNotExist(legacyErr) || renamedErr != nil):
+				t.Errorf("account.json not renamed (account.json: %v, account.json.migrated: %v)", legacyErr,
+					renamedErr)
+			case !tt.wantRenamed && tt.legacy != nil && legacyErr != nil:
+				t.Errorf("account.json moved though it was not imported: %v", legacyErr)
+			}
+
+			checkMigratedAccounts(t, dir, tt.wantStored)
+			if len(tt.wantStored) == 0 {
+				return
+			}
+
+			// Running again converts nothing and leaves accounts.json as it is
+			migrated, err := os.ReadFile(accountsFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			again, err := MigrateLegacyAccountFiles(dir, false, logger)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := (LegacyMigrationResult{SkippedAccountFile: tt.want.SkippedAccountFile}); *again != want {
+				t.Errorf("second run result = %+v, want %+v", *again, want)
+			}
+			if data, err := os.ReadFile(accountsFile); err != nil || string(data) != string(migrated) {
+				t.Errorf("second run rewrote accounts.json (%v)", err)
+			}
+
+			// Once migrated, accounts.json round-trips through single-account changes
+			repo, err := NewJSONAccountPersistenceRepository(dir, false, logger)
+			if err != nil {
+				t.Fatal(err)
+			}
+			added := &entities.Account{ID: uid.New(), Name: "added", Status: entities.AccountStatusActive}
+			if err := repo.Create(context.Background(), added); err != nil {
+				t.Fatal(err)
+			}
+			if err := repo.Delete(context.Background(), added.ID); err != nil {
+				t.Fatal(err)
+			}
+			checkMigratedAccounts(t, dir, tt.wantStored)
+		})
+	}
+}
+
+// checkMigratedAccounts checks accounts.json is in the current format and holds want
+func checkMigratedAccounts(t *testing.T, dir string, want []wantAccount) {
+	t.Helper()
+
+	data, err := os.ReadFile(filepath.Join(dir, "accounts.json"))
+	if len(want) == 0 {
+		if err == nil && len(strings.TrimSpace(string(data))) > 2 {
+			t.Errorf("accounts.json = %s, want no accounts", data)
+		}
+		return
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version := accountsFileSchemaVersion(data); version != dto.AccountsFileSchemaVersion {
+		t.Errorf("accounts.json schema = %d, want %d", version, dto.AccountsFileSchemaVersion)
+	}
+
+	accounts, _, err := parseAccountsFile(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	slices.SortFunc(accounts, func(a, b *entities.Account) int { return strings.Compare(a.Name, b.Name) })
+	if len(accounts) != len(want) {
+		t.Fatalf("accounts.json holds %d accounts, want %d", len(accounts), len(want))
+	}
+	for i, account := range accounts {
+		w := want[i]
+		if account.Name != w.name {
+			t.Errorf("account %d = %q, want %q", i, account.Name, w.name)
+			continue
+		}
+		switch {
+		case w.orgIDIsID && account.ID != w.orgUUID:
+			t.Errorf("%s: ID = %s, want its organization UUID", w.name, account.ID)
+		case !w.orgIDIsID && (!uid.IsUUID(account.ID) || account.ID == w.orgUUID):
+			t.Errorf("%s: ID = %q, want a new UUID", w.name, account.ID)
+		}
+		if account.OrganizationUUID != w.orgUUID {
+			t.Errorf("%s: organization UUID = %q, want %q", w.name, account.OrganizationUUID, w.orgUUID)
+		}
+		if account.AccessToken != w.accessToken || account.RefreshToken == "" {
+			t.Errorf("%s: tokens = %q / %q, want %q and a refresh token", w.name, account.AccessToken,
+				account.RefreshToken, w.accessToken)
+		}
+		if account.Status != w.status {
+			t.Errorf("%s: status = %s, want %s", w.name, account.Status, w.status)
+		}
+		if !account.ExpiresAt.Equal(w.expiresAt) {
+			t.Errorf("%s: expires at %v, want %v", w.name, account.ExpiresAt, w.expiresAt)
+		}
+		if !w.createdAt.IsZero() && !account.CreatedAt.Equal(w.createdAt) {
+			t.Errorf("%s: created at %v, want %v", w.name, account.CreatedAt, w.createdAt)
+		}
+		if account.AuthType != entities.AccountAuthOAuth || !account.AutoRefresh {
+			t.Errorf("%s: auth %s, auto refresh %v; want an auto-refreshed OAuth account", w.name, account.AuthType,
+				account.AutoRefresh)
+		}
+	}
+}
@@ -12,18 +12,22 @@ import (
 )
 
 // MemoryAccountRepository implements in-memory storage for accounts
+// Accounts are copied on the way in and out, so callers never mutate the stored objects outside the lock
 type MemoryAccountRepository struct {
 	accounts map[string]*entities.Account // accountID -> account
+	limit    softLimit
 	mu       sync.RWMutex
 	logger   sctx.Logger
 }
 
 // NewMemoryAccountRepository creates a new in-memory account repository
-func NewMemoryAccountRepository(appLogger sctx.Logger) interfaces.AccountRepository {
+// softLimit is the account count above which a warning is logged (0 = none)
+func NewMemoryAccountRepository(softLimit int, appLogger sctx.Logger) interfaces.CacheRepository {
 	logger := appLogger.Withs(sctx.Fields{"component": "memory-account-repository"})
 
 	return &MemoryAccountRepository{
 		accounts: make(map[string]*entities.Account),
+		limit:    softLimitFor("accounts", softLimit, logger),
 		logger:   logger,
 	}
 }
@@ -37,22 +41,29 @@ func (r *MemoryAccountRepository) Create(ctx context.Context, account *entities.
 		return fmt.Errorf("account already exists: %s", account.ID)
 	}
 
-	r.accounts[account.ID] = account
+	r.accounts[account.ID] = account.Clone()
+	r.limit.check(len(r.accounts))
 	r.logger.Withs(sctx.Fields{"account_id": account.ID}).Debug("Account created in memory")
 	return nil
 }
 
-// GetByID retrieves an account by ID
+// GetByID retrieves an account by ID, or by the legacy ID of a migrated account
 func (r *MemoryAccountRepository) GetByID(ctx context.Context, id string) (*entities.Account, error) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
 	account, exists := r.accounts[id]
 	if !exists {
+		// Accounts migrated to UUIDv7 are still found by their legacy ID
+		for _, candidate := range r.accounts {
+			if candidate.ExternalID != "" && candidate.ExternalID == id {
+				return candidate.Clone(), nil
+			}
+		}
 		return nil, fmt.Errorf("account not found: %s", id)
 	}
 
-	return account, nil
+	return account.Clone(), nil
 }
 
 // List retrieves all accounts
@@ -62,7 +73,7 @@ func (r *MemoryAccountRepository) List(ctx context.Context) ([]*entities.Account
 
 	accounts := make([]*entities.Account, 0, len(r.accounts))
 	for _, account := range r.accounts {
-		accounts = append(accounts, account)
+		accounts = append(accounts, account.Clone())
 	}
 
 	return accounts, nil
@@ -77,11 +88,48 @@ func (r *MemoryAccountRepository) Update(ctx context.Context, account *entities.
 		return fmt.Errorf("account not found: %s", account.ID)
 	}
 
-	r.accounts[account.ID] = account
+	r.accounts[account.ID] = account.Clone()
 	r.logger.Withs(sctx.Fields{"account_id": account.ID}).Debug("Account updated in memory")
 	return nil
 }
 
+// UpdateWith applies mutate to the account (found like GetByID) and stores the result, all under the write lock,
+// so concurrent changes to the same account are never lost; nothing is stored when mutate fails
+// mutate gets a copy and must not call back into the repository. A copy of the stored account is returned.
+func (r *MemoryAccountRepository) UpdateWith(
+	ctx context.Context,
+	id string,
+	mutate func(account *entities.Account) error,
+) (*entities.Account, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	account, exists := r.accounts[id]
+	if !exists {
+		for _, candidate := range r.accounts {
+			if candidate.ExternalID != "" && candidate.ExternalID == id {
+				account = candidate
+				break
+			}
+		}
+		if account == nil {
+			return nil, fmt.Errorf("account not found: %s", id)
+		}
+	}
+
+	updated := account.Clone()
+	if err := mutate(updated); err != nil {
+		return nil, err
+	}
+	if updated.ID != account.ID {
+		return nil, fmt.Errorf("account %s can't change its ID", account.ID)
+	}
+
+	r.accounts[account.ID] = updated
+	r.logger.Withs(sctx.Fields{"account_id": account.ID}).Debug("Account updated in memory")
+	return updated.Clone(), nil
+}
+
 // Delete removes an account by ID
 func (r *MemoryAccountRepository) Delete(ctx context.Context, id string) error {
 	r.mu.Lock()
@@ -92,6 +140,7 @@ func (r *MemoryAccountRepository) Delete(ctx context.Context, id string) error {
 	}
 
 	delete(r.accounts, id)
+	r.limit.check(len(r.accounts))
 	r.logger.Withs(sctx.Fields{"account_id": id}).Debug("Account deleted from memory")
 	return nil
 }
@@ -104,9 +153,21 @@ func (r *MemoryAccountRepository) GetActiveAccounts(ctx context.Context) ([]*ent
 	accounts := make([]*entities.Account, 0)
 	for _, account := range r.accounts {
 		if account.IsActive() {
-			accounts = append(accounts, account)
+			accounts = append(accounts, account.Clone())
 		}
 	}
 
 	return accounts, nil
 }
+
+// CacheStats returns the account count, approximate memory usage and soft limit
+func (r *MemoryAccountRepository) CacheStats() entities.CacheStats {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var bytes int64
+	for id, account := range r.accounts {
+		bytes += mapEntryOverhead + int64(len(id)) + approxSize(account)
+	}
+	return r.limit.stats(len(r.accounts), bytes)
+}
@@ -0,0 +1,129 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"claude-proxy/modules/auth/domain/entities"
+)
+
+// TestMemoryAccountRepositoryCopies changes the accounts it gets back while others read them: callers only
+// ever hold copies, which reach the cache through Update alone
+func TestMemoryAccountRepositoryCopies(t *testing.T) {
+	repo := NewMemoryAccountRepository(0, quietLogger(t))
+	ctx := context.Background()
+	for i := range 4 {
+		account := &entities.Account{
+			ID:           fmt.Sprintf("acc_%d", i),
+			Name:         fmt.Sprintf("account %d", i),
+			Status:       entities.AccountStatusActive,
+			AutoRefresh:  true,
+			AccessToken:  "sk-ant-oat01-initial",
+			RefreshToken: "sk-ant-ort01-initial",
+			ExpiresAt:    time.Now().Add(time.Hour),
+			Headers:      map[string]string{"User-Agent": "claude-cli/1.0.0"},
+		}
+		if err := repo.Create(ctx, account); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	hammer(8, 200, func(g, i int) {
+		id := fmt.Sprintf("acc_%d", (g+i)%4)
+		if g%2 == 0 {
+			account, err := repo.GetByID(ctx, id)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			account.UpdateTokens(fmt.Sprintf("sk-ant-oat01-%d-%d", g, i), fmt.Sprintf("sk-ant-ort01-%d-%d", g, i), 3600)
+			account.RecordRefreshToken(entities.RefreshTokenSourceRefresh)
+			account.Headers["User-Agent"] = fmt.Sprintf("claude-cli/1.0.%d", i)
+			if err := repo.Update(ctx, account); err != nil {
+				t.Error(err)
+			}
+			return
+		}
+		accounts, err := repo.GetActiveAccounts(ctx)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		for _, account := range accounts {
+			_ = account.AccessToken + account.Headers["User-Agent"]
+			for _, record := range account.RefreshLineage {
+				_ = record.Fingerprint
+			}
+		}
+	})
+
+	// A copy changed without Update leaves the cache as it was
+	account, err := repo.GetByID(ctx, "acc_0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored := account.Clone()
+	account.UpdateTokens("sk-ant-oat01-leaked", "sk-ant-ort01-leaked", 3600)
+	account.Headers["User-Agent"] = "leaked"
+	account.MarkConflicted("leaked")
+	again, err := repo.GetByID(ctx, "acc_0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.AccessToken != stored.AccessToken || again.Headers["User-Agent"] != stored.Headers["User-Agent"] ||
+		again.Status != entities.AccountStatusActive {
+		t.Errorf("a change to a returned account reached the cache: %s, %v, %s", again.AccessToken, again.Headers,
+			again.Status)
+	}
+}
+
+// TestMemoryAccountRepositoryUpdateWith records usage on an account from many goroutines: no increment is lost,
+// and a failing mutation leaves the account as it was
+func TestMemoryAccountRepositoryUpdateWith(t *testing.T) {
+	repo := NewMemoryAccountRepository(0, quietLogger(t))
+	ctx := context.Background()
+	account := &entities.Account{
+		ID:         "0190a5b2-0000-7000-8000-000000000001",
+		ExternalID: "acc_legacy",
+		Name:       "account",
+		Status:     entities.AccountStatusActive,
+	}
+	if err := repo.Create(ctx, account); err != nil {
+		t.Fatal(err)
+	}
+
+	hammer(8, 200, func(g, i int) {
+		id := account.ID
+		if g%2 == 0 {
+			id = account.ExternalID // Found by its legacy ID like GetByID
+		}
+		if _, err := repo.UpdateWith(ctx, id, func(account *entities.Account) error {
+			account.RecordUsage(entities.TokenUsage{InputTokens: 1})
+			return nil
+		}); err != nil {
+			t.Error(err)
+		}
+	})
+
+	failed := errors.New("rejected")
+	if _, err := repo.UpdateWith(ctx, account.ID, func(account *entities.Account) error {
+		account.RecordUsage(entities.TokenUsage{InputTokens: 1})
+		return failed
+	}); !errors.Is(err, failed) {
+		t.Errorf("UpdateWith() error = %v, want the mutation's", err)
+	}
+	if _, err := repo.UpdateWith(ctx, "acc_missing", func(*entities.Account) error { return nil }); err == nil {
+		t.Error("UpdateWith() of a missing account succeeded")
+	}
+
+	stored, err := repo.GetByID(ctx, account.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if requests, tokens := stored.CurrentWindowUsage(); requests != 8*200 || tokens != 8*200 {
+		t.Errorf("window usage = %d requests / %d tokens, want %d each", requests, tokens, 8*200)
+	}
+}
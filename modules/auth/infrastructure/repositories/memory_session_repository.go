@@ -4,7 +4,6 @@ import (
 	"context"
 	"fmt"
 	"sync"
-	"time"
 
 	"claude-proxy/modules/auth/domain/entities"
 	"claude-proxy/modules/auth/domain/interfaces"
@@ -13,21 +12,28 @@ import (
 )
 
 // MemorySessionRepository implements session repository with in-memory storage
+// Sessions are copied on the way in and out, so callers never mutate the stored objects outside the lock
+// Sessions are indexed by token and by client fingerprint, so per-request lookups never scan every session
 type MemorySessionRepository struct {
-	sessions map[string]*entities.Session // sessionID -> session
-	tokens   map[string][]string          // tokenID -> []sessionID
-	mu       sync.RWMutex
-	logger   sctx.Logger
+	sessions     map[string]*entities.Session // sessionID -> session
+	tokens       map[string][]string          // tokenID -> []sessionID
+	fingerprints map[string][]string          // entities.SessionFingerprint -> []sessionID
+	limit        softLimit
+	mu           sync.RWMutex
+	logger       sctx.Logger
 }
 
 // NewMemorySessionRepository creates a new in-memory session repository
-func NewMemorySessionRepository(appLogger sctx.Logger) interfaces.SessionCacheRepository {
+// softLimit is the session count above which a warning is logged (0 = none)
+func NewMemorySessionRepository(softLimit int, appLogger sctx.Logger) interfaces.SessionCacheRepository {
 	logger := appLogger.Withs(sctx.Fields{"component": "memory-session-repository"})
 
 	return &MemorySessionRepository{
-		sessions: make(map[string]*entities.Session),
-		tokens:   make(map[string][]string),
-		logger:   logger,
+		sessions:     make(map[string]*entities.Session),
+		tokens:       make(map[string][]string),
+		fingerprints: make(map[string][]string),
+		limit:        softLimitFor("sessions", softLimit, logger),
+		logger:       logger,
 	}
 }
 
@@ -37,13 +43,10 @@ func (r *MemorySessionRepository) CreateSession(ctx context.Context, session *en
 	defer r.mu.Unlock()
 
 	// Store session
-	r.sessions[session.ID] = session
-
-	// Add to token index
-	if _, exists := r.tokens[session.TokenID]; !exists {
-		r.tokens[session.TokenID] = []string{}
-	}
-	r.tokens[session.TokenID] = append(r.tokens[session.TokenID], session.ID)
+	stored := session.Clone()
+	r.sessions[session.ID] = stored
+	r.index(stored)
+	r.limit.check(len(r.sessions))
 
 	r.logger.Withs(sctx.Fields{
 		"session_id": session.ID,
@@ -63,7 +66,24 @@ func (r *MemorySessionRepository) GetSession(ctx context.Context, sessionID stri
 		return nil, fmt.Errorf("session not found: %s", sessionID)
 	}
 
-	return session, nil
+	return session.Clone(), nil
+}
+
+// FindLiveSession retrieves a live session of the client fingerprint through the fingerprint index
+func (r *MemorySessionRepository) FindLiveSession(
+	ctx context.Context,
+	tokenID, ipAddress, userAgent string,
+) (*entities.Session, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, sessionID := range r.fingerprints[entities.SessionFingerprint(tokenID, ipAddress, userAgent)] {
+		if session, exists := r.sessions[sessionID]; exists && session.IsLive() {
+			return session.Clone(), nil
+		}
+	}
+
+	return nil, nil
 }
 
 // UpdateSession updates an existing session
@@ -71,11 +91,17 @@ func (r *MemorySessionRepository) UpdateSession(ctx context.Context, session *en
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	if _, exists := r.sessions[session.ID]; !exists {
+	existing, exists := r.sessions[session.ID]
+	if !exists {
 		return fmt.Errorf("session not found: %s", session.ID)
 	}
 
-	r.sessions[session.ID] = session
+	stored := session.Clone()
+	r.sessions[session.ID] = stored
+	if existing.TokenID != stored.TokenID || existing.Fingerprint() != stored.Fingerprint() {
+		r.unindex(existing)
+		r.index(stored)
+	}
 
 	r.logger.Withs(sctx.Fields{"session_id": session.ID}).Debug("Session updated")
 	return nil
@@ -91,28 +117,58 @@ func (r *MemorySessionRepository) DeleteSession(ctx context.Context, sessionID s
 		return fmt.Errorf("session not found: %s", sessionID)
 	}
 
-	// Remove from sessions map
 	delete(r.sessions, sessionID)
+	r.unindex(session)
 
-	// Remove from token index
-	r.tokens[session.TokenID] = r.removeFromSlice(r.tokens[session.TokenID], sessionID)
-	if len(r.tokens[session.TokenID]) == 0 {
-		delete(r.tokens, session.TokenID)
-	}
-
+	r.limit.check(len(r.sessions))
 	r.logger.Withs(sctx.Fields{"session_id": sessionID}).Debug("Session deleted")
 	return nil
 }
 
+// DeleteSessions removes the sessions with the given IDs under a single lock (unknown IDs are skipped)
+func (r *MemorySessionRepository) DeleteSessions(ctx context.Context, sessionIDs []string) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	deleted := make([]string, 0, len(sessionIDs))
+	for _, sessionID := range sessionIDs {
+		session, exists := r.sessions[sessionID]
+		if !exists {
+			continue
+		}
+		delete(r.sessions, sessionID)
+		r.unindex(session)
+		deleted = append(deleted, sessionID)
+	}
+
+	r.limit.check(len(r.sessions))
+	r.logger.Withs(sctx.Fields{"count": len(deleted)}).Debug("Sessions deleted")
+	return deleted, nil
+}
+
 // CountActiveSessions counts total active (non-expired) sessions globally
 func (r *MemorySessionRepository) CountActiveSessions(ctx context.Context) (int, error) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
 	count := 0
-	now := time.Now()
 	for _, session := range r.sessions {
-		if session.IsActive && now.Before(session.ExpiresAt) {
+		if session.IsLive() {
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+// CountActiveSessionsByToken counts active (non-expired) sessions of a token using the token index
+func (r *MemorySessionRepository) CountActiveSessionsByToken(ctx context.Context, tokenID string) (int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	count := 0
+	for _, sessionID := range r.tokens[tokenID] {
+		if session, exists := r.sessions[sessionID]; exists && session.IsLive() {
 			count++
 		}
 	}
@@ -125,12 +181,11 @@ func (r *MemorySessionRepository) CleanupExpiredSessions(ctx context.Context) (i
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	now := time.Now()
 	expiredSessions := []string{}
 
-	// Find expired sessions
+	// Find expired sessions (idle timeout or maximum lifetime)
 	for sessionID, session := range r.sessions {
-		if now.After(session.ExpiresAt) {
+		if session.IsExpired() {
 			expiredSessions = append(expiredSessions, sessionID)
 		}
 	}
@@ -139,14 +194,10 @@ func (r *MemorySessionRepository) CleanupExpiredSessions(ctx context.Context) (i
 	for _, sessionID := range expiredSessions {
 		session := r.sessions[sessionID]
 		delete(r.sessions, sessionID)
-
-		// Remove from token index
-		r.tokens[session.TokenID] = r.removeFromSlice(r.tokens[session.TokenID], sessionID)
-		if len(r.tokens[session.TokenID]) == 0 {
-			delete(r.tokens, session.TokenID)
-		}
+		r.unindex(session)
 	}
 
+	r.limit.check(len(r.sessions))
 	if len(expiredSessions) > 0 {
 		r.logger.Withs(sctx.Fields{"count": len(expiredSessions)}).Debug("Expired sessions cleaned up")
 	}
@@ -161,12 +212,30 @@ func (r *MemorySessionRepository) ListAllSessions(ctx context.Context) ([]*entit
 
 	sessions := make([]*entities.Session, 0, len(r.sessions))
 	for _, session := range r.sessions {
-		sessions = append(sessions, session)
+		sessions = append(sessions, session.Clone())
 	}
 
 	return sessions, nil
 }
 
+// CountSessions counts all sessions by state
+func (r *MemorySessionRepository) CountSessions(ctx context.Context) (*entities.SessionCounts, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	counts := &entities.SessionCounts{Total: len(r.sessions)}
+	for _, session := range r.sessions {
+		switch {
+		case session.IsExpired():
+			counts.Expired++
+		case session.IsActive:
+			counts.Active++
+		}
+	}
+
+	return counts, nil
+}
+
 // ListSessionsByToken retrieves all sessions for a token
 func (r *MemorySessionRepository) ListSessionsByToken(
 	ctx context.Context,
@@ -183,19 +252,50 @@ func (r *MemorySessionRepository) ListSessionsByToken(
 	sessions := make([]*entities.Session, 0, len(sessionIDs))
 	for _, sessionID := range sessionIDs {
 		if session, exists := r.sessions[sessionID]; exists {
-			sessions = append(sessions, session)
+			sessions = append(sessions, session.Clone())
 		}
 	}
 
 	return sessions, nil
 }
 
-// Helper function to remove an element from a slice
-func (r *MemorySessionRepository) removeFromSlice(slice []string, value string) []string {
-	for i, v := range slice {
-		if v == value {
-			return append(slice[:i], slice[i+1:]...)
+// CacheStats returns the session count, approximate memory usage and soft limit
+func (r *MemorySessionRepository) CacheStats() entities.CacheStats {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	bytes := approxSize(r.tokens) + approxSize(r.fingerprints)
+	for id, session := range r.sessions {
+		bytes += mapEntryOverhead + int64(len(id)) + approxSize(session)
+	}
+	return r.limit.stats(len(r.sessions), bytes)
+}
+
+// index adds a stored session to the token and fingerprint indexes (requires lock)
+func (r *MemorySessionRepository) index(session *entities.Session) {
+	r.tokens[session.TokenID] = append(r.tokens[session.TokenID], session.ID)
+	fingerprint := session.Fingerprint()
+	r.fingerprints[fingerprint] = append(r.fingerprints[fingerprint], session.ID)
+}
+
+// unindex removes a stored session from the token and fingerprint indexes (requires lock)
+func (r *MemorySessionRepository) unindex(session *entities.Session) {
+	removeIndexEntry(r.tokens, session.TokenID, session.ID)
+	removeIndexEntry(r.fingerprints, session.Fingerprint(), session.ID)
+}
+
+// removeIndexEntry removes a session ID from the index entry of key, dropping the entry once empty
+func removeIndexEntry(index map[string][]string, key, sessionID string) {
+	ids := index[key]
+	for i, id := range ids {
+		if id == sessionID {
+			ids = append(ids[:i], ids[i+1:]...)
+			break
 		}
 	}
-	return slice
+	if len(ids) == 0 {
+		delete(index, key)
+		return
+	}
+	index[key] = ids
 }
@@ -0,0 +1,159 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"claude-proxy/modules/auth/domain/entities"
+	"claude-proxy/modules/auth/domain/interfaces"
+)
+
+const (
+	benchSessionCount  = 50_000
+	benchSessionTokens = 10 // Shared team tokens: each holds benchSessionCount/benchSessionTokens sessions
+)
+
+// TestMemorySessionRepositoryCopies changes the sessions it gets back while others read them: callers only
+// ever hold copies, which reach the cache through UpdateSession alone
+func TestMemorySessionRepositoryCopies(t *testing.T) {
+	repo := NewMemorySessionRepository(0, quietLogger(t))
+	ctx := context.Background()
+	now := time.Now()
+	for i := range 4 {
+		session := &entities.Session{
+			ID:         fmt.Sprintf("ses_%d", i),
+			TokenID:    "tok_shared",
+			UserAgent:  "claude-cli/1.0.0",
+			IPAddress:  benchSessionIP(i),
+			CreatedAt:  now,
+			LastSeenAt: now,
+			ExpiresAt:  now.Add(time.Hour),
+			IsActive:   true,
+		}
+		if err := repo.CreateSession(ctx, session); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	hammer(8, 200, func(g, i int) {
+		id := fmt.Sprintf("ses_%d", (g+i)%4)
+		switch g % 3 {
+		case 0:
+			session, err := repo.GetSession(ctx, id)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			session.Refresh(time.Hour)
+			session.RequestPath = fmt.Sprintf("/v1/messages?%d", i)
+			if err := repo.UpdateSession(ctx, session); err != nil {
+				t.Error(err)
+			}
+		case 1:
+			session, err := repo.FindLiveSession(ctx, "tok_shared", benchSessionIP((g+i)%4), "claude-cli/1.0.0")
+			if err != nil || session == nil {
+				t.Errorf("live session not found (%v)", err)
+				return
+			}
+			session.UpdateLastSeen()
+			session.IsActive = false
+		default:
+			sessions, err := repo.ListSessionsByToken(ctx, "tok_shared")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			for _, session := range sessions {
+				_ = session.IsLive() && session.RequestPath != ""
+			}
+			if _, err := repo.CountActiveSessions(ctx); err != nil {
+				t.Error(err)
+			}
+		}
+	})
+
+	// Sessions deactivated on copies only are still live in the cache
+	active, err := repo.CountActiveSessions(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if active != 4 {
+		t.Errorf("%d active sessions, want 4: a change to a returned session reached the cache", active)
+	}
+}
+
+// newBenchSessionRepository returns a repository holding n live sessions spread over tokens tokens, each from
+// a client IP of its own
+func newBenchSessionRepository(b *testing.B, n, tokens int) interfaces.SessionCacheRepository {
+	b.Helper()
+
+	repo := NewMemorySessionRepository(0, quietLogger(b))
+	ctx := context.Background()
+	now := time.Now()
+	for i := range n {
+		session := &entities.Session{
+			ID:         fmt.Sprintf("ses_%06d", i),
+			TokenID:    fmt.Sprintf("tok_%02d", i%tokens),
+			UserAgent:  "claude-cli/1.0.0",
+			IPAddress:  benchSessionIP(i),
+			CreatedAt:  now,
+			LastSeenAt: now,
+			ExpiresAt:  now.Add(time.Hour),
+			IsActive:   true,
+		}
+		if err := repo.CreateSession(ctx, session); err != nil {
+			b.Fatal(err)
+		}
+	}
+	return repo
+}
+
+// benchSessionIP is the client IP of the i-th benchmark session
+func benchSessionIP(i int) string {
+	return fmt.Sprintf("10.%d.%d.%d", i>>16&0xff, i>>8&0xff, i&0xff)
+}
+
+// BenchmarkMemorySessionRepositoryFindSession compares finding a client's live session through the
+// fingerprint index to the scan of the token's sessions it replaced
+func BenchmarkMemorySessionRepositoryFindSession(b *testing.B) {
+	repo := newBenchSessionRepository(b, benchSessionCount, benchSessionTokens)
+	ctx := context.Background()
+
+	b.Run("FingerprintIndex", func(b *testing.B) {
+		i := 0
+		for b.Loop() {
+			tokenID := fmt.Sprintf("tok_%02d", i%benchSessionTokens)
+			session, err := repo.FindLiveSession(ctx, tokenID, benchSessionIP(i), "claude-cli/1.0.0")
+			if err != nil || session == nil {
+				b.Fatalf("session %d not found (err = %v)", i, err)
+			}
+			i = (i + 7919) % benchSessionCount
+		}
+	})
+
+	b.Run("TokenScan", func(b *testing.B) {
+		i := 0
+		for b.Loop() {
+			tokenID := fmt.Sprintf("tok_%02d", i%benchSessionTokens)
+			sessions, err := repo.ListSessionsByToken(ctx, tokenID)
+			if err != nil {
+				b.Fatal(err)
+			}
+			var found *entities.Session
+			for _, session := range sessions {
+				if session.IPAddress == benchSessionIP(i) &&
+					strings.EqualFold(session.UserAgent, "claude-cli/1.0.0") && session.IsLive() {
+					found = session
+					break
+				}
+			}
+			if found == nil {
+				b.Fatalf("session %d not found", i)
+			}
+			i = (i + 7919) % benchSessionCount
+		}
+	})
+}
@@ -0,0 +1,98 @@
+package repositories
+
+import (
+	"reflect"
+
+	"claude-proxy/modules/auth/domain/entities"
+
+	sctx "github.com/phathdt/service-context"
+)
+
+// mapEntryOverhead approximates the bookkeeping of one map entry (bucket slot, hash, pointer)
+const mapEntryOverhead = 48
+
+// approxSize estimates the memory held by v: its own size plus what its strings, slices, maps and pointers
+// reference. Shared data is counted once per reference, so this is an upper bound, not a measurement.
+func approxSize(v any) int64 {
+	value := reflect.ValueOf(v)
+	if !value.IsValid() {
+		return 0
+	}
+	return int64(value.Type().Size()) + referencedSize(value)
+}
+
+// referencedSize estimates the memory v references beyond its own size
+func referencedSize(v reflect.Value) int64 {
+	switch v.Kind() {
+	case reflect.String:
+		return int64(v.Len())
+	case reflect.Pointer:
+		if v.IsNil() {
+			return 0
+		}
+		return int64(v.Elem().Type().Size()) + referencedSize(v.Elem())
+	case reflect.Struct:
+		var size int64
+		for i := range v.NumField() {
+			size += referencedSize(v.Field(i))
+		}
+		return size
+	case reflect.Slice:
+		size := int64(v.Cap()) * int64(v.Type().Elem().Size())
+		for i := range v.Len() {
+			size += referencedSize(v.Index(i))
+		}
+		return size
+	case reflect.Map:
+		size := int64(v.Len()) * (mapEntryOverhead + int64(v.Type().Key().Size()+v.Type().Elem().Size()))
+		iter := v.MapRange()
+		for iter.Next() {
+			size += referencedSize(iter.Key()) + referencedSize(iter.Value())
+		}
+		return size
+	default:
+		return 0
+	}
+}
+
+// softLimit logs a warning when a cache grows past its configured number of entries, and once it is back under
+// It never evicts: reaching the limit points at a bug or abuse (e.g. mass token creation) to investigate.
+type softLimit struct {
+	cache    string
+	limit    int // 0 = none
+	exceeded bool
+	logger   sctx.Logger
+}
+
+// check compares a cache's entry count with the limit (requires the cache's write lock)
+func (l *softLimit) check(count int) {
+	if l.limit <= 0 {
+		return
+	}
+	switch {
+	case count > l.limit && !l.exceeded:
+		l.exceeded = true
+		l.logger.Withs(sctx.Fields{
+			"cache":      l.cache,
+			"entries":    count,
+			"soft_limit": l.limit,
+		}).Warn("In-memory cache exceeded its soft limit")
+	case count <= l.limit && l.exceeded:
+		l.exceeded = false
+		l.logger.Withs(sctx.Fields{
+			"cache":      l.cache,
+			"entries":    count,
+			"soft_limit": l.limit,
+		}).Info("In-memory cache is back under its soft limit")
+	}
+}
+
+// stats builds the cache stats of a cache holding entries entries of size bytes in total
+func (l *softLimit) stats(entries int, bytes int64) entities.CacheStats {
+	return entities.CacheStats{Entries: entries, ApproxBytes: bytes, SoftLimit: l.limit}
+}
+
+// softLimitFor creates the soft limit of a cache (limit 0 = none)
+func softLimitFor(cache string, limit int, logger sctx.Logger) softLimit {
+	return softLimit{cache: cache, limit: limit, logger: logger}
+}
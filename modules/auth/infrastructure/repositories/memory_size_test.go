@@ -0,0 +1,78 @@
+package repositories
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"claude-proxy/modules/auth/domain/entities"
+)
+
+func TestApproxSize(t *testing.T) {
+	type pair struct {
+		Name string
+		Tags []string
+	}
+
+	tests := []struct {
+		name string
+		v    any
+		want int64
+	}{
+		{"nil", nil, 0},
+		{"int", 42, 8},
+		{"string", "abcd", 16 + 4},
+		{"struct", pair{Name: "ab", Tags: []string{"c", "de"}}, 16 + 24 + 2 + 2*16 + 1 + 2},
+		{"pointer", &pair{Name: "ab"}, 8 + 40 + 2},
+		{"nil pointer", (*pair)(nil), 8},
+		{"slice capacity", make([]int64, 1, 4), 24 + 4*8},
+		{"map", map[string]int64{"a": 1, "bc": 2}, 8 + 2*(mapEntryOverhead+16+8) + 1 + 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := approxSize(tt.v); got != tt.want {
+				t.Errorf("approxSize() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSoftLimitStats(t *testing.T) {
+	limit := softLimitFor("tokens", 2, quietLogger(t))
+	limit.check(3)
+	if !limit.exceeded {
+		t.Error("a cache over its soft limit is not flagged")
+	}
+	limit.check(2)
+	if limit.exceeded {
+		t.Error("a cache back at its soft limit is still flagged")
+	}
+
+	stats := limit.stats(2, 100)
+	if stats != (entities.CacheStats{Entries: 2, ApproxBytes: 100, SoftLimit: 2}) {
+		t.Errorf("stats() = %+v", stats)
+	}
+}
+
+// BenchmarkApproxSizeTokens measures the size estimate of 100k tokens, the bulk of the token cache's CacheStats
+func BenchmarkApproxSizeTokens(b *testing.B) {
+	now := time.Now()
+	tokens := make(map[string]*entities.Token, benchTokenCount)
+	for i := range benchTokenCount {
+		token := &entities.Token{
+			ID:         fmt.Sprintf("tok_%06d", i),
+			Name:       fmt.Sprintf("token %d", i),
+			Status:     entities.TokenStatusActive,
+			Role:       entities.TokenRoleUser,
+			LastUsedAt: &now,
+			CreatedAt:  now,
+			UpdatedAt:  now,
+		}
+		token.SetKey(fmt.Sprintf("sk-proxy-bench-%06d", i))
+		tokens[token.ID] = token
+	}
+
+	for b.Loop() {
+		approxSize(tokens)
+	}
+}
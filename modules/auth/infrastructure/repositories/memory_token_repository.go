@@ -3,8 +3,11 @@ package repositories
 import (
 	"context"
 	"fmt"
+	"slices"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"claude-proxy/modules/auth/domain/entities"
 	"claude-proxy/modules/auth/domain/interfaces"
@@ -13,19 +16,41 @@ import (
 )
 
 // MemoryTokenRepository implements in-memory storage for tokens
+// Tokens are copied on the way in and out, so callers never mutate the stored objects outside the lock;
+// cleartext keys are dropped on the way in, lookups go through an index of key hashes
+// Sorted ID lists are kept for every role/status combination, so filtered pages are sliced out of an index
+// instead of scanning and sorting every token on each request
+// Usage counts live in atomic counters beside the tokens, so recording a request's usage only takes the read lock
 type MemoryTokenRepository struct {
-	tokens map[string]*entities.Token // tokenID -> token
-	mu     sync.RWMutex
-	logger sctx.Logger
+	tokens  map[string]*entities.Token // tokenID -> token
+	usage   map[string]*tokenUsage     // tokenID -> usage counters
+	byHash  map[string]string          // key hash -> tokenID
+	byName  map[string]string          // lowercased name -> tokenID
+	indexes map[string][]string        // tokenIndexKey -> token IDs, sorted
+	limit   softLimit
+	mu      sync.RWMutex
+	logger  sctx.Logger
+}
+
+// tokenUsage counts a stored token's requests; it overrides the token's UsageCount and LastUsedAt
+type tokenUsage struct {
+	count    atomic.Int64
+	lastUsed atomic.Int64 // Unix nanoseconds, 0 = never used
 }
 
 // NewMemoryTokenRepository creates a new in-memory token repository
-func NewMemoryTokenRepository(appLogger sctx.Logger) interfaces.TokenCacheRepository {
+// softLimit is the token count above which a warning is logged (0 = none)
+func NewMemoryTokenRepository(softLimit int, appLogger sctx.Logger) interfaces.TokenCacheRepository {
 	logger := appLogger.Withs(sctx.Fields{"component": "memory-token-repository"})
 
 	return &MemoryTokenRepository{
-		tokens: make(map[string]*entities.Token),
-		logger: logger,
+		tokens:  make(map[string]*entities.Token),
+		usage:   make(map[string]*tokenUsage),
+		byHash:  make(map[string]string),
+		byName:  make(map[string]string),
+		indexes: make(map[string][]string),
+		limit:   softLimitFor("tokens", softLimit, logger),
+		logger:  logger,
 	}
 }
 
@@ -39,16 +64,16 @@ func (r *MemoryTokenRepository) Create(ctx context.Context, token *entities.Toke
 		return fmt.Errorf("token with ID already exists: %s", token.ID)
 	}
 
-	for _, t := range r.tokens {
-		if t.Key == token.Key {
-			return fmt.Errorf("token with key already exists")
-		}
-		if strings.EqualFold(t.Name, token.Name) {
-			return fmt.Errorf("token with name already exists")
-		}
+	if _, exists := r.byHash[token.KeyHash]; exists {
+		return fmt.Errorf("token with key already exists")
 	}
 
-	r.tokens[token.ID] = token
+	if _, exists := r.byName[strings.ToLower(token.Name)]; exists {
+		return fmt.Errorf("token with name already exists")
+	}
+
+	r.store(token)
+	r.limit.check(len(r.tokens))
 	r.logger.Withs(sctx.Fields{"token_id": token.ID, "token_name": token.Name}).Debug("Token created in memory")
 	return nil
 }
@@ -63,21 +88,35 @@ func (r *MemoryTokenRepository) GetByID(ctx context.Context, id string) (*entiti
 		return nil, fmt.Errorf("token not found: %s", id)
 	}
 
-	return token, nil
+	return r.clone(token), nil
 }
 
-// GetByKey retrieves a token by its key
+// GetByKey retrieves a token by its key, looked up by the key's hash
 func (r *MemoryTokenRepository) GetByKey(ctx context.Context, key string) (*entities.Token, error) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
-	for _, token := range r.tokens {
-		if token.Key == key {
-			return token, nil
-		}
+	id, exists := r.byHash[entities.HashTokenKey(key)]
+	if !exists {
+		return nil, fmt.Errorf("token not found")
+	}
+
+	return r.clone(r.tokens[id]), nil
+}
+
+// RecordUsage counts a request of the token at the given time, under the read lock only
+func (r *MemoryTokenRepository) RecordUsage(ctx context.Context, id string, at time.Time) error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	usage, exists := r.usage[id]
+	if !exists {
+		return fmt.Errorf("token not found: %s", id)
 	}
 
-	return nil, fmt.Errorf("token not found")
+	usage.count.Add(1)
+	usage.setLastUsed(at.UnixNano())
+	return nil
 }
 
 // List retrieves all tokens
@@ -87,34 +126,62 @@ func (r *MemoryTokenRepository) List(ctx context.Context) ([]*entities.Token, er
 
 	tokens := make([]*entities.Token, 0, len(r.tokens))
 	for _, token := range r.tokens {
-		tokens = append(tokens, token)
+		tokens = append(tokens, r.clone(token))
 	}
 
 	return tokens, nil
 }
 
+// Query returns the page of the tokens matching filter in ID order, and how many match
+// Without a search only the page is copied; a search scans the role/status index it narrows down
+func (r *MemoryTokenRepository) Query(
+	ctx context.Context,
+	filter entities.TokenFilter,
+	offset, limit int,
+) ([]*entities.Token, int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	ids := r.indexes[tokenIndexKey(filter.Role, filter.Status)]
+	if filter.Search != "" {
+		matching := make([]string, 0)
+		for _, id := range ids {
+			if r.tokens[id].MatchesSearch(filter.Search) {
+				matching = append(matching, id)
+			}
+		}
+		ids = matching
+	}
+
+	start := min(max(offset, 0), len(ids))
+	end := min(start+max(limit, 0), len(ids))
+	tokens := make([]*entities.Token, 0, end-start)
+	for _, id := range ids[start:end] {
+		tokens = append(tokens, r.clone(r.tokens[id]))
+	}
+	return tokens, len(ids), nil
+}
+
 // Update updates an existing token
 func (r *MemoryTokenRepository) Update(ctx context.Context, token *entities.Token) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	if _, exists := r.tokens[token.ID]; !exists {
+	existing, exists := r.tokens[token.ID]
+	if !exists {
 		return fmt.Errorf("token not found: %s", token.ID)
 	}
 
 	// Check if key or name changed and conflicts with another token
-	for id, t := range r.tokens {
-		if id != token.ID {
-			if t.Key == token.Key {
-				return fmt.Errorf("token with key already exists")
-			}
-			if strings.EqualFold(t.Name, token.Name) {
-				return fmt.Errorf("token with name already exists")
-			}
-		}
+	if id, exists := r.byHash[token.KeyHash]; exists && id != token.ID {
+		return fmt.Errorf("token with key already exists")
+	}
+	if id, exists := r.byName[strings.ToLower(token.Name)]; exists && id != token.ID {
+		return fmt.Errorf("token with name already exists")
 	}
 
-	r.tokens[token.ID] = token
+	r.unindex(existing)
+	r.store(token)
 	r.logger.Withs(sctx.Fields{"token_id": token.ID}).Debug("Token updated in memory")
 	return nil
 }
@@ -124,11 +191,106 @@ func (r *MemoryTokenRepository) Delete(ctx context.Context, id string) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	if _, exists := r.tokens[id]; !exists {
+	token, exists := r.tokens[id]
+	if !exists {
 		return fmt.Errorf("token not found: %s", id)
 	}
 
+	r.unindex(token)
 	delete(r.tokens, id)
+	delete(r.usage, id)
+	r.limit.check(len(r.tokens))
 	r.logger.Withs(sctx.Fields{"token_id": id}).Debug("Token deleted from memory")
 	return nil
 }
+
+// CacheStats returns the token count, approximate memory usage and soft limit
+func (r *MemoryTokenRepository) CacheStats() entities.CacheStats {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	bytes := approxSize(r.byHash) + approxSize(r.byName) + approxSize(r.indexes) + approxSize(r.usage)
+	for id, token := range r.tokens {
+		bytes += mapEntryOverhead + int64(len(id)) + approxSize(token)
+	}
+	return r.limit.stats(len(r.tokens), bytes)
+}
+
+// clone returns a copy of a stored token with its current usage counts (requires lock)
+func (r *MemoryTokenRepository) clone(token *entities.Token) *entities.Token {
+	copied := token.Clone()
+	if usage, exists := r.usage[token.ID]; exists {
+		copied.UsageCount = int(usage.count.Load())
+		copied.LastUsedAt = nil
+		if nanos := usage.lastUsed.Load(); nanos != 0 {
+			lastUsed := time.Unix(0, nanos)
+			copied.LastUsedAt = &lastUsed
+		}
+	}
+	return copied
+}
+
+// store saves a copy of the token without its cleartext key and indexes it (requires lock)
+// The usage counters only move forward: a token read before requests were counted doesn't undo them on update
+func (r *MemoryTokenRepository) store(token *entities.Token) {
+	stored := token.Clone()
+	stored.Key = ""
+	r.tokens[token.ID] = stored
+
+	usage, exists := r.usage[token.ID]
+	if !exists {
+		usage = &tokenUsage{}
+		r.usage[token.ID] = usage
+	}
+	usage.count.Store(max(usage.count.Load(), int64(token.UsageCount)))
+	if token.LastUsedAt != nil {
+		usage.setLastUsed(token.LastUsedAt.UnixNano())
+	}
+
+	r.byHash[token.KeyHash] = token.ID
+	r.byName[strings.ToLower(token.Name)] = token.ID
+	for _, key := range tokenIndexKeys(stored) {
+		ids := r.indexes[key]
+		if i, found := slices.BinarySearch(ids, token.ID); !found {
+			r.indexes[key] = slices.Insert(ids, i, token.ID)
+		}
+	}
+}
+
+// unindex removes a stored token from the key, name and role/status indexes (requires lock)
+func (r *MemoryTokenRepository) unindex(token *entities.Token) {
+	delete(r.byHash, token.KeyHash)
+	delete(r.byName, strings.ToLower(token.Name))
+	for _, key := range tokenIndexKeys(token) {
+		ids := r.indexes[key]
+		if i, found := slices.BinarySearch(ids, token.ID); found {
+			r.indexes[key] = slices.Delete(ids, i, i+1)
+		}
+	}
+}
+
+// setLastUsed moves the last use forward to nanos (Unix nanoseconds), never back
+func (u *tokenUsage) setLastUsed(nanos int64) {
+	for {
+		current := u.lastUsed.Load()
+		if nanos <= current || u.lastUsed.CompareAndSwap(current, nanos) {
+			return
+		}
+	}
+}
+
+// tokenIndexKeys returns the role/status index keys a token is listed under: all tokens, its role, its status,
+// and both
+func tokenIndexKeys(token *entities.Token) []string {
+	return []string{
+		tokenIndexKey("", ""),
+		tokenIndexKey(token.Role, ""),
+		tokenIndexKey("", token.Status),
+		tokenIndexKey(token.Role, token.Status),
+	}
+}
+
+// tokenIndexKey names the index of the tokens with a role and status (empty matches any)
+func tokenIndexKey(role entities.TokenRole, status entities.TokenStatus) string {
+	return string(role) + "/" + string(status)
+}
@@ -0,0 +1,250 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"claude-proxy/modules/auth/domain/entities"
+	"claude-proxy/modules/auth/domain/interfaces"
+	"claude-proxy/pkg/logging"
+
+	sctx "github.com/phathdt/service-context"
+)
+
+// benchTokenCount is the token count of the benchmarks, the size of a large deployment
+const benchTokenCount = 100_000
+
+// quietLogger returns a logger dropping everything below errors, so benchmarks don't measure debug logging
+func quietLogger(tb testing.TB) sctx.Logger {
+	tb.Helper()
+
+	registry, err := logging.NewRegistry("error")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return registry.Wrap(sctx.GlobalLogger().GetLogger("test"))
+}
+
+// hammer runs fn from goroutines goroutines, iterations times each, and waits for them; run with -race, so
+// entities shared between the cache and its callers are reported
+func hammer(goroutines, iterations int, fn func(goroutine, iteration int)) {
+	var wg sync.WaitGroup
+	for g := range goroutines {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range iterations {
+				fn(g, i)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// newBenchTokenRepository returns a repository holding n tokens (every tenth an admin), with their keys
+func newBenchTokenRepository(b *testing.B, n int) (interfaces.TokenCacheRepository, []string) {
+	b.Helper()
+
+	repo := NewMemoryTokenRepository(0, quietLogger(b))
+	ctx := context.Background()
+	now := time.Now()
+	keys := make([]string, n)
+	for i := range n {
+		token := &entities.Token{
+			ID:        fmt.Sprintf("tok_%06d", i),
+			Name:      fmt.Sprintf("token %d", i),
+			Status:    entities.TokenStatusActive,
+			Role:      entities.TokenRoleUser,
+			CreatedAt: now,
+			UpdatedAt: now,
+		}
+		if i%10 == 0 {
+			token.Role = entities.TokenRoleAdmin
+		}
+		keys[i] = fmt.Sprintf("sk-proxy-bench-%06d", i)
+		token.SetKey(keys[i])
+		if err := repo.Create(ctx, token); err != nil {
+			b.Fatal(err)
+		}
+	}
+	return repo, keys
+}
+
+// TestMemoryTokenRepositoryCopies changes the tokens it gets back while others read them: callers only ever
+// hold copies, which reach the cache through Update alone
+func TestMemoryTokenRepositoryCopies(t *testing.T) {
+	repo := NewMemoryTokenRepository(0, quietLogger(t))
+	ctx := context.Background()
+	lastUsed := time.Now().Add(-time.Hour)
+	keys := make([]string, 4)
+	for i := range keys {
+		token := &entities.Token{
+			ID:           fmt.Sprintf("tok_%d", i),
+			Name:         fmt.Sprintf("token %d", i),
+			Status:       entities.TokenStatusActive,
+			Role:         entities.TokenRoleUser,
+			AllowedPaths: []string{"/v1/messages"},
+			LastUsedAt:   &lastUsed,
+		}
+		keys[i] = fmt.Sprintf("sk-proxy-race-%d", i)
+		token.SetKey(keys[i])
+		if err := repo.Create(ctx, token); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	hammer(8, 200, func(g, i int) {
+		key := keys[(g+i)%len(keys)]
+		if g%2 == 0 {
+			token, err := repo.GetByKey(ctx, key)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			token.AllowedPaths[0] = fmt.Sprintf("/v1/messages/%d", i)
+			*token.LastUsedAt = time.Now()
+			token.UsageCount++
+			if err := repo.Update(ctx, token); err != nil {
+				t.Error(err)
+			}
+			return
+		}
+		tokens, err := repo.List(ctx)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		for _, token := range tokens {
+			_ = token.AllowedPaths[0] + token.LastUsedAt.String()
+		}
+		if err := repo.RecordUsage(ctx, tokens[0].ID, time.Now()); err != nil {
+			t.Error(err)
+		}
+	})
+
+	// A copy changed without Update leaves the cache as it was
+	token, err := repo.GetByID(ctx, "tok_0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	token.AllowedPaths[0] = "/leaked"
+	token.Status = entities.TokenStatusRevoked
+	stored, err := repo.GetByID(ctx, "tok_0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.AllowedPaths[0] == "/leaked" || stored.Status != entities.TokenStatusActive {
+		t.Errorf("a change to a returned token reached the cache: %v, %s", stored.AllowedPaths, stored.Status)
+	}
+}
+
+func BenchmarkMemoryTokenRepositoryGetByKey(b *testing.B) {
+	repo, keys := newBenchTokenRepository(b, benchTokenCount)
+	ctx := context.Background()
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			if _, err := repo.GetByKey(ctx, keys[i%len(keys)]); err != nil {
+				b.Fatal(err)
+			}
+			i += 7919 // Spread lookups over the whole key space
+		}
+	})
+}
+
+// BenchmarkMemoryTokenRepositoryRecordUsage compares counting a request with RecordUsage (read lock, atomic
+// counters) to the copy-and-Update it replaced (write lock), as concurrent requests validating tokens do
+func BenchmarkMemoryTokenRepositoryRecordUsage(b *testing.B) {
+	repo, keys := newBenchTokenRepository(b, benchTokenCount)
+	ctx := context.Background()
+
+	b.Run("RecordUsage", func(b *testing.B) {
+		b.RunParallel(func(pb *testing.PB) {
+			i := 0
+			for pb.Next() {
+				token, err := repo.GetByKey(ctx, keys[i%len(keys)])
+				if err != nil {
+					b.Fatal(err)
+				}
+				if err := repo.RecordUsage(ctx, token.ID, time.Now()); err != nil {
+					b.Fatal(err)
+				}
+				i += 7919
+			}
+		})
+	})
+
+	b.Run("Update", func(b *testing.B) {
+		b.RunParallel(func(pb *testing.PB) {
+			i := 0
+			for pb.Next() {
+				token, err := repo.GetByKey(ctx, keys[i%len(keys)])
+				if err != nil {
+					b.Fatal(err)
+				}
+				token.IncrementUsage()
+				if err := repo.Update(ctx, token); err != nil {
+					b.Fatal(err)
+				}
+				i += 7919
+			}
+		})
+	})
+}
+
+// BenchmarkMemoryTokenRepositoryQuery measures dashboard token pages, whose cost must not grow with the
+// token count except for searches
+func BenchmarkMemoryTokenRepositoryQuery(b *testing.B) {
+	repo, _ := newBenchTokenRepository(b, benchTokenCount)
+	ctx := context.Background()
+
+	activeUsers := entities.TokenFilter{Role: entities.TokenRoleUser, Status: entities.TokenStatusActive}
+	tests := []struct {
+		name   string
+		filter entities.TokenFilter
+		offset int
+	}{
+		{"FirstPage", entities.TokenFilter{}, 0},
+		{"LastPage", entities.TokenFilter{}, benchTokenCount - 20},
+		{"RoleFilter", entities.TokenFilter{Role: entities.TokenRoleAdmin}, 0},
+		{"RoleAndStatusFilter", activeUsers, 0},
+		{"Search", entities.TokenFilter{Search: "token 4242"}, 0},
+	}
+	for _, tt := range tests {
+		b.Run(tt.name, func(b *testing.B) {
+			for b.Loop() {
+				if _, _, err := repo.Query(ctx, tt.filter, tt.offset, 20); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkMemoryTokenRepositoryList measures copying every token, what token listing cost before Query
+func BenchmarkMemoryTokenRepositoryList(b *testing.B) {
+	repo, _ := newBenchTokenRepository(b, benchTokenCount)
+	ctx := context.Background()
+
+	for b.Loop() {
+		if _, err := repo.List(ctx); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkMemoryTokenRepositoryCacheStats measures the size estimate the cache metrics endpoint computes
+func BenchmarkMemoryTokenRepositoryCacheStats(b *testing.B) {
+	repo, _ := newBenchTokenRepository(b, benchTokenCount)
+
+	var stats entities.CacheStats
+	for b.Loop() {
+		stats = repo.CacheStats()
+	}
+	b.ReportMetric(float64(stats.ApproxBytes)/float64(stats.Entries), "bytes/token")
+}
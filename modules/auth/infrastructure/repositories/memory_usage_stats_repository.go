@@ -0,0 +1,155 @@
+package repositories
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"claude-proxy/modules/auth/domain/entities"
+	"claude-proxy/modules/auth/domain/interfaces"
+
+	sctx "github.com/phathdt/service-context"
+)
+
+// MemoryUsageStatsRepository implements in-memory storage for usage buckets
+type MemoryUsageStatsRepository struct {
+	buckets map[string]map[int64]*entities.UsageBucket // tokenID -> bucket start (unix) -> bucket
+	count   int                                        // Buckets across all tokens
+	limit   softLimit
+	mu      sync.RWMutex
+	logger  sctx.Logger
+}
+
+// NewMemoryUsageStatsRepository creates a new in-memory usage stats repository
+// softLimit is the bucket count above which a warning is logged (0 = none)
+func NewMemoryUsageStatsRepository(softLimit int, appLogger sctx.Logger) interfaces.UsageStatsCacheRepository {
+	logger := appLogger.Withs(sctx.Fields{"component": "memory-usage-stats-repository"})
+
+	return &MemoryUsageStatsRepository{
+		buckets: make(map[string]map[int64]*entities.UsageBucket),
+		limit:   softLimitFor("usage_buckets", softLimit, logger),
+		logger:  logger,
+	}
+}
+
+// AddSample folds a sample into the bucket starting at bucketStart, creating it if needed
+func (r *MemoryUsageStatsRepository) AddSample(
+	ctx context.Context,
+	bucketStart time.Time,
+	sample *entities.RequestSample,
+) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tokenBuckets, ok := r.buckets[sample.TokenID]
+	if !ok {
+		tokenBuckets = make(map[int64]*entities.UsageBucket)
+		r.buckets[sample.TokenID] = tokenBuckets
+	}
+
+	bucket, ok := tokenBuckets[bucketStart.Unix()]
+	if !ok {
+		bucket = &entities.UsageBucket{TokenID: sample.TokenID, Start: bucketStart}
+		tokenBuckets[bucketStart.Unix()] = bucket
+		r.count++
+		r.limit.check(r.count)
+	}
+
+	bucket.Add(sample)
+	return nil
+}
+
+// List returns copies of all buckets starting at or after since
+func (r *MemoryUsageStatsRepository) List(ctx context.Context, since time.Time) ([]*entities.UsageBucket, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	result := make([]*entities.UsageBucket, 0)
+	for _, tokenBuckets := range r.buckets {
+		result = appendBucketsSince(result, tokenBuckets, since)
+	}
+	return result, nil
+}
+
+// ListByToken returns copies of a token's buckets starting at or after since
+func (r *MemoryUsageStatsRepository) ListByToken(
+	ctx context.Context,
+	tokenID string,
+	since time.Time,
+) ([]*entities.UsageBucket, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return appendBucketsSince(make([]*entities.UsageBucket, 0), r.buckets[tokenID], since), nil
+}
+
+// DeleteBefore removes buckets starting before cutoff and returns how many were removed
+func (r *MemoryUsageStatsRepository) DeleteBefore(ctx context.Context, cutoff time.Time) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	removed := 0
+	for tokenID, tokenBuckets := range r.buckets {
+		for start, bucket := range tokenBuckets {
+			if bucket.Start.Before(cutoff) {
+				delete(tokenBuckets, start)
+				removed++
+			}
+		}
+		if len(tokenBuckets) == 0 {
+			delete(r.buckets, tokenID)
+		}
+	}
+
+	r.count -= removed
+	r.limit.check(r.count)
+	if removed > 0 {
+		r.logger.Withs(sctx.Fields{"removed": removed}).Debug("Expired usage buckets removed from memory")
+	}
+	return removed, nil
+}
+
+// ReplaceAll replaces the cache contents (used when loading from persistence)
+func (r *MemoryUsageStatsRepository) ReplaceAll(ctx context.Context, buckets []*entities.UsageBucket) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.buckets = make(map[string]map[int64]*entities.UsageBucket)
+	for _, bucket := range buckets {
+		tokenBuckets, ok := r.buckets[bucket.TokenID]
+		if !ok {
+			tokenBuckets = make(map[int64]*entities.UsageBucket)
+			r.buckets[bucket.TokenID] = tokenBuckets
+		}
+		tokenBuckets[bucket.Start.Unix()] = bucket.Clone()
+	}
+	r.count = 0
+	for _, tokenBuckets := range r.buckets {
+		r.count += len(tokenBuckets)
+	}
+	r.limit.check(r.count)
+	return nil
+}
+
+// CacheStats returns the bucket count, approximate memory usage and soft limit
+func (r *MemoryUsageStatsRepository) CacheStats() entities.CacheStats {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.limit.stats(r.count, approxSize(r.buckets))
+}
+
+// appendBucketsSince appends copies of buckets starting at or after since
+func appendBucketsSince(
+	result []*entities.UsageBucket,
+	tokenBuckets map[int64]*entities.UsageBucket,
+	since time.Time,
+) []*entities.UsageBucket {
+	for _, bucket := range tokenBuckets {
+		if bucket.Start.Before(since) {
+			continue
+		}
+		result = append(result, bucket.Clone())
+	}
+	return result
+}
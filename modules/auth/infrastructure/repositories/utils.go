@@ -6,8 +6,8 @@ import (
 	"strings"
 )
 
-// expandPath expands ~ to home directory
-func expandPath(path string) string {
+// ExpandPath expands ~ to home directory
+func ExpandPath(path string) string {
 	if strings.HasPrefix(path, "~") {
 		home, err := os.UserHomeDir()
 		if err != nil {
@@ -0,0 +1,54 @@
+package dto
+
+import (
+	"time"
+
+	"claude-proxy/modules/proxy/domain/entities"
+)
+
+// ============================================================================
+// API Response DTOs (for HTTP responses)
+// ============================================================================
+
+// AlertResponse represents an alert rule and its current state
+type AlertResponse struct {
+	Name        string  `json:"name"`
+	Metric      string  `json:"metric"`
+	Operator    string  `json:"operator"`
+	Threshold   float64 `json:"threshold"`
+	Window      string  `json:"window,omitempty"` // Go duration, omitted for metrics read as is
+	Severity    string  `json:"severity"`
+	MinRequests int64   `json:"min_requests,omitempty"`
+	State       string  `json:"state"`                  // ok or firing
+	Value       float64 `json:"value"`                  // At the last evaluation
+	Since       *string `json:"since,omitempty"`        // RFC3339/ISO 8601 datetime the current state began
+	EvaluatedAt *string `json:"evaluated_at,omitempty"` // RFC3339/ISO 8601 datetime
+	FiredCount  int     `json:"fired_count"`            // Times fired since the server started
+}
+
+// ToAlertResponse converts an alert to its response DTO
+func ToAlertResponse(alert entities.Alert) AlertResponse {
+	resp := AlertResponse{
+		Name:        alert.Rule.Name,
+		Metric:      alert.Rule.Metric,
+		Operator:    alert.Rule.Operator,
+		Threshold:   alert.Rule.Threshold,
+		Severity:    alert.Rule.Severity,
+		MinRequests: alert.Rule.MinRequests,
+		State:       string(alert.State),
+		Value:       alert.Value,
+		FiredCount:  alert.FiredCount,
+	}
+	if alert.Rule.Window > 0 {
+		resp.Window = alert.Rule.Window.String()
+	}
+	if !alert.Since.IsZero() {
+		since := alert.Since.Format(time.RFC3339)
+		resp.Since = &since
+	}
+	if !alert.EvaluatedAt.IsZero() {
+		evaluatedAt := alert.EvaluatedAt.Format(time.RFC3339)
+		resp.EvaluatedAt = &evaluatedAt
+	}
+	return resp
+}
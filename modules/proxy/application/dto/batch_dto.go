@@ -0,0 +1,106 @@
+package dto
+
+import (
+	"time"
+
+	"claude-proxy/modules/proxy/domain/entities"
+)
+
+// ============================================================================
+// Claude API DTOs (parsed from upstream responses)
+// ============================================================================
+
+// MessageBatchObject represents the fields of a Claude API message batch the proxy tracks
+type MessageBatchObject struct {
+	ID               string `json:"id"`
+	Type             string `json:"type"` // "message_batch", or "message_batch_deleted" for DELETE responses
+	ProcessingStatus string `json:"processing_status"`
+	EndedAt          string `json:"ended_at"` // RFC3339/ISO 8601 datetime, null while processing
+}
+
+// ============================================================================
+// Persistence DTOs (for JSON file storage)
+// ============================================================================
+
+// BatchPersistenceDTO represents the JSON structure of one entry in batches.json
+type BatchPersistenceDTO struct {
+	ID        string `json:"id"`
+	AccountID string `json:"account_id"`
+	TokenID   string `json:"token_id,omitempty"`
+	Status    string `json:"status,omitempty"`
+	CreatedAt string `json:"created_at"`         // RFC3339/ISO 8601 datetime
+	UpdatedAt string `json:"updated_at"`         // RFC3339/ISO 8601 datetime
+	EndedAt   string `json:"ended_at,omitempty"` // RFC3339/ISO 8601 datetime
+	ExpiresAt string `json:"expires_at"`         // RFC3339/ISO 8601 datetime
+}
+
+// ToBatchPersistenceDTO converts a batch to its persistence DTO
+func ToBatchPersistenceDTO(batch *entities.Batch) *BatchPersistenceDTO {
+	dto := &BatchPersistenceDTO{
+		ID:        batch.ID,
+		AccountID: batch.AccountID,
+		TokenID:   batch.TokenID,
+		Status:    batch.Status,
+		CreatedAt: batch.CreatedAt.Format(time.RFC3339),
+		UpdatedAt: batch.UpdatedAt.Format(time.RFC3339),
+		ExpiresAt: batch.ExpiresAt.Format(time.RFC3339),
+	}
+	if !batch.EndedAt.IsZero() {
+		dto.EndedAt = batch.EndedAt.Format(time.RFC3339)
+	}
+	return dto
+}
+
+// FromBatchPersistenceDTO converts a persistence DTO to a batch
+func FromBatchPersistenceDTO(dto *BatchPersistenceDTO) *entities.Batch {
+	createdAt, _ := time.Parse(time.RFC3339, dto.CreatedAt)
+	updatedAt, _ := time.Parse(time.RFC3339, dto.UpdatedAt)
+	endedAt, _ := time.Parse(time.RFC3339, dto.EndedAt)
+	expiresAt, _ := time.Parse(time.RFC3339, dto.ExpiresAt)
+	return &entities.Batch{
+		ID:        dto.ID,
+		AccountID: dto.AccountID,
+		TokenID:   dto.TokenID,
+		Status:    dto.Status,
+		CreatedAt: createdAt,
+		UpdatedAt: updatedAt,
+		EndedAt:   endedAt,
+		ExpiresAt: expiresAt,
+	}
+}
+
+// ============================================================================
+// API Response DTOs (for HTTP responses)
+// ============================================================================
+
+// BatchResponse represents a known message batch and the account it is pinned to
+type BatchResponse struct {
+	ID          string  `json:"id"`
+	AccountID   string  `json:"account_id"`
+	AccountName string  `json:"account_name,omitempty"` // Empty if the account no longer exists
+	TokenID     string  `json:"token_id,omitempty"`
+	Status      string  `json:"status"`
+	CreatedAt   string  `json:"created_at"`         // RFC3339/ISO 8601 datetime
+	UpdatedAt   string  `json:"updated_at"`         // RFC3339/ISO 8601 datetime
+	EndedAt     *string `json:"ended_at,omitempty"` // RFC3339/ISO 8601 datetime, nil while processing
+	ExpiresAt   string  `json:"expires_at"`         // RFC3339/ISO 8601 datetime the mapping is forgotten
+}
+
+// ToBatchResponse converts a batch to its response DTO
+func ToBatchResponse(batch *entities.Batch, accountName string) *BatchResponse {
+	resp := &BatchResponse{
+		ID:          batch.ID,
+		AccountID:   batch.AccountID,
+		AccountName: accountName,
+		TokenID:     batch.TokenID,
+		Status:      batch.Status,
+		CreatedAt:   batch.CreatedAt.Format(time.RFC3339),
+		UpdatedAt:   batch.UpdatedAt.Format(time.RFC3339),
+		ExpiresAt:   batch.ExpiresAt.Format(time.RFC3339),
+	}
+	if !batch.EndedAt.IsZero() {
+		endedAt := batch.EndedAt.Format(time.RFC3339)
+		resp.EndedAt = &endedAt
+	}
+	return resp
+}
@@ -0,0 +1,98 @@
+package dto
+
+import (
+	"time"
+
+	"claude-proxy/modules/proxy/domain/entities"
+)
+
+// ============================================================================
+// Claude API DTOs (parsed from upstream responses)
+// ============================================================================
+
+// FileObject represents the fields of a Claude API file the proxy tracks
+type FileObject struct {
+	ID        string `json:"id"`
+	Type      string `json:"type"` // "file", or "file_deleted" for DELETE responses
+	Filename  string `json:"filename"`
+	MimeType  string `json:"mime_type"`
+	SizeBytes int64  `json:"size_bytes"`
+}
+
+// ============================================================================
+// Persistence DTOs (for JSON file storage)
+// ============================================================================
+
+// FilePersistenceDTO represents the JSON structure of one entry in files.json
+type FilePersistenceDTO struct {
+	ID        string `json:"id"`
+	AccountID string `json:"account_id"`
+	TokenID   string `json:"token_id,omitempty"`
+	Filename  string `json:"filename,omitempty"`
+	MimeType  string `json:"mime_type,omitempty"`
+	SizeBytes int64  `json:"size_bytes,omitempty"`
+	CreatedAt string `json:"created_at"` // RFC3339/ISO 8601 datetime
+	ExpiresAt string `json:"expires_at"` // RFC3339/ISO 8601 datetime
+}
+
+// ToFilePersistenceDTO converts a file to its persistence DTO
+func ToFilePersistenceDTO(file *entities.File) *FilePersistenceDTO {
+	return &FilePersistenceDTO{
+		ID:        file.ID,
+		AccountID: file.AccountID,
+		TokenID:   file.TokenID,
+		Filename:  file.Filename,
+		MimeType:  file.MimeType,
+		SizeBytes: file.SizeBytes,
+		CreatedAt: file.CreatedAt.Format(time.RFC3339),
+		ExpiresAt: file.ExpiresAt.Format(time.RFC3339),
+	}
+}
+
+// FromFilePersistenceDTO converts a persistence DTO to a file
+func FromFilePersistenceDTO(dto *FilePersistenceDTO) *entities.File {
+	createdAt, _ := time.Parse(time.RFC3339, dto.CreatedAt)
+	expiresAt, _ := time.Parse(time.RFC3339, dto.ExpiresAt)
+	return &entities.File{
+		ID:        dto.ID,
+		AccountID: dto.AccountID,
+		TokenID:   dto.TokenID,
+		Filename:  dto.Filename,
+		MimeType:  dto.MimeType,
+		SizeBytes: dto.SizeBytes,
+		CreatedAt: createdAt,
+		ExpiresAt: expiresAt,
+	}
+}
+
+// ============================================================================
+// API Response DTOs (for HTTP responses)
+// ============================================================================
+
+// FileResponse represents a known uploaded file and the account it is pinned to
+type FileResponse struct {
+	ID          string `json:"id"`
+	AccountID   string `json:"account_id"`
+	AccountName string `json:"account_name,omitempty"` // Empty if the account no longer exists
+	TokenID     string `json:"token_id,omitempty"`
+	Filename    string `json:"filename"`
+	MimeType    string `json:"mime_type,omitempty"`
+	SizeBytes   int64  `json:"size_bytes"`
+	CreatedAt   string `json:"created_at"` // RFC3339/ISO 8601 datetime
+	ExpiresAt   string `json:"expires_at"` // RFC3339/ISO 8601 datetime the mapping is forgotten
+}
+
+// ToFileResponse converts a file to its response DTO
+func ToFileResponse(file *entities.File, accountName string) *FileResponse {
+	return &FileResponse{
+		ID:          file.ID,
+		AccountID:   file.AccountID,
+		AccountName: accountName,
+		TokenID:     file.TokenID,
+		Filename:    file.Filename,
+		MimeType:    file.MimeType,
+		SizeBytes:   file.SizeBytes,
+		CreatedAt:   file.CreatedAt.Format(time.RFC3339),
+		ExpiresAt:   file.ExpiresAt.Format(time.RFC3339),
+	}
+}
@@ -0,0 +1,86 @@
+package dto
+
+import (
+	"time"
+
+	"claude-proxy/modules/proxy/domain/entities"
+)
+
+// ============================================================================
+// Persistence DTOs (for JSON file storage)
+// ============================================================================
+
+// MaintenancePersistenceDTO represents the JSON structure of maintenance.json
+type MaintenancePersistenceDTO struct {
+	Enabled           bool   `json:"enabled"`
+	Message           string `json:"message,omitempty"`
+	RetryAfterSeconds int    `json:"retry_after_seconds,omitempty"`
+	UpdatedAt         string `json:"updated_at,omitempty"` // RFC3339/ISO 8601 datetime
+	UpdatedBy         string `json:"updated_by,omitempty"`
+}
+
+// ToMaintenancePersistenceDTO converts the maintenance state to its persistence DTO
+func ToMaintenancePersistenceDTO(state *entities.MaintenanceState) *MaintenancePersistenceDTO {
+	dto := &MaintenancePersistenceDTO{
+		Enabled:           state.Enabled,
+		Message:           state.Message,
+		RetryAfterSeconds: int(state.RetryAfter.Seconds()),
+		UpdatedBy:         state.UpdatedBy,
+	}
+	if !state.UpdatedAt.IsZero() {
+		dto.UpdatedAt = state.UpdatedAt.Format(time.RFC3339)
+	}
+	return dto
+}
+
+// FromMaintenancePersistenceDTO converts a persistence DTO to the maintenance state
+func FromMaintenancePersistenceDTO(dto *MaintenancePersistenceDTO) *entities.MaintenanceState {
+	updatedAt, _ := time.Parse(time.RFC3339, dto.UpdatedAt)
+	return &entities.MaintenanceState{
+		Enabled:    dto.Enabled,
+		Message:    dto.Message,
+		RetryAfter: time.Duration(dto.RetryAfterSeconds) * time.Second,
+		UpdatedAt:  updatedAt,
+		UpdatedBy:  dto.UpdatedBy,
+	}
+}
+
+// ============================================================================
+// API Request DTOs (for HTTP requests)
+// ============================================================================
+
+// SetMaintenanceRequest represents the request to toggle maintenance mode
+type SetMaintenanceRequest struct {
+	Enabled *bool  `json:"enabled" binding:"required"`
+	Message string `json:"message,omitempty"`
+	// RetryAfterSeconds is the Retry-After hint for clients (optional, default 300)
+	RetryAfterSeconds int `json:"retry_after_seconds,omitempty" binding:"omitempty,min=0"`
+}
+
+// ============================================================================
+// API Response DTOs (for HTTP responses)
+// ============================================================================
+
+// MaintenanceResponse represents the maintenance mode state
+type MaintenanceResponse struct {
+	Enabled           bool    `json:"enabled"`
+	Message           string  `json:"message,omitempty"`
+	RetryAfterSeconds int     `json:"retry_after_seconds"`
+	UpdatedAt         *string `json:"updated_at,omitempty"` // RFC3339/ISO 8601 datetime, nil if never toggled
+	UpdatedBy         string  `json:"updated_by,omitempty"`
+}
+
+// ToMaintenanceResponse converts the maintenance state to its response DTO
+func ToMaintenanceResponse(state entities.MaintenanceState) *MaintenanceResponse {
+	resp := &MaintenanceResponse{
+		Enabled:           state.Enabled,
+		Message:           state.Message,
+		RetryAfterSeconds: int(state.RetryAfter.Seconds()),
+		UpdatedBy:         state.UpdatedBy,
+	}
+	if !state.UpdatedAt.IsZero() {
+		timestamp := state.UpdatedAt.Format(time.RFC3339)
+		resp.UpdatedAt = &timestamp
+	}
+	return resp
+}
@@ -0,0 +1,89 @@
+package dto
+
+import (
+	"time"
+
+	"claude-proxy/modules/proxy/domain/entities"
+)
+
+// CapturedRequestResponse represents a captured failed request envelope
+// Its method, path, headers and body can be sent back as-is to POST /api/admin/replay
+type CapturedRequestResponse struct {
+	Timestamp         string            `json:"timestamp"` // RFC3339/ISO 8601 datetime
+	AccountID         string            `json:"account_id,omitempty"`
+	Method            string            `json:"method"`
+	Path              string            `json:"path"`
+	Headers           map[string]string `json:"headers"`
+	Body              string            `json:"body"`
+	BodyTruncated     bool              `json:"body_truncated,omitempty"` // Longer than 64KB, can't be replayed
+	StatusCode        int               `json:"status_code"`              // 0 when no upstream response was received
+	Error             string            `json:"error,omitempty"`
+	UpstreamRequestID string            `json:"upstream_request_id,omitempty"`
+}
+
+// ToCapturedRequestResponses converts captured request envelopes to response DTOs
+func ToCapturedRequestResponses(requests []entities.CapturedRequest) []CapturedRequestResponse {
+	result := make([]CapturedRequestResponse, len(requests))
+	for i, request := range requests {
+		result[i] = CapturedRequestResponse{
+			Timestamp:         request.Timestamp.Format(time.RFC3339),
+			AccountID:         request.AccountID,
+			Method:            request.Method,
+			Path:              request.Path,
+			Headers:           request.Headers,
+			Body:              string(request.Body),
+			BodyTruncated:     request.BodyTruncated,
+			StatusCode:        request.StatusCode,
+			Error:             request.Error,
+			UpstreamRequestID: request.UpstreamRequestID,
+		}
+	}
+	return result
+}
+
+// ReplayRequest represents the request to replay a captured request envelope through an account
+type ReplayRequest struct {
+	AccountID     string            `json:"account_id"     binding:"required"`
+	Method        string            `json:"method"         binding:"required,oneof=GET POST PUT PATCH DELETE"`
+	Path          string            `json:"path"           binding:"required,startswith=/v1/"`
+	Headers       map[string]string `json:"headers,omitempty"`
+	Body          string            `json:"body,omitempty"`
+	BodyTruncated bool              `json:"body_truncated,omitempty"` // Set on captures whose body was cut
+}
+
+// ToCapturedRequest converts the replay request to the envelope sent upstream
+func (r *ReplayRequest) ToCapturedRequest() entities.CapturedRequest {
+	request := entities.CapturedRequest{
+		Method:  r.Method,
+		Path:    r.Path,
+		Headers: r.Headers,
+	}
+	if r.Body != "" {
+		request.Body = []byte(r.Body)
+	}
+	return request
+}
+
+// ReplayResponse represents the upstream response to a replayed request
+type ReplayResponse struct {
+	AccountID         string            `json:"account_id"`
+	StatusCode        int               `json:"status_code"`
+	Headers           map[string]string `json:"headers"`
+	Body              string            `json:"body"`
+	BodyTruncated     bool              `json:"body_truncated,omitempty"` // Only the first 64KB are returned
+	LatencyMs         int64             `json:"latency_ms"`
+	UpstreamRequestID string            `json:"upstream_request_id,omitempty"`
+}
+
+// ToReplayResponse converts a replay result to the response DTO
+func ToReplayResponse(accountID string, result *entities.ReplayResult) *ReplayResponse {
+	return &ReplayResponse{
+		AccountID:         accountID,
+		StatusCode:        result.StatusCode,
+		Headers:           result.Headers,
+		Body:              string(result.Body),
+		BodyTruncated:     result.BodyTruncated,
+		LatencyMs:         result.Latency.Milliseconds(),
+		UpstreamRequestID: result.UpstreamRequestID,
+	}
+}
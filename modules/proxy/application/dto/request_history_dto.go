@@ -0,0 +1,41 @@
+package dto
+
+import (
+	"time"
+
+	"claude-proxy/modules/proxy/domain/entities"
+)
+
+// AccountRequestResponse represents one entry of an account's request history
+type AccountRequestResponse struct {
+	Timestamp  string `json:"timestamp"` // RFC3339/ISO 8601 datetime
+	TokenID    string `json:"token_id"`
+	Method     string `json:"method"`
+	Path       string `json:"path"`
+	Model      string `json:"model,omitempty"`
+	StatusCode int    `json:"status_code"` // 0 when no upstream response was received
+	LatencyMs  int64  `json:"latency_ms"`
+	Error      string `json:"error,omitempty"`
+	Shadow     bool   `json:"shadow,omitempty"` // Mirrored copy whose response was discarded
+	Replay     bool   `json:"replay,omitempty"` // Captured request resent by an admin
+}
+
+// ToAccountRequestResponses converts request history entries to response DTOs
+func ToAccountRequestResponses(requests []entities.AccountRequest) []AccountRequestResponse {
+	result := make([]AccountRequestResponse, len(requests))
+	for i, request := range requests {
+		result[i] = AccountRequestResponse{
+			Timestamp:  request.Timestamp.Format(time.RFC3339),
+			TokenID:    request.TokenID,
+			Method:     request.Method,
+			Path:       request.Path,
+			Model:      request.Model,
+			StatusCode: request.StatusCode,
+			LatencyMs:  request.Latency.Milliseconds(),
+			Error:      request.Error,
+			Shadow:     request.Shadow,
+			Replay:     request.Replay,
+		}
+	}
+	return result
+}
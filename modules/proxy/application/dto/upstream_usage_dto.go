@@ -0,0 +1,207 @@
+package dto
+
+import (
+	"time"
+
+	"claude-proxy/modules/proxy/domain/entities"
+)
+
+// ============================================================================
+// Persistence DTOs (for JSON file storage)
+// ============================================================================
+
+// UpstreamUsagePersistenceDTO represents the JSON structure of upstream_usage.json
+type UpstreamUsagePersistenceDTO struct {
+	Accounts []*AccountUpstreamUsagePersistenceDTO `json:"accounts"`
+}
+
+// AccountUpstreamUsagePersistenceDTO represents the official and local daily usage of one account
+type AccountUpstreamUsagePersistenceDTO struct {
+	AccountID     string                   `json:"account_id"`
+	Status        string                   `json:"status,omitempty"`
+	LastError     string                   `json:"last_error,omitempty"`
+	LastAttemptAt string                   `json:"last_attempt_at,omitempty"` // RFC3339/ISO 8601 datetime
+	LastSuccessAt string                   `json:"last_success_at,omitempty"` // RFC3339/ISO 8601 datetime
+	Official      map[string]DailyUsageDTO `json:"official,omitempty"`        // Keyed by UTC day (YYYY-MM-DD)
+	Local         map[string]DailyUsageDTO `json:"local,omitempty"`           // Keyed by UTC day (YYYY-MM-DD)
+}
+
+// DailyUsageDTO represents the token usage of one day
+type DailyUsageDTO struct {
+	Requests                 int64 `json:"requests,omitempty"`
+	InputTokens              int64 `json:"input_tokens"`
+	OutputTokens             int64 `json:"output_tokens"`
+	CacheCreationInputTokens int64 `json:"cache_creation_input_tokens"`
+	CacheReadInputTokens     int64 `json:"cache_read_input_tokens"`
+	TotalTokens              int64 `json:"total_tokens"`
+}
+
+// ToDailyUsageDTO converts a day's usage to its DTO
+func ToDailyUsageDTO(usage entities.DailyUsage) DailyUsageDTO {
+	return DailyUsageDTO{
+		Requests:                 usage.Requests,
+		InputTokens:              usage.InputTokens,
+		OutputTokens:             usage.OutputTokens,
+		CacheCreationInputTokens: usage.CacheCreationInputTokens,
+		CacheReadInputTokens:     usage.CacheReadInputTokens,
+		TotalTokens:              usage.TotalTokens(),
+	}
+}
+
+// fromDailyUsageDTO converts a day's usage DTO to the entity (the total is derived, not stored)
+func fromDailyUsageDTO(dto DailyUsageDTO) entities.DailyUsage {
+	return entities.DailyUsage{
+		Requests:                 dto.Requests,
+		InputTokens:              dto.InputTokens,
+		OutputTokens:             dto.OutputTokens,
+		CacheCreationInputTokens: dto.CacheCreationInputTokens,
+		CacheReadInputTokens:     dto.CacheReadInputTokens,
+	}
+}
+
+// ToUpstreamUsagePersistenceDTO converts the usage of every account to the persistence DTO
+func ToUpstreamUsagePersistenceDTO(usage []*entities.AccountUpstreamUsage) *UpstreamUsagePersistenceDTO {
+	result := &UpstreamUsagePersistenceDTO{Accounts: make([]*AccountUpstreamUsagePersistenceDTO, 0, len(usage))}
+	for _, account := range usage {
+		dto := &AccountUpstreamUsagePersistenceDTO{
+			AccountID: account.AccountID,
+			Status:    string(account.Status),
+			LastError: account.LastError,
+			Official:  toDailyUsageDTOs(account.Official),
+			Local:     toDailyUsageDTOs(account.Local),
+		}
+		if !account.LastAttemptAt.IsZero() {
+			dto.LastAttemptAt = account.LastAttemptAt.Format(time.RFC3339)
+		}
+		if !account.LastSuccessAt.IsZero() {
+			dto.LastSuccessAt = account.LastSuccessAt.Format(time.RFC3339)
+		}
+		result.Accounts = append(result.Accounts, dto)
+	}
+	return result
+}
+
+// FromUpstreamUsagePersistenceDTO converts the persistence DTO to the usage of every account
+func FromUpstreamUsagePersistenceDTO(dto *UpstreamUsagePersistenceDTO) []*entities.AccountUpstreamUsage {
+	usage := make([]*entities.AccountUpstreamUsage, 0, len(dto.Accounts))
+	for _, account := range dto.Accounts {
+		if account == nil || account.AccountID == "" {
+			continue
+		}
+		lastAttemptAt, _ := time.Parse(time.RFC3339, account.LastAttemptAt)
+		lastSuccessAt, _ := time.Parse(time.RFC3339, account.LastSuccessAt)
+		usage = append(usage, &entities.AccountUpstreamUsage{
+			AccountID:     account.AccountID,
+			Status:        entities.UpstreamUsageStatus(account.Status),
+			LastError:     account.LastError,
+			LastAttemptAt: lastAttemptAt,
+			LastSuccessAt: lastSuccessAt,
+			Official:      fromDailyUsageDTOs(account.Official),
+			Local:         fromDailyUsageDTOs(account.Local),
+		})
+	}
+	return usage
+}
+
+// toDailyUsageDTOs converts days of usage to their DTOs
+func toDailyUsageDTOs(days map[string]entities.DailyUsage) map[string]DailyUsageDTO {
+	if len(days) == 0 {
+		return nil
+	}
+	result := make(map[string]DailyUsageDTO, len(days))
+	for date, usage := range days {
+		result[date] = ToDailyUsageDTO(usage)
+	}
+	return result
+}
+
+// fromDailyUsageDTOs converts day usage DTOs to the entities
+func fromDailyUsageDTOs(days map[string]DailyUsageDTO) map[string]entities.DailyUsage {
+	result := make(map[string]entities.DailyUsage, len(days))
+	for date, usage := range days {
+		result[date] = fromDailyUsageDTO(usage)
+	}
+	return result
+}
+
+// ============================================================================
+// API Request DTOs (for HTTP requests)
+// ============================================================================
+
+// UpstreamUsageQueryParams represents the days of an upstream usage report (UTC, both inclusive)
+type UpstreamUsageQueryParams struct {
+	From time.Time `form:"from" time_format:"2006-01-02" time_utc:"1"` // Default: accounts.upstream_usage_days ago
+	To   time.Time `form:"to"   time_format:"2006-01-02" time_utc:"1"` // Default: today
+}
+
+// ============================================================================
+// API Response DTOs (for HTTP responses)
+// ============================================================================
+
+// UpstreamUsageResponse represents an account's official and locally counted daily usage
+type UpstreamUsageResponse struct {
+	AccountID     string                 `json:"account_id"`
+	AdminCapable  bool                   `json:"admin_capable"`
+	Status        string                 `json:"status"` // ok, error, forbidden (empty until the first collection)
+	LastError     string                 `json:"last_error,omitempty"`
+	LastAttemptAt *time.Time             `json:"last_attempt_at,omitempty"`
+	LastSuccessAt *time.Time             `json:"last_success_at,omitempty"`
+	From          string                 `json:"from"`
+	To            string                 `json:"to"`
+	Days          []UpstreamUsageDayDTO  `json:"days"`
+	Totals        UpstreamUsageTotalsDTO `json:"totals"`
+}
+
+// UpstreamUsageDayDTO represents one day of an account's usage report
+type UpstreamUsageDayDTO struct {
+	Date     string         `json:"date"`
+	Official *DailyUsageDTO `json:"official"` // Null if Claude API reported nothing for the day (yet)
+	Local    DailyUsageDTO  `json:"local"`
+}
+
+// UpstreamUsageTotalsDTO represents the official and local usage summed over a range of days
+type UpstreamUsageTotalsDTO struct {
+	Official DailyUsageDTO `json:"official"`
+	Local    DailyUsageDTO `json:"local"`
+}
+
+// ToUpstreamUsageResponse converts an account's usage over from..to to the response DTO
+func ToUpstreamUsageResponse(
+	accountID string,
+	adminCapable bool,
+	usage *entities.AccountUpstreamUsage,
+	from, to time.Time,
+) *UpstreamUsageResponse {
+	if usage == nil {
+		usage = &entities.AccountUpstreamUsage{AccountID: accountID}
+	}
+
+	response := &UpstreamUsageResponse{
+		AccountID:    accountID,
+		AdminCapable: adminCapable,
+		Status:       string(usage.Status),
+		LastError:    usage.LastError,
+		From:         entities.UsageDate(from),
+		To:           entities.UsageDate(to),
+		Days:         []UpstreamUsageDayDTO{},
+	}
+	if !usage.LastAttemptAt.IsZero() {
+		response.LastAttemptAt = &usage.LastAttemptAt
+	}
+	if !usage.LastSuccessAt.IsZero() {
+		response.LastSuccessAt = &usage.LastSuccessAt
+	}
+
+	for _, day := range usage.Days(from, to) {
+		entry := UpstreamUsageDayDTO{Date: day.Date, Local: ToDailyUsageDTO(day.Local)}
+		if day.Official != nil {
+			official := ToDailyUsageDTO(*day.Official)
+			entry.Official = &official
+		}
+		response.Days = append(response.Days, entry)
+	}
+	official, local := usage.Totals(from, to)
+	response.Totals = UpstreamUsageTotalsDTO{Official: ToDailyUsageDTO(official), Local: ToDailyUsageDTO(local)}
+
+	return response
+}
@@ -0,0 +1,151 @@
+package dto
+
+import (
+	"time"
+
+	"claude-proxy/modules/proxy/domain/entities"
+)
+
+// ============================================================================
+// Webhook Payload DTOs (sent to the webhook URL)
+// ============================================================================
+
+// UsageEventPayload represents the JSON body of a webhook delivery
+type UsageEventPayload struct {
+	ID         string           `json:"id"`
+	Type       string           `json:"type"`
+	Timestamp  string           `json:"timestamp"` // RFC3339/ISO 8601 datetime the request started
+	TokenID    string           `json:"token_id"`
+	AccountID  string           `json:"account_id,omitempty"`
+	Model      string           `json:"model,omitempty"`
+	StatusCode int              `json:"status_code"` // 0 when no upstream response was received
+	ErrorCode  string           `json:"error_code,omitempty"`
+	LatencyMs  int64            `json:"latency_ms"`
+	Usage      UsageEventTokens `json:"usage"`
+}
+
+// UsageEventTokens represents the token usage of a webhook event
+type UsageEventTokens struct {
+	InputTokens              int `json:"input_tokens"`
+	OutputTokens             int `json:"output_tokens"`
+	CacheCreationInputTokens int `json:"cache_creation_input_tokens"`
+	CacheReadInputTokens     int `json:"cache_read_input_tokens"`
+}
+
+// ToUsageEventPayload converts a usage event to its webhook payload
+func ToUsageEventPayload(event *entities.UsageEvent) *UsageEventPayload {
+	return &UsageEventPayload{
+		ID:         event.ID,
+		Type:       event.Type,
+		Timestamp:  event.Timestamp.Format(time.RFC3339Nano),
+		TokenID:    event.TokenID,
+		AccountID:  event.AccountID,
+		Model:      event.Model,
+		StatusCode: event.StatusCode,
+		ErrorCode:  event.ErrorCode,
+		LatencyMs:  event.Latency.Milliseconds(),
+		Usage: UsageEventTokens{
+			InputTokens:              event.Usage.InputTokens,
+			OutputTokens:             event.Usage.OutputTokens,
+			CacheCreationInputTokens: event.Usage.CacheCreationInputTokens,
+			CacheReadInputTokens:     event.Usage.CacheReadInputTokens,
+		},
+	}
+}
+
+// AlertEventPayload represents the JSON body of an alert webhook delivery
+type AlertEventPayload struct {
+	ID        string  `json:"id"`
+	Type      string  `json:"type"`
+	Timestamp string  `json:"timestamp"` // RFC3339/ISO 8601 datetime of the transition
+	Alert     string  `json:"alert"`     // Rule name
+	State     string  `json:"state"`     // firing or resolved
+	Severity  string  `json:"severity"`
+	Metric    string  `json:"metric"`
+	Operator  string  `json:"operator"`
+	Threshold float64 `json:"threshold"`
+	Window    string  `json:"window,omitempty"` // Go duration, omitted for metrics read as is
+	Value     float64 `json:"value"`
+}
+
+// ToAlertEventPayload converts an alert transition to its webhook payload
+func ToAlertEventPayload(transition *entities.AlertTransition) *AlertEventPayload {
+	rule := transition.Alert.Rule
+	payload := &AlertEventPayload{
+		ID:        transition.ID,
+		Type:      entities.WebhookEventAlert,
+		Timestamp: transition.At.Format(time.RFC3339Nano),
+		Alert:     rule.Name,
+		State:     string(entities.AlertStateFiring),
+		Severity:  rule.Severity,
+		Metric:    rule.Metric,
+		Operator:  rule.Operator,
+		Threshold: rule.Threshold,
+		Value:     transition.Alert.Value,
+	}
+	if transition.Resolved {
+		payload.State = "resolved"
+	}
+	if rule.Window > 0 {
+		payload.Window = rule.Window.String()
+	}
+	return payload
+}
+
+// ============================================================================
+// API Response DTOs (for HTTP responses)
+// ============================================================================
+
+// WebhookStatusResponse represents the webhook delivery counters and recent failures
+type WebhookStatusResponse struct {
+	Enabled     bool                     `json:"enabled"`
+	Events      []string                 `json:"events"`
+	QueueLength int                      `json:"queue_length"`
+	QueueSize   int                      `json:"queue_size"`
+	Delivered   int64                    `json:"delivered"`
+	Failed      int64                    `json:"failed"`  // Events given up after every retry
+	Dropped     int64                    `json:"dropped"` // Events discarded because the queue was full
+	Failures    []WebhookFailureResponse `json:"failures"`
+}
+
+// WebhookFailureResponse represents an event whose delivery failed
+type WebhookFailureResponse struct {
+	EventID    string `json:"event_id"`
+	EventType  string `json:"event_type"`
+	Attempts   int    `json:"attempts"`
+	StatusCode int    `json:"status_code,omitempty"` // Last response status, omitted on transport errors
+	Error      string `json:"error"`
+	FailedAt   string `json:"failed_at"` // RFC3339/ISO 8601 datetime
+}
+
+// ToWebhookStatusResponse converts the webhook status to its response DTO
+func ToWebhookStatusResponse(status entities.WebhookStatus) *WebhookStatusResponse {
+	resp := &WebhookStatusResponse{
+		Enabled:     status.Enabled,
+		Events:      status.Events,
+		QueueLength: status.QueueLength,
+		QueueSize:   status.QueueSize,
+		Delivered:   status.Delivered,
+		Failed:      status.Failed,
+		Dropped:     status.Dropped,
+		Failures:    make([]WebhookFailureResponse, 0, len(status.Failures)),
+	}
+	for _, failure := range status.Failures {
+		resp.Failures = append(resp.Failures, WebhookFailureResponse{
+			EventID:    failure.EventID,
+			EventType:  failure.EventType,
+			Attempts:   failure.Attempts,
+			StatusCode: failure.StatusCode,
+			Error:      failure.Error,
+			FailedAt:   failure.FailedAt.Format(time.RFC3339),
+		})
+	}
+	return resp
+}
+
+// WebhookTestResponse represents the result of a test delivery
+type WebhookTestResponse struct {
+	Delivered  bool   `json:"delivered"`
+	StatusCode int    `json:"status_code,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
@@ -0,0 +1,132 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"claude-proxy/modules/auth/domain/entities"
+	authinterfaces "claude-proxy/modules/auth/domain/interfaces"
+	proxyinterfaces "claude-proxy/modules/proxy/domain/interfaces"
+	"claude-proxy/pkg/telegram"
+
+	sctx "github.com/phathdt/service-context"
+)
+
+// AccountFailureTracker disables accounts after a run of consecutive upstream 403s
+// Only 403 (permission_error) counts: it is what Claude API answers for a suspended organization or disabled
+// account on every request, while 401s are left to the auth failure guard and 5xx, 429 and client errors say
+// nothing about the account. The last account available for proxying is never disabled.
+type AccountFailureTracker struct {
+	accountSvc authinterfaces.AccountService
+	notifier   *telegram.Client
+	threshold  int // Consecutive failures before an account is disabled (0 = never)
+	counts     map[string]int
+	mu         sync.Mutex
+	logger     sctx.Logger
+}
+
+// NewAccountFailureTracker creates a tracker disabling accounts after threshold consecutive failures
+// (0 or less = never disable)
+func NewAccountFailureTracker(
+	accountSvc authinterfaces.AccountService,
+	notifier *telegram.Client,
+	threshold int,
+	logger sctx.Logger,
+) proxyinterfaces.AccountFailureTracker {
+	return &AccountFailureTracker{
+		accountSvc: accountSvc,
+		notifier:   notifier,
+		threshold:  max(threshold, 0),
+		counts:     make(map[string]int),
+		logger:     logger,
+	}
+}
+
+// RecordSuccess resets the account's consecutive failure count
+func (t *AccountFailureTracker) RecordSuccess(accountID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.counts, accountID)
+}
+
+// RecordFailure counts an upstream 403, disabling the account when the count reaches the threshold
+func (t *AccountFailureTracker) RecordFailure(ctx context.Context, account *entities.Account, statusCode int) {
+	if statusCode != http.StatusForbidden {
+		return
+	}
+
+	t.mu.Lock()
+	t.counts[account.ID]++
+	count := t.counts[account.ID]
+	t.mu.Unlock()
+
+	if t.threshold == 0 || count < t.threshold {
+		return
+	}
+
+	fields := sctx.Fields{
+		"account_id":           account.ID,
+		"account_name":         account.Name,
+		"consecutive_failures": count,
+		"status_code":          statusCode,
+	}
+	if !t.otherAccountAvailable(ctx, account.ID) {
+		t.logger.Withs(fields).Warn("Account keeps failing but is the last one available, not auto-disabled")
+		return
+	}
+
+	reason := fmt.Sprintf("auto-disabled after %d consecutive upstream %ds", count, statusCode)
+	if err := t.accountSvc.DisableAccount(ctx, account.ID, reason); err != nil {
+		fields["error"] = err.Error()
+		t.logger.Withs(fields).Error("Failed to auto-disable account")
+		return
+	}
+	t.Reset(account.ID)
+
+	t.logger.Withs(fields).Error("Account auto-disabled after consecutive upstream failures")
+	text := fmt.Sprintf(
+		"⛔ *Account auto-disabled*: %s got %d consecutive upstream %d responses. Re-enable it with %s once fixed.",
+		markdownCode(account.Name), count, statusCode, markdownCode("POST /api/accounts/"+account.ID+"/enable"),
+	)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := t.notifier.SendMessage(ctx, text); err != nil {
+			t.logger.Withs(sctx.Fields{"error": err.Error()}).Warn("Failed to send account auto-disable notification")
+		}
+	}()
+}
+
+// otherAccountAvailable returns true if an account other than accountID can still serve requests
+func (t *AccountFailureTracker) otherAccountAvailable(ctx context.Context, accountID string) bool {
+	accounts, err := t.accountSvc.ListAccounts(ctx)
+	if err != nil {
+		return false
+	}
+	for _, account := range accounts {
+		if account.ID != accountID && account.IsAvailableForProxy() {
+			return true
+		}
+	}
+	return false
+}
+
+// Failures returns the account's current consecutive failure count
+func (t *AccountFailureTracker) Failures(accountID string) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.counts[accountID]
+}
+
+// Reset clears the account's consecutive failure count
+func (t *AccountFailureTracker) Reset(accountID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.counts, accountID)
+}
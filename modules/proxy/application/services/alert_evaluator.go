@@ -0,0 +1,277 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"claude-proxy/config"
+	authentities "claude-proxy/modules/auth/domain/entities"
+	authinterfaces "claude-proxy/modules/auth/domain/interfaces"
+	proxyentities "claude-proxy/modules/proxy/domain/entities"
+	proxyinterfaces "claude-proxy/modules/proxy/domain/interfaces"
+	"claude-proxy/pkg/telegram"
+	"claude-proxy/pkg/uid"
+
+	sctx "github.com/phathdt/service-context"
+)
+
+// trafficSample is the traffic counters since startup at one evaluation
+type trafficSample struct {
+	at           time.Time
+	requests     int64
+	errors       int64
+	serverErrors int64
+}
+
+// AlertEvaluator evaluates the alert rules against the traffic counters and the accounts
+// Counted metrics are the difference between the counters now and at the start of the rule's window, so each
+// evaluation records a sample of the counters and keeps those the longest window still needs. Only transitions
+// are notified: an alert is sent once when it starts firing and once when it resolves.
+type AlertEvaluator struct {
+	metrics    proxyinterfaces.TrafficMetrics
+	accountSvc authinterfaces.AccountService
+	notifier   *telegram.Client
+	webhooks   proxyinterfaces.WebhookDispatcher
+	maxWindow  time.Duration
+
+	alerts  []proxyentities.Alert
+	samples []trafficSample // Oldest first; the first one is at or before now - maxWindow once uptime allows
+	mu      sync.Mutex
+	logger  sctx.Logger
+}
+
+// NewAlertEvaluator creates an evaluator of rules (already merged with the defaults)
+func NewAlertEvaluator(
+	rules []config.AlertRuleConfig,
+	metrics proxyinterfaces.TrafficMetrics,
+	accountSvc authinterfaces.AccountService,
+	notifier *telegram.Client,
+	webhooks proxyinterfaces.WebhookDispatcher,
+	logger sctx.Logger,
+) proxyinterfaces.AlertEvaluator {
+	e := &AlertEvaluator{
+		metrics:    metrics,
+		accountSvc: accountSvc,
+		notifier:   notifier,
+		webhooks:   webhooks,
+		logger:     logger,
+	}
+	// The counters start at zero, which is the implicit sample at startup
+	e.samples = []trafficSample{{at: metrics.Snapshot().StartedAt}}
+
+	for _, rule := range rules {
+		e.alerts = append(e.alerts, proxyentities.Alert{
+			Rule: proxyentities.AlertRule{
+				Name:        rule.Name,
+				Metric:      rule.Metric,
+				Operator:    rule.Operator,
+				Threshold:   rule.Threshold,
+				Window:      rule.Window,
+				Severity:    rule.Severity,
+				MinRequests: rule.MinRequests,
+			},
+			State: proxyentities.AlertStateOK,
+		})
+		e.maxWindow = max(e.maxWindow, rule.Window)
+	}
+	return e
+}
+
+// Evaluate checks every rule at now and notifies the alerts that started or stopped firing
+func (e *AlertEvaluator) Evaluate(ctx context.Context, now time.Time) {
+	e.mu.Lock()
+	e.recordSample(now)
+
+	var transitions []proxyentities.AlertTransition
+	accounts := e.listAccounts(ctx)
+	for i := range e.alerts {
+		alert := &e.alerts[i]
+		value, ok, err := e.value(alert.Rule, now, accounts)
+		if err != nil {
+			e.logger.Withs(sctx.Fields{"alert": alert.Rule.Name, "error": err.Error()}).Warn("Failed to evaluate alert")
+			continue
+		}
+		alert.Value = value
+		alert.EvaluatedAt = now
+
+		firing := ok && alert.Rule.Breached(value)
+		if firing == (alert.State == proxyentities.AlertStateFiring) {
+			continue
+		}
+		alert.Since = now
+		if firing {
+			alert.State = proxyentities.AlertStateFiring
+			alert.FiredCount++
+		} else {
+			alert.State = proxyentities.AlertStateOK
+		}
+		transitions = append(transitions, proxyentities.AlertTransition{
+			ID:       uid.New(),
+			Alert:    *alert,
+			Resolved: !firing,
+			At:       now,
+		})
+	}
+	e.mu.Unlock()
+
+	for _, transition := range transitions {
+		e.notify(transition)
+	}
+}
+
+// Alerts returns the state of every rule, in configuration order
+func (e *AlertEvaluator) Alerts() []proxyentities.Alert {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]proxyentities.Alert(nil), e.alerts...)
+}
+
+// recordSample appends the current counters and drops the samples no window reaches back to (caller holds mu)
+func (e *AlertEvaluator) recordSample(now time.Time) {
+	snapshot := e.metrics.Snapshot()
+	e.samples = append(e.samples, trafficSample{
+		at:           now,
+		requests:     snapshot.Requests,
+		errors:       snapshot.Errors,
+		serverErrors: snapshot.ServerErrors,
+	})
+
+	// Keep the newest sample at or before the start of the longest window
+	cutoff := now.Add(-e.maxWindow)
+	drop := 0
+	for drop+1 < len(e.samples) && !e.samples[drop+1].at.After(cutoff) {
+		drop++
+	}
+	e.samples = e.samples[drop:]
+}
+
+// windowDelta returns the counters gained over the window ending now (since startup if it started earlier)
+// (caller holds mu)
+func (e *AlertEvaluator) windowDelta(window time.Duration, now time.Time) trafficSample {
+	latest := e.samples[len(e.samples)-1]
+	start := e.samples[0]
+	for _, sample := range e.samples {
+		if sample.at.After(now.Add(-window)) {
+			break
+		}
+		start = sample
+	}
+	return trafficSample{
+		requests:     latest.requests - start.requests,
+		errors:       latest.errors - start.errors,
+		serverErrors: latest.serverErrors - start.serverErrors,
+	}
+}
+
+// alertAccounts are the accounts listed once per evaluation for the account-based metrics
+type alertAccounts struct {
+	accounts []*authentities.Account
+	err      error
+}
+
+// listAccounts lists the accounts when a rule needs them (nil otherwise)
+func (e *AlertEvaluator) listAccounts(ctx context.Context) *alertAccounts {
+	for _, alert := range e.alerts {
+		switch alert.Rule.Metric {
+		case config.AlertMetricActiveAccounts, config.AlertMetricRefreshFailures:
+			accounts, err := e.accountSvc.ListAccounts(ctx)
+			return &alertAccounts{accounts: accounts, err: err}
+		}
+	}
+	return nil
+}
+
+// value returns the metric of a rule at now; ok is false when a rate has fewer requests than min_requests
+// (caller holds mu)
+func (e *AlertEvaluator) value(
+	rule proxyentities.AlertRule,
+	now time.Time,
+	accounts *alertAccounts,
+) (float64, bool, error) {
+	switch rule.Metric {
+	case config.AlertMetricRequests, config.AlertMetricErrors, config.AlertMetricServerErrors:
+		delta := e.windowDelta(rule.Window, now)
+		switch rule.Metric {
+		case config.AlertMetricErrors:
+			return float64(delta.errors), true, nil
+		case config.AlertMetricServerErrors:
+			return float64(delta.serverErrors), true, nil
+		default:
+			return float64(delta.requests), true, nil
+		}
+	case config.AlertMetricErrorRate, config.AlertMetricServerErrorRate:
+		delta := e.windowDelta(rule.Window, now)
+		if delta.requests == 0 || delta.requests < rule.MinRequests {
+			return 0, false, nil
+		}
+		failed := delta.errors
+		if rule.Metric == config.AlertMetricServerErrorRate {
+			failed = delta.serverErrors
+		}
+		return math.Round(float64(failed)*10000/float64(delta.requests)) / 100, true, nil // Percent, 2 decimals
+	case config.AlertMetricInFlight:
+		return float64(e.metrics.Snapshot().InFlight), true, nil
+	case config.AlertMetricActiveAccounts, config.AlertMetricRefreshFailures:
+		if accounts.err != nil {
+			return 0, false, fmt.Errorf("failed to list accounts: %w", accounts.err)
+		}
+		var count int
+		for _, account := range accounts.accounts {
+			if rule.Metric == config.AlertMetricActiveAccounts && account.IsUsableNow() {
+				count++
+			}
+			if rule.Metric == config.AlertMetricRefreshFailures && account.RefreshFailedAt != nil &&
+				now.Sub(*account.RefreshFailedAt) < rule.Window {
+				count++
+			}
+		}
+		return float64(count), true, nil
+	default:
+		return 0, false, fmt.Errorf("unknown metric %q", rule.Metric)
+	}
+}
+
+// notify logs a transition and sends it to Telegram and the webhook in the background
+func (e *AlertEvaluator) notify(transition proxyentities.AlertTransition) {
+	rule := transition.Alert.Rule
+	fields := sctx.Fields{
+		"alert":     rule.Name,
+		"severity":  rule.Severity,
+		"metric":    rule.Metric,
+		"value":     transition.Alert.Value,
+		"threshold": rule.Threshold,
+	}
+	condition := fmt.Sprintf("`%s` = `%s` (%s %s", rule.Metric, formatAlertValue(transition.Alert.Value),
+		rule.Operator, formatAlertValue(rule.Threshold))
+	if rule.Window > 0 {
+		condition += " over " + rule.Window.String()
+	}
+	condition += ")"
+
+	var text string
+	if transition.Resolved {
+		e.logger.Withs(fields).Info("Alert resolved")
+		text = fmt.Sprintf("✅ *Alert resolved*: `%s` - %s", rule.Name, condition)
+	} else {
+		e.logger.Withs(fields).Warn("Alert firing")
+		text = fmt.Sprintf("🚨 *Alert firing* (%s): `%s` - %s", rule.Severity, rule.Name, condition)
+	}
+
+	e.webhooks.PublishAlert(transition)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := e.notifier.SendMessage(ctx, text); err != nil {
+			e.logger.Withs(sctx.Fields{"error": err.Error()}).Warn("Failed to send alert notification")
+		}
+	}()
+}
+
+// formatAlertValue formats a metric value without trailing zeros (5, 12.5)
+func formatAlertValue(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
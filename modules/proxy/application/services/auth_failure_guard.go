@@ -0,0 +1,219 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"claude-proxy/modules/auth/domain/entities"
+	authinterfaces "claude-proxy/modules/auth/domain/interfaces"
+	proxyentities "claude-proxy/modules/proxy/domain/entities"
+	proxyinterfaces "claude-proxy/modules/proxy/domain/interfaces"
+	"claude-proxy/pkg/telegram"
+
+	sctx "github.com/phathdt/service-context"
+)
+
+// invalidReason is recorded on accounts marked invalid after an upstream 401
+const invalidReason = "Claude API rejected the account's credentials (401)"
+
+// AuthFailureGuard marks accounts invalid on upstream 401s, unless the failures look like they have another cause
+// In the default conservative mode an account is only marked invalid when another account's credentials were
+// accepted within the window and the clock is not known to be skewed: a single revoked account fails alone,
+// whereas clock skew (tokens used past their real expiry) or an upstream auth outage makes every account fail
+// at once. Those 401s are held, and a "possible clock skew or upstream auth outage" alert is raised instead,
+// once per window. The aggressive mode marks an account invalid on its first 401.
+type AuthFailureGuard struct {
+	accountSvc authinterfaces.AccountService
+	clock      proxyinterfaces.ClockMonitor
+	notifier   *telegram.Client
+	window     time.Duration
+	aggressive bool
+	failures   map[string]time.Time // Last held 401 per account
+	successes  map[string]time.Time // Last accepted request per account
+	outage     time.Time            // When every available account started failing (zero when not)
+	alertedAt  time.Time            // Last outage alert
+	mu         sync.Mutex
+	logger     sctx.Logger
+}
+
+// NewAuthFailureGuard creates an upstream 401 guard over a detection window
+func NewAuthFailureGuard(
+	accountSvc authinterfaces.AccountService,
+	clock proxyinterfaces.ClockMonitor,
+	notifier *telegram.Client,
+	window time.Duration,
+	aggressive bool,
+	logger sctx.Logger,
+) proxyinterfaces.AuthFailureGuard {
+	return &AuthFailureGuard{
+		accountSvc: accountSvc,
+		clock:      clock,
+		notifier:   notifier,
+		window:     window,
+		aggressive: aggressive,
+		failures:   make(map[string]time.Time),
+		successes:  make(map[string]time.Time),
+		logger:     logger,
+	}
+}
+
+// RecordSuccess notes that the account's credentials were accepted
+func (g *AuthFailureGuard) RecordSuccess(accountID string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.successes[accountID] = time.Now()
+	delete(g.failures, accountID)
+	if !g.outage.IsZero() {
+		g.logger.Withs(sctx.Fields{
+			"account_id":     accountID,
+			"outage_seconds": int64(time.Since(g.outage).Seconds()),
+		}).Info("Claude API accepts account credentials again, upstream auth failures resolved")
+		g.outage = time.Time{}
+	}
+}
+
+// RecordUnauthorized handles a 401 from Claude API for the account
+func (g *AuthFailureGuard) RecordUnauthorized(ctx context.Context, account *entities.Account) {
+	if g.aggressive {
+		g.invalidate(ctx, account)
+		return
+	}
+
+	now := time.Now()
+	clockSkewed := g.clock.Status().Exceeded()
+
+	g.mu.Lock()
+	g.prune(now)
+	otherAccepted := false
+	for accountID := range g.successes {
+		if accountID != account.ID {
+			otherAccepted = true
+			break
+		}
+	}
+	if otherAccepted && !clockSkewed {
+		delete(g.failures, account.ID)
+		g.mu.Unlock()
+		g.invalidate(ctx, account)
+		return
+	}
+	g.failures[account.ID] = now
+	failing := len(g.failures)
+	g.mu.Unlock()
+
+	g.logger.Withs(sctx.Fields{
+		"account_id":   account.ID,
+		"account_name": account.Name,
+		"failing":      failing,
+		"clock_skew":   clockSkewed,
+		"window":       g.window.String(),
+	}).Warn("Upstream 401 held: no other account was accepted recently, account not marked invalid")
+
+	g.checkOutage(ctx, now, clockSkewed)
+}
+
+// checkOutage raises an alert, once per window, when every available account failed within the window
+func (g *AuthFailureGuard) checkOutage(ctx context.Context, now time.Time, clockSkewed bool) {
+	accounts, err := g.accountSvc.ListAccounts(ctx)
+	if err != nil {
+		g.logger.Withs(sctx.Fields{"error": err.Error()}).Warn("Failed to list accounts for auth failure check")
+		return
+	}
+
+	g.mu.Lock()
+	available := 0
+	for _, account := range accounts {
+		if !account.IsAvailableForProxy() {
+			continue
+		}
+		available++
+		if _, failing := g.failures[account.ID]; !failing {
+			g.mu.Unlock()
+			return
+		}
+	}
+	if available == 0 {
+		g.mu.Unlock()
+		return
+	}
+	if g.outage.IsZero() {
+		g.outage = now
+	}
+	if !g.alertedAt.IsZero() && now.Sub(g.alertedAt) < g.window {
+		g.mu.Unlock()
+		return
+	}
+	g.alertedAt = now
+	g.mu.Unlock()
+
+	clock := g.clock.Status()
+	fields := sctx.Fields{"accounts": available, "window": g.window.String()}
+	if clock.Measured {
+		fields["clock_skew"] = clock.Skew.String()
+	}
+	g.logger.Withs(fields).Error(
+		"Possible clock skew or upstream auth outage: every account got 401 from Claude API, " +
+			"accounts are kept active (set clock.aggressive_invalidation to mark them invalid)",
+	)
+
+	text := fmt.Sprintf(
+		"🚨 *Possible clock skew or upstream auth outage*: all %d accounts got 401 from Claude API within `%s`. "+
+			"Accounts were not marked invalid.",
+		available, g.window,
+	)
+	if clockSkewed {
+		text += fmt.Sprintf("\nLocal clock is off by `%s` from Claude API.", clock.Skew)
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := g.notifier.SendMessage(ctx, text); err != nil {
+			g.logger.Withs(sctx.Fields{"error": err.Error()}).Warn("Failed to send auth failure notification")
+		}
+	}()
+}
+
+// invalidate marks the account invalid, logging failures
+func (g *AuthFailureGuard) invalidate(ctx context.Context, account *entities.Account) {
+	if err := g.accountSvc.InvalidateAccount(ctx, account.ID, invalidReason); err != nil {
+		g.logger.Withs(sctx.Fields{
+			"error":      err.Error(),
+			"account_id": account.ID,
+		}).Warn("Failed to mark account invalid")
+	}
+}
+
+// prune forgets failures and successes older than the window (caller holds the lock)
+func (g *AuthFailureGuard) prune(now time.Time) {
+	cutoff := now.Add(-g.window)
+	for accountID, at := range g.failures {
+		if at.Before(cutoff) {
+			delete(g.failures, accountID)
+		}
+	}
+	for accountID, at := range g.successes {
+		if at.Before(cutoff) {
+			delete(g.successes, accountID)
+		}
+	}
+	if len(g.failures) == 0 {
+		g.outage = time.Time{}
+	}
+}
+
+// Status returns the 401s held within the detection window
+func (g *AuthFailureGuard) Status() proxyentities.AuthFailureStatus {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.prune(time.Now())
+	return proxyentities.AuthFailureStatus{
+		Window:       g.window,
+		Aggressive:   g.aggressive,
+		HeldAccounts: len(g.failures),
+		OutageSince:  g.outage,
+	}
+}
@@ -0,0 +1,99 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"claude-proxy/modules/auth/domain/entities"
+	"claude-proxy/modules/proxy/application/dto"
+	proxyentities "claude-proxy/modules/proxy/domain/entities"
+	"claude-proxy/pkg/errors"
+
+	sctx "github.com/phathdt/service-context"
+)
+
+// batchesPath is the Message Batches API collection
+const batchesPath = "/v1/messages/batches"
+
+// isBatchRequest returns true for Message Batches API paths
+func isBatchRequest(path string) bool {
+	path = strings.TrimSuffix(path, "/")
+	return path == batchesPath || strings.HasPrefix(path, batchesPath+"/")
+}
+
+// batchIDFromPath returns the batch ID of /v1/messages/batches/{id} paths and their sub-paths
+// (results, cancel); "" for other paths
+func batchIDFromPath(path string) string {
+	rest, ok := strings.CutPrefix(path, batchesPath+"/")
+	if !ok {
+		return ""
+	}
+	id, _, _ := strings.Cut(rest, "/")
+	return id
+}
+
+// batchAccount returns the account owning a batch: batches only exist on the account that created them,
+// so it is used even while rate limited or with an open circuit breaker (Claude API answers for itself)
+func (s *ProxyService) batchAccount(ctx context.Context, batchID, accountID string) (*entities.Account, error) {
+	account, err := s.accountSvc.GetAccount(ctx, accountID)
+	if err != nil || account.IsDeleted() ||
+		(account.Status != entities.AccountStatusActive && account.Status != entities.AccountStatusRateLimited) {
+		return nil, errors.NewProxyError(
+			http.StatusServiceUnavailable, ErrCodeBatchAccountGone, "Batch account unavailable",
+			fmt.Sprintf("the account that created batch %s is deleted, inactive or invalid", batchID),
+		)
+	}
+	// Claims the probe slot of a cooled-down breaker when free; the request is sent either way
+	s.breaker.Acquire(account.ID)
+	return account, nil
+}
+
+// trackBatchResponse keeps the batch index in step with a successful Message Batches API response:
+// a created batch is pinned to the account, retrievals and cancels update its status, deletes forget it
+// List and results (JSONL, possibly large) responses are relayed without being read
+func (s *ProxyService) trackBatchResponse(req *http.Request, resp *http.Response, accountID, tokenID string) error {
+	path := strings.TrimSuffix(req.URL.Path, "/")
+	batchID := batchIDFromPath(path)
+	batchPath := batchesPath + "/" + batchID
+
+	switch {
+	case req.Method == http.MethodPost && path == batchesPath:
+	case req.Method == http.MethodGet && path == batchPath:
+	case req.Method == http.MethodPost && path == batchPath+"/cancel":
+	case req.Method == http.MethodDelete && path == batchPath:
+		s.batches.Forget(batchID)
+		return nil
+	default:
+		return nil
+	}
+
+	// Decoded so the batch object can be parsed; the relayed body is the decoded one
+	body, err := readDecodedBody(resp)
+	if err != nil {
+		s.logger.Withs(sctx.Fields{
+			"error":      err.Error(),
+			"account_id": accountID,
+			"path":       path,
+		}).Error("Failed to read message batch response")
+		return fmt.Errorf("failed to read message batch response: %w", err)
+	}
+	setResponseBody(resp, body)
+
+	var batch dto.MessageBatchObject
+	if err := json.Unmarshal(body, &batch); err != nil || batch.ID == "" || batch.Type != "message_batch" {
+		return nil // Not a batch object: nothing to track, relayed as is
+	}
+	endedAt, _ := time.Parse(time.RFC3339, batch.EndedAt)
+	s.batches.Track(&proxyentities.Batch{
+		ID:        batch.ID,
+		AccountID: accountID,
+		TokenID:   tokenID,
+		Status:    batch.ProcessingStatus,
+		EndedAt:   endedAt,
+	})
+	return nil
+}
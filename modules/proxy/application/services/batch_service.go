@@ -0,0 +1,186 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	proxyentities "claude-proxy/modules/proxy/domain/entities"
+	proxyinterfaces "claude-proxy/modules/proxy/domain/interfaces"
+
+	sctx "github.com/phathdt/service-context"
+)
+
+// BatchService keeps the message batch → account index in memory and syncs it to batches.json
+// Mappings live for the configured TTL from creation: ended batches keep theirs so results stay
+// downloadable from the right account, deleted batches are forgotten right away
+type BatchService struct {
+	repo    proxyinterfaces.BatchRepository
+	ttl     time.Duration
+	batches map[string]*proxyentities.Batch
+	dirty   bool
+	mu      sync.RWMutex
+	logger  sctx.Logger
+}
+
+// NewBatchService creates a batch service and loads the unexpired batches from persistence
+func NewBatchService(
+	repo proxyinterfaces.BatchRepository,
+	ttl time.Duration,
+	logger sctx.Logger,
+) proxyinterfaces.BatchService {
+	svc := &BatchService{
+		repo:    repo,
+		ttl:     ttl,
+		batches: make(map[string]*proxyentities.Batch),
+		logger:  logger,
+	}
+
+	batches, err := repo.LoadAll(context.Background())
+	if err != nil {
+		logger.Withs(sctx.Fields{"error": err.Error()}).Warn("Failed to load message batches from persistence")
+		return svc
+	}
+
+	now := time.Now()
+	for _, batch := range batches {
+		if batch.IsExpired(now) {
+			svc.dirty = true // Rewrite the file without it on next sync
+			continue
+		}
+		svc.batches[batch.ID] = batch
+	}
+	logger.Withs(sctx.Fields{"count": len(svc.batches)}).Info("Message batches loaded from persistence")
+
+	return svc
+}
+
+// Owner returns the ID of the account that created the batch
+func (s *BatchService) Owner(batchID string) (string, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	batch, ok := s.batches[batchID]
+	if !ok || batch.IsExpired(time.Now()) {
+		return "", false
+	}
+	return batch.AccountID, true
+}
+
+// Track records a new batch, or updates the status of a known one (its account never changes)
+func (s *BatchService) Track(batch *proxyentities.Batch) {
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, ok := s.batches[batch.ID]
+	if !ok {
+		tracked := batch.Clone()
+		tracked.CreatedAt = now
+		tracked.UpdatedAt = now
+		tracked.ExpiresAt = now.Add(s.ttl)
+		s.batches[batch.ID] = tracked
+		s.dirty = true
+
+		s.logger.Withs(sctx.Fields{
+			"batch_id":   batch.ID,
+			"account_id": batch.AccountID,
+			"token_id":   batch.TokenID,
+		}).Info("Message batch pinned to account")
+		return
+	}
+
+	if existing.Status == batch.Status && existing.EndedAt.Equal(batch.EndedAt) {
+		return
+	}
+	existing.Status = batch.Status
+	existing.EndedAt = batch.EndedAt
+	existing.UpdatedAt = now
+	s.dirty = true
+
+	s.logger.Withs(sctx.Fields{
+		"batch_id": batch.ID,
+		"status":   batch.Status,
+	}).Debug("Message batch status updated")
+}
+
+// Forget drops a batch
+func (s *BatchService) Forget(batchID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.batches[batchID]; !ok {
+		return
+	}
+	delete(s.batches, batchID)
+	s.dirty = true
+
+	s.logger.Withs(sctx.Fields{"batch_id": batchID}).Info("Message batch forgotten")
+}
+
+// List returns copies of the unexpired batches, most recently created first
+func (s *BatchService) List() []*proxyentities.Batch {
+	now := time.Now()
+
+	s.mu.RLock()
+	batches := make([]*proxyentities.Batch, 0, len(s.batches))
+	for _, batch := range s.batches {
+		if !batch.IsExpired(now) {
+			batches = append(batches, batch.Clone())
+		}
+	}
+	s.mu.RUnlock()
+
+	sort.Slice(batches, func(i, j int) bool {
+		return batches[i].CreatedAt.After(batches[j].CreatedAt)
+	})
+	return batches
+}
+
+// Sync drops expired batches and writes the index to persistent storage when it changed
+func (s *BatchService) Sync(ctx context.Context) error {
+	now := time.Now()
+
+	s.mu.Lock()
+	for id, batch := range s.batches {
+		if batch.IsExpired(now) {
+			delete(s.batches, id)
+			s.dirty = true
+		}
+	}
+	if !s.dirty {
+		s.mu.Unlock()
+		return nil // No changes, skip sync
+	}
+
+	// Clear before saving so changes made during the save mark the index dirty again
+	s.dirty = false
+	batches := make([]*proxyentities.Batch, 0, len(s.batches))
+	for _, batch := range s.batches {
+		batches = append(batches, batch.Clone())
+	}
+	s.mu.Unlock()
+
+	sort.Slice(batches, func(i, j int) bool {
+		return batches[i].CreatedAt.Before(batches[j].CreatedAt)
+	})
+
+	if err := s.repo.SaveAll(ctx, batches); err != nil {
+		s.mu.Lock()
+		s.dirty = true
+		s.mu.Unlock()
+		return fmt.Errorf("failed to save message batches: %w", err)
+	}
+
+	s.logger.Withs(sctx.Fields{"count": len(batches)}).Debug("Message batches synced to persistent storage")
+	return nil
+}
+
+// FinalSync performs final sync on graceful shutdown
+func (s *BatchService) FinalSync(ctx context.Context) error {
+	s.logger.Info("Performing final sync of message batches")
+	return s.Sync(ctx)
+}
@@ -0,0 +1,107 @@
+package services
+
+import (
+	"context"
+	"hash/fnv"
+
+	"claude-proxy/modules/auth/domain/entities"
+	authinterfaces "claude-proxy/modules/auth/domain/interfaces"
+	proxyentities "claude-proxy/modules/proxy/domain/entities"
+	proxyinterfaces "claude-proxy/modules/proxy/domain/interfaces"
+)
+
+// canaryBuckets is the resolution of the cohort split: hashes fall in one of 10000 buckets (0.01%)
+const canaryBuckets = 10000
+
+// CanaryRouter sends proxy.canary_percent of sessions to the accounts flagged canary and the rest to the others
+// The cohort is a hash of the session (or token) ID, so it needs no state and survives restarts. Finished
+// requests are counted per cohort in their own TrafficMetrics, which gives each the same error rates and
+// latency percentiles as the global traffic statistics.
+type CanaryRouter struct {
+	accountSvc authinterfaces.AccountService
+	percent    float64 // Share of sessions in the canary cohort (0-100)
+	metrics    map[proxyentities.Cohort]proxyinterfaces.TrafficMetrics
+}
+
+// NewCanaryRouter creates a router sending percent (0-100) of the sessions to canary accounts
+func NewCanaryRouter(accountSvc authinterfaces.AccountService, percent float64) proxyinterfaces.CanaryRouter {
+	return &CanaryRouter{
+		accountSvc: accountSvc,
+		percent:    percent,
+		metrics: map[proxyentities.Cohort]proxyinterfaces.TrafficMetrics{
+			proxyentities.CohortControl: NewTrafficMetrics(),
+			proxyentities.CohortCanary:  NewTrafficMetrics(),
+		},
+	}
+}
+
+// Enabled returns true if a share of sessions goes to canary accounts
+func (r *CanaryRouter) Enabled() bool {
+	return r.percent > 0
+}
+
+// Percent returns the share (0-100) of sessions in the canary cohort
+func (r *CanaryRouter) Percent() float64 {
+	return r.percent
+}
+
+// Cohort returns the cohort of a session or token ID
+func (r *CanaryRouter) Cohort(key string) proxyentities.Cohort {
+	if !r.Enabled() {
+		return proxyentities.CohortControl
+	}
+
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+	if float64(h.Sum32()%canaryBuckets) < r.percent*canaryBuckets/100 {
+		return proxyentities.CohortCanary
+	}
+	return proxyentities.CohortControl
+}
+
+// Pool narrows the selectable accounts to the cohort's, keeping them all when the cohort has none available
+// (a canary account being rate limited must not fail its cohort's requests, nor the control cohort's when only
+// canary accounts are left)
+func (r *CanaryRouter) Pool(accounts []*entities.Account, cohort proxyentities.Cohort) []*entities.Account {
+	if !r.Enabled() {
+		return accounts
+	}
+
+	wantCanary := cohort == proxyentities.CohortCanary
+	var pool []*entities.Account
+	for _, acc := range accounts {
+		if acc.Canary == wantCanary {
+			pool = append(pool, acc)
+		}
+	}
+	if len(pool) == 0 {
+		return accounts
+	}
+	return pool
+}
+
+// Record counts a finished request in the cohort of the account that served it
+// Requests that never reached an account (accountID "") and all requests while disabled are not counted.
+func (r *CanaryRouter) Record(accountID string, sample *entities.RequestSample) {
+	if !r.Enabled() || accountID == "" {
+		return
+	}
+
+	cohort := proxyentities.CohortControl
+	if account, err := r.accountSvc.GetAccount(context.Background(), accountID); err == nil && account.Canary {
+		cohort = proxyentities.CohortCanary
+	}
+	// Only finished requests are seen here, so the cohort counters track no in-flight requests
+	metrics := r.metrics[cohort]
+	metrics.RequestStarted()
+	metrics.RequestFinished(sample)
+}
+
+// Stats returns the traffic counters of each cohort
+func (r *CanaryRouter) Stats() map[proxyentities.Cohort]proxyentities.TrafficSnapshot {
+	stats := make(map[proxyentities.Cohort]proxyentities.TrafficSnapshot, len(r.metrics))
+	for cohort, metrics := range r.metrics {
+		stats[cohort] = metrics.Snapshot()
+	}
+	return stats
+}
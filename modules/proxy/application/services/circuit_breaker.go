@@ -0,0 +1,260 @@
+package services
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"claude-proxy/config"
+	proxyentities "claude-proxy/modules/proxy/domain/entities"
+	proxyinterfaces "claude-proxy/modules/proxy/domain/interfaces"
+
+	sctx "github.com/phathdt/service-context"
+)
+
+// maxBreakerSamples bounds the samples kept per account, whatever the window and traffic
+const maxBreakerSamples = 1000
+
+// breakerSample is one upstream request outcome
+type breakerSample struct {
+	at      time.Time
+	latency time.Duration
+	failed  bool
+}
+
+// accountBreaker is the circuit breaker state of a single account
+type accountBreaker struct {
+	state    proxyentities.BreakerState
+	samples  []breakerSample
+	openedAt time.Time
+	probing  bool // A half-open probe request is in flight
+}
+
+// CircuitBreaker tracks recent upstream latencies and errors per account and excludes degraded accounts
+// An account opens when its p95 latency or error rate over the rolling window exceeds the thresholds,
+// stays excluded for the cooldown, then lets a single probe request through (half-open):
+// a fast successful probe closes the breaker, anything else opens it for another cooldown
+type CircuitBreaker struct {
+	cfg      config.CircuitBreakerConfig
+	accounts map[string]*accountBreaker
+	mu       sync.Mutex
+	logger   sctx.Logger
+}
+
+// NewCircuitBreaker creates a circuit breaker (a no-op when disabled in config)
+func NewCircuitBreaker(cfg config.CircuitBreakerConfig, logger sctx.Logger) proxyinterfaces.CircuitBreaker {
+	return &CircuitBreaker{
+		cfg:      cfg,
+		accounts: make(map[string]*accountBreaker),
+		logger:   logger,
+	}
+}
+
+// Enabled returns true if the circuit breaker is configured
+func (b *CircuitBreaker) Enabled() bool {
+	return b.cfg.Enabled
+}
+
+// Selectable returns true if the account may be picked: closed, or cooled down with no probe in flight
+func (b *CircuitBreaker) Selectable(accountID string) bool {
+	if !b.cfg.Enabled {
+		return true
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	breaker, ok := b.accounts[accountID]
+	if !ok {
+		return true
+	}
+	return b.selectable(breaker, time.Now())
+}
+
+// Acquire admits a request to the selected account, claiming the probe slot of a cooled-down breaker
+// Returns false if another request took the probe first
+func (b *CircuitBreaker) Acquire(accountID string) bool {
+	if !b.cfg.Enabled {
+		return true
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	breaker, ok := b.accounts[accountID]
+	if !ok || breaker.state == proxyentities.BreakerStateClosed {
+		return true
+	}
+	if !b.selectable(breaker, time.Now()) {
+		return false
+	}
+
+	breaker.state = proxyentities.BreakerStateHalfOpen
+	breaker.probing = true
+	b.logger.Withs(sctx.Fields{"account_id": accountID}).Info("Circuit breaker half-open, probing account")
+	return true
+}
+
+// Release gives back a probe slot when the request ended before reaching Claude API
+// (client-side failures say nothing about the account's health)
+func (b *CircuitBreaker) Release(accountID string) {
+	if !b.cfg.Enabled {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if breaker, ok := b.accounts[accountID]; ok {
+		breaker.probing = false
+	}
+}
+
+// Record adds an upstream request outcome for the account and updates its breaker
+// latency is the time until response headers; failed covers transport errors and 5xx responses
+func (b *CircuitBreaker) Record(accountID string, latency time.Duration, failed bool) {
+	if !b.cfg.Enabled {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	breaker, ok := b.accounts[accountID]
+	if !ok {
+		breaker = &accountBreaker{state: proxyentities.BreakerStateClosed}
+		b.accounts[accountID] = breaker
+	}
+
+	switch breaker.state {
+	case proxyentities.BreakerStateOpen:
+		// Requests admitted before the breaker opened don't change its state
+		return
+	case proxyentities.BreakerStateHalfOpen:
+		if !breaker.probing {
+			return
+		}
+		breaker.probing = false
+		if failed || latency > b.cfg.MaxP95Latency {
+			b.open(accountID, breaker, now, "probe request failed or was too slow")
+			return
+		}
+		breaker.state = proxyentities.BreakerStateClosed
+		breaker.samples = nil
+		breaker.openedAt = time.Time{}
+		b.logger.Withs(sctx.Fields{
+			"account_id": accountID,
+			"latency_ms": latency.Milliseconds(),
+		}).Info("Circuit breaker closed, account recovered")
+		return
+	}
+
+	breaker.samples = append(breaker.samples, breakerSample{at: now, latency: latency, failed: failed})
+	b.prune(breaker, now)
+
+	if len(breaker.samples) < b.cfg.MinRequests {
+		return
+	}
+	p95, errorRate := breakerMetrics(breaker.samples)
+	switch {
+	case errorRate >= b.cfg.MaxErrorRate:
+		b.open(accountID, breaker, now, "error rate above threshold")
+	case p95 > b.cfg.MaxP95Latency:
+		b.open(accountID, breaker, now, "p95 latency above threshold")
+	}
+}
+
+// State returns the account's breaker state (open accounts past their cooldown are reported half-open)
+func (b *CircuitBreaker) State(accountID string) proxyentities.BreakerState {
+	return b.Status(accountID).State
+}
+
+// Status returns the account's breaker state and rolling window metrics
+func (b *CircuitBreaker) Status(accountID string) proxyentities.BreakerStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	breaker, ok := b.accounts[accountID]
+	if !ok {
+		return proxyentities.BreakerStatus{State: proxyentities.BreakerStateClosed}
+	}
+
+	now := time.Now()
+	b.prune(breaker, now)
+	status := proxyentities.BreakerStatus{
+		State:    breaker.state,
+		Requests: len(breaker.samples),
+		OpenedAt: breaker.openedAt,
+	}
+	status.P95Latency, status.ErrorRate = breakerMetrics(breaker.samples)
+	if breaker.state == proxyentities.BreakerStateOpen && !now.Before(breaker.openedAt.Add(b.cfg.Cooldown)) {
+		status.State = proxyentities.BreakerStateHalfOpen
+	}
+	return status
+}
+
+// selectable reports whether a breaker admits new requests (caller holds the lock)
+func (b *CircuitBreaker) selectable(breaker *accountBreaker, now time.Time) bool {
+	switch breaker.state {
+	case proxyentities.BreakerStateOpen:
+		return !now.Before(breaker.openedAt.Add(b.cfg.Cooldown))
+	case proxyentities.BreakerStateHalfOpen:
+		return !breaker.probing
+	default:
+		return true
+	}
+}
+
+// open excludes the account for a cooldown (caller holds the lock)
+func (b *CircuitBreaker) open(accountID string, breaker *accountBreaker, now time.Time, reason string) {
+	p95, errorRate := breakerMetrics(breaker.samples)
+	breaker.state = proxyentities.BreakerStateOpen
+	breaker.openedAt = now
+	breaker.probing = false
+
+	b.logger.Withs(sctx.Fields{
+		"account_id":     accountID,
+		"reason":         reason,
+		"requests":       len(breaker.samples),
+		"p95_latency_ms": p95.Milliseconds(),
+		"error_rate":     errorRate,
+		"cooldown":       b.cfg.Cooldown.String(),
+	}).Warn("Circuit breaker opened, excluding account from selection")
+}
+
+// prune drops samples older than the rolling window or beyond the per-account cap (caller holds the lock)
+func (b *CircuitBreaker) prune(breaker *accountBreaker, now time.Time) {
+	cutoff := now.Add(-b.cfg.Window)
+	drop := 0
+	for drop < len(breaker.samples) && breaker.samples[drop].at.Before(cutoff) {
+		drop++
+	}
+	if excess := len(breaker.samples) - drop - maxBreakerSamples; excess > 0 {
+		drop += excess
+	}
+	if drop > 0 {
+		breaker.samples = append(breaker.samples[:0], breaker.samples[drop:]...)
+	}
+}
+
+// breakerMetrics returns the p95 latency and error rate of the samples
+func breakerMetrics(samples []breakerSample) (time.Duration, float64) {
+	if len(samples) == 0 {
+		return 0, 0
+	}
+
+	latencies := make([]time.Duration, len(samples))
+	failures := 0
+	for i, sample := range samples {
+		latencies[i] = sample.latency
+		if sample.failed {
+			failures++
+		}
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+
+	index := int(math.Ceil(0.95*float64(len(latencies)))) - 1
+	return latencies[index], float64(failures) / float64(len(samples))
+}
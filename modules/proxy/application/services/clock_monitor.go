@@ -0,0 +1,111 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	proxyentities "claude-proxy/modules/proxy/domain/entities"
+	proxyinterfaces "claude-proxy/modules/proxy/domain/interfaces"
+	"claude-proxy/pkg/telegram"
+
+	sctx "github.com/phathdt/service-context"
+)
+
+// dateResolution is the precision of HTTP Date headers: the server time lies somewhere in the second they name
+const dateResolution = time.Second
+
+// ClockMonitor keeps the last measured skew between the local clock and Claude API's Date header
+// Crossing max_skew in either direction is logged as an error and sent to Telegram, as is the recovery
+type ClockMonitor struct {
+	maxSkew  time.Duration
+	notifier *telegram.Client
+	status   proxyentities.ClockStatus
+	mu       sync.RWMutex
+	logger   sctx.Logger
+}
+
+// NewClockMonitor creates a clock monitor tolerating up to maxSkew
+func NewClockMonitor(maxSkew time.Duration, notifier *telegram.Client, logger sctx.Logger) proxyinterfaces.ClockMonitor {
+	return &ClockMonitor{
+		maxSkew:  maxSkew,
+		notifier: notifier,
+		status:   proxyentities.ClockStatus{MaxSkew: maxSkew},
+		logger:   logger,
+	}
+}
+
+// Observe records a Date header received for a request sent at sent and answered at received
+// The server stamped the header somewhere in between, so the skew is measured against the midpoint;
+// headers that don't parse are ignored
+func (m *ClockMonitor) Observe(date string, sent, received time.Time, source string) {
+	serverTime, err := http.ParseTime(date)
+	if err != nil {
+		return
+	}
+
+	local := sent.Add(received.Sub(sent) / 2)
+	skew := local.Sub(serverTime.Add(dateResolution / 2)).Round(time.Millisecond)
+
+	m.mu.Lock()
+	wasExceeded := m.status.Exceeded()
+	m.status = proxyentities.ClockStatus{
+		Measured:  true,
+		Skew:      skew,
+		MaxSkew:   m.maxSkew,
+		Source:    source,
+		CheckedAt: received,
+	}
+	exceeded := m.status.Exceeded()
+	m.mu.Unlock()
+
+	fields := sctx.Fields{
+		"skew":     skew.String(),
+		"max_skew": m.maxSkew.String(),
+		"source":   source,
+	}
+	switch {
+	case exceeded && !wasExceeded:
+		m.logger.Withs(fields).Error(
+			"SYSTEM CLOCK SKEW: local time differs from Claude API by more than max_skew; access tokens may be " +
+				"used after they expire and fail with 401 - sync the clock (NTP)",
+		)
+		m.notifyAsync(fmt.Sprintf(
+			"⏰ *Clock skew detected*: local time is off by `%s` from Claude API (max `%s`). "+
+				"Token expiry checks are unreliable until the clock is synced.",
+			skew, m.maxSkew,
+		))
+	case !exceeded && wasExceeded:
+		m.logger.Withs(fields).Info("System clock back within max_skew of Claude API")
+		m.notifyAsync(fmt.Sprintf("✅ *Clock skew resolved*: local time is off by `%s` from Claude API", skew))
+	}
+}
+
+// Status returns the last measurement
+func (m *ClockMonitor) Status() proxyentities.ClockStatus {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.status
+}
+
+// CheckHealth returns an error while the measured skew exceeds the maximum (readiness check)
+func (m *ClockMonitor) CheckHealth(_ context.Context) error {
+	status := m.Status()
+	if status.Exceeded() {
+		return fmt.Errorf("system clock is off by %s from Claude API (max %s)", status.Skew, status.MaxSkew)
+	}
+	return nil
+}
+
+// notifyAsync sends a Telegram alert in the background
+func (m *ClockMonitor) notifyAsync(text string) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := m.notifier.SendMessage(ctx, text); err != nil {
+			m.logger.Withs(sctx.Fields{"error": err.Error()}).Warn("Failed to send clock skew notification")
+		}
+	}()
+}
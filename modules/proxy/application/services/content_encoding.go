@@ -0,0 +1,90 @@
+package services
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// inspectableEncodings are the content codings the proxy can decode when it needs to read a response
+// (usage extraction, models cache); "identity" asks for an uncompressed response
+var inspectableEncodings = map[string]bool{
+	"gzip":     true,
+	"deflate":  true,
+	"identity": true,
+}
+
+// forwardedAcceptEncoding filters the client's Accept-Encoding header down to the codings the proxy can decode
+// Returns "" when nothing usable remains, in which case the HTTP client negotiates (and decodes) gzip itself
+func forwardedAcceptEncoding(header string) string {
+	if header == "" {
+		return ""
+	}
+
+	codings := make([]string, 0, 3)
+	for _, part := range strings.Split(header, ",") {
+		part = strings.TrimSpace(part)
+		name := strings.ToLower(strings.TrimSpace(strings.SplitN(part, ";", 2)[0]))
+		if inspectableEncodings[name] {
+			codings = append(codings, part)
+		}
+	}
+	return strings.Join(codings, ", ")
+}
+
+// newDecodingReader wraps r with a decoder for the given Content-Encoding ("" and identity pass through)
+func newDecodingReader(encoding string, r io.Reader) (io.ReadCloser, error) {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "", "identity":
+		return io.NopCloser(r), nil
+	case "gzip":
+		return gzip.NewReader(r)
+	case "deflate":
+		// HTTP "deflate" is the zlib format (RFC 9110)
+		return zlib.NewReader(r)
+	default:
+		return nil, fmt.Errorf("unsupported content encoding: %s", encoding)
+	}
+}
+
+// decodeBytes decodes a complete (or truncated) encoded body, returning whatever could be decoded
+func decodeBytes(encoding string, data []byte) ([]byte, error) {
+	reader, err := newDecodingReader(encoding, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	decoded, err := io.ReadAll(reader)
+	if err == io.ErrUnexpectedEOF {
+		// Truncated input (buffer cap reached): the decoded prefix is still useful
+		err = nil
+	}
+	return decoded, err
+}
+
+// readDecodedBody reads and closes the response body, decoding it according to Content-Encoding
+// The Content-Encoding header is removed, so the caller can put the decoded bytes back with setResponseBody
+func readDecodedBody(resp *http.Response) ([]byte, error) {
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	encoding := resp.Header.Get("Content-Encoding")
+	if encoding == "" {
+		return body, nil
+	}
+
+	decoded, err := decodeBytes(encoding, body)
+	if err != nil {
+		return nil, err
+	}
+	resp.Header.Del("Content-Encoding")
+	return decoded, nil
+}
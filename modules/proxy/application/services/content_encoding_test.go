@@ -0,0 +1,69 @@
+package services
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestForwardedAcceptEncoding(t *testing.T) {
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{"", ""},
+		{"gzip", "gzip"},
+		{"gzip, deflate, br, zstd", "gzip, deflate"},
+		{"br;q=1.0, GZIP;q=0.5", "GZIP;q=0.5"},
+		{"br, zstd", ""},
+		{"identity", "identity"},
+	}
+	for _, tt := range tests {
+		if got := forwardedAcceptEncoding(tt.header); got != tt.want {
+			t.Errorf("forwardedAcceptEncoding(%q) = %q, want %q", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestDecodeBytes(t *testing.T) {
+	const plain = `{"usage":{"input_tokens":3,"output_tokens":1}}`
+	var long strings.Builder // Compresses poorly, so half the gzip stream holds a long decodable prefix
+	for i := range 2000 {
+		fmt.Fprintf(&long, `{"i":%d},`, i*7919)
+	}
+	var gz, zl bytes.Buffer
+	gw := gzip.NewWriter(&gz)
+	gw.Write([]byte(long.String()))
+	gw.Close()
+	zw := zlib.NewWriter(&zl)
+	zw.Write([]byte(plain))
+	zw.Close()
+
+	tests := []struct {
+		name     string
+		encoding string
+		data     []byte
+		want     string // Prefix of the decoded bytes
+		wantErr  bool
+	}{
+		{"identity", "identity", []byte(plain), plain, false},
+		{"gzip", "gzip", gz.Bytes(), long.String(), false},
+		{"deflate", " Deflate ", zl.Bytes(), plain, false},
+		{"truncated gzip", "gzip", gz.Bytes()[:gz.Len()/2], long.String()[:1000], false},
+		{"unsupported", "br", []byte(plain), "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeBytes(tt.encoding, tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("decodeBytes() error = %v, want error %v", err, tt.wantErr)
+			}
+			if !strings.HasPrefix(string(got), tt.want) {
+				t.Errorf("decodeBytes() = %.80q, want prefix %.80q", got, tt.want)
+			}
+		})
+	}
+}
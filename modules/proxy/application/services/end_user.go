@@ -0,0 +1,56 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// userIDField is the metadata field Claude API accepts to identify the end user behind a request
+var userIDField = []byte(`"user_id"`)
+
+// endUserID returns the metadata.user_id of a message request body
+// A body without one gets headerValue (the token's external user ID header) as its metadata.user_id first;
+// an ID the client already sent is kept untouched. Bodies that are not JSON objects, or whose metadata is not
+// an object, are returned unchanged (Claude API reports the error).
+func endUserID(bodyBytes []byte, headerValue string) ([]byte, string, error) {
+	if bytes.Contains(bodyBytes, userIDField) {
+		var probe struct {
+			Metadata struct {
+				UserID any `json:"user_id"`
+			} `json:"metadata"`
+		}
+		if err := json.Unmarshal(bodyBytes, &probe); err == nil {
+			if userID, ok := probe.Metadata.UserID.(string); ok && userID != "" {
+				return bodyBytes, userID, nil
+			}
+		}
+	}
+	if headerValue == "" {
+		return bodyBytes, "", nil
+	}
+
+	// UseNumber keeps numeric values exactly as sent when the body is re-serialized
+	var body map[string]any
+	decoder := json.NewDecoder(bytes.NewReader(bodyBytes))
+	decoder.UseNumber()
+	if err := decoder.Decode(&body); err != nil || body == nil {
+		return bodyBytes, "", nil
+	}
+
+	metadata, ok := body["metadata"].(map[string]any)
+	if !ok {
+		if body["metadata"] != nil {
+			return bodyBytes, "", nil
+		}
+		metadata = make(map[string]any)
+		body["metadata"] = metadata
+	}
+	metadata["user_id"] = headerValue
+
+	injected, err := json.Marshal(body)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to marshal request body with metadata.user_id: %w", err)
+	}
+	return injected, headerValue, nil
+}
@@ -0,0 +1,43 @@
+package services
+
+import (
+	"sync"
+
+	"claude-proxy/modules/proxy/domain/entities"
+	proxyinterfaces "claude-proxy/modules/proxy/domain/interfaces"
+)
+
+// capturedFailuresPerToken is how many failed request envelopes are kept per token
+const capturedFailuresPerToken = 10
+
+// FailureCapture keeps the last failed request envelopes of each token, newest first
+// Envelopes hold bodies of up to 64KB, so only a handful are kept per token and only for tokens that opted in
+type FailureCapture struct {
+	mu       sync.Mutex
+	captures map[string][]entities.CapturedRequest // Token ID -> envelopes, newest first
+}
+
+// NewFailureCapture creates an empty failure capture
+func NewFailureCapture() proxyinterfaces.FailureCapture {
+	return &FailureCapture{captures: make(map[string][]entities.CapturedRequest)}
+}
+
+// Record adds a failed request to the token's captures, evicting its oldest capture when full
+func (f *FailureCapture) Record(request entities.CapturedRequest) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	captures := append([]entities.CapturedRequest{request}, f.captures[request.TokenID]...)
+	if len(captures) > capturedFailuresPerToken {
+		captures = captures[:capturedFailuresPerToken]
+	}
+	f.captures[request.TokenID] = captures
+}
+
+// Recent returns the token's captured failed requests, newest first
+func (f *FailureCapture) Recent(tokenID string) []entities.CapturedRequest {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return append([]entities.CapturedRequest{}, f.captures[tokenID]...)
+}
@@ -0,0 +1,209 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"claude-proxy/modules/auth/domain/entities"
+	"claude-proxy/modules/proxy/application/dto"
+	proxyentities "claude-proxy/modules/proxy/domain/entities"
+	"claude-proxy/modules/proxy/infrastructure/clients"
+	"claude-proxy/pkg/errors"
+
+	sctx "github.com/phathdt/service-context"
+)
+
+// filesPath is the Files API collection
+const filesPath = "/v1/files"
+
+// fileIDField is the JSON field message content blocks reference uploaded files with
+var fileIDField = []byte(`"file_id"`)
+
+// isFileRequest returns true for Files API paths
+func isFileRequest(path string) bool {
+	path = strings.TrimSuffix(path, "/")
+	return path == filesPath || strings.HasPrefix(path, filesPath+"/")
+}
+
+// fileIDFromPath returns the file ID of /v1/files/{id} paths and their sub-paths (content); "" for other paths
+func fileIDFromPath(path string) string {
+	rest, ok := strings.CutPrefix(path, filesPath+"/")
+	if !ok {
+		return ""
+	}
+	id, _, _ := strings.Cut(rest, "/")
+	return id
+}
+
+// isFileUpload returns true for multipart POST /v1/files requests, whose body is streamed upstream
+func isFileUpload(req *http.Request) bool {
+	if req.Method != http.MethodPost || strings.TrimSuffix(req.URL.Path, "/") != filesPath {
+		return false
+	}
+	mediaType, _, err := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	return err == nil && mediaType == "multipart/form-data"
+}
+
+// mayReferenceFiles returns true for requests whose JSON body can reference uploaded files:
+// message creation, token counting and batch creation
+func mayReferenceFiles(method, path string) bool {
+	if method != http.MethodPost {
+		return false
+	}
+	switch strings.TrimSuffix(path, "/") {
+	case "/v1/messages", "/v1/messages/count_tokens", batchesPath:
+		return true
+	}
+	return false
+}
+
+// fileIDsInBody returns the "file_id" string values found at any depth of a JSON body, in order
+func fileIDsInBody(body []byte) []string {
+	if !bytes.Contains(body, fileIDField) {
+		return nil
+	}
+	var payload any
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil
+	}
+
+	var ids []string
+	var walk func(value any)
+	walk = func(value any) {
+		switch v := value.(type) {
+		case map[string]any:
+			for key, child := range v {
+				if id, ok := child.(string); ok && key == "file_id" && id != "" {
+					ids = append(ids, id)
+					continue
+				}
+				walk(child)
+			}
+		case []any:
+			for _, child := range v {
+				walk(child)
+			}
+		}
+	}
+	walk(payload)
+	return ids
+}
+
+// fileOwner returns the known file a request is about (its path) or references (its JSON body) with the
+// account it was uploaded to; ok is false when it involves no known file
+// A body that has to be scanned is read and put back, so it can still be forwarded
+func (s *ProxyService) fileOwner(req *http.Request) (fileID, accountID string, ok bool, err error) {
+	if id := fileIDFromPath(req.URL.Path); id != "" {
+		accountID, ok = s.files.Owner(id)
+		return id, accountID, ok, nil
+	}
+	if req.Body == nil || !mayReferenceFiles(req.Method, req.URL.Path) || s.files.Len() == 0 {
+		return "", "", false, nil
+	}
+
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		return "", "", false, classifyBodyReadError(err)
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+
+	for _, id := range fileIDsInBody(body) {
+		owner, known := s.files.Owner(id)
+		if !known {
+			continue
+		}
+		if !ok {
+			fileID, accountID, ok = id, owner, true
+			continue
+		}
+		if owner != accountID {
+			// Claude API will reject the files this account doesn't hold; nothing the proxy can fix
+			s.logger.Withs(sctx.Fields{
+				"file_id":       fileID,
+				"account_id":    accountID,
+				"other_file_id": id,
+				"other_account": owner,
+			}).Warn("Request references files uploaded to different accounts, routing to the first one's")
+			break
+		}
+	}
+	return fileID, accountID, ok, nil
+}
+
+// fileAccount returns the account a file was uploaded to: files only exist on that account, so it is used
+// even while rate limited or with an open circuit breaker (Claude API answers for itself)
+func (s *ProxyService) fileAccount(ctx context.Context, fileID, accountID string) (*entities.Account, error) {
+	account, err := s.accountSvc.GetAccount(ctx, accountID)
+	if err != nil || account.IsDeleted() ||
+		(account.Status != entities.AccountStatusActive && account.Status != entities.AccountStatusRateLimited) {
+		return nil, errors.NewProxyError(
+			http.StatusServiceUnavailable, ErrCodeFileAccountGone, "File account unavailable",
+			fmt.Sprintf("the account file %s was uploaded to is deleted, inactive or invalid", fileID),
+		)
+	}
+	// Claims the probe slot of a cooled-down breaker when free; the request is sent either way
+	s.breaker.Acquire(account.ID)
+	return account, nil
+}
+
+// trackFileResponse keeps the file index in step with a successful Files API response:
+// an uploaded file is pinned to the account and a deleted one is forgotten
+// Listings, metadata and content downloads are relayed without being read
+func (s *ProxyService) trackFileResponse(req *http.Request, resp *http.Response, accountID, tokenID string) error {
+	path := strings.TrimSuffix(req.URL.Path, "/")
+	fileID := fileIDFromPath(path)
+
+	switch {
+	case req.Method == http.MethodPost && path == filesPath:
+	case req.Method == http.MethodDelete && fileID != "" && path == filesPath+"/"+fileID:
+		s.files.Forget(fileID)
+		return nil
+	default:
+		return nil
+	}
+
+	// Decoded so the file object can be parsed; the relayed body is the decoded one
+	body, err := readDecodedBody(resp)
+	if err != nil {
+		s.logger.Withs(sctx.Fields{
+			"error":      err.Error(),
+			"account_id": accountID,
+			"path":       path,
+		}).Error("Failed to read file upload response")
+		return fmt.Errorf("failed to read file upload response: %w", err)
+	}
+	setResponseBody(resp, body)
+
+	var file dto.FileObject
+	if err := json.Unmarshal(body, &file); err != nil || file.ID == "" || file.Type != "file" {
+		return nil // Not a file object: nothing to track, relayed as is
+	}
+	s.files.Track(&proxyentities.File{
+		ID:        file.ID,
+		AccountID: accountID,
+		TokenID:   tokenID,
+		Filename:  file.Filename,
+		MimeType:  file.MimeType,
+		SizeBytes: file.SizeBytes,
+	})
+	return nil
+}
+
+// withFilesAPIBeta adds the Files API flag to the anthropic-beta header of headers, next to the OAuth flag
+// (or to the account's own anthropic-beta override); the client drops the OAuth flag for API-key accounts
+func withFilesAPIBeta(headers map[string]string, accountBeta string) {
+	beta := accountBeta
+	if beta == "" {
+		beta = clients.OAuthBeta
+	}
+	if !strings.Contains(beta, clients.FilesAPIBeta) {
+		beta += "," + clients.FilesAPIBeta
+	}
+	headers["anthropic-beta"] = beta
+}
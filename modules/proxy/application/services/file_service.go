@@ -0,0 +1,179 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	proxyentities "claude-proxy/modules/proxy/domain/entities"
+	proxyinterfaces "claude-proxy/modules/proxy/domain/interfaces"
+
+	sctx "github.com/phathdt/service-context"
+)
+
+// FileService keeps the Files API file → account index in memory and syncs it to files.json
+// Claude API keeps files until they are deleted, so mappings live for the configured TTL from upload
+// and are forgotten earlier only when the file is deleted through the proxy
+type FileService struct {
+	repo   proxyinterfaces.FileRepository
+	ttl    time.Duration
+	files  map[string]*proxyentities.File
+	dirty  bool
+	mu     sync.RWMutex
+	logger sctx.Logger
+}
+
+// NewFileService creates a file service and loads the unexpired files from persistence
+func NewFileService(
+	repo proxyinterfaces.FileRepository,
+	ttl time.Duration,
+	logger sctx.Logger,
+) proxyinterfaces.FileService {
+	svc := &FileService{
+		repo:   repo,
+		ttl:    ttl,
+		files:  make(map[string]*proxyentities.File),
+		logger: logger,
+	}
+
+	files, err := repo.LoadAll(context.Background())
+	if err != nil {
+		logger.Withs(sctx.Fields{"error": err.Error()}).Warn("Failed to load files from persistence")
+		return svc
+	}
+
+	now := time.Now()
+	for _, file := range files {
+		if file.IsExpired(now) {
+			svc.dirty = true // Rewrite the file without it on next sync
+			continue
+		}
+		svc.files[file.ID] = file
+	}
+	logger.Withs(sctx.Fields{"count": len(svc.files)}).Info("Files loaded from persistence")
+
+	return svc
+}
+
+// Owner returns the ID of the account the file was uploaded to
+func (s *FileService) Owner(fileID string) (string, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	file, ok := s.files[fileID]
+	if !ok || file.IsExpired(time.Now()) {
+		return "", false
+	}
+	return file.AccountID, true
+}
+
+// Track records an uploaded file; a file already known keeps its account
+func (s *FileService) Track(file *proxyentities.File) {
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.files[file.ID]; ok {
+		return
+	}
+	tracked := file.Clone()
+	tracked.CreatedAt = now
+	tracked.ExpiresAt = now.Add(s.ttl)
+	s.files[file.ID] = tracked
+	s.dirty = true
+
+	s.logger.Withs(sctx.Fields{
+		"file_id":    file.ID,
+		"account_id": file.AccountID,
+		"token_id":   file.TokenID,
+		"size_bytes": file.SizeBytes,
+	}).Info("File pinned to account")
+}
+
+// Forget drops a file
+func (s *FileService) Forget(fileID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.files[fileID]; !ok {
+		return
+	}
+	delete(s.files, fileID)
+	s.dirty = true
+
+	s.logger.Withs(sctx.Fields{"file_id": fileID}).Info("File forgotten")
+}
+
+// Len returns the number of known files, expired ones included until the next sync
+func (s *FileService) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.files)
+}
+
+// List returns copies of the unexpired files, most recently uploaded first
+func (s *FileService) List() []*proxyentities.File {
+	now := time.Now()
+
+	s.mu.RLock()
+	files := make([]*proxyentities.File, 0, len(s.files))
+	for _, file := range s.files {
+		if !file.IsExpired(now) {
+			files = append(files, file.Clone())
+		}
+	}
+	s.mu.RUnlock()
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].CreatedAt.After(files[j].CreatedAt)
+	})
+	return files
+}
+
+// Sync drops expired files and writes the index to persistent storage when it changed
+func (s *FileService) Sync(ctx context.Context) error {
+	now := time.Now()
+
+	s.mu.Lock()
+	for id, file := range s.files {
+		if file.IsExpired(now) {
+			delete(s.files, id)
+			s.dirty = true
+		}
+	}
+	if !s.dirty {
+		s.mu.Unlock()
+		return nil // No changes, skip sync
+	}
+
+	// Clear before saving so changes made during the save mark the index dirty again
+	s.dirty = false
+	files := make([]*proxyentities.File, 0, len(s.files))
+	for _, file := range s.files {
+		files = append(files, file.Clone())
+	}
+	s.mu.Unlock()
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].CreatedAt.Before(files[j].CreatedAt)
+	})
+
+	if err := s.repo.SaveAll(ctx, files); err != nil {
+		s.mu.Lock()
+		s.dirty = true
+		s.mu.Unlock()
+		return fmt.Errorf("failed to save files: %w", err)
+	}
+
+	s.logger.Withs(sctx.Fields{"count": len(files)}).Debug("Files synced to persistent storage")
+	return nil
+}
+
+// FinalSync performs final sync on graceful shutdown
+func (s *FileService) FinalSync(ctx context.Context) error {
+	s.logger.Info("Performing final sync of files")
+	return s.Sync(ctx)
+}
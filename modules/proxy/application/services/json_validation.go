@@ -0,0 +1,75 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"claude-proxy/pkg/errors"
+)
+
+// jsonBodyPaths are the /v1/messages endpoints whose POST requests always carry a JSON body; others, such as
+// cancelling a batch, are sent bodiless with Content-Type: application/json by the SDKs
+var jsonBodyPaths = map[string]bool{
+	"/v1/messages":              true,
+	"/v1/messages/count_tokens": true,
+	"/v1/messages/batches":      true,
+}
+
+// validateJSONBody rejects a malformed or empty JSON body of a POST request to /v1/messages (message creation,
+// token counting, batch creation) before it takes a session slot or an account request; Claude API would
+// answer it with a 400 anyway. Other methods, paths and content types pass through untouched.
+// The body is buffered and restored for forwarding.
+func validateJSONBody(req *http.Request) error {
+	if req.Method != http.MethodPost || !jsonBodyPaths[strings.TrimSuffix(req.URL.Path, "/")] {
+		return nil
+	}
+	if mediaType, _, err := mime.ParseMediaType(req.Header.Get("Content-Type")); err != nil ||
+		mediaType != "application/json" {
+		return nil
+	}
+
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		if err != nil {
+			return classifyBodyReadError(err)
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	if len(bytes.TrimSpace(body)) == 0 {
+		return errors.NewBadRequestError(
+			ErrCodeInvalidJSON, "Request body is empty", "Content-Type is application/json but the body is empty",
+		)
+	}
+	if json.Valid(body) {
+		return nil
+	}
+	return errors.NewBadRequestError(ErrCodeInvalidJSON, "Request body is not valid JSON", describeJSONError(body))
+}
+
+// describeJSONError explains why a body is not valid JSON, with the line and column of the syntax error
+func describeJSONError(body []byte) string {
+	var value any
+	err := json.Unmarshal(body, &value)
+	if err == nil {
+		// json.Valid rejected it, so only trailing data after a complete value remains
+		return "unexpected data after the top-level JSON value"
+	}
+
+	var syntaxErr *json.SyntaxError
+	if !stderrors.As(err, &syntaxErr) {
+		return err.Error()
+	}
+	offset := min(int(syntaxErr.Offset), len(body))
+	line := 1 + bytes.Count(body[:offset], []byte("\n"))
+	column := offset - bytes.LastIndexByte(body[:offset], '\n')
+	return fmt.Sprintf("%s at line %d, column %d (byte offset %d)", syntaxErr.Error(), line, column, syntaxErr.Offset)
+}
@@ -0,0 +1,64 @@
+package services
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"claude-proxy/pkg/errors"
+)
+
+func TestValidateJSONBody(t *testing.T) {
+	tests := []struct {
+		name    string
+		method  string
+		path    string
+		body    string
+		wantErr bool
+	}{
+		{"valid message", http.MethodPost, "/v1/messages", `{"model":"claude"}`, false},
+		{"empty message", http.MethodPost, "/v1/messages", "", true},
+		{"malformed message", http.MethodPost, "/v1/messages", `{"model":`, true},
+		{"trailing slash", http.MethodPost, "/v1/messages/", "  ", true},
+		{"empty count_tokens", http.MethodPost, "/v1/messages/count_tokens", "", true},
+		{"empty batch creation", http.MethodPost, "/v1/messages/batches", "", true},
+		{"bodiless batch cancel", http.MethodPost, "/v1/messages/batches/msgbatch_01/cancel", "", false},
+		{"malformed batch cancel", http.MethodPost, "/v1/messages/batches/msgbatch_01/cancel", "{", false},
+		{"batch retrieval", http.MethodGet, "/v1/messages/batches/msgbatch_01", "", false},
+		{"other path", http.MethodPost, "/v1/complete", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+
+			err := validateJSONBody(req)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("validateJSONBody() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				appErr, ok := err.(errors.AppError)
+				if !ok || appErr.StatusCode() != http.StatusBadRequest || appErr.ErrorCode() != ErrCodeInvalidJSON {
+					t.Fatalf("validateJSONBody() error = %#v, want 400 %s", err, ErrCodeInvalidJSON)
+				}
+				return
+			}
+
+			body, _ := io.ReadAll(req.Body)
+			if string(body) != tt.body {
+				t.Errorf("forwarded body = %q, want %q", body, tt.body)
+			}
+		})
+	}
+}
+
+func TestValidateJSONBodyIgnoresOtherContentTypes(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader("not json"))
+	req.Header.Set("Content-Type", "text/plain")
+	if err := validateJSONBody(req); err != nil {
+		t.Fatalf("validateJSONBody() error = %v, want nil", err)
+	}
+}
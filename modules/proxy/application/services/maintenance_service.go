@@ -0,0 +1,133 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	proxyentities "claude-proxy/modules/proxy/domain/entities"
+	proxyinterfaces "claude-proxy/modules/proxy/domain/interfaces"
+	"claude-proxy/pkg/telegram"
+
+	sctx "github.com/phathdt/service-context"
+)
+
+// MaintenanceService keeps the maintenance mode flag in memory and writes every change through to disk
+type MaintenanceService struct {
+	repo     proxyinterfaces.MaintenanceRepository
+	notifier *telegram.Client
+	state    proxyentities.MaintenanceState
+	mu       sync.RWMutex
+	logger   sctx.Logger
+}
+
+// NewMaintenanceService creates a maintenance service and restores the persisted state
+func NewMaintenanceService(
+	repo proxyinterfaces.MaintenanceRepository,
+	notifier *telegram.Client,
+	logger sctx.Logger,
+) proxyinterfaces.MaintenanceService {
+	svc := &MaintenanceService{
+		repo:     repo,
+		notifier: notifier,
+		state:    proxyentities.MaintenanceState{RetryAfter: proxyentities.DefaultMaintenanceRetryAfter},
+		logger:   logger,
+	}
+
+	state, err := repo.Load(context.Background())
+	if err != nil {
+		logger.Withs(sctx.Fields{"error": err.Error()}).Warn("Failed to load maintenance state, proxying stays enabled")
+	} else if state != nil {
+		if state.RetryAfter <= 0 {
+			state.RetryAfter = proxyentities.DefaultMaintenanceRetryAfter
+		}
+		svc.state = *state
+	}
+
+	if svc.state.Enabled {
+		logger.Withs(sctx.Fields{
+			"message":    svc.state.Message,
+			"updated_by": svc.state.UpdatedBy,
+		}).Warn("Maintenance mode is enabled, /v1 requests are rejected until it is turned off")
+	}
+
+	return svc
+}
+
+// State returns a copy of the current maintenance state
+func (s *MaintenanceService) State() proxyentities.MaintenanceState {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.state
+}
+
+// SetMaintenance enables or disables maintenance mode
+// Requests already being proxied (including streams) are not interrupted
+func (s *MaintenanceService) SetMaintenance(
+	ctx context.Context,
+	enabled bool,
+	message string,
+	retryAfter time.Duration,
+	actor string,
+) (proxyentities.MaintenanceState, error) {
+	if retryAfter <= 0 {
+		retryAfter = proxyentities.DefaultMaintenanceRetryAfter
+	}
+
+	s.mu.Lock()
+	previous := s.state
+	next := proxyentities.MaintenanceState{
+		Enabled:    enabled,
+		Message:    message,
+		RetryAfter: retryAfter,
+		UpdatedAt:  time.Now(),
+		UpdatedBy:  actor,
+	}
+	if err := s.repo.Save(ctx, &next); err != nil {
+		s.mu.Unlock()
+		return previous, fmt.Errorf("failed to persist maintenance state: %w", err)
+	}
+	s.state = next
+	s.mu.Unlock()
+
+	s.logger.Withs(sctx.Fields{
+		"enabled":     enabled,
+		"message":     message,
+		"retry_after": retryAfter.String(),
+		"actor":       actor,
+	}).Warn("Maintenance mode updated")
+
+	if previous.Enabled != enabled {
+		s.notifyAsync(next)
+	}
+
+	return next, nil
+}
+
+// notifyAsync sends a Telegram notification about a maintenance toggle in the background
+func (s *MaintenanceService) notifyAsync(state proxyentities.MaintenanceState) {
+	// Free text goes in code spans so underscores (e.g. config_api_key) don't break Telegram Markdown
+	actor := markdownCode(state.UpdatedBy)
+	text := fmt.Sprintf("✅ *Maintenance mode disabled* by %s: proxying resumed", actor)
+	if state.Enabled {
+		text = fmt.Sprintf("🚧 *Maintenance mode enabled* by %s: /v1 requests are paused", actor)
+		if state.Message != "" {
+			text += "\nMessage: " + markdownCode(state.Message)
+		}
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := s.notifier.SendMessage(ctx, text); err != nil {
+			s.logger.Withs(sctx.Fields{"error": err.Error()}).Warn("Failed to send maintenance notification")
+		}
+	}()
+}
+
+// markdownCode wraps text in a Markdown code span
+func markdownCode(text string) string {
+	return "`" + strings.ReplaceAll(text, "`", "'") + "`"
+}
@@ -0,0 +1,322 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// MessageSequenceError reports a messages array that normalization could not turn into a valid sequence
+type MessageSequenceError struct {
+	Reason string
+}
+
+func (e *MessageSequenceError) Error() string {
+	return e.Reason
+}
+
+// newSequenceError builds a MessageSequenceError with a formatted reason
+func newSequenceError(format string, args ...interface{}) error {
+	return &MessageSequenceError{Reason: fmt.Sprintf(format, args...)}
+}
+
+// isMessageSequenceError returns true if err was caused by an invalid message sequence
+func isMessageSequenceError(err error) bool {
+	var seqErr *MessageSequenceError
+	return errors.As(err, &seqErr)
+}
+
+// sequencedMessage is a message being normalized, with its position in the original request
+type sequencedMessage struct {
+	index  int // Index in the original messages array (first one for merged messages)
+	role   string
+	blocks []interface{}
+	fields map[string]interface{} // The message object (role and content are rewritten on output)
+}
+
+// MessageNormalizer repairs common client mistakes in POST /v1/messages message sequences
+// Fixes applied, in order: string content becomes a text block, empty text blocks and messages are dropped,
+// consecutive same-role messages are merged, tool_result blocks are moved to the front of their user
+// message and orphaned ones (no matching tool_use in the preceding assistant message) are dropped
+type MessageNormalizer struct {
+	enabled bool
+}
+
+// NewMessageNormalizer creates a message normalizer (a no-op unless enabled)
+func NewMessageNormalizer(enabled bool) *MessageNormalizer {
+	return &MessageNormalizer{enabled: enabled}
+}
+
+// Apply normalizes the messages of a message request body and returns it with a description of each fix
+// Returns a *MessageSequenceError when the sequence is still invalid after normalization;
+// bodies that are not JSON objects are returned unchanged (Claude API reports the error)
+func (n *MessageNormalizer) Apply(bodyBytes []byte) ([]byte, []string, error) {
+	if !n.enabled || len(bodyBytes) == 0 {
+		return bodyBytes, nil, nil
+	}
+
+	// UseNumber keeps numeric values exactly as sent when the body is re-serialized
+	var body map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(bodyBytes))
+	decoder.UseNumber()
+	if err := decoder.Decode(&body); err != nil || body == nil {
+		return bodyBytes, nil, nil
+	}
+
+	rawMessages, ok := body["messages"]
+	if !ok {
+		return bodyBytes, nil, nil
+	}
+	list, ok := rawMessages.([]interface{})
+	if !ok {
+		return nil, nil, newSequenceError("messages: expected an array of messages")
+	}
+
+	messages, stringContent, err := parseMessages(list)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var fixes []string
+	messages = dropEmptyContent(messages, &fixes)
+	messages = mergeSameRole(messages, &fixes)
+	messages = fixToolResults(messages, &fixes)
+	// Dropping orphaned tool results can empty a message and leave same-role neighbours behind
+	messages = dropEmptyContent(messages, &fixes)
+	messages = mergeSameRole(messages, &fixes)
+
+	if len(messages) == 0 {
+		return nil, nil, newSequenceError("messages: no message with non-empty content")
+	}
+	// Only a request that ends with an assistant message has a prefill; one whose last (user) message was
+	// dropped must not turn into one
+	prefill := list[len(list)-1].(map[string]interface{})["role"] == "assistant"
+	if err := checkToolUsePairs(messages, prefill); err != nil {
+		return nil, nil, err
+	}
+	if len(fixes) == 0 {
+		return bodyBytes, nil, nil
+	}
+
+	body["messages"] = renderMessages(messages, stringContent)
+	normalized, err := json.Marshal(body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to marshal normalized request body: %w", err)
+	}
+	return normalized, fixes, nil
+}
+
+// parseMessages validates the shape of each message and converts its content to blocks
+// The returned set marks messages whose content was a plain string, so untouched ones are written back as sent
+func parseMessages(list []interface{}) ([]*sequencedMessage, map[int]bool, error) {
+	messages := make([]*sequencedMessage, 0, len(list))
+	stringContent := make(map[int]bool)
+
+	for i, raw := range list {
+		fields, ok := raw.(map[string]interface{})
+		if !ok {
+			return nil, nil, newSequenceError("messages[%d]: expected an object", i)
+		}
+
+		role, _ := fields["role"].(string)
+		if role != "user" && role != "assistant" {
+			return nil, nil, newSequenceError(
+				"messages[%d].role: expected \"user\" or \"assistant\", got %v", i, fields["role"],
+			)
+		}
+
+		message := &sequencedMessage{index: i, role: role, fields: fields}
+		switch content := fields["content"].(type) {
+		case string:
+			stringContent[i] = true
+			message.blocks = []interface{}{map[string]interface{}{"type": "text", "text": content}}
+		case []interface{}:
+			for j, block := range content {
+				blockFields, ok := block.(map[string]interface{})
+				if !ok || blockType(blockFields) == "" {
+					return nil, nil, newSequenceError(
+						"messages[%d].content[%d]: expected a content block with a type", i, j,
+					)
+				}
+			}
+			message.blocks = content
+		default:
+			return nil, nil, newSequenceError(
+				"messages[%d].content: expected a string or an array of content blocks", i,
+			)
+		}
+		messages = append(messages, message)
+	}
+
+	return messages, stringContent, nil
+}
+
+// dropEmptyContent removes empty or whitespace-only text blocks and the messages left without content
+func dropEmptyContent(messages []*sequencedMessage, fixes *[]string) []*sequencedMessage {
+	kept := messages[:0]
+	for _, message := range messages {
+		blocks := make([]interface{}, 0, len(message.blocks))
+		for _, block := range message.blocks {
+			fields := block.(map[string]interface{})
+			if blockType(fields) == "text" {
+				if text, _ := fields["text"].(string); strings.TrimSpace(text) == "" {
+					*fixes = append(*fixes, fmt.Sprintf("dropped empty text block in messages[%d]", message.index))
+					continue
+				}
+			}
+			blocks = append(blocks, block)
+		}
+		message.blocks = blocks
+
+		if len(blocks) == 0 {
+			*fixes = append(*fixes, fmt.Sprintf(
+				"dropped messages[%d] (%s) with empty content", message.index, message.role,
+			))
+			continue
+		}
+		kept = append(kept, message)
+	}
+	return kept
+}
+
+// mergeSameRole merges consecutive messages with the same role into one
+func mergeSameRole(messages []*sequencedMessage, fixes *[]string) []*sequencedMessage {
+	merged := messages[:0]
+	for _, message := range messages {
+		if len(merged) > 0 {
+			previous := merged[len(merged)-1]
+			if previous.role == message.role {
+				previous.blocks = append(previous.blocks, message.blocks...)
+				*fixes = append(*fixes, fmt.Sprintf(
+					"merged messages[%d] into messages[%d] (consecutive %s messages)",
+					message.index, previous.index, message.role,
+				))
+				continue
+			}
+		}
+		merged = append(merged, message)
+	}
+	return merged
+}
+
+// fixToolResults drops tool_result blocks that answer no tool_use of the preceding assistant message
+// and moves the remaining ones to the front of their user message, as Claude API requires
+func fixToolResults(messages []*sequencedMessage, fixes *[]string) []*sequencedMessage {
+	for i, message := range messages {
+		if message.role != "user" {
+			continue
+		}
+
+		toolUseIDs := make(map[string]bool)
+		if i > 0 && messages[i-1].role == "assistant" {
+			for _, block := range messages[i-1].blocks {
+				fields := block.(map[string]interface{})
+				if blockType(fields) == "tool_use" {
+					id, _ := fields["id"].(string)
+					toolUseIDs[id] = true
+				}
+			}
+		}
+
+		var results, others []interface{}
+		reordered := false
+		for _, block := range message.blocks {
+			fields := block.(map[string]interface{})
+			if blockType(fields) != "tool_result" {
+				others = append(others, block)
+				continue
+			}
+
+			id, _ := fields["tool_use_id"].(string)
+			if !toolUseIDs[id] {
+				*fixes = append(*fixes, fmt.Sprintf(
+					"dropped orphaned tool_result %q in messages[%d] (no matching tool_use before it)",
+					id, message.index,
+				))
+				continue
+			}
+			if len(others) > 0 {
+				reordered = true
+			}
+			results = append(results, block)
+		}
+
+		if reordered {
+			*fixes = append(*fixes, fmt.Sprintf("moved tool_result blocks to the start of messages[%d]", message.index))
+		}
+		message.blocks = append(results, others...)
+	}
+	return messages
+}
+
+// checkToolUsePairs verifies that every tool_use is answered by a tool_result in the next message
+// A trailing assistant prefill is left to Claude API
+func checkToolUsePairs(messages []*sequencedMessage, prefill bool) error {
+	for i, message := range messages {
+		if message.role != "assistant" {
+			continue
+		}
+		last := i == len(messages)-1
+		if last && prefill {
+			continue
+		}
+
+		answered := make(map[string]bool)
+		var next []interface{}
+		if !last {
+			next = messages[i+1].blocks
+		}
+		for _, block := range next {
+			fields := block.(map[string]interface{})
+			if blockType(fields) == "tool_result" {
+				id, _ := fields["tool_use_id"].(string)
+				answered[id] = true
+			}
+		}
+
+		for _, block := range message.blocks {
+			fields := block.(map[string]interface{})
+			if blockType(fields) != "tool_use" {
+				continue
+			}
+			id, _ := fields["id"].(string)
+			if !answered[id] && last {
+				return newSequenceError(
+					"messages[%d]: tool_use %q has no tool_result (no user message with content follows it)",
+					message.index, id,
+				)
+			}
+			if !answered[id] {
+				return newSequenceError(
+					"messages[%d]: tool_use %q has no tool_result in the following user message (messages[%d])",
+					message.index, id, messages[i+1].index,
+				)
+			}
+		}
+	}
+	return nil
+}
+
+// renderMessages converts normalized messages back to request JSON
+// Messages that were sent with string content and still hold that single text block keep the string form
+func renderMessages(messages []*sequencedMessage, stringContent map[int]bool) []interface{} {
+	rendered := make([]interface{}, len(messages))
+	for i, message := range messages {
+		message.fields["role"] = message.role
+		if stringContent[message.index] && len(message.blocks) == 1 {
+			message.fields["content"] = message.blocks[0].(map[string]interface{})["text"]
+		} else {
+			message.fields["content"] = message.blocks
+		}
+		rendered[i] = message.fields
+	}
+	return rendered
+}
+
+// blockType returns the type of a content block ("" if missing)
+func blockType(block map[string]interface{}) string {
+	blockType, _ := block["type"].(string)
+	return blockType
+}
@@ -0,0 +1,236 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Content blocks of the normalizer corpus
+const (
+	toolUse1    = `{"type":"tool_use","id":"toolu_1","name":"get_weather","input":{"city":"Hanoi"}}`
+	toolUse2    = `{"type":"tool_use","id":"toolu_2","name":"get_time","input":{}}`
+	toolResult1 = `{"type":"tool_result","tool_use_id":"toolu_1","content":"31°C"}`
+	toolResult2 = `{"type":"tool_result","tool_use_id":"toolu_2","content":[{"type":"text","text":"12:00"}]}`
+	textBlock   = `{"type":"text","text":"thanks"}`
+)
+
+// decodeMessages decodes the messages array of a request body, keeping numbers as sent
+func decodeMessages(t *testing.T, body []byte) interface{} {
+	t.Helper()
+
+	var request struct {
+		Messages interface{} `json:"messages"`
+	}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if err := decoder.Decode(&request); err != nil {
+		t.Fatalf("invalid body %s: %v", body, err)
+	}
+	return request.Messages
+}
+
+func TestMessageNormalizerCorpus(t *testing.T) {
+	tests := []struct {
+		name      string
+		messages  string
+		want      string // Normalized messages; empty when the body must be forwarded untouched
+		wantFixes int
+		wantErr   string // Part of the MessageSequenceError reason
+	}{
+		{
+			name: "valid tool round trip",
+			messages: `[{"role":"user","content":"weather?"},{"role":"assistant","content":[` + toolUse1 + `]},` +
+				`{"role":"user","content":[` + toolResult1 + `]}]`,
+		},
+		{
+			name: "tool_result after text",
+			messages: `[{"role":"user","content":"weather?"},{"role":"assistant","content":[` + toolUse1 + `]},` +
+				`{"role":"user","content":[` + textBlock + `,` + toolResult1 + `]}]`,
+			want: `[{"role":"user","content":"weather?"},{"role":"assistant","content":[` + toolUse1 + `]},` +
+				`{"role":"user","content":[` + toolResult1 + `,` + textBlock + `]}]`,
+			wantFixes: 1,
+		},
+		{
+			name: "results of parallel tool calls across user messages",
+			messages: `[{"role":"user","content":"both?"},{"role":"assistant","content":[` + toolUse1 + `,` +
+				toolUse2 + `]},{"role":"user","content":[` + toolResult1 + `]},` +
+				`{"role":"user","content":[` + toolResult2 + `]}]`,
+			want: `[{"role":"user","content":"both?"},{"role":"assistant","content":[` + toolUse1 + `,` +
+				toolUse2 + `]},{"role":"user","content":[` + toolResult1 + `,` + toolResult2 + `]}]`,
+			wantFixes: 1,
+		},
+		{
+			name: "tool_use split across assistant messages",
+			messages: `[{"role":"user","content":"both?"},{"role":"assistant","content":[` + toolUse1 + `]},` +
+				`{"role":"assistant","content":[` + toolUse2 + `]},` +
+				`{"role":"user","content":[` + toolResult2 + `,` + toolResult1 + `]}]`,
+			want: `[{"role":"user","content":"both?"},{"role":"assistant","content":[` + toolUse1 + `,` +
+				toolUse2 + `]},{"role":"user","content":[` + toolResult2 + `,` + toolResult1 + `]}]`,
+			wantFixes: 1,
+		},
+		{
+			name: "orphaned tool_result",
+			messages: `[{"role":"user","content":[` + toolResult1 + `,` + textBlock + `]},` +
+				`{"role":"assistant","content":"ok"}]`,
+			want:      `[{"role":"user","content":[` + textBlock + `]},{"role":"assistant","content":"ok"}]`,
+			wantFixes: 1,
+		},
+		{
+			name: "tool_result answering a tool_use two messages back",
+			messages: `[{"role":"user","content":"weather?"},{"role":"assistant","content":[` + toolUse1 + `]},` +
+				`{"role":"user","content":[` + toolResult1 + `]},{"role":"assistant","content":"31°C"},` +
+				`{"role":"user","content":[` + toolResult1 + `,` + textBlock + `]}]`,
+			want: `[{"role":"user","content":"weather?"},{"role":"assistant","content":[` + toolUse1 + `]},` +
+				`{"role":"user","content":[` + toolResult1 + `]},{"role":"assistant","content":"31°C"},` +
+				`{"role":"user","content":[` + textBlock + `]}]`,
+			wantFixes: 1,
+		},
+		{
+			name: "tool_use_id not a string",
+			messages: `[{"role":"user","content":"weather?"},{"role":"assistant","content":"sure"},` +
+				`{"role":"user","content":[{"type":"tool_result","tool_use_id":1,"content":"x"},` + textBlock + `]}]`,
+			want: `[{"role":"user","content":"weather?"},{"role":"assistant","content":"sure"},` +
+				`{"role":"user","content":[` + textBlock + `]}]`,
+			wantFixes: 1,
+		},
+		{
+			name: "message emptied by an orphaned tool_result",
+			messages: `[{"role":"user","content":"hi"},{"role":"assistant","content":"hello"},` +
+				`{"role":"user","content":[` + toolResult1 + `]},{"role":"assistant","content":"again"}]`,
+			want: `[{"role":"user","content":"hi"},` +
+				`{"role":"assistant","content":[{"type":"text","text":"hello"},{"type":"text","text":"again"}]}]`,
+			wantFixes: 3, // Orphan dropped, empty message dropped, assistants merged
+		},
+		{
+			name: "large numbers in tool input and result",
+			messages: `[{"role":"user","content":"look up"},{"role":"assistant","content":[{"type":"tool_use",` +
+				`"id":"toolu_1","name":"lookup","input":{"order":123456789012345678901,"ratio":1.10}}]},` +
+				`{"role":"user","content":[` + textBlock + `,{"type":"tool_result","tool_use_id":"toolu_1",` +
+				`"content":[{"type":"text","text":"9007199254740993"}]}]}]`,
+			want: `[{"role":"user","content":"look up"},{"role":"assistant","content":[{"type":"tool_use",` +
+				`"id":"toolu_1","name":"lookup","input":{"order":123456789012345678901,"ratio":1.10}}]},` +
+				`{"role":"user","content":[{"type":"tool_result","tool_use_id":"toolu_1",` +
+				`"content":[{"type":"text","text":"9007199254740993"}]},` + textBlock + `]}]`,
+			wantFixes: 1,
+		},
+		{
+			name:     "trailing assistant tool_use is a prefill",
+			messages: `[{"role":"user","content":"weather?"},{"role":"assistant","content":[` + toolUse1 + `]}]`,
+		},
+		{
+			name: "unanswered tool_use",
+			messages: `[{"role":"user","content":"weather?"},{"role":"assistant","content":[` + toolUse1 + `]},` +
+				`{"role":"user","content":"never mind"}]`,
+			wantErr: `messages[1]: tool_use "toolu_1" has no tool_result`,
+		},
+		{
+			name: "one of parallel tool calls unanswered",
+			messages: `[{"role":"user","content":"both?"},{"role":"assistant","content":[` + toolUse1 + `,` +
+				toolUse2 + `]},{"role":"user","content":[` + toolResult1 + `]}]`,
+			wantErr: `tool_use "toolu_2" has no tool_result in the following user message (messages[2])`,
+		},
+		{
+			name: "tool_result answering an unknown id",
+			messages: `[{"role":"user","content":"weather?"},{"role":"assistant","content":[` + toolUse1 + `]},` +
+				`{"role":"user","content":[{"type":"tool_result","tool_use_id":"toolu_9","content":"x"}]}]`,
+			wantErr: `messages[1]: tool_use "toolu_1" has no tool_result (no user message with content follows it)`,
+		},
+		{
+			name:     "block without a type",
+			messages: `[{"role":"user","content":[{"tool_use_id":"toolu_1","content":"x"}]}]`,
+			wantErr:  "messages[0].content[0]: expected a content block with a type",
+		},
+		{
+			name:     "block not an object",
+			messages: `[{"role":"user","content":["toolu_1"]}]`,
+			wantErr:  "messages[0].content[0]: expected a content block with a type",
+		},
+		{
+			name:     "null content",
+			messages: `[{"role":"user","content":null}]`,
+			wantErr:  "messages[0].content: expected a string or an array of content blocks",
+		},
+		{
+			name:     "tool role",
+			messages: `[{"role":"user","content":"hi"},{"role":"tool","content":[` + toolResult1 + `]}]`,
+			wantErr:  `messages[1].role: expected "user" or "assistant", got tool`,
+		},
+		{
+			name:     "message not an object",
+			messages: `[{"role":"user","content":"hi"},"hello"]`,
+			wantErr:  "messages[1]: expected an object",
+		},
+		{
+			name:     "only empty content",
+			messages: `[{"role":"user","content":" "},{"role":"assistant","content":[]}]`,
+			wantErr:  "messages: no message with non-empty content",
+		},
+		{
+			name:     "only orphaned tool results",
+			messages: `[{"role":"user","content":[` + toolResult1 + `,` + toolResult2 + `]}]`,
+			wantErr:  "messages: no message with non-empty content",
+		},
+		{
+			name:     "messages not an array",
+			messages: `{"role":"user","content":"hi"}`,
+			wantErr:  "messages: expected an array of messages",
+		},
+	}
+
+	normalizer := NewMessageNormalizer(true)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := []byte(`{"model":"claude-sonnet-4", "max_tokens":10, "messages":` + tt.messages + `}`)
+			got, fixes, err := normalizer.Apply(body)
+			if tt.wantErr != "" {
+				if !isMessageSequenceError(err) || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Apply() error = %v, want a MessageSequenceError with %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Apply() error = %v", err)
+			}
+			if len(fixes) != tt.wantFixes {
+				t.Errorf("fixes = %q, want %d", fixes, tt.wantFixes)
+			}
+
+			if tt.want == "" {
+				if !bytes.Equal(got, body) {
+					t.Errorf("body = %s, want it untouched", got)
+				}
+				return
+			}
+			want := decodeMessages(t, []byte(`{"messages":`+tt.want+`}`))
+			if messages := decodeMessages(t, got); !reflect.DeepEqual(messages, want) {
+				t.Errorf("messages =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMessageNormalizerPassesThrough(t *testing.T) {
+	bodies := []string{
+		"",
+		"not json",
+		"null",
+		`{"model":"claude-sonnet-4"}`,
+		`[{"role":"user","content":[` + toolResult1 + `]}]`,
+	}
+	for _, body := range bodies {
+		got, fixes, err := NewMessageNormalizer(true).Apply([]byte(body))
+		if err != nil || fixes != nil || string(got) != body {
+			t.Errorf("Apply(%q) = %q, %q, %v; want it untouched", body, got, fixes, err)
+		}
+	}
+
+	// Disabled, even a broken sequence is Claude API's to report
+	body := `{"messages":[{"role":"tool","content":null}]}`
+	got, _, err := NewMessageNormalizer(false).Apply([]byte(body))
+	if err != nil || string(got) != body {
+		t.Errorf("disabled: Apply() = %q, %v; want it untouched", got, err)
+	}
+}
@@ -0,0 +1,154 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"claude-proxy/config"
+	proxyinterfaces "claude-proxy/modules/proxy/domain/interfaces"
+
+	sctx "github.com/phathdt/service-context"
+)
+
+// aliasCreatedAt is reported for config-defined models, which have no real release date
+var aliasCreatedAt = time.Unix(0, 0).UTC().Format(time.RFC3339)
+
+// ModelCatalog keeps the last successful GET /v1/models response and merges config-defined aliases into it
+type ModelCatalog struct {
+	repo    proxyinterfaces.ModelCacheRepository
+	aliases []config.ModelAliasConfig
+	cached  []byte // Raw upstream response body (without aliases)
+	mu      sync.RWMutex
+	logger  sctx.Logger
+}
+
+// NewModelCatalog creates a model catalog and loads the cached response from persistence
+func NewModelCatalog(
+	repo proxyinterfaces.ModelCacheRepository,
+	aliases []config.ModelAliasConfig,
+	logger sctx.Logger,
+) *ModelCatalog {
+	catalog := &ModelCatalog{
+		repo:    repo,
+		aliases: aliases,
+		logger:  logger,
+	}
+
+	cached, err := repo.Load(context.Background())
+	if err != nil {
+		// A missing or unreadable cache only disables the stale fallback until the next live call
+		logger.Withs(sctx.Fields{"error": err.Error()}).Warn("Failed to load cached models response")
+	} else {
+		catalog.cached = cached
+	}
+
+	return catalog
+}
+
+// Store records a successful upstream models response, persisting it when it changed
+func (c *ModelCatalog) Store(ctx context.Context, body []byte) {
+	if !json.Valid(body) {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if bytes.Equal(c.cached, body) {
+		return
+	}
+
+	if err := c.repo.Save(ctx, body); err != nil {
+		c.logger.Withs(sctx.Fields{"error": err.Error()}).Warn("Failed to persist models response")
+	}
+	c.cached = body
+}
+
+// Cached returns the last successful models response with aliases merged (false if none is cached)
+func (c *ModelCatalog) Cached() ([]byte, bool) {
+	c.mu.RLock()
+	cached := c.cached
+	c.mu.RUnlock()
+
+	if len(cached) == 0 {
+		return nil, false
+	}
+	return c.WithAliases(cached), true
+}
+
+// HasAliases returns true if config defines extra models
+func (c *ModelCatalog) HasAliases() bool {
+	return len(c.aliases) > 0
+}
+
+// WithAliases appends config-defined models missing from a models list response
+// Aliases go on the last page only; the body is returned unchanged if it is not a models list
+func (c *ModelCatalog) WithAliases(body []byte) []byte {
+	if len(c.aliases) == 0 {
+		return body
+	}
+
+	var list map[string]json.RawMessage
+	if err := json.Unmarshal(body, &list); err != nil {
+		return body
+	}
+	var models []json.RawMessage
+	if err := json.Unmarshal(list["data"], &models); err != nil {
+		return body
+	}
+	var hasMore bool
+	if raw, ok := list["has_more"]; ok && json.Unmarshal(raw, &hasMore) == nil && hasMore {
+		return body
+	}
+
+	listed := make(map[string]bool, len(models))
+	for _, model := range models {
+		var entry struct {
+			ID string `json:"id"`
+		}
+		if json.Unmarshal(model, &entry) == nil {
+			listed[entry.ID] = true
+		}
+	}
+
+	added := false
+	for _, alias := range c.aliases {
+		if alias.ID == "" || listed[alias.ID] {
+			continue
+		}
+		displayName := alias.DisplayName
+		if displayName == "" {
+			displayName = alias.ID
+		}
+		entry, err := json.Marshal(map[string]string{
+			"type":         "model",
+			"id":           alias.ID,
+			"display_name": displayName,
+			"created_at":   aliasCreatedAt,
+		})
+		if err != nil {
+			continue
+		}
+		models = append(models, entry)
+		listed[alias.ID] = true
+		added = true
+	}
+	if !added {
+		return body
+	}
+
+	data, err := json.Marshal(models)
+	if err != nil {
+		return body
+	}
+	list["data"] = data
+
+	merged, err := json.Marshal(list)
+	if err != nil {
+		return body
+	}
+	return merged
+}
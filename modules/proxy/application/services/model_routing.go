@@ -0,0 +1,153 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"claude-proxy/modules/auth/domain/entities"
+	"claude-proxy/pkg/errors"
+)
+
+// routedModel returns the model a request is routed by: the model its JSON body names, or the request shaping
+// default model for message requests naming none
+// It is "" (any account) unless an account restricts its models, so bodies are only read (and restored) then.
+// A model no account in the rotation serves is rejected with 400 before any account is selected.
+func (s *ProxyService) routedModel(ctx context.Context, req *http.Request) (string, error) {
+	if req.Method != http.MethodPost || req.Body == nil || isFileUpload(req) {
+		return "", nil
+	}
+
+	accounts, err := s.accountSvc.ListAccounts(ctx)
+	if err != nil {
+		return "", nil // GetValidAccount reports the failure
+	}
+	if _, all := servedModelPatterns(accounts); all {
+		return "", nil
+	}
+
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		return "", classifyBodyReadError(err)
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+
+	var payload struct {
+		Model string `json:"model"`
+	}
+	if json.Unmarshal(body, &payload) != nil {
+		return "", nil // Not a JSON object: Claude API reports the error
+	}
+	model := payload.Model
+	if model == "" && isMessageCreation(req.Method, req.URL.Path) {
+		model = s.shaper.defaultModel
+	}
+	if model == "" {
+		return "", nil
+	}
+
+	for _, account := range accounts {
+		if servesRotation(account) && account.SupportsModel(model) {
+			return model, nil
+		}
+	}
+	patterns, _ := servedModelPatterns(accounts)
+	available := "none"
+	if len(patterns) > 0 {
+		available = strings.Join(patterns, ", ")
+	}
+	return "", errors.NewBadRequestError(
+		ErrCodeModelNotSupported,
+		fmt.Sprintf("No account serves model %s", model),
+		"available models: "+available,
+	)
+}
+
+// servesRotation returns true if an account takes part in model routing: not a shadow account and neither
+// inactive, invalid nor conflicted (rate limited or over-quota accounts still serve their models once they recover)
+func servesRotation(account *entities.Account) bool {
+	return !account.Shadow && !account.IsDeleted() && !account.IsConflicted() &&
+		account.Status != entities.AccountStatusInactive && account.Status != entities.AccountStatusInvalid
+}
+
+// servedModelPatterns returns the union of the supported model patterns of the accounts in the rotation, and
+// true if one of them serves every model
+func servedModelPatterns(accounts []*entities.Account) ([]string, bool) {
+	var patterns []string
+	seen := make(map[string]bool)
+	for _, account := range accounts {
+		if !servesRotation(account) {
+			continue
+		}
+		if len(account.SupportedModels) == 0 {
+			return nil, true
+		}
+		for _, pattern := range account.SupportedModels {
+			if !seen[pattern] {
+				seen[pattern] = true
+				patterns = append(patterns, pattern)
+			}
+		}
+	}
+	return patterns, false
+}
+
+// filterModelsList keeps the entries of a models list response served by an account in the rotation
+// (proxy.filter_models); the body is returned unchanged when an account serves every model or it is not a
+// models list
+func (s *ProxyService) filterModelsList(ctx context.Context, body []byte) []byte {
+	if !s.filterModels {
+		return body
+	}
+	accounts, err := s.accountSvc.ListAccounts(ctx)
+	if err != nil {
+		return body
+	}
+	if _, all := servedModelPatterns(accounts); all {
+		return body
+	}
+
+	var list map[string]json.RawMessage
+	if err := json.Unmarshal(body, &list); err != nil {
+		return body
+	}
+	var models []json.RawMessage
+	if err := json.Unmarshal(list["data"], &models); err != nil {
+		return body
+	}
+
+	served := make([]json.RawMessage, 0, len(models))
+	for _, model := range models {
+		var entry struct {
+			ID string `json:"id"`
+		}
+		if json.Unmarshal(model, &entry) != nil {
+			continue
+		}
+		for _, account := range accounts {
+			if servesRotation(account) && account.SupportsModel(entry.ID) {
+				served = append(served, model)
+				break
+			}
+		}
+	}
+	if len(served) == len(models) {
+		return body
+	}
+
+	data, err := json.Marshal(served)
+	if err != nil {
+		return body
+	}
+	list["data"] = data
+
+	filtered, err := json.Marshal(list)
+	if err != nil {
+		return body
+	}
+	return filtered
+}
@@ -0,0 +1,61 @@
+package services
+
+import (
+	"sync"
+	"time"
+
+	proxyinterfaces "claude-proxy/modules/proxy/domain/interfaces"
+)
+
+// priorityTierMinutes is the number of one-minute buckets the selections are counted over
+const priorityTierMinutes = 60
+
+// priorityTierBucket holds the selections of each tier during one minute
+type priorityTierBucket struct {
+	minute int64 // Unix minute the counts belong to
+	counts map[int]int64
+}
+
+// PriorityTierTracker counts tier selections in a ring of one-minute buckets covering the last hour
+type PriorityTierTracker struct {
+	buckets [priorityTierMinutes]priorityTierBucket
+	mu      sync.Mutex
+}
+
+// NewPriorityTierTracker creates an empty tracker
+func NewPriorityTierTracker() proxyinterfaces.PriorityTierTracker {
+	return &PriorityTierTracker{}
+}
+
+// Record counts an account of tier priority selected at at
+func (t *PriorityTierTracker) Record(priority int, at time.Time) {
+	minute := at.Unix() / 60
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	bucket := &t.buckets[minute%priorityTierMinutes]
+	if bucket.minute != minute || bucket.counts == nil {
+		// The bucket still holds the counts of an hour ago (or none yet)
+		bucket.minute = minute
+		bucket.counts = make(map[int]int64)
+	}
+	bucket.counts[priority]++
+}
+
+// Selections returns the selections of each tier over the hour before now
+func (t *PriorityTierTracker) Selections(now time.Time) map[int]int64 {
+	current := now.Unix() / 60
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	selections := make(map[int]int64)
+	for _, bucket := range t.buckets {
+		if bucket.counts == nil || bucket.minute <= current-priorityTierMinutes || bucket.minute > current {
+			continue
+		}
+		for priority, count := range bucket.counts {
+			selections[priority] += count
+		}
+	}
+	return selections
+}
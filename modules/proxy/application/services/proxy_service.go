@@ -1,17 +1,27 @@
 package services
 
 import (
+	"bytes"
 	"context"
-	"encoding/json"
+	stderrors "errors"
 	"fmt"
 	"io"
+	"maps"
 	"net/http"
+	"slices"
+	"strings"
+	"sync"
 	"time"
 
 	"claude-proxy/modules/auth/domain/entities"
 	authinterfaces "claude-proxy/modules/auth/domain/interfaces"
+	proxyentities "claude-proxy/modules/proxy/domain/entities"
 	proxyinterfaces "claude-proxy/modules/proxy/domain/interfaces"
 	"claude-proxy/modules/proxy/infrastructure/clients"
+	"claude-proxy/pkg/errors"
+	"claude-proxy/pkg/httpproxy"
+	"claude-proxy/pkg/middleware"
+	"claude-proxy/pkg/requestid"
 
 	sctx "github.com/phathdt/service-context"
 )
@@ -21,6 +31,31 @@ type ProxyService struct {
 	accountSvc   authinterfaces.AccountService
 	claudeClient *clients.ClaudeAPIClient
 	sessionSvc   authinterfaces.SessionService
+	statsSvc     authinterfaces.StatisticsService
+	shaper       *RequestShaper
+	normalizer   *MessageNormalizer
+	thinking     *ThinkingFixer
+	models       *ModelCatalog
+	windowAware  bool     // Prefer the account with the least usage in its current 5-hour window
+	filterModels bool     // Limit models lists to the models some account serves
+	exemptPaths  []string // Paths that skip session creation and limits (glob patterns)
+	forwarded    bool     // Send Via and X-Forwarded-For upstream
+	breaker      proxyinterfaces.CircuitBreaker
+	metrics      proxyinterfaces.TrafficMetrics
+	history      proxyinterfaces.RequestHistory
+	webhooks     proxyinterfaces.WebhookDispatcher
+	batches      proxyinterfaces.BatchService
+	files        proxyinterfaces.FileService
+	queue        proxyinterfaces.RequestQueue
+	clock        proxyinterfaces.ClockMonitor
+	authGuard    proxyinterfaces.AuthFailureGuard
+	failures     proxyinterfaces.AccountFailureTracker
+	shadow       proxyinterfaces.ShadowMirror
+	captures     proxyinterfaces.FailureCapture
+	usage        proxyinterfaces.UpstreamUsage // Daily usage compared with the official usage reports
+	canary       proxyinterfaces.CanaryRouter
+	tiers        proxyinterfaces.PriorityTierTracker
+	touches      proxyinterfaces.SessionToucher
 	logger       sctx.Logger
 }
 
@@ -29,12 +64,62 @@ func NewProxyService(
 	accountSvc authinterfaces.AccountService,
 	claudeClient *clients.ClaudeAPIClient,
 	sessionSvc authinterfaces.SessionService,
+	statsSvc authinterfaces.StatisticsService,
+	shaper *RequestShaper,
+	normalizer *MessageNormalizer,
+	thinking *ThinkingFixer,
+	models *ModelCatalog,
+	windowAware bool,
+	filterModels bool,
+	exemptPaths []string,
+	forwardedHeaders bool,
+	breaker proxyinterfaces.CircuitBreaker,
+	metrics proxyinterfaces.TrafficMetrics,
+	history proxyinterfaces.RequestHistory,
+	webhooks proxyinterfaces.WebhookDispatcher,
+	batches proxyinterfaces.BatchService,
+	files proxyinterfaces.FileService,
+	queue proxyinterfaces.RequestQueue,
+	clock proxyinterfaces.ClockMonitor,
+	authGuard proxyinterfaces.AuthFailureGuard,
+	failures proxyinterfaces.AccountFailureTracker,
+	shadow proxyinterfaces.ShadowMirror,
+	captures proxyinterfaces.FailureCapture,
+	usage proxyinterfaces.UpstreamUsage,
+	canary proxyinterfaces.CanaryRouter,
+	tiers proxyinterfaces.PriorityTierTracker,
+	touches proxyinterfaces.SessionToucher,
 	logger sctx.Logger,
 ) proxyinterfaces.ProxyService {
 	return &ProxyService{
 		accountSvc:   accountSvc,
 		claudeClient: claudeClient,
 		sessionSvc:   sessionSvc,
+		statsSvc:     statsSvc,
+		shaper:       shaper,
+		normalizer:   normalizer,
+		thinking:     thinking,
+		models:       models,
+		windowAware:  windowAware,
+		filterModels: filterModels,
+		exemptPaths:  append(append([]string{}, DefaultSessionExemptPaths...), exemptPaths...),
+		forwarded:    forwardedHeaders,
+		breaker:      breaker,
+		metrics:      metrics,
+		history:      history,
+		webhooks:     webhooks,
+		batches:      batches,
+		files:        files,
+		queue:        queue,
+		clock:        clock,
+		authGuard:    authGuard,
+		failures:     failures,
+		shadow:       shadow,
+		captures:     captures,
+		usage:        usage,
+		canary:       canary,
+		tiers:        tiers,
+		touches:      touches,
 		logger:       logger,
 	}
 }
@@ -45,47 +130,303 @@ func (s *ProxyService) ProxyRequest(
 	token *entities.Token,
 	req *http.Request,
 ) (*http.Response, error) {
-	// Create/reuse session and check global limits (per client IP + UserAgent)
-	session, err := s.sessionSvc.CreateSession(ctx, token.ID, req)
+	if isModelsList(req.Method, req.URL.Path) {
+		return s.proxyModelsRequest(ctx, token, req)
+	}
+	return s.forwardRequest(ctx, token, req)
+}
+
+// proxyModelsRequest proxies GET /v1/models, caching successful responses and merging config aliases
+// When no account is available or the upstream call fails, the cached response is served instead
+func (s *ProxyService) proxyModelsRequest(
+	ctx context.Context,
+	token *entities.Token,
+	req *http.Request,
+) (*http.Response, error) {
+	resp, err := s.forwardRequest(ctx, token, req)
+
+	if err == nil && resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		// Paginated follow-up pages are not a full list, so only first pages refresh the cache
+		cacheable := req.URL.Query().Get("after_id") == "" && req.URL.Query().Get("before_id") == ""
+		if !cacheable && !s.models.HasAliases() && !s.filterModels {
+			return resp, nil
+		}
+
+		// The cache and alias merge work on JSON, so compressed responses are decoded here
+		body, readErr := readDecodedBody(resp)
+		if readErr != nil {
+			return nil, fmt.Errorf("failed to read models response: %w", readErr)
+		}
+		if cacheable {
+			s.models.Store(ctx, body)
+		}
+		setResponseBody(resp, s.filterModelsList(ctx, s.models.WithAliases(body)))
+		return resp, nil
+	}
+
+	// Client-side failures (canceled request, session limit, bad body) are never masked by the cache
+	if ctx.Err() != nil {
+		return resp, err
+	}
+	if err != nil && !isUpstreamUnavailable(err) {
+		return resp, err
+	}
+	if err == nil && resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode < 500 {
+		return resp, nil
+	}
+
+	cached, ok := s.models.Cached()
+	if !ok {
+		return resp, err
+	}
+
+	fields := sctx.Fields{"token_id": token.ID}
+	if err != nil {
+		fields["error"] = err.Error()
+	} else {
+		fields["status_code"] = resp.StatusCode
+		resp.Body.Close()
+	}
+	s.logger.Withs(fields).Warn("Serving cached models response")
+
+	stale := &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{},
+		Request:    req,
+	}
+	stale.Header.Set("Content-Type", "application/json")
+	stale.Header.Set("X-Proxy-Cache", "stale")
+	setResponseBody(stale, s.filterModelsList(ctx, cached))
+	return stale, nil
+}
+
+// setResponseBody replaces a response body, keeping the length headers consistent
+func setResponseBody(resp *http.Response, body []byte) {
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	resp.ContentLength = int64(len(body))
+	resp.Header.Del("Content-Length")
+}
+
+// forwardRequest selects an account and forwards the request to Claude API
+func (s *ProxyService) forwardRequest(
+	ctx context.Context,
+	token *entities.Token,
+	req *http.Request,
+) (*http.Response, error) {
+	start := time.Now()
+	// Every path below ends in exactly one saved sample, which also ends the in-flight period
+	s.metrics.RequestStarted()
+
+	// Malformed JSON bodies are rejected locally instead of costing an account request
+	if err := validateJSONBody(req); err != nil {
+		s.recordFailureAsync(token.ID, "", req, start, errorCodeOf(err))
+		return nil, err
+	}
+
+	// Create/reuse session and check global/per-token limits (per token + client IP + UserAgent)
+	// Lightweight endpoints (count_tokens, model listing) neither need a session slot nor take one
+	var session *entities.Session
+	var err error
+	if !s.isSessionExempt(req.URL.Path) {
+		session, err = s.sessionSvc.CreateSession(ctx, token, req)
+	}
 	if err != nil {
-		// If session limit exceeded, return error
+		// Session limit exceeded errors already carry their status, anything else is internal
+		if _, ok := err.(errors.AppError); !ok {
+			err = errors.NewInternalError(ErrCodeSessionCheckFailed, "Failed to check session limits", err.Error())
+		}
 		s.logger.Withs(sctx.Fields{
 			"error":    err.Error(),
 			"token_id": token.ID,
 		}).Warn("Session limit exceeded")
+		s.recordFailureAsync(token.ID, "", req, start, errorCodeOf(err))
 		return nil, err
 	}
 
-	// Defer session refresh to extend TTL
+	sessionID := ""
 	if session != nil {
-		defer func() {
-			if refreshErr := s.sessionSvc.RefreshSession(ctx, session.ID); refreshErr != nil {
-				s.logger.Withs(sctx.Fields{
-					"error":      refreshErr.Error(),
-					"session_id": session.ID,
-				}).Debug("Failed to refresh session")
+		sessionID = session.ID
+	}
+
+	// Requests about a known message batch go to the account owning it
+	if batchID := batchIDFromPath(req.URL.Path); batchID != "" {
+		if accountID, ok := s.batches.Owner(batchID); ok {
+			account, err := s.batchAccount(ctx, batchID, accountID)
+			if err != nil {
+				s.touches.Touch(sessionID)
+				s.recordFailureAsync(token.ID, "", req, start, ErrCodeBatchAccountGone)
+				return nil, err
 			}
-		}()
+			return s.forwardToAccount(ctx, token, req, start, sessionID, account)
+		}
 	}
 
-	// Get valid account (dynamic selection with automatic failover)
-	account, err := s.GetValidAccount(ctx)
+	// Requests about or referencing a known uploaded file go to the account holding it
+	fileID, accountID, ok, err := s.fileOwner(req)
 	if err != nil {
+		s.touches.Touch(sessionID)
+		s.recordFailureAsync(token.ID, "", req, start, errorCodeOf(err))
 		return nil, err
 	}
+	if ok {
+		account, err := s.fileAccount(ctx, fileID, accountID)
+		if err != nil {
+			s.touches.Touch(sessionID)
+			s.recordFailureAsync(token.ID, "", req, start, ErrCodeFileAccountGone)
+			return nil, err
+		}
+		return s.forwardToAccount(ctx, token, req, start, sessionID, account)
+	}
 
-	// Get valid access token (will refresh if needed)
-	accessToken, err := s.accountSvc.GetValidToken(ctx, account.ID)
+	// Accounts restricted to some models only get requests for them
+	model, err := s.routedModel(ctx, req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get valid access token: %w", err)
+		s.touches.Touch(sessionID)
+		s.recordFailureAsync(token.ID, "", req, start, errorCodeOf(err))
+		return nil, err
 	}
 
-	sessionID := ""
-	if session != nil {
-		sessionID = session.ID
+	// With canary routing, the session (or the token without one) decides which accounts may serve it
+	cohortKey := sessionID
+	if cohortKey == "" {
+		cohortKey = token.ID
 	}
+	cohort := s.canary.Cohort(cohortKey)
 
+	// Get valid account (dynamic selection with automatic failover), waiting for one to recover if queueing
+	account, err := s.GetValidAccount(ctx, model, cohort)
+	if err != nil {
+		account, err = s.waitForAccount(ctx, req, model, cohort, err)
+	}
+	if err != nil {
+		s.touches.Touch(sessionID)
+		s.recordFailureAsync(token.ID, "", req, start, errorCodeOf(err))
+		return nil, err
+	}
+
+	return s.forwardToAccount(ctx, token, req, start, sessionID, account)
+}
+
+// queueRetryDelay is how long after an account's expected recovery a queued request retries selection
+const queueRetryDelay = 100 * time.Millisecond
+
+// waitForAccount handles a failed account selection
+// With proxy.max_queue_wait the request waits for the first rate limited or over-quota account to recover
+// (retrying selection then) when that is within the maximum wait and the queue has room; otherwise it gets
+// 429 at once. Without queueing, or when no account recovers on its own, it gets 503. Nothing has been
+// written to the client yet, so streaming requests wait the same way.
+func (s *ProxyService) waitForAccount(
+	ctx context.Context,
+	req *http.Request,
+	model string,
+	cohort proxyentities.Cohort,
+	selectErr error,
+) (*entities.Account, error) {
+	recoveryAt, recovers := s.nextAccountRecovery(ctx, model)
+	if !s.queue.Enabled() || !recovers {
+		appErr := errors.NewProxyError(
+			http.StatusServiceUnavailable, ErrCodeNoAvailableAccount, "No account available", selectErr.Error(),
+		)
+		// Tell clients when the first rate limited or over-quota account is expected back
+		if recovers {
+			appErr = errors.WithHeaders(appErr, httpproxy.RateLimitHeaders(0, recoveryAt, time.Now()))
+		}
+		return nil, appErr
+	}
+
+	// The server only notices a client disconnect once the request body has been read, so buffer it first
+	if req.Body != nil {
+		body, err := io.ReadAll(req.Body)
+		if err != nil {
+			return nil, classifyBodyReadError(err)
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	deadline, err := s.queue.Enter(recoveryAt)
+	if err != nil {
+		code := ErrCodeAccountsRateLimited
+		if stderrors.Is(err, proxyentities.ErrQueueFull) {
+			code = ErrCodeQueueFull
+		}
+		return nil, accountsRateLimitedError(code, err.Error(), recoveryAt)
+	}
+
+	entered := time.Now()
 	s.logger.Withs(sctx.Fields{
+		"request_id":  requestid.FromContext(ctx),
+		"recovery_at": recoveryAt.Format(time.RFC3339),
+	}).Debug("No account available, request queued")
+
+	for {
+		timer := time.NewTimer(time.Until(recoveryAt) + queueRetryDelay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			s.queue.Leave(time.Since(entered), proxyentities.QueueOutcomeCanceled)
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+
+		account, err := s.GetValidAccount(ctx, model, cohort)
+		if err == nil {
+			waited := time.Since(entered)
+			s.queue.Leave(waited, proxyentities.QueueOutcomeServed)
+			s.logger.Withs(sctx.Fields{
+				"request_id": requestid.FromContext(ctx),
+				"account_id": account.ID,
+				"waited_ms":  waited.Milliseconds(),
+			}).Info("Queued request got an account")
+			return account, nil
+		}
+
+		// Another request may have taken the recovered account's capacity: wait for the next recovery
+		recoveryAt, recovers = s.nextAccountRecovery(ctx, model)
+		if !recovers || recoveryAt.After(deadline) {
+			s.queue.Leave(time.Since(entered), proxyentities.QueueOutcomeTimedOut)
+			if !recovers {
+				return nil, errors.NewProxyError(
+					http.StatusServiceUnavailable, ErrCodeNoAvailableAccount, "No account available", err.Error(),
+				)
+			}
+			return nil, accountsRateLimitedError(ErrCodeAccountsRateLimited, err.Error(), recoveryAt)
+		}
+	}
+}
+
+// accountsRateLimitedError is the 429 returned when a request can't wait for an account, with Retry-After
+// set to when the first one is expected back
+func accountsRateLimitedError(code, details string, recoveryAt time.Time) errors.AppError {
+	return errors.WithHeaders(
+		errors.NewProxyError(http.StatusTooManyRequests, code, "All accounts are rate limited", details),
+		httpproxy.RateLimitHeaders(0, recoveryAt, time.Now()),
+	)
+}
+
+// forwardToAccount forwards the request with the selected account's credentials
+// start is when the request started; sessionID is the session it counts toward ("" if exempt)
+func (s *ProxyService) forwardToAccount(
+	ctx context.Context,
+	token *entities.Token,
+	req *http.Request,
+	start time.Time,
+	sessionID string,
+	account *entities.Account,
+) (*http.Response, error) {
+	// Get valid access token (will refresh if needed)
+	accessToken, err := s.accountSvc.GetValidToken(ctx, account.ID)
+	if err != nil {
+		s.breaker.Release(account.ID)
+		s.touches.Touch(sessionID)
+		s.recordFailureAsync(token.ID, account.ID, req, start, ErrCodeAccountTokenFailed)
+		return nil, errors.NewProxyError(
+			http.StatusServiceUnavailable, ErrCodeAccountTokenFailed, "Failed to get valid access token", err.Error(),
+		)
+	}
+
+	requestID := requestid.FromContext(ctx)
+	s.logger.Withs(sctx.Fields{
+		"request_id":   requestID,
 		"token_id":     token.ID,
 		"token_name":   token.Name,
 		"account_id":   account.ID,
@@ -96,20 +437,104 @@ func (s *ProxyService) ProxyRequest(
 		"path":         req.URL.Path,
 	}).Info("Proxying request to Claude API")
 
-	// Read request body
+	// Read request body; file uploads are streamed upstream as they arrive instead
 	var bodyBytes []byte
-	if req.Body != nil {
+	upload := isFileUpload(req)
+	if req.Body != nil && !upload {
 		bodyBytes, err = io.ReadAll(req.Body)
 		if err != nil {
-			return nil, fmt.Errorf("failed to read request body: %w", err)
+			appErr := classifyBodyReadError(err)
+			s.breaker.Release(account.ID)
+			s.touches.Touch(sessionID)
+			s.recordFailureAsync(token.ID, account.ID, req, start, appErr.ErrorCode())
+			return nil, appErr
+		}
+	}
+
+	// Identify the end user: the client's metadata.user_id, or one taken from the token's configured header
+	var userID string
+	if len(bodyBytes) > 0 && isMessageCreation(req.Method, req.URL.Path) {
+		var headerValue string
+		if token.ExternalUserIDHeader != "" {
+			headerValue = strings.TrimSpace(req.Header.Get(token.ExternalUserIDHeader))
+		}
+		bodyBytes, userID, err = endUserID(bodyBytes, headerValue)
+		if err != nil {
+			s.breaker.Release(account.ID)
+			s.touches.Touch(sessionID)
+			s.recordFailureAsync(token.ID, account.ID, req, start, ErrCodeRequestRewriteFailed)
+			return nil, errors.NewInternalError(ErrCodeRequestRewriteFailed, "Failed to set end user ID", err.Error())
 		}
 	}
 
-	// Validate and fix extended thinking parameters if needed
+	// Apply configured request shaping to message creation (before thinking validation)
+	if len(bodyBytes) > 0 && isMessageCreation(req.Method, req.URL.Path) {
+		var fired []string
+		bodyBytes, fired, err = s.shaper.Apply(bodyBytes)
+		if err != nil {
+			s.breaker.Release(account.ID)
+			s.touches.Touch(sessionID)
+			s.recordFailureAsync(token.ID, account.ID, req, start, ErrCodeRequestRewriteFailed)
+			return nil, errors.NewInternalError(ErrCodeRequestRewriteFailed, "Failed to shape request", err.Error())
+		}
+		if len(fired) > 0 {
+			s.logger.Withs(sctx.Fields{
+				"token_id":   token.ID,
+				"transforms": strings.Join(fired, ","),
+			}).Debug("Request shaping applied")
+		}
+	}
+
+	// Repair invalid message sequences; reject locally what can't be repaired
+	if len(bodyBytes) > 0 && isMessageCreation(req.Method, req.URL.Path) {
+		var fixes []string
+		bodyBytes, fixes, err = s.normalizer.Apply(bodyBytes)
+		if err != nil {
+			s.breaker.Release(account.ID)
+			s.touches.Touch(sessionID)
+			if isMessageSequenceError(err) {
+				s.recordFailureAsync(token.ID, account.ID, req, start, ErrCodeInvalidMessages)
+				return nil, errors.NewBadRequestError(ErrCodeInvalidMessages, "Invalid message sequence", err.Error())
+			}
+			s.recordFailureAsync(token.ID, account.ID, req, start, ErrCodeRequestRewriteFailed)
+			return nil, errors.NewInternalError(
+				ErrCodeRequestRewriteFailed, "Failed to normalize messages", err.Error(),
+			)
+		}
+		for _, fix := range fixes {
+			s.logger.Withs(sctx.Fields{
+				"token_id": token.ID,
+				"fix":      fix,
+			}).Debug("Message sequence normalized")
+		}
+	}
+
+	// Validate extended thinking parameters, fixing or rejecting them per proxy.thinking_fix
 	if len(bodyBytes) > 0 {
-		bodyBytes, err = s.validateAndFixThinkingParams(bodyBytes)
+		var fix *ThinkingFix
+		bodyBytes, fix, err = s.thinking.Apply(bodyBytes)
 		if err != nil {
-			return nil, fmt.Errorf("failed to validate request parameters: %w", err)
+			s.breaker.Release(account.ID)
+			s.touches.Touch(sessionID)
+			if isThinkingParamsError(err) {
+				s.recordFailureAsync(token.ID, account.ID, req, start, ErrCodeInvalidThinkingParams)
+				return nil, errors.NewBadRequestError(
+					ErrCodeInvalidThinkingParams, "Invalid extended thinking parameters", err.Error(),
+				)
+			}
+			s.recordFailureAsync(token.ID, account.ID, req, start, ErrCodeRequestRewriteFailed)
+			return nil, errors.NewInternalError(
+				ErrCodeRequestRewriteFailed, "Failed to validate request parameters", err.Error(),
+			)
+		}
+		if fix != nil {
+			s.logger.Withs(sctx.Fields{
+				"token_id":            token.ID,
+				"original_max_tokens": fix.MaxTokens,
+				"budget_tokens":       fix.BudgetTokens,
+				"adjusted_max_tokens": fix.AdjustedMaxTokens,
+				"buffer_added":        fix.Buffer,
+			}).Warn("Auto-corrected max_tokens for extended thinking mode - max_tokens must be greater than budget_tokens")
 		}
 	}
 
@@ -119,33 +544,473 @@ func (s *ProxyService) ProxyRequest(
 		path += "?" + req.URL.RawQuery
 	}
 
-	// Proxy the request - only pass access token and body, headers are built in claude_client
-	resp, err := s.claudeClient.ProxyRequest(ctx, req.Method, path, accessToken, bodyBytes)
+	// Proxy the request - only pass access token, body and the negotiable Accept-Encoding,
+	// other headers are built in claude_client (Content-Length included: it always matches the
+	// possibly rewritten body). Headers the client marked hop-by-hop are never forwarded, and neither
+	// is its User-Agent: upstream sees the configured identification headers, or the account's overrides.
+	clientHeader := req.Header.Clone()
+	httpproxy.RemoveHopByHop(clientHeader)
+	headers := maps.Clone(account.Headers)
+	if headers == nil {
+		headers = map[string]string{}
+	}
+	// Streams closed with a usage summary must stay uncompressed for the event to be appended
+	usageSummary := wantsUsageSummary(req, token.UsageSummary)
+	acceptEncoding := forwardedAcceptEncoding(clientHeader.Get("Accept-Encoding"))
+	if acceptEncoding != "" && !usageSummary {
+		headers["Accept-Encoding"] = acceptEncoding
+	}
+	if s.forwarded {
+		via := append(clientHeader.Values("Via"), httpproxy.ViaEntry(req.ProtoMajor, req.ProtoMinor))
+		headers["Via"] = strings.Join(via, ", ")
+		if forwardedFor := httpproxy.ForwardedFor(req); forwardedFor != "" {
+			headers["X-Forwarded-For"] = forwardedFor
+		}
+	}
+	// The Files API and messages referencing uploaded files are behind a beta flag clients can't send
+	if isFileRequest(req.URL.Path) || (mayReferenceFiles(req.Method, req.URL.Path) && len(fileIDsInBody(bodyBytes)) > 0) {
+		withFilesAPIBeta(headers, account.Headers["anthropic-beta"])
+	}
+	// A sample of message requests is copied to shadow accounts in the background, never delaying this one
+	if isMessageCreation(req.Method, req.URL.Path) && len(bodyBytes) > 0 {
+		s.shadow.Mirror(token.ID, req.URL.Path, bodyBytes)
+	}
+
+	upstreamStart := time.Now()
+	credential := clients.Credential{Secret: accessToken, APIKey: account.IsAPIKey()}
+	var resp *http.Response
+	if upload {
+		resp, err = s.claudeClient.ProxyUpload(
+			ctx, req.Method, path, credential, req.Body, req.Header.Get("Content-Type"),
+			account.BaseURL, account.ProxyURL, headers,
+		)
+	} else {
+		resp, err = s.claudeClient.ProxyRequest(
+			ctx, req.Method, path, credential, bodyBytes, account.BaseURL, account.ProxyURL, headers,
+		)
+	}
 	if err != nil {
+		s.touches.Touch(sessionID)
+
+		// A canceled client request is not an upstream failure: return the context error as-is
+		if ctx.Err() == context.Canceled {
+			s.breaker.Release(account.ID)
+			s.recordFailureAsync(token.ID, account.ID, req, start, "")
+			return nil, ctx.Err()
+		}
+		s.breaker.Record(account.ID, time.Since(upstreamStart), true)
+
+		appErr := classifyTransportError(err)
 		s.logger.Withs(sctx.Fields{
 			"error":      err.Error(),
+			"error_code": appErr.ErrorCode(),
+			"request_id": requestID,
 			"token_id":   token.ID,
 			"account_id": account.ID,
 		}).Error("Failed to proxy request")
-		return nil, fmt.Errorf("failed to proxy request: %w", err)
+		s.recordFailureAsync(token.ID, account.ID, req, start, appErr.ErrorCode())
+		if !upload {
+			s.captureFailure(token, account.ID, req, path, headers, bodyBytes, 0, appErr.ErrorCode(), "")
+		}
+
+		// Connection failures through an egress proxy are recorded on that account only
+		if account.ProxyURL != "" && ctx.Err() == nil {
+			s.recordEgressErrorAsync(account, err)
+		}
+		return nil, appErr
 	}
 
+	// Claude API's request-id (relayed to the client with the rest of the headers) is logged next to ours,
+	// so an upstream support request can be traced back to the proxied one
 	s.logger.Withs(sctx.Fields{
-		"status_code": resp.StatusCode,
-		"token_id":    token.ID,
-		"account_id":  account.ID,
+		"status_code":         resp.StatusCode,
+		"request_id":          requestID,
+		"upstream_request_id": resp.Header.Get(requestid.UpstreamHeader),
+		"token_id":            token.ID,
+		"account_id":          account.ID,
 	}).Info("Received response from Claude API")
 
+	if resp.StatusCode >= 400 && !upload {
+		s.captureFailure(
+			token, account.ID, req, path, headers, bodyBytes, resp.StatusCode, "",
+			resp.Header.Get(requestid.UpstreamHeader),
+		)
+	}
+
+	// Feed the account's circuit breaker (client errors and rate limits say nothing about account health)
+	s.breaker.Record(account.ID, time.Since(upstreamStart), resp.StatusCode >= 500)
+
+	// The Date header was stamped as the response headers were sent, so it is measured against their arrival
+	received := time.Now()
+	s.clock.Observe(resp.Header.Get("Date"), received, received, proxyentities.ClockSourceUpstream)
+
+	// Rejected credentials may mean a revoked account, or clock skew / an upstream outage hitting every account;
+	// a run of permission errors means the account itself can no longer be used (e.g. suspended organization)
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized:
+		s.recordUnauthorizedAsync(account)
+	case resp.StatusCode == http.StatusForbidden:
+		s.recordAccountFailureAsync(account, resp.StatusCode)
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		s.authGuard.RecordSuccess(account.ID)
+		s.failures.RecordSuccess(account.ID)
+	}
+
+	// Pin created batches to this account and follow their status
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 && isBatchRequest(req.URL.Path) {
+		if err := s.trackBatchResponse(req, resp, account.ID, token.ID); err != nil {
+			s.touches.Touch(sessionID)
+			appErr := classifyTransportError(err)
+			s.recordFailureAsync(token.ID, account.ID, req, start, appErr.ErrorCode())
+			return nil, appErr
+		}
+	}
+
+	// Pin uploaded files to this account and forget deleted ones
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 && isFileRequest(req.URL.Path) {
+		if err := s.trackFileResponse(req, resp, account.ID, token.ID); err != nil {
+			s.touches.Touch(sessionID)
+			appErr := classifyTransportError(err)
+			s.recordFailureAsync(token.ID, account.ID, req, start, appErr.ErrorCode())
+			return nil, appErr
+		}
+	}
+
+	// Keep the account's usage window in step with the reset time Claude API reports (any status, 429 included)
+	if resetAt, ok := usageWindowResetFromHeaders(resp.Header); ok {
+		s.syncUsageWindowAsync(account.ID, resetAt)
+	}
+
+	// Track token usage of successful responses once the body has been relayed:
+	// message requests count toward the account quota, every request feeds the token's statistics
+	latency := time.Since(start)
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		streaming := strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream")
+		accountID := account.ID
+		statusCode := resp.StatusCode
+		countsQuota := countsTowardQuota(req.URL.Path)
+		method, requestPath := req.Method, req.URL.Path
+		encoding := resp.Header.Get("Content-Encoding")
+		tracker := newUsageTrackingBody(resp.Body, streaming, encoding, func(usage proxyentities.Usage, model string) {
+			if countsQuota {
+				s.recordUsageAsync(accountID, usage)
+			}
+			s.recordSample(token.ID, accountID, method, requestPath, start, latency, statusCode, usage, model, userID)
+		})
+		if streaming && usageSummary {
+			resp.Header.Del("Content-Length")
+			resp.ContentLength = -1
+			tracker.withTrailer(func(usage proxyentities.Usage, model string) []byte {
+				return usageSummaryEvent(accountID, usage, model, time.Since(start))
+			})
+		}
+		resp.Body = tracker
+	} else {
+		s.recordSample(
+			token.ID, account.ID, req.Method, req.URL.Path, start, latency, resp.StatusCode, proxyentities.Usage{}, "",
+			userID,
+		)
+	}
+
+	// Record session activity once the response body has been fully relayed and closed,
+	// so long streaming responses keep the session alive without adding latency
+	if sessionID != "" {
+		resp.Body = &sessionTouchingBody{
+			ReadCloser: resp.Body,
+			onClose:    func() { s.touches.Touch(sessionID) },
+		}
+	}
+
 	return resp, nil
 }
 
+// captureFailure keeps the envelope of a request Claude API failed or rejected, when its token has
+// capture_failures: what was sent upstream minus credentials, with the body capped at 64KB
+func (s *ProxyService) captureFailure(
+	token *entities.Token,
+	accountID string,
+	req *http.Request,
+	path string,
+	headers map[string]string,
+	body []byte,
+	statusCode int,
+	errorCode string,
+	upstreamRequestID string,
+) {
+	if !token.CaptureFailures {
+		return
+	}
+
+	capture := proxyentities.CapturedRequest{
+		Timestamp:         time.Now(),
+		TokenID:           token.ID,
+		AccountID:         accountID,
+		Method:            req.Method,
+		Path:              path,
+		Headers:           httpproxy.WithoutManagedHeaders(headers),
+		StatusCode:        statusCode,
+		Error:             errorCode,
+		UpstreamRequestID: upstreamRequestID,
+	}
+	if capture.Error == "" {
+		capture.Error = http.StatusText(statusCode)
+	}
+	// Copied so a large body isn't kept alive by the capture
+	if len(body) > proxyentities.MaxCapturedBodySize {
+		body = body[:proxyentities.MaxCapturedBodySize]
+		capture.BodyTruncated = true
+	}
+	capture.Body = bytes.Clone(body)
+	s.captures.Record(capture)
+}
+
+// recordUsageAsync adds a completed request's usage to the account's daily usage, and to its quota window in
+// the background
+func (s *ProxyService) recordUsageAsync(accountID string, usage proxyentities.Usage) {
+	s.usage.RecordLocal(accountID, usage, time.Now())
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		if err := s.accountSvc.RecordUsage(ctx, accountID, entities.TokenUsage{
+			InputTokens:         usage.InputTokens,
+			OutputTokens:        usage.OutputTokens,
+			CacheCreationTokens: usage.CacheCreationInputTokens,
+			CacheReadTokens:     usage.CacheReadInputTokens,
+		}); err != nil {
+			s.logger.Withs(sctx.Fields{
+				"error":      err.Error(),
+				"account_id": accountID,
+			}).Warn("Failed to record account usage")
+		}
+	}()
+}
+
+// syncUsageWindowAsync aligns the account's usage window with an upstream reset hint in the background
+func (s *ProxyService) syncUsageWindowAsync(accountID string, resetAt time.Time) {
+	go func() {
+		if err := s.accountSvc.SyncUsageWindow(context.Background(), accountID, resetAt); err != nil {
+			s.logger.Withs(sctx.Fields{
+				"account_id": accountID,
+				"error":      err.Error(),
+			}).Warn("Failed to sync account usage window")
+		}
+	}()
+}
+
+// recordFailureAsync records a request that failed before an upstream response was received, in the background
+// errorCode is the proxy error classification (empty for client cancellations); accountID is empty when
+// the request failed before an account was selected
+func (s *ProxyService) recordFailureAsync(
+	tokenID, accountID string,
+	req *http.Request,
+	start time.Time,
+	errorCode string,
+) {
+	latency := time.Since(start)
+	method, path := req.Method, req.URL.Path
+	go func() {
+		sample := newRequestSample(tokenID, start, latency, 0, proxyentities.Usage{})
+		sample.ErrorCode = errorCode
+		s.saveSample(sample, accountID, method, path, proxyentities.Usage{}, "")
+	}()
+}
+
+// recordSample adds a request sample to the token's usage statistics
+// model is the model named by the response and userID the request's end user (empty if unknown)
+func (s *ProxyService) recordSample(
+	tokenID, accountID string,
+	method, path string,
+	start time.Time,
+	latency time.Duration,
+	statusCode int,
+	usage proxyentities.Usage,
+	model, userID string,
+) {
+	sample := newRequestSample(tokenID, start, latency, statusCode, usage)
+	sample.UserID = userID
+	s.saveSample(sample, accountID, method, path, usage, model)
+}
+
+// newRequestSample builds a usage statistics sample for a proxied request
+func newRequestSample(
+	tokenID string,
+	start time.Time,
+	latency time.Duration,
+	statusCode int,
+	usage proxyentities.Usage,
+) *entities.RequestSample {
+	return &entities.RequestSample{
+		TokenID:      tokenID,
+		Timestamp:    start,
+		StatusCode:   statusCode,
+		Latency:      latency,
+		InputTokens:  usage.InputTokens,
+		OutputTokens: usage.OutputTokens,
+
+		CacheCreationTokens: usage.CacheCreationInputTokens,
+		CacheReadTokens:     usage.CacheReadInputTokens,
+	}
+}
+
+// newAccountRequest builds the request history entry of a sample
+func newAccountRequest(sample *entities.RequestSample, method, path, model string) proxyentities.AccountRequest {
+	request := proxyentities.AccountRequest{
+		Timestamp:  sample.Timestamp,
+		TokenID:    sample.TokenID,
+		Method:     method,
+		Path:       path,
+		Model:      model,
+		StatusCode: sample.StatusCode,
+		Latency:    sample.Latency,
+		Error:      sample.ErrorCode,
+	}
+	if request.Error == "" && sample.StatusCode >= 400 {
+		request.Error = http.StatusText(sample.StatusCode)
+	}
+	return request
+}
+
+// saveSample records a sample in the traffic metrics, the account's request history and the statistics
+// service, and publishes it as a webhook usage event (queued, never blocking)
+func (s *ProxyService) saveSample(
+	sample *entities.RequestSample,
+	accountID string,
+	method, path string,
+	usage proxyentities.Usage,
+	model string,
+) {
+	s.metrics.RequestFinished(sample)
+	s.canary.Record(accountID, sample)
+	if accountID != "" {
+		s.history.Record(accountID, newAccountRequest(sample, method, path, model))
+	}
+	eventType := proxyentities.WebhookEventRequestFailed
+	if sample.StatusCode >= 200 && sample.StatusCode < 300 {
+		eventType = proxyentities.WebhookEventRequestCompleted
+	}
+	s.webhooks.Publish(proxyentities.UsageEvent{
+		Type:       eventType,
+		Timestamp:  sample.Timestamp,
+		TokenID:    sample.TokenID,
+		AccountID:  accountID,
+		Model:      model,
+		StatusCode: sample.StatusCode,
+		ErrorCode:  sample.ErrorCode,
+		Latency:    sample.Latency,
+		Usage:      usage,
+	})
+	if err := s.statsSvc.RecordRequest(context.Background(), sample); err != nil {
+		s.logger.Withs(sctx.Fields{
+			"error":    err.Error(),
+			"token_id": sample.TokenID,
+		}).Debug("Failed to record request statistics")
+	}
+}
+
+// recordEgressErrorAsync records an egress proxy failure on the account in the background
+func (s *ProxyService) recordEgressErrorAsync(account *entities.Account, proxyErr error) {
+	accountID := account.ID
+	errMsg := fmt.Sprintf("egress proxy %s connection failed: %v", account.MaskedProxyURL(), proxyErr)
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		if err := s.accountSvc.RecordEgressError(ctx, accountID, errMsg); err != nil {
+			s.logger.Withs(sctx.Fields{
+				"error":      err.Error(),
+				"account_id": accountID,
+			}).Warn("Failed to record egress proxy error")
+		}
+	}()
+}
+
+// recordUnauthorizedAsync hands an upstream 401 to the auth failure guard in the background
+func (s *ProxyService) recordUnauthorizedAsync(account *entities.Account) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		s.authGuard.RecordUnauthorized(ctx, account)
+	}()
+}
+
+// recordAccountFailureAsync counts an upstream failure against the account in the background
+func (s *ProxyService) recordAccountFailureAsync(account *entities.Account, statusCode int) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		s.failures.RecordFailure(ctx, account, statusCode)
+	}()
+}
+
+// countsTowardQuota returns true for message creation endpoints
+// Other endpoints (count_tokens, models) are free and never consume account quota
+func countsTowardQuota(path string) bool {
+	return strings.HasSuffix(strings.TrimSuffix(path, "/"), "/messages")
+}
+
+// isMessageCreation returns true for POST /v1/messages (not count_tokens or batches)
+func isMessageCreation(method, path string) bool {
+	return method == http.MethodPost && strings.TrimSuffix(path, "/") == "/v1/messages"
+}
+
+// DefaultSessionExemptPaths never create or use a session: they are cheap and often called ahead of the
+// real request (prompt sizing, model discovery), so a client at its session limit can still reach them
+var DefaultSessionExemptPaths = []string{
+	"/v1/messages/count_tokens",
+	"/v1/models",
+	"/v1/models/*",
+}
+
+// isSessionExempt returns true if requests to path skip session creation and limits
+func (s *ProxyService) isSessionExempt(path string) bool {
+	for _, pattern := range s.exemptPaths {
+		if middleware.MatchPathPattern(pattern, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// isModelsList returns true for GET /v1/models (not a single model lookup)
+func isModelsList(method, path string) bool {
+	return method == http.MethodGet && strings.TrimSuffix(path, "/") == "/v1/models"
+}
+
+// sessionTouchingBody wraps an upstream response body and fires onClose exactly once when closed
+type sessionTouchingBody struct {
+	io.ReadCloser
+	once    sync.Once
+	onClose func()
+}
+
+// Close closes the underlying body and records session activity
+func (b *sessionTouchingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.onClose)
+	return err
+}
+
 // GetValidAccount returns a valid active account using enhanced load balancing
-// Priority:
+// Preference within a priority tier:
 // 1. Healthy active accounts (not needing refresh)
 // 2. Active accounts that need refresh
 // 3. Recently recovered rate-limited accounts
-// Excludes: rate_limited (not expired), invalid, inactive
-func (s *ProxyService) GetValidAccount(ctx context.Context) (*entities.Account, error) {
+// Within the chosen group, proxy.window_aware picks the least-consumed usage window instead of round-robin
+// Excludes: rate_limited (not expired), invalid, inactive, expired access token with a recently failed refresh,
+// over usage quota, open circuit breaker, new accounts in their cooldown, shadow accounts, accounts outside their
+// availability windows, and accounts not serving model ("" = any model)
+// With canary routing, the available accounts are then narrowed to those of the cohort (all of them if it has none)
+// Accounts are then tried by priority tier, lowest first: a tier only serves when every better one has no account
+// left to select
+func (s *ProxyService) GetValidAccount(
+	ctx context.Context,
+	model string,
+	cohort proxyentities.Cohort,
+) (*entities.Account, error) {
 	// Get all accounts (not just active)
 	allAccounts, err := s.accountSvc.ListAccounts(ctx)
 	if err != nil {
@@ -156,36 +1021,78 @@ func (s *ProxyService) GetValidAccount(ctx context.Context) (*entities.Account,
 		return nil, fmt.Errorf("no accounts available")
 	}
 
-	// Filter available accounts (active or rate-limit expired)
+	// Filter available accounts (active or rate-limit expired, within usage quota)
 	var availableAccounts []*entities.Account
+	overQuotaCount := 0
+	breakerOpenCount := 0
+	refreshFailingCount := 0
+	coolingDownCount := 0
+	shadowCount := 0
+	otherModelsCount := 0
+	unavailableCount := 0
+	now := time.Now()
 	for _, acc := range allAccounts {
-		if acc.IsAvailableForProxy() {
-			availableAccounts = append(availableAccounts, acc)
+		if acc.Shadow {
+			shadowCount++
+			continue
 		}
+		if !acc.SupportsModel(model) {
+			otherModelsCount++
+			continue
+		}
+		if acc.IsCoolingDown() {
+			coolingDownCount++
+			continue
+		}
+		if !acc.IsAvailableForProxy() {
+			continue
+		}
+		if !acc.IsWithinAvailability(now) {
+			unavailableCount++
+			continue
+		}
+		if !acc.IsUsableNow() {
+			refreshFailingCount++
+			continue
+		}
+		if acc.IsOverQuota() {
+			overQuotaCount++
+			continue
+		}
+		if !s.breaker.Selectable(acc.ID) {
+			breakerOpenCount++
+			continue
+		}
+		availableAccounts = append(availableAccounts, acc)
 	}
 
 	if len(availableAccounts) == 0 {
+		if overQuotaCount > 0 || breakerOpenCount > 0 || refreshFailingCount > 0 || coolingDownCount > 0 ||
+			shadowCount > 0 || otherModelsCount > 0 || unavailableCount > 0 {
+			return nil, fmt.Errorf(
+				"no available accounts (%d over usage quota, %d circuit breaker open, "+
+					"%d expired with a failing token refresh, %d pending in new account cooldown, %d shadow, "+
+					"%d not serving the model, %d outside their availability windows, "+
+					"others rate limited, invalid, or inactive)",
+				overQuotaCount, breakerOpenCount, refreshFailingCount, coolingDownCount, shadowCount,
+				otherModelsCount, unavailableCount,
+			)
+		}
 		return nil, fmt.Errorf("no available accounts (all are rate limited, invalid, or inactive)")
 	}
+	availableAccounts = s.canary.Pool(availableAccounts, cohort)
 
-	// Prioritize healthy accounts (active and not needing refresh)
-	var healthyAccounts []*entities.Account
-	for _, acc := range availableAccounts {
-		if acc.Status == entities.AccountStatusActive && !acc.NeedsRefresh() {
-			healthyAccounts = append(healthyAccounts, acc)
+	var account *entities.Account
+	var tier []*entities.Account
+	for _, tier = range priorityTiers(availableAccounts) {
+		if account = s.selectFromTier(tier); account != nil {
+			break
 		}
 	}
-
-	// Select from healthy accounts if available, otherwise use all available
-	var selectedAccounts []*entities.Account
-	if len(healthyAccounts) > 0 {
-		selectedAccounts = healthyAccounts
-	} else {
-		selectedAccounts = availableAccounts
+	if account == nil {
+		return nil, fmt.Errorf("no available accounts (circuit breaker probes already in flight)")
 	}
-
-	// Round-robin selection
-	account := s.selectAccountRoundRobin(selectedAccounts)
+	s.tiers.Record(account.Priority, now)
 
 	s.logger.Withs(sctx.Fields{
 		"account_id":         account.ID,
@@ -194,91 +1101,114 @@ func (s *ProxyService) GetValidAccount(ctx context.Context) (*entities.Account,
 		"needs_refresh":      account.NeedsRefresh(),
 		"total_accounts":     len(allAccounts),
 		"available_accounts": len(availableAccounts),
-		"healthy_accounts":   len(healthyAccounts),
+		"tier_accounts":      len(tier),
+		"priority":           account.Priority,
+		"cohort":             cohort,
 	}).Debug("Selected account for proxy request")
 
 	return account, nil
 }
 
-// selectAccountRoundRobin selects an account using round-robin strategy
-// Uses a simple hash-based distribution to avoid needing persistent state
-func (s *ProxyService) selectAccountRoundRobin(accounts []*entities.Account) *entities.Account {
-	if len(accounts) == 0 {
-		return nil
+// selectFromTier picks an account of one priority tier, preferring the healthy ones (active and not needing
+// refresh), or returns nil when the half-open probes of all its candidates were just claimed by concurrent requests
+func (s *ProxyService) selectFromTier(tier []*entities.Account) *entities.Account {
+	var healthyAccounts []*entities.Account
+	for _, acc := range tier {
+		if acc.Status == entities.AccountStatusActive && !acc.NeedsRefresh() {
+			healthyAccounts = append(healthyAccounts, acc)
+		}
 	}
-	if len(accounts) == 1 {
-		return accounts[0]
+
+	// Select from healthy accounts if available, otherwise use all of the tier
+	selectedAccounts := tier
+	if len(healthyAccounts) > 0 {
+		selectedAccounts = healthyAccounts
 	}
 
-	// Use current timestamp as a rotating index
-	// This provides round-robin behavior without needing to maintain state
-	index := int(time.Now().UnixNano()) % len(accounts)
-	return accounts[index]
+	// Window-aware selection spreads consumption across subscriptions, round-robin otherwise
+	// An account whose half-open probe was just claimed by a concurrent request is skipped
+	for len(selectedAccounts) > 0 {
+		var account *entities.Account
+		if s.windowAware {
+			account = selectAccountWindowAware(selectedAccounts)
+		} else {
+			account = s.selectAccountRoundRobin(selectedAccounts)
+		}
+		if s.breaker.Acquire(account.ID) {
+			return account
+		}
+		selectedAccounts = withoutAccount(selectedAccounts, account.ID)
+	}
+	return nil
 }
 
-// validateAndFixThinkingParams validates and automatically fixes extended thinking parameters
-// If max_tokens <= thinking.budget_tokens, it adjusts max_tokens to be budget_tokens + buffer
-func (s *ProxyService) validateAndFixThinkingParams(bodyBytes []byte) ([]byte, error) {
-	// Try to parse as JSON
-	var body map[string]interface{}
-	if err := json.Unmarshal(bodyBytes, &body); err != nil {
-		// Not JSON or invalid - let Claude API handle it
-		return bodyBytes, nil
+// priorityTiers groups accounts by priority, best (lowest) tier first, keeping their order within each tier
+func priorityTiers(accounts []*entities.Account) [][]*entities.Account {
+	byPriority := make(map[int][]*entities.Account)
+	for _, acc := range accounts {
+		byPriority[acc.Priority] = append(byPriority[acc.Priority], acc)
 	}
 
-	// Check if thinking mode is enabled
-	thinking, hasThinking := body["thinking"].(map[string]interface{})
-	if !hasThinking {
-		// No thinking configuration - pass through
-		return bodyBytes, nil
+	priorities := slices.Sorted(maps.Keys(byPriority))
+	tiers := make([][]*entities.Account, 0, len(priorities))
+	for _, priority := range priorities {
+		tiers = append(tiers, byPriority[priority])
 	}
+	return tiers
+}
 
-	// Extract thinking.budget_tokens
-	budgetTokensFloat, hasBudget := thinking["budget_tokens"].(float64)
-	if !hasBudget {
-		// No budget_tokens specified - pass through
-		return bodyBytes, nil
+// nextAccountRecovery returns when the first rate limited, over-quota or out-of-window account serving model
+// becomes selectable again (false when no account is expected back on its own, e.g. all are invalid or inactive)
+func (s *ProxyService) nextAccountRecovery(ctx context.Context, model string) (time.Time, bool) {
+	accounts, err := s.accountSvc.ListAccounts(ctx)
+	if err != nil {
+		return time.Time{}, false
 	}
-	budgetTokens := int(budgetTokensFloat)
 
-	// Extract max_tokens
-	maxTokensFloat, hasMaxTokens := body["max_tokens"].(float64)
-	if !hasMaxTokens {
-		// No max_tokens specified - pass through
-		return bodyBytes, nil
+	var next time.Time
+	now := time.Now()
+	for _, acc := range accounts {
+		var at time.Time
+		switch {
+		case acc.IsDeleted() || acc.Shadow || !acc.SupportsModel(model):
+			continue
+		case acc.IsAvailableForProxy() && !acc.IsWithinAvailability(now):
+			at, _ = acc.NextAvailabilityChange(now)
+		case acc.Status == entities.AccountStatusRateLimited && acc.RateLimitedUntil != nil:
+			at = *acc.RateLimitedUntil
+		case acc.IsAvailableForProxy() && acc.IsOverQuota():
+			at = acc.UsageWindowEnd()
+		}
+		if at.After(now) && (next.IsZero() || at.Before(next)) {
+			next = at
+		}
 	}
-	maxTokens := int(maxTokensFloat)
+	return next, !next.IsZero()
+}
 
-	// Check if max_tokens > budget_tokens (Claude API requirement)
-	if maxTokens > budgetTokens {
-		// Valid configuration - pass through
-		return bodyBytes, nil
+// withoutAccount returns the accounts except the one with the given ID
+func withoutAccount(accounts []*entities.Account, id string) []*entities.Account {
+	remaining := make([]*entities.Account, 0, len(accounts))
+	for _, acc := range accounts {
+		if acc.ID != id {
+			remaining = append(remaining, acc)
+		}
 	}
+	return remaining
+}
 
-	// Invalid configuration detected - auto-fix by increasing max_tokens
-	// Add reasonable buffer (10% of budget_tokens or minimum 1024 tokens)
-	buffer := budgetTokens / 10
-	if buffer < 1024 {
-		buffer = 1024
+// selectAccountRoundRobin selects an account using round-robin strategy
+// Uses a simple hash-based distribution to avoid needing persistent state
+func (s *ProxyService) selectAccountRoundRobin(accounts []*entities.Account) *entities.Account {
+	if len(accounts) == 0 {
+		return nil
 	}
-	newMaxTokens := budgetTokens + buffer
-
-	// Log the auto-correction
-	s.logger.Withs(sctx.Fields{
-		"original_max_tokens": maxTokens,
-		"budget_tokens":       budgetTokens,
-		"adjusted_max_tokens": newMaxTokens,
-		"buffer_added":        buffer,
-	}).Warn("Auto-corrected max_tokens for extended thinking mode - max_tokens must be greater than budget_tokens")
-
-	// Update max_tokens in the request body
-	body["max_tokens"] = newMaxTokens
-
-	// Re-serialize to JSON
-	modifiedBody, err := json.Marshal(body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal modified request body: %w", err)
+	if len(accounts) == 1 {
+		return accounts[0]
 	}
 
-	return modifiedBody, nil
+	// Use current timestamp as a rotating index
+	// This provides round-robin behavior without needing to maintain state
+	index := int(time.Now().UnixNano()) % len(accounts)
+	return accounts[index]
 }
@@ -0,0 +1,173 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"claude-proxy/config"
+	"claude-proxy/modules/auth/domain/entities"
+	authinterfaces "claude-proxy/modules/auth/domain/interfaces"
+	proxyentities "claude-proxy/modules/proxy/domain/entities"
+	"claude-proxy/pkg/logging"
+
+	sctx "github.com/phathdt/service-context"
+)
+
+// quietLogger returns a logger dropping everything below errors
+func quietLogger(tb testing.TB) sctx.Logger {
+	tb.Helper()
+
+	registry, err := logging.NewRegistry("error")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return registry.Wrap(sctx.GlobalLogger().GetLogger("test"))
+}
+
+// listedAccounts is an account service serving a fixed account list
+type listedAccounts struct {
+	authinterfaces.AccountService
+	accounts []*entities.Account
+}
+
+func (l *listedAccounts) ListAccounts(context.Context) ([]*entities.Account, error) {
+	return l.accounts, nil
+}
+
+// newSelectionService creates a proxy service selecting among accounts, round-robin and without canary cohorts
+func newSelectionService(t *testing.T, accounts ...*entities.Account) *ProxyService {
+	t.Helper()
+
+	logger := quietLogger(t)
+	return &ProxyService{
+		accountSvc: &listedAccounts{accounts: accounts},
+		breaker:    NewCircuitBreaker(config.CircuitBreakerConfig{}, logger),
+		canary:     NewCanaryRouter(nil, 0),
+		tiers:      NewPriorityTierTracker(),
+		logger:     logger,
+	}
+}
+
+// testAccount returns an active OAuth account in automatic refresh mode with an access token valid for an hour
+func testAccount(id string) *entities.Account {
+	return &entities.Account{
+		ID:          id,
+		Name:        id,
+		Status:      entities.AccountStatusActive,
+		AccessToken: "sk-ant-oat01-" + id,
+		ExpiresAt:   time.Now().Add(time.Hour),
+		AutoRefresh: true,
+	}
+}
+
+func TestGetValidAccountSelectionMatrix(t *testing.T) {
+	now := time.Now()
+	longAgo := now.Add(-2 * entities.RefreshRetryDelay)
+	statuses := []struct {
+		name       string
+		selectable bool
+		apply      func(a *entities.Account)
+	}{
+		{"active", true, func(a *entities.Account) {}},
+		{"inactive", false, (*entities.Account).Deactivate},
+		{"invalid", false, func(a *entities.Account) { a.MarkInvalid("invalid_grant") }},
+		{"conflicted", false, func(a *entities.Account) { a.Status = entities.AccountStatusConflicted }},
+		{"rate limited", false, func(a *entities.Account) { a.MarkRateLimited(now.Add(time.Hour), "429") }},
+		{"rate limit expired", true, func(a *entities.Account) { a.MarkRateLimited(now.Add(-time.Minute), "429") }},
+	}
+	expiries := []struct {
+		name    string
+		expired bool
+		at      time.Time
+	}{
+		{"fresh token", false, now.Add(time.Hour)},
+		{"token needing refresh", false, now.Add(30 * time.Second)},
+		{"expired token", true, now.Add(-time.Hour)},
+	}
+	lastErrors := []struct {
+		name     string
+		failing  bool
+		failedAt *time.Time
+	}{
+		{"no refresh error", false, nil},
+		{"refresh failed just now", true, &now},
+		{"refresh failed long ago", false, &longAgo},
+	}
+
+	for _, status := range statuses {
+		for _, expiry := range expiries {
+			for _, lastError := range lastErrors {
+				name := status.name + ", " + expiry.name + ", " + lastError.name
+				account := testAccount("acc_1")
+				account.ExpiresAt = expiry.at
+				if lastError.failedAt != nil {
+					account.RecordRefreshFailure("timeout")
+					account.RefreshFailedAt = lastError.failedAt
+				}
+				status.apply(account)
+
+				want := status.selectable && !(expiry.expired && lastError.failing)
+				svc := newSelectionService(t, account)
+				got, err := svc.GetValidAccount(context.Background(), "", proxyentities.CohortControl)
+				if want && (err != nil || got != account) {
+					t.Errorf("%s: GetValidAccount() = %v, %v; want the account", name, got, err)
+				}
+				if !want && err == nil {
+					t.Errorf("%s: GetValidAccount() selected the account", name)
+				}
+			}
+		}
+	}
+}
+
+func TestGetValidAccountPrefersHealthyAccounts(t *testing.T) {
+	healthy := testAccount("healthy")
+
+	needsRefresh := testAccount("needs_refresh")
+	needsRefresh.ExpiresAt = time.Now().Add(30 * time.Second)
+
+	retrying := testAccount("retrying") // Expired, its last refresh failure old enough to try again
+	retrying.ExpiresAt = time.Now().Add(-time.Hour)
+	retrying.RecordRefreshFailure("timeout")
+	*retrying.RefreshFailedAt = time.Now().Add(-2 * entities.RefreshRetryDelay)
+
+	failing := testAccount("failing")
+	failing.ExpiresAt = time.Now().Add(-time.Hour)
+	failing.RecordRefreshFailure("timeout")
+
+	recovered := testAccount("recovered")
+	recovered.MarkRateLimited(time.Now().Add(-time.Minute), "429")
+
+	tests := []struct {
+		name     string
+		accounts []*entities.Account
+		want     string // Account always selected, empty for none
+	}{
+		{"healthy over refresh candidates", []*entities.Account{needsRefresh, retrying, healthy, failing}, "healthy"},
+		{"healthy over recovered", []*entities.Account{recovered, healthy}, "healthy"},
+		{"needing refresh over failing", []*entities.Account{failing, needsRefresh}, "needs_refresh"},
+		{"refresh retried once the failure is old", []*entities.Account{failing, retrying}, "retrying"},
+		{"only failing", []*entities.Account{failing}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := newSelectionService(t, tt.accounts...)
+			for range 20 {
+				got, err := svc.GetValidAccount(context.Background(), "", proxyentities.CohortControl)
+				if tt.want == "" {
+					if err == nil {
+						t.Fatalf("GetValidAccount() selected %s, want none", got.ID)
+					}
+					return
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got.ID != tt.want {
+					t.Fatalf("GetValidAccount() selected %s, want %s", got.ID, tt.want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,142 @@
+package services
+
+import (
+	"context"
+	"io"
+	"maps"
+	"net/http"
+	"time"
+
+	authinterfaces "claude-proxy/modules/auth/domain/interfaces"
+	proxyentities "claude-proxy/modules/proxy/domain/entities"
+	proxyinterfaces "claude-proxy/modules/proxy/domain/interfaces"
+	"claude-proxy/modules/proxy/infrastructure/clients"
+	"claude-proxy/pkg/errors"
+	"claude-proxy/pkg/httpproxy"
+	"claude-proxy/pkg/requestid"
+
+	sctx "github.com/phathdt/service-context"
+)
+
+// ReplayService resends captured requests through a chosen account
+// Replays skip token validation, session limits, request rewriting and usage statistics; they only show
+// in the account's request history (flagged replay) and in the logs
+type ReplayService struct {
+	accountSvc   authinterfaces.AccountService
+	claudeClient *clients.ClaudeAPIClient
+	history      proxyinterfaces.RequestHistory
+	logger       sctx.Logger
+}
+
+// NewReplayService creates a new replay service
+func NewReplayService(
+	accountSvc authinterfaces.AccountService,
+	claudeClient *clients.ClaudeAPIClient,
+	history proxyinterfaces.RequestHistory,
+	logger sctx.Logger,
+) proxyinterfaces.ReplayService {
+	return &ReplayService{
+		accountSvc:   accountSvc,
+		claudeClient: claudeClient,
+		history:      history,
+		logger:       logger,
+	}
+}
+
+// Replay sends request to Claude API with the account's credentials and returns the upstream response
+// The account's header overrides apply first, then the request's own headers (proxy-managed ones dropped)
+func (s *ReplayService) Replay(
+	ctx context.Context,
+	accountID string,
+	request proxyentities.CapturedRequest,
+) (*proxyentities.ReplayResult, error) {
+	account, err := s.accountSvc.GetAccount(ctx, accountID)
+	if err != nil {
+		return nil, errors.NewNotFoundError("ACCOUNT_NOT_FOUND", "Account not found", accountID)
+	}
+	if account.IsDeleted() {
+		return nil, errors.NewConflictError("ACCOUNT_DELETED", "Account is deleted", accountID)
+	}
+
+	accessToken, err := s.accountSvc.GetValidToken(ctx, account.ID)
+	if err != nil {
+		return nil, errors.NewProxyError(
+			http.StatusServiceUnavailable, ErrCodeAccountTokenFailed, "Failed to get valid access token", err.Error(),
+		)
+	}
+
+	headers := maps.Clone(account.Headers)
+	if headers == nil {
+		headers = map[string]string{}
+	}
+	maps.Copy(headers, httpproxy.WithoutManagedHeaders(request.Headers))
+
+	s.logger.Withs(sctx.Fields{
+		"replay":       true,
+		"request_id":   requestid.FromContext(ctx),
+		"account_id":   account.ID,
+		"account_name": account.Name,
+		"method":       request.Method,
+		"path":         request.Path,
+	}).Warn("Replaying captured request")
+
+	start := time.Now()
+	history := proxyentities.AccountRequest{
+		Timestamp: start,
+		Method:    request.Method,
+		Path:      request.Path,
+		Replay:    true,
+	}
+
+	credential := clients.Credential{Secret: accessToken, APIKey: account.IsAPIKey()}
+	resp, err := s.claudeClient.ProxyRequest(
+		ctx, request.Method, request.Path, credential, request.Body, account.BaseURL, account.ProxyURL, headers,
+	)
+	history.Latency = time.Since(start)
+	if err != nil {
+		appErr := classifyTransportError(err)
+		history.Error = appErr.ErrorCode()
+		s.history.Record(account.ID, history)
+		return nil, appErr
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, proxyentities.MaxCapturedBodySize+1))
+	if err != nil {
+		appErr := classifyTransportError(err)
+		history.Error = appErr.ErrorCode()
+		s.history.Record(account.ID, history)
+		return nil, appErr
+	}
+
+	history.StatusCode = resp.StatusCode
+	if resp.StatusCode >= 400 {
+		history.Error = http.StatusText(resp.StatusCode)
+	}
+	s.history.Record(account.ID, history)
+
+	result := &proxyentities.ReplayResult{
+		StatusCode:        resp.StatusCode,
+		Headers:           make(map[string]string, len(resp.Header)),
+		Body:              body,
+		Latency:           history.Latency,
+		UpstreamRequestID: resp.Header.Get(requestid.UpstreamHeader),
+	}
+	if len(body) > proxyentities.MaxCapturedBodySize {
+		result.Body = body[:proxyentities.MaxCapturedBodySize]
+		result.BodyTruncated = true
+	}
+	for name := range resp.Header {
+		result.Headers[name] = resp.Header.Get(name)
+	}
+
+	s.logger.Withs(sctx.Fields{
+		"replay":              true,
+		"account_id":          account.ID,
+		"status_code":         resp.StatusCode,
+		"upstream_request_id": result.UpstreamRequestID,
+		"latency_ms":          history.Latency.Milliseconds(),
+	}).Info("Replayed request answered")
+
+	return result, nil
+}
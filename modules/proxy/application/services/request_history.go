@@ -0,0 +1,75 @@
+package services
+
+import (
+	"sync"
+
+	"claude-proxy/modules/proxy/domain/entities"
+	proxyinterfaces "claude-proxy/modules/proxy/domain/interfaces"
+)
+
+// DefaultRequestHistorySize is how many requests are kept per account when proxy.request_history_size is unset
+const DefaultRequestHistorySize = 50
+
+// RequestHistory keeps a fixed-size ring of recent requests per account
+// Rings are created once per account and each has its own mutex, so recording only contends with
+// requests of the same account and never allocates after the ring is full
+type RequestHistory struct {
+	size  int
+	rings sync.Map // Account ID -> *requestRing
+}
+
+// requestRing is the circular buffer of one account's requests
+type requestRing struct {
+	mu      sync.Mutex
+	entries []entities.AccountRequest // Grows to the ring size, then overwritten in place
+	next    int                       // Index written by the next Record once the ring is full
+}
+
+// NewRequestHistory creates a request history keeping size requests per account
+func NewRequestHistory(size int) proxyinterfaces.RequestHistory {
+	if size <= 0 {
+		size = DefaultRequestHistorySize
+	}
+	return &RequestHistory{size: size}
+}
+
+// Record adds a request to the account's history, evicting its oldest request when full
+func (h *RequestHistory) Record(accountID string, request entities.AccountRequest) {
+	value, ok := h.rings.Load(accountID)
+	if !ok {
+		value, _ = h.rings.LoadOrStore(accountID, &requestRing{
+			entries: make([]entities.AccountRequest, 0, h.size),
+		})
+	}
+	ring := value.(*requestRing)
+
+	ring.mu.Lock()
+	defer ring.mu.Unlock()
+
+	if len(ring.entries) < h.size {
+		ring.entries = append(ring.entries, request)
+		return
+	}
+	ring.entries[ring.next] = request
+	ring.next = (ring.next + 1) % h.size
+}
+
+// Recent returns the account's recorded requests, newest first
+func (h *RequestHistory) Recent(accountID string) []entities.AccountRequest {
+	value, ok := h.rings.Load(accountID)
+	if !ok {
+		return []entities.AccountRequest{}
+	}
+	ring := value.(*requestRing)
+
+	ring.mu.Lock()
+	defer ring.mu.Unlock()
+
+	// Until the ring is full next stays 0, so walking back from it covers both cases
+	count := len(ring.entries)
+	recent := make([]entities.AccountRequest, count)
+	for i := range count {
+		recent[i] = ring.entries[(ring.next-1-i+2*count)%count]
+	}
+	return recent
+}
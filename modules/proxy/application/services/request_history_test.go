@@ -0,0 +1,145 @@
+package services
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"claude-proxy/modules/proxy/domain/entities"
+)
+
+// historyRequest is the seq-th request recorded by a writer
+func historyRequest(writer, seq int) entities.AccountRequest {
+	return entities.AccountRequest{TokenID: fmt.Sprint("writer_", writer), Path: fmt.Sprint("/v1/messages/", seq)}
+}
+
+// historyOrderError verifies that recent (newest first) holds each writer's requests newest first and
+// only evicts a writer's oldest ones: what is left of a writer is the suffix of its sequence starting at
+// its oldest kept request, up to its latest when complete
+func historyOrderError(recent []entities.AccountRequest, latest map[string]int, complete bool) error {
+	previous := make(map[string]int) // Writer -> sequence of its last request seen, walking back in time
+	for _, request := range recent {
+		var seq int
+		if _, err := fmt.Sscanf(request.Path, "/v1/messages/%d", &seq); err != nil {
+			return fmt.Errorf("unexpected request %+v", request)
+		}
+		last, seen := previous[request.TokenID]
+		switch {
+		case !seen && complete && seq != latest[request.TokenID]:
+			return fmt.Errorf("newest request of %s is #%d, want #%d", request.TokenID, seq, latest[request.TokenID])
+		case seen && seq != last-1:
+			return fmt.Errorf("%s: request #%d follows #%d in the history", request.TokenID, seq, last)
+		}
+		previous[request.TokenID] = seq
+	}
+	return nil
+}
+
+func TestRequestHistoryConcurrentRecords(t *testing.T) {
+	const (
+		size    = 64
+		writers = 8
+		records = 500
+	)
+	history := NewRequestHistory(size)
+
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	wg.Add(writers)
+	for w := range writers {
+		go func() {
+			defer wg.Done()
+			for seq := range records {
+				history.Record("acc_1", historyRequest(w, seq))
+			}
+		}()
+	}
+
+	// Snapshots taken mid-write are consistent too
+	readerDone := make(chan struct{})
+	go func() {
+		defer close(readerDone)
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			recent := history.Recent("acc_1")
+			if len(recent) > size {
+				t.Errorf("history holds %d requests, more than its size %d", len(recent), size)
+				return
+			}
+			if err := historyOrderError(recent, nil, false); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+	wg.Wait()
+	close(done)
+	<-readerDone
+
+	recent := history.Recent("acc_1")
+	if len(recent) != size {
+		t.Fatalf("history holds %d requests, want %d", len(recent), size)
+	}
+	latest := make(map[string]int)
+	for w := range writers {
+		latest[historyRequest(w, 0).TokenID] = records - 1
+	}
+	if err := historyOrderError(recent, latest, true); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRequestHistoryConcurrentFirstRecords(t *testing.T) {
+	const (
+		writers = 16
+		records = 20
+	)
+	history := NewRequestHistory(writers * records)
+
+	// Every writer races to create the rings of the same new accounts, each getting a fourth of its requests
+	var wg sync.WaitGroup
+	wg.Add(writers)
+	for w := range writers {
+		go func() {
+			defer wg.Done()
+			for seq := range records {
+				history.Record(fmt.Sprint("acc_", seq%4), historyRequest(w, seq/4))
+			}
+		}()
+	}
+	wg.Wait()
+
+	total := 0
+	for account := range 4 {
+		recent := history.Recent(fmt.Sprint("acc_", account))
+		if err := historyOrderError(recent, nil, false); err != nil {
+			t.Error(err)
+		}
+		total += len(recent)
+	}
+	if total != writers*records {
+		t.Errorf("history holds %d requests, want all %d", total, writers*records)
+	}
+}
+
+func TestRequestHistoryEviction(t *testing.T) {
+	history := NewRequestHistory(3)
+	if recent := history.Recent("acc_1"); recent == nil || len(recent) != 0 {
+		t.Fatalf("Recent() of an unknown account = %#v, want an empty list", recent)
+	}
+
+	for seq := range 5 {
+		history.Record("acc_1", historyRequest(0, seq))
+		recent := history.Recent("acc_1")
+		if want := min(seq+1, 3); len(recent) != want {
+			t.Fatalf("after %d records: %d requests, want %d", seq+1, len(recent), want)
+		}
+		if err := historyOrderError(recent, map[string]int{"writer_0": seq}, true); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
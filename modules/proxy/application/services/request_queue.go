@@ -0,0 +1,93 @@
+package services
+
+import (
+	"sync"
+	"time"
+
+	proxyentities "claude-proxy/modules/proxy/domain/entities"
+	proxyinterfaces "claude-proxy/modules/proxy/domain/interfaces"
+)
+
+// RequestQueue counts the requests waiting for an account to recover (in memory, reset on restart)
+type RequestQueue struct {
+	maxWait time.Duration // 0 disables queueing
+	size    int
+
+	mu          sync.Mutex
+	depth       int
+	served      int64
+	timedOut    int64
+	canceled    int64
+	rejected    int64
+	waitedTotal time.Duration
+	maxWaited   time.Duration
+}
+
+// NewRequestQueue creates a queue letting up to size requests wait at most maxWait (0 = no queueing)
+func NewRequestQueue(maxWait time.Duration, size int) proxyinterfaces.RequestQueue {
+	return &RequestQueue{maxWait: maxWait, size: size}
+}
+
+// Enabled returns true if requests may wait for an account
+func (q *RequestQueue) Enabled() bool {
+	return q.maxWait > 0
+}
+
+// Enter claims a slot for a request that can be served once an account recovers at recoveryAt
+func (q *RequestQueue) Enter(recoveryAt time.Time) (time.Time, error) {
+	now := time.Now()
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if recoveryAt.Sub(now) > q.maxWait {
+		q.rejected++
+		return time.Time{}, proxyentities.ErrQueueWaitTooLong
+	}
+	if q.depth >= q.size {
+		q.rejected++
+		return time.Time{}, proxyentities.ErrQueueFull
+	}
+	q.depth++
+	return now.Add(q.maxWait), nil
+}
+
+// Leave frees the slot of a request that waited for the given time, recording the outcome
+func (q *RequestQueue) Leave(waited time.Duration, outcome proxyentities.QueueOutcome) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.depth--
+	switch outcome {
+	case proxyentities.QueueOutcomeServed:
+		q.served++
+	case proxyentities.QueueOutcomeTimedOut:
+		q.timedOut++
+	case proxyentities.QueueOutcomeCanceled:
+		q.canceled++
+	}
+	q.waitedTotal += waited
+	q.maxWaited = max(q.maxWaited, waited)
+}
+
+// Status returns the queue depth and counters
+func (q *RequestQueue) Status() proxyentities.QueueStatus {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	status := proxyentities.QueueStatus{
+		Enabled:   q.Enabled(),
+		MaxWait:   q.maxWait,
+		Size:      q.size,
+		Depth:     q.depth,
+		Served:    q.served,
+		TimedOut:  q.timedOut,
+		Canceled:  q.canceled,
+		Rejected:  q.rejected,
+		MaxWaited: q.maxWaited,
+	}
+	if left := q.served + q.timedOut + q.canceled; left > 0 {
+		status.AverageWait = q.waitedTotal / time.Duration(left)
+	}
+	return status
+}
@@ -0,0 +1,131 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"claude-proxy/config"
+)
+
+// RequestShaper applies operator-configured transformations to POST /v1/messages bodies
+type RequestShaper struct {
+	systemPrompt     string
+	dropFields       [][]string // Dotted field paths split into segments
+	defaultModel     string
+	defaultMaxTokens int
+}
+
+// NewRequestShaper creates a request shaper from configuration
+func NewRequestShaper(cfg config.RequestShapingConfig) *RequestShaper {
+	shaper := &RequestShaper{
+		systemPrompt:     cfg.SystemPrompt,
+		defaultModel:     cfg.DefaultModel,
+		defaultMaxTokens: cfg.DefaultMaxTokens,
+	}
+
+	for _, field := range cfg.DropFields {
+		field = strings.TrimSpace(field)
+		if field != "" {
+			shaper.dropFields = append(shaper.dropFields, strings.Split(field, "."))
+		}
+	}
+
+	return shaper
+}
+
+// Enabled returns true if any transformation is configured
+func (r *RequestShaper) Enabled() bool {
+	return r.systemPrompt != "" || len(r.dropFields) > 0 || r.defaultModel != "" || r.defaultMaxTokens > 0
+}
+
+// Apply transforms a message request body and returns it with the names of the transforms that fired
+// Bodies that are not JSON objects are returned unchanged (Claude API reports the error)
+func (r *RequestShaper) Apply(bodyBytes []byte) ([]byte, []string, error) {
+	if !r.Enabled() || len(bodyBytes) == 0 {
+		return bodyBytes, nil, nil
+	}
+
+	// UseNumber keeps numeric values exactly as sent when the body is re-serialized
+	var body map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(bodyBytes))
+	decoder.UseNumber()
+	if err := decoder.Decode(&body); err != nil || body == nil {
+		return bodyBytes, nil, nil
+	}
+
+	var fired []string
+
+	for _, path := range r.dropFields {
+		if dropField(body, path) {
+			fired = append(fired, "drop:"+strings.Join(path, "."))
+		}
+	}
+
+	if r.systemPrompt != "" {
+		if system, ok := mergeSystemPrompt(body["system"], r.systemPrompt); ok {
+			body["system"] = system
+			fired = append(fired, "system_prompt")
+		}
+	}
+
+	if _, ok := body["model"]; !ok && r.defaultModel != "" {
+		body["model"] = r.defaultModel
+		fired = append(fired, "default_model")
+	}
+
+	if _, ok := body["max_tokens"]; !ok && r.defaultMaxTokens > 0 {
+		body["max_tokens"] = r.defaultMaxTokens
+		fired = append(fired, "default_max_tokens")
+	}
+
+	if len(fired) == 0 {
+		return bodyBytes, nil, nil
+	}
+
+	shaped, err := json.Marshal(body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to marshal shaped request body: %w", err)
+	}
+
+	return shaped, fired, nil
+}
+
+// dropField removes a (possibly nested) field and returns true if it was present
+func dropField(body map[string]interface{}, path []string) bool {
+	current := body
+	for _, key := range path[:len(path)-1] {
+		next, ok := current[key].(map[string]interface{})
+		if !ok {
+			return false
+		}
+		current = next
+	}
+
+	last := path[len(path)-1]
+	if _, ok := current[last]; !ok {
+		return false
+	}
+	delete(current, last)
+	return true
+}
+
+// mergeSystemPrompt prepends prompt to the request's system field, which may be absent,
+// a string, or an array of content blocks; other shapes are left untouched
+func mergeSystemPrompt(system interface{}, prompt string) (interface{}, bool) {
+	switch existing := system.(type) {
+	case nil:
+		return prompt, true
+	case string:
+		if existing == "" {
+			return prompt, true
+		}
+		return prompt + "\n\n" + existing, true
+	case []interface{}:
+		block := map[string]interface{}{"type": "text", "text": prompt}
+		return append([]interface{}{block}, existing...), true
+	default:
+		return system, false
+	}
+}
@@ -0,0 +1,106 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	authinterfaces "claude-proxy/modules/auth/domain/interfaces"
+	proxyinterfaces "claude-proxy/modules/proxy/domain/interfaces"
+
+	sctx "github.com/phathdt/service-context"
+)
+
+// sessionTouchInterval is how often queued session activity is recorded
+const sessionTouchInterval = time.Second
+
+// SessionToucher collects the sessions seen by proxied requests and records their activity from one background
+// loop, so the request path only adds an ID to a set
+type SessionToucher struct {
+	sessionSvc authinterfaces.SessionService
+	interval   time.Duration
+
+	mu      sync.Mutex
+	pending map[string]struct{} // Sessions touched since the last flush
+
+	done chan struct{}
+	wg   sync.WaitGroup
+
+	logger sctx.Logger
+}
+
+// NewSessionToucher creates a toucher flushing every sessionTouchInterval once started
+func NewSessionToucher(sessionSvc authinterfaces.SessionService, logger sctx.Logger) proxyinterfaces.SessionToucher {
+	return &SessionToucher{
+		sessionSvc: sessionSvc,
+		interval:   sessionTouchInterval,
+		pending:    make(map[string]struct{}),
+		done:       make(chan struct{}),
+		logger:     logger,
+	}
+}
+
+// Touch queues the session for the next flush
+func (t *SessionToucher) Touch(sessionID string) {
+	if sessionID == "" {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.pending[sessionID] = struct{}{}
+}
+
+// Start launches the periodic flush
+func (t *SessionToucher) Start() {
+	t.wg.Add(1)
+	go t.run()
+}
+
+// Stop ends the periodic flush and records the sessions still queued
+func (t *SessionToucher) Stop() {
+	close(t.done)
+	t.wg.Wait()
+}
+
+// run flushes the queued sessions every interval until Stop
+func (t *SessionToucher) run() {
+	defer t.wg.Done()
+
+	ticker := time.NewTicker(t.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-t.done:
+			t.flush()
+			return
+		case <-ticker.C:
+			t.flush()
+		}
+	}
+}
+
+// flush records activity on the sessions queued since the last flush
+func (t *SessionToucher) flush() {
+	t.mu.Lock()
+	pending := t.pending
+	if len(pending) == 0 {
+		t.mu.Unlock()
+		return
+	}
+	t.pending = make(map[string]struct{}, len(pending))
+	t.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	for sessionID := range pending {
+		if err := t.sessionSvc.TouchSession(ctx, sessionID); err != nil {
+			t.logger.Withs(sctx.Fields{
+				"error":      err.Error(),
+				"session_id": sessionID,
+			}).Debug("Failed to touch session")
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	authinterfaces "claude-proxy/modules/auth/domain/interfaces"
+)
+
+// countingSessions is a session service counting the touches of each session
+type countingSessions struct {
+	authinterfaces.SessionService
+	mu      sync.Mutex
+	touches map[string]int
+}
+
+func (c *countingSessions) TouchSession(_ context.Context, sessionID string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.touches[sessionID]++
+	return nil
+}
+
+func (c *countingSessions) count(sessionID string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.touches[sessionID]
+}
+
+// TestSessionToucherBatchesTouches touches sessions from concurrent requests: each session is touched once per
+// flush, by the periodic flush and by Stop for what is still queued
+func TestSessionToucherBatchesTouches(t *testing.T) {
+	sessions := &countingSessions{touches: make(map[string]int)}
+	toucher := NewSessionToucher(sessions, quietLogger(t)).(*SessionToucher)
+	toucher.interval = 10 * time.Millisecond
+	toucher.Start()
+
+	var wg sync.WaitGroup
+	for g := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 50 {
+				toucher.Touch(fmt.Sprintf("ses_%d", g%2))
+				toucher.Touch("")
+			}
+		}()
+	}
+	wg.Wait()
+
+	deadline := time.Now().Add(time.Second)
+	for sessions.count("ses_0") == 0 || sessions.count("ses_1") == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("queued sessions were not touched by the periodic flush")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	flushed := sessions.count("ses_0")
+
+	toucher.Touch("ses_0")
+	toucher.Touch("ses_0")
+	toucher.Stop()
+
+	if got := sessions.count("ses_0"); got <= flushed {
+		t.Errorf("ses_0 touched %d times after Stop, want more than the %d before", got, flushed)
+	}
+	if got := sessions.count("ses_1"); got >= 200 {
+		t.Errorf("ses_1 touched %d times for 200 requests, want them batched", got)
+	}
+	if got := sessions.count(""); got != 0 {
+		t.Errorf("empty session ID touched %d times", got)
+	}
+}
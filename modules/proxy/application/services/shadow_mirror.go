@@ -0,0 +1,208 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"maps"
+	"math/rand/v2"
+	"net/http"
+	"sync"
+	"time"
+
+	"claude-proxy/modules/auth/domain/entities"
+	authinterfaces "claude-proxy/modules/auth/domain/interfaces"
+	proxyentities "claude-proxy/modules/proxy/domain/entities"
+	proxyinterfaces "claude-proxy/modules/proxy/domain/interfaces"
+	"claude-proxy/modules/proxy/infrastructure/clients"
+
+	sctx "github.com/phathdt/service-context"
+)
+
+const (
+	// maxShadowInFlight bounds the mirrored requests in flight at once; further copies are dropped
+	maxShadowInFlight = 16
+
+	// shadowTimeout bounds one mirrored request, its discarded response body included
+	shadowTimeout = 10 * time.Minute
+)
+
+// ShadowMirror mirrors a sample of non-streaming message requests to shadow accounts
+// A copy goes out after the request is accepted and runs in its own goroutine, so the client never waits for
+// it. Each copy feeds the shadow account's circuit breaker and request history (flagged shadow) and its
+// Stats, which is what lets its error rate be compared with the accounts in the rotation.
+type ShadowMirror struct {
+	accountSvc   authinterfaces.AccountService
+	claudeClient *clients.ClaudeAPIClient
+	breaker      proxyinterfaces.CircuitBreaker
+	history      proxyinterfaces.RequestHistory
+	percent      float64       // Share of requests mirrored (0-100)
+	slots        chan struct{} // Mirrored requests in flight
+	stats        map[string]*proxyentities.ShadowStats
+	mu           sync.Mutex
+	logger       sctx.Logger
+}
+
+// NewShadowMirror creates a mirror copying percent (0-100) of the eligible requests to shadow accounts
+func NewShadowMirror(
+	accountSvc authinterfaces.AccountService,
+	claudeClient *clients.ClaudeAPIClient,
+	breaker proxyinterfaces.CircuitBreaker,
+	history proxyinterfaces.RequestHistory,
+	percent float64,
+	logger sctx.Logger,
+) proxyinterfaces.ShadowMirror {
+	return &ShadowMirror{
+		accountSvc:   accountSvc,
+		claudeClient: claudeClient,
+		breaker:      breaker,
+		history:      history,
+		percent:      percent,
+		slots:        make(chan struct{}, maxShadowInFlight),
+		stats:        make(map[string]*proxyentities.ShadowStats),
+		logger:       logger,
+	}
+}
+
+// Enabled returns true if a share of requests is mirrored
+func (m *ShadowMirror) Enabled() bool {
+	return m.percent > 0
+}
+
+// Mirror sends a copy of a message request body to every usable shadow account, in the background
+func (m *ShadowMirror) Mirror(tokenID, path string, body []byte) {
+	if m.percent <= 0 || (m.percent < 100 && rand.Float64()*100 >= m.percent) {
+		return
+	}
+
+	go func() {
+		if isStreamingRequest(body) {
+			return
+		}
+
+		accounts, err := m.accountSvc.ListAccounts(context.Background())
+		if err != nil {
+			m.logger.Withs(sctx.Fields{"error": err.Error()}).Warn("Failed to list shadow accounts")
+			return
+		}
+		for _, account := range accounts {
+			if !account.Shadow || !account.IsUsableNow() {
+				continue
+			}
+
+			select {
+			case m.slots <- struct{}{}:
+			default:
+				m.update(account.ID, func(stats *proxyentities.ShadowStats) { stats.Dropped++ })
+				continue
+			}
+			go func(account *entities.Account) {
+				defer func() { <-m.slots }()
+				m.send(account, tokenID, path, body)
+			}(account)
+		}
+	}()
+}
+
+// send sends one mirrored request and records its outcome on the shadow account
+func (m *ShadowMirror) send(account *entities.Account, tokenID, path string, body []byte) {
+	ctx, cancel := context.WithTimeout(context.Background(), shadowTimeout)
+	defer cancel()
+
+	start := time.Now()
+	request := proxyentities.AccountRequest{
+		Timestamp: start,
+		TokenID:   tokenID,
+		Method:    http.MethodPost,
+		Path:      path,
+		Shadow:    true,
+	}
+
+	accessToken, err := m.accountSvc.GetValidToken(ctx, account.ID)
+	if err != nil {
+		request.Error = ErrCodeAccountTokenFailed
+		m.finish(account, request, err)
+		return
+	}
+
+	credential := clients.Credential{Secret: accessToken, APIKey: account.IsAPIKey()}
+	resp, err := m.claudeClient.ProxyRequest(
+		ctx, http.MethodPost, path, credential, body, account.BaseURL, account.ProxyURL, maps.Clone(account.Headers),
+	)
+	request.Latency = time.Since(start)
+	if err != nil {
+		m.breaker.Record(account.ID, request.Latency, true)
+		request.Error = classifyTransportError(err).ErrorCode()
+		m.finish(account, request, err)
+		return
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+
+	m.breaker.Record(account.ID, request.Latency, resp.StatusCode >= 500)
+	request.StatusCode = resp.StatusCode
+	if resp.StatusCode >= 400 {
+		request.Error = http.StatusText(resp.StatusCode)
+	}
+	m.finish(account, request, nil)
+}
+
+// finish records a mirrored request in the shadow account's history and stats
+func (m *ShadowMirror) finish(account *entities.Account, request proxyentities.AccountRequest, err error) {
+	m.history.Record(account.ID, request)
+	m.update(account.ID, func(stats *proxyentities.ShadowStats) {
+		stats.Sent++
+		stats.LastAt = time.Now()
+		stats.LastLatency = request.Latency
+		if request.IsError() {
+			stats.Failed++
+			stats.LastError = request.Error
+		}
+	})
+
+	if request.IsError() {
+		fields := sctx.Fields{
+			"account_id":   account.ID,
+			"account_name": account.Name,
+			"status_code":  request.StatusCode,
+			"error_code":   request.Error,
+		}
+		if err != nil {
+			fields["error"] = err.Error()
+		}
+		m.logger.Withs(fields).Warn("Mirrored request to shadow account failed")
+	}
+}
+
+// update changes the stats of a shadow account under the lock
+func (m *ShadowMirror) update(accountID string, fn func(stats *proxyentities.ShadowStats)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stats, ok := m.stats[accountID]
+	if !ok {
+		stats = &proxyentities.ShadowStats{}
+		m.stats[accountID] = stats
+	}
+	fn(stats)
+}
+
+// Stats returns the mirrored request counts per shadow account
+func (m *ShadowMirror) Stats() map[string]proxyentities.ShadowStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	result := make(map[string]proxyentities.ShadowStats, len(m.stats))
+	for accountID, stats := range m.stats {
+		result[accountID] = *stats
+	}
+	return result
+}
+
+// isStreamingRequest returns true if a message request body asks for a streamed response
+func isStreamingRequest(body []byte) bool {
+	var payload struct {
+		Stream bool `json:"stream"`
+	}
+	return json.Unmarshal(body, &payload) == nil && payload.Stream
+}
@@ -0,0 +1,151 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"claude-proxy/config"
+)
+
+// ThinkingParamsError reports a request whose max_tokens doesn't exceed thinking.budget_tokens
+// (returned under the reject policy)
+type ThinkingParamsError struct {
+	MaxTokens    int64
+	BudgetTokens int64
+}
+
+func (e *ThinkingParamsError) Error() string {
+	return fmt.Sprintf(
+		"max_tokens (%d) must be greater than thinking.budget_tokens (%d) when extended thinking is enabled",
+		e.MaxTokens, e.BudgetTokens,
+	)
+}
+
+// isThinkingParamsError returns true if err was caused by invalid extended thinking parameters
+func isThinkingParamsError(err error) bool {
+	var paramsErr *ThinkingParamsError
+	return errors.As(err, &paramsErr)
+}
+
+// ThinkingFix describes a max_tokens raised above the thinking budget
+type ThinkingFix struct {
+	MaxTokens         int64 // As sent
+	BudgetTokens      int64
+	AdjustedMaxTokens int64
+	Buffer            int64 // Tokens added on top of the budget
+}
+
+// ThinkingFixer enforces max_tokens > thinking.budget_tokens, as Claude API requires, per the configured policy
+// Only the max_tokens value is ever rewritten; every other byte of the body is forwarded as sent
+type ThinkingFixer struct {
+	policy string // config.ThinkingFixAutofix, config.ThinkingFixReject or config.ThinkingFixOff
+}
+
+// NewThinkingFixer creates a thinking parameter fixer for a proxy.thinking_fix policy (empty means autofix)
+func NewThinkingFixer(policy string) *ThinkingFixer {
+	if policy == "" {
+		policy = config.ThinkingFixAutofix
+	}
+	return &ThinkingFixer{policy: policy}
+}
+
+// Apply checks a request body's thinking parameters, returning the body with the fix applied, if any
+// Bodies that are not JSON objects, or carry no integer budget_tokens and max_tokens, are returned unchanged
+func (f *ThinkingFixer) Apply(bodyBytes []byte) ([]byte, *ThinkingFix, error) {
+	if f.policy == config.ThinkingFixOff || len(bodyBytes) == 0 {
+		return bodyBytes, nil, nil
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(bodyBytes, &fields); err != nil {
+		// Not JSON or invalid - let Claude API handle it
+		return bodyBytes, nil, nil
+	}
+
+	var thinking map[string]json.RawMessage
+	if err := json.Unmarshal(fields["thinking"], &thinking); err != nil || thinking == nil {
+		// No thinking configuration - pass through
+		return bodyBytes, nil, nil
+	}
+
+	budgetTokens, hasBudget := rawInteger(thinking["budget_tokens"])
+	maxTokens, hasMaxTokens := rawInteger(fields["max_tokens"])
+	if !hasBudget || !hasMaxTokens || maxTokens > budgetTokens {
+		return bodyBytes, nil, nil
+	}
+
+	if f.policy == config.ThinkingFixReject {
+		return nil, nil, &ThinkingParamsError{MaxTokens: maxTokens, BudgetTokens: budgetTokens}
+	}
+
+	// Add reasonable buffer (10% of budget_tokens or minimum 1024 tokens)
+	buffer := max(budgetTokens/10, 1024)
+	fix := &ThinkingFix{
+		MaxTokens:         maxTokens,
+		BudgetTokens:      budgetTokens,
+		AdjustedMaxTokens: budgetTokens + buffer,
+		Buffer:            buffer,
+	}
+
+	start, end, ok := topLevelValueSpan(bodyBytes, "max_tokens")
+	if !ok {
+		return nil, nil, fmt.Errorf("failed to locate max_tokens in request body")
+	}
+
+	fixed := make([]byte, 0, len(bodyBytes)+8)
+	fixed = append(fixed, bodyBytes[:start]...)
+	fixed = strconv.AppendInt(fixed, fix.AdjustedMaxTokens, 10)
+	fixed = append(fixed, bodyBytes[end:]...)
+	return fixed, fix, nil
+}
+
+// rawInteger parses a JSON integer without float64 rounding; strings and fractions are rejected
+func rawInteger(raw json.RawMessage) (int64, bool) {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 || raw[0] == '"' {
+		return 0, false
+	}
+
+	var number json.Number
+	if err := json.Unmarshal(raw, &number); err != nil {
+		return 0, false
+	}
+	value, err := number.Int64()
+	if err != nil {
+		return 0, false
+	}
+	return value, true
+}
+
+// topLevelValueSpan returns the byte range of a top-level field's value in a JSON object
+// (the last occurrence when the key is repeated, matching which value Claude API reads)
+func topLevelValueSpan(bodyBytes []byte, key string) (int, int, bool) {
+	decoder := json.NewDecoder(bytes.NewReader(bodyBytes))
+	if token, err := decoder.Token(); err != nil || token != json.Delim('{') {
+		return 0, 0, false
+	}
+
+	start, end, found := 0, 0, false
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return 0, 0, false
+		}
+		name, _ := token.(string)
+
+		// RawMessage holds the value's exact bytes, which end at the decoder's offset
+		var value json.RawMessage
+		if err := decoder.Decode(&value); err != nil {
+			return 0, 0, false
+		}
+		if name == key {
+			end = int(decoder.InputOffset())
+			start = end - len(value)
+			found = true
+		}
+	}
+	return start, end, found
+}
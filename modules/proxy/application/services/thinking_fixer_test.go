@@ -0,0 +1,124 @@
+package services
+
+import (
+	"testing"
+
+	"claude-proxy/config"
+)
+
+func TestThinkingFixerApply(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		want    string // Body forwarded; empty for unchanged
+		wantFix *ThinkingFix
+	}{
+		{
+			name:    "max_tokens at the budget",
+			body:    `{"model":"m","max_tokens":4096,"thinking":{"type":"enabled","budget_tokens":4096}}`,
+			want:    `{"model":"m","max_tokens":5120,"thinking":{"type":"enabled","budget_tokens":4096}}`,
+			wantFix: &ThinkingFix{MaxTokens: 4096, BudgetTokens: 4096, AdjustedMaxTokens: 5120, Buffer: 1024},
+		},
+		{
+			name: "valid parameters",
+			body: `{"max_tokens":8192,"thinking":{"type":"enabled","budget_tokens":4096}}`,
+		},
+		{
+			name: "large integer IDs kept digit for digit",
+			body: `{"metadata":{"user_id":"u"},"request_seq":9007199254740993,"max_tokens":100,` +
+				`"messages":[{"role":"user","content":[{"type":"tool_result","tool_use_id":"toolu_1",` +
+				`"content":"{\"order_id\":123456789012345678901}"}]}],"trace":18446744073709551615,` +
+				`"thinking":{"type":"enabled","budget_tokens":20000}}`,
+			want: `{"metadata":{"user_id":"u"},"request_seq":9007199254740993,"max_tokens":22000,` +
+				`"messages":[{"role":"user","content":[{"type":"tool_result","tool_use_id":"toolu_1",` +
+				`"content":"{\"order_id\":123456789012345678901}"}]}],"trace":18446744073709551615,` +
+				`"thinking":{"type":"enabled","budget_tokens":20000}}`,
+			wantFix: &ThinkingFix{MaxTokens: 100, BudgetTokens: 20000, AdjustedMaxTokens: 22000, Buffer: 2000},
+		},
+		{
+			// Rounded to float64, both would be 2^53 and look equal
+			name: "integers beyond float64 precision",
+			body: `{"max_tokens":9007199254740993,"thinking":{"type":"enabled","budget_tokens":9007199254740992}}`,
+		},
+		{
+			name: "max_tokens beyond int64",
+			body: `{"max_tokens":99999999999999999999,"thinking":{"type":"enabled","budget_tokens":4096}}`,
+		},
+		{
+			name: "nested max_tokens left alone",
+			body: `{"tools":[{"name":"t","input_schema":{"type":"object","max_tokens":1}}],"max_tokens":1000,` +
+				`"thinking":{"type":"enabled","budget_tokens":2048,"options":{"max_tokens":1,"budget_tokens":1}}}`,
+			want: `{"tools":[{"name":"t","input_schema":{"type":"object","max_tokens":1}}],"max_tokens":3072,` +
+				`"thinking":{"type":"enabled","budget_tokens":2048,"options":{"max_tokens":1,"budget_tokens":1}}}`,
+			wantFix: &ThinkingFix{MaxTokens: 1000, BudgetTokens: 2048, AdjustedMaxTokens: 3072, Buffer: 1024},
+		},
+		{
+			name: "thinking blocks in messages are not a thinking config",
+			body: `{"max_tokens":10,"messages":[{"role":"assistant","content":[{"type":"thinking",` +
+				`"thinking":"...","budget_tokens":4096}]}]}`,
+		},
+		{
+			name: "budget nested below thinking",
+			body: `{"max_tokens":10,"thinking":{"type":"enabled","config":{"budget_tokens":4096}}}`,
+		},
+		{
+			name:    "repeated max_tokens: the last one counts",
+			body:    `{"max_tokens":99999,"max_tokens":100,"thinking":{"budget_tokens":2048}}`,
+			want:    `{"max_tokens":99999,"max_tokens":3072,"thinking":{"budget_tokens":2048}}`,
+			wantFix: &ThinkingFix{MaxTokens: 100, BudgetTokens: 2048, AdjustedMaxTokens: 3072, Buffer: 1024},
+		},
+		{
+			name:    "pretty-printed body",
+			body:    "{\n  \"max_tokens\" :  100 ,\n  \"thinking\": {\"budget_tokens\": 2048}\n}",
+			want:    "{\n  \"max_tokens\" :  3072 ,\n  \"thinking\": {\"budget_tokens\": 2048}\n}",
+			wantFix: &ThinkingFix{MaxTokens: 100, BudgetTokens: 2048, AdjustedMaxTokens: 3072, Buffer: 1024},
+		},
+		{name: "budget as a string", body: `{"max_tokens":10,"thinking":{"budget_tokens":"4096"}}`},
+		{name: "budget as a fraction", body: `{"max_tokens":10,"thinking":{"budget_tokens":4096.5}}`},
+		{name: "thinking disabled without budget", body: `{"max_tokens":10,"thinking":{"type":"disabled"}}`},
+		{name: "thinking null", body: `{"max_tokens":10,"thinking":null}`},
+		{name: "thinking not an object", body: `{"max_tokens":10,"thinking":[{"budget_tokens":4096}]}`},
+		{name: "not JSON", body: `max_tokens=10`},
+		{name: "JSON array", body: `[{"max_tokens":10,"thinking":{"budget_tokens":4096}}]`},
+	}
+	fixer := NewThinkingFixer("")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, fix, err := fixer.Apply([]byte(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := tt.want
+			if want == "" {
+				want = tt.body
+			}
+			if string(got) != want {
+				t.Errorf("body =\n%s\nwant\n%s", got, want)
+			}
+			switch {
+			case (fix == nil) != (tt.wantFix == nil):
+				t.Errorf("fix = %+v, want %+v", fix, tt.wantFix)
+			case fix != nil && *fix != *tt.wantFix:
+				t.Errorf("fix = %+v, want %+v", *fix, *tt.wantFix)
+			}
+		})
+	}
+}
+
+func TestThinkingFixerPolicies(t *testing.T) {
+	const body = `{"max_tokens":100,"thinking":{"type":"enabled","budget_tokens":2048}}`
+
+	got, fix, err := NewThinkingFixer(config.ThinkingFixOff).Apply([]byte(body))
+	if err != nil || fix != nil || string(got) != body {
+		t.Errorf("off: Apply() = %s, %+v, %v; want the body unchanged", got, fix, err)
+	}
+
+	_, _, err = NewThinkingFixer(config.ThinkingFixReject).Apply([]byte(body))
+	if !isThinkingParamsError(err) {
+		t.Fatalf("reject: error = %v, want a ThinkingParamsError", err)
+	}
+	want := "max_tokens (100) must be greater than thinking.budget_tokens (2048) when extended thinking is enabled"
+	if err.Error() != want {
+		t.Errorf("reject: error = %q, want %q", err, want)
+	}
+}
@@ -0,0 +1,180 @@
+package services
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"claude-proxy/modules/auth/domain/entities"
+	proxyentities "claude-proxy/modules/proxy/domain/entities"
+	proxyinterfaces "claude-proxy/modules/proxy/domain/interfaces"
+)
+
+const (
+	trafficSlotSize  = 10 * time.Second // Granularity of the rolling windows
+	trafficSlotCount = 360              // One hour of slots
+)
+
+// latencyBucketBounds are the upper bounds of the latency histogram (the last bucket is unbounded)
+var latencyBucketBounds = []time.Duration{
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2 * time.Second,
+	5 * time.Second,
+	10 * time.Second,
+	20 * time.Second,
+	30 * time.Second,
+	time.Minute,
+	2 * time.Minute,
+	5 * time.Minute,
+}
+
+// trafficSlot counts the requests completed during one slot of the ring buffer
+type trafficSlot struct {
+	index    int64 // Slot number since the Unix epoch; a stale index means the slot is empty
+	requests int64
+	errors   int64
+}
+
+// TrafficMetrics keeps startup totals in atomic counters and the last hour in a ring of 10-second slots
+type TrafficMetrics struct {
+	startedAt      time.Time
+	requests       atomic.Int64
+	errors         atomic.Int64
+	serverErrors   atomic.Int64
+	inFlight       atomic.Int64
+	inputTokens    atomic.Int64
+	outputTokens   atomic.Int64
+	cacheCreation  atomic.Int64
+	cacheRead      atomic.Int64
+	latencyCount   atomic.Int64
+	latencyTotalMs atomic.Int64
+	latencyBuckets []atomic.Int64 // One more than latencyBucketBounds (overflow bucket)
+
+	slots   [trafficSlotCount]trafficSlot
+	slotsMu sync.Mutex
+}
+
+// NewTrafficMetrics creates empty traffic counters
+func NewTrafficMetrics() proxyinterfaces.TrafficMetrics {
+	return &TrafficMetrics{
+		startedAt:      time.Now(),
+		latencyBuckets: make([]atomic.Int64, len(latencyBucketBounds)+1),
+	}
+}
+
+// RequestStarted marks a request as in flight
+func (m *TrafficMetrics) RequestStarted() {
+	m.inFlight.Add(1)
+}
+
+// RequestFinished records a completed request and ends its in-flight period
+func (m *TrafficMetrics) RequestFinished(sample *entities.RequestSample) {
+	m.inFlight.Add(-1)
+	m.requests.Add(1)
+	failed := sample.IsError()
+	if failed {
+		m.errors.Add(1)
+	}
+	if sample.IsServerError() {
+		m.serverErrors.Add(1)
+	}
+	m.inputTokens.Add(int64(sample.InputTokens))
+	m.outputTokens.Add(int64(sample.OutputTokens))
+	m.cacheCreation.Add(int64(sample.CacheCreationTokens))
+	m.cacheRead.Add(int64(sample.CacheReadTokens))
+
+	// Latency only describes requests that got an upstream response
+	if sample.StatusCode != 0 {
+		m.latencyCount.Add(1)
+		m.latencyTotalMs.Add(sample.Latency.Milliseconds())
+		m.latencyBuckets[latencyBucket(sample.Latency)].Add(1)
+	}
+
+	index := time.Now().UnixNano() / int64(trafficSlotSize)
+	m.slotsMu.Lock()
+	slot := &m.slots[index%trafficSlotCount]
+	if slot.index != index {
+		*slot = trafficSlot{index: index}
+	}
+	slot.requests++
+	if failed {
+		slot.errors++
+	}
+	m.slotsMu.Unlock()
+}
+
+// Snapshot returns the current counters
+func (m *TrafficMetrics) Snapshot() proxyentities.TrafficSnapshot {
+	snapshot := proxyentities.TrafficSnapshot{
+		StartedAt:    m.startedAt,
+		Requests:     m.requests.Load(),
+		Errors:       m.errors.Load(),
+		ServerErrors: m.serverErrors.Load(),
+		InFlight:     m.inFlight.Load(),
+		InputTokens:  m.inputTokens.Load(),
+		OutputTokens: m.outputTokens.Load(),
+
+		CacheCreationTokens: m.cacheCreation.Load(),
+		CacheReadTokens:     m.cacheRead.Load(),
+	}
+
+	// Rolling windows include the current (partial) slot
+	current := time.Now().UnixNano() / int64(trafficSlotSize)
+	minuteSlots := int64(time.Minute / trafficSlotSize)
+	fiveMinuteSlots := int64(5 * time.Minute / trafficSlotSize)
+	m.slotsMu.Lock()
+	for _, slot := range m.slots {
+		age := current - slot.index
+		if age < 0 || age >= trafficSlotCount {
+			continue
+		}
+		snapshot.RequestsLastHour += slot.requests
+		snapshot.ErrorsLastHour += slot.errors
+		if age < fiveMinuteSlots {
+			snapshot.RequestsLast5Min += slot.requests
+			snapshot.ErrorsLast5Min += slot.errors
+		}
+		if age < minuteSlots {
+			snapshot.RequestsLastMinute += slot.requests
+			snapshot.ErrorsLastMinute += slot.errors
+		}
+	}
+	m.slotsMu.Unlock()
+
+	if count := m.latencyCount.Load(); count > 0 {
+		snapshot.AverageLatency = time.Duration(m.latencyTotalMs.Load()/count) * time.Millisecond
+		snapshot.P95Latency = m.latencyPercentile(count, 0.95)
+	}
+	return snapshot
+}
+
+// latencyPercentile returns the upper bound of the histogram bucket holding the given percentile
+// The overflow bucket reports the largest bound
+func (m *TrafficMetrics) latencyPercentile(count int64, percentile float64) time.Duration {
+	target := int64(float64(count)*percentile + 0.5)
+	if target < 1 {
+		target = 1
+	}
+
+	var seen int64
+	for i := range latencyBucketBounds {
+		seen += m.latencyBuckets[i].Load()
+		if seen >= target {
+			return latencyBucketBounds[i]
+		}
+	}
+	return latencyBucketBounds[len(latencyBucketBounds)-1]
+}
+
+// latencyBucket returns the histogram bucket of a latency
+func latencyBucket(latency time.Duration) int {
+	for i, bound := range latencyBucketBounds {
+		if latency <= bound {
+			return i
+		}
+	}
+	return len(latencyBucketBounds)
+}
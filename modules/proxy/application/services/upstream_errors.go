@@ -0,0 +1,111 @@
+package services
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"net/http"
+	"syscall"
+
+	apperrors "claude-proxy/pkg/errors"
+)
+
+// Proxy error codes, returned in the error body and recorded in the token's usage statistics
+// Upstream HTTP error responses are relayed verbatim and never carry one of these codes
+const (
+	ErrCodeRequestTooLarge       = "REQUEST_TOO_LARGE"           // 413: request body exceeds the server limit
+	ErrCodeInvalidRequestBody    = "INVALID_REQUEST_BODY"        // 400: request body could not be read
+	ErrCodeInvalidJSON           = "INVALID_JSON"                // 400: JSON body of a /v1/messages request is malformed or empty
+	ErrCodeInvalidMessages       = "INVALID_MESSAGE_SEQUENCE"    // 400: messages could not be normalized into a valid sequence
+	ErrCodeInvalidThinkingParams = "INVALID_THINKING_PARAMS"     // 400: max_tokens not above thinking.budget_tokens (thinking_fix: reject)
+	ErrCodeRequestRewriteFailed  = "REQUEST_REWRITE_FAILED"      // 500: shaping or thinking fix could not re-encode the body
+	ErrCodeSessionCheckFailed    = "SESSION_CHECK_FAILED"        // 500: session limits could not be evaluated
+	ErrCodeModelNotSupported     = "MODEL_NOT_SUPPORTED"         // 400: no account serves the requested model (supported_models)
+	ErrCodeNoAvailableAccount    = "NO_AVAILABLE_ACCOUNT"        // 503: every account is rate limited, invalid, inactive or over quota
+	ErrCodeAccountsRateLimited   = "ACCOUNTS_RATE_LIMITED"       // 429: no account recovers within proxy.max_queue_wait
+	ErrCodeQueueFull             = "REQUEST_QUEUE_FULL"          // 429: too many requests already wait for an account
+	ErrCodeAccountTokenFailed    = "ACCOUNT_TOKEN_UNAVAILABLE"   // 503: the selected account's access token could not be refreshed
+	ErrCodeBatchAccountGone      = "BATCH_ACCOUNT_UNAVAILABLE"   // 503: the account owning the requested batch was deleted or disabled
+	ErrCodeFileAccountGone       = "FILE_ACCOUNT_UNAVAILABLE"    // 503: the account a referenced file was uploaded to was deleted or disabled
+	ErrCodeUpstreamTimeout       = "UPSTREAM_TIMEOUT"            // 504: no upstream response in time
+	ErrCodeUpstreamDNS           = "UPSTREAM_DNS_ERROR"          // 502: upstream (or egress proxy) host could not be resolved
+	ErrCodeUpstreamTLS           = "UPSTREAM_TLS_ERROR"          // 502: TLS handshake or certificate verification failed
+	ErrCodeUpstreamRefused       = "UPSTREAM_CONNECTION_REFUSED" // 503: upstream (or egress proxy) refused the connection
+	ErrCodeUpstreamConnection    = "UPSTREAM_CONNECTION_ERROR"   // 502: connection reset or other transport failure
+)
+
+// errMessageUpstreamUnavailable is the message of transport failures other than timeouts
+const errMessageUpstreamUnavailable = "Upstream request failed"
+
+// classifyTransportError maps a failure to reach Claude API (no HTTP response) to a proxy error
+func classifyTransportError(err error) apperrors.AppError {
+	var netErr net.Error
+	var dnsErr *net.DNSError
+	var certErr *tls.CertificateVerificationError
+	var recordErr tls.RecordHeaderError
+	var unknownAuthorityErr x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var certInvalidErr x509.CertificateInvalidError
+
+	switch {
+	case errors.As(err, &dnsErr):
+		// Checked before timeouts: a DNS lookup timeout is still a resolution problem
+		return apperrors.NewProxyError(
+			http.StatusBadGateway, ErrCodeUpstreamDNS, errMessageUpstreamUnavailable, err.Error(),
+		)
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return apperrors.NewProxyError(
+			http.StatusGatewayTimeout, ErrCodeUpstreamTimeout, "Upstream request timed out", err.Error(),
+		)
+	case errors.As(err, &certErr), errors.As(err, &recordErr), errors.As(err, &unknownAuthorityErr),
+		errors.As(err, &hostnameErr), errors.As(err, &certInvalidErr):
+		return apperrors.NewProxyError(
+			http.StatusBadGateway, ErrCodeUpstreamTLS, errMessageUpstreamUnavailable, err.Error(),
+		)
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return apperrors.NewProxyError(
+			http.StatusServiceUnavailable, ErrCodeUpstreamRefused, errMessageUpstreamUnavailable, err.Error(),
+		)
+	default:
+		return apperrors.NewProxyError(
+			http.StatusBadGateway, ErrCodeUpstreamConnection, errMessageUpstreamUnavailable, err.Error(),
+		)
+	}
+}
+
+// classifyBodyReadError maps a failure to read the client's request body to a proxy error
+func classifyBodyReadError(err error) apperrors.AppError {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return apperrors.NewProxyError(
+			http.StatusRequestEntityTooLarge, ErrCodeRequestTooLarge, "Request body too large", err.Error(),
+		)
+	}
+	return apperrors.NewBadRequestError(ErrCodeInvalidRequestBody, "Failed to read request body", err.Error())
+}
+
+// isUpstreamUnavailable returns true for proxy errors caused by accounts or Claude API being unreachable
+// (as opposed to client-side failures such as session limits or unreadable bodies)
+func isUpstreamUnavailable(err error) bool {
+	var appErr apperrors.AppError
+	if !errors.As(err, &appErr) {
+		return false
+	}
+	switch appErr.StatusCode() {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+// errorCodeOf returns the proxy error code carried by err (empty if it has none)
+func errorCodeOf(err error) string {
+	var appErr apperrors.AppError
+	if errors.As(err, &appErr) {
+		return appErr.ErrorCode()
+	}
+	return ""
+}
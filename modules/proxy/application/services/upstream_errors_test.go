@@ -0,0 +1,174 @@
+package services
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+
+	"claude-proxy/pkg/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// stubTransport fails every round trip with err, as a broken network path would
+type stubTransport struct {
+	err error
+}
+
+func (s stubTransport) RoundTrip(*http.Request) (*http.Response, error) {
+	return nil, s.err
+}
+
+// timeoutError is a net.Error reporting a timeout
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestClassifyTransportError(t *testing.T) {
+	dialErr := func(err error) error {
+		return &net.OpError{Op: "dial", Net: "tcp", Err: err}
+	}
+
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+		wantCode   string
+	}{
+		{
+			name:       "dns",
+			err:        dialErr(&net.DNSError{Err: "no such host", Name: "api.anthropic.com", IsNotFound: true}),
+			wantStatus: http.StatusBadGateway,
+			wantCode:   ErrCodeUpstreamDNS,
+		},
+		{
+			name:       "dns timeout",
+			err:        dialErr(&net.DNSError{Err: "timeout", Name: "api.anthropic.com", IsTimeout: true}),
+			wantStatus: http.StatusBadGateway,
+			wantCode:   ErrCodeUpstreamDNS,
+		},
+		{
+			name:       "tls",
+			err:        &tls.CertificateVerificationError{Err: errors.New("certificate signed by unknown authority")},
+			wantStatus: http.StatusBadGateway,
+			wantCode:   ErrCodeUpstreamTLS,
+		},
+		{
+			name:       "timeout",
+			err:        dialErr(timeoutError{}),
+			wantStatus: http.StatusGatewayTimeout,
+			wantCode:   ErrCodeUpstreamTimeout,
+		},
+		{
+			name:       "connection refused",
+			err:        dialErr(os.NewSyscallError("connect", syscall.ECONNREFUSED)),
+			wantStatus: http.StatusServiceUnavailable,
+			wantCode:   ErrCodeUpstreamRefused,
+		},
+		{
+			name:       "connection reset",
+			err:        dialErr(os.NewSyscallError("read", syscall.ECONNRESET)),
+			wantStatus: http.StatusBadGateway,
+			wantCode:   ErrCodeUpstreamConnection,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &http.Client{Transport: stubTransport{err: tt.err}}
+			_, err := client.Get("https://api.anthropic.com/v1/messages")
+			if err == nil {
+				t.Fatal("request through the stub transport succeeded")
+			}
+
+			appErr := classifyTransportError(err)
+			if appErr.StatusCode() != tt.wantStatus || appErr.ErrorCode() != tt.wantCode {
+				t.Errorf("classifyTransportError() = %d %s, want %d %s",
+					appErr.StatusCode(), appErr.ErrorCode(), tt.wantStatus, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestClassifyBodyReadError(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       io.ReadCloser
+		wantStatus int
+		wantCode   string
+	}{
+		{
+			name:       "too large",
+			body:       http.MaxBytesReader(nil, io.NopCloser(strings.NewReader(strings.Repeat("x", 64))), 16),
+			wantStatus: http.StatusRequestEntityTooLarge,
+			wantCode:   ErrCodeRequestTooLarge,
+		},
+		{
+			name:       "unreadable",
+			body:       io.NopCloser(iotestErrReader{}),
+			wantStatus: http.StatusBadRequest,
+			wantCode:   ErrCodeInvalidRequestBody,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := io.ReadAll(tt.body)
+			if err == nil {
+				t.Fatal("reading the body succeeded")
+			}
+
+			appErr := classifyBodyReadError(err)
+			if appErr.StatusCode() != tt.wantStatus || appErr.ErrorCode() != tt.wantCode {
+				t.Errorf("classifyBodyReadError() = %d %s, want %d %s",
+					appErr.StatusCode(), appErr.ErrorCode(), tt.wantStatus, tt.wantCode)
+			}
+		})
+	}
+}
+
+// iotestErrReader fails every read, as a client dropping mid-upload does
+type iotestErrReader struct{}
+
+func (iotestErrReader) Read([]byte) (int, error) { return 0, io.ErrUnexpectedEOF }
+
+func TestTransportErrorEnvelopeCarriesCode(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	client := &http.Client{Transport: stubTransport{err: &net.OpError{Op: "dial", Err: timeoutError{}}}}
+	_, err := client.Get("https://api.anthropic.com/v1/messages")
+	appErr := classifyTransportError(err)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	middleware.AbortWithAnthropicErrorCode(c, appErr.StatusCode(), appErr.ErrorCode(), appErr.Message())
+
+	var envelope struct {
+		Type  string `json:"type"`
+		Error struct {
+			Type    string `json:"type"`
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &envelope); err != nil {
+		t.Fatalf("invalid error body %q: %v", w.Body.String(), err)
+	}
+	if w.Code != http.StatusGatewayTimeout {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusGatewayTimeout)
+	}
+	if envelope.Type != "error" || envelope.Error.Type != "timeout_error" ||
+		envelope.Error.Code != ErrCodeUpstreamTimeout {
+		t.Errorf("envelope = %+v, want a timeout_error with code %s", envelope, ErrCodeUpstreamTimeout)
+	}
+}
@@ -0,0 +1,375 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"claude-proxy/modules/auth/domain/entities"
+	authinterfaces "claude-proxy/modules/auth/domain/interfaces"
+	proxyentities "claude-proxy/modules/proxy/domain/entities"
+	proxyinterfaces "claude-proxy/modules/proxy/domain/interfaces"
+	"claude-proxy/modules/proxy/infrastructure/clients"
+
+	sctx "github.com/phathdt/service-context"
+)
+
+const (
+	// usageReportPath is Claude API's organization usage report of the Messages API
+	usageReportPath = "/v1/organizations/usage_report/messages"
+
+	// usageReportMaxPages bounds the pages followed per account and collection
+	usageReportMaxPages = 10
+
+	// usageReportTimeout bounds the collection of one account's report
+	usageReportTimeout = time.Minute
+
+	// upstreamUsageRetention is how long daily usage is kept
+	upstreamUsageRetention = 90 * 24 * time.Hour
+)
+
+// usageReportPage is one page of an organization usage report with daily buckets
+type usageReportPage struct {
+	Data []struct {
+		StartingAt time.Time `json:"starting_at"`
+		Results    []struct {
+			UncachedInputTokens int64 `json:"uncached_input_tokens"`
+			CacheCreation       struct {
+				Ephemeral1hInputTokens int64 `json:"ephemeral_1h_input_tokens"`
+				Ephemeral5mInputTokens int64 `json:"ephemeral_5m_input_tokens"`
+			} `json:"cache_creation"`
+			CacheReadInputTokens int64 `json:"cache_read_input_tokens"`
+			OutputTokens         int64 `json:"output_tokens"`
+		} `json:"results"`
+	} `json:"data"`
+	HasMore  bool   `json:"has_more"`
+	NextPage string `json:"next_page"`
+}
+
+// UpstreamUsageService keeps the official and locally counted daily usage of accounts in memory and syncs it
+// to upstream_usage.json
+// Official usage comes from Claude API's organization usage reports, which only admin-capable accounts
+// (flagged by an admin) may read; an account refused with a 403 is not asked again until it is re-flagged,
+// so one missing permission doesn't fail every collection.
+type UpstreamUsageService struct {
+	accountSvc   authinterfaces.AccountService
+	claudeClient *clients.ClaudeAPIClient
+	repo         proxyinterfaces.UpstreamUsageRepository
+	days         int // Days covered by each collection, today included
+	usage        map[string]*proxyentities.AccountUpstreamUsage
+	dirty        bool
+	mu           sync.Mutex
+	logger       sctx.Logger
+}
+
+// NewUpstreamUsageService creates an upstream usage service and loads the stored usage from persistence
+func NewUpstreamUsageService(
+	accountSvc authinterfaces.AccountService,
+	claudeClient *clients.ClaudeAPIClient,
+	repo proxyinterfaces.UpstreamUsageRepository,
+	days int,
+	logger sctx.Logger,
+) proxyinterfaces.UpstreamUsage {
+	svc := &UpstreamUsageService{
+		accountSvc:   accountSvc,
+		claudeClient: claudeClient,
+		repo:         repo,
+		days:         days,
+		usage:        make(map[string]*proxyentities.AccountUpstreamUsage),
+		logger:       logger,
+	}
+
+	usage, err := repo.Load(context.Background())
+	if err != nil {
+		logger.Withs(sctx.Fields{"error": err.Error()}).Warn("Failed to load upstream usage from persistence")
+		return svc
+	}
+	for _, account := range usage {
+		svc.usage[account.AccountID] = account
+	}
+	logger.Withs(sctx.Fields{"count": len(svc.usage)}).Info("Upstream usage loaded from persistence")
+
+	return svc
+}
+
+// RecordLocal adds a proxied request's usage to the account's locally counted usage of the day
+func (s *UpstreamUsageService) RecordLocal(accountID string, usage proxyentities.Usage, at time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	account := s.accountUsage(accountID)
+	date := proxyentities.UsageDate(at)
+	day := account.Local[date]
+	day.Add(usage)
+	account.Local[date] = day
+	s.dirty = true
+}
+
+// Get returns a copy of the account's usage (nil if none was counted or collected)
+func (s *UpstreamUsageService) Get(accountID string) *proxyentities.AccountUpstreamUsage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	account, ok := s.usage[accountID]
+	if !ok {
+		return nil
+	}
+	return account.Clone()
+}
+
+// Reset clears the account's collection status so a refused account is tried again
+func (s *UpstreamUsageService) Reset(accountID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	account, ok := s.usage[accountID]
+	if !ok || account.Status == "" {
+		return
+	}
+	account.Status = ""
+	account.LastError = ""
+	s.dirty = true
+}
+
+// Collect fetches the usage reports of the admin-capable accounts, one at a time, and saves the results
+// Accounts an admin disabled, or whose credentials are invalid or conflicted, are skipped like refused ones.
+func (s *UpstreamUsageService) Collect(ctx context.Context) error {
+	accounts, err := s.accountSvc.ListAccounts(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list accounts: %w", err)
+	}
+
+	now := time.Now().UTC()
+	from := time.Date(now.Year(), now.Month(), now.Day()-(s.days-1), 0, 0, 0, 0, time.UTC)
+	for _, account := range accounts {
+		if !account.AdminCapable || s.refused(account.ID) {
+			continue
+		}
+		if account.Status != entities.AccountStatusActive && account.Status != entities.AccountStatusRateLimited {
+			continue
+		}
+		s.collectAccount(ctx, account, from, now)
+	}
+
+	return s.Sync(ctx)
+}
+
+// refused returns true if the account's usage reports were refused at the last collection
+func (s *UpstreamUsageService) refused(accountID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	account, ok := s.usage[accountID]
+	return ok && account.Status == proxyentities.UpstreamUsageStatusForbidden
+}
+
+// collectAccount fetches the account's daily usage from..to and records the outcome
+func (s *UpstreamUsageService) collectAccount(ctx context.Context, account *entities.Account, from, to time.Time) {
+	ctx, cancel := context.WithTimeout(ctx, usageReportTimeout)
+	defer cancel()
+
+	fields := sctx.Fields{
+		"account_id":   account.ID,
+		"account_name": account.Name,
+	}
+
+	days, statusCode, err := s.fetchReport(ctx, account, from, to)
+	now := time.Now()
+
+	s.mu.Lock()
+	usage := s.accountUsage(account.ID)
+	usage.LastAttemptAt = now
+	s.dirty = true
+	switch {
+	case err == nil:
+		usage.Status = proxyentities.UpstreamUsageStatusOK
+		usage.LastError = ""
+		usage.LastSuccessAt = now
+		for date, day := range days {
+			usage.Official[date] = day
+		}
+	case statusCode == http.StatusForbidden:
+		usage.Status = proxyentities.UpstreamUsageStatusForbidden
+		usage.LastError = err.Error()
+	default:
+		usage.Status = proxyentities.UpstreamUsageStatusError
+		usage.LastError = err.Error()
+	}
+	s.mu.Unlock()
+
+	switch {
+	case err == nil:
+		fields["days"] = len(days)
+		s.logger.Withs(fields).Debug("Upstream usage report collected")
+	case statusCode == http.StatusForbidden:
+		fields["error"] = err.Error()
+		s.logger.Withs(fields).Warn("Usage report refused, account skipped until it is flagged admin-capable again")
+	default:
+		fields["error"] = err.Error()
+		s.logger.Withs(fields).Warn("Failed to collect upstream usage report")
+	}
+}
+
+// fetchReport reads the account's usage report from..to, following its pages, and returns the usage per day
+// with the status code of a rejected request (0 if the request didn't get that far)
+func (s *UpstreamUsageService) fetchReport(
+	ctx context.Context,
+	account *entities.Account,
+	from, to time.Time,
+) (map[string]proxyentities.DailyUsage, int, error) {
+	accessToken, err := s.accountSvc.GetValidToken(ctx, account.ID)
+	if err != nil {
+		return nil, 0, fmt.Errorf("no valid access token: %w", err)
+	}
+	credential := clients.Credential{Secret: accessToken, APIKey: account.IsAPIKey()}
+
+	query := url.Values{}
+	query.Set("starting_at", from.Format(time.RFC3339))
+	query.Set("ending_at", to.Format(time.RFC3339))
+	query.Set("bucket_width", "1d")
+	query.Set("limit", "31")
+
+	days := make(map[string]proxyentities.DailyUsage)
+	for range usageReportMaxPages {
+		page, statusCode, err := s.fetchPage(ctx, account, credential, usageReportPath+"?"+query.Encode())
+		if err != nil {
+			return nil, statusCode, err
+		}
+
+		for _, bucket := range page.Data {
+			var day proxyentities.DailyUsage
+			for _, result := range bucket.Results {
+				day.InputTokens += result.UncachedInputTokens
+				day.OutputTokens += result.OutputTokens
+				day.CacheCreationInputTokens += result.CacheCreation.Ephemeral1hInputTokens +
+					result.CacheCreation.Ephemeral5mInputTokens
+				day.CacheReadInputTokens += result.CacheReadInputTokens
+			}
+			days[proxyentities.UsageDate(bucket.StartingAt)] = day
+		}
+
+		if !page.HasMore || page.NextPage == "" {
+			return days, 0, nil
+		}
+		query.Set("page", page.NextPage)
+	}
+	return days, 0, nil
+}
+
+// fetchPage requests one page of a usage report through the account
+func (s *UpstreamUsageService) fetchPage(
+	ctx context.Context,
+	account *entities.Account,
+	credential clients.Credential,
+	path string,
+) (*usageReportPage, int, error) {
+	resp, err := s.claudeClient.ProxyRequest(
+		ctx, http.MethodGet, path, credential, nil, account.BaseURL, account.ProxyURL, account.Headers,
+	)
+	if err != nil {
+		return nil, 0, fmt.Errorf("usage report request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 4<<20))
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read usage report: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, resp.StatusCode, fmt.Errorf(
+			"usage report rejected by Claude API (status %d): %s", resp.StatusCode, upstreamErrorMessage(body),
+		)
+	}
+
+	var page usageReportPage
+	if err := json.Unmarshal(body, &page); err != nil {
+		return nil, 0, fmt.Errorf("failed to decode usage report: %w", err)
+	}
+	return &page, 0, nil
+}
+
+// upstreamErrorMessage returns the message of a Claude API error body, or the start of a body that isn't one
+func upstreamErrorMessage(body []byte) string {
+	var payload struct {
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if json.Unmarshal(body, &payload) == nil && payload.Error.Message != "" {
+		return payload.Error.Message
+	}
+	text := strings.TrimSpace(string(body))
+	if len(text) > 200 {
+		text = text[:200] + "..."
+	}
+	return text
+}
+
+// accountUsage returns the account's usage, created on first use (callers hold mu)
+func (s *UpstreamUsageService) accountUsage(accountID string) *proxyentities.AccountUpstreamUsage {
+	account, ok := s.usage[accountID]
+	if !ok {
+		account = &proxyentities.AccountUpstreamUsage{AccountID: accountID}
+		s.usage[accountID] = account
+	}
+	if account.Official == nil {
+		account.Official = make(map[string]proxyentities.DailyUsage)
+	}
+	if account.Local == nil {
+		account.Local = make(map[string]proxyentities.DailyUsage)
+	}
+	return account
+}
+
+// Sync drops days past retention and writes the usage to persistent storage when it changed
+func (s *UpstreamUsageService) Sync(ctx context.Context) error {
+	cutoff := time.Now().Add(-upstreamUsageRetention)
+
+	s.mu.Lock()
+	for _, account := range s.usage {
+		before := len(account.Official) + len(account.Local)
+		account.Prune(cutoff)
+		if len(account.Official)+len(account.Local) != before {
+			s.dirty = true
+		}
+	}
+	if !s.dirty {
+		s.mu.Unlock()
+		return nil // No changes, skip sync
+	}
+
+	// Clear before saving so changes made during the save mark the usage dirty again
+	s.dirty = false
+	usage := make([]*proxyentities.AccountUpstreamUsage, 0, len(s.usage))
+	for _, account := range s.usage {
+		usage = append(usage, account.Clone())
+	}
+	s.mu.Unlock()
+
+	sort.Slice(usage, func(i, j int) bool {
+		return usage[i].AccountID < usage[j].AccountID
+	})
+
+	if err := s.repo.Save(ctx, usage); err != nil {
+		s.mu.Lock()
+		s.dirty = true
+		s.mu.Unlock()
+		return fmt.Errorf("failed to save upstream usage: %w", err)
+	}
+
+	s.logger.Withs(sctx.Fields{"count": len(usage)}).Debug("Upstream usage synced to persistent storage")
+	return nil
+}
+
+// FinalSync performs final sync on graceful shutdown
+func (s *UpstreamUsageService) FinalSync(ctx context.Context) error {
+	s.logger.Info("Performing final sync of upstream usage")
+	return s.Sync(ctx)
+}
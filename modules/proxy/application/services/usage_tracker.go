@@ -0,0 +1,236 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"claude-proxy/modules/proxy/domain/entities"
+)
+
+// UsageSummaryHeader requests a proxy_usage event at the end of a streaming response (value "summary")
+const UsageSummaryHeader = "anthropic-proxy-usage"
+
+// maxUsageBufferSize caps how much of a non-streaming response is buffered for usage extraction
+const maxUsageBufferSize = 10 << 20 // 10MB
+
+// usageEnvelope matches the usage-bearing parts of Claude API responses and SSE events
+type usageEnvelope struct {
+	Type    string          `json:"type"`
+	Model   string          `json:"model"`
+	Usage   *entities.Usage `json:"usage"`
+	Message *struct {
+		Model string          `json:"model"`
+		Usage *entities.Usage `json:"usage"`
+	} `json:"message"`
+}
+
+// usageTrackingBody wraps an upstream response body, extracts token usage while the body is relayed,
+// and reports it (with the model the response names) exactly once when the body is closed
+type usageTrackingBody struct {
+	io.ReadCloser
+	streaming bool
+	encoding  string // Content-Encoding of the relayed bytes; encoded bodies are parsed once decoded on Close
+	buf       []byte
+	usage     entities.Usage
+	model     string
+	once      sync.Once
+	onClose   func(usage entities.Usage, model string)
+	trailer   func(usage entities.Usage, model string) []byte // Appended to an SSE stream once upstream ends it
+	pending   []byte                                          // Trailer bytes not yet read (nil until the stream ends)
+}
+
+// newUsageTrackingBody wraps body; streaming selects SSE line parsing instead of whole-body JSON parsing
+// encoding is the response Content-Encoding ("" when the body is not compressed)
+func newUsageTrackingBody(
+	body io.ReadCloser,
+	streaming bool,
+	encoding string,
+	onClose func(usage entities.Usage, model string),
+) *usageTrackingBody {
+	if strings.EqualFold(encoding, "identity") {
+		encoding = ""
+	}
+	return &usageTrackingBody{
+		ReadCloser: body,
+		streaming:  streaming,
+		encoding:   encoding,
+		onClose:    onClose,
+	}
+}
+
+// withTrailer appends the bytes returned by trailer, given the stream's usage and model, after the last upstream
+// byte of an uncompressed SSE stream; streams cut short by an error get no trailer
+func (b *usageTrackingBody) withTrailer(trailer func(usage entities.Usage, model string) []byte) *usageTrackingBody {
+	if b.streaming && b.encoding == "" {
+		b.trailer = trailer
+	}
+	return b
+}
+
+// Read reads from the underlying body and inspects the bytes for usage data
+func (b *usageTrackingBody) Read(p []byte) (int, error) {
+	if b.pending != nil {
+		return b.readTrailer(p)
+	}
+
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 {
+		b.consume(p[:n])
+	}
+	if err == io.EOF && b.trailer != nil {
+		b.pending = b.endStream()
+		if n > 0 {
+			return n, nil
+		}
+		return b.readTrailer(p)
+	}
+	return n, err
+}
+
+// endStream parses the stream's unterminated last line, if any, and renders the trailer
+func (b *usageTrackingBody) endStream() []byte {
+	var trailer []byte
+	if len(b.buf) > 0 {
+		b.parseSSELine(b.buf)
+		b.buf = b.buf[:0]
+		// Terminate the last event so the trailer starts a new one
+		trailer = []byte("\n\n")
+	}
+	trailer = append(trailer, b.trailer(b.usage, b.model)...)
+	b.trailer = nil
+	return trailer
+}
+
+// readTrailer reads the trailer appended after the upstream stream
+func (b *usageTrackingBody) readTrailer(p []byte) (int, error) {
+	n := copy(p, b.pending)
+	b.pending = b.pending[n:]
+	if len(b.pending) == 0 {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// Close closes the underlying body and reports the collected usage
+func (b *usageTrackingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(func() {
+		if b.encoding != "" {
+			b.consumeEncoded()
+		}
+		if !b.streaming {
+			b.parseEvent(b.buf)
+		} else if len(b.buf) > 0 {
+			// The last SSE line may lack a trailing newline
+			b.parseSSELine(b.buf)
+		}
+		b.buf = nil
+		b.onClose(b.usage, b.model)
+	})
+	return err
+}
+
+// consumeEncoded decodes the buffered compressed bytes and parses them like an uncompressed body
+func (b *usageTrackingBody) consumeEncoded() {
+	encoded := b.buf
+	b.buf = nil
+	decoded, err := decodeBytes(b.encoding, encoded)
+	b.encoding = ""
+	if err != nil {
+		return
+	}
+	b.consume(decoded)
+}
+
+// consume buffers a chunk and, for SSE streams, parses every complete line
+// Compressed chunks are only buffered: they are decoded as a whole when the body is closed
+func (b *usageTrackingBody) consume(chunk []byte) {
+	if !b.streaming || b.encoding != "" {
+		if len(b.buf)+len(chunk) <= maxUsageBufferSize {
+			b.buf = append(b.buf, chunk...)
+		}
+		return
+	}
+
+	b.buf = append(b.buf, chunk...)
+	start := 0
+	for {
+		idx := bytes.IndexByte(b.buf[start:], '\n')
+		if idx < 0 {
+			break
+		}
+		b.parseSSELine(b.buf[start : start+idx])
+		start += idx + 1
+	}
+	b.buf = append(b.buf[:0], b.buf[start:]...)
+
+	// A single line should never get this large; drop it rather than grow unbounded
+	if len(b.buf) > maxUsageBufferSize {
+		b.buf = b.buf[:0]
+	}
+}
+
+// parseSSELine parses a single SSE line, picking usage out of data events
+func (b *usageTrackingBody) parseSSELine(line []byte) {
+	line = bytes.TrimRight(line, "\r")
+	if !bytes.HasPrefix(line, []byte("data:")) {
+		return
+	}
+	b.parseEvent(bytes.TrimSpace(line[len("data:"):]))
+}
+
+// parseEvent extracts usage and model from a JSON response body or SSE event payload
+func (b *usageTrackingBody) parseEvent(data []byte) {
+	if len(data) == 0 || !bytes.Contains(data, []byte(`"usage"`)) {
+		return
+	}
+
+	var envelope usageEnvelope
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return
+	}
+
+	if envelope.Model != "" {
+		b.model = envelope.Model
+	}
+	if envelope.Message != nil && envelope.Message.Model != "" {
+		b.model = envelope.Message.Model
+	}
+	if envelope.Message != nil && envelope.Message.Usage != nil {
+		b.usage.Merge(*envelope.Message.Usage)
+	}
+	if envelope.Usage != nil {
+		b.usage.Merge(*envelope.Usage)
+	}
+}
+
+// usageSummary is the payload of the proxy_usage event appended to streams that asked for it
+type usageSummary struct {
+	Type      string         `json:"type"`
+	AccountID string         `json:"account_id"`
+	Model     string         `json:"model,omitempty"`
+	Usage     entities.Usage `json:"usage"`
+	LatencyMs int64          `json:"latency_ms"` // From the proxy receiving the request to the end of the stream
+}
+
+// wantsUsageSummary returns true if a streaming response should end with a proxy_usage event
+func wantsUsageSummary(req *http.Request, tokenEnabled bool) bool {
+	return tokenEnabled || strings.EqualFold(strings.TrimSpace(req.Header.Get(UsageSummaryHeader)), "summary")
+}
+
+// usageSummaryEvent renders the proxy_usage SSE event closing a stream
+func usageSummaryEvent(accountID string, usage entities.Usage, model string, latency time.Duration) []byte {
+	data, _ := json.Marshal(usageSummary{
+		Type:      "proxy_usage",
+		AccountID: accountID,
+		Model:     model,
+		Usage:     usage,
+		LatencyMs: latency.Milliseconds(),
+	})
+	return []byte("event: proxy_usage\ndata: " + string(data) + "\n\n")
+}
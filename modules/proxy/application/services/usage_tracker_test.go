@@ -0,0 +1,199 @@
+package services
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"claude-proxy/modules/proxy/domain/entities"
+)
+
+// capturedStream is an SSE transcript as Claude API sends it for a short streaming message
+const capturedStream = "event: message_start\n" +
+	`data: {"type":"message_start","message":{"id":"msg_1","type":"message","role":"assistant",` +
+	`"model":"claude-sonnet-4-20250514","content":[],"stop_reason":null,` +
+	`"usage":{"input_tokens":25,"cache_creation_input_tokens":0,"cache_read_input_tokens":12,"output_tokens":1}}}` +
+	"\n\n" +
+	"event: content_block_start\n" +
+	`data: {"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}` + "\n\n" +
+	"event: ping\n" +
+	`data: {"type": "ping"}` + "\n\n" +
+	"event: content_block_delta\n" +
+	`data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Hello"}}` + "\n\n" +
+	"event: content_block_stop\n" +
+	`data: {"type":"content_block_stop","index":0}` + "\n\n" +
+	"event: message_delta\n" +
+	`data: {"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":15}}` + "\n\n" +
+	"event: message_stop\n" +
+	`data: {"type":"message_stop"}` + "\n\n"
+
+// capturedStreamWant is the usage of capturedStream
+var capturedStreamWant = entities.Usage{InputTokens: 25, CacheReadInputTokens: 12, OutputTokens: 15}
+
+// cutStream is a stream whose upstream connection ended in the middle of its last event, after the usage
+const cutStream = "event: message_start\n" +
+	`data: {"type":"message_start","message":{"model":"claude-sonnet-4",` +
+	`"usage":{"input_tokens":8,"output_tokens":1}}}` + "\n\n" +
+	"event: message_delta\n" +
+	`data: {"type":"message_delta","usage":{"output_tokens":4}}`
+
+// testJSONMessage is a non-streaming message response
+const testJSONMessage = `{"id":"msg_1","type":"message","model":"claude-sonnet-4","content":[],` +
+	`"usage":{"input_tokens":3,"output_tokens":1}}`
+
+// testTrailer renders a trailer event from the stream's usage
+func testTrailer(usage entities.Usage, model string) []byte {
+	return usageSummaryEvent("acc_1", usage, model, time.Second)
+}
+
+// trackedRead relays transcript through a usage tracking body read with reads of readSize bytes (0 = whole
+// chunks), returning the relayed bytes and the usage and model reported on Close
+func trackedRead(
+	t *testing.T,
+	transcript []byte,
+	streaming bool,
+	encoding string,
+	trailer bool,
+	readSize int,
+) ([]byte, entities.Usage, string) {
+	t.Helper()
+
+	var usage entities.Usage
+	var model string
+	closes := 0
+	body := newUsageTrackingBody(io.NopCloser(bytes.NewReader(transcript)), streaming, encoding,
+		func(u entities.Usage, m string) {
+			usage, model = u, m
+			closes++
+		})
+	if trailer {
+		body.withTrailer(testTrailer)
+	}
+
+	var reader io.Reader = body
+	if readSize == 1 {
+		reader = iotest.OneByteReader(body)
+	}
+	var out bytes.Buffer
+	buf := make([]byte, 32<<10)
+	if readSize > 1 {
+		buf = buf[:readSize]
+	}
+	for {
+		n, err := reader.Read(buf)
+		out.Write(buf[:n])
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	body.Close()
+	body.Close()
+	if closes != 1 {
+		t.Errorf("usage reported %d times, want once", closes)
+	}
+	return out.Bytes(), usage, model
+}
+
+func TestUsageTrackingBodyRelaysUntouchedWithoutTrailer(t *testing.T) {
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	zw.Write([]byte(capturedStream))
+	zw.Close()
+
+	tests := []struct {
+		name       string
+		transcript []byte
+		streaming  bool
+		encoding   string
+		trailer    bool // Asked for, but must not apply
+		wantUsage  entities.Usage
+	}{
+		{"stream", []byte(capturedStream), true, "", false, capturedStreamWant},
+		{"stream with CRLF lines", []byte(strings.ReplaceAll(capturedStream, "\n", "\r\n")), true, "", false,
+			capturedStreamWant},
+		{"cut stream", []byte(cutStream), true, "", false, entities.Usage{InputTokens: 8, OutputTokens: 4}},
+		{"gzip stream", gz.Bytes(), true, "gzip", true, capturedStreamWant},
+		{"JSON body", []byte(testJSONMessage), false, "", true, entities.Usage{InputTokens: 3, OutputTokens: 1}},
+	}
+	for _, tt := range tests {
+		for _, readSize := range []int{0, 1, 7} {
+			got, usage, _ := trackedRead(t, tt.transcript, tt.streaming, tt.encoding, tt.trailer, readSize)
+			if !bytes.Equal(got, tt.transcript) {
+				t.Errorf("%s, reads of %d: relayed bytes differ from upstream's", tt.name, readSize)
+			}
+			if usage != tt.wantUsage {
+				t.Errorf("%s, reads of %d: usage = %+v, want %+v", tt.name, readSize, usage, tt.wantUsage)
+			}
+		}
+	}
+}
+
+func TestUsageTrackingBodyTrailer(t *testing.T) {
+	tests := []struct {
+		name       string
+		transcript string
+		wantPrefix string // Relayed bytes before the trailer
+		wantUsage  entities.Usage
+		wantModel  string
+	}{
+		{"complete stream", capturedStream, capturedStream, capturedStreamWant, "claude-sonnet-4-20250514"},
+		{
+			name:       "unterminated last line",
+			transcript: cutStream,
+			wantPrefix: cutStream + "\n\n",
+			wantUsage:  entities.Usage{InputTokens: 8, OutputTokens: 4},
+			wantModel:  "claude-sonnet-4",
+		},
+		{"empty stream", "", "", entities.Usage{}, ""},
+	}
+	for _, tt := range tests {
+		for _, readSize := range []int{0, 1, 7} {
+			got, usage, model := trackedRead(t, []byte(tt.transcript), true, "", true, readSize)
+			if usage != tt.wantUsage || model != tt.wantModel {
+				t.Errorf("%s, reads of %d: usage = %+v, %q", tt.name, readSize, usage, model)
+			}
+
+			trailer := string(testTrailer(tt.wantUsage, tt.wantModel))
+			if string(got) != tt.wantPrefix+trailer {
+				t.Errorf("%s, reads of %d: relayed\n%q\nwant\n%q", tt.name, readSize, got, tt.wantPrefix+trailer)
+				continue
+			}
+
+			// The trailer is a well-formed event of its own
+			data, ok := strings.CutPrefix(trailer, "event: proxy_usage\ndata: ")
+			if !ok || !strings.HasSuffix(data, "\n\n") {
+				t.Fatalf("trailer %q is not one SSE event", trailer)
+			}
+			var summary usageSummary
+			if err := json.Unmarshal([]byte(strings.TrimSpace(data)), &summary); err != nil {
+				t.Fatal(err)
+			}
+			if summary.Type != "proxy_usage" || summary.Usage != tt.wantUsage || summary.AccountID != "acc_1" {
+				t.Errorf("%s: summary = %+v", tt.name, summary)
+			}
+		}
+	}
+}
+
+func TestUsageTrackingBodyTrailerSkippedOnError(t *testing.T) {
+	errCut := io.ErrUnexpectedEOF
+	upstream := io.MultiReader(strings.NewReader(cutStream), iotest.ErrReader(errCut))
+	body := newUsageTrackingBody(io.NopCloser(upstream), true, "", func(entities.Usage, string) {})
+	body.withTrailer(testTrailer)
+
+	got, err := io.ReadAll(body)
+	if err != errCut {
+		t.Errorf("error = %v, want %v", err, errCut)
+	}
+	if string(got) != cutStream {
+		t.Errorf("a stream cut by an error was relayed as %q, want it untouched", got)
+	}
+}
@@ -0,0 +1,65 @@
+package services
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"claude-proxy/modules/auth/domain/entities"
+)
+
+// Usage window reset headers sent by Claude API for subscription accounts (Unix timestamps in seconds)
+// The 5-hour specific header is preferred; the unified one may describe a longer (weekly) window
+var usageWindowResetHeaders = []string{
+	"anthropic-ratelimit-unified-5h-reset",
+	"anthropic-ratelimit-unified-reset",
+}
+
+// usageWindowResetFromHeaders returns the usage window reset time hinted by response headers
+// Hints further away than one usage window are ignored, as they cannot describe the 5-hour window
+func usageWindowResetFromHeaders(header http.Header) (time.Time, bool) {
+	for _, name := range usageWindowResetHeaders {
+		value := header.Get(name)
+		if value == "" {
+			continue
+		}
+		seconds, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			continue
+		}
+		resetAt := time.Unix(seconds, 0)
+		if resetAt.After(time.Now()) && !resetAt.After(time.Now().Add(entities.UsageWindow)) {
+			return resetAt, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// selectAccountWindowAware picks the account that has consumed the least of its current usage window
+// Ties go to the account whose window resets soonest (its remaining budget expires first);
+// accounts without an active window come after those with one, so idle subscriptions aren't started early
+func selectAccountWindowAware(accounts []*entities.Account) *entities.Account {
+	if len(accounts) == 0 {
+		return nil
+	}
+
+	ranked := append([]*entities.Account(nil), accounts...)
+	sort.SliceStable(ranked, func(i, j int) bool {
+		iRequests, iTokens := ranked[i].CurrentWindowUsage()
+		jRequests, jTokens := ranked[j].CurrentWindowUsage()
+		if iTokens != jTokens {
+			return iTokens < jTokens
+		}
+		if iRequests != jRequests {
+			return iRequests < jRequests
+		}
+
+		iActive, jActive := ranked[i].HasActiveUsageWindow(), ranked[j].HasActiveUsageWindow()
+		if iActive != jActive {
+			return iActive
+		}
+		return ranked[i].UsageWindowEnd().Before(ranked[j].UsageWindowEnd())
+	})
+	return ranked[0]
+}
@@ -0,0 +1,295 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"claude-proxy/config"
+	"claude-proxy/modules/proxy/application/dto"
+	proxyentities "claude-proxy/modules/proxy/domain/entities"
+	proxyinterfaces "claude-proxy/modules/proxy/domain/interfaces"
+	"claude-proxy/modules/proxy/infrastructure/clients"
+	"claude-proxy/pkg/uid"
+
+	sctx "github.com/phathdt/service-context"
+)
+
+const (
+	// maxWebhookFailures bounds the failures kept for GET /api/admin/webhooks/failures
+	maxWebhookFailures = 50
+
+	// maxWebhookRetryDelay caps the exponential backoff between delivery attempts
+	maxWebhookRetryDelay = 5 * time.Minute
+
+	// webhookDropLogInterval rate-limits the queue overflow warning
+	webhookDropLogInterval = time.Minute
+)
+
+// WebhookDispatcher queues usage events and delivers them with retries from background workers
+// The request path only ever does a non-blocking channel send: a full queue drops the event and
+// counts it, so a slow or unreachable webhook never delays proxied requests
+type WebhookDispatcher struct {
+	cfg    config.WebhooksConfig
+	events map[string]bool // Event types to deliver
+	client *clients.WebhookClient
+	queue  chan proxyentities.UsageEvent
+
+	ctx    context.Context // Canceled by Stop, aborting in-flight deliveries and backoff waits
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	delivered   atomic.Int64
+	failed      atomic.Int64
+	dropped     atomic.Int64
+	lastDropLog atomic.Int64 // Unix nanoseconds of the last overflow warning
+
+	failuresMu sync.Mutex
+	failures   []proxyentities.WebhookFailure // Oldest first, at most maxWebhookFailures
+
+	logger sctx.Logger
+}
+
+// NewWebhookDispatcher creates a dispatcher (a no-op when webhooks are disabled in config)
+// Every event type is delivered unless webhooks.events selects some
+func NewWebhookDispatcher(
+	cfg config.WebhooksConfig,
+	client *clients.WebhookClient,
+	logger sctx.Logger,
+) (proxyinterfaces.WebhookDispatcher, error) {
+	events := make(map[string]bool)
+	for _, event := range cfg.Events {
+		if !slices.Contains(proxyentities.WebhookEventTypes, event) {
+			return nil, fmt.Errorf(
+				"unknown webhook event %q: expected one of %v", event, proxyentities.WebhookEventTypes,
+			)
+		}
+		events[event] = true
+	}
+	if len(events) == 0 {
+		for _, event := range proxyentities.WebhookEventTypes {
+			events[event] = true
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &WebhookDispatcher{
+		cfg:    cfg,
+		events: events,
+		client: client,
+		queue:  make(chan proxyentities.UsageEvent, cfg.QueueSize),
+		ctx:    ctx,
+		cancel: cancel,
+		logger: logger,
+	}, nil
+}
+
+// Publish queues an event without blocking; the event is dropped when the queue is full
+func (d *WebhookDispatcher) Publish(event proxyentities.UsageEvent) {
+	if !d.cfg.Enabled || !d.events[event.Type] {
+		return
+	}
+	event.ID = uid.New()
+
+	select {
+	case d.queue <- event:
+	default:
+		dropped := d.dropped.Add(1)
+		now := time.Now().UnixNano()
+		last := d.lastDropLog.Load()
+		if now-last >= int64(webhookDropLogInterval) && d.lastDropLog.CompareAndSwap(last, now) {
+			d.logger.Withs(sctx.Fields{
+				"queue_size": d.cfg.QueueSize,
+				"dropped":    dropped,
+			}).Warn("Webhook queue full, dropping usage events")
+		}
+	}
+}
+
+// PublishAlert delivers an alert transition from its own goroutine, with the usual retries
+// Transitions are rare, so they bypass the queue and are never dropped for lack of room
+func (d *WebhookDispatcher) PublishAlert(transition proxyentities.AlertTransition) {
+	if !d.cfg.Enabled || !d.events[proxyentities.WebhookEventAlert] {
+		return
+	}
+
+	payload, err := json.Marshal(dto.ToAlertEventPayload(&transition))
+	if err != nil {
+		d.recordFailure(
+			transition.ID, proxyentities.WebhookEventAlert, "", 0, 0, fmt.Errorf("failed to encode event: %w", err),
+		)
+		return
+	}
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+		d.send(transition.ID, proxyentities.WebhookEventAlert, "", payload)
+	}()
+}
+
+// SendTest delivers a sample event once, bypassing the queue and the event filter
+func (d *WebhookDispatcher) SendTest(ctx context.Context) (int, error) {
+	if !d.cfg.Enabled {
+		return 0, fmt.Errorf("webhooks are disabled")
+	}
+
+	event := proxyentities.UsageEvent{
+		ID:         uid.New(),
+		Type:       proxyentities.WebhookEventTest,
+		Timestamp:  time.Now(),
+		TokenID:    "test",
+		AccountID:  "test",
+		Model:      "claude-sonnet-4-5",
+		StatusCode: http.StatusOK,
+		Latency:    1200 * time.Millisecond,
+		Usage:      proxyentities.Usage{InputTokens: 100, OutputTokens: 50},
+	}
+	payload, err := json.Marshal(dto.ToUsageEventPayload(&event))
+	if err != nil {
+		return 0, fmt.Errorf("failed to encode test event: %w", err)
+	}
+	return d.client.Deliver(ctx, event.ID, event.Type, payload)
+}
+
+// Status returns the delivery counters and the most recent failures (newest first)
+func (d *WebhookDispatcher) Status() proxyentities.WebhookStatus {
+	status := proxyentities.WebhookStatus{
+		Enabled:     d.cfg.Enabled,
+		QueueLength: len(d.queue),
+		QueueSize:   d.cfg.QueueSize,
+		Delivered:   d.delivered.Load(),
+		Failed:      d.failed.Load(),
+		Dropped:     d.dropped.Load(),
+	}
+	for _, event := range proxyentities.WebhookEventTypes {
+		if d.events[event] {
+			status.Events = append(status.Events, event)
+		}
+	}
+
+	d.failuresMu.Lock()
+	status.Failures = make([]proxyentities.WebhookFailure, 0, len(d.failures))
+	for i := len(d.failures) - 1; i >= 0; i-- {
+		status.Failures = append(status.Failures, d.failures[i])
+	}
+	d.failuresMu.Unlock()
+	return status
+}
+
+// Start launches the delivery workers
+func (d *WebhookDispatcher) Start() {
+	if !d.cfg.Enabled {
+		return
+	}
+	for range d.cfg.Workers {
+		d.wg.Add(1)
+		go d.work()
+	}
+	d.logger.Withs(sctx.Fields{
+		"workers":    d.cfg.Workers,
+		"queue_size": d.cfg.QueueSize,
+		"events":     d.Status().Events,
+	}).Info("Webhook dispatcher started")
+}
+
+// Stop cancels in-flight deliveries and waits for the workers; queued events are discarded
+func (d *WebhookDispatcher) Stop() {
+	d.cancel()
+	d.wg.Wait()
+
+	if pending := len(d.queue); pending > 0 {
+		d.logger.Withs(sctx.Fields{"pending": pending}).Warn("Webhook dispatcher stopped with undelivered events")
+	}
+}
+
+// work delivers queued events until the dispatcher stops
+func (d *WebhookDispatcher) work() {
+	defer d.wg.Done()
+	for {
+		select {
+		case <-d.ctx.Done():
+			return
+		case event := <-d.queue:
+			d.deliver(event)
+		}
+	}
+}
+
+// deliver sends one usage event
+func (d *WebhookDispatcher) deliver(event proxyentities.UsageEvent) {
+	payload, err := json.Marshal(dto.ToUsageEventPayload(&event))
+	if err != nil {
+		d.recordFailure(event.ID, event.Type, event.TokenID, 0, 0, fmt.Errorf("failed to encode event: %w", err))
+		return
+	}
+	d.send(event.ID, event.Type, event.TokenID, payload)
+}
+
+// send delivers a payload, retrying transport errors, 5xx and 429 with exponential backoff
+// Other 4xx responses mean the receiver rejected the event, so they are not retried
+func (d *WebhookDispatcher) send(eventID, eventType, tokenID string, payload []byte) {
+	attempts := d.cfg.MaxRetries + 1
+	for attempt := 1; ; attempt++ {
+		statusCode, err := d.client.Deliver(d.ctx, eventID, eventType, payload)
+		if err == nil {
+			d.delivered.Add(1)
+			return
+		}
+
+		retryable := statusCode == 0 || statusCode >= 500 || statusCode == http.StatusTooManyRequests
+		if !retryable || attempt == attempts || d.ctx.Err() != nil {
+			d.recordFailure(eventID, eventType, tokenID, attempt, statusCode, err)
+			return
+		}
+
+		delay := min(d.cfg.RetryDelay<<min(attempt-1, 16), maxWebhookRetryDelay) // Bounded shift can't overflow
+		d.logger.Withs(sctx.Fields{
+			"event_id":    eventID,
+			"attempt":     attempt,
+			"status_code": statusCode,
+			"error":       err.Error(),
+			"retry_in":    delay.String(),
+		}).Debug("Webhook delivery failed, retrying")
+
+		select {
+		case <-d.ctx.Done():
+			d.recordFailure(eventID, eventType, tokenID, attempt, statusCode, err)
+			return
+		case <-time.After(delay):
+		}
+	}
+}
+
+// recordFailure counts an event given up on and keeps it in the recent failures
+func (d *WebhookDispatcher) recordFailure(eventID, eventType, tokenID string, attempts, statusCode int, err error) {
+	d.failed.Add(1)
+	failure := proxyentities.WebhookFailure{
+		EventID:    eventID,
+		EventType:  eventType,
+		Attempts:   attempts,
+		StatusCode: statusCode,
+		Error:      err.Error(),
+		FailedAt:   time.Now(),
+	}
+
+	d.failuresMu.Lock()
+	d.failures = append(d.failures, failure)
+	if len(d.failures) > maxWebhookFailures {
+		d.failures = append(d.failures[:0], d.failures[len(d.failures)-maxWebhookFailures:]...)
+	}
+	d.failuresMu.Unlock()
+
+	d.logger.Withs(sctx.Fields{
+		"event_id":    eventID,
+		"event_type":  eventType,
+		"token_id":    tokenID,
+		"attempts":    attempts,
+		"status_code": statusCode,
+		"error":       err.Error(),
+	}).Warn("Webhook delivery failed")
+}
@@ -0,0 +1,56 @@
+package entities
+
+import "time"
+
+// AlertState is whether an alert rule's condition currently holds
+type AlertState string
+
+const (
+	AlertStateOK     AlertState = "ok"     // Condition false, not evaluated yet, or too few requests for a rate
+	AlertStateFiring AlertState = "firing" // Condition true since Since
+)
+
+// AlertRule is a condition on a statistics metric, e.g. server_error_rate > 5 over 5 minutes
+type AlertRule struct {
+	Name        string
+	Metric      string
+	Operator    string // >, >=, < or <=
+	Threshold   float64
+	Window      time.Duration // 0 for metrics read as is
+	Severity    string
+	MinRequests int64 // Rate metrics are not evaluated over fewer requests
+}
+
+// Breached returns true if value satisfies the rule's condition
+func (r AlertRule) Breached(value float64) bool {
+	switch r.Operator {
+	case ">":
+		return value > r.Threshold
+	case ">=":
+		return value >= r.Threshold
+	case "<":
+		return value < r.Threshold
+	case "<=":
+		return value <= r.Threshold
+	default:
+		return false
+	}
+}
+
+// Alert is the evaluation state of one rule
+type Alert struct {
+	Rule        AlertRule
+	State       AlertState
+	Value       float64   // Metric value at the last evaluation
+	Since       time.Time // When the alert entered its current state
+	EvaluatedAt time.Time
+	FiredCount  int // Transitions to firing since the server started
+}
+
+// AlertTransition is an alert changing between firing and resolved, which is what gets notified
+type AlertTransition struct {
+	ID       string
+	Alert    Alert
+	Resolved bool // The alert stopped firing (otherwise it started)
+	At       time.Time
+}
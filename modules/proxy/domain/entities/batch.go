@@ -0,0 +1,34 @@
+package entities
+
+import "time"
+
+// Message batch processing statuses reported by Claude API
+const (
+	BatchStatusInProgress = "in_progress"
+	BatchStatusCanceling  = "canceling"
+	BatchStatusEnded      = "ended"
+)
+
+// Batch records which account created a message batch, so later requests about it (polling, results,
+// cancel, delete) reach the same account: batches only exist on the account that created them
+type Batch struct {
+	ID        string    // Batch ID assigned by Claude API (msgbatch_...)
+	AccountID string    // Account the batch was created on
+	TokenID   string    // Token that created the batch
+	Status    string    // Last processing status seen in a response
+	CreatedAt time.Time // When the proxy saw the batch created
+	UpdatedAt time.Time // When the status was last seen
+	EndedAt   time.Time // When the batch ended (zero while it is processing)
+	ExpiresAt time.Time // When the mapping is forgotten
+}
+
+// Clone returns a copy of the batch that shares no state with the original
+func (b *Batch) Clone() *Batch {
+	copied := *b
+	return &copied
+}
+
+// IsExpired returns true if the mapping is past its expiry
+func (b *Batch) IsExpired(now time.Time) bool {
+	return !b.ExpiresAt.IsZero() && now.After(b.ExpiresAt)
+}
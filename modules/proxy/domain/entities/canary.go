@@ -0,0 +1,9 @@
+package entities
+
+// Cohort is the share of traffic a request belongs to when canary routing splits it (proxy.canary_percent)
+type Cohort string
+
+const (
+	CohortControl Cohort = "control" // Served by the accounts not flagged canary
+	CohortCanary  Cohort = "canary"  // Served by the accounts flagged canary
+)
@@ -0,0 +1,32 @@
+package entities
+
+import "time"
+
+// MaxCapturedBodySize caps the request and response bodies kept by failure capture and replay
+const MaxCapturedBodySize = 64 * 1024
+
+// CapturedRequest is the envelope of a failed request kept for a token with capture_failures
+// It holds what was sent upstream, minus credentials, so it can be replayed against an account as-is
+type CapturedRequest struct {
+	Timestamp         time.Time
+	TokenID           string
+	AccountID         string // Account the request was sent to (empty if none was selected)
+	Method            string
+	Path              string            // Path and query string
+	Headers           map[string]string // Headers sent upstream besides Authorization and proxy-managed ones
+	Body              []byte            // Body sent upstream, capped at MaxCapturedBodySize
+	BodyTruncated     bool              // Body was longer than MaxCapturedBodySize
+	StatusCode        int               // Upstream status code (0 when no response was received)
+	Error             string            // Proxy error code, or the status text of an upstream error status
+	UpstreamRequestID string            // Claude API's request-id, if a response was received
+}
+
+// ReplayResult is the upstream response to a replayed request
+type ReplayResult struct {
+	StatusCode        int
+	Headers           map[string]string
+	Body              []byte // Capped at MaxCapturedBodySize
+	BodyTruncated     bool
+	Latency           time.Duration
+	UpstreamRequestID string
+}
@@ -0,0 +1,21 @@
+package entities
+
+import "time"
+
+// BreakerState is the state of an account's circuit breaker
+type BreakerState string
+
+const (
+	BreakerStateClosed   BreakerState = "closed"    // Account is selected normally
+	BreakerStateOpen     BreakerState = "open"      // Account is excluded from selection until the cooldown ends
+	BreakerStateHalfOpen BreakerState = "half_open" // Cooldown ended: the next request probes the account
+)
+
+// BreakerStatus describes an account's circuit breaker and its rolling window metrics
+type BreakerStatus struct {
+	State      BreakerState
+	Requests   int           // Requests in the rolling window
+	P95Latency time.Duration // p95 time until upstream response headers over the window
+	ErrorRate  float64       // Fraction of failed requests over the window
+	OpenedAt   time.Time     // Zero unless open or half-open
+}
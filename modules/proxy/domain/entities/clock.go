@@ -0,0 +1,33 @@
+package entities
+
+import "time"
+
+// Clock skew measurement sources
+const (
+	ClockSourceProbe    = "probe"    // Dedicated request to Claude API (startup and periodic checks)
+	ClockSourceUpstream = "upstream" // Date header of a proxied response
+)
+
+// ClockStatus describes the local clock compared with Claude API's
+// Skew is local time minus server time: positive when the local clock runs ahead
+type ClockStatus struct {
+	Measured  bool // False until a first Date header was seen
+	Skew      time.Duration
+	MaxSkew   time.Duration
+	Source    string
+	CheckedAt time.Time
+}
+
+// Exceeded returns true if the last measured skew is larger than the maximum, in either direction
+func (s ClockStatus) Exceeded() bool {
+	return s.Measured && s.Skew.Abs() > s.MaxSkew
+}
+
+// AuthFailureStatus describes the upstream 401 responses held within the detection window
+type AuthFailureStatus struct {
+	Window          time.Duration
+	Aggressive      bool      // Accounts are marked invalid on their first 401
+	FailingAccounts int       // Accounts with a 401 within the window
+	HeldAccounts    int       // Of those, accounts kept active because every account was failing
+	OutageSince     time.Time // When every available account started failing (zero when not in an outage)
+}
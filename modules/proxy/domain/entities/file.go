@@ -0,0 +1,28 @@
+package entities
+
+import "time"
+
+// File records which account a file was uploaded to through the Files API, so requests about it
+// (metadata, content, delete) and messages referencing its file_id reach the same account: files only
+// exist on the account they were uploaded to
+type File struct {
+	ID        string    // File ID assigned by Claude API (file_...)
+	AccountID string    // Account the file was uploaded to
+	TokenID   string    // Token that uploaded the file
+	Filename  string    // Name given at upload
+	MimeType  string    // Content type reported by Claude API
+	SizeBytes int64     // File size reported by Claude API
+	CreatedAt time.Time // When the proxy saw the upload
+	ExpiresAt time.Time // When the mapping is forgotten
+}
+
+// Clone returns a copy of the file that shares no state with the original
+func (f *File) Clone() *File {
+	copied := *f
+	return &copied
+}
+
+// IsExpired returns true if the mapping is past its expiry
+func (f *File) IsExpired(now time.Time) bool {
+	return !f.ExpiresAt.IsZero() && now.After(f.ExpiresAt)
+}
@@ -0,0 +1,23 @@
+package entities
+
+import "time"
+
+// DefaultMaintenanceRetryAfter is the Retry-After hint sent to clients when the operator gives none
+const DefaultMaintenanceRetryAfter = 5 * time.Minute
+
+// MaintenanceState describes whether proxying to Claude API is paused by an operator
+type MaintenanceState struct {
+	Enabled    bool
+	Message    string        // Shown to API clients while enabled
+	RetryAfter time.Duration // Sent as the Retry-After header while enabled
+	UpdatedAt  time.Time     // Zero if maintenance mode was never toggled
+	UpdatedBy  string        // Admin identity that last toggled maintenance mode
+}
+
+// ClientMessage returns the message returned to API clients while maintenance mode is enabled
+func (m *MaintenanceState) ClientMessage() string {
+	if m.Message != "" {
+		return "Service is under maintenance: " + m.Message
+	}
+	return "Service is under maintenance"
+}
@@ -0,0 +1,23 @@
+package entities
+
+import "time"
+
+// AccountRequest summarizes one request proxied through an account, for troubleshooting
+// Bodies are never recorded
+type AccountRequest struct {
+	Timestamp  time.Time
+	TokenID    string
+	Method     string
+	Path       string
+	Model      string        // Model named by the response (empty if unknown)
+	StatusCode int           // Upstream status code (0 when no response was received)
+	Latency    time.Duration // Time until upstream response headers (or failure)
+	Error      string        // Proxy error code, or the status text of an upstream error status
+	Shadow     bool          // Mirrored copy sent to a shadow account (its response was discarded)
+	Replay     bool          // Captured request resent by an admin through POST /api/admin/replay
+}
+
+// IsError returns true if the request failed (no response or an error status)
+func (r *AccountRequest) IsError() bool {
+	return r.StatusCode == 0 || r.StatusCode >= 400
+}
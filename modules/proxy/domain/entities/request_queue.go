@@ -0,0 +1,37 @@
+package entities
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	// ErrQueueFull is returned when every queue slot is taken by a waiting request
+	ErrQueueFull = errors.New("request queue is full")
+
+	// ErrQueueWaitTooLong is returned when no account recovers within the maximum queue wait
+	ErrQueueWaitTooLong = errors.New("no account recovers within the maximum queue wait")
+)
+
+// QueueOutcome is how a queued request left the queue
+type QueueOutcome string
+
+const (
+	QueueOutcomeServed   QueueOutcome = "served"    // An account recovered and the request was forwarded
+	QueueOutcomeTimedOut QueueOutcome = "timed_out" // No account recovered before the wait deadline
+	QueueOutcomeCanceled QueueOutcome = "canceled"  // The client disconnected while waiting
+)
+
+// QueueStatus describes the request queue since the server started
+type QueueStatus struct {
+	Enabled     bool
+	MaxWait     time.Duration
+	Size        int   // Requests that can wait at once
+	Depth       int   // Requests waiting now
+	Served      int64 // Requests forwarded after waiting
+	TimedOut    int64
+	Canceled    int64
+	Rejected    int64         // Requests answered 429 at once (queue full or recovery too far away)
+	AverageWait time.Duration // Over requests that left the queue
+	MaxWaited   time.Duration // Longest wait of a request that left the queue
+}
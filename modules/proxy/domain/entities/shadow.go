@@ -0,0 +1,13 @@
+package entities
+
+import "time"
+
+// ShadowStats counts the mirrored requests sent to one shadow account since startup
+type ShadowStats struct {
+	Sent        int64     // Copies that reached Claude API or failed on the way
+	Failed      int64     // Copies without an upstream response or with an error status
+	Dropped     int64     // Copies skipped because too many were already in flight
+	LastAt      time.Time // When the last copy finished (zero if none)
+	LastError   string    // Proxy error code or upstream status text of the last failed copy
+	LastLatency time.Duration
+}
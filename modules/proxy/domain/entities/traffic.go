@@ -0,0 +1,29 @@
+package entities
+
+import "time"
+
+// TrafficSnapshot summarizes proxied traffic since the server started
+type TrafficSnapshot struct {
+	StartedAt    time.Time
+	Requests     int64 // Completed requests (success or failure)
+	Errors       int64 // Requests without an upstream response or with an error status
+	ServerErrors int64 // Requests without an upstream response or with a 5xx status
+	InFlight     int64 // Requests accepted but not finished (streaming responses included)
+	InputTokens  int64
+	OutputTokens int64
+	// Prompt cache tokens, not included in InputTokens
+	CacheCreationTokens int64
+	CacheReadTokens     int64
+
+	// Completed requests over the rolling windows
+	RequestsLastMinute int64
+	RequestsLast5Min   int64
+	RequestsLastHour   int64
+	ErrorsLastMinute   int64
+	ErrorsLast5Min     int64
+	ErrorsLastHour     int64
+
+	// Time until upstream response headers, over requests that got a response
+	AverageLatency time.Duration
+	P95Latency     time.Duration // Upper bound of the latency histogram bucket holding the 95th percentile
+}
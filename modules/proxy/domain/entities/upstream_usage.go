@@ -0,0 +1,127 @@
+package entities
+
+import (
+	"maps"
+	"time"
+)
+
+// UpstreamUsageDateLayout is the layout of the days usage is reported for (UTC)
+const UpstreamUsageDateLayout = "2006-01-02"
+
+// UpstreamUsageStatus is the outcome of the last usage report collection of an account
+type UpstreamUsageStatus string
+
+const (
+	UpstreamUsageStatusOK        UpstreamUsageStatus = "ok"        // Last collection succeeded
+	UpstreamUsageStatusError     UpstreamUsageStatus = "error"     // Last collection failed, retried next run
+	UpstreamUsageStatusForbidden UpstreamUsageStatus = "forbidden" // No admin access: not retried until re-flagged
+)
+
+// DailyUsage is the token usage of an account on one UTC day
+type DailyUsage struct {
+	Requests                 int64 // Locally counted only (usage reports carry no request counts)
+	InputTokens              int64
+	OutputTokens             int64
+	CacheCreationInputTokens int64
+	CacheReadInputTokens     int64
+}
+
+// Add adds a request's usage to the day
+func (d *DailyUsage) Add(usage Usage) {
+	d.Requests++
+	d.InputTokens += int64(usage.InputTokens)
+	d.OutputTokens += int64(usage.OutputTokens)
+	d.CacheCreationInputTokens += int64(usage.CacheCreationInputTokens)
+	d.CacheReadInputTokens += int64(usage.CacheReadInputTokens)
+}
+
+// Plus returns the sum of two days' usage
+func (d DailyUsage) Plus(other DailyUsage) DailyUsage {
+	return DailyUsage{
+		Requests:                 d.Requests + other.Requests,
+		InputTokens:              d.InputTokens + other.InputTokens,
+		OutputTokens:             d.OutputTokens + other.OutputTokens,
+		CacheCreationInputTokens: d.CacheCreationInputTokens + other.CacheCreationInputTokens,
+		CacheReadInputTokens:     d.CacheReadInputTokens + other.CacheReadInputTokens,
+	}
+}
+
+// TotalTokens returns input, output and prompt cache tokens
+func (d DailyUsage) TotalTokens() int64 {
+	return d.InputTokens + d.OutputTokens + d.CacheCreationInputTokens + d.CacheReadInputTokens
+}
+
+// AccountUpstreamUsage is an account's daily usage as reported by Claude API (organization usage reports) next
+// to the usage the proxy counted itself, keyed by UTC day (UpstreamUsageDateLayout)
+type AccountUpstreamUsage struct {
+	AccountID     string
+	Status        UpstreamUsageStatus // Empty until the first collection
+	LastError     string
+	LastAttemptAt time.Time // Zero until the first collection
+	LastSuccessAt time.Time // Zero until a collection succeeds
+	Official      map[string]DailyUsage
+	Local         map[string]DailyUsage
+}
+
+// UpstreamUsageDay is one day of an account's usage report: the official and the locally counted usage
+type UpstreamUsageDay struct {
+	Date     string
+	Official *DailyUsage // Nil if Claude API reported nothing for the day (yet)
+	Local    DailyUsage
+}
+
+// Clone returns a copy of the account usage that shares no state with the original
+func (u *AccountUpstreamUsage) Clone() *AccountUpstreamUsage {
+	copied := *u
+	copied.Official = maps.Clone(u.Official)
+	copied.Local = maps.Clone(u.Local)
+	return &copied
+}
+
+// Days returns the days from..to (inclusive, UTC) in order, with the official usage where it was reported
+func (u *AccountUpstreamUsage) Days(from, to time.Time) []UpstreamUsageDay {
+	var days []UpstreamUsageDay
+	for day := truncateDay(from); !day.After(truncateDay(to)); day = day.AddDate(0, 0, 1) {
+		date := day.Format(UpstreamUsageDateLayout)
+		entry := UpstreamUsageDay{Date: date, Local: u.Local[date]}
+		if official, ok := u.Official[date]; ok {
+			entry.Official = &official
+		}
+		days = append(days, entry)
+	}
+	return days
+}
+
+// Totals returns the official and the locally counted usage summed over the days from..to (inclusive, UTC)
+func (u *AccountUpstreamUsage) Totals(from, to time.Time) (official, local DailyUsage) {
+	for _, day := range u.Days(from, to) {
+		if day.Official != nil {
+			official = official.Plus(*day.Official)
+		}
+		local = local.Plus(day.Local)
+	}
+	return official, local
+}
+
+// Prune drops the days before cutoff
+func (u *AccountUpstreamUsage) Prune(cutoff time.Time) {
+	oldest := truncateDay(cutoff).Format(UpstreamUsageDateLayout)
+	for _, days := range []map[string]DailyUsage{u.Official, u.Local} {
+		for date := range days {
+			if date < oldest {
+				delete(days, date)
+			}
+		}
+	}
+}
+
+// UsageDate returns the UTC day t falls on (UpstreamUsageDateLayout)
+func UsageDate(t time.Time) string {
+	return t.UTC().Format(UpstreamUsageDateLayout)
+}
+
+// truncateDay returns the start of the UTC day t falls on
+func truncateDay(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,36 @@
+package entities
+
+// Usage represents token usage reported by Claude API for a single request
+type Usage struct {
+	InputTokens              int `json:"input_tokens"`
+	OutputTokens             int `json:"output_tokens"`
+	CacheCreationInputTokens int `json:"cache_creation_input_tokens"`
+	CacheReadInputTokens     int `json:"cache_read_input_tokens"`
+}
+
+// TotalTokens returns all tokens consumed by the request (input, output and cache)
+func (u Usage) TotalTokens() int {
+	return u.InputTokens + u.OutputTokens + u.CacheCreationInputTokens + u.CacheReadInputTokens
+}
+
+// IsZero returns true if no usage was reported
+func (u Usage) IsZero() bool {
+	return u.TotalTokens() == 0
+}
+
+// Merge folds a later usage report into this one (non-zero fields win)
+// Streaming responses report input usage in message_start and output usage in message_delta
+func (u *Usage) Merge(other Usage) {
+	if other.InputTokens > 0 {
+		u.InputTokens = other.InputTokens
+	}
+	if other.OutputTokens > 0 {
+		u.OutputTokens = other.OutputTokens
+	}
+	if other.CacheCreationInputTokens > 0 {
+		u.CacheCreationInputTokens = other.CacheCreationInputTokens
+	}
+	if other.CacheReadInputTokens > 0 {
+		u.CacheReadInputTokens = other.CacheReadInputTokens
+	}
+}
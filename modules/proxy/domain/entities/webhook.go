@@ -0,0 +1,50 @@
+package entities
+
+import "time"
+
+// Webhook event types
+const (
+	WebhookEventRequestCompleted = "request.completed" // Upstream answered with a 2xx status
+	WebhookEventRequestFailed    = "request.failed"    // No upstream response, or an error status
+	WebhookEventAlert            = "alert"             // An alert rule started or stopped firing
+	WebhookEventTest             = "test"              // Sample event sent on demand (never filtered)
+)
+
+// WebhookEventTypes lists the event types that can be selected in webhooks.events
+var WebhookEventTypes = []string{WebhookEventRequestCompleted, WebhookEventRequestFailed, WebhookEventAlert}
+
+// UsageEvent describes a completed proxied request for external billing
+type UsageEvent struct {
+	ID         string
+	Type       string
+	Timestamp  time.Time // When the request started
+	TokenID    string
+	AccountID  string // Empty when the request failed before an account was selected
+	Model      string // Model reported by Claude API (empty when no response body reported one)
+	StatusCode int    // Upstream status code (0 when no response was received)
+	ErrorCode  string // Proxy error classification when no upstream response was relayed
+	Latency    time.Duration
+	Usage      Usage
+}
+
+// WebhookFailure is an event whose delivery failed after every attempt
+type WebhookFailure struct {
+	EventID    string
+	EventType  string
+	Attempts   int
+	StatusCode int // Last response status (0 on a transport error)
+	Error      string
+	FailedAt   time.Time
+}
+
+// WebhookStatus summarizes webhook deliveries since the server started
+type WebhookStatus struct {
+	Enabled     bool
+	Events      []string // Delivered event types
+	QueueLength int
+	QueueSize   int
+	Delivered   int64
+	Failed      int64            // Events given up after every retry
+	Dropped     int64            // Events discarded because the queue was full
+	Failures    []WebhookFailure // Most recent failures, newest first
+}
@@ -0,0 +1,25 @@
+package interfaces
+
+import (
+	"context"
+
+	"claude-proxy/modules/auth/domain/entities"
+)
+
+// AccountFailureTracker counts consecutive upstream permission failures (403) per account and disables accounts
+// that reach the threshold, such as those of suspended organizations whose tokens still refresh fine
+// Counts are kept in memory only and restart from zero after a restart
+type AccountFailureTracker interface {
+	// RecordSuccess resets the account's consecutive failure count
+	RecordSuccess(accountID string)
+
+	// RecordFailure counts an upstream response with statusCode, disabling the account at the threshold
+	// Statuses that don't say anything about the account itself (client errors, rate limits) are ignored
+	RecordFailure(ctx context.Context, account *entities.Account, statusCode int)
+
+	// Failures returns the account's current consecutive failure count
+	Failures(accountID string) int
+
+	// Reset clears the account's consecutive failure count
+	Reset(accountID string)
+}
@@ -0,0 +1,17 @@
+package interfaces
+
+import (
+	"context"
+	"time"
+
+	"claude-proxy/modules/proxy/domain/entities"
+)
+
+// AlertEvaluator checks the alert rules against the statistics counters and notifies state transitions
+type AlertEvaluator interface {
+	// Evaluate checks every rule at now, notifying (Telegram, webhooks) the alerts that start or stop firing
+	Evaluate(ctx context.Context, now time.Time)
+
+	// Alerts returns the state of every rule, in configuration order
+	Alerts() []entities.Alert
+}
@@ -0,0 +1,16 @@
+package interfaces
+
+import (
+	"context"
+
+	"claude-proxy/modules/proxy/domain/entities"
+)
+
+// BatchRepository persists the message batch index across restarts
+type BatchRepository interface {
+	// SaveAll replaces the stored batches
+	SaveAll(ctx context.Context, batches []*entities.Batch) error
+
+	// LoadAll returns the stored batches (empty if none were saved)
+	LoadAll(ctx context.Context) ([]*entities.Batch, error)
+}
@@ -0,0 +1,28 @@
+package interfaces
+
+import (
+	"context"
+
+	"claude-proxy/modules/proxy/domain/entities"
+)
+
+// BatchService keeps the message batch → account index used to route batch requests
+type BatchService interface {
+	// Owner returns the ID of the account that created the batch (false if the batch is unknown or expired)
+	Owner(batchID string) (string, bool)
+
+	// Track records a batch seen in a Claude API response, or updates its status
+	Track(batch *entities.Batch)
+
+	// Forget drops a batch (deleted upstream)
+	Forget(batchID string)
+
+	// List returns the known batches, most recently created first
+	List() []*entities.Batch
+
+	// Sync drops expired batches and writes changes to persistent storage
+	Sync(ctx context.Context) error
+
+	// FinalSync performs final sync on graceful shutdown
+	FinalSync(ctx context.Context) error
+}
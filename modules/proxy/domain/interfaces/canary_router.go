@@ -0,0 +1,30 @@
+package interfaces
+
+import (
+	authentities "claude-proxy/modules/auth/domain/entities"
+	"claude-proxy/modules/proxy/domain/entities"
+)
+
+// CanaryRouter splits traffic between the accounts flagged canary and the rest of the pool
+// Cohorts are assigned per session (or token), so a client never alternates between them; counters are kept
+// in memory only.
+type CanaryRouter interface {
+	// Enabled returns true if a share of sessions goes to canary accounts (proxy.canary_percent above 0)
+	Enabled() bool
+
+	// Percent returns the share (0-100) of sessions in the canary cohort
+	Percent() float64
+
+	// Cohort returns the cohort of a session or token ID, the same one every time (control when disabled)
+	Cohort(key string) entities.Cohort
+
+	// Pool narrows the selectable accounts to the cohort's; a cohort without any keeps them all, and so does
+	// a disabled router
+	Pool(accounts []*authentities.Account, cohort entities.Cohort) []*authentities.Account
+
+	// Record counts a finished request in the cohort of the account that served it
+	Record(accountID string, sample *authentities.RequestSample)
+
+	// Stats returns the traffic counters of each cohort
+	Stats() map[entities.Cohort]entities.TrafficSnapshot
+}
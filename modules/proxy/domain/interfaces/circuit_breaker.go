@@ -0,0 +1,33 @@
+package interfaces
+
+import (
+	"time"
+
+	"claude-proxy/modules/proxy/domain/entities"
+)
+
+// CircuitBreaker tracks upstream latency and errors per account and excludes degraded accounts from selection
+// State is kept in memory only: every breaker starts closed after a restart
+type CircuitBreaker interface {
+	// Enabled returns true if the circuit breaker is configured (otherwise every account stays selectable)
+	Enabled() bool
+
+	// Selectable returns true if the account may be picked for a request
+	Selectable(accountID string) bool
+
+	// Acquire admits a request to the selected account, claiming the single probe slot of a half-open breaker
+	// Returns false if the account can't take the request (another probe is already in flight)
+	Acquire(accountID string) bool
+
+	// Release gives back an acquired probe slot when the request never reached Claude API
+	Release(accountID string)
+
+	// Record adds an upstream request outcome (time until response headers, transport error or 5xx)
+	Record(accountID string, latency time.Duration, failed bool)
+
+	// State returns the account's breaker state
+	State(accountID string) entities.BreakerState
+
+	// Status returns the account's breaker state and rolling window metrics
+	Status(accountID string) entities.BreakerStatus
+}
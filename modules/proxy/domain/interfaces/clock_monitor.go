@@ -0,0 +1,36 @@
+package interfaces
+
+import (
+	"context"
+	"time"
+
+	"claude-proxy/modules/auth/domain/entities"
+	proxyentities "claude-proxy/modules/proxy/domain/entities"
+)
+
+// ClockMonitor measures the local clock against the Date header of Claude API responses
+// Token expiry is computed locally, so a clock running late keeps expired access tokens in use
+type ClockMonitor interface {
+	// Observe records a Date header received for a request sent at sent and answered at received
+	Observe(date string, sent, received time.Time, source string)
+
+	// Status returns the last measurement
+	Status() proxyentities.ClockStatus
+
+	// CheckHealth returns an error while the measured skew exceeds the maximum (readiness check)
+	CheckHealth(ctx context.Context) error
+}
+
+// AuthFailureGuard decides what an upstream 401 means for the account that received it
+// When every available account fails within a short window the cause is more likely clock skew or an
+// upstream outage than revoked credentials, so accounts are kept and an alert is raised instead
+type AuthFailureGuard interface {
+	// RecordSuccess notes that the account's credentials were accepted
+	RecordSuccess(accountID string)
+
+	// RecordUnauthorized handles a 401 from Claude API for the account
+	RecordUnauthorized(ctx context.Context, account *entities.Account)
+
+	// Status returns the 401s held within the detection window
+	Status() proxyentities.AuthFailureStatus
+}
@@ -0,0 +1,13 @@
+package interfaces
+
+import "claude-proxy/modules/proxy/domain/entities"
+
+// FailureCapture keeps the envelopes of the last failed requests of tokens with capture_failures in memory
+// (lost on restart)
+type FailureCapture interface {
+	// Record adds a failed request to the token's captures, evicting its oldest capture when full
+	Record(request entities.CapturedRequest)
+
+	// Recent returns the token's captured failed requests, newest first
+	Recent(tokenID string) []entities.CapturedRequest
+}
@@ -0,0 +1,16 @@
+package interfaces
+
+import (
+	"context"
+
+	"claude-proxy/modules/proxy/domain/entities"
+)
+
+// FileRepository persists the Files API file index across restarts
+type FileRepository interface {
+	// SaveAll replaces the stored files
+	SaveAll(ctx context.Context, files []*entities.File) error
+
+	// LoadAll returns the stored files (empty if none were saved)
+	LoadAll(ctx context.Context) ([]*entities.File, error)
+}
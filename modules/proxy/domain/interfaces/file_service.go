@@ -0,0 +1,32 @@
+package interfaces
+
+import (
+	"context"
+
+	"claude-proxy/modules/proxy/domain/entities"
+)
+
+// FileService keeps the Files API file → account index used to route file requests and messages
+// referencing uploaded files
+type FileService interface {
+	// Owner returns the ID of the account the file was uploaded to (false if the file is unknown or expired)
+	Owner(fileID string) (string, bool)
+
+	// Track records a file uploaded to an account
+	Track(file *entities.File)
+
+	// Forget drops a file (deleted upstream)
+	Forget(fileID string)
+
+	// Len returns the number of known files
+	Len() int
+
+	// List returns the known files, most recently uploaded first
+	List() []*entities.File
+
+	// Sync drops expired files and writes changes to persistent storage
+	Sync(ctx context.Context) error
+
+	// FinalSync performs final sync on graceful shutdown
+	FinalSync(ctx context.Context) error
+}
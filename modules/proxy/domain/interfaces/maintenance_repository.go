@@ -0,0 +1,16 @@
+package interfaces
+
+import (
+	"context"
+
+	"claude-proxy/modules/proxy/domain/entities"
+)
+
+// MaintenanceRepository persists the maintenance mode state across restarts
+type MaintenanceRepository interface {
+	// Save stores the maintenance state
+	Save(ctx context.Context, state *entities.MaintenanceState) error
+
+	// Load returns the stored maintenance state (nil if it was never saved)
+	Load(ctx context.Context) (*entities.MaintenanceState, error)
+}
@@ -0,0 +1,24 @@
+package interfaces
+
+import (
+	"context"
+	"time"
+
+	"claude-proxy/modules/proxy/domain/entities"
+)
+
+// MaintenanceService manages the operator-controlled pause of all proxying
+type MaintenanceService interface {
+	// State returns a copy of the current maintenance state (served from memory, safe for every request)
+	State() entities.MaintenanceState
+
+	// SetMaintenance enables or disables maintenance mode, persists it and notifies operators
+	// retryAfter <= 0 uses the default Retry-After hint; actor identifies the admin making the change
+	SetMaintenance(
+		ctx context.Context,
+		enabled bool,
+		message string,
+		retryAfter time.Duration,
+		actor string,
+	) (entities.MaintenanceState, error)
+}
@@ -0,0 +1,12 @@
+package interfaces
+
+import "context"
+
+// ModelCacheRepository persists the last successful GET /v1/models response
+type ModelCacheRepository interface {
+	// Save stores the raw response body
+	Save(ctx context.Context, body []byte) error
+
+	// Load returns the stored response body (nil if nothing has been cached yet)
+	Load(ctx context.Context) ([]byte, error)
+}
@@ -0,0 +1,13 @@
+package interfaces
+
+import "time"
+
+// PriorityTierTracker counts the accounts selected from each priority tier over the last hour
+// Counts are kept in memory only and restart from zero after a restart
+type PriorityTierTracker interface {
+	// Record counts an account of tier priority selected at at
+	Record(priority int, at time.Time)
+
+	// Selections returns the selections of each tier over the hour before now (tiers never selected are absent)
+	Selections(now time.Time) map[int]int64
+}
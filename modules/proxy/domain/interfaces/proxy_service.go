@@ -5,6 +5,7 @@ import (
 	"net/http"
 
 	"claude-proxy/modules/auth/domain/entities"
+	proxyentities "claude-proxy/modules/proxy/domain/entities"
 )
 
 // ProxyService defines the interface for proxy operations
@@ -13,6 +14,7 @@ type ProxyService interface {
 	// It validates the token, selects an active account, and forwards the request
 	ProxyRequest(ctx context.Context, token *entities.Token, req *http.Request) (*http.Response, error)
 
-	// GetValidAccount returns a valid active account with a fresh access token
-	GetValidAccount(ctx context.Context) (*entities.Account, error)
+	// GetValidAccount returns a valid active account with a fresh access token, serving model ("" = any model),
+	// from the accounts of cohort when canary routing is enabled
+	GetValidAccount(ctx context.Context, model string, cohort proxyentities.Cohort) (*entities.Account, error)
 }
@@ -0,0 +1,14 @@
+package interfaces
+
+import (
+	"context"
+
+	"claude-proxy/modules/proxy/domain/entities"
+)
+
+// ReplayService resends captured requests through a chosen account, for troubleshooting
+type ReplayService interface {
+	// Replay sends request (method, path, headers and body) to Claude API with the account's credentials,
+	// bypassing token validation and session limits, and returns the upstream response
+	Replay(ctx context.Context, accountID string, request entities.CapturedRequest) (*entities.ReplayResult, error)
+}
@@ -0,0 +1,12 @@
+package interfaces
+
+import "claude-proxy/modules/proxy/domain/entities"
+
+// RequestHistory keeps the last requests of each account in memory (lost on restart)
+type RequestHistory interface {
+	// Record adds a request to the account's history, evicting its oldest request when full
+	Record(accountID string, request entities.AccountRequest)
+
+	// Recent returns the account's recorded requests, newest first
+	Recent(accountID string) []entities.AccountRequest
+}
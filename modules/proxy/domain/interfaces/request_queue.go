@@ -0,0 +1,24 @@
+package interfaces
+
+import (
+	"time"
+
+	"claude-proxy/modules/proxy/domain/entities"
+)
+
+// RequestQueue bounds the requests parked while every account is rate limited or over quota
+// It only hands out slots and keeps counters: waiting and retrying account selection is up to the caller
+type RequestQueue interface {
+	// Enabled returns true if requests may wait for an account (proxy.max_queue_wait is set)
+	Enabled() bool
+
+	// Enter claims a slot for a request that can be served once an account recovers at recoveryAt
+	// Returns the deadline of the wait, or ErrQueueWaitTooLong / ErrQueueFull when the request can't wait
+	Enter(recoveryAt time.Time) (time.Time, error)
+
+	// Leave frees the slot of a request that waited for the given time, recording the outcome
+	Leave(waited time.Duration, outcome entities.QueueOutcome)
+
+	// Status returns the queue depth and counters
+	Status() entities.QueueStatus
+}
@@ -0,0 +1,14 @@
+package interfaces
+
+// SessionToucher records activity on sessions in batches, off the request path
+// Sessions touched several times between two flushes are touched once.
+type SessionToucher interface {
+	// Touch queues a session's activity for the next flush (a no-op for an empty ID)
+	Touch(sessionID string)
+
+	// Start launches the periodic flush
+	Start()
+
+	// Stop ends the periodic flush, flushing the queued sessions one last time
+	Stop()
+}
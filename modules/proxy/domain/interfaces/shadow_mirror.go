@@ -0,0 +1,19 @@
+package interfaces
+
+import "claude-proxy/modules/proxy/domain/entities"
+
+// ShadowMirror sends copies of proxied message requests to shadow accounts, to validate them on real traffic
+// Copies are fire-and-forget: their responses are discarded and they never count toward traffic metrics,
+// token statistics, quotas, sessions or webhooks, only toward the shadow account's health (circuit breaker,
+// request history). Stats are kept in memory only.
+type ShadowMirror interface {
+	// Enabled returns true if a share of requests is mirrored (proxy.shadow_sample_percent above 0)
+	Enabled() bool
+
+	// Mirror sends a copy of a POST /v1/messages body to every usable shadow account in the background, for the
+	// sampled share of non-streaming requests; it never blocks the request it copies
+	Mirror(tokenID, path string, body []byte)
+
+	// Stats returns the mirrored request counts per shadow account
+	Stats() map[string]entities.ShadowStats
+}
@@ -0,0 +1,18 @@
+package interfaces
+
+import (
+	authentities "claude-proxy/modules/auth/domain/entities"
+	"claude-proxy/modules/proxy/domain/entities"
+)
+
+// TrafficMetrics counts proxied requests in memory (reset on restart) for the statistics endpoint
+type TrafficMetrics interface {
+	// RequestStarted marks a request as in flight
+	RequestStarted()
+
+	// RequestFinished records a completed request and ends its in-flight period
+	RequestFinished(sample *authentities.RequestSample)
+
+	// Snapshot returns the current counters; its cost does not depend on the traffic volume
+	Snapshot() entities.TrafficSnapshot
+}
@@ -0,0 +1,31 @@
+package interfaces
+
+import (
+	"context"
+	"time"
+
+	"claude-proxy/modules/proxy/domain/entities"
+)
+
+// UpstreamUsage keeps the daily usage Claude API reports for admin-capable accounts (organization usage
+// reports) next to the usage the proxy counts itself for every account, so both can be compared
+type UpstreamUsage interface {
+	// RecordLocal adds a proxied request's usage to the account's locally counted usage of the day
+	RecordLocal(accountID string, usage entities.Usage, at time.Time)
+
+	// Collect fetches the usage reports of the admin-capable accounts and saves the results; accounts whose
+	// reports were refused (no admin access) are skipped until Reset
+	Collect(ctx context.Context) error
+
+	// Get returns a copy of the account's usage (nil if none was counted or collected)
+	Get(accountID string) *entities.AccountUpstreamUsage
+
+	// Reset clears the account's collection status so a refused account is tried again
+	Reset(accountID string)
+
+	// Sync drops days past retention and writes changes to persistent storage
+	Sync(ctx context.Context) error
+
+	// FinalSync performs final sync on graceful shutdown
+	FinalSync(ctx context.Context) error
+}
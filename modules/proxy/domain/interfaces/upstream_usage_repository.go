@@ -0,0 +1,16 @@
+package interfaces
+
+import (
+	"context"
+
+	"claude-proxy/modules/proxy/domain/entities"
+)
+
+// UpstreamUsageRepository persists the official and locally counted daily usage of accounts across restarts
+type UpstreamUsageRepository interface {
+	// Save stores the usage of every account
+	Save(ctx context.Context, usage []*entities.AccountUpstreamUsage) error
+
+	// Load returns the stored usage (empty if it was never saved)
+	Load(ctx context.Context) ([]*entities.AccountUpstreamUsage, error)
+}
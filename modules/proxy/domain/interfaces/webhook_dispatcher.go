@@ -0,0 +1,30 @@
+package interfaces
+
+import (
+	"context"
+
+	"claude-proxy/modules/proxy/domain/entities"
+)
+
+// WebhookDispatcher delivers usage events to the configured webhook in the background
+// Publishing never blocks: events are queued and dropped when the queue is full
+type WebhookDispatcher interface {
+	// Publish queues an event for delivery (a no-op when webhooks are disabled or the type is filtered out)
+	Publish(event entities.UsageEvent)
+
+	// PublishAlert delivers an alert transition in the background (a no-op when webhooks are disabled or the
+	// alert type is filtered out)
+	PublishAlert(transition entities.AlertTransition)
+
+	// SendTest delivers a sample event once, synchronously, returning the response status
+	SendTest(ctx context.Context) (int, error)
+
+	// Status returns the delivery counters and the most recent failures
+	Status() entities.WebhookStatus
+
+	// Start launches the delivery workers
+	Start()
+
+	// Stop ends the delivery workers; events still queued are discarded
+	Stop()
+}
@@ -0,0 +1,170 @@
+package clients
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"unicode/utf8"
+
+	"claude-proxy/config"
+
+	"github.com/imroc/req/v3"
+	sctx "github.com/phathdt/service-context"
+)
+
+// bodyLogSampledKey is the request context key recording whether the request's bodies are logged beyond
+// metadata, decided once per request so its request and response log lines agree
+type bodyLogSampledKey struct{}
+
+// contentStructureKeys are the content block fields redactContent keeps: they describe a block rather than
+// carry its content
+var contentStructureKeys = map[string]bool{
+	"type":          true,
+	"id":            true,
+	"tool_use_id":   true,
+	"name":          true,
+	"media_type":    true,
+	"cache_control": true,
+	"is_error":      true,
+}
+
+// bodyLogPolicy decides how much of the Claude API request and response bodies is logged (logger.log_bodies)
+type bodyLogPolicy struct {
+	mode    string  // config.LogBodiesNone, config.LogBodiesMetadata, config.LogBodiesRedacted or config.LogBodiesFull
+	percent float64 // Share of requests (0-100) logged under redacted or full; the others get metadata
+}
+
+// sample returns true if the bodies of a new request are logged beyond metadata
+func (p bodyLogPolicy) sample() bool {
+	if p.mode != config.LogBodiesRedacted && p.mode != config.LogBodiesFull {
+		return false
+	}
+	return p.percent >= 100 || rand.Float64()*100 < p.percent
+}
+
+// modeFor returns the mode a request's bodies are logged in: metadata if the request was left out of the sample
+func (p bodyLogPolicy) modeFor(request *req.Request) string {
+	if p.mode != config.LogBodiesRedacted && p.mode != config.LogBodiesFull {
+		return p.mode
+	}
+	if sampled, _ := request.GetContextData(bodyLogSampledKey{}).(bool); sampled {
+		return p.mode
+	}
+	return config.LogBodiesMetadata
+}
+
+// addBody adds a body to the log fields per mode: its size under sizeKey, and under valueKey its content
+// (redacted or full, secrets masked) when shorter than limit
+func addBody(fields sctx.Fields, sizeKey, valueKey string, body []byte, mode string, limit int) {
+	if len(body) == 0 || mode == config.LogBodiesNone || mode == "" {
+		return
+	}
+	fields[sizeKey] = len(body)
+
+	switch mode {
+	case config.LogBodiesRedacted:
+		if redacted, ok := redactBody(body); ok && len(redacted) < limit {
+			fields[valueKey] = redactSecrets(string(redacted))
+		}
+	case config.LogBodiesFull:
+		if len(body) < limit {
+			fields[valueKey] = redactSecrets(string(body))
+		}
+	}
+}
+
+// addRequestMetadata adds the model, message count and stream flag of a JSON request body to the log fields
+func addRequestMetadata(fields sctx.Fields, body []byte, mode string) {
+	if len(body) == 0 || mode == config.LogBodiesNone || mode == "" {
+		return
+	}
+
+	var payload struct {
+		Model    string            `json:"model"`
+		Messages []json.RawMessage `json:"messages"`
+		Stream   bool              `json:"stream"`
+	}
+	if json.Unmarshal(body, &payload) != nil {
+		return
+	}
+	if payload.Model != "" {
+		fields["model"] = payload.Model
+	}
+	if payload.Messages != nil {
+		fields["message_count"] = len(payload.Messages)
+	}
+	if payload.Stream {
+		fields["stream"] = true
+	}
+}
+
+// redactBody replaces the message content of a JSON body with its length, keeping the structure: the content
+// of messages[] and the system prompt of requests, and the content of message responses
+// Returns false if the body isn't a JSON object.
+func redactBody(body []byte) ([]byte, bool) {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+
+	var payload map[string]any
+	if decoder.Decode(&payload) != nil || payload == nil {
+		return nil, false
+	}
+
+	if messages, ok := payload["messages"].([]any); ok {
+		for _, message := range messages {
+			if fields, ok := message.(map[string]any); ok {
+				if content, ok := fields["content"]; ok {
+					fields["content"] = redactContent(content)
+				}
+			}
+		}
+	}
+	for _, key := range []string{"system", "content"} {
+		if value, ok := payload[key]; ok {
+			payload[key] = redactContent(value)
+		}
+	}
+
+	redacted, err := json.Marshal(payload)
+	return redacted, err == nil
+}
+
+// redactContent replaces every string of a content value with its length ("[42 chars]"), except the fields
+// describing content blocks (type, id, name, ...)
+func redactContent(value any) any {
+	switch v := value.(type) {
+	case string:
+		return fmt.Sprintf("[%d chars]", utf8.RuneCountInString(v))
+	case []any:
+		for i, item := range v {
+			v[i] = redactContent(item)
+		}
+		return v
+	case map[string]any:
+		for key, item := range v {
+			if !contentStructureKeys[key] {
+				v[key] = redactContent(item)
+			}
+		}
+		return v
+	default:
+		return value
+	}
+}
+
+// addErrorMetadata adds the error type and message of a Claude API error response body to the log fields;
+// they describe the failure, not the prompt, so they are logged under metadata too
+func addErrorMetadata(fields sctx.Fields, body []byte) {
+	var payload struct {
+		Error struct {
+			Type    string `json:"type"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if json.Unmarshal(body, &payload) != nil || payload.Error.Type == "" {
+		return
+	}
+	fields["error_type"] = payload.Error.Type
+	fields["error_message"] = redactSecrets(payload.Error.Message)
+}
@@ -1,45 +1,150 @@
 package clients
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
 	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
 	"time"
 
+	"claude-proxy/config"
+	"claude-proxy/pkg/httpproxy"
+
 	"github.com/imroc/req/v3"
 	sctx "github.com/phathdt/service-context"
 )
 
+const (
+	redactedValue = "[REDACTED]"
+
+	// OAuthBeta is the anthropic-beta flag every request needs to authenticate with an OAuth access token
+	OAuthBeta = "oauth-2025-04-20"
+	// FilesAPIBeta is the anthropic-beta flag of the Files API (uploads and messages referencing file IDs)
+	FilesAPIBeta = "files-api-2025-04-14"
+
+	// transportRetries is how often idempotent requests are retried after a transport error
+	transportRetries = 2
+)
+
+// sensitiveHeaders are masked in debug dumps at every log level (lowercase names)
+var sensitiveHeaders = map[string]bool{
+	"authorization":       true,
+	"proxy-authorization": true,
+	"x-api-key":           true,
+	"cookie":              true,
+}
+
+// secretPattern matches Claude API keys, OAuth tokens and proxy tokens (sk-ant-..., sk-proxy-...)
+var secretPattern = regexp.MustCompile(`sk-(?:ant|proxy)-[A-Za-z0-9_\-]{8,}`)
+
+// Credential authenticates a proxied request: an OAuth access token (Bearer, with the OAuth beta flag) or,
+// for api_key accounts, an Anthropic API key (x-api-key, without it)
+type Credential struct {
+	Secret string
+	APIKey bool
+}
+
 // ClaudeAPIClient handles HTTP communication with Claude API using req
 type ClaudeAPIClient struct {
-	baseURL string
-	client  *req.Client
-	logger  sctx.Logger
+	baseURL       string                    // Default Claude API base URL (claude.base_url)
+	timeout       time.Duration             // Deadline of non-streaming requests, including reading the body (0 = none)
+	streamTimeout time.Duration             // Deadline of streaming requests (0 = none, bounded by the caller's context)
+	headers       map[string]string         // Client identification headers (User-Agent, x-app, extras)
+	client        *req.Client               // Default client (default base URL, direct egress)
+	clients       map[clientKey]*req.Client // Dedicated clients of the other base URL / egress proxy pairs
+	clientsMu     sync.Mutex
+	bodyLog       bodyLogPolicy // How much of the request and response bodies is logged
+	logger        sctx.Logger
+}
+
+// clientKey identifies the upstream route of a pooled client
+type clientKey struct {
+	baseURL  string
+	proxyURL string // Empty for direct egress
 }
 
 // NewClaudeAPIClient creates a new Claude API client with req
-func NewClaudeAPIClient(baseURL string, timeout time.Duration, logger sctx.Logger) *ClaudeAPIClient {
+// timeout applies to non-streaming requests and streamTimeout to requests whose body sets "stream": true;
+// headers are sent on every request unless the request sets them itself; logBodies (config.LogBodies*) and
+// bodySamplePercent (0-100) control how much of the bodies is logged
+func NewClaudeAPIClient(
+	baseURL string,
+	timeout, streamTimeout time.Duration,
+	headers map[string]string,
+	logBodies string,
+	bodySamplePercent float64,
+	logger sctx.Logger,
+) *ClaudeAPIClient {
+	c := &ClaudeAPIClient{
+		baseURL:       baseURL,
+		timeout:       timeout,
+		streamTimeout: streamTimeout,
+		headers:       headers,
+		clients:       make(map[clientKey]*req.Client),
+		bodyLog:       bodyLogPolicy{mode: logBodies, percent: bodySamplePercent},
+		logger:        logger,
+	}
+	c.client = c.newClient(baseURL)
+
+	return c
+}
+
+// newClient builds a req client with the shared settings, headers and logging hooks
+// It has no client-wide timeout or retries: both depend on the request and are set in ProxyRequest
+func (c *ClaudeAPIClient) newClient(baseURL string) *req.Client {
 	client := req.C().
 		SetBaseURL(baseURL).
-		SetTimeout(timeout). // Use configurable timeout for LLM API requests
-		SetCommonRetryCount(2).
-		SetCommonRetryBackoffInterval(1*time.Second, 5*time.Second).
 		SetCommonHeaders(map[string]string{
 			"Content-Type":      "application/json",
 			"anthropic-version": "2023-06-01",
-			"anthropic-beta":    "oauth-2025-04-20", // Required for OAuth authentication
-		})
+		}).
+		SetCommonHeaders(c.headers)
 
-	c := &ClaudeAPIClient{
-		baseURL: baseURL,
-		client:  client,
-		logger:  logger,
+	// Add request/response logging middleware
+	client.OnBeforeRequest(c.logRequest)
+	client.OnAfterResponse(c.logResponse)
+
+	return client
+}
+
+// clientFor returns the client for a base URL and egress proxy URL (empty = claude.base_url and direct egress)
+// One client is built per distinct pair so connection pools are never shared across upstream hosts or
+// egress paths
+func (c *ClaudeAPIClient) clientFor(baseURL, proxyURL string) (*req.Client, error) {
+	if baseURL == "" {
+		baseURL = c.baseURL
+	}
+	if baseURL == c.baseURL && proxyURL == "" {
+		return c.client, nil
 	}
 
-	// Add request/response logging middleware
-	c.client.OnBeforeRequest(c.logRequest)
-	c.client.OnAfterResponse(c.logResponse)
+	c.clientsMu.Lock()
+	defer c.clientsMu.Unlock()
 
-	return c
+	key := clientKey{baseURL: baseURL, proxyURL: proxyURL}
+	if client, ok := c.clients[key]; ok {
+		return client, nil
+	}
+
+	if _, err := url.Parse(baseURL); err != nil {
+		return nil, fmt.Errorf("invalid base URL: %w", err)
+	}
+	client := c.newClient(baseURL)
+	if proxyURL != "" {
+		if _, err := url.Parse(proxyURL); err != nil {
+			return nil, fmt.Errorf("invalid egress proxy URL: %w", err)
+		}
+		client.SetProxyURL(proxyURL)
+	}
+	c.clients[key] = client
+	return client, nil
 }
 
 // logRequest logs the outgoing request to Claude API
@@ -54,20 +159,16 @@ func (c *ClaudeAPIClient) logRequest(client *req.Client, req *req.Request) error
 		headerMap := make(map[string]string)
 		for key, values := range req.Headers {
 			if len(values) > 0 {
-				headerMap[key] = values[0]
+				headerMap[key] = redactHeader(key, values[0])
 			}
 		}
 		fields["headers"] = headerMap
 	}
 
-	// Log body size if present
-	if len(req.Body) > 0 {
-		fields["body_size"] = len(req.Body)
-		// For small bodies (< 500 bytes), log the actual content
-		if len(req.Body) < 500 {
-			fields["body_preview"] = string(req.Body)
-		}
-	}
+	// Log the body per logger.log_bodies; small bodies (< 500 bytes) are previewed under redacted or full
+	mode := c.bodyLog.modeFor(req)
+	addRequestMetadata(fields, req.Body, mode)
+	addBody(fields, "body_size", "body_preview", req.Body, mode, 500)
 
 	c.logger.Withs(fields).Debug("Sending request to Claude API")
 
@@ -105,21 +206,27 @@ func (c *ClaudeAPIClient) logResponse(client *req.Client, resp *req.Response) er
 		}
 	}
 
-	// Log response body (be careful with large responses)
-	body := resp.String()
-	if body != "" && len(body) < 10000 { // Log only if under 10KB to avoid huge logs
-		fields["response_body"] = body
-	} else if body != "" {
-		fields["response_body_size"] = len(body)
+	// Proxied bodies are streamed, not read here; error bodies are small, so buffer them for logging
+	// and hand the caller an equivalent reader (compressed bodies are relayed untouched and not logged)
+	if statusCode >= 400 && resp.Header.Get("Content-Encoding") == "" && resp.String() == "" {
+		if data, err := resp.ToBytes(); err == nil {
+			resp.Response.Body = io.NopCloser(bytes.NewReader(data))
+		}
 	}
 
-	// Log request body if available (from Response.Request)
-	if resp.Request != nil && len(resp.Request.Body) > 0 {
-		if len(resp.Request.Body) < 10000 {
-			fields["request_body"] = string(resp.Request.Body)
-		} else {
-			fields["request_body_size"] = len(resp.Request.Body)
-		}
+	// Log the response and request bodies per logger.log_bodies (only under 10KB to avoid huge logs)
+	mode := config.LogBodiesMetadata
+	if resp.Request != nil {
+		mode = c.bodyLog.modeFor(resp.Request)
+	}
+	body := resp.Bytes()
+	if statusCode >= 400 && mode == config.LogBodiesMetadata {
+		addErrorMetadata(fields, body)
+	}
+	addBody(fields, "response_body_size", "response_body", body, mode, 10000)
+	if resp.Request != nil {
+		addRequestMetadata(fields, resp.Request.Body, mode)
+		addBody(fields, "request_body_size", "request_body", resp.Request.Body, mode, 10000)
 	}
 
 	// Log based on status code
@@ -137,30 +244,118 @@ func (c *ClaudeAPIClient) logResponse(client *req.Client, resp *req.Response) er
 	return nil
 }
 
+// redactHeader masks the credentials of authentication headers, keeping the scheme ("Bearer [REDACTED]")
+func redactHeader(key, value string) string {
+	if !sensitiveHeaders[strings.ToLower(key)] {
+		return value
+	}
+	if scheme, _, ok := strings.Cut(value, " "); ok {
+		return scheme + " " + redactedValue
+	}
+	return redactedValue
+}
+
+// redactSecrets masks API keys and OAuth tokens in logged bodies, keeping their prefix ("sk-ant-[REDACTED]")
+func redactSecrets(text string) string {
+	return secretPattern.ReplaceAllStringFunc(text, func(secret string) string {
+		return secret[:strings.Index(secret[3:], "-")+4] + redactedValue
+	})
+}
+
 // ProxyRequest proxies an HTTP request to Claude API using req
+// baseURL selects the account's Claude API host (empty = claude.base_url) and proxyURL its egress proxy
+// (empty = direct); headers are extra request headers
+// (account overrides and headers forwarded from the client) that take precedence over the common ones.
+// When they include Accept-Encoding, the response body is returned still encoded, with its
+// Content-Encoding and Content-Length headers intact, so it can be relayed as-is
 func (c *ClaudeAPIClient) ProxyRequest(
 	ctx context.Context,
 	method, path string,
-	accessToken string,
+	credential Credential,
 	body []byte,
+	baseURL, proxyURL string,
+	headers map[string]string,
+) (*http.Response, error) {
+	// Streams may legitimately run for minutes, so they get their own deadline (usually none)
+	timeout := c.timeout
+	if isStreamingBody(body) {
+		timeout = c.streamTimeout
+	}
+
+	return c.send(ctx, method, path, credential, baseURL, proxyURL, headers, timeout, func(request *req.Request) {
+		if len(body) > 0 {
+			request.SetBodyBytes(body)
+		}
+	})
+}
+
+// ProxyUpload proxies a request whose body is streamed to Claude API as it is read instead of being
+// buffered (Files API multipart uploads), sent with the client's contentType (multipart boundary included)
+// The body goes out chunked, and the request gets the streaming deadline since its length is up to the client
+func (c *ClaudeAPIClient) ProxyUpload(
+	ctx context.Context,
+	method, path string,
+	credential Credential,
+	body io.Reader,
+	contentType string,
+	baseURL, proxyURL string,
+	headers map[string]string,
+) (*http.Response, error) {
+	headers = maps.Clone(headers)
+	if headers == nil {
+		headers = map[string]string{}
+	}
+	headers["Content-Type"] = contentType
+
+	return c.send(ctx, method, path, credential, baseURL, proxyURL, headers, c.streamTimeout, func(request *req.Request) {
+		request.SetBody(body)
+	})
+}
+
+// send sends a proxied request with the body set by setBody and returns the response with its live body
+// timeout bounds the whole exchange, reading the response body included (0 = none)
+func (c *ClaudeAPIClient) send(
+	ctx context.Context,
+	method, path string,
+	credential Credential,
+	baseURL, proxyURL string,
+	headers map[string]string,
+	timeout time.Duration,
+	setBody func(request *req.Request),
 ) (*http.Response, error) {
+	client, err := c.clientFor(baseURL, proxyURL)
+	if err != nil {
+		return nil, err
+	}
+
+	cancel := context.CancelFunc(func() {})
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+	}
+
 	// Create req request with context
-	// Common headers (Content-Type, Anthropic-Version, Anthropic-Beta) are already set
-	// Only add the Authorization header which varies per request
-	request := c.client.R().
+	// Common headers (Content-Type, Anthropic-Version, User-Agent, X-App) are already set
+	// Only add the authentication headers which vary per request
+	// Content-Length and Transfer-Encoding always come from the body actually sent: extra headers carrying
+	// them (e.g. a hand-edited account override) are dropped rather than contradicting a rewritten body
+	// The body is not read here: it is relayed as it arrives, and canceling ctx (client disconnect)
+	// aborts the upstream request instead of letting it run to completion in the background
+	request := client.R().
 		SetContext(ctx).
-		SetHeaders(map[string]string{
-			"Authorization": "Bearer " + accessToken,
-		})
+		DisableAutoReadResponse().
+		SetHeaders(httpproxy.WithoutFramingHeaders(headers)).
+		SetContextData(bodyLogSampledKey{}, c.bodyLog.sample())
+	setAuthentication(request, credential, headers)
+	setBody(request)
 
-	// Set body if present
-	if len(body) > 0 {
-		request.SetBodyBytes(body)
+	// Only idempotent requests are retried here: a POST (e.g. a message generation) may have reached
+	// Claude before the connection failed, and resending it would duplicate the generation and its usage
+	if isIdempotent(method) {
+		request.SetRetryCount(transportRetries).SetRetryBackoffInterval(1*time.Second, 5*time.Second)
 	}
 
 	// Execute request based on method
 	var resp *req.Response
-	var err error
 
 	switch method {
 	case http.MethodGet:
@@ -182,10 +377,96 @@ func (c *ClaudeAPIClient) ProxyRequest(
 	}
 
 	if err != nil {
+		cancel()
 		return nil, err
 	}
 
-	// Return the underlying *http.Response
-	// req automatically handles the response body properly for proxying
+	// Return the underlying *http.Response with its live body (caller must close it)
+	// The deadline keeps running while the body is read and is released when it is closed
+	resp.Response.Body = &cancelOnClose{ReadCloser: resp.Response.Body, cancel: cancel}
 	return resp.Response, nil
 }
+
+// setAuthentication sets the credential's headers: Bearer and the OAuth beta flag (unless headers set their own
+// anthropic-beta) for access tokens, x-api-key without the OAuth flag for API keys
+func setAuthentication(request *req.Request, credential Credential, headers map[string]string) {
+	var beta string
+	for name, value := range headers {
+		if strings.EqualFold(name, "anthropic-beta") {
+			beta = value
+		}
+	}
+
+	if !credential.APIKey {
+		request.SetHeader("Authorization", "Bearer "+credential.Secret)
+		if beta == "" {
+			request.SetHeader("anthropic-beta", OAuthBeta)
+		}
+		return
+	}
+
+	request.SetHeader("x-api-key", credential.Secret)
+	var flags []string
+	for _, flag := range strings.Split(beta, ",") {
+		if flag = strings.TrimSpace(flag); flag != "" && flag != OAuthBeta {
+			flags = append(flags, flag)
+		}
+	}
+	if len(flags) == 0 {
+		request.Headers.Del("anthropic-beta")
+		return
+	}
+	request.SetHeader("anthropic-beta", strings.Join(flags, ","))
+}
+
+// ServerDate sends an unauthenticated HEAD /v1/models to the default base URL and returns the response's
+// Date header with the times the request was sent and answered (any status will do: only the header matters)
+func (c *ClaudeAPIClient) ServerDate(ctx context.Context) (string, time.Time, time.Time, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	sent := time.Now()
+	resp, err := c.client.R().SetContext(ctx).Head("/v1/models")
+	received := time.Now()
+	if err != nil {
+		return "", sent, received, err
+	}
+
+	date := resp.Header.Get("Date")
+	if date == "" {
+		return "", sent, received, fmt.Errorf("response from Claude API has no Date header (status %d)", resp.StatusCode)
+	}
+	return date, sent, received, nil
+}
+
+// cancelOnClose releases a request's context when its response body is closed
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// isStreamingBody returns true if a JSON request body asks for a streamed (SSE) response
+func isStreamingBody(body []byte) bool {
+	if len(body) == 0 {
+		return false
+	}
+	var payload struct {
+		Stream bool `json:"stream"`
+	}
+	return json.Unmarshal(body, &payload) == nil && payload.Stream
+}
+
+// isIdempotent returns true for methods that can be safely resent after a transport error
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	return false
+}
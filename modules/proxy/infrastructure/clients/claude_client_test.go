@@ -0,0 +1,91 @@
+package clients
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"claude-proxy/config"
+
+	sctx "github.com/phathdt/service-context"
+)
+
+// droppingUpstream closes every connection without answering, counting the requests that reached it
+func droppingUpstream(t *testing.T) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err == nil {
+			conn.Close()
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, &hits
+}
+
+func newTestClaudeClient(baseURL string) *ClaudeAPIClient {
+	return NewClaudeAPIClient(
+		baseURL, 10*time.Second, 0, nil, config.LogBodiesNone, 0, sctx.GlobalLogger().GetLogger("test"),
+	)
+}
+
+func TestProxyRequestDoesNotRetryFailedStreamingPost(t *testing.T) {
+	upstream, hits := droppingUpstream(t)
+	client := newTestClaudeClient(upstream.URL)
+
+	body := []byte(`{"model":"claude-sonnet-4","stream":true,"messages":[{"role":"user","content":"hi"}]}`)
+	resp, err := client.ProxyRequest(
+		context.Background(), http.MethodPost, "/v1/messages", Credential{Secret: "token"}, body, "", "", nil,
+	)
+	if err == nil {
+		resp.Body.Close()
+		t.Fatal("ProxyRequest() succeeded against an upstream dropping connections")
+	}
+	if hits.Load() != 1 {
+		t.Errorf("upstream received the streaming POST %d times, want 1", hits.Load())
+	}
+}
+
+func TestProxyRequestRetriesIdempotentRequests(t *testing.T) {
+	if testing.Short() {
+		t.Skip("waits for the transport retry backoff")
+	}
+
+	upstream, hits := droppingUpstream(t)
+	client := newTestClaudeClient(upstream.URL)
+
+	resp, err := client.ProxyRequest(
+		context.Background(), http.MethodGet, "/v1/models", Credential{Secret: "token"}, nil, "", "", nil,
+	)
+	if err == nil {
+		resp.Body.Close()
+		t.Fatal("ProxyRequest() succeeded against an upstream dropping connections")
+	}
+	if want := int32(transportRetries + 1); hits.Load() != want {
+		t.Errorf("upstream received the GET %d times, want %d", hits.Load(), want)
+	}
+}
+
+func TestIsStreamingBody(t *testing.T) {
+	tests := []struct {
+		body string
+		want bool
+	}{
+		{`{"stream":true}`, true},
+		{`{"stream":false}`, false},
+		{`{"model":"claude"}`, false},
+		{`not json`, false},
+		{``, false},
+	}
+	for _, tt := range tests {
+		if got := isStreamingBody([]byte(tt.body)); got != tt.want {
+			t.Errorf("isStreamingBody(%q) = %t, want %t", tt.body, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package clients
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Webhook delivery headers
+const (
+	WebhookSignatureHeader = "X-Webhook-Signature" // "sha256=" + hex HMAC-SHA256 of the body
+	WebhookEventHeader     = "X-Webhook-Event"
+	WebhookIDHeader        = "X-Webhook-Id"
+)
+
+// maxWebhookErrorBody caps how much of an error response is kept for the failure log
+const maxWebhookErrorBody = 512
+
+// WebhookClient POSTs signed event payloads to the webhook URL
+type WebhookClient struct {
+	url        string
+	secret     string
+	httpClient *http.Client
+}
+
+// NewWebhookClient creates a webhook client; timeout bounds each delivery attempt
+func NewWebhookClient(url, secret string, timeout time.Duration) *WebhookClient {
+	return &WebhookClient{
+		url:        url,
+		secret:     secret,
+		httpClient: &http.Client{Timeout: timeout},
+	}
+}
+
+// Deliver POSTs one payload and returns the response status (0 on a transport error)
+// Any non-2xx status is returned with an error carrying the start of the response body
+func (c *WebhookClient) Deliver(ctx context.Context, eventID, eventType string, payload []byte) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(payload))
+	if err != nil {
+		return 0, fmt.Errorf("failed to create webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "claude-proxy-webhook")
+	req.Header.Set(WebhookIDHeader, eventID)
+	req.Header.Set(WebhookEventHeader, eventType)
+	req.Header.Set(WebhookSignatureHeader, SignWebhookPayload(c.secret, payload))
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, maxWebhookErrorBody))
+		return resp.StatusCode, fmt.Errorf("webhook returned status %d: %s", resp.StatusCode, body)
+	}
+	_, _ = io.Copy(io.Discard, resp.Body) // Drain so the connection is reused
+	return resp.StatusCode, nil
+}
+
+// SignWebhookPayload returns the signature header value of a payload: "sha256=" + hex HMAC-SHA256
+func SignWebhookPayload(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
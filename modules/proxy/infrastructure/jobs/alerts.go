@@ -0,0 +1,85 @@
+package jobs
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"claude-proxy/modules/proxy/domain/interfaces"
+
+	sctx "github.com/phathdt/service-context"
+	"github.com/robfig/cron/v3"
+)
+
+// alertEvaluateTimeout bounds one evaluation of the alert rules
+const alertEvaluateTimeout = 20 * time.Second
+
+// AlertScheduler evaluates the alert rules every interval
+type AlertScheduler struct {
+	evaluator  interfaces.AlertEvaluator
+	interval   time.Duration
+	rules      int
+	cron       *cron.Cron
+	jobRunning atomic.Bool
+	mu         sync.Mutex
+	logger     sctx.Logger
+}
+
+// NewAlertScheduler creates an alert evaluation scheduler
+func NewAlertScheduler(
+	evaluator interfaces.AlertEvaluator,
+	interval time.Duration,
+	appLogger sctx.Logger,
+) *AlertScheduler {
+	return &AlertScheduler{
+		evaluator: evaluator,
+		interval:  interval,
+		rules:     len(evaluator.Alerts()),
+		cron:      cron.New(),
+		logger:    appLogger.Withs(sctx.Fields{"component": "alert-scheduler"}),
+	}
+}
+
+// Start schedules the evaluations; nothing is scheduled without rules
+func (s *AlertScheduler) Start() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.rules == 0 {
+		s.logger.Info("No alert rules, alert scheduler not started")
+		return nil
+	}
+	if _, err := s.cron.AddFunc("@every "+s.interval.String(), s.runEvaluate); err != nil {
+		s.logger.Withs(sctx.Fields{"error": err.Error()}).Error("Failed to schedule alert job")
+		return err
+	}
+	s.cron.Start()
+
+	s.logger.Withs(sctx.Fields{
+		"interval": s.interval.String(),
+		"rules":    s.rules,
+	}).Info("Alert scheduler started")
+	return nil
+}
+
+// Stop stops the alert scheduler
+func (s *AlertScheduler) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.cron.Stop()
+}
+
+// runEvaluate evaluates the rules, skipping the run if the previous one is still going
+func (s *AlertScheduler) runEvaluate() {
+	if !s.jobRunning.CompareAndSwap(false, true) {
+		return
+	}
+	defer s.jobRunning.Store(false)
+
+	ctx, cancel := context.WithTimeout(context.Background(), alertEvaluateTimeout)
+	defer cancel()
+
+	s.evaluator.Evaluate(ctx, time.Now())
+}
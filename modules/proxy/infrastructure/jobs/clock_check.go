@@ -0,0 +1,116 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"claude-proxy/config"
+	proxyentities "claude-proxy/modules/proxy/domain/entities"
+	"claude-proxy/modules/proxy/domain/interfaces"
+	"claude-proxy/modules/proxy/infrastructure/clients"
+
+	sctx "github.com/phathdt/service-context"
+	"github.com/robfig/cron/v3"
+)
+
+// ClockCheckScheduler measures the local clock against Claude API at startup and every interval
+// Proxied responses feed the same monitor, so the periodic probe mostly matters when traffic is idle
+type ClockCheckScheduler struct {
+	claudeClient *clients.ClaudeAPIClient
+	monitor      interfaces.ClockMonitor
+	interval     time.Duration
+	startup      string // config.ClockStartupBlock, config.ClockStartupWarn or config.ClockStartupOff
+	cron         *cron.Cron
+	jobRunning   atomic.Bool
+	mu           sync.Mutex
+	logger       sctx.Logger
+}
+
+// NewClockCheckScheduler creates a clock check scheduler
+func NewClockCheckScheduler(
+	claudeClient *clients.ClaudeAPIClient,
+	monitor interfaces.ClockMonitor,
+	interval time.Duration,
+	startup string,
+	appLogger sctx.Logger,
+) *ClockCheckScheduler {
+	return &ClockCheckScheduler{
+		claudeClient: claudeClient,
+		monitor:      monitor,
+		interval:     interval,
+		startup:      startup,
+		cron:         cron.New(),
+		logger:       appLogger.Withs(sctx.Fields{"component": "clock-check"}),
+	}
+}
+
+// Start runs the startup check and schedules the periodic ones
+// Under the block policy an excessive skew is returned as an error, which stops the server from starting;
+// failing to reach Claude API is only logged, so an upstream outage never prevents startup
+func (s *ClockCheckScheduler) Start(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.startup != config.ClockStartupOff {
+		if err := s.check(ctx); err != nil {
+			s.logger.Withs(sctx.Fields{"error": err.Error()}).Warn("Startup clock check failed, clock skew unknown")
+		} else if status := s.monitor.Status(); status.Exceeded() && s.startup == config.ClockStartupBlock {
+			return fmt.Errorf(
+				"system clock is off by %s from Claude API (clock.max_skew %s): sync the clock (NTP) "+
+					"or set clock.startup_check to warn",
+				status.Skew, status.MaxSkew,
+			)
+		}
+	}
+
+	if _, err := s.cron.AddFunc("@every "+s.interval.String(), s.runCheck); err != nil {
+		s.logger.Withs(sctx.Fields{"error": err.Error()}).Error("Failed to schedule clock check job")
+		return err
+	}
+	s.cron.Start()
+
+	s.logger.Withs(sctx.Fields{
+		"interval": s.interval.String(),
+		"startup":  s.startup,
+	}).Info("Clock check scheduler started")
+	return nil
+}
+
+// Stop stops the clock check scheduler
+func (s *ClockCheckScheduler) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.cron.Stop()
+}
+
+// runCheck runs one periodic check, skipping it while the previous one is still running
+func (s *ClockCheckScheduler) runCheck() {
+	if !s.jobRunning.CompareAndSwap(false, true) {
+		return
+	}
+	defer s.jobRunning.Store(false)
+
+	if err := s.check(context.Background()); err != nil {
+		s.logger.Withs(sctx.Fields{"error": err.Error()}).Warn("Clock check failed")
+	}
+}
+
+// check probes Claude API for its Date header and records the measurement
+func (s *ClockCheckScheduler) check(ctx context.Context) error {
+	date, sent, received, err := s.claudeClient.ServerDate(ctx)
+	if err != nil {
+		return err
+	}
+	s.monitor.Observe(date, sent, received, proxyentities.ClockSourceProbe)
+
+	status := s.monitor.Status()
+	s.logger.Withs(sctx.Fields{
+		"skew":     status.Skew.String(),
+		"max_skew": status.MaxSkew.String(),
+	}).Debug("Clock checked against Claude API")
+	return nil
+}
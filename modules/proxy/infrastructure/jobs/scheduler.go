@@ -2,33 +2,98 @@ package jobs
 
 import (
 	"context"
+	"fmt"
+	"math/rand/v2"
+	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"claude-proxy/modules/auth/domain/entities"
 	"claude-proxy/modules/auth/domain/interfaces"
+	"claude-proxy/pkg/telegram"
 
 	sctx "github.com/phathdt/service-context"
 	"github.com/robfig/cron/v3"
 )
 
+// refreshInterval is how often the token refresh job runs (cron "0 * * * *")
+const refreshInterval = time.Hour
+
+// cooldownCheckSchedule is how often accounts whose new-account cooldown elapsed are let into the rotation
+const cooldownCheckSchedule = "@every 1m"
+
+// availabilityCheckSchedule is how often the accounts within their availability windows are counted
+const availabilityCheckSchedule = "@every 1m"
+
+// conflictCheckSchedule is how often newly conflicted accounts (refresh token rotated elsewhere) are reported
+const conflictCheckSchedule = "@every 1m"
+
+// RefreshOptions controls how a token refresh run paces its OAuth requests
+type RefreshOptions struct {
+	MaxConcurrent int           // Refreshes in flight at once
+	Spread        time.Duration // Window the refreshes of one run are evenly spaced over
+	Jitter        time.Duration // Random extra delay of up to this much per refresh
+	AlertFailures int           // Failed refreshes in one run that trigger a Telegram alert (0 = never)
+	// AlertUnavailable sends a Telegram alert when availability windows leave no account in the rotation
+	AlertUnavailable bool
+}
+
+// clock tells the time and waits for it; staggered refreshes go through it so tests can fake time
+type clock interface {
+	Now() time.Time
+	SleepUntil(ctx context.Context, t time.Time) error
+}
+
+// systemClock is the wall clock
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+func (systemClock) SleepUntil(ctx context.Context, t time.Time) error { return sleepUntil(ctx, t) }
+
 // Scheduler manages in-memory job scheduling with cron
 type Scheduler struct {
 	cron       *cron.Cron
 	accountSvc interfaces.AccountService
+	notifier   *telegram.Client
+	options    RefreshOptions
 	logger     sctx.Logger
+	clock      clock
 	mu         sync.Mutex
 	running    bool
+	jobRunning atomic.Bool
+
+	// noneInWindow is true when the last availability check found accounts, none of them within its
+	// availability windows
+	noneInWindow atomic.Bool
+
+	// reportedConflicts maps conflicted account IDs to the detection time already reported, so each conflict
+	// is reported once (an unresolved one again after a restart)
+	conflictsMu       sync.Mutex
+	reportedConflicts map[string]time.Time
 }
 
 // NewScheduler creates a new in-memory scheduler
 func NewScheduler(
 	accountSvc interfaces.AccountService,
+	notifier *telegram.Client,
+	options RefreshOptions,
 	logger sctx.Logger,
 ) *Scheduler {
+	if options.MaxConcurrent < 1 {
+		options.MaxConcurrent = 1
+	}
 	return &Scheduler{
 		cron:       cron.New(),
 		accountSvc: accountSvc,
+		notifier:   notifier,
+		options:    options,
 		logger:     logger,
+		clock:      systemClock{},
+
+		reportedConflicts: make(map[string]time.Time),
 	}
 }
 
@@ -50,6 +115,27 @@ func (s *Scheduler) Start() error {
 		return err
 	}
 
+	if _, err := s.cron.AddFunc(cooldownCheckSchedule, s.EndCooldownsJob); err != nil {
+		s.logger.Withs(sctx.Fields{
+			"error": err.Error(),
+		}).Error("Failed to register cooldown job")
+		return err
+	}
+
+	if _, err := s.cron.AddFunc(availabilityCheckSchedule, s.CheckAvailabilityJob); err != nil {
+		s.logger.Withs(sctx.Fields{
+			"error": err.Error(),
+		}).Error("Failed to register availability check job")
+		return err
+	}
+
+	if _, err := s.cron.AddFunc(conflictCheckSchedule, s.ReportRefreshConflictsJob); err != nil {
+		s.logger.Withs(sctx.Fields{
+			"error": err.Error(),
+		}).Error("Failed to register refresh conflict job")
+		return err
+	}
+
 	s.cron.Start()
 	s.running = true
 
@@ -78,11 +164,21 @@ func (s *Scheduler) Stop() {
 	s.logger.Info("In-memory job scheduler stopped")
 }
 
-// RefreshTokensJob refreshes tokens for all active accounts and recovers rate-limited accounts
+// RefreshTokensJob recovers rate-limited accounts, then refreshes the tokens of active accounts that
+// expire before the next run. Refreshes go soonest expiry first, evenly spaced over the spread window
+// with some jitter and at most maxConcurrent at a time, so OAuth requests never leave in a burst.
 func (s *Scheduler) RefreshTokensJob() {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	// The startup run and an hourly tick must not overlap
+	if !s.jobRunning.CompareAndSwap(false, true) {
+		s.logger.Warn("Token refresh job still running, skipping this run")
+		return
+	}
+	defer s.jobRunning.Store(false)
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.options.Spread+s.options.Jitter+5*time.Minute)
 	defer cancel()
 
+	start := time.Now()
 	s.logger.Debug("Starting token refresh and recovery job")
 
 	// Step 1: Recover rate-limited accounts with expired limits
@@ -97,20 +193,286 @@ func (s *Scheduler) RefreshTokensJob() {
 		}).Info("Recovered rate limited accounts")
 	}
 
-	// Step 2: Refresh active accounts using service method
-	refreshedCount, failedCount, skippedCount, err := s.accountSvc.RefreshAllAccounts(ctx)
+	// Step 2: Pick the accounts due for a refresh, soonest expiry first
+	accounts, err := s.accountSvc.ListAccounts(ctx)
 	if err != nil {
 		s.logger.Withs(sctx.Fields{
 			"error": err.Error(),
-		}).Error("Failed to refresh accounts")
+		}).Error("Failed to list accounts for token refresh")
 		return
 	}
 
-	// Log summary
+	var due []*entities.Account
+	skippedCount, manualCount, apiKeyCount, notDueCount := 0, 0, 0, 0
+	for _, account := range accounts {
+		switch {
+		case account.IsAPIKey():
+			// API keys never expire
+			apiKeyCount++
+		case !account.AutoRefresh:
+			// Manual mode: tokens are pushed through the credentials endpoint
+			manualCount++
+		case !account.IsActive():
+			// Rate-limited accounts are recovered above; invalid and inactive ones need an operator
+			skippedCount++
+		case account.ExpiresWithin(refreshInterval + s.options.Spread + s.options.Jitter):
+			due = append(due, account)
+		default:
+			notDueCount++
+		}
+	}
+	sort.Slice(due, func(i, j int) bool { return due[i].ExpiresAt.Before(due[j].ExpiresAt) })
+
+	// Step 3: Refresh them, staggered
+	failures := s.refreshStaggered(ctx, due)
+
 	s.logger.Withs(sctx.Fields{
-		"refreshed": refreshedCount,
-		"failed":    failedCount,
-		"skipped":   skippedCount,
-		"recovered": recoveredCount,
+		"refreshed":   len(due) - len(failures),
+		"failed":      len(failures),
+		"skipped":     skippedCount,
+		"manual":      manualCount,
+		"api_key":     apiKeyCount,
+		"not_due":     notDueCount,
+		"recovered":   recoveredCount,
+		"duration_ms": time.Since(start).Milliseconds(),
 	}).Info("Token refresh and recovery job completed")
+
+	if s.options.AlertFailures > 0 && len(failures) >= s.options.AlertFailures {
+		s.sendFailureAlert(len(due), failures)
+	}
+}
+
+// EndCooldownsJob lets accounts whose new-account cooldown elapsed into the rotation and reports them to Telegram
+func (s *Scheduler) EndCooldownsJob() {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	ended, err := s.accountSvc.EndElapsedCooldowns(ctx)
+	if err != nil {
+		s.logger.Withs(sctx.Fields{
+			"error": err.Error(),
+		}).Error("Failed to end elapsed account cooldowns")
+		return
+	}
+
+	for _, account := range ended {
+		text := fmt.Sprintf("🟢 *Account entered the rotation*: %s (new account cooldown ended)", markdownCode(account.Name))
+		if err := s.notifier.SendMessage(ctx, text); err != nil {
+			s.logger.Withs(sctx.Fields{"error": err.Error()}).Warn("Failed to send cooldown notification")
+		}
+	}
+}
+
+// CheckAvailabilityJob warns, and with AlertUnavailable notifies Telegram, when accounts exist but availability
+// windows leave none of them in the rotation; only the change is reported, not every minute it lasts
+func (s *Scheduler) CheckAvailabilityJob() {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	accounts, err := s.accountSvc.ListAccounts(ctx)
+	if err != nil {
+		s.logger.Withs(sctx.Fields{
+			"error": err.Error(),
+		}).Error("Failed to list accounts for availability check")
+		return
+	}
+
+	now := time.Now()
+	pool, inWindow := 0, 0
+	var next time.Time
+	for _, account := range accounts {
+		// Accounts an operator took out of the rotation don't count
+		if account.IsDeleted() || account.Shadow || account.Status == entities.AccountStatusInactive ||
+			account.Status == entities.AccountStatusInvalid || account.IsConflicted() {
+			continue
+		}
+		pool++
+		if account.IsWithinAvailability(now) {
+			inWindow++
+		} else if at, ok := account.NextAvailabilityChange(now); ok && (next.IsZero() || at.Before(next)) {
+			next = at
+		}
+	}
+
+	noneInWindow := pool > 0 && inWindow == 0
+	if s.noneInWindow.Swap(noneInWindow) == noneInWindow {
+		return
+	}
+
+	if !noneInWindow {
+		s.logger.Withs(sctx.Fields{
+			"available_accounts": inWindow,
+		}).Info("Accounts are within their availability windows again")
+		return
+	}
+
+	fields := sctx.Fields{"accounts": pool}
+	text := fmt.Sprintf("🌙 *No account available*: all %d accounts are outside their availability windows", pool)
+	if !next.IsZero() {
+		fields["next_available_at"] = next.Format(time.RFC3339)
+		text += fmt.Sprintf(" until %s", markdownCode(next.Format(time.RFC3339)))
+	}
+	s.logger.Withs(fields).Warn("No account is within its availability windows")
+
+	if s.options.AlertUnavailable {
+		if err := s.notifier.SendMessage(ctx, text); err != nil {
+			s.logger.Withs(sctx.Fields{"error": err.Error()}).Warn("Failed to send availability alert")
+		}
+	}
+}
+
+// ReportRefreshConflictsJob warns and notifies Telegram of accounts newly found conflicted: another client
+// (a Claude Code login or a second proxy) rotated their refresh token, so they wait for fresh credentials
+func (s *Scheduler) ReportRefreshConflictsJob() {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	accounts, err := s.accountSvc.ListAccounts(ctx)
+	if err != nil {
+		s.logger.Withs(sctx.Fields{
+			"error": err.Error(),
+		}).Error("Failed to list accounts for refresh conflict check")
+		return
+	}
+
+	s.conflictsMu.Lock()
+	defer s.conflictsMu.Unlock()
+
+	conflicted := make(map[string]bool)
+	for _, account := range accounts {
+		if !account.IsConflicted() || account.ConflictDetectedAt == nil {
+			continue
+		}
+		conflicted[account.ID] = true
+		if reported, ok := s.reportedConflicts[account.ID]; ok && reported.Equal(*account.ConflictDetectedAt) {
+			continue
+		}
+		s.reportedConflicts[account.ID] = *account.ConflictDetectedAt
+
+		s.logger.Withs(sctx.Fields{
+			"account_id":   account.ID,
+			"account_name": account.Name,
+			"detected_at":  account.ConflictDetectedAt.Format(time.RFC3339),
+		}).Warn("Concurrent refresher detected: another client is using the same account")
+
+		text := fmt.Sprintf(
+			"🔀 *Concurrent refresher detected*: %s\nAnother client rotated its refresh token; the account is "+
+				"out of the rotation until fresh credentials are posted to %s",
+			markdownCode(account.Name), markdownCode("POST /api/accounts/"+account.ID+"/adopt"),
+		)
+		if err := s.notifier.SendMessage(ctx, text); err != nil {
+			s.logger.Withs(sctx.Fields{"error": err.Error()}).Warn("Failed to send refresh conflict alert")
+		}
+	}
+
+	// Forget resolved conflicts so a new one on the same account is reported
+	for accountID := range s.reportedConflicts {
+		if !conflicted[accountID] {
+			delete(s.reportedConflicts, accountID)
+		}
+	}
+}
+
+// refreshFailure is an account whose scheduled refresh failed
+type refreshFailure struct {
+	accountName string
+	err         error
+}
+
+// refreshStaggered refreshes accounts in order, starting the i-th one at i*spread/n plus jitter, with at
+// most maxConcurrent refreshes in flight, and returns the failures
+// Accounts not started when ctx ends count as failed.
+func (s *Scheduler) refreshStaggered(ctx context.Context, accounts []*entities.Account) []refreshFailure {
+	var failures []refreshFailure
+	if len(accounts) == 0 {
+		return failures
+	}
+
+	var (
+		mu        sync.Mutex
+		wg        sync.WaitGroup
+		slots     = make(chan struct{}, s.options.MaxConcurrent)
+		spacing   = s.options.Spread / time.Duration(len(accounts))
+		startedAt = s.clock.Now()
+	)
+	fail := func(account *entities.Account, err error) {
+		mu.Lock()
+		failures = append(failures, refreshFailure{accountName: account.Name, err: err})
+		mu.Unlock()
+	}
+
+	for i, account := range accounts {
+		startAt := startedAt.Add(time.Duration(i) * spacing)
+		if s.options.Jitter > 0 {
+			startAt = startAt.Add(time.Duration(rand.Int64N(int64(s.options.Jitter))))
+		}
+
+		if err := s.clock.SleepUntil(ctx, startAt); err != nil {
+			fail(account, err)
+			continue
+		}
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+			fail(account, ctx.Err())
+			continue
+		}
+
+		wg.Add(1)
+		go func(account *entities.Account) {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			if err := s.accountSvc.RefreshAccount(ctx, account.ID); err != nil {
+				s.logger.Withs(sctx.Fields{
+					"account_id": account.ID,
+					"error":      err.Error(),
+				}).Warn("Scheduled token refresh failed")
+				fail(account, err)
+			}
+		}(account)
+	}
+
+	wg.Wait()
+	return failures
+}
+
+// sendFailureAlert reports a run with too many failed refreshes to Telegram
+func (s *Scheduler) sendFailureAlert(attempted int, failures []refreshFailure) {
+	sort.Slice(failures, func(i, j int) bool { return failures[i].accountName < failures[j].accountName })
+
+	// Names and errors go in code spans so Markdown characters in them can't break the message
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d of %d scheduled token refreshes failed:\n", len(failures), attempted)
+	for _, failure := range failures {
+		fmt.Fprintf(&b, "\n• %s: %s", markdownCode(failure.accountName), markdownCode(failure.err.Error()))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := s.notifier.SendMarkdownMessage(ctx, "Token refresh failures", b.String()); err != nil {
+		s.logger.Withs(sctx.Fields{"error": err.Error()}).Warn("Failed to send token refresh alert")
+	}
+}
+
+// markdownCode wraps text in a Markdown code span
+func markdownCode(text string) string {
+	return "`" + strings.ReplaceAll(text, "`", "'") + "`"
+}
+
+// sleepUntil waits until t, returning early with the context error if ctx ends first
+func sleepUntil(ctx context.Context, t time.Time) error {
+	delay := time.Until(t)
+	if delay <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
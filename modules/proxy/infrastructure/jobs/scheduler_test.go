@@ -0,0 +1,272 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"claude-proxy/modules/auth/domain/entities"
+	"claude-proxy/modules/auth/domain/interfaces"
+	"claude-proxy/pkg/logging"
+
+	sctx "github.com/phathdt/service-context"
+)
+
+// quietLogger returns a logger dropping everything below errors
+func quietLogger(tb testing.TB) sctx.Logger {
+	tb.Helper()
+
+	registry, err := logging.NewRegistry("error")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return registry.Wrap(sctx.GlobalLogger().GetLogger("test"))
+}
+
+// fakeClock is a clock whose waits return at once, moving its time forward to their end
+type fakeClock struct {
+	mu     sync.Mutex
+	start  time.Time
+	now    time.Time
+	sleeps []time.Duration // Ends of the waits asked for, from start
+}
+
+func newFakeClock() *fakeClock {
+	start := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+	return &fakeClock{start: start, now: start}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) SleepUntil(ctx context.Context, t time.Time) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sleeps = append(c.sleeps, t.Sub(c.start))
+	if t.After(c.now) {
+		c.now = t
+	}
+	return nil
+}
+
+// refreshRecorder is an account service recording the refreshes it is asked for
+type refreshRecorder struct {
+	interfaces.AccountService
+	clock    *fakeClock
+	accounts []*entities.Account
+	hold     time.Duration   // Real time each refresh takes, so refreshes overlap
+	failing  map[string]bool // Accounts whose refresh fails
+
+	mu          sync.Mutex
+	refreshed   []string
+	inFlight    int
+	maxInFlight int
+}
+
+func (r *refreshRecorder) ListAccounts(context.Context) ([]*entities.Account, error) {
+	return r.accounts, nil
+}
+
+func (r *refreshRecorder) RecoverRateLimitedAccounts(context.Context) (int, error) {
+	return 0, nil
+}
+
+func (r *refreshRecorder) RefreshAccount(_ context.Context, accountID string) error {
+	r.mu.Lock()
+	r.refreshed = append(r.refreshed, accountID)
+	r.inFlight++
+	r.maxInFlight = max(r.maxInFlight, r.inFlight)
+	r.mu.Unlock()
+
+	time.Sleep(r.hold)
+
+	r.mu.Lock()
+	r.inFlight--
+	r.mu.Unlock()
+	if r.failing[accountID] {
+		return errors.New("invalid_grant")
+	}
+	return nil
+}
+
+// newTestScheduler creates a scheduler refreshing through recorder on a fake clock
+func newTestScheduler(t *testing.T, recorder *refreshRecorder, options RefreshOptions) *Scheduler {
+	t.Helper()
+
+	recorder.clock = newFakeClock()
+	scheduler := NewScheduler(recorder, nil, options, quietLogger(t))
+	scheduler.clock = recorder.clock
+	return scheduler
+}
+
+// dueAccounts returns n active accounts expiring in the coming minutes, in expiry order
+func dueAccounts(n int) []*entities.Account {
+	accounts := make([]*entities.Account, n)
+	for i := range accounts {
+		accounts[i] = &entities.Account{
+			ID:          fmt.Sprint("acc_", i),
+			Name:        fmt.Sprint("account ", i),
+			Status:      entities.AccountStatusActive,
+			AutoRefresh: true,
+			ExpiresAt:   time.Now().Add(time.Duration(i+1) * time.Minute),
+		}
+	}
+	return accounts
+}
+
+func accountIDs(accounts []*entities.Account) []string {
+	ids := make([]string, len(accounts))
+	for i, account := range accounts {
+		ids[i] = account.ID
+	}
+	return ids
+}
+
+func TestRefreshStaggeredSpacing(t *testing.T) {
+	tests := []struct {
+		name     string
+		accounts int
+		spread   time.Duration
+		want     []time.Duration // Start of each refresh, from the run's start
+	}{
+		{"one account", 1, time.Hour, []time.Duration{0}},
+		{"even spacing", 4, time.Hour, []time.Duration{0, 15 * time.Minute, 30 * time.Minute, 45 * time.Minute}},
+		{"uneven spacing", 3, 10 * time.Minute, []time.Duration{0, 200 * time.Second, 400 * time.Second}},
+		{"no spread", 3, 0, []time.Duration{0, 0, 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := &refreshRecorder{}
+			scheduler := newTestScheduler(t, recorder, RefreshOptions{MaxConcurrent: 1, Spread: tt.spread})
+			accounts := dueAccounts(tt.accounts)
+
+			if failures := scheduler.refreshStaggered(context.Background(), accounts); len(failures) != 0 {
+				t.Fatalf("failures = %v", failures)
+			}
+			if !slices.Equal(recorder.clock.sleeps, tt.want) {
+				t.Errorf("waited until %v, want %v", recorder.clock.sleeps, tt.want)
+			}
+			if want := accountIDs(accounts); !slices.Equal(recorder.refreshed, want) {
+				t.Errorf("refreshed %v, want %v in order", recorder.refreshed, want)
+			}
+		})
+	}
+}
+
+func TestRefreshStaggeredJitter(t *testing.T) {
+	const jitter = 30 * time.Second
+	recorder := &refreshRecorder{}
+	scheduler := newTestScheduler(t, recorder, RefreshOptions{
+		MaxConcurrent: 20,
+		Spread:        20 * time.Minute,
+		Jitter:        jitter,
+	})
+
+	scheduler.refreshStaggered(context.Background(), dueAccounts(20))
+	if len(recorder.clock.sleeps) != 20 {
+		t.Fatalf("%d waits, want one per account", len(recorder.clock.sleeps))
+	}
+	jittered := false
+	for i, at := range recorder.clock.sleeps {
+		slot := time.Duration(i) * time.Minute
+		if at < slot || at >= slot+jitter {
+			t.Errorf("refresh %d waited until %v, want within [%v, %v)", i, at, slot, slot+jitter)
+		}
+		jittered = jittered || at != slot
+	}
+	if !jittered {
+		t.Error("no refresh was delayed by any jitter")
+	}
+}
+
+func TestRefreshStaggeredConcurrencyCap(t *testing.T) {
+	for _, limit := range []int{1, 3} {
+		t.Run(fmt.Sprint(limit, " at a time"), func(t *testing.T) {
+			recorder := &refreshRecorder{hold: 10 * time.Millisecond}
+			scheduler := newTestScheduler(t, recorder, RefreshOptions{MaxConcurrent: limit})
+
+			if failures := scheduler.refreshStaggered(context.Background(), dueAccounts(8)); len(failures) != 0 {
+				t.Fatalf("failures = %v", failures)
+			}
+			if len(recorder.refreshed) != 8 {
+				t.Errorf("%d refreshes, want 8", len(recorder.refreshed))
+			}
+			if recorder.maxInFlight != limit {
+				t.Errorf("up to %d refreshes in flight, want %d", recorder.maxInFlight, limit)
+			}
+		})
+	}
+}
+
+func TestRefreshStaggeredFailures(t *testing.T) {
+	recorder := &refreshRecorder{failing: map[string]bool{"acc_1": true}}
+	scheduler := newTestScheduler(t, recorder, RefreshOptions{MaxConcurrent: 2, Spread: time.Minute})
+
+	failures := scheduler.refreshStaggered(context.Background(), dueAccounts(3))
+	if len(failures) != 1 || failures[0].accountName != "account 1" {
+		t.Errorf("failures = %v, want account 1's", failures)
+	}
+
+	// Accounts not started when the run's context ends count as failed
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	recorder = &refreshRecorder{}
+	scheduler = newTestScheduler(t, recorder, RefreshOptions{MaxConcurrent: 2, Spread: time.Minute})
+	failures = scheduler.refreshStaggered(ctx, dueAccounts(3))
+	if len(failures) != 3 || len(recorder.refreshed) != 0 {
+		t.Fatalf("canceled run: %d failures, %d refreshes; want every account failed", len(failures),
+			len(recorder.refreshed))
+	}
+	for _, failure := range failures {
+		if !errors.Is(failure.err, context.Canceled) {
+			t.Errorf("%s failed with %v, want the context error", failure.accountName, failure.err)
+		}
+	}
+}
+
+func TestRefreshTokensJobStaggersDueAccountsByExpiry(t *testing.T) {
+	account := func(id string, expiresIn time.Duration, configure func(a *entities.Account)) *entities.Account {
+		a := &entities.Account{
+			ID:          id,
+			Name:        id,
+			Status:      entities.AccountStatusActive,
+			AutoRefresh: true,
+			ExpiresAt:   time.Now().Add(expiresIn),
+		}
+		if configure != nil {
+			configure(a)
+		}
+		return a
+	}
+	recorder := &refreshRecorder{accounts: []*entities.Account{
+		account("later", 50*time.Minute, nil),
+		account("not_due", 3*time.Hour, nil),
+		account("expired", -time.Hour, nil),
+		account("manual", 10*time.Minute, func(a *entities.Account) { a.AutoRefresh = false }),
+		account("api_key", 0, func(a *entities.Account) { a.AuthType = entities.AccountAuthAPIKey }),
+		account("inactive", 10*time.Minute, (*entities.Account).Deactivate),
+		account("soon", 10*time.Minute, nil),
+		account("end_of_window", 65*time.Minute, nil),
+	}}
+	scheduler := newTestScheduler(t, recorder, RefreshOptions{MaxConcurrent: 1, Spread: 12 * time.Minute})
+
+	scheduler.RefreshTokensJob()
+	// Due: expiring before the next run ends its spread window (1h12m), soonest expiry first
+	if want := []string{"expired", "soon", "later", "end_of_window"}; !slices.Equal(recorder.refreshed, want) {
+		t.Errorf("refreshed %v, want %v", recorder.refreshed, want)
+	}
+	want := []time.Duration{0, 3 * time.Minute, 6 * time.Minute, 9 * time.Minute}
+	if !slices.Equal(recorder.clock.sleeps, want) {
+		t.Errorf("refreshes waited until %v, want %v", recorder.clock.sleeps, want)
+	}
+}
@@ -0,0 +1,84 @@
+package jobs
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"claude-proxy/modules/proxy/domain/interfaces"
+
+	sctx "github.com/phathdt/service-context"
+	"github.com/robfig/cron/v3"
+)
+
+// upstreamUsageCollectTimeout bounds one collection of every admin-capable account's usage report
+const upstreamUsageCollectTimeout = 10 * time.Minute
+
+// UpstreamUsageScheduler collects the usage reports of admin-capable accounts at startup and every interval
+// Saving the locally counted usage between collections is left to the sync job.
+type UpstreamUsageScheduler struct {
+	usage      interfaces.UpstreamUsage
+	interval   time.Duration
+	cron       *cron.Cron
+	jobRunning atomic.Bool
+	mu         sync.Mutex
+	logger     sctx.Logger
+}
+
+// NewUpstreamUsageScheduler creates an upstream usage collection scheduler
+func NewUpstreamUsageScheduler(
+	usage interfaces.UpstreamUsage,
+	interval time.Duration,
+	appLogger sctx.Logger,
+) *UpstreamUsageScheduler {
+	return &UpstreamUsageScheduler{
+		usage:    usage,
+		interval: interval,
+		cron:     cron.New(),
+		logger:   appLogger.Withs(sctx.Fields{"component": "upstream-usage-scheduler"}),
+	}
+}
+
+// Start schedules the collections and runs the first one in the background
+func (s *UpstreamUsageScheduler) Start() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.cron.AddFunc("@every "+s.interval.String(), s.runCollect); err != nil {
+		s.logger.Withs(sctx.Fields{"error": err.Error()}).Error("Failed to schedule upstream usage job")
+		return err
+	}
+	s.cron.Start()
+
+	s.logger.Withs(sctx.Fields{"interval": s.interval.String()}).Info("Upstream usage scheduler started")
+
+	go s.runCollect()
+	return nil
+}
+
+// Stop stops the upstream usage scheduler
+func (s *UpstreamUsageScheduler) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.cron.Stop()
+}
+
+// runCollect collects the usage reports, skipping the run if the previous one is still going
+func (s *UpstreamUsageScheduler) runCollect() {
+	if !s.jobRunning.CompareAndSwap(false, true) {
+		return
+	}
+	defer s.jobRunning.Store(false)
+
+	ctx, cancel := context.WithTimeout(context.Background(), upstreamUsageCollectTimeout)
+	defer cancel()
+
+	start := time.Now()
+	if err := s.usage.Collect(ctx); err != nil {
+		s.logger.Withs(sctx.Fields{"error": err.Error()}).Error("Upstream usage collection failed")
+		return
+	}
+	s.logger.Withs(sctx.Fields{"duration": time.Since(start).String()}).Debug("Upstream usage collection completed")
+}
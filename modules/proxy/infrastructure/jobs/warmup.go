@@ -0,0 +1,187 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"claude-proxy/modules/auth/domain/entities"
+	"claude-proxy/modules/auth/domain/interfaces"
+	"claude-proxy/modules/proxy/infrastructure/clients"
+	"claude-proxy/pkg/requestid"
+
+	sctx "github.com/phathdt/service-context"
+	"github.com/robfig/cron/v3"
+)
+
+// warmupTimeout bounds one priming request
+const warmupTimeout = time.Minute
+
+// WarmupOptions controls the priming requests sent through accounts that become available
+type WarmupOptions struct {
+	Prompt   string        // System prompt cached by the priming request
+	Model    string        // Model of the priming request
+	Interval time.Duration // How often accounts are checked for becoming available
+}
+
+// WarmupScheduler primes the prompt cache of accounts when they become available for proxying
+// Prompt caching is per organization, so an account the load balancer fails over to starts cold. Every
+// interval the scheduler looks for accounts that were not available at the previous check (all of them at
+// startup, then recovered, restored and new accounts) and sends one priming request through each. Priming
+// requests bypass the proxy service: they never count toward token statistics, quotas or webhooks, and a
+// failed one is only logged, leaving the account's status untouched.
+type WarmupScheduler struct {
+	accountSvc   interfaces.AccountService
+	claudeClient *clients.ClaudeAPIClient
+	options      WarmupOptions
+	body         []byte
+	cron         *cron.Cron
+	available    map[string]bool // Accounts available at the previous check
+	jobRunning   atomic.Bool
+	mu           sync.Mutex
+	logger       sctx.Logger
+}
+
+// NewWarmupScheduler creates a warmup scheduler for the configured priming prompt
+func NewWarmupScheduler(
+	accountSvc interfaces.AccountService,
+	claudeClient *clients.ClaudeAPIClient,
+	options WarmupOptions,
+	appLogger sctx.Logger,
+) (*WarmupScheduler, error) {
+	body, err := json.Marshal(map[string]interface{}{
+		"model":      options.Model,
+		"max_tokens": 1,
+		"system": []map[string]interface{}{{
+			"type":          "text",
+			"text":          options.Prompt,
+			"cache_control": map[string]string{"type": "ephemeral"},
+		}},
+		"messages": []map[string]string{{"role": "user", "content": "."}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode warmup request: %w", err)
+	}
+
+	return &WarmupScheduler{
+		accountSvc:   accountSvc,
+		claudeClient: claudeClient,
+		options:      options,
+		body:         body,
+		cron:         cron.New(),
+		available:    make(map[string]bool),
+		logger:       appLogger.Withs(sctx.Fields{"component": "warmup-scheduler"}),
+	}, nil
+}
+
+// Start schedules the availability checks and runs the first one (warming every available account)
+func (s *WarmupScheduler) Start() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.cron.AddFunc("@every "+s.options.Interval.String(), s.runWarmup); err != nil {
+		s.logger.Withs(sctx.Fields{"error": err.Error()}).Error("Failed to schedule warmup job")
+		return err
+	}
+	s.cron.Start()
+
+	s.logger.Withs(sctx.Fields{
+		"interval":     s.options.Interval.String(),
+		"model":        s.options.Model,
+		"prompt_bytes": len(s.options.Prompt),
+	}).Info("Warmup scheduler started")
+
+	go s.runWarmup()
+	return nil
+}
+
+// Stop stops the warmup scheduler
+func (s *WarmupScheduler) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.cron.Stop()
+}
+
+// runWarmup primes every account that became available since the previous check, one at a time
+func (s *WarmupScheduler) runWarmup() {
+	if !s.jobRunning.CompareAndSwap(false, true) {
+		return
+	}
+	defer s.jobRunning.Store(false)
+
+	ctx := context.Background()
+	accounts, err := s.accountSvc.ListAccounts(ctx)
+	if err != nil {
+		s.logger.Withs(sctx.Fields{"error": err.Error()}).Error("Failed to list accounts for warmup")
+		return
+	}
+
+	available := make(map[string]bool, len(accounts))
+	for _, account := range accounts {
+		if !account.IsUsableNow() || account.IsOverQuota() {
+			continue
+		}
+		available[account.ID] = true
+		if !s.available[account.ID] {
+			s.warm(ctx, account)
+		}
+	}
+	s.available = available
+}
+
+// warm sends one priming request through the account, logging the outcome
+func (s *WarmupScheduler) warm(ctx context.Context, account *entities.Account) {
+	ctx, cancel := context.WithTimeout(ctx, warmupTimeout)
+	defer cancel()
+
+	fields := sctx.Fields{
+		"warmup":       true,
+		"account_id":   account.ID,
+		"account_name": account.Name,
+	}
+
+	accessToken, err := s.accountSvc.GetValidToken(ctx, account.ID)
+	if err != nil {
+		fields["error"] = err.Error()
+		s.logger.Withs(fields).Warn("Warmup skipped: no valid access token")
+		return
+	}
+
+	start := time.Now()
+	credential := clients.Credential{Secret: accessToken, APIKey: account.IsAPIKey()}
+	resp, err := s.claudeClient.ProxyRequest(
+		ctx, http.MethodPost, "/v1/messages", credential, s.body, account.BaseURL, account.ProxyURL, account.Headers,
+	)
+	if err != nil {
+		fields["error"] = err.Error()
+		s.logger.Withs(fields).Warn("Warmup request failed")
+		return
+	}
+	defer resp.Body.Close()
+
+	fields["status_code"] = resp.StatusCode
+	fields["upstream_request_id"] = resp.Header.Get(requestid.UpstreamHeader)
+	fields["duration_ms"] = time.Since(start).Milliseconds()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		s.logger.Withs(fields).Warn("Warmup request rejected by Claude API")
+		return
+	}
+
+	var result struct {
+		Usage struct {
+			CacheCreationInputTokens int `json:"cache_creation_input_tokens"`
+			CacheReadInputTokens     int `json:"cache_read_input_tokens"`
+		} `json:"usage"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&result); err == nil {
+		fields["cache_creation_tokens"] = result.Usage.CacheCreationInputTokens
+		fields["cache_read_tokens"] = result.Usage.CacheReadInputTokens
+	}
+	s.logger.Withs(fields).Info("Account prompt cache warmed")
+}
@@ -0,0 +1,89 @@
+package repositories
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"claude-proxy/modules/proxy/application/dto"
+	"claude-proxy/modules/proxy/domain/entities"
+	"claude-proxy/modules/proxy/domain/interfaces"
+	"claude-proxy/pkg/atomicfile"
+)
+
+// JSONBatchRepository implements BatchRepository using a JSON file in the data folder
+type JSONBatchRepository struct {
+	dataFolder string
+	fsync      bool         // Flush writes to disk before reporting success (storage.fsync)
+	mu         sync.RWMutex // Only for file I/O concurrency control
+}
+
+// NewJSONBatchRepository creates a new JSON batch repository (dataFolder must be expanded)
+func NewJSONBatchRepository(dataFolder string, fsync bool) (interfaces.BatchRepository, error) {
+	repo := &JSONBatchRepository{
+		dataFolder: dataFolder,
+		fsync:      fsync,
+	}
+
+	// Create data folder if it doesn't exist
+	if err := os.MkdirAll(repo.dataFolder, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create data folder: %w", err)
+	}
+
+	return repo, nil
+}
+
+// SaveAll stores every batch (atomic write)
+func (r *JSONBatchRepository) SaveAll(ctx context.Context, batches []*entities.Batch) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	batchesFile := filepath.Join(r.dataFolder, "batches.json")
+
+	dtos := make([]*dto.BatchPersistenceDTO, 0, len(batches))
+	for _, batch := range batches {
+		dtos = append(dtos, dto.ToBatchPersistenceDTO(batch))
+	}
+
+	data, err := json.MarshalIndent(dtos, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal batches: %w", err)
+	}
+
+	// Atomic write (temp file + rename), fsynced when storage.fsync is set
+	if err := atomicfile.Write(batchesFile, data, 0o600, r.fsync); err != nil {
+		return fmt.Errorf("failed to write batches file: %w", err)
+	}
+
+	return nil
+}
+
+// LoadAll returns the stored batches
+func (r *JSONBatchRepository) LoadAll(ctx context.Context) ([]*entities.Batch, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	batchesFile := filepath.Join(r.dataFolder, "batches.json")
+
+	data, err := os.ReadFile(batchesFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil // No batch created yet
+		}
+		return nil, fmt.Errorf("failed to read batches file: %w", err)
+	}
+
+	var dtos []*dto.BatchPersistenceDTO
+	if err := json.Unmarshal(data, &dtos); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal batches: %w", err)
+	}
+
+	batches := make([]*entities.Batch, 0, len(dtos))
+	for _, batchDTO := range dtos {
+		batches = append(batches, dto.FromBatchPersistenceDTO(batchDTO))
+	}
+	return batches, nil
+}
@@ -0,0 +1,89 @@
+package repositories
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"claude-proxy/modules/proxy/application/dto"
+	"claude-proxy/modules/proxy/domain/entities"
+	"claude-proxy/modules/proxy/domain/interfaces"
+	"claude-proxy/pkg/atomicfile"
+)
+
+// JSONFileRepository implements FileRepository (the Files API index) using files.json in the data folder
+type JSONFileRepository struct {
+	dataFolder string
+	fsync      bool         // Flush writes to disk before reporting success (storage.fsync)
+	mu         sync.RWMutex // Only for file I/O concurrency control
+}
+
+// NewJSONFileRepository creates a new Files API index repository (dataFolder must be expanded)
+func NewJSONFileRepository(dataFolder string, fsync bool) (interfaces.FileRepository, error) {
+	repo := &JSONFileRepository{
+		dataFolder: dataFolder,
+		fsync:      fsync,
+	}
+
+	// Create data folder if it doesn't exist
+	if err := os.MkdirAll(repo.dataFolder, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create data folder: %w", err)
+	}
+
+	return repo, nil
+}
+
+// SaveAll stores every tracked file (atomic write)
+func (r *JSONFileRepository) SaveAll(ctx context.Context, files []*entities.File) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	filesFile := filepath.Join(r.dataFolder, "files.json")
+
+	dtos := make([]*dto.FilePersistenceDTO, 0, len(files))
+	for _, file := range files {
+		dtos = append(dtos, dto.ToFilePersistenceDTO(file))
+	}
+
+	data, err := json.MarshalIndent(dtos, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal files: %w", err)
+	}
+
+	// Atomic write (temp file + rename), fsynced when storage.fsync is set
+	if err := atomicfile.Write(filesFile, data, 0o600, r.fsync); err != nil {
+		return fmt.Errorf("failed to write files file: %w", err)
+	}
+
+	return nil
+}
+
+// LoadAll returns the stored files
+func (r *JSONFileRepository) LoadAll(ctx context.Context) ([]*entities.File, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	filesFile := filepath.Join(r.dataFolder, "files.json")
+
+	data, err := os.ReadFile(filesFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil // No file uploaded yet
+		}
+		return nil, fmt.Errorf("failed to read files file: %w", err)
+	}
+
+	var dtos []*dto.FilePersistenceDTO
+	if err := json.Unmarshal(data, &dtos); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal files: %w", err)
+	}
+
+	files := make([]*entities.File, 0, len(dtos))
+	for _, fileDTO := range dtos {
+		files = append(files, dto.FromFilePersistenceDTO(fileDTO))
+	}
+	return files, nil
+}
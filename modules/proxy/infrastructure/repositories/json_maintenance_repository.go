@@ -0,0 +1,80 @@
+package repositories
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"claude-proxy/modules/proxy/application/dto"
+	"claude-proxy/modules/proxy/domain/entities"
+	"claude-proxy/modules/proxy/domain/interfaces"
+	"claude-proxy/pkg/atomicfile"
+)
+
+// JSONMaintenanceRepository implements MaintenanceRepository using a JSON file in the data folder
+type JSONMaintenanceRepository struct {
+	dataFolder string
+	fsync      bool         // Flush writes to disk before reporting success (storage.fsync)
+	mu         sync.RWMutex // Only for file I/O concurrency control
+}
+
+// NewJSONMaintenanceRepository creates a new JSON maintenance repository (dataFolder must be expanded)
+func NewJSONMaintenanceRepository(dataFolder string, fsync bool) (interfaces.MaintenanceRepository, error) {
+	repo := &JSONMaintenanceRepository{
+		dataFolder: dataFolder,
+		fsync:      fsync,
+	}
+
+	// Create data folder if it doesn't exist
+	if err := os.MkdirAll(repo.dataFolder, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create data folder: %w", err)
+	}
+
+	return repo, nil
+}
+
+// Save stores the maintenance state (atomic write)
+func (r *JSONMaintenanceRepository) Save(ctx context.Context, state *entities.MaintenanceState) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	maintenanceFile := filepath.Join(r.dataFolder, "maintenance.json")
+
+	data, err := json.MarshalIndent(dto.ToMaintenancePersistenceDTO(state), "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal maintenance state: %w", err)
+	}
+
+	// Atomic write (temp file + rename), fsynced when storage.fsync is set
+	if err := atomicfile.Write(maintenanceFile, data, 0o600, r.fsync); err != nil {
+		return fmt.Errorf("failed to write maintenance file: %w", err)
+	}
+
+	return nil
+}
+
+// Load returns the stored maintenance state
+func (r *JSONMaintenanceRepository) Load(ctx context.Context) (*entities.MaintenanceState, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	maintenanceFile := filepath.Join(r.dataFolder, "maintenance.json")
+
+	data, err := os.ReadFile(maintenanceFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil // Never toggled
+		}
+		return nil, fmt.Errorf("failed to read maintenance file: %w", err)
+	}
+
+	var stateDTO dto.MaintenancePersistenceDTO
+	if err := json.Unmarshal(data, &stateDTO); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal maintenance state: %w", err)
+	}
+
+	return dto.FromMaintenancePersistenceDTO(&stateDTO), nil
+}
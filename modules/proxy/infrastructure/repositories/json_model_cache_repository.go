@@ -0,0 +1,67 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"claude-proxy/modules/proxy/domain/interfaces"
+	"claude-proxy/pkg/atomicfile"
+)
+
+// JSONModelCacheRepository implements ModelCacheRepository using a JSON file in the data folder
+type JSONModelCacheRepository struct {
+	dataFolder string
+	fsync      bool         // Flush writes to disk before reporting success (storage.fsync)
+	mu         sync.RWMutex // Only for file I/O concurrency control
+}
+
+// NewJSONModelCacheRepository creates a new JSON model cache repository (dataFolder must be expanded)
+func NewJSONModelCacheRepository(dataFolder string, fsync bool) (interfaces.ModelCacheRepository, error) {
+	repo := &JSONModelCacheRepository{
+		dataFolder: dataFolder,
+		fsync:      fsync,
+	}
+
+	// Create data folder if it doesn't exist
+	if err := os.MkdirAll(repo.dataFolder, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create data folder: %w", err)
+	}
+
+	return repo, nil
+}
+
+// Save stores the models response body (atomic write)
+func (r *JSONModelCacheRepository) Save(ctx context.Context, body []byte) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	modelsFile := filepath.Join(r.dataFolder, "models.json")
+
+	// Atomic write (temp file + rename), fsynced when storage.fsync is set
+	if err := atomicfile.Write(modelsFile, body, 0o600, r.fsync); err != nil {
+		return fmt.Errorf("failed to write models cache file: %w", err)
+	}
+
+	return nil
+}
+
+// Load returns the cached models response body
+func (r *JSONModelCacheRepository) Load(ctx context.Context) ([]byte, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	modelsFile := filepath.Join(r.dataFolder, "models.json")
+
+	data, err := os.ReadFile(modelsFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil // Nothing cached yet
+		}
+		return nil, fmt.Errorf("failed to read models cache file: %w", err)
+	}
+
+	return data, nil
+}
@@ -0,0 +1,80 @@
+package repositories
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"claude-proxy/modules/proxy/application/dto"
+	"claude-proxy/modules/proxy/domain/entities"
+	"claude-proxy/modules/proxy/domain/interfaces"
+	"claude-proxy/pkg/atomicfile"
+)
+
+// JSONUpstreamUsageRepository implements UpstreamUsageRepository using a JSON file in the data folder
+type JSONUpstreamUsageRepository struct {
+	dataFolder string
+	fsync      bool         // Flush writes to disk before reporting success (storage.fsync)
+	mu         sync.RWMutex // Only for file I/O concurrency control
+}
+
+// NewJSONUpstreamUsageRepository creates a new JSON upstream usage repository (dataFolder must be expanded)
+func NewJSONUpstreamUsageRepository(dataFolder string, fsync bool) (interfaces.UpstreamUsageRepository, error) {
+	repo := &JSONUpstreamUsageRepository{
+		dataFolder: dataFolder,
+		fsync:      fsync,
+	}
+
+	// Create data folder if it doesn't exist
+	if err := os.MkdirAll(repo.dataFolder, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create data folder: %w", err)
+	}
+
+	return repo, nil
+}
+
+// Save stores the usage of every account (atomic write)
+func (r *JSONUpstreamUsageRepository) Save(ctx context.Context, usage []*entities.AccountUpstreamUsage) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	usageFile := filepath.Join(r.dataFolder, "upstream_usage.json")
+
+	data, err := json.MarshalIndent(dto.ToUpstreamUsagePersistenceDTO(usage), "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal upstream usage: %w", err)
+	}
+
+	// Atomic write (temp file + rename), fsynced when storage.fsync is set
+	if err := atomicfile.Write(usageFile, data, 0o600, r.fsync); err != nil {
+		return fmt.Errorf("failed to write upstream usage file: %w", err)
+	}
+
+	return nil
+}
+
+// Load returns the stored usage
+func (r *JSONUpstreamUsageRepository) Load(ctx context.Context) ([]*entities.AccountUpstreamUsage, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	usageFile := filepath.Join(r.dataFolder, "upstream_usage.json")
+
+	data, err := os.ReadFile(usageFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil // Never saved
+		}
+		return nil, fmt.Errorf("failed to read upstream usage file: %w", err)
+	}
+
+	var usageDTO dto.UpstreamUsagePersistenceDTO
+	if err := json.Unmarshal(data, &usageDTO); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal upstream usage: %w", err)
+	}
+
+	return dto.FromUpstreamUsagePersistenceDTO(&usageDTO), nil
+}
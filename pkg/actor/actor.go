@@ -0,0 +1,32 @@
+package actor
+
+import "context"
+
+const (
+	// Unknown is shown for changes made before actors were recorded, or outside any admin request
+	Unknown = "unknown"
+
+	// OAuth is the actor of accounts added through the public OAuth flow (the dashboard's "add account")
+	OAuth = "oauth"
+)
+
+type contextKey struct{}
+
+// NewContext returns a copy of ctx carrying the identity making changes (e.g. "admin_key:<id>", "token:<id>")
+func NewContext(ctx context.Context, identity string) context.Context {
+	return context.WithValue(ctx, contextKey{}, identity)
+}
+
+// FromContext returns the identity carried by ctx (empty if none)
+func FromContext(ctx context.Context) string {
+	identity, _ := ctx.Value(contextKey{}).(string)
+	return identity
+}
+
+// OrUnknown returns identity, or Unknown if it is empty
+func OrUnknown(identity string) string {
+	if identity == "" {
+		return Unknown
+	}
+	return identity
+}
@@ -0,0 +1,222 @@
+package atomicfile
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// BackupSuffix is appended to a file's path for the previous generation kept by WriteVerified
+const BackupSuffix = ".bak"
+
+// writeMu serializes every write in the process, so saves triggered together (sync job, admin changes,
+// shutdown) reach the disk one file at a time instead of as concurrent IO bursts
+var writeMu sync.Mutex
+
+// written remembers the last content written to each path, so unchanged data is not rewritten
+var written = map[string]writtenFile{}
+
+// fileSystem is the part of the os package writes go through, so tests can inject failures
+type fileSystem interface {
+	OpenFile(name string, flag int, perm os.FileMode) (file, error)
+	ReadFile(name string) ([]byte, error)
+	Link(oldname, newname string) error
+	Rename(oldpath, newpath string) error
+	Remove(name string) error
+}
+
+// file is the part of *os.File writeTemp uses
+type file interface {
+	Write(b []byte) (int, error)
+	Sync() error
+	Close() error
+}
+
+// osFS is the fileSystem of the os package
+type osFS struct{}
+
+func (osFS) OpenFile(name string, flag int, perm os.FileMode) (file, error) {
+	return os.OpenFile(name, flag, perm)
+}
+func (osFS) ReadFile(name string) ([]byte, error) { return os.ReadFile(name) }
+func (osFS) Link(oldname, newname string) error   { return os.Link(oldname, newname) }
+func (osFS) Rename(oldpath, newpath string) error { return os.Rename(oldpath, newpath) }
+func (osFS) Remove(name string) error             { return os.Remove(name) }
+
+// fsys is the fileSystem every write goes through
+var fsys fileSystem = osFS{}
+
+// writtenFile identifies the content and on-disk version of a file this process wrote
+type writtenFile struct {
+	sum     [sha256.Size]byte
+	size    int64
+	modTime time.Time
+}
+
+// Write replaces the file at path with data atomically: the data goes to a temporary file that is
+// renamed over the target, so readers never see a partial file
+// With fsync set, the temporary file is flushed before the rename and the directory after it,
+// so the new content survives a power loss once Write returns
+// The disk is not touched when the file still holds exactly the data last written to it
+func Write(path string, data []byte, perm os.FileMode, fsync bool) error {
+	return write(path, data, perm, fsync, nil, 0)
+}
+
+// WriteVerified writes like Write, guarding data files that hold a list of entries:
+// the temporary file is read back and must match data byte for byte and hold the expected number of entries
+// (counted by countEntries) before it replaces the target, and the content it replaces is kept as
+// path + BackupSuffix for one generation, unless countEntries can't read that content either
+func WriteVerified(
+	path string,
+	data []byte,
+	perm os.FileMode,
+	fsync bool,
+	countEntries func(data []byte) (int, error),
+	entries int,
+) error {
+	return write(path, data, perm, fsync, countEntries, entries)
+}
+
+// write implements Write, and WriteVerified when countEntries is set
+func write(
+	path string,
+	data []byte,
+	perm os.FileMode,
+	fsync bool,
+	countEntries func(data []byte) (int, error),
+	entries int,
+) error {
+	writeMu.Lock()
+	defer writeMu.Unlock()
+
+	sum := sha256.Sum256(data)
+	if last, ok := written[path]; ok && last.sum == sum {
+		if info, err := os.Stat(path); err == nil && info.Size() == last.size && info.ModTime().Equal(last.modTime) {
+			return nil
+		}
+	}
+
+	tmpFile := path + ".tmp"
+	if err := writeTemp(tmpFile, data, perm, fsync); err != nil {
+		fsys.Remove(tmpFile)
+		return err
+	}
+
+	if countEntries != nil {
+		if err := verifyTemp(tmpFile, data, countEntries, entries); err != nil {
+			fsys.Remove(tmpFile)
+			return err
+		}
+		if err := backup(path, perm, fsync, countEntries); err != nil {
+			fsys.Remove(tmpFile)
+			return err
+		}
+	}
+
+	if err := fsys.Rename(tmpFile, path); err != nil {
+		fsys.Remove(tmpFile)
+		return fmt.Errorf("failed to rename %s: %w", filepath.Base(path), err)
+	}
+
+	if fsync {
+		if err := syncDir(filepath.Dir(path)); err != nil {
+			return err
+		}
+	}
+
+	if info, err := os.Stat(path); err == nil {
+		written[path] = writtenFile{sum: sum, size: info.Size(), modTime: info.ModTime()}
+	}
+	return nil
+}
+
+// writeTemp writes data to a new temporary file, flushing it to disk when fsync is set
+func writeTemp(tmpFile string, data []byte, perm os.FileMode, fsync bool) error {
+	file, err := fsys.OpenFile(tmpFile, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Base(tmpFile), err)
+	}
+
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write %s: %w", filepath.Base(tmpFile), err)
+	}
+	if fsync {
+		if err := file.Sync(); err != nil {
+			file.Close()
+			return fmt.Errorf("failed to fsync %s: %w", filepath.Base(tmpFile), err)
+		}
+	}
+	return file.Close()
+}
+
+// verifyTemp reads a temporary file back and checks it holds data, with the expected number of entries
+func verifyTemp(tmpFile string, data []byte, countEntries func(data []byte) (int, error), entries int) error {
+	name := filepath.Base(tmpFile)
+	written, err := fsys.ReadFile(tmpFile)
+	if err != nil {
+		return fmt.Errorf("failed to read back %s: %w", name, err)
+	}
+	if len(written) != len(data) || !bytes.Equal(written, data) {
+		return fmt.Errorf("%s does not match the data written (%d of %d bytes)", name, len(written), len(data))
+	}
+
+	count, err := countEntries(written)
+	if err != nil {
+		return fmt.Errorf("%s does not parse back: %w", name, err)
+	}
+	if count != entries {
+		return fmt.Errorf("%s holds %d entries, expected %d", name, count, entries)
+	}
+	return nil
+}
+
+// backup keeps the current content of path as path + BackupSuffix, replacing the previous backup
+// A file countEntries can't read is not backed up, so a corrupt file never replaces a good backup.
+// The backup is a hard link, as the rename that follows gives path a new inode; it is a copy where the
+// filesystem has no hard links.
+func backup(path string, perm os.FileMode, fsync bool, countEntries func(data []byte) (int, error)) error {
+	current, err := fsys.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to read %s: %w", filepath.Base(path), err)
+	}
+	if _, err := countEntries(current); err != nil {
+		return nil
+	}
+
+	backupFile := path + BackupSuffix
+	tmpFile := backupFile + ".tmp"
+	fsys.Remove(tmpFile)
+	if err := fsys.Link(path, tmpFile); err != nil {
+		if err := writeTemp(tmpFile, current, perm, fsync); err != nil {
+			fsys.Remove(tmpFile)
+			return err
+		}
+	}
+	if err := fsys.Rename(tmpFile, backupFile); err != nil {
+		fsys.Remove(tmpFile)
+		return fmt.Errorf("failed to rename %s: %w", filepath.Base(backupFile), err)
+	}
+	return nil
+}
+
+// syncDir flushes a directory entry update (the rename) to disk
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return fmt.Errorf("failed to open %s for fsync: %w", dir, err)
+	}
+	defer d.Close()
+
+	if err := d.Sync(); err != nil {
+		return fmt.Errorf("failed to fsync %s: %w", dir, err)
+	}
+	return nil
+}
@@ -0,0 +1,236 @@
+package atomicfile
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+// faultyFS is the os file system with failures injected into writes
+type faultyFS struct {
+	osFS
+	writeLimit  int              // Bytes a file accepts before its writes fail, when positive
+	silentShort bool             // Writes past writeLimit are dropped without an error instead of failing with ENOSPC
+	syncErr     error            // Returned by every file Sync
+	linkErr     error            // Returned by every Link
+	renameErr   map[string]error // Returned by renames, by base name of the target
+}
+
+func (f *faultyFS) OpenFile(name string, flag int, perm os.FileMode) (file, error) {
+	osFile, err := f.osFS.OpenFile(name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	return &faultyFile{file: osFile, fs: f}, nil
+}
+
+func (f *faultyFS) Link(oldname, newname string) error {
+	if f.linkErr != nil {
+		return f.linkErr
+	}
+	return f.osFS.Link(oldname, newname)
+}
+
+func (f *faultyFS) Rename(oldpath, newpath string) error {
+	if err := f.renameErr[filepath.Base(newpath)]; err != nil {
+		return err
+	}
+	return f.osFS.Rename(oldpath, newpath)
+}
+
+// faultyFile is a file of a faultyFS
+type faultyFile struct {
+	file
+	fs      *faultyFS
+	written int
+}
+
+func (f *faultyFile) Write(b []byte) (int, error) {
+	limit := f.fs.writeLimit
+	if limit <= 0 || f.written+len(b) <= limit {
+		n, err := f.file.Write(b)
+		f.written += n
+		return n, err
+	}
+
+	n, err := f.file.Write(b[:limit-f.written])
+	f.written += n
+	if err != nil {
+		return n, err
+	}
+	if f.fs.silentShort {
+		return len(b), nil
+	}
+	return n, syscall.ENOSPC
+}
+
+func (f *faultyFile) Sync() error {
+	if f.fs.syncErr != nil {
+		return f.fs.syncErr
+	}
+	return f.file.Sync()
+}
+
+// useFS makes writes go through fs for the rest of the test
+func useFS(t *testing.T, fs fileSystem) {
+	t.Helper()
+
+	fsys = fs
+	t.Cleanup(func() { fsys = osFS{} })
+}
+
+// countArray counts the entries of a JSON array
+func countArray(data []byte) (int, error) {
+	var entries []json.RawMessage
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return 0, err
+	}
+	return len(entries), nil
+}
+
+// assertContent fails the test unless the file at path holds want
+func assertContent(t *testing.T, path, want string) {
+	t.Helper()
+
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read %s: %v", filepath.Base(path), err)
+	}
+	if string(got) != want {
+		t.Errorf("%s = %q, want %q", filepath.Base(path), got, want)
+	}
+}
+
+// assertNoTempFiles fails the test if a temporary file is left in dir
+func assertNoTempFiles(t *testing.T, dir string) {
+	t.Helper()
+
+	leftovers, err := filepath.Glob(filepath.Join(dir, "*.tmp"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(leftovers) > 0 {
+		t.Errorf("temporary files left behind: %v", leftovers)
+	}
+}
+
+func TestWriteFailuresKeepTarget(t *testing.T) {
+	const (
+		previous = `["a","b"]`
+		next     = `["a","b","c"]`
+	)
+	errIO := errors.New("injected IO error")
+
+	tests := []struct {
+		name     string
+		fs       *faultyFS
+		verified bool
+		wantErr  error // Error the failure must wrap, if any
+	}{
+		{"write fails mid-way", &faultyFS{writeLimit: 5}, false, syscall.ENOSPC},
+		{"verified write fails mid-way", &faultyFS{writeLimit: 5}, true, syscall.ENOSPC},
+		{"silent short write", &faultyFS{writeLimit: 5, silentShort: true}, true, nil},
+		{"fsync fails", &faultyFS{syncErr: errIO}, false, errIO},
+		{"rename fails", &faultyFS{renameErr: map[string]error{"data.json": errIO}}, false, errIO},
+		{"backup rename fails", &faultyFS{renameErr: map[string]error{"data.json.bak": errIO}}, true, errIO},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "data.json")
+			if err := Write(path, []byte(previous), 0o600, false); err != nil {
+				t.Fatal(err)
+			}
+
+			useFS(t, tt.fs)
+			var err error
+			if tt.verified {
+				err = WriteVerified(path, []byte(next), 0o600, true, countArray, 3)
+			} else {
+				err = Write(path, []byte(next), 0o600, true)
+			}
+			if err == nil {
+				t.Fatal("write succeeded, want an error")
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("error = %v, want it to wrap %v", err, tt.wantErr)
+			}
+			assertContent(t, path, previous)
+			assertNoTempFiles(t, dir)
+
+			// The failed attempt must not be remembered as written
+			useFS(t, osFS{})
+			if err := Write(path, []byte(next), 0o600, false); err != nil {
+				t.Fatalf("retry: %v", err)
+			}
+			assertContent(t, path, next)
+		})
+	}
+}
+
+func TestWriteVerifiedKeepsBackup(t *testing.T) {
+	tests := []struct {
+		name string
+		fs   fileSystem
+	}{
+		{"hard link", osFS{}},
+		{"copy without hard links", &faultyFS{linkErr: errors.New("links not supported")}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "data.json")
+			useFS(t, tt.fs)
+
+			if err := WriteVerified(path, []byte(`["a"]`), 0o600, false, countArray, 1); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := os.Stat(path + BackupSuffix); !os.IsNotExist(err) {
+				t.Errorf("backup of a new file exists (err = %v)", err)
+			}
+
+			if err := WriteVerified(path, []byte(`["a","b"]`), 0o600, true, countArray, 2); err != nil {
+				t.Fatal(err)
+			}
+			assertContent(t, path, `["a","b"]`)
+			assertContent(t, path+BackupSuffix, `["a"]`)
+			assertNoTempFiles(t, dir)
+		})
+	}
+}
+
+func TestWriteVerifiedDoesNotBackUpCorruptFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "data.json")
+	if err := os.WriteFile(path+BackupSuffix, []byte(`["good"]`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(`["trunc`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := WriteVerified(path, []byte(`["new"]`), 0o600, false, countArray, 1); err != nil {
+		t.Fatal(err)
+	}
+	assertContent(t, path, `["new"]`)
+	assertContent(t, path+BackupSuffix, `["good"]`)
+}
+
+func TestWriteVerifiedRejectsEntryCountMismatch(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "data.json")
+	if err := Write(path, []byte(`["a"]`), 0o600, false); err != nil {
+		t.Fatal(err)
+	}
+
+	err := WriteVerified(path, []byte(`["a","b"]`), 0o600, false, countArray, 3)
+	if err == nil || !strings.Contains(err.Error(), "holds 2 entries, expected 3") {
+		t.Errorf("error = %v, want an entry count mismatch", err)
+	}
+	assertContent(t, path, `["a"]`)
+	assertNoTempFiles(t, dir)
+}
@@ -145,6 +145,15 @@ func NewRequestTimeoutError(details string) AppError {
 	}
 }
 
+func NewGatewayTimeoutError(details string) AppError {
+	return &BaseAppError{
+		Code:       "GATEWAY_TIMEOUT",
+		Msg:        "Upstream request timed out",
+		Detail:     details,
+		HttpStatus: http.StatusGatewayTimeout,
+	}
+}
+
 func NewRateLimitError(message string, details map[string]interface{}) AppError {
 	detailStr := ""
 	for k, v := range details {
@@ -157,3 +166,39 @@ func NewRateLimitError(message string, details map[string]interface{}) AppError
 		HttpStatus: http.StatusTooManyRequests,
 	}
 }
+
+// NewProxyError creates an error for a failed proxy attempt with an explicit HTTP status
+// Used for classified upstream failures (502 bad gateway, 503 unavailable, 504 timeout)
+func NewProxyError(status int, code, message, details string) AppError {
+	return &BaseAppError{
+		Code:       code,
+		Msg:        message,
+		Detail:     details,
+		HttpStatus: status,
+	}
+}
+
+// headersError is an AppError with response headers attached
+type headersError struct {
+	AppError
+	headers http.Header
+}
+
+// Headers returns the response headers attached to the error
+func (e *headersError) Headers() http.Header {
+	return e.headers
+}
+
+// WithHeaders attaches response headers (Retry-After, rate limit headers) to err, which keeps its status,
+// code and message; the headers are written with the error response
+func WithHeaders(err AppError, headers http.Header) AppError {
+	return &headersError{AppError: err, headers: headers}
+}
+
+// HeadersOf returns the response headers attached to err (nil if none)
+func HeadersOf(err AppError) http.Header {
+	if carrier, ok := err.(interface{ Headers() http.Header }); ok {
+		return carrier.Headers()
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+// Package filelock provides advisory cross-process locks on data files, so the server and CLI
+// commands working on the same data folder never interleave their read-modify-write cycles
+package filelock
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// Lock is an exclusive lock held on a data file
+type Lock struct {
+	file *os.File // Nil when the folder is read-only and the lock file doesn't exist (nothing to lock)
+}
+
+// Acquire blocks until this process holds the exclusive lock of the data file at path
+// The lock is taken on a sibling "<path>.lock" file, as the data file itself is replaced by renames.
+// In a read-only folder an existing lock file is locked read-only, and a missing one is not needed: no writer
+// can replace the data file there, so reading it needs no lock.
+func Acquire(path string) (*Lock, error) {
+	file, err := os.OpenFile(path+".lock", os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil && (errors.Is(err, syscall.EROFS) || errors.Is(err, os.ErrPermission)) {
+		file, err = os.Open(path + ".lock")
+		if errors.Is(err, os.ErrNotExist) {
+			return &Lock{}, nil
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file of %s: %w", filepath.Base(path), err)
+	}
+
+	if err := lockFile(file); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to lock %s: %w", filepath.Base(path), err)
+	}
+	return &Lock{file: file}, nil
+}
+
+// Release releases the lock; the lock file is kept for the next writer
+func (l *Lock) Release() error {
+	if l.file == nil {
+		return nil
+	}
+	defer l.file.Close()
+	return unlockFile(l.file)
+}
@@ -0,0 +1,15 @@
+//go:build !unix && !windows
+
+package filelock
+
+import "os"
+
+// lockFile is a no-op on platforms without file locking; the in-process mutexes still apply
+func lockFile(file *os.File) error {
+	return nil
+}
+
+// unlockFile is a no-op on platforms without file locking
+func unlockFile(file *os.File) error {
+	return nil
+}
@@ -0,0 +1,85 @@
+package health
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// checkTimeout bounds each readiness check, so one hung dependency can't hang the probe
+const checkTimeout = 5 * time.Second
+
+// Check verifies one dependency, returning an error describing the problem when it is not ready
+type Check func(ctx context.Context) error
+
+// Result is the outcome of one readiness check
+type Result struct {
+	Name       string `json:"name"`
+	Status     string `json:"status"` // "ok" or "failed"
+	Error      string `json:"error,omitempty"`
+	DurationMs int64  `json:"duration_ms"`
+}
+
+// Checker holds the readiness checks registered by the modules
+type Checker struct {
+	mu     sync.RWMutex
+	names  []string // Registration order
+	checks map[string]Check
+}
+
+// NewChecker creates a checker without checks (always ready)
+func NewChecker() *Checker {
+	return &Checker{checks: make(map[string]Check)}
+}
+
+// Register adds a readiness check; registering a name again replaces its check
+func (c *Checker) Register(name string, check Check) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, exists := c.checks[name]; !exists {
+		c.names = append(c.names, name)
+	}
+	c.checks[name] = check
+}
+
+// Run executes every check concurrently and returns their results in registration order
+// The instance is ready when every check passed.
+func (c *Checker) Run(ctx context.Context) ([]Result, bool) {
+	c.mu.RLock()
+	names := append([]string{}, c.names...)
+	checks := make([]Check, len(names))
+	for i, name := range names {
+		checks[i] = c.checks[name]
+	}
+	c.mu.RUnlock()
+
+	results := make([]Result, len(names))
+	var wg sync.WaitGroup
+	for i := range names {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			checkCtx, cancel := context.WithTimeout(ctx, checkTimeout)
+			defer cancel()
+
+			start := time.Now()
+			err := checks[i](checkCtx)
+			results[i] = Result{Name: names[i], Status: "ok", DurationMs: time.Since(start).Milliseconds()}
+			if err != nil {
+				results[i].Status = "failed"
+				results[i].Error = err.Error()
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	ready := true
+	for _, result := range results {
+		if result.Status != "ok" {
+			ready = false
+		}
+	}
+	return results, ready
+}
@@ -0,0 +1,143 @@
+package httpproxy
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/textproto"
+	"slices"
+	"strings"
+)
+
+// Pseudonym identifies the proxy in Via headers
+const Pseudonym = "claude-proxy"
+
+// hopByHopHeaders apply to a single connection and must not be forwarded by proxies (RFC 7230 section 6.1)
+var hopByHopHeaders = []string{
+	"Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Proxy-Connection", // Non-standard, still sent by some clients
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// RemoveHopByHop deletes the hop-by-hop headers from h, including those listed in its Connection header
+func RemoveHopByHop(h http.Header) {
+	for _, value := range h.Values("Connection") {
+		for _, name := range strings.Split(value, ",") {
+			if name = textproto.TrimString(name); name != "" {
+				h.Del(name)
+			}
+		}
+	}
+	for _, name := range hopByHopHeaders {
+		h.Del(name)
+	}
+}
+
+// ViaEntry returns this proxy's Via entry for a message received over HTTP/major.minor
+func ViaEntry(major, minor int) string {
+	return fmt.Sprintf("%d.%d %s", major, minor, Pseudonym)
+}
+
+// AppendVia adds this proxy to the Via header of h for a message received over HTTP/major.minor
+func AppendVia(h http.Header, major, minor int) {
+	h.Add("Via", ViaEntry(major, minor))
+}
+
+// ForwardedFor returns the X-Forwarded-For value for forwarding r: the chain r already carries, followed
+// by the address of its direct peer (empty when neither is known)
+func ForwardedFor(r *http.Request) string {
+	clientIP, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		clientIP = r.RemoteAddr
+	}
+	chain := r.Header.Values("X-Forwarded-For")
+	if clientIP != "" && clientIP != "@" { // Unix socket peers have no address
+		chain = append(chain, clientIP)
+	}
+	return strings.Join(chain, ", ")
+}
+
+// BodyAllowed returns true if a response to method with status may carry a body (RFC 7230 section 3.3.3)
+func BodyAllowed(method string, status int) bool {
+	if method == http.MethodHead {
+		return false
+	}
+	return status != http.StatusNoContent && status != http.StatusNotModified && (status < 100 || status >= 200)
+}
+
+// reservedStaticHeaders are managed per request by the proxy and can't be set as static upstream headers
+var reservedStaticHeaders = []string{"Authorization", "Host", "Content-Length", "Content-Type", "Accept-Encoding"}
+
+// ValidateStaticHeaders checks headers configured to be sent on every upstream request: names must be
+// valid HTTP tokens other than hop-by-hop or proxy-managed headers, and values must be single-line
+func ValidateStaticHeaders(headers map[string]string) error {
+	for name, value := range headers {
+		if !isToken(name) {
+			return fmt.Errorf("invalid header name %q", name)
+		}
+		canonical := textproto.CanonicalMIMEHeaderKey(name)
+		if slices.Contains(reservedStaticHeaders, canonical) || slices.Contains(hopByHopHeaders, canonical) {
+			return fmt.Errorf("header %q can't be overridden", name)
+		}
+		if strings.ContainsAny(value, "\r\n\x00") {
+			return fmt.Errorf("invalid value for header %q: must be a single line", name)
+		}
+	}
+	return nil
+}
+
+// WithoutManagedHeaders returns a copy of headers without the hop-by-hop and proxy-managed headers
+// (Authorization included), i.e. the ones ValidateStaticHeaders would reject
+func WithoutManagedHeaders(headers map[string]string) map[string]string {
+	result := make(map[string]string, len(headers))
+	for name, value := range headers {
+		canonical := textproto.CanonicalMIMEHeaderKey(name)
+		if slices.Contains(reservedStaticHeaders, canonical) || slices.Contains(hopByHopHeaders, canonical) {
+			continue
+		}
+		result[canonical] = value
+	}
+	return result
+}
+
+// WithoutFramingHeaders returns a copy of headers without Content-Length and the hop-by-hop headers
+// (Transfer-Encoding included): the HTTP client frames the body it sends itself, and a length copied
+// from elsewhere no longer matches once the body has been rewritten
+func WithoutFramingHeaders(headers map[string]string) map[string]string {
+	result := make(map[string]string, len(headers))
+	for name, value := range headers {
+		canonical := textproto.CanonicalMIMEHeaderKey(name)
+		if canonical == "Content-Length" || slices.Contains(hopByHopHeaders, canonical) {
+			continue
+		}
+		result[name] = value
+	}
+	return result
+}
+
+// ValidateHeaderName checks that name is a valid HTTP header name
+func ValidateHeaderName(name string) error {
+	if !isToken(name) {
+		return fmt.Errorf("invalid header name %q", name)
+	}
+	return nil
+}
+
+// isToken returns true if s is a non-empty RFC 7230 token (the syntax of header names)
+func isToken(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r > 0x7e || r <= ' ' || strings.ContainsRune(`"(),/:;<=>?@[\]{}`, r) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,25 @@
+package httpproxy
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RateLimitHeaders returns the headers of a rate limit response generated by the proxy itself, mirroring
+// those Claude API sends: Retry-After in whole seconds (at least 1) and, when limit is positive, the
+// anthropic-ratelimit-requests-* headers with nothing remaining until resetAt
+func RateLimitHeaders(limit int, resetAt, now time.Time) http.Header {
+	retryAfter := max(int(math.Ceil(resetAt.Sub(now).Seconds())), 1)
+	resetAt = now.Add(time.Duration(retryAfter) * time.Second)
+
+	h := http.Header{}
+	h.Set("Retry-After", strconv.Itoa(retryAfter))
+	if limit > 0 {
+		h.Set("Anthropic-Ratelimit-Requests-Limit", strconv.Itoa(limit))
+		h.Set("Anthropic-Ratelimit-Requests-Remaining", "0")
+	}
+	h.Set("Anthropic-Ratelimit-Requests-Reset", resetAt.UTC().Format(time.RFC3339))
+	return h
+}
@@ -0,0 +1,149 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"runtime"
+	"strings"
+
+	sctx "github.com/phathdt/service-context"
+)
+
+// componentLogger is an sctx.Logger whose level is looked up in the registry for its component
+// Messages are written to the underlying slog logger, whose handler must accept every level
+type componentLogger struct {
+	slog      *slog.Logger
+	format    string
+	component string // Value of the last "component" field ("" for the default level)
+	registry  *Registry
+}
+
+// log writes a message if the component's level allows it; debug messages get the caller's source
+func (l *componentLogger) log(level sctx.CustomLevel, msg func() string) {
+	if !l.registry.enabled(l.component, level) {
+		return
+	}
+
+	logger := l.slog
+	if level == sctx.LevelDebug {
+		// Skip log and the Debug* method to reach the caller
+		if _, file, line, ok := runtime.Caller(2); ok {
+			logger = logger.With("source", fmt.Sprintf("%s:%d", file[strings.LastIndex(file, "/")+1:], line))
+		}
+	}
+	logger.Log(context.Background(), level.Level(), msg())
+}
+
+// sprint returns a message builder for fmt.Sprint arguments, evaluated only when the message is written
+func sprint(args []any) func() string {
+	return func() string { return fmt.Sprint(args...) }
+}
+
+// sprintf returns a message builder for fmt.Sprintf arguments, evaluated only when the message is written
+func sprintf(format string, args []any) func() string {
+	return func() string { return fmt.Sprintf(format, args...) }
+}
+
+func (l *componentLogger) Debug(args ...any) { l.log(sctx.LevelDebug, sprint(args)) }
+func (l *componentLogger) Info(args ...any)  { l.log(sctx.LevelInfo, sprint(args)) }
+func (l *componentLogger) Warn(args ...any)  { l.log(sctx.LevelWarn, sprint(args)) }
+func (l *componentLogger) Error(args ...any) { l.log(sctx.LevelError, sprint(args)) }
+func (l *componentLogger) Trace(args ...any) { l.log(sctx.LevelTrace, sprint(args)) }
+
+func (l *componentLogger) Fatal(args ...any) {
+	l.log(sctx.LevelFatal, sprint(args))
+	os.Exit(1)
+}
+
+func (l *componentLogger) Panic(args ...any) {
+	msg := fmt.Sprint(args...)
+	l.log(sctx.LevelPanic, func() string { return msg })
+	panic(msg)
+}
+
+func (l *componentLogger) Debugf(format string, args ...any) {
+	l.log(sctx.LevelDebug, sprintf(format, args))
+}
+func (l *componentLogger) Infof(format string, args ...any) {
+	l.log(sctx.LevelInfo, sprintf(format, args))
+}
+func (l *componentLogger) Warnf(format string, args ...any) {
+	l.log(sctx.LevelWarn, sprintf(format, args))
+}
+func (l *componentLogger) Errorf(format string, args ...any) {
+	l.log(sctx.LevelError, sprintf(format, args))
+}
+func (l *componentLogger) Tracef(format string, args ...any) {
+	l.log(sctx.LevelTrace, sprintf(format, args))
+}
+
+func (l *componentLogger) Fatalf(format string, args ...any) {
+	l.log(sctx.LevelFatal, sprintf(format, args))
+	os.Exit(1)
+}
+
+func (l *componentLogger) Panicf(format string, args ...any) {
+	msg := fmt.Sprintf(format, args...)
+	l.log(sctx.LevelPanic, func() string { return msg })
+	panic(msg)
+}
+
+func (l *componentLogger) Debugln(args ...any) { l.log(sctx.LevelDebug, sprint(args)) }
+func (l *componentLogger) Infoln(args ...any)  { l.Info(args...) }
+func (l *componentLogger) Warnln(args ...any)  { l.Warn(args...) }
+func (l *componentLogger) Errorln(args ...any) { l.Error(args...) }
+func (l *componentLogger) Fatalln(args ...any) { l.Fatal(args...) }
+func (l *componentLogger) Panicln(args ...any) { l.Panic(args...) }
+func (l *componentLogger) Traceln(args ...any) { l.Trace(args...) }
+
+// With returns a logger with an extra field; a "component" field makes the logger addressable by that name
+func (l *componentLogger) With(key string, value any) sctx.Logger {
+	return l.Withs(sctx.Fields{key: value})
+}
+
+// Withs returns a logger with extra fields; a "component" field makes the logger addressable by that name
+func (l *componentLogger) Withs(fields sctx.Fields) sctx.Logger {
+	attrs := make([]any, 0, len(fields)*2)
+	for k, v := range fields {
+		attrs = append(attrs, k, v)
+	}
+
+	child := &componentLogger{
+		slog:      l.slog.With(attrs...),
+		format:    l.format,
+		component: l.component,
+		registry:  l.registry,
+	}
+	if component, ok := fields["component"].(string); ok && component != "" {
+		child.component = component
+		l.registry.register(component)
+	}
+	return child
+}
+
+// WithSrc returns a logger that records the caller's source location
+func (l *componentLogger) WithSrc() sctx.Logger {
+	child := *l
+	if _, file, line, ok := runtime.Caller(1); ok {
+		child.slog = l.slog.With("source", fmt.Sprintf("%s:%d", file[strings.LastIndex(file, "/")+1:], line))
+	}
+	return &child
+}
+
+// GetLevel returns the current effective level of the logger's component
+func (l *componentLogger) GetLevel() string {
+	l.registry.mu.RLock()
+	defer l.registry.mu.RUnlock()
+	return l.registry.levelOf(l.component).String()
+}
+
+func (l *componentLogger) GetFormat() string {
+	return l.format
+}
+
+// GetSLogger returns the underlying slog logger (not filtered by the registry)
+func (l *componentLogger) GetSLogger() *slog.Logger {
+	return l.slog
+}
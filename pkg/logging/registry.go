@@ -0,0 +1,224 @@
+package logging
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	sctx "github.com/phathdt/service-context"
+)
+
+// DefaultComponent addresses the level of every logger without a component override
+const DefaultComponent = "default"
+
+// Registry holds the runtime log levels: the default level and per-component overrides
+// Loggers created by Wrap look their level up on every call, so changes apply immediately
+type Registry struct {
+	mu         sync.RWMutex
+	configured sctx.CustomLevel     // logger.level from the config file
+	overrides  map[string]*override // Component (or DefaultComponent) -> override
+	components map[string]bool      // Components seen so far (via a "component" logger field)
+	logger     sctx.Logger
+}
+
+// override is a level set at runtime, optionally reverting after a TTL
+type override struct {
+	level     sctx.CustomLevel
+	expiresAt time.Time   // Zero when permanent
+	timer     *time.Timer // Reverts the override at expiresAt
+}
+
+// LevelStatus describes the level of one component
+type LevelStatus struct {
+	Component string     `json:"component"`
+	Level     string     `json:"level"`                // Effective level
+	Override  bool       `json:"override"`             // Set at runtime rather than inherited
+	ExpiresAt *time.Time `json:"expires_at,omitempty"` // When the override reverts
+}
+
+// NewRegistry creates a registry whose default level is the configured one
+func NewRegistry(level string) (*Registry, error) {
+	configured, err := ParseLevel(level)
+	if err != nil {
+		return nil, err
+	}
+	return &Registry{
+		configured: configured,
+		overrides:  make(map[string]*override),
+		components: make(map[string]bool),
+	}, nil
+}
+
+// Wrap returns a logger that writes through base (which must log at every level) and filters by the
+// registry's level for its component; the component is taken from a "component" field added later
+func (r *Registry) Wrap(base sctx.Logger) sctx.Logger {
+	logger := &componentLogger{slog: base.GetSLogger(), format: base.GetFormat(), registry: r}
+
+	r.mu.Lock()
+	if r.logger == nil {
+		r.logger = logger
+	}
+	r.mu.Unlock()
+	return logger
+}
+
+// SetLevel overrides the level of a component (DefaultComponent for the default level)
+// A positive ttl reverts the override after that long
+func (r *Registry) SetLevel(component, level string, ttl time.Duration) error {
+	parsed, err := ParseLevel(level)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	if err := r.checkComponent(component); err != nil {
+		r.mu.Unlock()
+		return err
+	}
+	r.stopOverride(component)
+
+	o := &override{level: parsed}
+	if ttl > 0 {
+		o.expiresAt = time.Now().Add(ttl)
+		o.timer = time.AfterFunc(ttl, func() { r.expire(component, o) })
+	}
+	r.overrides[component] = o
+	r.mu.Unlock()
+
+	// Not "level": the log handlers reserve that key for the record level
+	fields := sctx.Fields{"target_component": component, "log_level": parsed.String()}
+	if ttl > 0 {
+		fields["ttl"] = ttl.String()
+	}
+	r.logger.Withs(fields).Info("Log level changed")
+	return nil
+}
+
+// ResetLevel removes the override of a component, restoring the inherited level
+func (r *Registry) ResetLevel(component string) error {
+	r.mu.Lock()
+	if err := r.checkComponent(component); err != nil {
+		r.mu.Unlock()
+		return err
+	}
+	r.stopOverride(component)
+	r.mu.Unlock()
+
+	r.logger.Withs(sctx.Fields{"target_component": component}).Info("Log level reset")
+	return nil
+}
+
+// Levels returns the default level followed by each known component, sorted by name
+func (r *Registry) Levels() []LevelStatus {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	names := make([]string, 0, len(r.components))
+	for name := range r.components {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	levels := []LevelStatus{r.status(DefaultComponent)}
+	for _, name := range names {
+		levels = append(levels, r.status(name))
+	}
+	return levels
+}
+
+// enabled returns true if a message at level should be written for component
+func (r *Registry) enabled(component string, level sctx.CustomLevel) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return level >= r.levelOf(component)
+}
+
+// register records a component so its level can be listed and changed
+func (r *Registry) register(component string) {
+	r.mu.RLock()
+	known := r.components[component]
+	r.mu.RUnlock()
+	if known {
+		return
+	}
+
+	r.mu.Lock()
+	r.components[component] = true
+	r.mu.Unlock()
+}
+
+// levelOf returns the effective level of a component (caller must hold mu)
+func (r *Registry) levelOf(component string) sctx.CustomLevel {
+	if o, ok := r.overrides[component]; ok {
+		return o.level
+	}
+	if o, ok := r.overrides[DefaultComponent]; ok {
+		return o.level
+	}
+	return r.configured
+}
+
+// status describes the level of a component (caller must hold mu)
+func (r *Registry) status(component string) LevelStatus {
+	status := LevelStatus{Component: component, Level: r.levelOf(component).String()}
+	if o, ok := r.overrides[component]; ok {
+		status.Override = true
+		if !o.expiresAt.IsZero() {
+			expiresAt := o.expiresAt
+			status.ExpiresAt = &expiresAt
+		}
+	}
+	return status
+}
+
+// checkComponent rejects unknown component names, so typos don't silently do nothing (caller must hold mu)
+func (r *Registry) checkComponent(component string) error {
+	if component == DefaultComponent || r.components[component] {
+		return nil
+	}
+	return fmt.Errorf("unknown log component %q", component)
+}
+
+// stopOverride removes a component's override and cancels its revert timer (caller must hold mu)
+func (r *Registry) stopOverride(component string) {
+	if o, ok := r.overrides[component]; ok {
+		if o.timer != nil {
+			o.timer.Stop()
+		}
+		delete(r.overrides, component)
+	}
+}
+
+// expire reverts an override when its TTL ends, unless it was replaced in the meantime
+func (r *Registry) expire(component string, o *override) {
+	r.mu.Lock()
+	current, ok := r.overrides[component]
+	if !ok || current != o {
+		r.mu.Unlock()
+		return
+	}
+	delete(r.overrides, component)
+	r.mu.Unlock()
+
+	r.logger.Withs(sctx.Fields{"target_component": component}).Info("Log level override expired")
+}
+
+// ParseLevel parses a level name (trace, debug, info, warn, error)
+func ParseLevel(level string) (sctx.CustomLevel, error) {
+	switch strings.ToLower(level) {
+	case "trace":
+		return sctx.LevelTrace, nil
+	case "debug":
+		return sctx.LevelDebug, nil
+	case "info":
+		return sctx.LevelInfo, nil
+	case "warn":
+		return sctx.LevelWarn, nil
+	case "error":
+		return sctx.LevelError, nil
+	default:
+		return 0, fmt.Errorf("invalid log level %q: expected trace, debug, info, warn or error", level)
+	}
+}
@@ -0,0 +1,73 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AnthropicErrorFormatKey marks a request whose errors must use the Anthropic error envelope
+const AnthropicErrorFormatKey = "anthropic_error_format"
+
+// AnthropicErrorFormat marks every request in the route group as Anthropic-compatible,
+// so recovered panics are rendered with the Anthropic error envelope instead of the admin format
+func AnthropicErrorFormat() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(AnthropicErrorFormatKey, true)
+		c.Next()
+	}
+}
+
+// UsesAnthropicErrorFormat returns true if the request belongs to an Anthropic-compatible route group
+func UsesAnthropicErrorFormat(c *gin.Context) bool {
+	return c.GetBool(AnthropicErrorFormatKey)
+}
+
+// AnthropicErrorType maps an HTTP status code to the matching Anthropic error type
+func AnthropicErrorType(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return "invalid_request_error"
+	case http.StatusUnauthorized:
+		return "authentication_error"
+	case http.StatusForbidden:
+		return "permission_error"
+	case http.StatusNotFound:
+		return "not_found_error"
+	case http.StatusRequestEntityTooLarge:
+		return "request_too_large"
+	case http.StatusTooManyRequests:
+		return "rate_limit_error"
+	case http.StatusGatewayTimeout:
+		return "timeout_error"
+	case http.StatusServiceUnavailable, 529:
+		return "overloaded_error"
+	default:
+		return "api_error"
+	}
+}
+
+// AbortWithAnthropicError aborts the request with an Anthropic-compatible error body:
+// {"type":"error","error":{"type":"...","message":"..."}}
+func AbortWithAnthropicError(c *gin.Context, status int, message string) {
+	c.AbortWithStatusJSON(status, gin.H{
+		"type": "error",
+		"error": gin.H{
+			"type":    AnthropicErrorType(status),
+			"message": message,
+		},
+	})
+}
+
+// AbortWithAnthropicErrorCode aborts like AbortWithAnthropicError and adds the proxy's
+// machine-readable error code: {"type":"error","error":{"type":"...","code":"...","message":"..."}}
+func AbortWithAnthropicErrorCode(c *gin.Context, status int, code, message string) {
+	c.AbortWithStatusJSON(status, gin.H{
+		"type": "error",
+		"error": gin.H{
+			"type":    AnthropicErrorType(status),
+			"code":    code,
+			"message": message,
+		},
+	})
+}
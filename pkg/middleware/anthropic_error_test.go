@@ -0,0 +1,103 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// assertJSONBody fails unless body is exactly the JSON want: the same fields, none missing and none added
+func assertJSONBody(t *testing.T, body []byte, want string) {
+	t.Helper()
+
+	var got, expected interface{}
+	if err := json.Unmarshal(body, &got); err != nil {
+		t.Fatalf("body %q is not JSON: %v", body, err)
+	}
+	if err := json.Unmarshal([]byte(want), &expected); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("body = %s, want %s", body, want)
+	}
+}
+
+func TestAnthropicErrorType(t *testing.T) {
+	tests := []struct {
+		status int
+		want   string
+	}{
+		{http.StatusBadRequest, "invalid_request_error"},
+		{http.StatusUnauthorized, "authentication_error"},
+		{http.StatusForbidden, "permission_error"},
+		{http.StatusNotFound, "not_found_error"},
+		{http.StatusRequestEntityTooLarge, "request_too_large"},
+		{http.StatusTooManyRequests, "rate_limit_error"},
+		{http.StatusGatewayTimeout, "timeout_error"},
+		{http.StatusServiceUnavailable, "overloaded_error"},
+		{529, "overloaded_error"},
+		{http.StatusInternalServerError, "api_error"},
+		{http.StatusBadGateway, "api_error"},
+		{http.StatusConflict, "api_error"},
+	}
+	for _, tt := range tests {
+		if got := AnthropicErrorType(tt.status); got != tt.want {
+			t.Errorf("AnthropicErrorType(%d) = %q, want %q", tt.status, got, tt.want)
+		}
+	}
+}
+
+func TestAbortWithAnthropicError(t *testing.T) {
+	tests := []struct {
+		name     string
+		abort    func(c *gin.Context)
+		status   int
+		wantBody string
+	}{
+		{
+			name:     "without code",
+			abort:    func(c *gin.Context) { AbortWithAnthropicError(c, http.StatusNotFound, "model not found") },
+			status:   http.StatusNotFound,
+			wantBody: `{"type":"error","error":{"type":"not_found_error","message":"model not found"}}`,
+		},
+		{
+			name: "with code",
+			abort: func(c *gin.Context) {
+				AbortWithAnthropicErrorCode(c, http.StatusServiceUnavailable, ErrCodeMaintenance, "back soon")
+			},
+			status: http.StatusServiceUnavailable,
+			wantBody: `{"type":"error","error":{"type":"overloaded_error","code":"` + ErrCodeMaintenance +
+				`","message":"back soon"}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			reached := false
+			engine := gin.New()
+			engine.GET("/v1/models", AnthropicErrorFormat(), func(c *gin.Context) {
+				if !UsesAnthropicErrorFormat(c) {
+					t.Error("route group not marked as Anthropic-compatible")
+				}
+				tt.abort(c)
+			}, func(c *gin.Context) { reached = true })
+
+			w := httptest.NewRecorder()
+			engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/models", nil))
+			if w.Code != tt.status {
+				t.Errorf("status = %d, want %d", w.Code, tt.status)
+			}
+			if got := w.Header().Get("Content-Type"); got != "application/json; charset=utf-8" {
+				t.Errorf("Content-Type = %q, want JSON", got)
+			}
+			assertJSONBody(t, w.Body.Bytes(), tt.wantBody)
+			if reached {
+				t.Error("the handler chain went on after the abort")
+			}
+		})
+	}
+}
@@ -1,11 +1,14 @@
 package middleware
 
 import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"fmt"
 	"net/http"
 	"strings"
 
 	"claude-proxy/modules/auth/domain/interfaces"
-	"claude-proxy/pkg/errors"
+	"claude-proxy/pkg/actor"
 
 	"github.com/gin-gonic/gin"
 	sctx "github.com/phathdt/service-context"
@@ -44,19 +47,249 @@ func APIKeyAuth(apiKey string) gin.HandlerFunc {
 	}
 }
 
+// AdminIdentityContextKey is the gin context key holding the identity that passed AdminAuth
+const AdminIdentityContextKey = "admin_identity"
+
+// setAdminIdentity records the identity that passed AdminAuth in the gin context, and in the request context
+// as the actor of the changes the request makes
+func setAdminIdentity(c *gin.Context, identity string) {
+	c.Set(AdminIdentityContextKey, identity)
+	c.Request = c.Request.WithContext(actor.NewContext(c.Request.Context(), identity))
+}
+
+// ViewerContextKey is the gin context key set to true when a viewer-role token passed AdminAuth
+// Handlers reachable by viewers mask account names and organization UUIDs when it is set
+const ViewerContextKey = "viewer"
+
+// IsViewer returns true if the request was authenticated with a viewer-role token
+func IsViewer(c *gin.Context) bool {
+	return c.GetBool(ViewerContextKey)
+}
+
+// AccessLevelContextKey is the gin context key holding the AccessLevel granted by AdminAuth
+const AccessLevelContextKey = "access_level"
+
+// Access levels granted by AdminAuth
+const (
+	AccessLevelFull = "full" // Admin API keys and admin-role tokens
+	AccessLevelRead = "read" // The read-only API key and viewer-role tokens
+)
+
+// ReadOnlyKeyIdentity is the identity of requests authenticated with auth.readonly_api_key
+const ReadOnlyKeyIdentity = "readonly_api_key"
+
+// ErrCodeReadOnlyKey is the error code answering the read-only API key on routes that need full access
+const ErrCodeReadOnlyKey = "READ_ONLY_API_KEY"
+
+// AccessLevel returns the access level granted to the request by AdminAuth (empty if it didn't pass it)
+func AccessLevel(c *gin.Context) string {
+	return c.GetString(AccessLevelContextKey)
+}
+
+// AdminAuth creates middleware for admin API authentication
+// Accepts either an admin API key (managed keys, or the config key until the first rotation)
+// or an active admin-role token, provided via X-API-Key header or Authorization: Bearer header.
+// Viewer-role tokens are accepted on viewerRoutes only ("METHOD /route/pattern", e.g.
+// "GET /api/admin/statistics"); user-role tokens and viewers elsewhere are rejected with 403.
+// The read-only key (auth.readonly_api_key, empty = none) is accepted on readOnlyRoutes only; elsewhere it gets
+// 403 with the ErrCodeReadOnlyKey code.
+func AdminAuth(
+	adminKeyService interfaces.AdminKeyService,
+	tokenService interfaces.TokenService,
+	viewerRoutes []string,
+	readOnlyKey string,
+	readOnlyRoutes []string,
+	logger sctx.Logger,
+) gin.HandlerFunc {
+	allowedToViewers := make(map[string]bool, len(viewerRoutes))
+	for _, route := range viewerRoutes {
+		allowedToViewers[route] = true
+	}
+	allowedToReadOnlyKey := make(map[string]bool, len(readOnlyRoutes))
+	for _, route := range readOnlyRoutes {
+		allowedToReadOnlyKey[route] = true
+	}
+	var readOnlyKeyHash [sha256.Size]byte
+	if readOnlyKey != "" {
+		readOnlyKeyHash = sha256.Sum256([]byte(readOnlyKey))
+	}
+
+	return func(c *gin.Context) {
+		providedKey := c.GetHeader("X-API-Key")
+		if providedKey == "" {
+			if parts := strings.Split(c.GetHeader("Authorization"), " "); len(parts) == 2 && parts[0] == "Bearer" {
+				providedKey = parts[1]
+			}
+		}
+
+		if providedKey == "" {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error": gin.H{
+					"type":    "authentication_error",
+					"message": "API key is required",
+				},
+			})
+			c.Abort()
+			return
+		}
+
+		// Admin API key (hashed, constant-time compare; rotated-out keys work until their grace period ends)
+		if identity, ok := adminKeyService.Authenticate(c.Request.Context(), providedKey); ok {
+			logger.Withs(sctx.Fields{
+				"identity": identity,
+				"method":   c.Request.Method,
+				"path":     c.Request.URL.Path,
+			}).Info("Admin request authenticated")
+
+			setAdminIdentity(c, identity)
+			c.Set(AccessLevelContextKey, AccessLevelFull)
+			c.Next()
+			return
+		}
+
+		// Read-only API key (hashed so the compare takes the same time whatever the provided key's length)
+		providedHash := sha256.Sum256([]byte(providedKey))
+		if readOnlyKey != "" && subtle.ConstantTimeCompare(providedHash[:], readOnlyKeyHash[:]) == 1 {
+			route := c.Request.Method + " " + c.FullPath()
+			if !allowedToReadOnlyKey[route] {
+				logger.Withs(sctx.Fields{
+					"identity": ReadOnlyKeyIdentity,
+					"method":   c.Request.Method,
+					"path":     c.Request.URL.Path,
+				}).Warn("Read-only API key attempted a route that needs full access")
+
+				c.JSON(http.StatusForbidden, gin.H{
+					"error": gin.H{
+						"type":    "permission_error",
+						"code":    ErrCodeReadOnlyKey,
+						"message": "The read-only API key can't be used for " + route,
+					},
+				})
+				c.Abort()
+				return
+			}
+
+			logger.Withs(sctx.Fields{
+				"identity": ReadOnlyKeyIdentity,
+				"method":   c.Request.Method,
+				"path":     c.Request.URL.Path,
+			}).Debug("Read-only request authenticated")
+
+			setAdminIdentity(c, ReadOnlyKeyIdentity)
+			c.Set(AccessLevelContextKey, AccessLevelRead)
+			c.Next()
+			return
+		}
+
+		// Admin-role token, looked up without counting the request: admin calls are not proxy usage and must not
+		// mark the tokens for a save
+		token, err := tokenService.GetTokenByKey(c.Request.Context(), providedKey)
+		if err == nil && !token.IsActive() {
+			err = fmt.Errorf("token is not active")
+		}
+		if err != nil {
+			logger.Withs(sctx.Fields{
+				"error": err.Error(),
+				"path":  c.Request.URL.Path,
+			}).Warn("Admin authentication failed")
+
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error": gin.H{
+					"type":    "authentication_error",
+					"message": "Invalid API key",
+				},
+			})
+			c.Abort()
+			return
+		}
+
+		if token.IsViewer() && allowedToViewers[c.Request.Method+" "+c.FullPath()] {
+			logger.Withs(sctx.Fields{
+				"identity":   "token:" + token.ID,
+				"token_name": token.Name,
+				"method":     c.Request.Method,
+				"path":       c.Request.URL.Path,
+			}).Debug("Viewer request authenticated")
+
+			setAdminIdentity(c, "token:"+token.ID)
+			c.Set(AccessLevelContextKey, AccessLevelRead)
+			c.Set(ViewerContextKey, true)
+			c.Set("validated_token", token)
+			c.Next()
+			return
+		}
+
+		if !token.IsAdmin() {
+			logger.Withs(sctx.Fields{
+				"token_id":   token.ID,
+				"token_name": token.Name,
+				"path":       c.Request.URL.Path,
+			}).Warn("Non-admin token attempted admin access")
+
+			c.JSON(http.StatusForbidden, gin.H{
+				"error": gin.H{
+					"type":    "permission_error",
+					"message": "Admin role is required",
+				},
+			})
+			c.Abort()
+			return
+		}
+
+		logger.Withs(sctx.Fields{
+			"identity":   "token:" + token.ID,
+			"token_name": token.Name,
+			"method":     c.Request.Method,
+			"path":       c.Request.URL.Path,
+		}).Info("Admin request authenticated")
+
+		setAdminIdentity(c, "token:"+token.ID)
+		c.Set(AccessLevelContextKey, AccessLevelFull)
+		c.Set("validated_token", token)
+		c.Next()
+	}
+}
+
+// RequireAdminKey creates middleware, used after AdminAuth, that rejects admin-role tokens (and anything
+// without full access) with 403 so only admin API keys reach the route
+func RequireAdminKey() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		isToken := strings.HasPrefix(c.GetString(AdminIdentityContextKey), "token:")
+		if isToken || AccessLevel(c) != AccessLevelFull {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error": gin.H{
+					"type":    "permission_error",
+					"message": "Admin API key is required",
+				},
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
 // BearerTokenAuth creates middleware for Bearer token authentication
+// Failures are returned in the Anthropic error envelope so SDK clients can parse them
 func BearerTokenAuth(tokenService interfaces.TokenService, logger sctx.Logger) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Extract bearer token from Authorization header
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
-			panic(errors.NewUnauthorizedError("missing authorization header"))
+			AbortWithAnthropicError(c, http.StatusUnauthorized, "missing authorization header")
+			return
 		}
 
 		// Parse bearer token
 		parts := strings.Split(authHeader, " ")
 		if len(parts) != 2 || parts[0] != "Bearer" {
-			panic(errors.NewUnauthorizedError("invalid authorization header format, expected 'Bearer <token>'"))
+			AbortWithAnthropicError(
+				c,
+				http.StatusUnauthorized,
+				"invalid authorization header format, expected 'Bearer <token>'",
+			)
+			return
 		}
 		bearerToken := parts[1]
 
@@ -66,7 +299,18 @@ func BearerTokenAuth(tokenService interfaces.TokenService, logger sctx.Logger) g
 			logger.Withs(sctx.Fields{
 				"error": err.Error(),
 			}).Warn("Token validation failed")
-			panic(errors.NewUnauthorizedError("invalid or inactive token"))
+			AbortWithAnthropicError(c, http.StatusUnauthorized, "invalid or inactive token")
+			return
+		}
+
+		if validatedToken.IsViewer() {
+			logger.Withs(sctx.Fields{
+				"token_id":   validatedToken.ID,
+				"token_name": validatedToken.Name,
+				"path":       c.Request.URL.Path,
+			}).Warn("Viewer token attempted proxy access")
+			AbortWithAnthropicError(c, http.StatusForbidden, "viewer tokens can only read admin statistics")
+			return
 		}
 
 		logger.Withs(sctx.Fields{
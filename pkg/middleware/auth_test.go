@@ -0,0 +1,180 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"claude-proxy/modules/auth/domain/entities"
+	"claude-proxy/modules/auth/domain/interfaces"
+	"claude-proxy/pkg/logging"
+
+	"github.com/gin-gonic/gin"
+	sctx "github.com/phathdt/service-context"
+)
+
+// quietLogger returns a logger dropping everything below errors
+func quietLogger(tb testing.TB) sctx.Logger {
+	tb.Helper()
+
+	registry, err := logging.NewRegistry("error")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return registry.Wrap(sctx.GlobalLogger().GetLogger("test"))
+}
+
+// keyedTokens is a token service holding tokens by key, counting the requests validated
+type keyedTokens struct {
+	interfaces.TokenService
+	tokens    map[string]*entities.Token
+	validated int
+}
+
+func (k *keyedTokens) ValidateToken(_ context.Context, key string) (*entities.Token, error) {
+	token, ok := k.tokens[key]
+	if !ok || !token.IsActive() {
+		return nil, errors.New("invalid token")
+	}
+	k.validated++
+	return token, nil
+}
+
+func (k *keyedTokens) GetTokenByKey(_ context.Context, key string) (*entities.Token, error) {
+	token, ok := k.tokens[key]
+	if !ok {
+		return nil, errors.New("token not found")
+	}
+	return token, nil
+}
+
+// singleAdminKey is an admin key service accepting one key
+type singleAdminKey struct {
+	interfaces.AdminKeyService
+	key string
+}
+
+func (s *singleAdminKey) Authenticate(_ context.Context, key string) (string, bool) {
+	return "admin_key", key == s.key
+}
+
+// testTokens holds an active token of each role, keyed by role, and revoked user and admin ones
+func testTokens() *keyedTokens {
+	token := func(id string, role entities.TokenRole, status entities.TokenStatus) *entities.Token {
+		return &entities.Token{ID: id, Name: id, Role: role, Status: status}
+	}
+	return &keyedTokens{tokens: map[string]*entities.Token{
+		"user":          token("tok_user", entities.TokenRoleUser, entities.TokenStatusActive),
+		"admin":         token("tok_admin", entities.TokenRoleAdmin, entities.TokenStatusActive),
+		"viewer":        token("tok_viewer", entities.TokenRoleViewer, entities.TokenStatusActive),
+		"revoked":       token("tok_revoked", entities.TokenRoleUser, entities.TokenStatusRevoked),
+		"revoked-admin": token("tok_revoked_admin", entities.TokenRoleAdmin, entities.TokenStatusRevoked),
+	}}
+}
+
+func TestBearerTokenAuth(t *testing.T) {
+	const (
+		missing = `{"type":"error","error":{"type":"authentication_error","message":"missing authorization header"}}`
+		format  = `{"type":"error","error":{"type":"authentication_error",` +
+			`"message":"invalid authorization header format, expected 'Bearer <token>'"}}`
+		invalid = `{"type":"error","error":{"type":"authentication_error","message":"invalid or inactive token"}}`
+		viewer  = `{"type":"error","error":{"type":"permission_error",` +
+			`"message":"viewer tokens can only read admin statistics"}}`
+	)
+	tests := []struct {
+		name          string
+		authorization string // "" sends no Authorization header
+		wantStatus    int
+		wantBody      string // Exact JSON body; "" when the request goes through
+		wantToken     string // ID of the token handed to the handler
+	}{
+		{"missing header", "", http.StatusUnauthorized, missing, ""},
+		{"basic scheme", "Basic dXNlcjpwYXNz", http.StatusUnauthorized, format, ""},
+		{"lowercase scheme", "bearer user", http.StatusUnauthorized, format, ""},
+		{"no token", "Bearer", http.StatusUnauthorized, format, ""},
+		{"extra field", "Bearer user extra", http.StatusUnauthorized, format, ""},
+		{"unknown token", "Bearer unknown", http.StatusUnauthorized, invalid, ""},
+		{"revoked token", "Bearer revoked", http.StatusUnauthorized, invalid, ""},
+		{"viewer token", "Bearer viewer", http.StatusForbidden, viewer, ""},
+		{"user token", "Bearer user", http.StatusOK, "", "tok_user"},
+		{"admin token", "Bearer admin", http.StatusOK, "", "tok_admin"},
+	}
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.POST("/v1/messages", BearerTokenAuth(testTokens(), quietLogger(t)), func(c *gin.Context) {
+		token := c.MustGet("validated_token").(*entities.Token)
+		c.String(http.StatusOK, token.ID)
+	})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/v1/messages", nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			w := httptest.NewRecorder()
+			engine.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d (%s), want %d", w.Code, w.Body.String(), tt.wantStatus)
+			}
+			if tt.wantBody == "" {
+				if w.Body.String() != tt.wantToken {
+					t.Errorf("handler got token %q, want %s", w.Body.String(), tt.wantToken)
+				}
+				return
+			}
+			assertJSONBody(t, w.Body.Bytes(), tt.wantBody)
+		})
+	}
+}
+
+func TestAdminAuthDoesNotCountUsage(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		key        string
+		wantStatus int
+		wantLevel  string // Access level handed to the handler
+	}{
+		{"admin key", http.MethodDelete, "/api/tokens/tok_1", "admin-key", http.StatusOK, AccessLevelFull},
+		{"admin token", http.MethodDelete, "/api/tokens/tok_1", "admin", http.StatusOK, AccessLevelFull},
+		{"viewer token on a viewer route", http.MethodGet, "/api/admin/statistics", "viewer", http.StatusOK,
+			AccessLevelRead},
+		{"viewer token elsewhere", http.MethodDelete, "/api/tokens/tok_1", "viewer", http.StatusForbidden, ""},
+		{"user token", http.MethodGet, "/api/admin/statistics", "user", http.StatusForbidden, ""},
+		{"revoked admin token", http.MethodDelete, "/api/tokens/tok_1", "revoked-admin", http.StatusUnauthorized, ""},
+		{"unknown key", http.MethodDelete, "/api/tokens/tok_1", "unknown", http.StatusUnauthorized, ""},
+	}
+	gin.SetMode(gin.TestMode)
+	tokens := testTokens()
+	auth := AdminAuth(&singleAdminKey{key: "admin-key"}, tokens, []string{"GET /api/admin/statistics"}, "", nil,
+		quietLogger(t))
+	engine := gin.New()
+	handler := func(c *gin.Context) { c.String(http.StatusOK, AccessLevel(c)) }
+	engine.GET("/api/admin/statistics", auth, handler)
+	engine.DELETE("/api/tokens/:id", auth, handler)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			req.Header.Set("Authorization", "Bearer "+tt.key)
+			w := httptest.NewRecorder()
+			engine.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d (%s), want %d", w.Code, w.Body.String(), tt.wantStatus)
+			}
+			if tt.wantLevel != "" && w.Body.String() != tt.wantLevel {
+				t.Errorf("access level = %q, want %q", w.Body.String(), tt.wantLevel)
+			}
+		})
+	}
+
+	// Admin requests never count as token usage, so they don't mark tokens for a save
+	if tokens.validated != 0 {
+		t.Errorf("%d admin requests counted as token usage", tokens.validated)
+	}
+}
@@ -0,0 +1,242 @@
+package middleware
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Content codings responses are compressed with, most preferred first
+const (
+	EncodingZstd = "zstd"
+	EncodingGzip = "gzip"
+)
+
+// encoder is a pooled compressor writing to a response
+type encoder interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+var encoderPools = map[string]*sync.Pool{
+	EncodingGzip: {New: func() any {
+		w, _ := gzip.NewWriterLevel(nil, gzip.DefaultCompression)
+		return w
+	}},
+	EncodingZstd: {New: func() any {
+		w, _ := zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.SpeedDefault), zstd.WithEncoderConcurrency(1))
+		return w
+	}},
+}
+
+// Compress compresses response bodies of at least minSize bytes with the coding the client prefers
+// (zstd or gzip, from Accept-Encoding)
+// Bodies are only compressed when their type is compressible (JSON, text, JavaScript, ...) and no handler
+// already set a Content-Encoding; event streams are never buffered. Only use it on routes whose bodies
+// the proxy produces itself: /v1 responses must be relayed untouched
+func Compress(minSize int) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		encoding := NegotiateEncoding(c.GetHeader("Accept-Encoding"))
+		if encoding == "" || c.Request.Method == http.MethodHead {
+			c.Next()
+			return
+		}
+
+		w := &compressWriter{ResponseWriter: c.Writer, encoding: encoding, minSize: minSize}
+		c.Writer = w
+		defer w.finish()
+		c.Next()
+	}
+}
+
+// NegotiateEncoding returns the coding (EncodingZstd or EncodingGzip) an Accept-Encoding header prefers,
+// or "" when it accepts neither
+// Higher q-values win, zstd on ties; "*" stands for codings the header doesn't name
+func NegotiateEncoding(acceptEncoding string) string {
+	if acceptEncoding == "" {
+		return ""
+	}
+
+	qualities := make(map[string]float64)
+	for part := range strings.SplitSeq(acceptEncoding, ",") {
+		coding, params, _ := strings.Cut(part, ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		qualities[coding] = q
+	}
+
+	best, bestQ := "", 0.0
+	for _, coding := range []string{EncodingZstd, EncodingGzip} {
+		q, ok := qualities[coding]
+		if !ok {
+			q = qualities["*"]
+		}
+		if q > bestQ {
+			best, bestQ = coding, q
+		}
+	}
+	return best
+}
+
+// IsCompressible returns true for content types worth compressing: text (but not event streams),
+// JSON, JavaScript, XML and SVG
+func IsCompressible(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	switch {
+	case mediaType == "text/event-stream":
+		return false
+	case strings.HasPrefix(mediaType, "text/"),
+		strings.HasSuffix(mediaType, "+json"),
+		strings.HasSuffix(mediaType, "+xml"):
+		return true
+	}
+	switch mediaType {
+	case "application/json", "application/javascript", "application/xml", "application/wasm",
+		"application/manifest+json", "image/svg+xml":
+		return true
+	}
+	return false
+}
+
+// CompressBytes compresses data with a coding NegotiateEncoding returned
+func CompressBytes(encoding string, data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := getEncoder(encoding, &buf)
+	defer putEncoder(encoding, enc)
+
+	if _, err := enc.Write(data); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func getEncoder(encoding string, w io.Writer) encoder {
+	enc := encoderPools[encoding].Get().(encoder)
+	enc.Reset(w)
+	return enc
+}
+
+func putEncoder(encoding string, enc encoder) {
+	enc.Reset(nil)
+	encoderPools[encoding].Put(enc)
+}
+
+// compressWriter buffers the start of a response body until it reaches the minimum size, then decides
+// once (from the final status and headers) whether the rest is compressed
+type compressWriter struct {
+	gin.ResponseWriter
+	encoding string
+	minSize  int
+	buf      []byte
+	decided  bool
+	enc      encoder // Set when the body is compressed
+}
+
+func (w *compressWriter) Write(data []byte) (int, error) {
+	if !w.decided {
+		w.buf = append(w.buf, data...)
+		if len(w.buf) < w.minSize {
+			return len(data), nil
+		}
+		if err := w.decide(true); err != nil {
+			return 0, err
+		}
+		return len(data), nil
+	}
+	if w.enc != nil {
+		return w.enc.Write(data)
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *compressWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// Written reports buffered bytes as written, so handlers and recovery don't write a second response
+func (w *compressWriter) Written() bool {
+	return len(w.buf) > 0 || w.decided || w.ResponseWriter.Written()
+}
+
+// Flush sends what was written so far; a body still under the minimum size is sent uncompressed
+func (w *compressWriter) Flush() {
+	if !w.decided {
+		_ = w.decide(len(w.buf) >= w.minSize)
+	}
+	if w.enc != nil {
+		_ = w.enc.Flush()
+	}
+	w.ResponseWriter.Flush()
+}
+
+func (w *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return w.ResponseWriter.Hijack()
+}
+
+// decide writes the headers and the buffered body, compressing them when wanted and the response allows it
+func (w *compressWriter) decide(compress bool) error {
+	w.decided = true
+	header := w.Header()
+
+	status := w.Status()
+	eligible := status >= http.StatusOK && status != http.StatusNoContent && status != http.StatusNotModified &&
+		header.Get("Content-Encoding") == "" && IsCompressible(header.Get("Content-Type"))
+	if eligible {
+		header.Add("Vary", "Accept-Encoding")
+	}
+
+	buf := w.buf
+	w.buf = nil
+	if !eligible || !compress {
+		if len(buf) == 0 {
+			return nil
+		}
+		_, err := w.ResponseWriter.Write(buf)
+		return err
+	}
+
+	header.Set("Content-Encoding", w.encoding)
+	header.Del("Content-Length")
+	w.enc = getEncoder(w.encoding, w.ResponseWriter)
+	_, err := w.enc.Write(buf)
+	return err
+}
+
+// finish sends a body that stayed under the minimum size and completes a compressed one
+func (w *compressWriter) finish() {
+	if !w.decided {
+		if len(w.buf) > 0 {
+			w.Header().Set("Content-Length", strconv.Itoa(len(w.buf)))
+		}
+		_ = w.decide(false)
+	}
+	if w.enc != nil {
+		_ = w.enc.Close()
+		putEncoder(w.encoding, w.enc)
+		w.enc = nil
+	}
+}
@@ -0,0 +1,232 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DefaultCORSAllowedHeaders are the request headers allowed cross-origin when the admin policy lists none
+var DefaultCORSAllowedHeaders = []string{
+	"Content-Type", "Content-Length", "Accept-Encoding", "X-CSRF-Token", "Authorization", "accept", "origin",
+	"Cache-Control", "X-Requested-With", "X-API-Key",
+}
+
+// DefaultCORSProxyHeaders are the request headers allowed cross-origin when the proxy policy lists none: the
+// admin ones plus those Anthropic SDKs send from browsers. Headers a preflight asks for are allowed as well
+// (the proxy default is CORSAnyHeader).
+var DefaultCORSProxyHeaders = append(append([]string{}, DefaultCORSAllowedHeaders...),
+	"anthropic-version", "anthropic-beta", "anthropic-dangerous-direct-browser-access",
+)
+
+// DefaultCORSProxyExposedHeaders are the response headers browsers may read when the proxy policy lists none:
+// request IDs, Retry-After and the rate limit headers
+var DefaultCORSProxyExposedHeaders = []string{
+	"request-id", "X-Request-Id", "Retry-After",
+	"anthropic-ratelimit-requests-limit", "anthropic-ratelimit-requests-remaining",
+	"anthropic-ratelimit-requests-reset",
+	"anthropic-ratelimit-tokens-limit", "anthropic-ratelimit-tokens-remaining", "anthropic-ratelimit-tokens-reset",
+	"anthropic-ratelimit-input-tokens-limit", "anthropic-ratelimit-input-tokens-remaining",
+	"anthropic-ratelimit-input-tokens-reset",
+	"anthropic-ratelimit-output-tokens-limit", "anthropic-ratelimit-output-tokens-remaining",
+	"anthropic-ratelimit-output-tokens-reset",
+	"anthropic-ratelimit-unified-reset", "anthropic-ratelimit-unified-5h-reset",
+}
+
+// CORSAnyHeader in a policy's allowed headers also allows every header a preflight asks for
+// (Access-Control-Request-Headers is reflected, with Vary, so it works with credentials too)
+const CORSAnyHeader = "*"
+
+// maxReflectedHeaders bounds the headers of Access-Control-Request-Headers reflected in one preflight
+const maxReflectedHeaders = 50
+
+// corsAllowedMethods are the methods allowed cross-origin
+const corsAllowedMethods = "POST, OPTIONS, GET, PUT, DELETE"
+
+// CORSPolicy is the cross-origin access allowed to a group of routes
+type CORSPolicy struct {
+	AllowedOrigins   []string // Exact origins (scheme://host[:port]) or "*" for any origin; empty = "*"
+	AllowedHeaders   []string // Empty = the group's defaults; CORSAnyHeader reflects the preflight's headers
+	ExposedHeaders   []string // Response headers browsers may read (Access-Control-Expose-Headers)
+	AllowCredentials bool     // Allow cookies and HTTP auth; the request's origin is echoed instead of "*"
+	MaxAge           time.Duration
+}
+
+// corsRules is a validated CORSPolicy with its headers pre-rendered
+type corsRules struct {
+	anyOrigin   bool
+	origins     map[string]bool
+	headers     string
+	anyHeader   bool            // Reflect the headers a preflight asks for, besides headers
+	listed      map[string]bool // Lowercased headers, not reflected twice
+	exposed     string          // Empty when no Access-Control-Expose-Headers is sent
+	credentials bool
+	maxAge      string // Empty when no Access-Control-Max-Age is sent
+}
+
+// CORS creates middleware answering cross-origin requests, with one policy for the /v1 proxy routes and
+// one for every other route (admin API, OAuth, dashboard, health)
+// Matching origins are echoed back with Vary: Origin; only a wildcard policy without credentials answers
+// "Access-Control-Allow-Origin: *". Preflight (OPTIONS) requests are answered with 204 and never reach routes.
+// Unless configured otherwise, the proxy policy allows the Anthropic request headers and any other header a
+// preflight asks for, and exposes the request ID and rate limit response headers to browser clients.
+func CORS(proxy, admin CORSPolicy) (gin.HandlerFunc, error) {
+	if len(proxy.AllowedHeaders) == 0 {
+		proxy.AllowedHeaders = append(append([]string{}, DefaultCORSProxyHeaders...), CORSAnyHeader)
+	}
+	if len(proxy.ExposedHeaders) == 0 {
+		proxy.ExposedHeaders = DefaultCORSProxyExposedHeaders
+	}
+	proxyRules, err := newCORSRules(proxy)
+	if err != nil {
+		return nil, fmt.Errorf("proxy policy: %w", err)
+	}
+	adminRules, err := newCORSRules(admin)
+	if err != nil {
+		return nil, fmt.Errorf("admin policy: %w", err)
+	}
+
+	return func(c *gin.Context) {
+		rules := adminRules
+		if path := c.Request.URL.Path; path == "/v1" || strings.HasPrefix(path, "/v1/") {
+			rules = proxyRules
+		}
+		rules.apply(c)
+
+		if c.Request.Method == http.MethodOptions {
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+
+		c.Next()
+	}, nil
+}
+
+// newCORSRules validates a policy, applying its defaults
+func newCORSRules(policy CORSPolicy) (*corsRules, error) {
+	if policy.MaxAge < 0 {
+		return nil, fmt.Errorf("max_age must not be negative")
+	}
+
+	origins := policy.AllowedOrigins
+	if len(origins) == 0 {
+		origins = []string{"*"}
+	}
+	headers := policy.AllowedHeaders
+	if len(headers) == 0 {
+		headers = DefaultCORSAllowedHeaders
+	}
+
+	rules := &corsRules{
+		origins:     make(map[string]bool, len(origins)),
+		listed:      make(map[string]bool, len(headers)),
+		exposed:     strings.Join(policy.ExposedHeaders, ", "),
+		credentials: policy.AllowCredentials,
+	}
+	var listed []string
+	for _, h := range headers {
+		if h == CORSAnyHeader {
+			rules.anyHeader = true
+			continue
+		}
+		listed = append(listed, h)
+		rules.listed[strings.ToLower(h)] = true
+	}
+	rules.headers = strings.Join(listed, ", ")
+	if policy.MaxAge > 0 {
+		rules.maxAge = strconv.Itoa(int(policy.MaxAge.Seconds()))
+	}
+
+	for _, origin := range origins {
+		if origin == "*" {
+			rules.anyOrigin = true
+			continue
+		}
+		u, err := url.Parse(origin)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" ||
+			strings.TrimSuffix(u.Path, "/") != "" || u.RawQuery != "" {
+			return nil, fmt.Errorf("allowed origin %q must be \"*\" or scheme://host[:port]", origin)
+		}
+		rules.origins[strings.ToLower(u.Scheme+"://"+u.Host)] = true
+	}
+	return rules, nil
+}
+
+// apply sets the CORS response headers for the request's origin, if it is allowed
+func (r *corsRules) apply(c *gin.Context) {
+	header := c.Writer.Header()
+	origin := c.Request.Header.Get("Origin")
+
+	switch {
+	case r.anyOrigin && !r.credentials:
+		header.Set("Access-Control-Allow-Origin", "*")
+	case origin != "" && (r.anyOrigin || r.origins[strings.ToLower(origin)]):
+		header.Set("Access-Control-Allow-Origin", origin)
+		header.Add("Vary", "Origin")
+		if r.credentials {
+			header.Set("Access-Control-Allow-Credentials", "true")
+		}
+	default:
+		if !r.anyOrigin {
+			header.Add("Vary", "Origin")
+		}
+		return
+	}
+
+	header.Set("Access-Control-Allow-Methods", corsAllowedMethods)
+	if c.Request.Method != http.MethodOptions {
+		if r.exposed != "" {
+			header.Set("Access-Control-Expose-Headers", r.exposed)
+		}
+		header.Set("Access-Control-Allow-Headers", r.headers)
+		return
+	}
+
+	requested := c.Request.Header.Get("Access-Control-Request-Headers")
+	header.Set("Access-Control-Allow-Headers", r.allowedHeaders(requested))
+	if r.anyHeader {
+		header.Add("Vary", "Access-Control-Request-Headers")
+	}
+	if r.maxAge != "" {
+		header.Set("Access-Control-Max-Age", r.maxAge)
+	}
+}
+
+// allowedHeaders returns the Access-Control-Allow-Headers of a preflight: the listed headers, plus the requested
+// ones not among them when the policy allows any header (malformed names are never reflected)
+func (r *corsRules) allowedHeaders(requested string) string {
+	if !r.anyHeader || requested == "" {
+		return r.headers
+	}
+
+	allowed := r.headers
+	seen := make(map[string]bool)
+	for _, name := range strings.Split(requested, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" || r.listed[name] || seen[name] || !isHeaderToken(name) || len(seen) >= maxReflectedHeaders {
+			continue
+		}
+		seen[name] = true
+		if allowed != "" {
+			allowed += ", "
+		}
+		allowed += name
+	}
+	return allowed
+}
+
+// isHeaderToken returns true if name is a valid HTTP header field name
+func isHeaderToken(name string) bool {
+	for _, ch := range name {
+		alphanumeric := ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z' || ch >= '0' && ch <= '9'
+		if !alphanumeric && !strings.ContainsRune("!#$%&'*+-.^_`|~", ch) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,204 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// sdkPreflightHeaders are the headers an Anthropic SDK preflight asks for before POST /v1/messages
+const sdkPreflightHeaders = "anthropic-version,anthropic-beta,anthropic-dangerous-direct-browser-access," +
+	"content-type,x-api-key,x-stainless-os"
+
+// newCORSEngine serves POST /v1/messages and POST /api/tokens behind CORS with the given policies
+func newCORSEngine(t *testing.T, proxy, admin CORSPolicy) *gin.Engine {
+	t.Helper()
+
+	cors, err := CORS(proxy, admin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.Use(cors)
+	for _, path := range []string{"/v1/messages", "/api/tokens"} {
+		engine.POST(path, func(c *gin.Context) { c.Status(http.StatusOK) })
+	}
+	return engine
+}
+
+// preflight sends the preflight of a POST to path from origin, asking for headers
+func preflight(engine *gin.Engine, path, origin, headers string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodOptions, path, nil)
+	req.Header.Set("Origin", origin)
+	req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+	req.Header.Set("Access-Control-Request-Headers", headers)
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	return w
+}
+
+// headerList splits a comma-separated header value into lowercased names
+func headerList(value string) []string {
+	var names []string
+	for _, name := range strings.Split(value, ",") {
+		if name = strings.ToLower(strings.TrimSpace(name)); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+func TestCORSProxyPreflight(t *testing.T) {
+	const origin = "https://app.example.com"
+
+	tests := []struct {
+		name            string
+		proxy           CORSPolicy
+		origin          string
+		requested       string
+		wantOrigin      string   // Access-Control-Allow-Origin; empty for none
+		wantAllowed     []string // Headers Access-Control-Allow-Headers must list
+		wantNotAllowed  []string // Headers it must not list
+		wantVary        []string
+		wantCredentials bool
+		wantMaxAge      string
+	}{
+		{
+			name:        "defaults",
+			origin:      origin,
+			requested:   sdkPreflightHeaders,
+			wantOrigin:  "*",
+			wantAllowed: headerList(sdkPreflightHeaders),
+			wantVary:    []string{"Access-Control-Request-Headers"},
+		},
+		{
+			name: "listed origin with credentials and max age",
+			proxy: CORSPolicy{
+				AllowedOrigins: []string{origin}, AllowCredentials: true, MaxAge: 10 * time.Minute,
+			},
+			origin:          origin,
+			requested:       sdkPreflightHeaders,
+			wantOrigin:      origin,
+			wantAllowed:     headerList(sdkPreflightHeaders),
+			wantVary:        []string{"Origin", "Access-Control-Request-Headers"},
+			wantCredentials: true,
+			wantMaxAge:      "600",
+		},
+		{
+			name:     "unlisted origin",
+			proxy:    CORSPolicy{AllowedOrigins: []string{origin}},
+			origin:   "https://evil.example.com",
+			wantVary: []string{"Origin"},
+		},
+		{
+			name:           "fixed header list",
+			proxy:          CORSPolicy{AllowedHeaders: []string{"Content-Type", "anthropic-version"}},
+			origin:         origin,
+			requested:      sdkPreflightHeaders,
+			wantOrigin:     "*",
+			wantAllowed:    []string{"content-type", "anthropic-version"},
+			wantNotAllowed: []string{"anthropic-beta", "x-stainless-os"},
+		},
+		{
+			name:           "malformed requested headers",
+			origin:         origin,
+			requested:      "anthropic-version, bad header, x-ok, (bad)",
+			wantOrigin:     "*",
+			wantAllowed:    []string{"anthropic-version", "x-ok"},
+			wantNotAllowed: []string{"bad header", "(bad)"},
+			wantVary:       []string{"Access-Control-Request-Headers"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := preflight(newCORSEngine(t, tt.proxy, CORSPolicy{}), "/v1/messages", tt.origin, tt.requested)
+			header := w.Header()
+
+			if w.Code != http.StatusNoContent {
+				t.Errorf("status = %d, want 204", w.Code)
+			}
+			if got := header.Get("Access-Control-Allow-Origin"); got != tt.wantOrigin {
+				t.Errorf("Access-Control-Allow-Origin = %q, want %q", got, tt.wantOrigin)
+			}
+			if got := header.Values("Vary"); !slices.Equal(got, tt.wantVary) {
+				t.Errorf("Vary = %q, want %q", got, tt.wantVary)
+			}
+			if got := header.Get("Access-Control-Allow-Credentials") == "true"; got != tt.wantCredentials {
+				t.Errorf("Access-Control-Allow-Credentials = %q", header.Get("Access-Control-Allow-Credentials"))
+			}
+			if got := header.Get("Access-Control-Max-Age"); got != tt.wantMaxAge {
+				t.Errorf("Access-Control-Max-Age = %q, want %q", got, tt.wantMaxAge)
+			}
+			if got := header.Get("Access-Control-Expose-Headers"); got != "" {
+				t.Errorf("preflight exposes headers: %q", got)
+			}
+			if tt.wantOrigin != "" && header.Get("Access-Control-Allow-Methods") != corsAllowedMethods {
+				t.Errorf("Access-Control-Allow-Methods = %q", header.Get("Access-Control-Allow-Methods"))
+			}
+
+			allowed := headerList(header.Get("Access-Control-Allow-Headers"))
+			for _, name := range tt.wantAllowed {
+				if !slices.Contains(allowed, name) {
+					t.Errorf("Access-Control-Allow-Headers %q lacks %s", allowed, name)
+				}
+			}
+			for _, name := range tt.wantNotAllowed {
+				if slices.Contains(allowed, name) {
+					t.Errorf("Access-Control-Allow-Headers %q lists %s", allowed, name)
+				}
+			}
+			for i, name := range allowed {
+				if slices.Contains(allowed[i+1:], name) {
+					t.Errorf("Access-Control-Allow-Headers %q lists %s twice", allowed, name)
+				}
+			}
+		})
+	}
+}
+
+func TestCORSProxyExposesResponseHeaders(t *testing.T) {
+	engine := newCORSEngine(t, CORSPolicy{}, CORSPolicy{})
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/messages", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200 from the route", w.Code)
+	}
+	exposed := headerList(w.Header().Get("Access-Control-Expose-Headers"))
+	for _, name := range []string{
+		"request-id", "x-request-id", "retry-after", "anthropic-ratelimit-requests-remaining",
+		"anthropic-ratelimit-tokens-reset", "anthropic-ratelimit-unified-5h-reset",
+	} {
+		if !slices.Contains(exposed, name) {
+			t.Errorf("Access-Control-Expose-Headers %q lacks %s", exposed, name)
+		}
+	}
+	if got := w.Header().Get("Access-Control-Max-Age"); got != "" {
+		t.Errorf("Access-Control-Max-Age = %q on a non-preflight response", got)
+	}
+}
+
+func TestCORSAdminPreflightKeepsItsOwnPolicy(t *testing.T) {
+	engine := newCORSEngine(t, CORSPolicy{}, CORSPolicy{MaxAge: time.Hour})
+
+	w := preflight(engine, "/api/tokens", "https://app.example.com", sdkPreflightHeaders)
+	allowed := headerList(w.Header().Get("Access-Control-Allow-Headers"))
+	if !slices.Equal(allowed, headerList(strings.Join(DefaultCORSAllowedHeaders, ","))) {
+		t.Errorf("Access-Control-Allow-Headers = %q, want the admin defaults", allowed)
+	}
+	if got := w.Header().Values("Vary"); len(got) != 0 {
+		t.Errorf("Vary = %q, want none", got)
+	}
+	if got := w.Header().Get("Access-Control-Max-Age"); got != "3600" {
+		t.Errorf("Access-Control-Max-Age = %q, want 3600", got)
+	}
+}
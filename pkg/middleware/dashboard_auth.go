@@ -0,0 +1,39 @@
+package middleware
+
+import (
+	"net/http"
+
+	"claude-proxy/modules/auth/domain/interfaces"
+
+	"github.com/gin-gonic/gin"
+	sctx "github.com/phathdt/service-context"
+)
+
+// DashboardAuthCookie is the cookie that may carry the admin API key for dashboard assets
+const DashboardAuthCookie = "claude_proxy_admin_key"
+
+// DashboardAuth creates middleware that requires an admin API key before serving dashboard assets
+// The key is taken from the DashboardAuthCookie cookie or from the password of HTTP Basic auth (any
+// username), so browsers can prompt for it; admin-role tokens are not accepted here.
+func DashboardAuth(adminKeyService interfaces.AdminKeyService, logger sctx.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		providedKey, _ := c.Cookie(DashboardAuthCookie)
+		if providedKey == "" {
+			_, providedKey, _ = c.Request.BasicAuth()
+		}
+
+		if providedKey != "" {
+			if _, ok := adminKeyService.Authenticate(c.Request.Context(), providedKey); ok {
+				c.Next()
+				return
+			}
+			logger.Withs(sctx.Fields{
+				"path":      c.Request.URL.Path,
+				"client_ip": c.ClientIP(),
+			}).Warn("Dashboard authentication failed")
+		}
+
+		c.Header("WWW-Authenticate", `Basic realm="Claude Proxy Dashboard", charset="UTF-8"`)
+		c.AbortWithStatus(http.StatusUnauthorized)
+	}
+}
@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+
+	"claude-proxy/modules/proxy/domain/interfaces"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ErrCodeMaintenance is the error code returned while maintenance mode is enabled
+const ErrCodeMaintenance = "MAINTENANCE_MODE"
+
+// Maintenance rejects new requests with 503 and a Retry-After header while maintenance mode is enabled
+// Only the start of a request is checked, so responses already streaming are allowed to finish
+func Maintenance(maintenanceService interfaces.MaintenanceService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		state := maintenanceService.State()
+		if !state.Enabled {
+			c.Next()
+			return
+		}
+
+		c.Header("Retry-After", strconv.Itoa(int(state.RetryAfter.Seconds())))
+		AbortWithAnthropicErrorCode(c, http.StatusServiceUnavailable, ErrCodeMaintenance, state.ClientMessage())
+	}
+}
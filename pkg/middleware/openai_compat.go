@@ -71,8 +71,10 @@ func OpenAICompatibility() gin.HandlerFunc {
 			}
 
 			// Replace request body
+			// The client's Content-Length described the original body
 			c.Request.Body = io.NopCloser(bytes.NewReader(newBody))
 			c.Request.ContentLength = int64(len(newBody))
+			c.Request.Header.Del("Content-Length")
 
 			// Rewrite path to /v1/messages
 			c.Request.URL.Path = "/v1/messages"
@@ -0,0 +1,68 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestOpenAICompatibilityKeepsLengthInStep(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var gotPath, gotHeader string
+	var gotLength int64
+	var gotBody []byte
+	engine := gin.New()
+	engine.Use(OpenAICompatibility())
+	engine.POST("/v1/*path", func(c *gin.Context) {
+		gotPath = c.Request.URL.Path
+		gotHeader = c.Request.Header.Get("Content-Length")
+		gotLength = c.Request.ContentLength
+		gotBody, _ = io.ReadAll(c.Request.Body)
+	})
+
+	body := `{"model":"claude-sonnet-4","messages":[{"role":"user","content":"hi"}],` +
+		`"temperature":0.5,"presence_penalty":1,"user":"someone"}`
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body))
+	req.Header.Set("Content-Length", strconv.Itoa(len(body)))
+	engine.ServeHTTP(httptest.NewRecorder(), req)
+
+	if gotPath != "/v1/messages" {
+		t.Errorf("path = %q, want /v1/messages", gotPath)
+	}
+	if len(gotBody) == len(body) {
+		t.Fatalf("body was not rewritten: %s", gotBody)
+	}
+	if gotLength != int64(len(gotBody)) {
+		t.Errorf("ContentLength = %d, want %d (the rewritten body)", gotLength, len(gotBody))
+	}
+	if gotHeader != "" {
+		t.Errorf("Content-Length header = %q, want it removed with the original body", gotHeader)
+	}
+}
+
+func TestOpenAICompatibilityLeavesOtherRequestsAlone(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var gotLength int64
+	var gotBody []byte
+	engine := gin.New()
+	engine.Use(OpenAICompatibility())
+	engine.POST("/v1/*path", func(c *gin.Context) {
+		gotLength = c.Request.ContentLength
+		gotBody, _ = io.ReadAll(c.Request.Body)
+	})
+
+	body := `{"model":"claude-sonnet-4","max_tokens":10,"messages":[{"role":"user","content":"hi"}]}`
+	req := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(body))
+	engine.ServeHTTP(httptest.NewRecorder(), req)
+
+	if string(gotBody) != body || gotLength != int64(len(body)) {
+		t.Errorf("body = %s (length %d), want the original %s", gotBody, gotLength, body)
+	}
+}
@@ -0,0 +1,110 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+
+	"claude-proxy/modules/auth/domain/entities"
+
+	"github.com/gin-gonic/gin"
+	sctx "github.com/phathdt/service-context"
+)
+
+// DefaultAllowedPaths are the Claude API paths reachable through the proxy without extra configuration
+// The Message Batches and Files APIs store data upstream, so they are opt-in through proxy.allowed_paths.
+var DefaultAllowedPaths = []string{
+	"/v1/messages",
+	"/v1/messages/count_tokens",
+	"/v1/models",
+	"/v1/models/*",
+}
+
+// MatchPathPattern reports whether requestPath matches a glob pattern
+// Patterns use path.Match syntax ("*" matches one segment); a trailing "/**" matches any sub-path.
+// The path is cleaned first (repeated and trailing slashes), and a path with a "." or ".." segment never
+// matches: the upstream could resolve it to another path than the one checked (/v1/files/../admin).
+func MatchPathPattern(pattern, requestPath string) bool {
+	if hasDotSegment(requestPath) {
+		return false
+	}
+	requestPath = path.Clean("/" + requestPath)
+
+	if prefix, ok := strings.CutSuffix(pattern, "/**"); ok {
+		return requestPath == prefix || strings.HasPrefix(requestPath, prefix+"/")
+	}
+
+	matched, err := path.Match(pattern, requestPath)
+	return err == nil && matched
+}
+
+// hasDotSegment returns true if requestPath has a "." or ".." segment
+func hasDotSegment(requestPath string) bool {
+	for _, segment := range strings.Split(requestPath, "/") {
+		if segment == "." || segment == ".." {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidatePathPatterns checks that every pattern is an absolute path with valid glob syntax
+func ValidatePathPatterns(patterns []string) error {
+	for _, pattern := range patterns {
+		if !strings.HasPrefix(pattern, "/") {
+			return fmt.Errorf("path pattern %q must start with /", pattern)
+		}
+		if _, err := path.Match(strings.TrimSuffix(pattern, "/**"), ""); err != nil {
+			return fmt.Errorf("invalid path pattern %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// PathPolicy creates middleware restricting proxied requests to allow-listed paths
+// A request is allowed when it matches the default list, the configured extra patterns,
+// or the validated token's own allowed paths.
+// Must run after BearerTokenAuth so the token is available in context.
+func PathPolicy(extraPaths []string, logger sctx.Logger) gin.HandlerFunc {
+	allowed := append(append([]string{}, DefaultAllowedPaths...), extraPaths...)
+
+	return func(c *gin.Context) {
+		requestPath := c.Request.URL.Path
+
+		for _, pattern := range allowed {
+			if MatchPathPattern(pattern, requestPath) {
+				c.Next()
+				return
+			}
+		}
+
+		if value, exists := c.Get("validated_token"); exists {
+			if token, ok := value.(*entities.Token); ok {
+				for _, pattern := range token.AllowedPaths {
+					if MatchPathPattern(pattern, requestPath) {
+						c.Next()
+						return
+					}
+				}
+			}
+		}
+
+		fields := sctx.Fields{
+			"method": c.Request.Method,
+			"path":   requestPath,
+		}
+		if value, exists := c.Get("validated_token"); exists {
+			if token, ok := value.(*entities.Token); ok {
+				fields["token_id"] = token.ID
+			}
+		}
+		logger.Withs(fields).Warn("Blocked request to non-allowed path")
+
+		AbortWithAnthropicError(
+			c,
+			http.StatusForbidden,
+			fmt.Sprintf("Path %s is not allowed through this proxy", requestPath),
+		)
+	}
+}
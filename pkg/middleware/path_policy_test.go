@@ -0,0 +1,102 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"claude-proxy/modules/auth/domain/entities"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestMatchPathPattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		want    bool
+	}{
+		{"/v1/messages", "/v1/messages", true},
+		{"/v1/messages", "/v1/messages/", true},
+		{"/v1/messages", "/v1//messages", true},
+		{"/v1/messages", "/v1/messages/count_tokens", false},
+		{"/v1/models/*", "/v1/models/claude-sonnet-4", true},
+		{"/v1/models/*", "/v1/models/a/b", false},
+		{"/v1/files/**", "/v1/files", true},
+		{"/v1/files/**", "/v1/files/file_1/content", true},
+		{"/v1/files/**", "/v1/filesystem", false},
+		{"/", "", true},
+
+		// Dot segments never match, whatever they would resolve to
+		{"/v1/files/**", "/v1/files/../admin", false},
+		{"/v1/files/**", "/v1/files/..", false},
+		{"/v1/files/**", "/v1/files/./file_1", false},
+		{"/v1/messages", "/v1/./messages", false},
+		{"/v1/messages", "/v1/x/../messages", false},
+		{"/v1/models/*", "/v1/models/..", false},
+		{"/**", "/..", false},
+
+		// Dots within a segment are ordinary names
+		{"/v1/files/*", "/v1/files/notes..txt", true},
+		{"/v1/files/*", "/v1/files/.env", true},
+	}
+	for _, tt := range tests {
+		if got := MatchPathPattern(tt.pattern, tt.path); got != tt.want {
+			t.Errorf("MatchPathPattern(%q, %q) = %v, want %v", tt.pattern, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestPathPolicyRejectsDotSegments(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	token := &entities.Token{ID: "tok_1", AllowedPaths: []string{"/v1/custom/**"}}
+	engine.Use(func(c *gin.Context) { c.Set("validated_token", token) }, PathPolicy(nil, quietLogger(t)))
+	engine.NoRoute(func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	tests := []struct {
+		target     string
+		wantStatus int
+	}{
+		{"/v1/models/claude-sonnet-4", http.StatusOK},
+		{"/v1/custom/report", http.StatusOK},
+		{"/v1/models/../../api/admin/tokens", http.StatusForbidden},
+		{"/v1/models/%2e%2e/%2e%2e/api/admin/tokens", http.StatusForbidden},
+		{"/v1/custom/./report", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.target, nil))
+		if w.Code != tt.wantStatus {
+			t.Errorf("GET %s: status = %d, want %d", tt.target, w.Code, tt.wantStatus)
+		}
+	}
+}
+
+func TestPathPolicyBatchesAndFilesAreOptIn(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	token := &entities.Token{ID: "tok_1"}
+	targets := []string{"/v1/messages/batches", "/v1/messages/batches/msgbatch_1/results", "/v1/files/file_1"}
+
+	tests := []struct {
+		name         string
+		allowedPaths []string
+		wantStatus   int
+	}{
+		{"default", nil, http.StatusForbidden},
+		{"allowed in config", []string{"/v1/messages/batches/**", "/v1/files/**"}, http.StatusOK},
+	}
+	for _, tt := range tests {
+		engine := gin.New()
+		engine.Use(func(c *gin.Context) { c.Set("validated_token", token) }, PathPolicy(tt.allowedPaths, quietLogger(t)))
+		engine.NoRoute(func(c *gin.Context) { c.Status(http.StatusOK) })
+
+		for _, target := range targets {
+			w := httptest.NewRecorder()
+			engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+			if w.Code != tt.wantStatus {
+				t.Errorf("%s: GET %s: status = %d, want %d", tt.name, target, w.Code, tt.wantStatus)
+			}
+		}
+	}
+}
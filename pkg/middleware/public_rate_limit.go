@@ -0,0 +1,72 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"claude-proxy/pkg/ratelimit"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ErrCodeRateLimitExceeded is the error code returned when a client IP exceeds server.public_rate_limit
+const ErrCodeRateLimitExceeded = "RATE_LIMIT_EXCEEDED"
+
+// LoginFailedContextKey is set to true by handlers behind LoginRateLimit that answer a failed attempt with 200
+// (such as {"valid": false}), so the attempt doesn't clear the IP's lockout progression
+const LoginFailedContextKey = "login_failed"
+
+// PublicRateLimit throttles unauthenticated routes per client IP (gin's ClientIP, so X-Forwarded-For is honored
+// from server.trusted_proxies only), answering 429 with Retry-After once the IP's token bucket is empty
+func PublicRateLimit(limiter *ratelimit.IPLimiter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if ok, retryAfter := limiter.Allow(c.ClientIP(), time.Now()); !ok {
+			abortRateLimited(c, retryAfter, "Too many requests from this IP address")
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// LoginRateLimit throttles login attempts like PublicRateLimit, and exceeding the limit also locks the IP out
+// for an exponentially growing period (server.public_rate_limit.login_lockout doubled per lockout, up to
+// login_max_lockout), which makes guessing the admin API key impractical. A successful login (200 without
+// LoginFailedContextKey) clears the progression.
+func LoginRateLimit(limiter *ratelimit.IPLimiter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ip := c.ClientIP()
+		now := time.Now()
+
+		if ok, retryAfter := limiter.Allow(ip, now); !ok {
+			// An IP already locked out waits out the lockout; one merely out of tokens earns the next lockout
+			if remaining := limiter.LockoutRemaining(ip, now); remaining > 0 {
+				retryAfter = remaining
+			} else {
+				retryAfter = limiter.Penalize(ip, now)
+			}
+			abortRateLimited(c, retryAfter, "Too many login attempts from this IP address")
+			return
+		}
+
+		c.Next()
+
+		if c.Writer.Status() == http.StatusOK && !c.GetBool(LoginFailedContextKey) {
+			limiter.Reset(ip)
+		}
+	}
+}
+
+// abortRateLimited answers 429 with Retry-After in whole seconds (at least 1)
+func abortRateLimited(c *gin.Context, retryAfter time.Duration, message string) {
+	seconds := max(int(math.Ceil(retryAfter.Seconds())), 1)
+	c.Header("Retry-After", strconv.Itoa(seconds))
+	c.JSON(http.StatusTooManyRequests, gin.H{
+		"code":    ErrCodeRateLimitExceeded,
+		"message": message,
+		"details": "retry after " + strconv.Itoa(seconds) + "s",
+	})
+	c.Abort()
+}
@@ -0,0 +1,159 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"claude-proxy/pkg/ratelimit"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newLoginEngine serves POST /login behind limit, answering 200 for the key "right" and 401 otherwise
+func newLoginEngine(limit gin.HandlerFunc) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.POST("/login", limit, func(c *gin.Context) {
+		if c.Query("key") != "right" {
+			c.Status(http.StatusUnauthorized)
+			return
+		}
+		c.Status(http.StatusOK)
+	})
+	return engine
+}
+
+// login posts a login attempt from ip and returns the status and Retry-After header
+func login(engine *gin.Engine, ip, key string) (int, string) {
+	req := httptest.NewRequest(http.MethodPost, "/login?key="+key, nil)
+	req.RemoteAddr = ip + ":40000"
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	return w.Code, w.Header().Get("Retry-After")
+}
+
+func TestPublicRateLimit(t *testing.T) {
+	limiter := ratelimit.NewIPLimiter(ratelimit.IPLimiterConfig{
+		RequestsPerMinute: 30, Burst: 3, Lockout: time.Minute, MaxLockout: time.Hour, IdleTimeout: time.Hour,
+	})
+	engine := newLoginEngine(PublicRateLimit(limiter))
+
+	for i := range 3 {
+		if status, _ := login(engine, "192.0.2.1", "right"); status != http.StatusOK {
+			t.Fatalf("request %d under the limit: status = %d, want 200", i+1, status)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/login?key=right", nil)
+	req.RemoteAddr = "192.0.2.1:40000"
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "2" {
+		t.Errorf("request over the limit: status = %d, Retry-After = %q; want 429, 2",
+			w.Code, w.Header().Get("Retry-After"))
+	}
+	var body struct {
+		Code string `json:"code"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil || body.Code != ErrCodeRateLimitExceeded {
+		t.Errorf("body = %s, want code %s", w.Body.String(), ErrCodeRateLimitExceeded)
+	}
+
+	// Exceeding the public limit throttles without locking out
+	if remaining := limiter.LockoutRemaining("192.0.2.1", time.Now()); remaining != 0 {
+		t.Errorf("public limit locked the IP out for %s", remaining)
+	}
+	if status, _ := login(engine, "192.0.2.2", "right"); status != http.StatusOK {
+		t.Errorf("another IP: status = %d, want 200", status)
+	}
+}
+
+// loginLockout is the first lockout of newLoginLimiter, short enough for tests to wait lockouts out
+const loginLockout = 40 * time.Millisecond
+
+// newLoginLimiter allows 2 attempts in a row, refilled one per loginLockout, with lockouts from loginLockout
+// to 4*loginLockout
+func newLoginLimiter() *ratelimit.IPLimiter {
+	return ratelimit.NewIPLimiter(ratelimit.IPLimiterConfig{
+		RequestsPerMinute: int(time.Minute / loginLockout),
+		Burst:             2,
+		Lockout:           loginLockout,
+		MaxLockout:        4 * loginLockout,
+		IdleTimeout:       time.Hour,
+	})
+}
+
+// lockOut fails logins from ip until it is locked out, and returns the lockout
+func lockOut(t *testing.T, engine *gin.Engine, limiter *ratelimit.IPLimiter, ip string) time.Duration {
+	t.Helper()
+
+	for range 10 {
+		status, retryAfter := login(engine, ip, "wrong")
+		if status == http.StatusUnauthorized {
+			continue
+		}
+		if status != http.StatusTooManyRequests || retryAfter != "1" {
+			t.Fatalf("status = %d, Retry-After = %q; want 429, 1", status, retryAfter)
+		}
+		return limiter.LockoutRemaining(ip, time.Now())
+	}
+	t.Fatal("never locked out")
+	return 0
+}
+
+// assertLockout fails the test unless a lockout just started is about want long
+func assertLockout(t *testing.T, got, want time.Duration) {
+	t.Helper()
+
+	if got > want || got < want/2 {
+		t.Errorf("lockout = %s, want %s", got, want)
+	}
+}
+
+func TestLoginRateLimitLockoutProgression(t *testing.T) {
+	limiter := newLoginLimiter()
+	engine := newLoginEngine(LoginRateLimit(limiter))
+
+	for i := range 2 {
+		if status, _ := login(engine, "192.0.2.1", "wrong"); status != http.StatusUnauthorized {
+			t.Fatalf("attempt %d under the limit: status = %d, want 401", i+1, status)
+		}
+	}
+
+	// Each lockout doubles the previous one, up to login_max_lockout
+	for _, want := range []time.Duration{loginLockout, 2 * loginLockout, 4 * loginLockout, 4 * loginLockout} {
+		lockout := lockOut(t, engine, limiter, "192.0.2.1")
+		assertLockout(t, lockout, want)
+
+		// Attempts during the lockout, even with the right key, are refused without extending it
+		if status, _ := login(engine, "192.0.2.1", "right"); status != http.StatusTooManyRequests {
+			t.Errorf("attempt during the lockout: status = %d, want 429", status)
+		}
+		if remaining := limiter.LockoutRemaining("192.0.2.1", time.Now()); remaining > lockout {
+			t.Errorf("lockout extended from %s to %s", lockout, remaining)
+		}
+
+		// Other IPs are not affected
+		if status, _ := login(engine, "192.0.2.2", "right"); status != http.StatusOK {
+			t.Errorf("another IP: status = %d, want 200", status)
+		}
+		time.Sleep(limiter.LockoutRemaining("192.0.2.1", time.Now()))
+	}
+}
+
+func TestLoginRateLimitSuccessResetsProgression(t *testing.T) {
+	limiter := newLoginLimiter()
+	engine := newLoginEngine(LoginRateLimit(limiter))
+
+	assertLockout(t, lockOut(t, engine, limiter, "192.0.2.1"), loginLockout)
+	time.Sleep(limiter.LockoutRemaining("192.0.2.1", time.Now()) + loginLockout) // Refills a token
+
+	// A legitimate login after the lockout passes and clears the strikes, so the next lockout is the first again
+	if status, _ := login(engine, "192.0.2.1", "right"); status != http.StatusOK {
+		t.Fatalf("login after the lockout: status = %d, want 200", status)
+	}
+	assertLockout(t, lockOut(t, engine, limiter, "192.0.2.1"), loginLockout)
+}
@@ -0,0 +1,27 @@
+package middleware
+
+import (
+	"claude-proxy/pkg/requestid"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequestIDKey is the context key holding the request ID
+const RequestIDKey = "request_id"
+
+// RequestID assigns every request an ID, adopting a well-formed X-Request-Id sent by the client
+// The ID is echoed in the X-Request-Id response header and carried by the request context, so logs
+// of the proxy and of Claude API (its own request-id header) can be correlated
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(requestid.Header)
+		if !requestid.Valid(id) {
+			id = requestid.New()
+		}
+
+		c.Set(RequestIDKey, id)
+		c.Request = c.Request.WithContext(requestid.NewContext(c.Request.Context(), id))
+		c.Header(requestid.Header, id)
+		c.Next()
+	}
+}
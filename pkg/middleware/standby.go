@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"net/http"
+
+	"claude-proxy/modules/auth/domain/interfaces"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ErrCodeStandby is the error code returned while the instance is a warm standby
+const ErrCodeStandby = "STANDBY"
+
+// Standby rejects requests with 503 while the instance is a warm standby of another one (standby.enabled),
+// until promoted with POST /api/admin/promote
+func Standby(standbyService interfaces.StandbyService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !standbyService.Standby() {
+			c.Next()
+			return
+		}
+
+		message := "This proxy instance is a standby and does not serve requests until promoted"
+		AbortWithAnthropicErrorCode(c, http.StatusServiceUnavailable, ErrCodeStandby, message)
+	}
+}
+
+// RequireActive rejects the request with 503 while the instance is a warm standby: the next pull would overwrite
+// the change it makes, and refreshing an account would rotate the refresh token the primary still uses
+// Applied to the routes changing accounts, tokens, sessions and invites.
+func RequireActive(standbyService interfaces.StandbyService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !standbyService.Standby() {
+			c.Next()
+			return
+		}
+
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"code":    ErrCodeStandby,
+			"message": "Instance is a standby",
+			"details": "changes are made on the primary until this instance is promoted with POST /api/admin/promote",
+		})
+		c.Abort()
+	}
+}
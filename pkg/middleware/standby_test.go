@@ -0,0 +1,62 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"claude-proxy/modules/auth/domain/entities"
+
+	"github.com/gin-gonic/gin"
+)
+
+// stubStandby is a StandbyService whose standby state is set by the test
+type stubStandby struct {
+	standby bool
+}
+
+func (s *stubStandby) Standby() bool { return s.standby }
+func (s *stubStandby) Status() entities.StandbyStatus {
+	return entities.StandbyStatus{Standby: s.standby}
+}
+func (s *stubStandby) OnPromote(fn func()) {}
+func (s *stubStandby) Start()              {}
+func (s *stubStandby) Stop()               {}
+
+func (s *stubStandby) Promote(context.Context) (entities.StandbyStatus, error) {
+	s.standby = false
+	return s.Status(), nil
+}
+
+func TestRequireActive(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	for _, standby := range []bool{true, false} {
+		svc := &stubStandby{standby: standby}
+		engine := gin.New()
+		engine.POST("/api/accounts/:id/restore", RequireActive(svc), func(c *gin.Context) {
+			c.Status(http.StatusNoContent)
+		})
+
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/accounts/acc_1/restore", nil))
+
+		if !standby {
+			if w.Code != http.StatusNoContent {
+				t.Errorf("active instance: status = %d, want %d", w.Code, http.StatusNoContent)
+			}
+			continue
+		}
+		if w.Code != http.StatusServiceUnavailable {
+			t.Fatalf("standby: status = %d, want %d", w.Code, http.StatusServiceUnavailable)
+		}
+		var body struct {
+			Code string `json:"code"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil || body.Code != ErrCodeStandby {
+			t.Errorf("standby: body = %s, want code %s", w.Body.String(), ErrCodeStandby)
+		}
+	}
+}
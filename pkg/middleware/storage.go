@@ -0,0 +1,31 @@
+package middleware
+
+import (
+	"net/http"
+
+	"claude-proxy/pkg/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ErrCodeStorageReadOnly is the error code returned for changes refused because the data folder is read-only
+const ErrCodeStorageReadOnly = "STORAGE_READ_ONLY"
+
+// RequireWritableStorage rejects the request with 503 while the data folder is read-only, instead of letting the
+// change it makes succeed in memory and vanish on restart (storage.allow_readonly lets every request through)
+// Applied to the routes changing accounts, tokens and other saved data.
+func RequireWritableStorage(monitor *storage.Monitor) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := monitor.RequireWritable(); err != nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{
+				"code":    ErrCodeStorageReadOnly,
+				"message": "Storage is read-only",
+				"details": err.Error(),
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
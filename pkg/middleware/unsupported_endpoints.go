@@ -0,0 +1,78 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	sctx "github.com/phathdt/service-context"
+)
+
+// DefaultUnsupportedPaths are OpenAI endpoints with no Claude API equivalent, answered locally
+// so OpenAI-mode clients get a clear error instead of an upstream 404 that costs an account request
+var DefaultUnsupportedPaths = []string{
+	"/v1/embeddings",
+	"/v1/completions",
+	"/v1/moderations",
+	"/v1/images/**",
+	"/v1/audio/**",
+}
+
+// unsupportedEndpointMessages explains the default unsupported endpoints; other paths get a generic message
+var unsupportedEndpointMessages = map[string]string{
+	"/v1/embeddings": "Embeddings are not supported by this proxy: the Claude API has no embeddings endpoint. " +
+		"Use an embeddings provider directly, and /v1/chat/completions or /v1/messages for text generation.",
+	"/v1/completions": "The legacy completions endpoint is not supported by this proxy. " +
+		"Use /v1/chat/completions (or /v1/messages) instead.",
+}
+
+// UnsupportedEndpoints creates middleware answering requests to endpoints this proxy can't serve
+// with a 404 in the OpenAI error format, before any account is selected or upstream is contacted
+// The default list is extended with extraPaths (glob patterns, "/**" suffix for sub-paths).
+func UnsupportedEndpoints(extraPaths []string, logger sctx.Logger) (gin.HandlerFunc, error) {
+	if err := ValidatePathPatterns(extraPaths); err != nil {
+		return nil, err
+	}
+	unsupported := append(append([]string{}, DefaultUnsupportedPaths...), extraPaths...)
+
+	return func(c *gin.Context) {
+		requestPath := c.Request.URL.Path
+
+		for _, pattern := range unsupported {
+			if !MatchPathPattern(pattern, requestPath) {
+				continue
+			}
+
+			logger.Withs(sctx.Fields{
+				"method": c.Request.Method,
+				"path":   requestPath,
+			}).Debug("Rejected request to unsupported endpoint")
+
+			message, ok := unsupportedEndpointMessages[pattern]
+			if !ok {
+				message = fmt.Sprintf(
+					"%s is not supported by this proxy: the Claude API has no equivalent endpoint. "+
+						"Use /v1/chat/completions or /v1/messages instead.",
+					requestPath,
+				)
+			}
+			AbortWithOpenAIError(c, http.StatusNotFound, "unsupported_endpoint", message)
+			return
+		}
+
+		c.Next()
+	}, nil
+}
+
+// AbortWithOpenAIError aborts the request with an OpenAI-compatible error body:
+// {"error":{"message":"...","type":"invalid_request_error","param":null,"code":"..."}}
+func AbortWithOpenAIError(c *gin.Context, status int, code, message string) {
+	c.AbortWithStatusJSON(status, gin.H{
+		"error": gin.H{
+			"message": message,
+			"type":    "invalid_request_error",
+			"param":   nil,
+			"code":    code,
+		},
+	})
+}
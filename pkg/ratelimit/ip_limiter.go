@@ -0,0 +1,129 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// IPLimiterConfig configures an IPLimiter (zero values are replaced by config.LoadConfig defaults)
+type IPLimiterConfig struct {
+	RequestsPerMinute int           // Tokens refilled per minute
+	Burst             int           // Bucket size: requests allowed back to back
+	Lockout           time.Duration // First lockout of an IP penalized for exceeding the limit, doubled each time
+	MaxLockout        time.Duration // Longest lockout
+	IdleTimeout       time.Duration // Buckets unused this long (and no longer locked out) are dropped
+}
+
+// ipBucket is the token bucket and lockout state of one client IP
+type ipBucket struct {
+	tokens      float64
+	updatedAt   time.Time // Last refill
+	strikes     int       // Lockouts since the last reset, doubling the next one
+	lockedUntil time.Time
+}
+
+// IPLimiter is a token bucket per client IP, with an exponential lockout for IPs penalized repeatedly
+// Buckets are dropped after IdleTimeout without requests, so memory stays bounded by the IPs seen recently.
+type IPLimiter struct {
+	cfg       IPLimiterConfig
+	rate      float64 // Tokens per second
+	buckets   map[string]*ipBucket
+	lastSweep time.Time
+	mu        sync.Mutex
+}
+
+// NewIPLimiter creates a limiter with an empty bucket table
+func NewIPLimiter(cfg IPLimiterConfig) *IPLimiter {
+	return &IPLimiter{
+		cfg:       cfg,
+		rate:      float64(cfg.RequestsPerMinute) / 60,
+		buckets:   make(map[string]*ipBucket),
+		lastSweep: time.Now(),
+	}
+}
+
+// Allow takes a token from ip's bucket; when it is empty (or ip is locked out) it returns false and how long
+// until the next request would be allowed
+func (l *IPLimiter) Allow(ip string, now time.Time) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.sweep(now)
+	bucket := l.bucket(ip, now)
+	if now.Before(bucket.lockedUntil) {
+		return false, bucket.lockedUntil.Sub(now)
+	}
+	if bucket.tokens < 1 {
+		return false, time.Duration((1 - bucket.tokens) / l.rate * float64(time.Second))
+	}
+	bucket.tokens--
+	return true, 0
+}
+
+// Penalize locks ip out for the configured lockout, doubled for each earlier lockout since the last Reset and
+// capped at MaxLockout, and returns its length
+func (l *IPLimiter) Penalize(ip string, now time.Time) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	bucket := l.bucket(ip, now)
+	lockout := l.cfg.Lockout
+	for i := 0; i < bucket.strikes && lockout < l.cfg.MaxLockout; i++ {
+		lockout *= 2
+	}
+	lockout = min(lockout, l.cfg.MaxLockout)
+	bucket.strikes++
+	bucket.lockedUntil = now.Add(lockout)
+	return lockout
+}
+
+// LockoutRemaining returns how long ip stays locked out (0 if it isn't)
+func (l *IPLimiter) LockoutRemaining(ip string, now time.Time) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if bucket, ok := l.buckets[ip]; ok && now.Before(bucket.lockedUntil) {
+		return bucket.lockedUntil.Sub(now)
+	}
+	return 0
+}
+
+// Reset clears the lockout progression of ip (after it authenticated successfully)
+func (l *IPLimiter) Reset(ip string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if bucket, ok := l.buckets[ip]; ok {
+		bucket.strikes = 0
+		bucket.lockedUntil = time.Time{}
+	}
+}
+
+// bucket returns ip's bucket refilled up to now, creating a full one for a new IP (caller holds mu)
+func (l *IPLimiter) bucket(ip string, now time.Time) *ipBucket {
+	bucket, ok := l.buckets[ip]
+	if !ok {
+		bucket = &ipBucket{tokens: float64(l.cfg.Burst), updatedAt: now}
+		l.buckets[ip] = bucket
+		return bucket
+	}
+	if elapsed := now.Sub(bucket.updatedAt).Seconds(); elapsed > 0 {
+		bucket.tokens = min(bucket.tokens+elapsed*l.rate, float64(l.cfg.Burst))
+		bucket.updatedAt = now
+	}
+	return bucket
+}
+
+// sweep drops the buckets idle for IdleTimeout and no longer locked out, at most once per half IdleTimeout
+// (caller holds mu); a dropped IP starts over with a full bucket and no strikes
+func (l *IPLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < l.cfg.IdleTimeout/2 {
+		return
+	}
+	l.lastSweep = now
+	for ip, bucket := range l.buckets {
+		if now.Sub(bucket.updatedAt) >= l.cfg.IdleTimeout && !now.Before(bucket.lockedUntil) {
+			delete(l.buckets, ip)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+// testLimiterConfig refills one request per second, with bursts of 5 and lockouts from 1m to 10m
+var testLimiterConfig = IPLimiterConfig{
+	RequestsPerMinute: 60,
+	Burst:             5,
+	Lockout:           time.Minute,
+	MaxLockout:        10 * time.Minute,
+	IdleTimeout:       10 * time.Minute,
+}
+
+func TestIPLimiterAllowsTrafficUnderLimit(t *testing.T) {
+	limiter := NewIPLimiter(testLimiterConfig)
+	start := time.Now()
+
+	// A steady request per second never runs the bucket dry
+	for i := range 600 {
+		now := start.Add(time.Duration(i) * time.Second)
+		if ok, _ := limiter.Allow("192.0.2.1", now); !ok {
+			t.Fatalf("request %d at 1/s refused", i)
+		}
+	}
+
+	// Bursts up to the bucket size pass once it refilled
+	now := start.Add(time.Hour)
+	for i := range testLimiterConfig.Burst {
+		if ok, _ := limiter.Allow("192.0.2.1", now); !ok {
+			t.Fatalf("burst request %d refused", i)
+		}
+	}
+}
+
+func TestIPLimiterRefusesOverLimit(t *testing.T) {
+	limiter := NewIPLimiter(testLimiterConfig)
+	now := time.Now()
+
+	for range testLimiterConfig.Burst {
+		limiter.Allow("192.0.2.1", now)
+	}
+	ok, retryAfter := limiter.Allow("192.0.2.1", now)
+	if ok || retryAfter != time.Second {
+		t.Errorf("Allow() over the burst = %v, %s; want false, 1s", ok, retryAfter)
+	}
+
+	// Other IPs keep their own buckets
+	if ok, _ := limiter.Allow("192.0.2.2", now); !ok {
+		t.Error("another IP is refused")
+	}
+
+	// Half a token later the wait halves; a full token later the request passes
+	if _, retryAfter := limiter.Allow("192.0.2.1", now.Add(500*time.Millisecond)); retryAfter != 500*time.Millisecond {
+		t.Errorf("retry after half a refill = %s, want 500ms", retryAfter)
+	}
+	if ok, _ := limiter.Allow("192.0.2.1", now.Add(time.Second)); !ok {
+		t.Error("request refused after a refill")
+	}
+}
+
+func TestIPLimiterLockoutProgression(t *testing.T) {
+	limiter := NewIPLimiter(testLimiterConfig)
+	now := time.Now()
+
+	// Each lockout doubles the previous one, up to MaxLockout
+	for i, want := range []time.Duration{
+		time.Minute, 2 * time.Minute, 4 * time.Minute, 8 * time.Minute, 10 * time.Minute, 10 * time.Minute,
+	} {
+		if got := limiter.Penalize("192.0.2.1", now); got != want {
+			t.Fatalf("lockout %d = %s, want %s", i+1, got, want)
+		}
+
+		// The IP is refused until the lockout ends, even with tokens left
+		if ok, retryAfter := limiter.Allow("192.0.2.1", now.Add(time.Second)); ok || retryAfter != want-time.Second {
+			t.Errorf("Allow() during lockout %d = %v, %s; want false, %s", i+1, ok, retryAfter, want-time.Second)
+		}
+		if got := limiter.LockoutRemaining("192.0.2.1", now.Add(time.Second)); got != want-time.Second {
+			t.Errorf("LockoutRemaining() during lockout %d = %s", i+1, got)
+		}
+		now = now.Add(want)
+		if got := limiter.LockoutRemaining("192.0.2.1", now); got != 0 {
+			t.Errorf("LockoutRemaining() after lockout %d = %s, want 0", i+1, got)
+		}
+		if ok, _ := limiter.Allow("192.0.2.1", now); !ok {
+			t.Errorf("request refused after lockout %d ended", i+1)
+		}
+	}
+
+	// Another IP starts at the first lockout, and so does the IP after a reset
+	if got := limiter.Penalize("192.0.2.2", now); got != time.Minute {
+		t.Errorf("first lockout of another IP = %s, want 1m", got)
+	}
+	limiter.Reset("192.0.2.1")
+	if got := limiter.LockoutRemaining("192.0.2.1", now); got != 0 {
+		t.Errorf("LockoutRemaining() after Reset = %s, want 0", got)
+	}
+	if got := limiter.Penalize("192.0.2.1", now); got != time.Minute {
+		t.Errorf("lockout after Reset = %s, want 1m", got)
+	}
+}
+
+func TestIPLimiterDropsIdleBuckets(t *testing.T) {
+	cfg := testLimiterConfig
+	cfg.IdleTimeout = 5 * time.Minute
+	limiter := NewIPLimiter(cfg)
+	now := time.Now()
+
+	limiter.Penalize("192.0.2.1", now) // Locked out for 1m
+	for range 5 {
+		limiter.Penalize("192.0.2.2", now) // Up to 10m
+	}
+
+	// Past IdleTimeout the IP whose lockout ended is forgotten with its strikes; the locked out IP is kept
+	later := now.Add(cfg.IdleTimeout + time.Second)
+	limiter.Allow("192.0.2.3", later)
+	if _, ok := limiter.buckets["192.0.2.1"]; ok {
+		t.Error("idle bucket kept")
+	}
+	if got := limiter.LockoutRemaining("192.0.2.2", later); got != 10*time.Minute-cfg.IdleTimeout-time.Second {
+		t.Errorf("lockout remaining after the sweep = %s", got)
+	}
+	if got := limiter.Penalize("192.0.2.1", later); got != time.Minute {
+		t.Errorf("lockout of a forgotten IP = %s, want 1m", got)
+	}
+}
@@ -0,0 +1,50 @@
+package requestid
+
+import (
+	"context"
+
+	"claude-proxy/pkg/uid"
+)
+
+const (
+	// Header carries the proxy's request ID: accepted from clients and echoed on every response
+	Header = "X-Request-Id"
+
+	// UpstreamHeader is the header Claude API identifies its requests with (relayed to clients as-is)
+	UpstreamHeader = "Request-Id"
+
+	// maxLength bounds request IDs accepted from clients
+	maxLength = 128
+)
+
+type contextKey struct{}
+
+// New generates a request ID
+func New() string {
+	return uid.New()
+}
+
+// Valid returns true if a client-supplied request ID can be adopted: 1 to 128 letters, digits, '-', '_', '.' or ':'
+func Valid(id string) bool {
+	if id == "" || len(id) > maxLength {
+		return false
+	}
+	for _, r := range id {
+		isAlnum := (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9')
+		if !isAlnum && r != '-' && r != '_' && r != '.' && r != ':' {
+			return false
+		}
+	}
+	return true
+}
+
+// NewContext returns a copy of ctx carrying the request ID
+func NewContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// FromContext returns the request ID carried by ctx (empty if none)
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(contextKey{}).(string)
+	return id
+}
@@ -0,0 +1,46 @@
+package requestid
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestValid(t *testing.T) {
+	tests := []struct {
+		id   string
+		want bool
+	}{
+		{"req-1", true},
+		{"0192f3a4-5b6c-7d8e-9f00-112233445566", true},
+		{"trace.span:1_a", true},
+		{strings.Repeat("a", maxLength), true},
+		{"", false},
+		{strings.Repeat("a", maxLength+1), false},
+		{"has space", false},
+		{"line\nbreak", false},
+		{"semi;colon", false},
+		{"é", false},
+	}
+	for _, tt := range tests {
+		if got := Valid(tt.id); got != tt.want {
+			t.Errorf("Valid(%q) = %v, want %v", tt.id, got, tt.want)
+		}
+	}
+}
+
+func TestNewIsValid(t *testing.T) {
+	first, second := New(), New()
+	if !Valid(first) || first == second {
+		t.Errorf("New() = %q then %q, want distinct valid request IDs", first, second)
+	}
+}
+
+func TestContext(t *testing.T) {
+	if id := FromContext(context.Background()); id != "" {
+		t.Errorf("FromContext() of a bare context = %q, want none", id)
+	}
+	if id := FromContext(NewContext(context.Background(), "req-1")); id != "req-1" {
+		t.Errorf("FromContext() = %q, want req-1", id)
+	}
+}
@@ -0,0 +1,108 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// ErrReadOnly is returned for changes that could not be saved because the data folder is read-only
+var ErrReadOnly = errors.New("storage is read-only")
+
+// probeFileName is the file created and removed to test whether the data folder is writable
+const probeFileName = ".write-probe"
+
+// Status is the outcome of the last writability probe of the data folder
+type Status struct {
+	ReadOnly      bool
+	AllowReadOnly bool   // storage.allow_readonly: running in memory only is accepted
+	Error         string // Why the probe failed (empty when writable)
+	CheckedAt     time.Time
+}
+
+// Monitor tracks whether the data folder is writable
+// Services keep working in memory on a read-only folder, but nothing they change survives a restart, so changes
+// users expect to keep (new accounts, tokens, OAuth exchanges) are refused up front unless storage.allow_readonly
+// accepts an ephemeral deployment. The folder is probed again whenever it is consulted while read-only, so a
+// remount is picked up without a restart.
+type Monitor struct {
+	dir           string
+	allowReadOnly bool
+	status        Status
+	mu            sync.RWMutex
+}
+
+// NewMonitor creates a monitor of dir (must be expanded) and probes it once
+func NewMonitor(dir string, allowReadOnly bool) *Monitor {
+	m := &Monitor{dir: dir, allowReadOnly: allowReadOnly}
+	m.Probe()
+	return m
+}
+
+// Probe creates and removes a probe file in the data folder, records the outcome and returns its error
+func (m *Monitor) Probe() error {
+	err := probe(m.dir)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.status = Status{ReadOnly: err != nil, AllowReadOnly: m.allowReadOnly, CheckedAt: time.Now()}
+	if err != nil {
+		m.status.Error = err.Error()
+	}
+	return err
+}
+
+// Status returns the outcome of the last probe
+func (m *Monitor) Status() Status {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.status
+}
+
+// ReadOnly returns true if the data folder was read-only at the last probe
+func (m *Monitor) ReadOnly() bool {
+	return m.Status().ReadOnly
+}
+
+// AllowReadOnly returns true if running on a read-only data folder is accepted (storage.allow_readonly)
+func (m *Monitor) AllowReadOnly() bool {
+	return m.allowReadOnly
+}
+
+// RequireWritable returns ErrReadOnly if a change made now would not be saved and storage.allow_readonly
+// doesn't accept that; a folder read-only at the last probe is probed again first
+func (m *Monitor) RequireWritable() error {
+	if m.allowReadOnly || !m.ReadOnly() {
+		return nil
+	}
+	if err := m.Probe(); err != nil {
+		return fmt.Errorf("%w: %v", ErrReadOnly, err)
+	}
+	return nil
+}
+
+// CheckHealth is a readiness check: the data folder must be writable, unless storage.allow_readonly
+func (m *Monitor) CheckHealth(ctx context.Context) error {
+	err := m.Probe()
+	if err == nil || m.allowReadOnly {
+		return nil
+	}
+	return fmt.Errorf("data folder is not writable: %w", err)
+}
+
+// probe creates and removes a probe file in dir
+func probe(dir string) error {
+	path := filepath.Join(dir, probeFileName)
+	if err := os.WriteFile(path, []byte(time.Now().UTC().Format(time.RFC3339)), 0o600); err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil {
+		return fmt.Errorf("failed to remove probe file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,18 @@
+package uid
+
+import (
+	"github.com/google/uuid"
+)
+
+// New generates the ID of a stored entity: a UUIDv7, so IDs created later sort after earlier ones
+// Within the process IDs are strictly increasing, even when several are generated in the same millisecond
+func New() string {
+	return uuid.Must(uuid.NewV7()).String()
+}
+
+// IsUUID returns true if id is a UUID in canonical form (lowercase, hyphenated), whatever its version
+// IDs written by older releases (e.g. app_1716412345123456789) are not
+func IsUUID(id string) bool {
+	parsed, err := uuid.Parse(id)
+	return err == nil && parsed.String() == id
+}
@@ -0,0 +1,74 @@
+package uid
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestNewIsMonotonic(t *testing.T) {
+	// Far more IDs than milliseconds pass, so most share their millisecond with the previous one
+	const count = 100_000
+	previous := New()
+	for range count {
+		id := New()
+		if id <= previous {
+			t.Fatalf("New() = %s after %s, want it to sort after", id, previous)
+		}
+		previous = id
+	}
+}
+
+func TestNewIsMonotonicAcrossGoroutines(t *testing.T) {
+	const (
+		goroutines = 8
+		count      = 10_000
+	)
+	ids := make([][]string, goroutines)
+	var wg sync.WaitGroup
+	for g := range goroutines {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ids[g] = make([]string, count)
+			for i := range ids[g] {
+				ids[g][i] = New()
+			}
+		}()
+	}
+	wg.Wait()
+
+	seen := make(map[string]bool, goroutines*count)
+	for g := range ids {
+		for i, id := range ids[g] {
+			if i > 0 && id <= ids[g][i-1] {
+				t.Fatalf("goroutine %d: New() = %s after %s, want it to sort after", g, id, ids[g][i-1])
+			}
+			if seen[id] {
+				t.Fatalf("New() returned %s twice", id)
+			}
+			seen[id] = true
+		}
+	}
+}
+
+func TestIsUUID(t *testing.T) {
+	tests := []struct {
+		id   string
+		want bool
+	}{
+		{New(), true},
+		{"0192f3a4-5b6c-7d8e-9f00-112233445566", true},
+		{"6ba7b810-9dad-11d1-80b4-00c04fd430c8", true}, // UUIDv1
+		{"0192F3A4-5B6C-7D8E-9F00-112233445566", false},
+		{"0192f3a45b6c7d8e9f00112233445566", false},
+		{"{0192f3a4-5b6c-7d8e-9f00-112233445566}", false},
+		{"urn:uuid:0192f3a4-5b6c-7d8e-9f00-112233445566", false},
+		{"app_1716412345123456789", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := IsUUID(tt.id); got != tt.want {
+			t.Errorf("IsUUID(%q) = %v, want %v", tt.id, got, tt.want)
+		}
+	}
+}
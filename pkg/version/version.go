@@ -0,0 +1,27 @@
+package version
+
+import "runtime/debug"
+
+// Version is the application version, set at build time:
+// go build -ldflags "-X claude-proxy/pkg/version.Version=v1.2.3"
+var Version = "dev"
+
+// Commit is the source revision, set at build time:
+// go build -ldflags "-X claude-proxy/pkg/version.Commit=$(git rev-parse --short HEAD)"
+var Commit = ""
+
+// Revision returns Commit, falling back to the VCS revision the Go toolchain stamps into binaries
+// built from a git checkout ("unknown" when neither is available)
+func Revision() string {
+	if Commit != "" {
+		return Commit
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range info.Settings {
+			if setting.Key == "vcs.revision" {
+				return setting.Value
+			}
+		}
+	}
+	return "unknown"
+}